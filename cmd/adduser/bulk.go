@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// createdUser is a user created from a CSV file and their password.
+type createdUser struct {
+	user     *models.User
+	password string
+}
+
+// csvUser is a row of the CSV file. An empty password gets generated.
+type csvUser struct {
+	line     int
+	username string
+	password string
+}
+
+// addUsersFromCSV creates the users listed in a CSV file and prints their
+// credentials as CSV, or writes them to the -credentials file.
+func addUsersFromCSV(opts options, stdin io.Reader, stdout, stderr io.Writer) error {
+	created, err := createUsersFromCSV(opts, stdin)
+	if err != nil {
+		return err
+	}
+	if opts.credentials != "" {
+		fmt.Fprintf(stdout, "Created %d users, credentials written to %s\n", len(created), opts.credentials)
+		return nil
+	}
+	if err := writeCredentials(stdout, created); err != nil {
+		return err
+	}
+	// On stderr, so stdout can be redirected to a file of just the credentials
+	fmt.Fprintf(stderr, "Created %d users\n", len(created))
+	return nil
+}
+
+// createUsersFromCSV creates the users listed in the -from-csv file, all of
+// them or none. Passwords left empty in the file are generated.
+func createUsersFromCSV(opts options, stdin io.Reader) ([]createdUser, error) {
+	if opts.username != "" || opts.password != "" || opts.passwordFile != "" || opts.passwordStdin {
+		return nil, invalidInput("-from-csv can't be combined with -user or a password flag")
+	}
+	if opts.email != "" {
+		return nil, invalidInput("-email can't be combined with -from-csv")
+	}
+	if err := validateRole(opts.role); err != nil {
+		return nil, err
+	}
+
+	in := stdin
+	if opts.fromCSV != "-" {
+		f, err := os.Open(opts.fromCSV)
+		if err != nil {
+			return nil, invalidInput("failed to open %s: %w", opts.fromCSV, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	rows, err := readUsersCSV(in)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openDB(opts.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	for _, row := range rows {
+		if _, err := db.GetUserByUsername(row.username); err == nil {
+			return nil, &userError{code: "user_exists", err: fmt.Errorf("line %d: user %s already exists", row.line, row.username)}
+		}
+	}
+
+	// Create the file before the users, so their passwords can't get lost
+	var credentials *os.File
+	if opts.credentials != "" {
+		credentials, err = os.OpenFile(opts.credentials, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create credentials file: %w", err)
+		}
+		defer credentials.Close()
+	}
+
+	newUsers := make([]storage.NewUser, 0, len(rows))
+	passwords := make([]string, 0, len(rows))
+	for _, row := range rows {
+		password := row.password
+		if password == "" {
+			if password, err = auth.GenerateRandomPassword(); err != nil {
+				return nil, fmt.Errorf("failed to generate password: %w", err)
+			}
+		}
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		newUsers = append(newUsers, storage.NewUser{Username: row.username, PasswordHash: hash, Role: opts.role})
+		passwords = append(passwords, password)
+	}
+
+	users, err := db.CreateUsers(newUsers)
+	if err != nil {
+		if credentials != nil {
+			credentials.Close()
+			os.Remove(opts.credentials)
+		}
+		return nil, fmt.Errorf("failed to create users: %w", err)
+	}
+	created := make([]createdUser, len(users))
+	for i, user := range users {
+		created[i] = createdUser{user: user, password: passwords[i]}
+	}
+
+	if credentials != nil {
+		if err := writeCredentials(credentials, created); err != nil {
+			return created, fmt.Errorf("users created, but failed to write credentials: %w", err)
+		}
+		if err := credentials.Close(); err != nil {
+			return created, fmt.Errorf("users created, but failed to write credentials: %w", err)
+		}
+	}
+	return created, nil
+}
+
+// readUsersCSV reads rows of username and optional password. A first row
+// whose first column is "username" is taken as a header and skipped.
+func readUsersCSV(r io.Reader) ([]csvUser, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []csvUser
+	seen := map[string]int{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, invalidInput("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		username := strings.TrimSpace(record[0])
+		if first && strings.EqualFold(username, "username") {
+			continue
+		}
+		if len(record) > 2 {
+			return nil, invalidInput("line %d: expected username and password, got %d columns", line, len(record))
+		}
+		if username == "" {
+			return nil, invalidInput("line %d: missing username", line)
+		}
+		if previous, ok := seen[username]; ok {
+			return nil, invalidInput("line %d: %s is already listed on line %d", line, username, previous)
+		}
+		seen[username] = line
+
+		row := csvUser{line: line, username: username}
+		if len(record) == 2 {
+			row.password = record[1]
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, invalidInput("no users in the CSV file")
+	}
+	return rows, nil
+}
+
+// writeCredentials writes the usernames and passwords of created users as CSV.
+func writeCredentials(w io.Writer, created []createdUser) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"username", "password"}); err != nil {
+		return err
+	}
+	for _, c := range created {
+		if err := out.Write([]string{c.user.Username, c.password}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FromCSV(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test_csv.db")
+	csvPath := filepath.Join(tmpDir, "team.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("username,password\nalice,alice-secret\nbob\ncarol,\n"), 0o600))
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err := run([]string{"-from-csv", csvPath, "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stderr.String(), "Created 3 users")
+
+	records, err := csv.NewReader(stdout).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"username", "password"}, records[0])
+	assert.Equal(t, []string{"alice", "alice-secret"}, records[1])
+	for _, record := range records[1:] {
+		assert.NotEmpty(t, record[1], "missing passwords are generated")
+		assertPassword(t, dbPath, record[0], record[1])
+	}
+}
+
+func TestRun_FromCSVCredentialsFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test_csv_credentials.db")
+	credentialsPath := filepath.Join(tmpDir, "credentials.csv")
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"-from-csv", "-", "-credentials", credentialsPath, "-json", "-db", dbPath}, strings.NewReader("alice\nbob\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+
+	var result []userJSON
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	require.Len(t, result, 2)
+	assert.Equal(t, "bob", result[1].Username)
+	assert.Empty(t, result[1].Password, "passwords only go to the credentials file")
+
+	info, err := os.Stat(credentialsPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(credentialsPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "alice,")
+
+	// The credentials file is never overwritten
+	err = run([]string{"-from-csv", "-", "-credentials", credentialsPath, "-db", dbPath}, strings.NewReader("carol\n"), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+}
+
+func TestRun_FromCSVCreatesAllOrNone(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_csv_none.db")
+	require.NoError(t, run([]string{"-user", "bob", "-password", "secret", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)))
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"-from-csv", "-", "-json", "-db", dbPath}, strings.NewReader("alice\nbob\n"), stdout, new(bytes.Buffer))
+	assert.ErrorIs(t, err, errReported)
+	assert.JSONEq(t, `{"error":"line 2: user bob already exists","code":"user_exists"}`, stdout.String())
+
+	err = run([]string{"-from-csv", "-", "-db", dbPath}, strings.NewReader("carol\ncarol\n"), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2: carol is already listed on line 1")
+
+	err = run([]string{"-from-csv", "-", "-user", "dave", "-db", dbPath}, strings.NewReader("dave\n"), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't be combined")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	count, err := db.UserCount()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "only bob exists")
+}
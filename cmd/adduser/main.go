@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -9,17 +11,65 @@ import (
 	"strings"
 
 	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
 	"expense-tracker/internal/storage"
 
 	"golang.org/x/term"
 )
 
+// options are the flags of a run.
+type options struct {
+	username      string
+	password      string
+	passwordFile  string
+	passwordStdin bool
+	dbPath        string
+	role          string
+	email         string
+	fromCSV       string
+	credentials   string
+}
+
+// errReported is returned by run when it already printed the error as JSON.
+var errReported = errors.New("error already reported")
+
+// userError is an error caused by the input rather than the environment,
+// with a code for -json output that scripts can match on.
+type userError struct {
+	code string
+	err  error
+}
+
+func (e *userError) Error() string { return e.err.Error() }
+func (e *userError) Unwrap() error { return e.err }
+
+// invalidInput wraps a message about bad flags or input as a userError.
+func invalidInput(format string, args ...any) error {
+	return &userError{code: "invalid_input", err: fmt.Errorf(format, args...)}
+}
+
+// userJSON is how -json prints a created user. The password is only included
+// for users created from a CSV file.
+type userJSON struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+}
+
+// errorJSON is how -json prints an error.
+type errorJSON struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
 func main() {
 	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
 		if err == flag.ErrHelp {
 			os.Exit(0)
 		}
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !errors.Is(err, errReported) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 		os.Exit(1)
 	}
 }
@@ -28,64 +78,198 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	fs := flag.NewFlagSet("adduser", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	username := fs.String("user", "", "Username")
-	passwordFlag := fs.String("password", "", "Password (optional, will prompt if omitted)")
-	dbPath := fs.String("db", "expenses.db", "Path to database file")
+	var opts options
+	fs.StringVar(&opts.username, "user", "", "Username")
+	fs.StringVar(&opts.password, "password", "", "Password (optional, will prompt if omitted)")
+	fs.StringVar(&opts.passwordFile, "password-file", "", "Read the password from this file, e.g. a Docker secret (or set PASSWORD_FILE)")
+	fs.BoolVar(&opts.passwordStdin, "password-stdin", false, "Read the password from stdin without prompting")
+	fs.StringVar(&opts.dbPath, "db", "expenses.db", "Path to database file")
+	fs.StringVar(&opts.role, "role", "", "Role: admin or member (default admin for the first user, member after)")
+	fs.StringVar(&opts.email, "email", "", "Email address, counted as verified")
+	fs.StringVar(&opts.fromCSV, "from-csv", "", "Create the users listed in this CSV file (- for stdin) instead of one user")
+	fs.StringVar(&opts.credentials, "credentials", "", "With -from-csv, write the usernames and passwords to this new file instead of stdout")
+	jsonOutput := fs.Bool("json", false, "Print the result, or the error, as JSON on stdout")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *username == "" {
-		fmt.Fprintln(stdout, "Usage: adduser -user <username> [-password <password>] [-db <db_path>]")
-		fs.PrintDefaults()
-		return fmt.Errorf("missing required flags: user")
+	if opts.fromCSV != "" {
+		if !*jsonOutput {
+			return addUsersFromCSV(opts, stdin, stdout, stderr)
+		}
+		created, err := createUsersFromCSV(opts, stdin)
+		if err != nil {
+			return reportJSON(stdout, nil, err)
+		}
+		result := make([]userJSON, len(created))
+		for i, c := range created {
+			result[i] = userJSON{ID: c.user.ID, Username: c.user.Username}
+			// Passwords written to a file stay out of the output
+			if opts.credentials == "" {
+				result[i].Password = c.password
+			}
+		}
+		return reportJSON(stdout, result, nil)
+	}
+
+	if !*jsonOutput {
+		if opts.username == "" {
+			fmt.Fprintln(stdout, "Usage: adduser -user <username> [-password <password> | -password-file <path> | -password-stdin] [-role admin|member] [-email <address>] [-db <db_path>] [-json]")
+			fmt.Fprintln(stdout, "       adduser -from-csv <file> [-credentials <file>] [-role admin|member] [-db <db_path>] [-json]")
+			fs.PrintDefaults()
+		}
+		return addUser(opts, stdin, stdout)
+	}
+
+	// Keep stdout for the JSON, the password prompt goes to stderr
+	user, err := createUser(opts, stdin, stderr)
+	if err != nil {
+		return reportJSON(stdout, nil, err)
+	}
+	return reportJSON(stdout, userJSON{ID: user.ID, Username: user.Username}, nil)
+}
+
+// reportJSON prints result, or err if it isn't nil, as JSON. It returns
+// errReported after printing an error.
+func reportJSON(w io.Writer, result any, err error) error {
+	if err != nil {
+		out := errorJSON{Error: err.Error(), Code: "failed"}
+		var ue *userError
+		if errors.As(err, &ue) {
+			out.Code = ue.code
+		}
+		if encodeErr := json.NewEncoder(w).Encode(out); encodeErr != nil {
+			return encodeErr
+		}
+		return errReported
+	}
+	return json.NewEncoder(w).Encode(result)
+}
+
+// addUser creates a user and reports it in words.
+func addUser(opts options, stdin io.Reader, stdout io.Writer) error {
+	user, err := createUser(opts, stdin, stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "User %s created successfully with ID %d\n", user.Username, user.ID)
+	return nil
+}
+
+// createUser reads the password from whichever source was given and creates
+// the user. Prompts are written to prompt.
+func createUser(opts options, stdin io.Reader, prompt io.Writer) (*models.User, error) {
+	if opts.username == "" {
+		return nil, invalidInput("missing required flags: user")
+	}
+	if opts.credentials != "" {
+		return nil, invalidInput("-credentials only applies to -from-csv")
+	}
+	if err := validateRole(opts.role); err != nil {
+		return nil, err
+	}
+	if opts.email != "" {
+		if err := auth.ValidateEmail(opts.email); err != nil {
+			return nil, invalidInput("%w", err)
+		}
+	}
+
+	sources := 0
+	for _, set := range []bool{opts.password != "", opts.passwordFile != "", opts.passwordStdin} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, invalidInput("use only one of -password, -password-file, and -password-stdin")
+	}
+	// The environment only counts when no flag says where the password is
+	if sources == 0 {
+		opts.passwordFile = os.Getenv("PASSWORD_FILE")
 	}
 
-	password := *passwordFlag
-	if password == "" {
-		fmt.Fprint(stdout, "Password: ")
+	password := opts.password
+	switch {
+	case opts.passwordFile != "":
+		data, err := os.ReadFile(opts.passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	case opts.passwordStdin:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	case password == "":
+		fmt.Fprint(prompt, "Password: ")
 		var err error
 		password, err = readPassword(stdin)
 		if err != nil {
-			return fmt.Errorf("failed to read password: %w", err)
+			return nil, fmt.Errorf("failed to read password: %w", err)
 		}
-		fmt.Fprintln(stdout) // Print newline after password input
+		fmt.Fprintln(prompt) // Print newline after password input
 	}
 
 	if strings.TrimSpace(password) == "" {
-		return fmt.Errorf("password cannot be empty")
-	}
-
-	// Allow overriding db path via env var if not explicitly set via flag (flag default is used)
-	if path := os.Getenv("DB_PATH"); path != "" && *dbPath == "expenses.db" {
-		*dbPath = path
+		return nil, invalidInput("password cannot be empty")
 	}
 
-	db, err := storage.NewDB(*dbPath)
+	db, err := openDB(opts.dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 	defer db.Close()
 
 	// Check if user already exists
-	existingUser, err := db.GetUserByUsername(*username)
+	existingUser, err := db.GetUserByUsername(opts.username)
 	if err == nil && existingUser != nil {
-		return fmt.Errorf("user %s already exists", *username)
+		return nil, &userError{code: "user_exists", err: fmt.Errorf("user %s already exists", opts.username)}
 	}
 
 	hash, err := auth.HashPassword(password)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	user, err := db.CreateUser(*username, hash)
+	users, err := db.CreateUsers([]storage.NewUser{{
+		Username:      opts.username,
+		PasswordHash:  hash,
+		Role:          opts.role,
+		Email:         opts.email,
+		EmailVerified: true,
+	}})
+	if errors.Is(err, storage.ErrEmailTaken) {
+		return nil, &userError{code: "email_taken", err: fmt.Errorf("email address %s is already in use", opts.email)}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	return users[0], nil
+}
 
-	fmt.Fprintf(stdout, "User %s created successfully with ID %d\n", user.Username, user.ID)
-	return nil
+// validateRole checks the -role flag, which may be empty for the default.
+func validateRole(role string) error {
+	switch role {
+	case "", models.RoleAdmin, models.RoleMember:
+		return nil
+	}
+	return invalidInput("unknown role %q, use %s or %s", role, models.RoleAdmin, models.RoleMember)
+}
+
+// openDB opens the database at path, or at DB_PATH if path is the default.
+func openDB(path string) (*storage.DB, error) {
+	// Allow overriding db path via env var if not explicitly set via flag (flag default is used)
+	if env := os.Getenv("DB_PATH"); env != "" && path == "expenses.db" {
+		path = env
+	}
+	db, err := storage.NewDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
 }
 
 func readPassword(stdin io.Reader) (string, error) {
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/storage"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -138,3 +143,106 @@ func TestRun_InvalidFlag(t *testing.T) {
 	require.Error(t, err, "expected error for invalid flag")
 	assert.Contains(t, err.Error(), "flag provided but not defined")
 }
+
+func TestRun_PasswordFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test_password_file.db")
+	passwordPath := filepath.Join(tmpDir, "password")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("from file\n"), 0o600))
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"-user", "alice", "-password-file", passwordPath, "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.NotContains(t, stdout.String(), "Password:", "no prompt")
+	assertPassword(t, dbPath, "alice", "from file")
+
+	// PASSWORD_FILE works the same, for Docker secrets
+	t.Setenv("PASSWORD_FILE", passwordPath)
+	err = run([]string{"-user", "bob", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.NoError(t, err)
+	assertPassword(t, dbPath, "bob", "from file")
+
+	err = run([]string{"-user", "carol", "-password-file", filepath.Join(tmpDir, "missing"), "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+}
+
+func TestRun_PasswordStdin(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_password_stdin.db")
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"-user", "alice", "-password-stdin", "-db", dbPath}, strings.NewReader("with spaces \n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.NotContains(t, stdout.String(), "Password:")
+	assertPassword(t, dbPath, "alice", "with spaces ")
+
+	err = run([]string{"-user", "bob", "-password-stdin", "-password", "secret", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use only one of")
+}
+
+// assertPassword checks that a user was created with a password.
+func assertPassword(t *testing.T, dbPath, username, password string) {
+	t.Helper()
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	user, err := db.GetUserByUsername(username)
+	require.NoError(t, err)
+	assert.True(t, auth.CheckPassword(password, user.PasswordHash))
+}
+
+func TestRun_JSON(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_json.db")
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err := run([]string{"-user", "alice", "-json", "-db", dbPath}, strings.NewReader("secret\n"), stdout, stderr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"username":"alice"}`, stdout.String())
+	assert.Contains(t, stderr.String(), "Password:", "the prompt stays out of the JSON")
+
+	stdout.Reset()
+	err = run([]string{"-user", "alice", "-password", "secret", "-json", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	assert.ErrorIs(t, err, errReported)
+	assert.JSONEq(t, `{"error":"user alice already exists","code":"user_exists"}`, stdout.String())
+
+	stdout.Reset()
+	err = run([]string{"-password", "secret", "-json", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	assert.ErrorIs(t, err, errReported)
+	assert.JSONEq(t, `{"error":"missing required flags: user","code":"invalid_input"}`, stdout.String())
+}
+
+func TestRun_RoleAndEmail(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_role.db")
+
+	err := run([]string{"-user", "owner", "-password", "secret", "-role", "member", "-email", "owner@example.com", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.NoError(t, err)
+	err = run([]string{"-user", "boss", "-password", "secret", "-role", "admin", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.NoError(t, err)
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	owner, err := db.GetUserByUsername("owner")
+	require.NoError(t, err)
+	assert.False(t, owner.IsAdmin(), "-role overrides the first user becoming admin")
+	assert.Equal(t, "owner@example.com", owner.Email)
+	assert.True(t, owner.EmailVerified)
+	boss, err := db.GetUserByUsername("boss")
+	require.NoError(t, err)
+	assert.True(t, boss.IsAdmin())
+
+	err = run([]string{"-user", "carol", "-password", "secret", "-role", "owner", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown role")
+
+	err = run([]string{"-user", "carol", "-password", "secret", "-email", "not-an-address", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"-user", "carol", "-password", "secret", "-email", "OWNER@example.com", "-json", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	assert.ErrorIs(t, err, errReported)
+	assert.Contains(t, stdout.String(), `"code":"email_taken"`)
+	_, err = db.GetUserByUsername("carol")
+	assert.Error(t, err, "nothing is created when the email is taken")
+}
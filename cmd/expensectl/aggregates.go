@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+func runAggregates(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "rebuild" {
+		fmt.Fprintln(stdout, "Usage: expensectl aggregates rebuild [-db <db_path>]")
+		return fmt.Errorf("missing subcommand: rebuild")
+	}
+
+	fs := flag.NewFlagSet("aggregates rebuild", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.RebuildAggregates()
+	if err != nil {
+		return fmt.Errorf("failed to rebuild aggregates: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Rebuilt statistics aggregates (%d rows)\n", rows)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregates_Rebuild(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_aggregates.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC), 1))
+	require.NoError(t, db.CreateExpense(20, "Bus", "Transport", time.Date(2026, 1, 16, 12, 0, 0, 0, time.UTC), 1))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err = run([]string{"aggregates", "rebuild", "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Rebuilt statistics aggregates (2 rows)")
+}
+
+func TestAggregates_MissingSubcommand(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"aggregates"}, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing subcommand")
+}
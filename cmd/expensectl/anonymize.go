@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"expense-tracker/internal/storage"
+)
+
+func runAnonymize(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "", "Path of the anonymized copy, which must not exist yet")
+	jitter := fs.Float64("jitter", 0.1, "Change amounts by up to this fraction, 0 to keep them")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		fmt.Fprintln(stdout, "Usage: expensectl anonymize -output <path> [-jitter <fraction>] [-db <db_path>]")
+		return fmt.Errorf("missing required flags: output")
+	}
+	if *jitter < 0 || *jitter >= 1 {
+		return fmt.Errorf("-jitter must be at least 0 and less than 1")
+	}
+	if _, err := os.Stat(*output); err == nil {
+		return fmt.Errorf("%s already exists", *output)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Anonymize under another name, so a failure never leaves a copy with
+	// real data where the anonymized one was expected
+	partial := filepath.Join(filepath.Dir(*output), "."+filepath.Base(*output)+".partial")
+	if err := db.Backup(partial); err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("failed to copy the database: %w", err)
+	}
+	if err := anonymizeFile(partial, *jitter); err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("failed to anonymize the copy: %w", err)
+	}
+	if err := os.Rename(partial, *output); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Wrote an anonymized copy of the database to %s\n", *output)
+	return nil
+}
+
+// anonymizeFile anonymizes the database at path.
+func anonymizeFile(path string, jitter float64) error {
+	copied, err := storage.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := copied.Anonymize(jitter); err != nil {
+		copied.Close()
+		return err
+	}
+	return copied.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymize(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test_anonymize.db")
+	output := filepath.Join(dir, "shareable.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(42, "Pharmacy", "Health", time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), user.ID))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"anonymize", "-output", output, "-jitter", "0", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Wrote an anonymized copy")
+
+	copied, err := storage.NewDB(output)
+	require.NoError(t, err)
+	defer copied.Close()
+	_, err = copied.GetUserByUsername("alice")
+	assert.Error(t, err)
+	expenses, err := copied.GetExpensesByMonth(2026, 3)
+	require.NoError(t, err)
+	require.Len(t, expenses, 1)
+	assert.NotEqual(t, "Pharmacy", expenses[0].Description)
+	assert.Equal(t, 42.0, expenses[0].Amount, "no jitter")
+
+	original, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer original.Close()
+	_, err = original.GetUserByUsername("alice")
+	assert.NoError(t, err, "the original is untouched")
+
+	err = run([]string{"anonymize", "-output", output, "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+}
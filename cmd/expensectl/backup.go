@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// backupName matches the files backup writes, whose UTC timestamps sort in
+// the order they were taken.
+var backupName = regexp.MustCompile(`^expenses-\d{8}T\d{6}Z\.db$`)
+
+func runBackup(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", "", "Directory to write the backup to")
+	keep := fs.Int("keep", 0, "Delete all but this many of the newest backups in -dir, 0 to keep them all")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		fmt.Fprintln(stdout, "Usage: expensectl backup -dir <directory> [-keep <n>] [-db <db_path>]")
+		return fmt.Errorf("missing required flags: dir")
+	}
+	if *keep < 0 {
+		return fmt.Errorf("-keep must not be negative")
+	}
+	if err := os.MkdirAll(*dir, 0o750); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Write under a name pruning ignores, so a failed backup never replaces
+	// a good one
+	name := "expenses-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	path := filepath.Join(*dir, name)
+	partial := filepath.Join(*dir, "."+name+".partial")
+	if err := db.Backup(partial); err != nil {
+		_ = os.Remove(partial)
+		return fmt.Errorf("failed to back up the database: %w", err)
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Backed up the database to %s\n", path)
+
+	if *keep == 0 {
+		return nil
+	}
+	pruned, err := pruneBackups(*dir, *keep)
+	if err != nil {
+		return fmt.Errorf("failed to delete old backups: %w", err)
+	}
+	if pruned > 0 {
+		fmt.Fprintf(stdout, "Deleted %d old backups\n", pruned)
+	}
+	return nil
+}
+
+// pruneBackups deletes all but the newest keep backups in dir and returns
+// how many it deleted. Other files are left alone.
+func pruneBackups(dir string, keep int) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var backups []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && backupName.MatchString(e.Name()) {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return 0, nil
+	}
+	sort.Strings(backups)
+	old := backups[:len(backups)-keep]
+	for i, name := range old {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return i, err
+		}
+	}
+	return len(old), nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_backup.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	stdout := new(bytes.Buffer)
+	err = run([]string{"backup", "-dir", dir, "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Backed up the database to "+dir)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Regexp(t, backupName, entries[0].Name())
+
+	backup, err := storage.NewDB(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	defer backup.Close()
+	_, err = backup.GetUserByUsername("alice")
+	assert.NoError(t, err)
+}
+
+func TestBackup_Keep(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_backup.db")
+	dir := t.TempDir()
+	for _, name := range []string{
+		"expenses-20260101T030000Z.db",
+		"expenses-20260102T030000Z.db",
+		"expenses-20260103T030000Z.db",
+		"notes.txt",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"backup", "-dir", dir, "-keep", "2", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Deleted 2 old backups")
+
+	var names []string
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Len(t, names, 3)
+	assert.Equal(t, "expenses-20260103T030000Z.db", names[0], "the newest old backup is kept")
+	assert.Regexp(t, backupName, names[1], "so is the new one")
+	assert.Equal(t, "notes.txt", names[2], "other files are left alone")
+}
+
+func TestBackup_Errors(t *testing.T) {
+	err := run([]string{"backup"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dir")
+
+	err = run([]string{"backup", "-dir", t.TempDir(), "-keep", "-1"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"expense-tracker/internal/storage"
+)
+
+const categoriesUsage = "Usage: expensectl categories list [-db <db_path>]\n       expensectl categories add -name <category> [-db <db_path>]\n       expensectl categories rename -from <category> -to <name> [-db <db_path>]\n       expensectl categories merge -from <category> -into <category> [-force] [-dry-run] [-db <db_path>]\n       expensectl categories delete -name <category> [-dry-run] [-db <db_path>]"
+
+func runCategories(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, categoriesUsage)
+		return fmt.Errorf("missing subcommand: list, add, rename, merge, or delete")
+	}
+	switch args[0] {
+	case "list":
+		return runCategoriesList(args[1:], stdout, stderr)
+	case "add":
+		return runCategoriesAdd(args[1:], stdout, stderr)
+	case "rename":
+		return runCategoriesRename(args[1:], stdout, stderr)
+	case "merge":
+		return runCategoriesMerge(args[1:], stdin, stdout, stderr)
+	case "delete":
+		return runCategoriesDelete(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, categoriesUsage)
+		return fmt.Errorf("missing subcommand: list, add, rename, merge, or delete")
+	}
+}
+
+func runCategoriesList(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("categories list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	names, err := db.ListCategories()
+	if err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	for _, name := range names {
+		fmt.Fprintln(stdout, name)
+	}
+	return nil
+}
+
+func runCategoriesAdd(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("categories add", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("name", "", "Name of the new category")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	*name = strings.TrimSpace(*name)
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.AddCategory(*name); err != nil {
+		if errors.Is(err, storage.ErrCategoryExists) {
+			return fmt.Errorf("category %s already exists", *name)
+		}
+		return fmt.Errorf("failed to add category: %w", err)
+	}
+	fmt.Fprintf(stdout, "Added category %s\n", *name)
+	return nil
+}
+
+func runCategoriesRename(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("categories rename", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "Category to rename")
+	to := fs.String("to", "", "New name")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	*to = strings.TrimSpace(*to)
+	if *from == "" || *to == "" {
+		return fmt.Errorf("-from and -to are required")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	moved, err := db.RenameCategory(*from, *to)
+	switch {
+	case errors.Is(err, storage.ErrCategoryNotFound):
+		return fmt.Errorf("category %s not found", *from)
+	case errors.Is(err, storage.ErrCategoryExists):
+		return fmt.Errorf("category %s already exists, use categories merge to combine them", *to)
+	case err != nil:
+		return fmt.Errorf("failed to rename category: %w", err)
+	}
+	fmt.Fprintf(stdout, "Renamed %s to %s, moved %d expenses\n", *from, *to, moved)
+	return nil
+}
+
+func runCategoriesMerge(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("categories merge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "Category to merge and delete")
+	into := fs.String("into", "", "Category that gets its expenses")
+	force := fs.Bool("force", false, "Don't ask for confirmation")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *into == "" {
+		return fmt.Errorf("-from and -into are required")
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	if !*force {
+		ok, err := c.confirm(stdin, fmt.Sprintf("Move all %s expenses to %s and delete %s?", *from, *into, *from))
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("cancelled")
+		}
+	}
+
+	moved, err := db.MergeCategories(*from, *into)
+	if errors.Is(err, storage.ErrCategoryNotFound) {
+		return fmt.Errorf("categories %s and %s must both exist", *from, *into)
+	} else if err != nil {
+		return fmt.Errorf("failed to merge categories: %w", err)
+	}
+	fmt.Fprintf(stdout, "Merged %s into %s, moved %d expenses\n", *from, *into, moved)
+	return nil
+}
+
+func runCategoriesDelete(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("categories delete", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("name", "", "Category to delete")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	err = db.DeleteCategory(*name)
+	switch {
+	case errors.Is(err, storage.ErrCategoryNotFound):
+		return fmt.Errorf("category %s not found", *name)
+	case errors.Is(err, storage.ErrCategoryInUse):
+		return fmt.Errorf("category %s still has expenses, use categories merge to move them to another category", *name)
+	case err != nil:
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	fmt.Fprintf(stdout, "Deleted category %s\n", *name)
+	return nil
+}
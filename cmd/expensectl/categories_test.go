@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategories_AddAndRename(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_categories.db")
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"categories", "add", "-name", "Pets", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Added category Pets")
+
+	err = run([]string{"categories", "add", "-name", "pets", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+
+	err = run([]string{"categories", "rename", "-from", "Pets", "-to", "Groceries", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "categories merge")
+
+	stdout.Reset()
+	err = run([]string{"categories", "rename", "-from", "Pets", "-to", "Animals", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Renamed Pets to Animals")
+
+	stdout.Reset()
+	err = run([]string{"categories", "list", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Equal(t, "Groceries", lines[0])
+	assert.Equal(t, "Animals", lines[len(lines)-1])
+}
+
+func TestCategories_MergeAndDelete(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_categories_merge.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.CreateExpense(12, "Lunch", "Eating Out", day, 1))
+	require.NoError(t, db.CreateExpense(40, "Market", "Groceries", day, 1))
+	require.NoError(t, db.Close())
+
+	err = run([]string{"categories", "delete", "-name", "Eating Out", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "still has expenses")
+
+	err = run([]string{"categories", "merge", "-from", "Eating Out", "-into", "Groceries", "-db", dbPath}, strings.NewReader("n\n"), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cancelled")
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"categories", "merge", "-from", "Eating Out", "-into", "Groceries", "-db", dbPath}, strings.NewReader("y\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "moved 1 expenses")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	totals, err := db.GetCategoryTotalsByMonth(0, 2026, 3)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.Len(t, totals, 1)
+	assert.Equal(t, "Groceries", totals[0].Category)
+	assert.Equal(t, 52.0, totals[0].Total)
+
+	stdout.Reset()
+	err = run([]string{"categories", "delete", "-name", "Gifts", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Deleted category Gifts")
+
+	err = run([]string{"categories", "delete", "-name", "Gifts", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
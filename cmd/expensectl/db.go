@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+func runDB(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(stdout, "Usage: expensectl db check [-db <db_path>]")
+		return fmt.Errorf("missing subcommand: check")
+	}
+
+	fs := flag.NewFlagSet("db check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	problems, err := db.Check()
+	if err != nil {
+		return fmt.Errorf("failed to check database: %w", err)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintln(stdout, "No problems found")
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "Problem: %s\n  Fix: %s\n", p.Description, p.Fix)
+	}
+	return fmt.Errorf("found %d problems", len(problems))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Check(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_check.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC), user.ID))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"db", "check", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "No problems found")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(-3, "Refund", "Other", time.Date(2026, 1, 16, 12, 0, 0, 0, time.UTC), user.ID))
+	require.NoError(t, db.Close())
+
+	stdout.Reset()
+	err = run([]string{"db", "check", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 1 problems")
+	assert.Contains(t, stdout.String(), "Problem: 1 expenses have a negative amount (IDs 2)")
+	assert.Contains(t, stdout.String(), "Fix: ")
+}
+
+func TestDB_MissingSubcommand(t *testing.T) {
+	err := run([]string{"db"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing subcommand")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+func runDedupe(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Only look at this user's expenses")
+	days := fs.Int("days", 1, "Treat expenses with the same amount up to this many days apart as duplicates")
+	auto := fs.Bool("auto", false, "Keep the first of every group of duplicates without asking")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days < 0 {
+		return fmt.Errorf("-days can't be negative")
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	var userID int64
+	if *username != "" {
+		user, err := lookupUser(db, *username)
+		if err != nil {
+			return err
+		}
+		userID = user.ID
+	}
+	groups, err := db.FindDuplicates(userID, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %w", err)
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(stdout, "No duplicates found")
+		return nil
+	}
+
+	// One reader for every question, so answers piped in aren't lost to
+	// buffering
+	answers := bufio.NewReader(stdin)
+	var ids []int64
+	for _, group := range groups {
+		for i, e := range group {
+			mark := "  "
+			if i == 0 {
+				mark = "* "
+			}
+			fmt.Fprintf(stdout, "%s#%d  %s  %.2f  %s (%s)\n", mark, e.ID, e.Date.Format("2006-01-02 15:04"), e.Amount, e.Description, e.Category)
+		}
+		if !*auto {
+			ok, err := c.confirm(answers, fmt.Sprintf("Keep #%d and delete %d others?", group[0].ID, len(group)-1))
+			if err != nil {
+				return fmt.Errorf("failed to read confirmation: %w", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		ids = append(ids, duplicateIDs(group)...)
+	}
+
+	deleted, err := db.DeleteExpenses(ids)
+	if err != nil {
+		return fmt.Errorf("failed to delete duplicates: %w", err)
+	}
+	fmt.Fprintf(stdout, "Deleted %d duplicate expenses\n", deleted)
+	return nil
+}
+
+// duplicateIDs returns the IDs of all but the first expense of a group.
+func duplicateIDs(group []models.Expense) []int64 {
+	ids := make([]int64, 0, len(group)-1)
+	for _, e := range group[1:] {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupe(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_dedupe.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.CreateExpense(20, "Market", "Groceries", day, user.ID))
+	require.NoError(t, db.CreateExpense(20, "MARKET 0310", "Groceries", day.Add(time.Hour), user.ID))
+	require.NoError(t, db.CreateExpense(8, "Cinema", "Entertainment", day, user.ID))
+	require.NoError(t, db.CreateExpense(8, "CINEMA 0311", "Entertainment", day.AddDate(0, 0, 1), user.ID))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"dedupe", "-user", "alice", "-db", dbPath}, strings.NewReader("y\nn\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "MARKET 0310")
+	assert.Contains(t, stdout.String(), "Deleted 1 duplicate expenses", "only the first group")
+
+	stdout.Reset()
+	err = run([]string{"dedupe", "-auto", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Deleted 1 duplicate expenses")
+
+	stdout.Reset()
+	err = run([]string{"dedupe", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "No duplicates found")
+}
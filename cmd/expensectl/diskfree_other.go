@@ -0,0 +1,8 @@
+//go:build !(linux || darwin)
+
+package main
+
+// diskFree can't tell the free space on this platform, so it reports false.
+func diskFree(string) (uint64, bool, error) {
+	return 0, false, nil
+}
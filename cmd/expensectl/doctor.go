@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/mail"
+	"expense-tracker/internal/storage"
+	"expense-tracker/web"
+)
+
+// minFreeSpace is the free disk space below which doctor warns, since the
+// database, its journal, and backups need room to grow.
+const minFreeSpace = 100 << 20
+
+// Severities of a doctor finding.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// finding is the result of one doctor check.
+type finding struct {
+	severity string
+	message  string
+	fix      string // Empty when there's nothing to do
+}
+
+func runDoctor(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	// The server's own flags, environment, and config file, so the checks see
+	// exactly what the server would
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	var findings []finding
+	switch {
+	case cfg == nil:
+		// The settings couldn't even be read, so nothing else can be checked
+		findings = append(findings, finding{checkFail, "Configuration can't be read: " + err.Error(), "Correct the config file, environment variable, or flag named above"})
+	default:
+		if err != nil {
+			findings = append(findings, finding{checkFail, "Configuration is invalid: " + strings.ReplaceAll(err.Error(), "\n", "; "), "Correct the settings named above"})
+		} else {
+			findings = append(findings, finding{severity: checkOK, message: "Configuration is valid"})
+		}
+		findings = append(findings, checkDatabase(cfg.Database.Path)...)
+		findings = append(findings, checkTemplates(cfg))
+		findings = append(findings, checkSMTP(cfg.SMTP))
+	}
+
+	failures := 0
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "[%s] %s\n", f.severity, f.message)
+		if f.fix != "" {
+			fmt.Fprintf(stdout, "  Fix: %s\n", f.fix)
+		}
+		if f.severity == checkFail {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("found %d problems", failures)
+	}
+	return nil
+}
+
+// checkDatabase checks that the database can be opened without changing it,
+// is migrated, and has room to grow.
+func checkDatabase(path string) []finding {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(dir); err != nil {
+			return []finding{{checkFail, fmt.Sprintf("Database directory %s doesn't exist", dir), "Create it, or point DB_PATH at an existing directory"}}
+		}
+		return []finding{{severity: checkWarn, message: fmt.Sprintf("Database %s doesn't exist yet, the server creates it on first start", path)}}
+	}
+	if err != nil {
+		return []finding{{checkFail, fmt.Sprintf("Database %s can't be read: %v", path, err), "Check the file's permissions"}}
+	}
+
+	var findings []finding
+	missing, err := storage.MissingSchema(path)
+	switch {
+	case err != nil:
+		findings = append(findings, finding{checkFail, fmt.Sprintf("Database %s can't be opened: %v", path, err), "Check that it's an SQLite database, then run expensectl db check"})
+	case len(missing) > 0:
+		findings = append(findings, finding{checkWarn, fmt.Sprintf("Database %s isn't migrated to this version, it lacks %s", path, strings.Join(missing, ", ")), "Run expensectl migrate up, or start the server, which migrates it unless DB_AUTO_MIGRATE is off"})
+	default:
+		findings = append(findings, finding{severity: checkOK, message: fmt.Sprintf("Database %s is reachable and migrated", path)})
+	}
+
+	free, known, err := diskFree(filepath.Dir(path))
+	switch {
+	case err != nil:
+		findings = append(findings, finding{checkWarn, fmt.Sprintf("Free disk space can't be determined: %v", err), ""})
+	case !known:
+	case free < uint64(info.Size()):
+		findings = append(findings, finding{checkFail, fmt.Sprintf("Only %s free next to the %s database", formatBytes(free), formatBytes(uint64(info.Size()))), "Free up disk space, backups and migrations need room for a copy of the database"})
+	case free < minFreeSpace:
+		findings = append(findings, finding{checkWarn, fmt.Sprintf("Only %s of disk space free", formatBytes(free)), "Free up disk space before the database runs out of room"})
+	default:
+		findings = append(findings, finding{severity: checkOK, message: fmt.Sprintf("%s of disk space free", formatBytes(free))})
+	}
+	return findings
+}
+
+// checkTemplates checks that a template directory on disk has every template
+// the binary was built with.
+func checkTemplates(cfg *config.Config) finding {
+	webDir := cfg.WebDir
+	if cfg.Dev && webDir == "" {
+		webDir = "web"
+	}
+	if webDir == "" {
+		return finding{severity: checkOK, message: "Templates and static files are embedded in the binary"}
+	}
+
+	var missing []string
+	for _, dir := range []struct {
+		name     string
+		embedded fs.FS
+	}{{"templates", web.Templates()}, {"static", web.Static()}} {
+		onDisk := os.DirFS(filepath.Join(webDir, dir.name))
+		err := fs.WalkDir(dir.embedded, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if _, err := fs.Stat(onDisk, path); err != nil {
+				missing = append(missing, filepath.Join(dir.name, path))
+			}
+			return nil
+		})
+		if err != nil {
+			return finding{checkFail, fmt.Sprintf("Web files can't be listed: %v", err), ""}
+		}
+	}
+	if len(missing) > 0 {
+		if len(missing) > maxListedFiles {
+			missing = append(missing[:maxListedFiles], "…")
+		}
+		return finding{checkFail, fmt.Sprintf("Web files are missing from %s: %s", webDir, strings.Join(missing, ", ")), "Point WEB_DIR at a complete copy of the web directory, or unset it to use the embedded files"}
+	}
+	return finding{severity: checkOK, message: fmt.Sprintf("Templates and static files in %s are complete", webDir)}
+}
+
+// maxListedFiles is how many missing files doctor names.
+const maxListedFiles = 5
+
+// checkSMTP connects to the SMTP server, if one is set.
+func checkSMTP(cfg config.SMTP) finding {
+	if cfg.Host == "" {
+		return finding{severity: checkWarn, message: "No SMTP server set, emails are written to the log instead of sent"}
+	}
+	sender := mail.SMTPSender{Host: cfg.Host, Port: cfg.Port, Username: cfg.Username, Password: cfg.Password, From: cfg.From, TLS: cfg.TLS}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	if err := sender.Check(); err != nil {
+		return finding{checkFail, fmt.Sprintf("SMTP server %s doesn't work: %v", addr, err), "Check SMTP_HOST, SMTP_PORT, SMTP_TLS, and the credentials, and that outgoing connections are allowed"}
+	}
+	return finding{severity: checkOK, message: fmt.Sprintf("SMTP server %s accepts connections", addr)}
+}
+
+// formatBytes formats a size in the largest binary unit it reaches.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, exp := float64(n)/unit, 0
+	for size >= unit && exp < 3 {
+		size /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", size, "KMGT"[exp])
+}
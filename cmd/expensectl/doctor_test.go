@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctor_Healthy(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_doctor.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"doctor", "-db-path", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	out := stdout.String()
+	assert.Contains(t, out, "[ok] Configuration is valid")
+	assert.Contains(t, out, "[ok] Database "+dbPath+" is reachable and migrated")
+	assert.Contains(t, out, "[ok] Templates and static files are embedded in the binary")
+	assert.Contains(t, out, "[warn] No SMTP server set")
+}
+
+func TestDoctor_Problems(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "old.db")
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = conn.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT)")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	webDir := filepath.Join(dir, "web")
+	require.NoError(t, os.MkdirAll(filepath.Join(webDir, "templates"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(webDir, "templates", "base.html"), nil, 0o600))
+
+	// Nothing listens on a port that was just released
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"doctor", "-db-path", dbPath, "-web-dir", webDir,
+		"-smtp-host", "127.0.0.1", "-smtp-port", strconv.Itoa(port), "-smtp-from", "expenses@example.com", "-smtp-tls", "none"},
+		new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "found 2 problems")
+	out := stdout.String()
+	assert.Contains(t, out, "[warn] Database "+dbPath+" isn't migrated to this version, it lacks ")
+	assert.Contains(t, out, "[fail] Web files are missing from "+webDir+": ")
+	assert.NotContains(t, out, "templates/base.html")
+	assert.Contains(t, out, "[fail] SMTP server 127.0.0.1:"+strconv.Itoa(port)+" doesn't work")
+	assert.Contains(t, out, "  Fix: ")
+}
+
+func TestDoctor_InvalidConfig(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	err := run([]string{"doctor", "-db-path", filepath.Join(t.TempDir(), "new.db"), "-session-binding", "sometimes"}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.Error(t, err)
+	out := stdout.String()
+	assert.Contains(t, out, `[fail] Configuration is invalid: invalid session binding "sometimes"`)
+	assert.Contains(t, out, "doesn't exist yet, the server creates it on first start")
+
+	stdout.Reset()
+	err = run([]string{"doctor", "-smtp-port", "many"}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, stdout.String(), "[fail] Configuration can't be read")
+}
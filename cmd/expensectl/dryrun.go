@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"expense-tracker/internal/storage"
+)
+
+// dryRunPrefix starts every line a command prints in a dry run.
+const dryRunPrefix = "[dry run] "
+
+// change is the database a destructive command works on. In a dry run it is
+// a throwaway copy, so the command runs exactly as it would and prints what
+// it changed, while the real database stays as it was.
+type change struct {
+	db     *storage.DB
+	out    io.Writer // The command's output, marked line by line in a dry run
+	dryRun bool
+	dir    string // Holds the copy in a dry run
+}
+
+// dryRunFlag registers the shared -dry-run flag on a destructive
+// subcommand's flag set.
+func dryRunFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("dry-run", false, "Show what would change without changing anything")
+}
+
+// openChange opens the database for a destructive command, or with dryRun a
+// migrated copy of it in a temporary directory. Close removes the copy.
+func openChange(path string, dryRun bool, stdout io.Writer) (*change, error) {
+	if !dryRun {
+		db, err := openDB(path)
+		if err != nil {
+			return nil, err
+		}
+		return &change{db: db, out: stdout}, nil
+	}
+
+	// Not even migrations touch the real database
+	db, err := storage.Open(resolveDBPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	dir, err := os.MkdirTemp("", "expensectl-dry-run-")
+	if err != nil {
+		return nil, err
+	}
+	copyPath := filepath.Join(dir, "expenses.db")
+	if err := db.Backup(copyPath); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to copy the database for a dry run: %w", err)
+	}
+	copied, err := storage.NewDB(copyPath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to open the copy for a dry run: %w", err)
+	}
+	return &change{db: copied, out: &prefixWriter{w: stdout, prefix: dryRunPrefix}, dryRun: true, dir: dir}, nil
+}
+
+// confirm asks a yes or no question, except in a dry run, which goes ahead
+// without asking since nothing is changed.
+func (c *change) confirm(stdin io.Reader, question string) (bool, error) {
+	if c.dryRun {
+		return true, nil
+	}
+	return confirm(stdin, c.out, question)
+}
+
+// Close closes the database and removes the copy of a dry run.
+func (c *change) Close() error {
+	err := c.db.Close()
+	if c.dir != "" {
+		_ = os.RemoveAll(c.dir)
+	}
+	return err
+}
+
+// prefixWriter starts every line written to it with a prefix.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	midLine bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if !p.midLine {
+			if _, err := io.WriteString(p.w, p.prefix); err != nil {
+				return written, err
+			}
+		}
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i+1]
+		}
+		n, err := p.w.Write(line)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p.midLine = line[len(line)-1] != '\n'
+		b = b[len(line):]
+	}
+	return written, nil
+}
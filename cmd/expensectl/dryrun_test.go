@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun_UsersDelete(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_dry_run.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(5, "Coffee", "Eating Out", time.Now(), user.ID))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "delete", "-user", "alice", "-dry-run", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err, "a dry run doesn't ask for confirmation")
+	assert.Equal(t, "[dry run] Deleted user alice and 1 expenses\n", stdout.String())
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.GetUserByUsername("alice")
+	assert.NoError(t, err, "the user still exists")
+}
+
+func TestDryRun_DoesNotMigrate(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_dry_run_migrate.db")
+
+	db, err := storage.Open(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "purge", "-dry-run", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "[dry run] Purged 0 deleted accounts")
+
+	db, err = storage.Open(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	pending, err := db.PendingMigrations()
+	require.NoError(t, err)
+	assert.NotZero(t, pending)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	out := new(bytes.Buffer)
+	w := &prefixWriter{w: out, prefix: "> "}
+	_, err := w.Write([]byte("one\ntw"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("o\nthree"))
+	require.NoError(t, err)
+	assert.Equal(t, "> one\n> two\n> three", out.String())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/storage"
+)
+
+const expensesUsage = "Usage: expensectl expenses add -user <username> -amount <amount> -category <category> [-description <text>] [-date <YYYY-MM-DD[THH:MM]>] [-db <db_path>]\n       expensectl expenses archive -before <YYYY-MM-DD> [-dry-run] [-db <db_path>]\n       expensectl expenses reassign -user <username> -to-user <username> [-from <YYYY-MM-DD>] [-to <YYYY-MM-DD>] [-category <category>] [-dry-run] [-db <db_path>]"
+
+func runExpenses(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, expensesUsage)
+		return fmt.Errorf("missing subcommand: add, archive, or reassign")
+	}
+	switch args[0] {
+	case "add":
+		return runExpensesAdd(args[1:], stdout, stderr)
+	case "archive":
+		return runExpensesArchive(args[1:], stdout, stderr)
+	case "reassign":
+		return runExpensesReassign(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, expensesUsage)
+		return fmt.Errorf("missing subcommand: add, archive, or reassign")
+	}
+}
+
+func runExpensesAdd(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("expenses add", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username the expense belongs to")
+	amount := fs.Float64("amount", 0, "Amount")
+	category := fs.String("category", "", "Category, e.g. Groceries")
+	description := fs.String("description", "", "Description (defaults to the category)")
+	dateFlag := fs.String("date", "", "Date as YYYY-MM-DD or YYYY-MM-DDTHH:MM (defaults to now)")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var missing []string
+	if *username == "" {
+		missing = append(missing, "user")
+	}
+	if *amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if *category == "" {
+		missing = append(missing, "category")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	if *amount < 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	date, err := parseExpenseDate(*dateFlag)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	categories, err := db.ListCategories()
+	if err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	name, ok := importer.MatchCategory(*category, categories)
+	if !ok {
+		return fmt.Errorf("unknown category %q, use one of: %s", *category, strings.Join(categories, ", "))
+	}
+	if *description == "" {
+		*description = name
+	}
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	if err := db.CreateExpense(*amount, *description, name, date, user.ID); err != nil {
+		return fmt.Errorf("failed to add expense: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Added %.2f for %s (%s) on %s\n", *amount, *description, name, date.Format(time.DateOnly))
+	return nil
+}
+
+// parseExpenseDate parses the date of an expense like the web form does, with
+// the time of day being optional. An empty value means now.
+func parseExpenseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", time.DateOnly} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD or YYYY-MM-DDTHH:MM", value)
+}
+
+func runExpensesArchive(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("expenses archive", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	before := fs.String("before", "", "Archive expenses dated before this day (YYYY-MM-DD)")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *before == "" {
+		return fmt.Errorf("missing required flags: before")
+	}
+	cutoff, err := time.ParseInLocation(time.DateOnly, *before, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, want YYYY-MM-DD", *before)
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	moved, err := db.ArchiveExpenses(cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to archive expenses: %w", err)
+	}
+	total, err := db.ArchivedExpenseCount()
+	if err != nil {
+		return fmt.Errorf("failed to count archived expenses: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Archived %d expenses (%d archived in total)\n", moved, total)
+	return nil
+}
+
+func runExpensesReassign(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("expenses reassign", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username the expenses belong to now")
+	toUsername := fs.String("to-user", "", "Username to give them to")
+	from := fs.String("from", "", "Only expenses on or after this day (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only expenses up to and including this day (YYYY-MM-DD)")
+	category := fs.String("category", "", "Only expenses in this category")
+	dryRun := fs.Bool("dry-run", false, "Show what would be moved without changing anything")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var missing []string
+	if *username == "" {
+		missing = append(missing, "user")
+	}
+	if *toUsername == "" {
+		missing = append(missing, "to-user")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	filter := storage.ExpenseFilter{Category: *category}
+	if *from != "" {
+		day, err := time.Parse(time.DateOnly, *from)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, want YYYY-MM-DD", *from)
+		}
+		filter.From = day
+	}
+	if *to != "" {
+		day, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, want YYYY-MM-DD", *to)
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	target, err := lookupUser(db, *toUsername)
+	if err != nil {
+		return err
+	}
+	if user.ID == target.ID {
+		return fmt.Errorf("-user and -to-user are the same")
+	}
+	filter.UserID = user.ID
+
+	result, err := db.ReassignExpenses(filter, target.ID, *dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to reassign expenses: %w", err)
+	}
+
+	verb := "Moved"
+	if *dryRun {
+		verb = "Would move"
+	}
+	fmt.Fprintf(stdout, "%s %d expenses and %d archived expenses, %.2f in total, from %s to %s\n",
+		verb, result.Expenses, result.Archived, result.Total, user.Username, target.Username)
+	return nil
+}
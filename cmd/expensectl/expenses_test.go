@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpenses_Archive(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_archive.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Date(2023, 1, 15, 12, 0, 0, 0, time.Local), 1))
+	require.NoError(t, db.CreateExpense(20, "Bus", "Transport", time.Date(2026, 1, 16, 12, 0, 0, 0, time.Local), 1))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"expenses", "archive", "-before", "2024-01-01", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Archived 1 expenses (1 archived in total)")
+}
+
+func TestExpenses_ArchiveInvalidDate(t *testing.T) {
+	err := run([]string{"expenses", "archive", "-before", "last year"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid date")
+
+	err = run([]string{"expenses", "archive"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "before")
+}
+
+func TestExpenses_Add(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_add.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"expenses", "add", "-user", "alice", "-amount", "4.5", "-category", "eating out", "-description", "Coffee", "-date", "2026-03-10", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Added 4.50 for Coffee (Eating Out) on 2026-03-10")
+
+	// The description defaults to the category, the date to now
+	err = run([]string{"expenses", "add", "-user", "alice", "-amount", "2", "-category", "Transport", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.NoError(t, err)
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	expenses, err := db.FilterExpenses(storage.ExpenseFilter{})
+	require.NoError(t, err)
+	require.Len(t, expenses, 2)
+	byDescription := map[string]time.Time{}
+	for _, e := range expenses {
+		byDescription[e.Description] = e.Date
+	}
+	assert.Equal(t, "2026-03-10", byDescription["Coffee"].Format(time.DateOnly))
+	assert.WithinDuration(t, time.Now(), byDescription["Transport"], time.Minute)
+	total, err := db.GetTotalForPeriod(0, 2026, 3)
+	require.NoError(t, err)
+	assert.InDelta(t, 4.5, total, 0.001, "the statistics include it")
+}
+
+func TestExpenses_AddInvalid(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_add.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-user", "alice"}, "missing required flags: amount, category"},
+		{[]string{"-user", "alice", "-amount", "-3", "-category", "Other"}, "amount must be positive"},
+		{[]string{"-user", "alice", "-amount", "3", "-category", "Snacks"}, `unknown category "Snacks"`},
+		{[]string{"-user", "alice", "-amount", "3", "-category", "Other", "-date", "yesterday"}, "invalid date"},
+		{[]string{"-user", "bob", "-amount", "3", "-category", "Other"}, "user bob not found"},
+	} {
+		args := append([]string{"expenses", "add", "-db", dbPath}, tc.args...)
+		err := run(args, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+		require.Error(t, err, tc.args)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+}
+
+func TestExpenses_Reassign(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_reassign.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	shared, err := db.CreateUser("shared", "hash")
+	require.NoError(t, err)
+	_, err = db.CreateUser("bob", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(40, "Gym", "Sport", time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC), shared.ID))
+	require.NoError(t, db.CreateExpense(45, "Gym", "Sport", time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), shared.ID))
+	require.NoError(t, db.CreateExpense(60, "Market", "Groceries", time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC), shared.ID))
+	require.NoError(t, db.Close())
+
+	args := []string{"expenses", "reassign", "-user", "shared", "-to-user", "bob", "-from", "2026-03-01", "-category", "Sport", "-db", dbPath}
+	stdout := new(bytes.Buffer)
+	err = run(append(args, "-dry-run"), new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Would move 1 expenses and 0 archived expenses, 45.00 in total, from shared to bob")
+
+	stdout.Reset()
+	err = run(args, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Moved 1 expenses")
+
+	err = run([]string{"expenses", "reassign", "-user", "bob", "-to-user", "bob", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the same")
+}
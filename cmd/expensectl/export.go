@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"expense-tracker/internal/exporter"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+const exportUsage = "Usage: expensectl export [-format csv|json] [-from <YYYY-MM-DD>] [-to <YYYY-MM-DD>] [-user <username>] [-output <path>] [-timeout <duration>] [-db <db_path>]"
+
+func runExport(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "csv", "Output format: csv or json")
+	from := fs.String("from", "", "Only export expenses on or after this day (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only export expenses up to and including this day (YYYY-MM-DD)")
+	username := fs.String("user", "", "Only export this user's expenses (defaults to everyone's)")
+	output := fs.String("output", "", "File to write to (defaults to stdout)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Give up if reading the expenses takes longer, e.g. on a locked database; 0 for no limit")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != exporter.FormatCSV && *format != exporter.FormatJSON {
+		fmt.Fprintln(stdout, exportUsage)
+		return fmt.Errorf("unknown format %q, use csv or json", *format)
+	}
+
+	var filter storage.ExpenseFilter
+	if *from != "" {
+		day, err := time.Parse(time.DateOnly, *from)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, want YYYY-MM-DD", *from)
+		}
+		filter.From = day
+	}
+	if *to != "" {
+		day, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, want YYYY-MM-DD", *to)
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		db = db.WithContext(ctx)
+	}
+
+	if *username != "" {
+		user, err := lookupUser(db, *username)
+		if err != nil {
+			return err
+		}
+		filter.UserID = user.ID
+	}
+	users, err := db.ListUsers()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	usernames := make(map[int64]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+	out := stdout
+	var file *os.File
+	if *output != "" {
+		if file, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer, err := exporter.NewWriter(out, *format)
+	if err != nil {
+		return err
+	}
+	err = db.StreamExpenses(filter, func(e models.Expense) error {
+		var user string
+		if e.UserID != nil {
+			user = usernames[*e.UserID]
+		}
+		return writer.Write(exporter.NewRow(e, user))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export expenses: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write expenses: %w", err)
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Exported %d expenses to %s\n", writer.Count(), *output)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/exporter"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportTestDB creates a database with expenses of two users.
+func exportTestDB(t *testing.T) string {
+	dbPath := filepath.Join(t.TempDir(), "test_export.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	alice, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	bob, err := db.CreateUser("bob", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(4.5, "Coffee, black", "Eating Out", time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), alice.ID))
+	require.NoError(t, db.CreateExpense(30, "Supermarket", "Groceries", time.Date(2026, 3, 5, 18, 0, 0, 0, time.UTC), bob.ID))
+	require.NoError(t, db.CreateExpense(12, "Cinema", "Entertainment", time.Date(2026, 4, 1, 20, 0, 0, 0, time.UTC), alice.ID))
+	require.NoError(t, db.Close())
+	return dbPath
+}
+
+func TestExport_CSV(t *testing.T) {
+	dbPath := exportTestDB(t)
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"export", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Equal(t, "date,amount,description,category,user\n"+
+		"2026-03-02T08:30:00,4.50,\"Coffee, black\",Eating Out,alice\n"+
+		"2026-03-05T18:00:00,30.00,Supermarket,Groceries,bob\n"+
+		"2026-04-01T20:00:00,12.00,Cinema,Entertainment,alice\n", stdout.String())
+}
+
+func TestExport_JSONFiltered(t *testing.T) {
+	dbPath := exportTestDB(t)
+	output := filepath.Join(t.TempDir(), "march.json")
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"export", "-format", "json", "-from", "2026-03-01", "-to", "2026-03-31", "-user", "alice", "-output", output, "-db", dbPath},
+		new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Exported 1 expenses to "+output)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	var expenses []exporter.Row
+	require.NoError(t, json.Unmarshal(data, &expenses))
+	require.Len(t, expenses, 1)
+	assert.Equal(t, exporter.Row{Date: "2026-03-02T08:30:00", Amount: 4.5, Description: "Coffee, black", Category: "Eating Out", User: "alice"}, expenses[0])
+}
+
+func TestExport_RoundTripsThroughImport(t *testing.T) {
+	dbPath := exportTestDB(t)
+	file := filepath.Join(t.TempDir(), "expenses.csv")
+	require.NoError(t, run([]string{"export", "-output", file, "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)))
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"import", "-user", "alice", "-file", file, "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Imported 0 expenses, skipped 3 duplicates")
+}
+
+func TestExport_Errors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-format", "xml"},
+		{"-from", "March"},
+		{"-user", "nobody"},
+		{"-timeout", "1ns"},
+	} {
+		err := run(append([]string{"export", "-db", filepath.Join(t.TempDir(), "test.db")}, args...), new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+		assert.Error(t, err, strings.Join(args, " "))
+	}
+}
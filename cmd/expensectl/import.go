@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"expense-tracker/internal/importer"
+)
+
+const importUsage = "Usage: expensectl import -user <username> -file <path|-> [-format csv|ofx] [-date-column <name>] [-amount-column <name>] [-description-column <name>] [-category-column <name>] [-date-format <layout>] [-negative-debits] [-category <category>] [-dry-run] [-db <db_path>]"
+
+func runImport(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username the expenses belong to")
+	path := fs.String("file", "", "CSV or OFX file to import, - for stdin")
+	format := fs.String("format", "", "csv or ofx (defaults to the file extension)")
+	m := importer.DefaultMapping()
+	fs.StringVar(&m.Date, "date-column", m.Date, "CSV column holding the date")
+	fs.StringVar(&m.Amount, "amount-column", m.Amount, "CSV column holding the amount")
+	fs.StringVar(&m.Description, "description-column", m.Description, "CSV column holding the description")
+	fs.StringVar(&m.Category, "category-column", m.Category, "CSV column holding the category, if any")
+	fs.StringVar(&m.DateLayout, "date-format", "", "Go time layout of CSV dates, e.g. 02/01/2006 (defaults to ISO 8601)")
+	fs.BoolVar(&m.NegativeDebits, "negative-debits", false, "Spending is negative in the CSV, as in bank statements")
+	fs.StringVar(&m.DefaultCategory, "category", m.DefaultCategory, "Category for expenses without a known one")
+	dryRun := fs.Bool("dry-run", false, "Only report what would be imported")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *path == "" {
+		fmt.Fprintln(stdout, importUsage)
+		return fmt.Errorf("missing required flags: user and file")
+	}
+	if *format == "" {
+		if *path == "-" {
+			return fmt.Errorf("-format is required when reading stdin")
+		}
+		detected, err := importer.DetectFormat(*path)
+		if err != nil {
+			return err
+		}
+		*format = detected
+	}
+
+	in := stdin
+	if *path != "-" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if m.Categories, err = db.ListCategories(); err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	category, ok := importer.MatchCategory(m.DefaultCategory, m.Categories)
+	if !ok {
+		return fmt.Errorf("unknown category %q", m.DefaultCategory)
+	}
+	m.DefaultCategory = category
+
+	result, err := importer.Parse(in, *format, m)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *path, err)
+	}
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	added, err := db.ImportExpenses(result.Expenses, user.ID, *dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to import expenses: %w", err)
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(stdout, "%s %d expenses, skipped %d duplicates and %d credits\n",
+		verb, added, len(result.Expenses)-added, result.Credits)
+	return nil
+}
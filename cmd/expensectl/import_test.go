@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport_CSV(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test_import.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	csvPath := filepath.Join(dir, "expenses.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("date,amount,description,category\n2026-03-02,4.50,Coffee,Eating Out\n2026-03-03,30,Supermarket,Groceries\n"), 0o600))
+	args := []string{"import", "-user", "alice", "-file", csvPath, "-db", dbPath}
+
+	stdout := new(bytes.Buffer)
+	err = run(append(args, "-dry-run"), new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Would import 2 expenses")
+
+	stdout.Reset()
+	err = run(args, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Imported 2 expenses, skipped 0 duplicates and 0 credits")
+
+	// Running it again, e.g. from a nightly cron job, adds nothing
+	stdout.Reset()
+	err = run(args, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Imported 0 expenses, skipped 2 duplicates")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	total, err := db.GetTotalForPeriod(0, 2026, 3)
+	require.NoError(t, err)
+	assert.InDelta(t, 34.5, total, 0.001)
+}
+
+func TestImport_OFXFromStdin(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_import.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	statement := "<OFX><STMTTRN><DTPOSTED>20260302<TRNAMT>-12.00<NAME>Bakery</STMTTRN>" +
+		"<STMTTRN><DTPOSTED>20260303<TRNAMT>100.00<NAME>Refund</STMTTRN></OFX>"
+	stdout := new(bytes.Buffer)
+	err = run([]string{"import", "-user", "alice", "-file", "-", "-format", "ofx", "-category", "groceries", "-db", dbPath},
+		strings.NewReader(statement), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Imported 1 expenses, skipped 0 duplicates and 1 credits")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	expenses, err := db.FilterExpenses(storage.ExpenseFilter{})
+	require.NoError(t, err)
+	require.Len(t, expenses, 1)
+	assert.Equal(t, "Groceries", expenses[0].Category)
+}
+
+func TestImport_Errors(t *testing.T) {
+	err := run([]string{"import", "-user", "alice"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing required flags")
+
+	err = run([]string{"import", "-user", "alice", "-file", "-"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "-format is required")
+
+	err = run([]string{"import", "-user", "alice", "-file", "statement.pdf"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't tell the format")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"expense-tracker/internal/storage"
+
+	"golang.org/x/term"
+)
+
+// defaultDBPath is the database used when neither -db nor DB_PATH is set.
+const defaultDBPath = "expenses.db"
+
+// command is a top-level expensectl subcommand.
+type command struct {
+	name  string
+	usage []string // One line per subcommand
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{"aggregates", []string{"aggregates rebuild [-db <db_path>]"}, runAggregates},
+		{"anonymize", []string{"anonymize -output <path> [-jitter <fraction>] [-db <db_path>]"}, runAnonymize},
+		{"backup", []string{"backup -dir <directory> [-keep <n>] [-db <db_path>]"}, runBackup},
+		{"categories", []string{"categories list [-db <db_path>]", "categories add -name <category> [-db <db_path>]", "categories rename -from <category> -to <name> [-db <db_path>]", "categories merge -from <category> -into <category> [-force] [-dry-run] [-db <db_path>]", "categories delete -name <category> [-dry-run] [-db <db_path>]"}, runCategories},
+		{"db", []string{"db check [-db <db_path>]"}, runDB},
+		{"dedupe", []string{"dedupe [-user <username>] [-days <n>] [-auto] [-dry-run] [-db <db_path>]"}, runDedupe},
+		{"doctor", []string{"doctor [-config <file>] [-db-path <db_path>] [server flags]"}, runDoctor},
+		{"expenses", []string{"expenses add -user <username> -amount <amount> -category <category> [-description <text>] [-date <YYYY-MM-DD[THH:MM]>] [-db <db_path>]", "expenses archive -before <YYYY-MM-DD> [-dry-run] [-db <db_path>]", "expenses reassign -user <username> -to-user <username> [-from <YYYY-MM-DD>] [-to <YYYY-MM-DD>] [-category <category>] [-dry-run] [-db <db_path>]"}, runExpenses},
+		{"export", []string{"export [-format csv|json] [-from <YYYY-MM-DD>] [-to <YYYY-MM-DD>] [-user <username>] [-output <path>] [-db <db_path>]"}, runExport},
+		{"import", []string{"import -user <username> -file <path|-> [-format csv|ofx] [-date-column <name>] [-amount-column <name>] [-description-column <name>] [-category-column <name>] [-date-format <layout>] [-negative-debits] [-category <category>] [-dry-run] [-db <db_path>]"}, runImport},
+		{"migrate", []string{"migrate up [-db <db_path>]", "migrate down [-steps <n>] [-force] [-db <db_path>]", "migrate status [-db <db_path>]"}, runMigrate},
+		{"recurring", []string{"recurring list [-user <username>] [-format table|json] [-db <db_path>]", "recurring add -user <username> -amount <amount> -category <category> -frequency daily|weekly|monthly|yearly [-description <text>] [-start <YYYY-MM-DD[THH:MM]>] [-db <db_path>]", "recurring pause -id <id> [-resume] [-db <db_path>]", "recurring run-now [-db <db_path>]"}, runRecurring},
+		{"retention", []string{"retention apply [-dry-run] [-login-history-days <n>] [-invite-days <n>] [-expense-years <n>] [-db <db_path>]"}, runRetention},
+		{"sessions", []string{"sessions list [-user <username>] [-format table|json] [-db <db_path>]", "sessions revoke (-user <username> | -token <prefix> | -all) [-force] [-dry-run] [-db <db_path>]", "sessions clean [-db <db_path>]"}, runSessions},
+		{"shell", []string{"shell -user <username> [-db <db_path>]"}, runShell},
+		{"stats", []string{"stats [-month <YYYY-MM> | -year <YYYY>] [-db <db_path>]"}, runStats},
+		{"users", []string{"users list [-format table|json] [-db <db_path>]", "users unlock -user <username> [-db <db_path>]", "users passwd -user <username> [-password <password>] [-revoke-sessions] [-db <db_path>]", "users delete -user <username> [-disable | -reassign <username>] [-force] [-dry-run] [-db <db_path>]", "users enable -user <username> [-db <db_path>]", "users purge [-dry-run] [-db <db_path>]"}, runUsers},
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stdout)
+		if len(args) == 0 {
+			return errors.New("missing command")
+		}
+		return nil
+	}
+
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	printUsage(stdout)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: expensectl <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands() {
+		for _, usage := range c.usage {
+			fmt.Fprintf(w, "  %s\n", usage)
+		}
+	}
+}
+
+// dbFlag registers the shared -db flag on a subcommand's flag set.
+func dbFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", defaultDBPath, "Path to database file")
+}
+
+// readPassword reads a password without echoing it when stdin is a
+// terminal, or the first line of stdin otherwise.
+func readPassword(stdin io.Reader) (string, error) {
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		password, err := term.ReadPassword(int(f.Fd()))
+		if err != nil {
+			return "", err
+		}
+		return string(password), nil
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	if scanner.Scan() {
+		return scanner.Text(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// openDB opens the database and migrates it, letting DB_PATH override the
+// flag default.
+func openDB(path string) (*storage.DB, error) {
+	db, err := storage.NewDB(resolveDBPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// resolveDBPath returns the database path, letting DB_PATH override the flag
+// default.
+func resolveDBPath(path string) string {
+	if env := os.Getenv("DB_PATH"); env != "" && path == defaultDBPath {
+		return env
+	}
+	return path
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_NoCommand(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run(nil, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing command")
+	assert.Contains(t, stdout.String(), "Usage: expensectl")
+}
+
+func TestRun_Help(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"help"}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "aggregates rebuild")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"bogus"}, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown command "bogus"`)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"expense-tracker/internal/storage"
+)
+
+const migrateUsage = "Usage: expensectl migrate up [-db <db_path>]\n       expensectl migrate down [-steps <n>] [-force] [-db <db_path>]\n       expensectl migrate status [-db <db_path>]"
+
+func runMigrate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, migrateUsage)
+		return fmt.Errorf("missing subcommand: up, down, or status")
+	}
+	switch args[0] {
+	case "up":
+		return runMigrateUp(args[1:], stdout, stderr)
+	case "down":
+		return runMigrateDown(args[1:], stdin, stdout, stderr)
+	case "status":
+		return runMigrateStatus(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, migrateUsage)
+		return fmt.Errorf("missing subcommand: up, down, or status")
+	}
+}
+
+// openUnmigrated opens the database without applying migrations.
+func openUnmigrated(path string) (*storage.DB, error) {
+	db, err := storage.Open(resolveDBPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+func runMigrateUp(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openUnmigrated(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := db.Migrate()
+	if err != nil {
+		return fmt.Errorf("failed to migrate after applying %d migrations: %w", applied, err)
+	}
+	if applied == 0 {
+		fmt.Fprintln(stdout, "Database is up to date")
+		return nil
+	}
+	fmt.Fprintf(stdout, "Applied %d migrations\n", applied)
+	return nil
+}
+
+func runMigrateDown(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	steps := fs.Int("steps", 1, "How many migrations to undo, newest first")
+	force := fs.Bool("force", false, "Don't ask for confirmation")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	db, err := openUnmigrated(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if !*force {
+		ok, err := confirm(stdin, stdout, fmt.Sprintf("Undo the last %d migrations? Data in the tables and columns they added is lost.", *steps))
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("cancelled")
+		}
+	}
+
+	undone, err := db.MigrateDown(*steps)
+	if err != nil {
+		return fmt.Errorf("failed to migrate down after undoing %d migrations: %w", undone, err)
+	}
+	fmt.Fprintf(stdout, "Undid %d migrations\n", undone)
+	return nil
+}
+
+func runMigrateStatus(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openUnmigrated(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	statuses, err := db.Migrations()
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	pending := 0
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tDESCRIPTION\tAPPLIED\tREVERSIBLE")
+	for _, m := range statuses {
+		description := m.Description
+		if description == "" {
+			description = "(from a newer version of the app)"
+		}
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format("2006-01-02 15:04")
+		} else {
+			pending++
+		}
+		reversible := "no"
+		if m.Reversible {
+			reversible = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", m.Version, description, applied, reversible)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d pending\n", pending)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_migrate.db")
+
+	stdout := new(bytes.Buffer)
+	err := run([]string{"migrate", "status", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "baseline schema")
+	assert.Contains(t, stdout.String(), "pending")
+	assert.NotContains(t, stdout.String(), "\n0 pending", "status doesn't migrate")
+
+	stdout.Reset()
+	err = run([]string{"migrate", "up", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Applied")
+
+	stdout.Reset()
+	err = run([]string{"migrate", "up", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Database is up to date")
+
+	stdout.Reset()
+	err = run([]string{"migrate", "status", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "\n0 pending")
+
+	err = run([]string{"migrate", "down", "-db", dbPath}, strings.NewReader("n\n"), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cancelled")
+
+	stdout.Reset()
+	err = run([]string{"migrate", "down", "-force", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Undid 1 migrations")
+
+	err = run([]string{"migrate", "down", "-steps", "100", "-force", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't be undone", "the baseline stays")
+}
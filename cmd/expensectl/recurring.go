@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/storage"
+)
+
+const recurringUsage = "Usage: expensectl recurring list [-user <username>] [-format table|json] [-db <db_path>]\n       expensectl recurring add -user <username> -amount <amount> -category <category> -frequency daily|weekly|monthly|yearly [-description <text>] [-start <YYYY-MM-DD[THH:MM]>] [-db <db_path>]\n       expensectl recurring pause -id <id> [-resume] [-db <db_path>]\n       expensectl recurring run-now [-db <db_path>]"
+
+func runRecurring(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, recurringUsage)
+		return fmt.Errorf("missing subcommand: list, add, pause, or run-now")
+	}
+	switch args[0] {
+	case "list":
+		return runRecurringList(args[1:], stdout, stderr)
+	case "add":
+		return runRecurringAdd(args[1:], stdout, stderr)
+	case "pause":
+		return runRecurringPause(args[1:], stdout, stderr)
+	case "run-now":
+		return runRecurringRunNow(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, recurringUsage)
+		return fmt.Errorf("missing subcommand: list, add, pause, or run-now")
+	}
+}
+
+// recurringJSON is how recurring list -format json prints a recurring
+// expense.
+type recurringJSON struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Amount      float64   `json:"amount"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Frequency   string    `json:"frequency"`
+	NextDate    time.Time `json:"next_date"`
+	Paused      bool      `json:"paused"`
+}
+
+func runRecurringList(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("recurring list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Only list this user's recurring expenses")
+	format := fs.String("format", "table", "Output format: table or json")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q, use table or json", *format)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var userID int64
+	if *username != "" {
+		user, err := lookupUser(db, *username)
+		if err != nil {
+			return err
+		}
+		userID = user.ID
+	}
+	list, err := db.ListRecurring(userID)
+	if err != nil {
+		return fmt.Errorf("failed to list recurring expenses: %w", err)
+	}
+
+	if *format == "json" {
+		out := make([]recurringJSON, 0, len(list))
+		for _, r := range list {
+			out = append(out, recurringJSON{ID: r.ID, Username: r.Username, Amount: r.Amount, Description: r.Description, Category: r.Category, Frequency: r.Frequency, NextDate: r.NextDate, Paused: r.Paused})
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tAMOUNT\tDESCRIPTION\tCATEGORY\tFREQUENCY\tNEXT")
+	for _, r := range list {
+		next := r.NextDate.Format("2006-01-02 15:04")
+		if r.Paused {
+			next = "paused"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.2f\t%s\t%s\t%s\t%s\n", r.ID, r.Username, r.Amount, r.Description, r.Category, r.Frequency, next)
+	}
+	return tw.Flush()
+}
+
+func runRecurringAdd(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("recurring add", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username the expenses belong to")
+	amount := fs.Float64("amount", 0, "Amount")
+	category := fs.String("category", "", "Category, e.g. Housing")
+	frequency := fs.String("frequency", "", "How often: daily, weekly, monthly, or yearly")
+	description := fs.String("description", "", "Description (defaults to the category)")
+	startFlag := fs.String("start", "", "Date of the first expense as YYYY-MM-DD or YYYY-MM-DDTHH:MM (defaults to now)")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var missing []string
+	if *username == "" {
+		missing = append(missing, "user")
+	}
+	if *amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if *category == "" {
+		missing = append(missing, "category")
+	}
+	if *frequency == "" {
+		missing = append(missing, "frequency")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	if *amount < 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if !slices.Contains(storage.Frequencies, *frequency) {
+		return fmt.Errorf("unknown frequency %q, use one of: %s", *frequency, strings.Join(storage.Frequencies, ", "))
+	}
+	start, err := parseExpenseDate(*startFlag)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	categories, err := db.ListCategories()
+	if err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	name, ok := importer.MatchCategory(*category, categories)
+	if !ok {
+		return fmt.Errorf("unknown category %q, use one of: %s", *category, strings.Join(categories, ", "))
+	}
+	if *description == "" {
+		*description = name
+	}
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	id, err := db.CreateRecurring(user.ID, *amount, *description, name, *frequency, start)
+	if err != nil {
+		return fmt.Errorf("failed to add recurring expense: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Added recurring expense %d: %.2f for %s (%s) %s from %s\n", id, *amount, *description, name, *frequency, start.Format(time.DateOnly))
+	return nil
+}
+
+func runRecurringPause(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("recurring pause", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	id := fs.Int64("id", 0, "ID of the recurring expense, as shown by recurring list")
+	resume := fs.Bool("resume", false, "Resume instead, skipping what was missed while paused")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return fmt.Errorf("missing required flags: id")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	action := "Paused"
+	if *resume {
+		action = "Resumed"
+		err = db.ResumeRecurring(*id, time.Now())
+	} else {
+		err = db.PauseRecurring(*id)
+	}
+	if errors.Is(err, storage.ErrRecurringNotFound) {
+		return fmt.Errorf("no recurring expense with ID %d", *id)
+	} else if err != nil {
+		return fmt.Errorf("failed to update recurring expense: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "%s recurring expense %d\n", action, *id)
+	return nil
+}
+
+func runRecurringRunNow(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("recurring run-now", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	added, err := db.MaterializeRecurring(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to add recurring expenses: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Added %d recurring expenses\n", added)
+	return nil
+}
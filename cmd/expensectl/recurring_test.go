@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurring(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_recurring.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	start := time.Now().AddDate(0, 0, -15).Format(time.DateOnly)
+	stdout := new(bytes.Buffer)
+	err = run([]string{"recurring", "add", "-user", "alice", "-amount", "12", "-category", "sport", "-frequency", "weekly", "-description", "Climbing", "-start", start, "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Added recurring expense 1: 12.00 for Climbing (Sport) weekly")
+
+	stdout.Reset()
+	err = run([]string{"recurring", "run-now", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Added 3 recurring expenses")
+
+	stdout.Reset()
+	err = run([]string{"recurring", "pause", "-id", "1", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Paused recurring expense 1")
+
+	stdout.Reset()
+	err = run([]string{"recurring", "list", "-user", "alice", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Climbing")
+	assert.Contains(t, stdout.String(), "paused")
+
+	err = run([]string{"recurring", "pause", "-id", "7", "-resume", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recurring expense with ID 7")
+}
+
+func TestRecurring_InvalidFrequency(t *testing.T) {
+	err := run([]string{"recurring", "add", "-user", "alice", "-amount", "12", "-category", "Sport", "-frequency", "hourly"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown frequency")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"expense-tracker/internal/storage"
+)
+
+func runRetention(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "apply" {
+		fmt.Fprintln(stdout, "Usage: expensectl retention apply [-dry-run] [-login-history-days <n>] [-invite-days <n>] [-expense-years <n>] [-db <db_path>]")
+		return fmt.Errorf("missing subcommand: apply")
+	}
+
+	fs := flag.NewFlagSet("retention apply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "Only report what would be deleted and anonymized")
+	// Default to the server's settings, so cron jobs can share its environment
+	var policy storage.RetentionPolicy
+	fs.IntVar(&policy.LoginHistoryDays, "login-history-days", envInt("RETENTION_LOGIN_HISTORY_DAYS"), "Delete login history after this many days, 0 to keep it")
+	fs.IntVar(&policy.InviteDays, "invite-days", envInt("RETENTION_INVITE_DAYS"), "Delete invites this many days after they expire, 0 to keep them")
+	fs.IntVar(&policy.ExpenseYears, "expense-years", envInt("RETENTION_EXPENSE_YEARS"), "Anonymize expenses after this many years, 0 to keep them as they are")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if policy.LoginHistoryDays < 0 || policy.InviteDays < 0 || policy.ExpenseYears < 0 {
+		return fmt.Errorf("retention periods must not be negative")
+	}
+	if policy == (storage.RetentionPolicy{}) {
+		fmt.Fprintln(stdout, "No retention periods set, nothing to do")
+		return nil
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.ApplyRetention(policy, time.Now(), *dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to apply retention policy: %w", err)
+	}
+
+	verb := "Deleted"
+	anonymized := "anonymized"
+	if *dryRun {
+		verb = "Would delete"
+		anonymized = "would anonymize"
+	}
+	fmt.Fprintf(stdout, "%s %d login attempts and %d invites, and %s %d expenses\n",
+		verb, result.LoginEvents, result.Invites, anonymized, result.Expenses)
+	return nil
+}
+
+// envInt returns the integer in an environment variable, or 0 if it is unset
+// or not a number.
+func envInt(name string) int {
+	n, _ := strconv.Atoi(os.Getenv(name))
+	return n
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention_Apply(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_retention.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Now().AddDate(-3, 0, 0), 1))
+	require.NoError(t, db.CreateExpense(20, "Bus", "Transport", time.Now(), 1))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"retention", "apply", "-dry-run", "-expense-years", "2", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Would delete 0 login attempts and 0 invites, and would anonymize 1 expenses")
+
+	// The environment sets the default, like for the server
+	t.Setenv("RETENTION_EXPENSE_YEARS", "2")
+	stdout.Reset()
+	err = run([]string{"retention", "apply", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "anonymized 1 expenses")
+
+	stdout.Reset()
+	err = run([]string{"retention", "apply", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "anonymized 0 expenses")
+}
+
+func TestRetention_NothingToDo(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	err := run([]string{"retention", "apply", "-db", filepath.Join(t.TempDir(), "unused.db")}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "nothing to do")
+}
+
+func TestRetention_MissingSubcommand(t *testing.T) {
+	err := run([]string{"retention"}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing subcommand")
+}
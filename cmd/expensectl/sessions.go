@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"expense-tracker/internal/storage"
+)
+
+const sessionsUsage = "Usage: expensectl sessions list [-user <username>] [-format table|json] [-db <db_path>]\n       expensectl sessions revoke (-user <username> | -token <prefix> | -all) [-force] [-dry-run] [-db <db_path>]\n       expensectl sessions clean [-db <db_path>]"
+
+func runSessions(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, sessionsUsage)
+		return fmt.Errorf("missing subcommand: list, revoke, or clean")
+	}
+	switch args[0] {
+	case "list":
+		return runSessionsList(args[1:], stdout, stderr)
+	case "revoke":
+		return runSessionsRevoke(args[1:], stdin, stdout, stderr)
+	case "clean":
+		return runSessionsClean(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, sessionsUsage)
+		return fmt.Errorf("missing subcommand: list, revoke, or clean")
+	}
+}
+
+// sessionJSON is how sessions list -format json prints a session.
+type sessionJSON struct {
+	Token        string    `json:"token"` // Only the prefix
+	Username     string    `json:"username"`
+	LastActivity time.Time `json:"last_activity"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Bound        bool      `json:"bound"`
+}
+
+func runSessionsList(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sessions list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Only list this user's sessions")
+	format := fs.String("format", "table", "Output format: table or json")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q, use table or json", *format)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var userID int64
+	if *username != "" {
+		user, err := lookupUser(db, *username)
+		if err != nil {
+			return err
+		}
+		userID = user.ID
+	}
+	sessions, err := db.ListSessions(userID)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	if *format == "json" {
+		list := make([]sessionJSON, 0, len(sessions))
+		for _, s := range sessions {
+			list = append(list, sessionJSON{Token: s.TokenPrefix, Username: s.Username, LastActivity: s.LastActivity, ExpiresAt: s.ExpiresAt, Bound: s.Bound})
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOKEN\tUSERNAME\tLAST ACTIVE\tEXPIRES\tBOUND")
+	for _, s := range sessions {
+		bound := "no"
+		if s.Bound {
+			bound = "yes"
+		}
+		fmt.Fprintf(tw, "%s…\t%s\t%s\t%s\t%s\n", s.TokenPrefix, s.Username, s.LastActivity.Local().Format("2006-01-02 15:04"), s.ExpiresAt.Local().Format("2006-01-02 15:04"), bound)
+	}
+	return tw.Flush()
+}
+
+func runSessionsRevoke(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Sign this user out everywhere")
+	prefix := fs.String("token", "", "Revoke the session whose token starts with this, as shown by sessions list")
+	all := fs.Bool("all", false, "Sign everyone out")
+	force := fs.Bool("force", false, "Don't ask for confirmation with -all")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	selectors := 0
+	for _, set := range []bool{*username != "", *prefix != "", *all} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("use exactly one of -user, -token, and -all")
+	}
+	// A short prefix could match sessions nobody meant to revoke
+	if *prefix != "" && len(*prefix) < storage.SessionTokenPrefix {
+		return fmt.Errorf("token prefix must be at least %d characters", storage.SessionTokenPrefix)
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	var revoked int64
+	switch {
+	case *username != "":
+		user, err := lookupUser(db, *username)
+		if err != nil {
+			return err
+		}
+		if revoked, err = db.DeleteUserSessions(user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	case *prefix != "":
+		if revoked, err = db.DeleteSessionsByPrefix(*prefix); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if revoked == 0 {
+			return fmt.Errorf("no session with token %s…", *prefix)
+		}
+	default:
+		if !*force {
+			ok, err := c.confirm(stdin, "Sign out every user?")
+			if err != nil {
+				return fmt.Errorf("failed to read confirmation: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("cancelled")
+			}
+		}
+		if revoked, err = db.DeleteAllSessions(); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "Revoked %d sessions\n", revoked)
+	return nil
+}
+
+func runSessionsClean(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sessions clean", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	removed, err := db.CleanExpiredSessions()
+	if err != nil {
+		return fmt.Errorf("failed to clean sessions: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Removed %d expired sessions\n", removed)
+	return nil
+}
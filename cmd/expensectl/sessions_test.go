@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions_Clean(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_sessions.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateSession("expired", user.ID, -time.Hour, ""))
+	require.NoError(t, db.CreateSession("active", user.ID, time.Hour, ""))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err = run([]string{"sessions", "clean", "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Removed 1 expired sessions")
+}
+
+func TestSessions_MissingSubcommand(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"sessions"}, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing subcommand")
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_sessions_revoke.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	alice, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	bob, err := db.CreateUser("bob", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateSession("alice-laptop-token", alice.ID, time.Hour, ""))
+	require.NoError(t, db.CreateSession("alice-phone-token", alice.ID, time.Hour, ""))
+	require.NoError(t, db.CreateSession("bob-laptop-token", bob.ID, time.Hour, ""))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"sessions", "list", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "alice-ph…")
+	assert.NotContains(t, stdout.String(), "alice-phone-token", "tokens are never shown in full")
+
+	stdout.Reset()
+	err = run([]string{"sessions", "revoke", "-token", "alice-ph", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Revoked 1 sessions")
+
+	err = run([]string{"sessions", "revoke", "-token", "alice", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err, "prefix too short")
+
+	stdout.Reset()
+	err = run([]string{"sessions", "revoke", "-user", "alice", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Revoked 1 sessions")
+
+	err = run([]string{"sessions", "revoke", "-all", "-db", dbPath}, strings.NewReader("n\n"), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+
+	stdout.Reset()
+	err = run([]string{"sessions", "list", "-format", "json", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), `"token": "bob-lapt"`)
+
+	stdout.Reset()
+	err = run([]string{"sessions", "revoke", "-all", "-db", dbPath}, strings.NewReader("y\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Revoked 1 sessions")
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"golang.org/x/term"
+)
+
+// shellPrompt is shown before every command on a terminal.
+const shellPrompt = "expenses> "
+
+// shellHelp lists the shell's commands.
+const shellHelp = `Commands:
+  add <amount> <category> [description]  Log an expense dated now
+  list [n]                               Show your last n expenses (default 10)
+  stats [YYYY-MM | YYYY]                 Summarize a month (default this one) or a year
+  help                                   Show this help
+  exit                                   Leave the shell
+Quote categories with spaces, e.g. add 4.50 "Eating Out" Coffee. Tab completes
+commands and categories.`
+
+// shellCommands are the commands the shell completes.
+var shellCommands = []string{"add", "list", "stats", "help", "exit"}
+
+func runShell(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("shell", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username to log expenses for")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		fmt.Fprintln(stdout, "Usage: expensectl shell -user <username> [-db <db_path>]")
+		return fmt.Errorf("missing required flags: user")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	categories, err := db.ListCategories()
+	if err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	sh := &shell{db: db, user: user, categories: categories}
+
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return sh.runTerminal(f, stdout)
+	}
+	// Commands piped in run without prompts, so the shell can be scripted
+	sh.out = stdout
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		if sh.exec(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// shell runs the commands of expensectl shell for one user.
+type shell struct {
+	db         *storage.DB
+	user       *models.User
+	categories []string
+	out        io.Writer
+}
+
+// runTerminal reads commands from a terminal with line editing and tab
+// completion until exit or Ctrl-D.
+func (sh *shell) runTerminal(f *os.File, stdout io.Writer) error {
+	state, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = term.Restore(int(f.Fd()), state) }()
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{f, stdout}, shellPrompt)
+	terminal.AutoCompleteCallback = sh.complete
+	// In raw mode line breaks need a carriage return, which the terminal adds
+	sh.out = terminal
+
+	fmt.Fprintf(sh.out, "Logging expenses for %s. Type help for the commands.\n", sh.user.Username)
+	for {
+		line, err := terminal.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sh.exec(line) {
+			return nil
+		}
+	}
+}
+
+// exec runs one command line and reports whether the shell should exit.
+// Errors are printed, they don't end the shell.
+func (sh *shell) exec(line string) (exit bool) {
+	words, err := splitWords(line)
+	if err != nil {
+		fmt.Fprintf(sh.out, "Error: %v\n", err)
+		return false
+	}
+	if len(words) == 0 {
+		return false
+	}
+
+	switch words[0] {
+	case "add":
+		err = sh.add(words[1:])
+	case "list":
+		err = sh.list(words[1:])
+	case "stats":
+		err = sh.stats(words[1:])
+	case "help":
+		fmt.Fprintln(sh.out, shellHelp)
+	case "exit", "quit":
+		return true
+	default:
+		err = fmt.Errorf("unknown command %q, type help for the commands", words[0])
+	}
+	if err != nil {
+		fmt.Fprintf(sh.out, "Error: %v\n", err)
+	}
+	return false
+}
+
+func (sh *shell) add(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: add <amount> <category> [description]")
+	}
+	amount, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("invalid amount %q", args[0])
+	}
+	category, ok := importer.MatchCategory(args[1], sh.categories)
+	if !ok {
+		return fmt.Errorf("unknown category %q, use one of: %s", args[1], strings.Join(sh.categories, ", "))
+	}
+	description := strings.Join(args[2:], " ")
+	if description == "" {
+		description = category
+	}
+
+	date := time.Now()
+	if err := sh.db.CreateExpense(amount, description, category, date, sh.user.ID); err != nil {
+		return fmt.Errorf("failed to add expense: %w", err)
+	}
+	fmt.Fprintf(sh.out, "Added %.2f for %s (%s) on %s\n", amount, description, category, date.Format(time.DateOnly))
+	return nil
+}
+
+func (sh *shell) list(args []string) error {
+	n := 10
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+			return fmt.Errorf("invalid number %q", args[0])
+		}
+	}
+	expenses, err := sh.db.FilterExpenses(storage.ExpenseFilter{UserID: sh.user.ID})
+	if err != nil {
+		return fmt.Errorf("failed to list expenses: %w", err)
+	}
+	if len(expenses) == 0 {
+		fmt.Fprintln(sh.out, "No expenses")
+		return nil
+	}
+	for _, e := range expenses[:min(n, len(expenses))] {
+		fmt.Fprintf(sh.out, "%s  %10.2f  %s (%s)\n", e.Date.Format("2006-01-02 15:04"), e.Amount, e.Description, e.Category)
+	}
+	return nil
+}
+
+func (sh *shell) stats(args []string) error {
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+	if len(args) > 0 {
+		if start, err := time.Parse("2006-01", args[0]); err == nil {
+			year, month = start.Year(), int(start.Month())
+		} else if y, err := strconv.Atoi(args[0]); err == nil && len(args[0]) == 4 {
+			year, month = y, 0
+		} else {
+			return fmt.Errorf("invalid period %q, want YYYY-MM or YYYY", args[0])
+		}
+	}
+	return printStats(sh.db, sh.out, 0, year, month)
+}
+
+// complete completes the command, or the category of add, when Tab is
+// pressed. A single match is completed with a space after it, quoted if it
+// contains spaces. Several matches are completed as far as they agree.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	prefix, suffix := line[:pos], line[pos:]
+
+	// The word being typed starts after the last space, or at an unclosed quote
+	start := strings.LastIndex(prefix, " ") + 1
+	if strings.Count(prefix, `"`)%2 == 1 {
+		start = strings.LastIndex(prefix, `"`)
+	}
+	before, err := splitWords(prefix[:start])
+	if err != nil {
+		return "", 0, false
+	}
+	partial := strings.TrimPrefix(prefix[start:], `"`)
+
+	var candidates []string
+	switch {
+	case len(before) == 0:
+		candidates = shellCommands
+	case before[0] == "add" && len(before) == 2:
+		candidates = sh.categories
+	default:
+		return "", 0, false
+	}
+	var matches []string
+	for _, c := range candidates {
+		if len(c) >= len(partial) && strings.EqualFold(c[:len(partial)], partial) {
+			matches = append(matches, c)
+		}
+	}
+
+	var completed string
+	switch len(matches) {
+	case 0:
+		return "", 0, false
+	case 1:
+		completed = matches[0]
+		if strings.Contains(completed, " ") {
+			completed = `"` + completed + `"`
+		}
+		completed += " "
+	default:
+		common := commonPrefix(matches)
+		if len(common) <= len(partial) {
+			return "", 0, false
+		}
+		completed = common
+		if strings.Contains(prefix[start:], `"`) || strings.Contains(common, " ") {
+			completed = `"` + common
+		}
+	}
+	newPrefix := prefix[:start] + completed
+	return newPrefix + suffix, len(newPrefix), true
+}
+
+// commonPrefix returns the longest prefix all words share, ignoring case,
+// spelled like the first.
+func commonPrefix(words []string) string {
+	common := words[0]
+	for _, w := range words[1:] {
+		n := 0
+		for n < len(common) && n < len(w) && strings.EqualFold(common[n:n+1], w[n:n+1]) {
+			n++
+		}
+		common = common[:n]
+	}
+	return common
+}
+
+// splitWords splits a command line at spaces, keeping text in double quotes
+// together.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case r == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShell(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_shell.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	commands := strings.Join([]string{
+		`add 4.50 "eating out" Coffee with Sam`,
+		`add 30 Groceries`,
+		`add 12 Nonexistent`,
+		`list 1`,
+		`stats`,
+		`exit`,
+		`add 1 Other`,
+	}, "\n")
+	stdout := new(bytes.Buffer)
+	err = run([]string{"shell", "-user", "alice", "-db", dbPath}, strings.NewReader(commands), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	out := stdout.String()
+	assert.Contains(t, out, "Added 4.50 for Coffee with Sam (Eating Out)")
+	assert.Contains(t, out, "Added 30.00 for Groceries (Groceries)")
+	assert.Contains(t, out, `Error: unknown category "Nonexistent"`)
+	assert.Contains(t, out, "Total: €34.50")
+	assert.NotContains(t, out, "Added 1.00", "nothing runs after exit")
+}
+
+func TestShell_Complete(t *testing.T) {
+	sh := &shell{categories: []string{"Groceries", "Eating Out", "Entertainment", "Other"}}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"st", "stats "},
+		{"add 4 gro", "add 4 Groceries "},
+		{"add 4 ea", `add 4 "Eating Out" `},
+		{`add 4 "Eating`, `add 4 "Eating Out" `},
+		{"add 4 en", "add 4 Entertainment "},
+		{"add 4 e", ""},
+		{"add 4 Eating Out x", ""},
+		{"list 1", ""},
+	}
+	for _, tt := range tests {
+		line, pos, ok := sh.complete(tt.line, len(tt.line), '\t')
+		if tt.want == "" {
+			assert.False(t, ok, tt.line)
+			continue
+		}
+		require.True(t, ok, tt.line)
+		assert.Equal(t, tt.want, line, tt.line)
+		assert.Equal(t, len(tt.want), pos, tt.line)
+	}
+
+	_, _, ok := sh.complete("st", 2, 'a')
+	assert.False(t, ok, "only Tab completes")
+}
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`add 4.50 "Eating Out"  Coffee ""`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"add", "4.50", "Eating Out", "Coffee", ""}, words)
+
+	_, err = splitWords(`add 4.50 "Eating`)
+	assert.Error(t, err)
+}
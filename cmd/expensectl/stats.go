@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"expense-tracker/internal/storage"
+)
+
+func runStats(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	monthFlag := fs.String("month", "", "Month to summarize as YYYY-MM (defaults to the current month)")
+	yearFlag := fs.Int("year", 0, "Summarize a whole year instead of a month")
+	ledger := fs.Int64("ledger", 0, "Only count the expenses of the ledger with this ID (defaults to all ledgers)")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *monthFlag != "" && *yearFlag != 0 {
+		return fmt.Errorf("-month and -year can't be combined")
+	}
+
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+	switch {
+	case *yearFlag != 0:
+		year, month = *yearFlag, 0
+	case *monthFlag != "":
+		start, err := time.Parse("2006-01", *monthFlag)
+		if err != nil {
+			return fmt.Errorf("invalid month %q, want YYYY-MM", *monthFlag)
+		}
+		year, month = start.Year(), int(start.Month())
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return printStats(db, stdout, *ledger, year, month)
+}
+
+// printStats prints the total of a month, or a year if month is 0, compared
+// to the one before, and the totals per category, of a ledger or of all of
+// them if ledgerID is 0.
+func printStats(db *storage.DB, stdout io.Writer, ledgerID int64, year, month int) error {
+	prevYear, prevMonth := year-1, 0
+	if month != 0 {
+		prev := time.Date(year, time.Month(month)-1, 1, 0, 0, 0, 0, time.UTC)
+		prevYear, prevMonth = prev.Year(), int(prev.Month())
+	}
+
+	total, err := db.GetTotalForPeriod(ledgerID, year, month)
+	if err != nil {
+		return fmt.Errorf("failed to load statistics: %w", err)
+	}
+	prevTotal, err := db.GetTotalForPeriod(ledgerID, prevYear, prevMonth)
+	if err != nil {
+		return fmt.Errorf("failed to load statistics: %w", err)
+	}
+	var categories []storage.CategoryTotal
+	if month == 0 {
+		categories, err = db.GetCategoryTotalsByYear(ledgerID, year)
+	} else {
+		categories, err = db.GetCategoryTotalsByMonth(ledgerID, year, month)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load statistics: %w", err)
+	}
+
+	fmt.Fprintln(stdout, periodName(year, month))
+	fmt.Fprintf(stdout, "Total: €%.2f\n", total)
+	if prevTotal > 0 {
+		change := (total - prevTotal) / prevTotal * 100
+		fmt.Fprintf(stdout, "Change: %+.1f%% vs %s (€%.2f)\n", change, periodName(prevYear, prevMonth), prevTotal)
+	} else {
+		fmt.Fprintf(stdout, "Change: nothing spent in %s\n", periodName(prevYear, prevMonth))
+	}
+	if len(categories) == 0 {
+		fmt.Fprintln(stdout, "\nNo expenses")
+		return nil
+	}
+
+	// Names line up on the left, figures on the right
+	width := len("CATEGORY")
+	for _, c := range categories {
+		width = max(width, utf8.RuneCountInString(c.Category))
+	}
+	fmt.Fprintf(stdout, "\n%-*s  %10s  %5s  %6s\n", width, "CATEGORY", "TOTAL", "COUNT", "SHARE")
+	for _, c := range categories {
+		fmt.Fprintf(stdout, "%-*s  %10s  %5d  %5.1f%%\n", width, c.Category, fmt.Sprintf("€%.2f", c.Total), c.Count, c.Total/total*100)
+	}
+	return nil
+}
+
+// periodName names a month, or a year if month is 0.
+func periodName(year, month int) string {
+	if month == 0 {
+		return strconv.Itoa(year)
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).Format("January 2006")
+}
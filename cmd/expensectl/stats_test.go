@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_stats.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	for _, e := range []struct {
+		amount   float64
+		category string
+		date     time.Time
+	}{
+		{50, "Groceries", time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)},
+		{45, "Groceries", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{30, "Groceries", time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)},
+		{25, "Transport", time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)},
+	} {
+		require.NoError(t, db.CreateExpense(e.amount, e.category, e.category, e.date, 1))
+	}
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"stats", "-month", "2026-03", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	out := stdout.String()
+	assert.Contains(t, out, "March 2026\nTotal: €100.00\nChange: +100.0% vs February 2026 (€50.00)\n")
+	assert.Regexp(t, `Groceries\s+€75.00\s+2\s+75.0%`, out)
+	assert.Regexp(t, `Transport\s+€25.00\s+1\s+25.0%`, out)
+
+	stdout.Reset()
+	err = run([]string{"stats", "-year", "2026", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "2026\nTotal: €150.00\nChange: nothing spent in 2025\n")
+
+	stdout.Reset()
+	err = run([]string{"stats", "-month", "2026-05", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "No expenses")
+
+	err = run([]string{"stats", "-month", "March", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+const usersUsage = "Usage: expensectl users unlock -user <username> [-db <db_path>]\n       expensectl users passwd -user <username> [-password <password> | -directory] [-revoke-sessions] [-db <db_path>]\n       expensectl users delete -user <username> [-disable | -reassign <username>] [-force] [-dry-run] [-db <db_path>]\n       expensectl users enable -user <username> [-db <db_path>]\n       expensectl users list [-format table|json] [-db <db_path>]\n       expensectl users purge [-dry-run] [-db <db_path>]"
+
+func runUsers(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, usersUsage)
+		return fmt.Errorf("missing subcommand: list, unlock, passwd, delete, enable, or purge")
+	}
+	switch args[0] {
+	case "list":
+		return runUsersList(args[1:], stdout, stderr)
+	case "unlock":
+		return runUsersUnlock(args[1:], stdout, stderr)
+	case "passwd":
+		return runUsersPasswd(args[1:], stdin, stdout, stderr)
+	case "delete":
+		return runUsersDelete(args[1:], stdin, stdout, stderr)
+	case "enable":
+		return runUsersEnable(args[1:], stdout, stderr)
+	case "purge":
+		return runUsersPurge(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stdout, usersUsage)
+		return fmt.Errorf("missing subcommand: list, unlock, passwd, delete, enable, or purge")
+	}
+}
+
+// userJSON is how users list -format json prints a user.
+type userJSON struct {
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastLogin *time.Time `json:"last_login"`
+	Expenses  int        `json:"expenses"`
+}
+
+func runUsersList(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "table", "Output format: table or json")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q, use table or json", *format)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	if *format == "json" {
+		list := make([]userJSON, 0, len(users))
+		for _, u := range users {
+			list = append(list, userJSON{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt, LastLogin: u.LastLogin, Expenses: u.Expenses})
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tCREATED\tLAST LOGIN\tEXPENSES")
+	for _, u := range users {
+		lastLogin := "never"
+		if u.LastLogin != nil {
+			lastLogin = u.LastLogin.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\n", u.ID, u.Username, u.CreatedAt.Local().Format("2006-01-02 15:04"), lastLogin, u.Expenses)
+	}
+	return tw.Flush()
+}
+
+func runUsersUnlock(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users unlock", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("missing required flags: user")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.UnlockUser(*username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %s not found", *username)
+		}
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Unlocked user %s\n", *username)
+	return nil
+}
+
+func runUsersPasswd(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users passwd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username")
+	passwordFlag := fs.String("password", "", "New password (optional, will prompt if omitted)")
+	directory := fs.Bool("directory", false, "Remove the local password, so the LDAP user of the same name signs in as the user")
+	revoke := fs.Bool("revoke-sessions", false, "Sign the user out everywhere")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("missing required flags: user")
+	}
+	if *directory && *passwordFlag != "" {
+		return fmt.Errorf("use either -password or -directory")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+
+	if *directory {
+		if err := db.SetPassword(user.ID, ""); err != nil {
+			return fmt.Errorf("failed to remove password: %w", err)
+		}
+		fmt.Fprintf(stdout, "User %s now signs in through LDAP\n", user.Username)
+	} else if err := changePassword(db, user, *passwordFlag, stdin, stdout); err != nil {
+		return err
+	}
+
+	if *revoke {
+		revoked, err := db.DeleteUserSessions(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		fmt.Fprintf(stdout, "Signed out %d sessions\n", revoked)
+	}
+	return nil
+}
+
+// changePassword sets a user's password to password, or to one read from
+// stdin when it's empty.
+func changePassword(db *storage.DB, user *models.User, password string, stdin io.Reader, stdout io.Writer) error {
+	if password == "" {
+		fmt.Fprint(stdout, "New password: ")
+		var err error
+		password, err = readPassword(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		fmt.Fprintln(stdout) // Print newline after password input
+	}
+	if err := auth.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := db.SetPassword(user.ID, hash); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	fmt.Fprintf(stdout, "Changed the password of user %s\n", user.Username)
+	return nil
+}
+
+func runUsersDelete(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users delete", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username")
+	disable := fs.Bool("disable", false, "Block logins but keep the account and its expenses")
+	reassign := fs.String("reassign", "", "Give the user's expenses to this user instead of deleting them")
+	force := fs.Bool("force", false, "Don't ask for confirmation")
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("missing required flags: user")
+	}
+	if *disable && *reassign != "" {
+		return fmt.Errorf("-disable and -reassign can't be combined")
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	var heir *models.User
+	if *reassign != "" {
+		if heir, err = lookupUser(db, *reassign); err != nil {
+			return err
+		}
+		if heir.ID == user.ID {
+			return fmt.Errorf("can't reassign expenses to the user being deleted")
+		}
+	}
+	count, err := db.UserExpenseCount(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count expenses: %w", err)
+	}
+
+	var question string
+	switch {
+	case *disable:
+		question = fmt.Sprintf("Disable user %s? They will be signed out and unable to log in.", user.Username)
+	case heir != nil:
+		question = fmt.Sprintf("Delete user %s and give their %d expenses to %s?", user.Username, count, heir.Username)
+	default:
+		question = fmt.Sprintf("Delete user %s and their %d expenses? This can't be undone.", user.Username, count)
+	}
+	if !*force {
+		ok, err := c.confirm(stdin, question)
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("cancelled")
+		}
+	}
+
+	switch {
+	case *disable:
+		if err := db.DisableUser(user.ID); err != nil {
+			return fmt.Errorf("failed to disable user: %w", err)
+		}
+		fmt.Fprintf(stdout, "Disabled user %s\n", user.Username)
+	case heir != nil:
+		if err := db.DeleteUser(user.ID, heir.ID); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		fmt.Fprintf(stdout, "Deleted user %s and gave %d expenses to %s\n", user.Username, count, heir.Username)
+	default:
+		if err := db.DeleteUser(user.ID, 0); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		fmt.Fprintf(stdout, "Deleted user %s and %d expenses\n", user.Username, count)
+	}
+	return nil
+}
+
+func runUsersEnable(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users enable", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	username := fs.String("user", "", "Username")
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("missing required flags: user")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := lookupUser(db, *username)
+	if err != nil {
+		return err
+	}
+	enabled, err := db.EnableUser(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to enable user: %w", err)
+	}
+	if !enabled {
+		fmt.Fprintf(stdout, "User %s is not disabled\n", user.Username)
+		return nil
+	}
+	fmt.Fprintf(stdout, "Enabled user %s\n", user.Username)
+	return nil
+}
+
+// lookupUser returns the user with a username, or a readable error if there
+// is none.
+func lookupUser(db *storage.DB, username string) (*models.User, error) {
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user %s not found", username)
+		}
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	return user, nil
+}
+
+// confirm asks a yes or no question and reports whether the answer was yes.
+func confirm(stdin io.Reader, stdout io.Writer, question string) (bool, error) {
+	fmt.Fprintf(stdout, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func runUsersPurge(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("users purge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dryRun := dryRunFlag(fs)
+	dbPath := dbFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := openChange(*dbPath, *dryRun, stdout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	db, stdout := c.db, c.out
+
+	purged, err := db.PurgeDeletedAccounts()
+	if err != nil {
+		return fmt.Errorf("failed to purge accounts: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "Purged %d deleted accounts\n", purged)
+	return nil
+}
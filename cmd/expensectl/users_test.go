@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers_Unlock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	_, err = db.RecordFailedLogin(user.ID)
+	require.NoError(t, err)
+	require.NoError(t, db.LockUser(user.ID, time.Now().Add(time.Hour)))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err = run([]string{"users", "unlock", "-user", "alice", "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Unlocked user alice")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	lockout, err := db.GetLoginLockout(user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, lockout.FailedLogins)
+	assert.True(t, lockout.LockedUntil.IsZero())
+}
+
+func TestUsers_UnlockUnknownUser(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err := run([]string{"users", "unlock", "-user", "nobody", "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user nobody not found")
+}
+
+func TestUsers_Purge(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.ScheduleAccountDeletion(user.ID, time.Now().Add(-time.Minute)))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err = run([]string{"users", "purge", "-db", dbPath}, new(bytes.Buffer), stdout, stderr)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Purged 1 deleted accounts")
+}
+
+func TestUsers_MissingSubcommand(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"users"}, new(bytes.Buffer), stdout, stderr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing subcommand")
+}
+
+func TestUsers_Passwd(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateSession("token", user.ID, time.Hour, ""))
+	require.NoError(t, db.LockUser(user.ID, time.Now().Add(time.Hour)))
+	require.NoError(t, db.Close())
+
+	// Prompted for, since -password is missing
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "passwd", "-user", "alice", "-db", dbPath}, strings.NewReader("new secret\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "New password:")
+	assert.Contains(t, stdout.String(), "Changed the password of user alice")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	user, err = db.GetUserByUsername("alice")
+	require.NoError(t, err)
+	assert.True(t, auth.CheckPassword("new secret", user.PasswordHash))
+	lockout, err := db.GetLoginLockout(user.ID)
+	require.NoError(t, err)
+	assert.True(t, lockout.LockedUntil.IsZero(), "the lockout is lifted")
+	_, err = db.ValidateSession("token")
+	assert.NoError(t, err, "sessions survive without -revoke-sessions")
+	require.NoError(t, db.Close())
+
+	stdout.Reset()
+	err = run([]string{"users", "passwd", "-user", "alice", "-password", "another secret", "-revoke-sessions", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Signed out 1 sessions")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.ValidateSession("token")
+	assert.Error(t, err)
+}
+
+func TestUsers_PasswdDirectory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("admin", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	err = run([]string{"users", "passwd", "-user", "admin", "-password", "long enough", "-directory", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "either")
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "passwd", "-user", "admin", "-directory", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.NotContains(t, stdout.String(), "New password:", "not prompted for")
+	assert.Contains(t, stdout.String(), "User admin now signs in through LDAP")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	user, err := db.GetUserByUsername("admin")
+	require.NoError(t, err)
+	assert.Empty(t, user.PasswordHash)
+}
+
+func TestUsers_PasswdErrors(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	err = run([]string{"users", "passwd", "-user", "nobody", "-password", "long enough", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user nobody not found")
+
+	err = run([]string{"users", "passwd", "-user", "alice", "-password", "short", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least 8 characters")
+}
+
+func TestUsers_Delete(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("admin", "hash")
+	require.NoError(t, err)
+	alice, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Now(), alice.ID))
+	require.NoError(t, db.Close())
+
+	// Answering no keeps the user
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "delete", "-user", "alice", "-db", dbPath}, strings.NewReader("n\n"), stdout, new(bytes.Buffer))
+	require.Error(t, err)
+	assert.Contains(t, stdout.String(), "Delete user alice and their 1 expenses? This can't be undone. [y/N]")
+
+	stdout.Reset()
+	err = run([]string{"users", "delete", "-user", "alice", "-db", dbPath}, strings.NewReader("y\n"), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Deleted user alice and 1 expenses")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.GetUserByUsername("alice")
+	assert.Error(t, err)
+	expenses, err := db.FilterExpenses(storage.ExpenseFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, expenses)
+}
+
+func TestUsers_DeleteReassign(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	admin, err := db.CreateUser("admin", "hash")
+	require.NoError(t, err)
+	alice, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Now(), alice.ID))
+	require.NoError(t, db.Close())
+
+	err = run([]string{"users", "delete", "-user", "alice", "-reassign", "alice", "-force", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	require.Error(t, err)
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "delete", "-user", "alice", "-reassign", "admin", "-force", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Deleted user alice and gave 1 expenses to admin")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	count, err := db.UserExpenseCount(admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestUsers_DisableAndEnable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	_, err = db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "delete", "-user", "alice", "-disable", "-force", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Disabled user alice")
+
+	db, err = storage.NewDB(dbPath)
+	require.NoError(t, err)
+	alice, err := db.GetUserByUsername("alice")
+	require.NoError(t, err)
+	assert.True(t, alice.Disabled, "the account is kept")
+	require.NoError(t, db.Close())
+
+	stdout.Reset()
+	err = run([]string{"users", "enable", "-user", "alice", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Enabled user alice")
+}
+
+func TestUsers_List(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_users.db")
+	db, err := storage.NewDB(dbPath)
+	require.NoError(t, err)
+	alice, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	_, err = db.CreateUser("bob", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateExpense(10, "Coffee", "Eating Out", time.Now(), alice.ID))
+	require.NoError(t, db.RecordLogin(&alice.ID, "alice", "192.0.2.1", "", true))
+	require.NoError(t, db.Close())
+
+	stdout := new(bytes.Buffer)
+	err = run([]string{"users", "list", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+USERNAME\s+CREATED\s+LAST LOGIN\s+EXPENSES$`, lines[0])
+	assert.Regexp(t, `^1\s+alice\s+\S+ \S+\s+\S+ \S+\s+1$`, lines[1])
+	assert.Regexp(t, `^2\s+bob\s+\S+ \S+\s+never\s+0$`, lines[2])
+
+	stdout.Reset()
+	err = run([]string{"users", "list", "-format", "json", "-db", dbPath}, new(bytes.Buffer), stdout, new(bytes.Buffer))
+	require.NoError(t, err)
+	var users []struct {
+		ID        int64      `json:"id"`
+		Username  string     `json:"username"`
+		LastLogin *time.Time `json:"last_login"`
+		Expenses  int        `json:"expenses"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &users))
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Username)
+	assert.Equal(t, 1, users[0].Expenses)
+	assert.NotNil(t, users[0].LastLogin)
+	assert.Nil(t, users[1].LastLogin)
+
+	err = run([]string{"users", "list", "-format", "csv", "-db", dbPath}, new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer))
+	assert.Error(t, err)
+}
@@ -2,134 +2,65 @@ package main
 
 import (
 	"context"
-	"expense-tracker/internal/auth"
-	"expense-tracker/internal/handlers"
-	"expense-tracker/internal/storage"
+	"errors"
+	"expense-tracker/internal/app"
+	"expense-tracker/internal/config"
+	"expvar"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
-func setupRouter(h *handlers.Handlers, staticDir string) *http.ServeMux {
-	mux := http.NewServeMux()
-
-	// Static files (public)
-	fs := http.FileServer(http.Dir(staticDir))
-	mux.Handle("GET /static/", http.StripPrefix("/static/", fs))
-
-	// Auth routes (public)
-	mux.HandleFunc("GET /login", h.LoginForm)
-	mux.HandleFunc("POST /login", h.Login)
-	mux.HandleFunc("GET /logout", h.Logout)
-
-	// Root redirect
-	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			http.Redirect(w, r, "/expenses", http.StatusFound)
-			return
-		}
-		http.NotFound(w, r)
-	})
-
-	// Protected routes (require authentication)
-	mux.Handle("GET /expenses", h.AuthMiddleware(http.HandlerFunc(h.ListExpenses)))
-	mux.Handle("GET /expenses/create", h.AuthMiddleware(http.HandlerFunc(h.CreateExpenseForm)))
-	mux.Handle("POST /expenses", h.AuthMiddleware(http.HandlerFunc(h.CreateExpense)))
-	mux.Handle("GET /expenses/{id}/edit", h.AuthMiddleware(http.HandlerFunc(h.EditExpenseForm)))
-	mux.Handle("POST /expenses/{id}", h.AuthMiddleware(http.HandlerFunc(h.UpdateExpense)))
-	mux.Handle("DELETE /expenses/{id}", h.AuthMiddleware(http.HandlerFunc(h.DeleteExpense)))
-	mux.Handle("GET /statistics", h.AuthMiddleware(http.HandlerFunc(h.Statistics)))
-
-	return mux
-}
-
-// bootstrapUser creates a default user if none exist and credentials are provided via env vars.
-func bootstrapUser(db *storage.DB) {
-	count, err := db.UserCount()
-	if err != nil {
-		log.Printf("Warning: could not check user count: %v", err)
+func main() {
+	// Settings come from CONFIG_FILE or -config, the environment, and flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
-
-	if count > 0 {
-		return // Users already exist
-	}
-
-	username := os.Getenv("ADMIN_USER")
-	password := os.Getenv("ADMIN_PASSWORD")
-
-	if username == "" || password == "" {
-		// Generate default admin with random password
-		username = "admin"
-		var err error
-		password, err = auth.GenerateRandomPassword()
-		if err != nil {
-			log.Printf("Failed to generate random password: %v", err)
-			return
-		}
-		log.Println("=======================================================")
-		log.Println("WARNING: Creating default admin user with random password")
-		log.Printf("Password: %s", password)
-		log.Println("=======================================================")
-	}
-
-	hash, err := auth.HashPassword(password)
 	if err != nil {
-		log.Printf("Failed to hash password: %v", err)
-		return
-	}
-
-	if _, err := db.CreateUser(username, hash); err != nil {
-		log.Printf("Failed to create admin user: %v", err)
-		return
+		log.Fatalf("Invalid configuration: %v", err)
 	}
-
-	log.Printf("Created admin user: %s", username)
-}
-
-func main() {
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "expenses.db"
+	var effective strings.Builder
+	if err := cfg.Print(&effective); err == nil {
+		log.Printf("Configuration:\n%s", effective.String())
 	}
 
-	db, err := storage.NewDB(dbPath)
+	a, err := app.NewServer(cfg)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		log.Fatal(err)
 	}
-	defer db.Close()
-
-	// Create initial user if needed
-	bootstrapUser(db)
-
-	// Use secure cookies when running with HTTPS (production)
-	secureCookie := os.Getenv("SECURE_COOKIE") == "true"
-
-	h := handlers.NewHandlers(db, "web/templates", secureCookie)
-	mux := setupRouter(h, "web/static")
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = ":8080"
-	}
-	if port[0] != ':' {
-		port = ":" + port
+	// Background jobs stop when the server shuts down, finishing their
+	// current run first
+	a.Start(context.Background())
+
+	// Serve metrics on a separate, usually internal, address
+	var metrics *http.Server
+	if addr := cfg.MetricsAddr; addr != "" {
+		metrics = &http.Server{
+			Addr:              addr,
+			Handler:           expvar.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("Metrics available on %s", addr)
+			if err := metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics server error: %v", err)
+			}
+		}()
 	}
 
-	srv := &http.Server{
-		Addr:              port,
-		Handler:           mux,
-		ReadHeaderTimeout: 10 * time.Second,
-	}
+	srv := a.Server()
 
 	// Channel to listen for errors coming from the listener.
 	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Printf("API server starting on %s", port)
+		log.Printf("API server starting on %s", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			serverErrors <- err
 		}
@@ -139,25 +70,39 @@ func main() {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	exitCode := 0
 	select {
 	case err := <-serverErrors:
 		log.Printf("Error starting server: %v", err)
-		return
+		exitCode = 1
 
-	case <-shutdown:
-		log.Println("Starting shutdown...")
+	case sig := <-shutdown:
+		log.Printf("Received %v, starting shutdown...", sig)
+		// A second signal kills the process right away
+		signal.Stop(shutdown)
 
 		// Create a context with a timeout for shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 		defer cancel()
 
-		// Attempt graceful shutdown
+		// Stop accepting connections and wait for in-flight requests
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("Could not stop server gracefully: %v", err)
 			if err = srv.Close(); err != nil {
 				log.Printf("Could not stop http server: %v", err)
 			}
 		}
-		log.Println("Server stopped")
+		if metrics != nil {
+			if err := metrics.Shutdown(ctx); err != nil {
+				log.Printf("Could not stop metrics server: %v", err)
+			}
+		}
+	}
+
+	// Let background jobs finish writing before the database goes away
+	if err := a.Close(); err != nil {
+		log.Printf("Could not close database: %v", err)
 	}
+	log.Println("Server stopped")
+	os.Exit(exitCode)
 }
@@ -57,7 +57,6 @@ func runTestMain(m *testing.M) int {
 		"ADMIN_USER=testuser",
 		"ADMIN_PASSWORD=testpass123",
 	)
-	serverCmd.Dir = ".." // Run from project root so it finds web/templates
 	serverCmd.Stdout = os.Stdout
 	serverCmd.Stderr = os.Stderr
 
@@ -0,0 +1,163 @@
+// Package analytics works out how users are doing with their spending, for
+// the challenges page: their streaks of days without spending, and the
+// progress of their challenges to keep a week's spending under an amount.
+// Both follow the daily totals, so current and archived expenses count.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Analyzer computes streaks and tracks challenges.
+type Analyzer struct {
+	db  *storage.DB
+	now func() time.Time
+}
+
+// Option configures an Analyzer.
+type Option func(*Analyzer)
+
+// WithClock sets the clock streaks and challenges are computed against, for
+// tests.
+func WithClock(now func() time.Time) Option {
+	return func(a *Analyzer) { a.now = now }
+}
+
+// New creates an Analyzer that reads expenses from and keeps its results in
+// db.
+func New(db *storage.DB, opts ...Option) *Analyzer {
+	a := &Analyzer{db: db, now: time.Now}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Run updates the no-spend streak of every enabled user and the progress of
+// every active challenge, completing those whose week ended under the limit
+// and failing those that went over it. It can run as often as needed.
+func (a *Analyzer) Run(ctx context.Context) error {
+	users, err := a.db.ListUsers()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, u := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if u.Disabled {
+			continue
+		}
+		if err := a.updateStreak(u.User); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", u.ID, err))
+		}
+	}
+
+	challenges, err := a.db.ActiveChallenges()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, c := range challenges {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.track(c); err != nil {
+			errs = append(errs, fmt.Errorf("challenge %d: %w", c.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// today returns the start of today in the user's time zone, along with
+// their preferences.
+func (a *Analyzer) today(userID int64) (time.Time, models.Preferences, error) {
+	prefs, err := a.db.GetPreferences(userID)
+	if err != nil {
+		return time.Time{}, prefs, err
+	}
+	loc := time.Local
+	if prefs.Timezone != "" {
+		if l, err := time.LoadLocation(prefs.Timezone); err == nil {
+			loc = l
+		}
+	}
+	now := a.now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), prefs, nil
+}
+
+// updateStreak computes a user's no-spend streak over the ledger they
+// picked, from the day they signed up up to yesterday; today may still
+// bring an expense.
+func (a *Analyzer) updateStreak(u models.User) error {
+	today, prefs, err := a.today(u.ID)
+	if err != nil {
+		return err
+	}
+	ledger := prefs.Ledger
+	if ledger == 0 {
+		ledger = models.PersonalLedgerID
+	}
+	since := u.CreatedAt.In(today.Location())
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, today.Location())
+	days, err := a.db.SpendingDays(ledger, since, today)
+	if err != nil {
+		return err
+	}
+	current, longest := streak(since, today, days)
+	return a.db.SaveStreak(&models.Streak{UserID: u.ID, Current: current, Longest: longest})
+}
+
+// streak counts the no-spend days from the day of since up to the day of
+// today, exclusive, given the days among them with spending, the earliest
+// first. It returns the run of no-spend days up to yesterday, and the
+// longest run.
+func streak(since, today time.Time, spending []string) (current, longest int) {
+	// Counted in calendar days, which DST doesn't shorten or lengthen
+	day := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC) }
+	between := func(from, to time.Time) int { return max(int(to.Sub(from).Hours()/24), 0) }
+
+	start, end := day(since), day(today)
+	for _, s := range spending {
+		spent, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			continue
+		}
+		longest = max(longest, between(start, spent))
+		start = spent.AddDate(0, 0, 1)
+	}
+	current = between(start, end)
+	return current, max(longest, current)
+}
+
+// track records what was spent in a challenge's week so far, and completes
+// or fails it.
+func (a *Analyzer) track(c models.Challenge) error {
+	today, _, err := a.today(c.UserID)
+	if err != nil {
+		return err
+	}
+	monday, err := time.ParseInLocation(time.DateOnly, c.Week, today.Location())
+	if err != nil {
+		return err
+	}
+	end := monday.AddDate(0, 0, 7)
+	spent, err := a.db.GetTotalBetween(c.LedgerID, monday, end)
+	if err != nil {
+		return err
+	}
+	status := models.ChallengeActive
+	switch {
+	case spent > c.Limit:
+		status = models.ChallengeFailed
+	case !today.Before(end):
+		status = models.ChallengeCompleted
+	}
+	return a.db.UpdateChallenge(c.ID, spent, status)
+}
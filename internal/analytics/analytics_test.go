@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// AnalyticsTestSuite provides a test suite for streaks and challenges
+type AnalyticsTestSuite struct {
+	suite.Suite
+	db     *storage.DB
+	userID int64
+	now    time.Time
+}
+
+// SetupTest runs before each test
+func (s *AnalyticsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	user, err := db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.userID = user.ID
+	prefs := models.DefaultPreferences()
+	prefs.Timezone = "UTC"
+	s.Require().NoError(db.SavePreferences(user.ID, prefs))
+}
+
+// TearDownTest runs after each test
+func (s *AnalyticsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// run runs the analyzer at s.now.
+func (s *AnalyticsTestSuite) run() {
+	s.Require().NoError(New(s.db, WithClock(func() time.Time { return s.now })).Run(context.Background()))
+}
+
+func (s *AnalyticsTestSuite) TestStreak() {
+	// Alice signed up today, so count the days from now on
+	signup := time.Now().UTC()
+	day := func(n int) time.Time {
+		return time.Date(signup.Year(), signup.Month(), signup.Day()+n, 12, 0, 0, 0, time.UTC)
+	}
+	s.Require().NoError(s.db.CreateExpense(5, "Coffee", "Eating Out", day(6), s.userID))
+	s.Require().NoError(s.db.CreateExpense(30, "Groceries", "Groceries", day(8), s.userID))
+	s.Require().NoError(s.db.CreateExpense(9, "Lunch", "Eating Out", day(10), s.userID))
+
+	s.now = day(10)
+	s.run()
+	streak, err := s.db.GetStreak(s.userID)
+	s.Require().NoError(err)
+	s.Equal(1, streak.Current, "the day before yesterday had an expense, today's doesn't count yet")
+	s.Equal(6, streak.Longest, "from signing up until the coffee")
+
+	s.now = day(13)
+	s.run()
+	streak, err = s.db.GetStreak(s.userID)
+	s.Require().NoError(err)
+	s.Equal(2, streak.Current)
+	s.Equal(6, streak.Longest)
+}
+
+func (s *AnalyticsTestSuite) TestChallenges() {
+	// Wednesday
+	s.now = time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(80, "Groceries", "Groceries", time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(30, "Dinner", "Eating Out", time.Date(2026, 3, 9, 19, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(40, "Shoes", "Shopping", time.Date(2026, 2, 25, 12, 0, 0, 0, time.UTC), s.userID))
+
+	lastWeek := &models.Challenge{UserID: s.userID, LedgerID: models.PersonalLedgerID, Week: "2026-03-02", Limit: 100}
+	thisWeek := &models.Challenge{UserID: s.userID, LedgerID: models.PersonalLedgerID, Week: "2026-03-09", Limit: 50}
+	blown := &models.Challenge{UserID: s.userID, LedgerID: models.PersonalLedgerID, Week: "2026-02-23", Limit: 10}
+	for _, c := range []*models.Challenge{lastWeek, thisWeek, blown} {
+		s.Require().NoError(s.db.CreateChallenge(c))
+	}
+	s.run()
+
+	challenges, err := s.db.ListChallenges(s.userID)
+	s.Require().NoError(err)
+	s.Require().Len(challenges, 3)
+	byWeek := map[string]models.Challenge{}
+	for _, c := range challenges {
+		byWeek[c.Week] = c
+	}
+	s.Equal(models.ChallengeActive, byWeek["2026-03-09"].Status)
+	s.InDelta(30, byWeek["2026-03-09"].Spent, 0.001)
+	s.Equal(models.ChallengeCompleted, byWeek["2026-03-02"].Status)
+	s.InDelta(80, byWeek["2026-03-02"].Spent, 0.001)
+	s.Equal(models.ChallengeFailed, byWeek["2026-02-23"].Status)
+
+	s.Require().NoError(s.db.CreateExpense(25, "Cinema", "Entertainment", time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC), s.userID))
+	s.run()
+	active, err := s.db.ActiveChallenges()
+	s.Require().NoError(err)
+	s.Empty(active, "over the limit before the week is over")
+}
+
+// TestAnalyticsTestSuite runs the streaks and challenges test suite
+func TestAnalyticsTestSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsTestSuite))
+}
+
+func TestStreakCount(t *testing.T) {
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		spending         []string
+		current, longest int
+	}{
+		{"no spending", nil, 10, 10},
+		{"spent yesterday", []string{"2026-03-10"}, 0, 9},
+		{"runs", []string{"2026-03-03", "2026-03-04", "2026-03-08"}, 2, 3},
+		{"every day", []string{"2026-03-01", "2026-03-02", "2026-03-03", "2026-03-04", "2026-03-05", "2026-03-06", "2026-03-07", "2026-03-08", "2026-03-09", "2026-03-10"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := streak(since, today, tt.spending)
+			assert.Equal(t, tt.current, current)
+			assert.Equal(t, tt.longest, longest)
+		})
+	}
+
+	current, longest := streak(today, since, nil)
+	assert.Zero(t, current, "signed up after today")
+	assert.Zero(t, longest)
+}
@@ -0,0 +1,378 @@
+// Package app wires the database, handlers, routes, middleware, and
+// background jobs into the expense tracker server, so the server binary and
+// integration tests start the same application.
+package app
+
+import (
+	"context"
+	"expense-tracker/internal/analytics"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/bootstrap"
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/demo"
+	"expense-tracker/internal/handlers"
+	"expense-tracker/internal/jobs"
+	"expense-tracker/internal/mail"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/notify"
+	"expense-tracker/internal/storage"
+	"expense-tracker/web"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// App is a configured expense tracker: its database, handlers, and
+// background jobs.
+type App struct {
+	cfg       *config.Config
+	db        *storage.DB
+	handlers  *handlers.Handlers
+	scheduler *jobs.Scheduler
+	handler   http.Handler
+	webDir    string
+	stop      context.CancelFunc
+}
+
+// NewServer opens the database, sets up the demo or admin account, and
+// builds the handlers, routes, and middleware for cfg. Background jobs don't
+// run until Start.
+func NewServer(cfg *config.Config) (*App, error) {
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	a, err := newApp(cfg, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func newApp(cfg *config.Config, db *storage.DB) (*App, error) {
+	// A demo starts over with made-up data; other instances get an admin
+	// account if they have none
+	if cfg.Demo.Enabled {
+		log.Println("Running as a public demo, replacing all data")
+		if _, err := demo.Reset(db, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to set up demo: %w", err)
+		}
+	} else {
+		if _, err := bootstrap.Admin(db, cfg.Admin); err != nil {
+			log.Printf("Warning: admin bootstrap failed: %v", err)
+		}
+	}
+
+	// Use secure cookies when running with HTTPS (production)
+	secureCookie := cfg.Cookie.Secure
+	opts := []handlers.Option{handlers.WithSecureCookie(secureCookie)}
+	// Fail requests stuck on a locked database instead of piling them up
+	opts = append(opts, handlers.WithQueryTimeout(cfg.Database.QueryTimeout))
+	if cfg.Debug {
+		opts = append(opts, handlers.WithQueryLog())
+	}
+
+	// Let visitors create their own accounts at /register
+	allowSignup := cfg.Features.AllowSignup && !cfg.Demo.Enabled
+
+	// Check passwords against a directory server instead of local accounts
+	if cfg.LDAP.URL != "" {
+		if allowSignup {
+			// Local accounts can't log in while LDAP is in use
+			log.Println("Warning: ALLOW_SIGNUP is ignored when LDAP_URL is set")
+			allowSignup = false
+		}
+		opts = append(opts, handlers.WithLDAP(auth.LDAPConfig{
+			URL:     cfg.LDAP.URL,
+			UserDN:  cfg.LDAP.UserDN,
+			GroupDN: cfg.LDAP.GroupDN,
+		}))
+		log.Printf("Authenticating users against %s", cfg.LDAP.URL)
+	}
+	opts = append(opts, handlers.WithSignup(allowSignup))
+	if cfg.Demo.Enabled {
+		opts = append(opts, handlers.WithDemo(demo.Username, demo.Password))
+	}
+	// Refuse changes until an admin turns read-only mode off
+	if cfg.ReadOnly {
+		log.Println("Starting in read-only mode")
+		opts = append(opts, handlers.WithReadOnly(true))
+	}
+	// Key that signs emailed links; generated and kept in the database when unset
+	if cfg.SecretKey != "" {
+		opts = append(opts, handlers.WithSecretKey([]byte(cfg.SecretKey)))
+	}
+	// Limit stolen session cookies to the browser (and network) that logged in
+	switch cfg.Session.Binding {
+	case "browser":
+		opts = append(opts, handlers.WithSessionBinding(handlers.BindUserAgent))
+	case "network":
+		opts = append(opts, handlers.WithSessionBinding(handlers.BindUserAgentAndNetwork))
+	}
+	// Tighten the session cookie for deployments that allow it
+	opts = append(opts, handlers.WithSessionCookieName(cfg.Cookie.Name))
+	if cfg.Cookie.HostPrefix {
+		if !secureCookie {
+			log.Println("Warning: SESSION_COOKIE_HOST_PREFIX makes the session cookie HTTPS-only")
+		}
+		opts = append(opts, handlers.WithHostCookiePrefix())
+	}
+	if cfg.Cookie.SameSite == "strict" {
+		opts = append(opts, handlers.WithSameSite(http.SameSiteStrictMode))
+	}
+	// Templates and static files are built into the binary; WEB_DIR reads
+	// them from disk instead, so edits show up without rebuilding
+	templates, static := web.Templates(), web.Static()
+	webDir := cfg.WebDir
+	if cfg.Dev {
+		if webDir == "" {
+			webDir = "web"
+		}
+		opts = append(opts, handlers.WithDevMode())
+		log.Println("Development mode: pages reload when templates or static files change")
+	}
+	if webDir != "" {
+		templates = os.DirFS(filepath.Join(webDir, "templates"))
+		static = os.DirFS(filepath.Join(webDir, "static"))
+		log.Printf("Serving templates and static files from %s", webDir)
+	}
+	opts = append(opts, handlers.WithTemplateFS(templates))
+	emails, err := fs.Sub(templates, "email")
+	if err != nil {
+		return nil, err
+	}
+	mailer := mail.New(mailSender(cfg.SMTP), emails)
+	opts = append(opts, handlers.WithMailer(mailer))
+	// Users set up notifications through ntfy, Web Push, and email, except
+	// in a public demo where they'd reach strangers
+	var notifier *notify.Notifier
+	if !cfg.Demo.Enabled {
+		if notifier, err = newNotifier(cfg.Notify, db, mailer); err != nil {
+			return nil, err
+		}
+		opts = append(opts, handlers.WithNotifier(notifier))
+	}
+	// Origin passkeys are bound to; derived from each request when unset
+	if cfg.PasskeyOrigin != "" {
+		opts = append(opts, handlers.WithPasskeyOrigin(cfg.PasskeyOrigin))
+	}
+
+	// Fingerprint static files so browsers can cache them until the next
+	// deploy. Files read from WEB_DIR may change at any time, so they are
+	// served as they are.
+	staticFiles := http.FileServerFS(static)
+	if webDir == "" {
+		assets, err := handlers.NewStaticAssets(static)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read static files: %w", err)
+		}
+		opts = append(opts, handlers.WithStaticAssets(assets))
+		staticFiles = assets
+	}
+
+	// Background jobs pause in read-only mode, and admins can see them and
+	// start them early
+	var h *handlers.Handlers
+	scheduler := jobs.New(jobs.WithPause(func() bool { return h.ReadOnly() }))
+	opts = append(opts, handlers.WithJobs(scheduler))
+
+	h = handlers.NewHandlers(db, opts...)
+	mux := setupRouter(h, staticFiles)
+
+	// Remove expired sessions, purge deleted accounts, and apply the
+	// retention policy in the background; 0 leaves it to expensectl
+	if cfg.Session.CleanupInterval > 0 {
+		registerCleanup(scheduler, db, cfg.Session.CleanupInterval, storage.RetentionPolicy{
+			LoginHistoryDays: cfg.Retention.LoginHistoryDays,
+			InviteDays:       cfg.Retention.InviteDays,
+			ExpenseYears:     cfg.Retention.ExpenseYears,
+		})
+	}
+	// Recurring expenses are added within an hour of being due, or right
+	// away with expensectl recurring run-now
+	scheduler.Register(jobs.Job{
+		Name:       "recurring",
+		Interval:   time.Hour,
+		RunAtStart: true,
+		Run:        func(context.Context) error { return materializeRecurring(db) },
+	})
+	// No-spend streaks and the progress of challenges are at most an hour
+	// behind
+	scheduler.Register(jobs.Job{
+		Name:       "analytics",
+		Interval:   time.Hour,
+		RunAtStart: true,
+		Run:        analytics.New(db).Run,
+	})
+	// Budget alerts, bill reminders, and weekly digests go out within an
+	// interval of being due
+	if notifier != nil && cfg.Notify.Interval > 0 {
+		scheduler.Register(jobs.Job{
+			Name:     "notifications",
+			Interval: cfg.Notify.Interval,
+			Run:      notifier.Check,
+		})
+	}
+	if cfg.Demo.Enabled && cfg.Demo.ResetInterval > 0 {
+		scheduler.Register(jobs.Job{
+			Name:     "demo-reset",
+			Interval: cfg.Demo.ResetInterval,
+			Run:      func(context.Context) error { return resetDemo(db) },
+		})
+	}
+
+	// Behind a reverse proxy, see the client's address and scheme rather
+	// than the proxy's
+	var handler http.Handler = handlers.TrustProxies(h.ReadOnlyMiddleware(h.DemoMiddleware(mux)), cfg.TrustedProxies)
+	// Compress pages, styles, and scripts for slow mobile connections
+	handler = handlers.Compress(handler, int(cfg.HTTP.CompressMinSize))
+	if cfg.Dev {
+		handler = handlers.NoCache(handler)
+	}
+
+	return &App{
+		cfg:       cfg,
+		db:        db,
+		handlers:  h,
+		scheduler: scheduler,
+		handler:   handler,
+		webDir:    webDir,
+	}, nil
+}
+
+// Handler returns the application's routes with all middleware applied, but
+// without the request size limit of Server.
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// Server returns the HTTP server for the configured port, with the
+// configured timeouts and size limits. Shutting it down closes the live
+// update connections, which it doesn't track itself.
+func (a *App) Server() *http.Server {
+	port := a.cfg.Port
+	if !strings.Contains(port, ":") {
+		port = ":" + port
+	}
+	srv := newServer(port, a.handler, a.cfg.HTTP)
+	srv.RegisterOnShutdown(a.handlers.CloseLiveUpdates)
+	return srv
+}
+
+// Start runs the background jobs, and in development mode the watcher that
+// reloads pages, until ctx is done or Close is called.
+func (a *App) Start(ctx context.Context) {
+	ctx, a.stop = context.WithCancel(ctx)
+	a.scheduler.Start(ctx)
+	if a.cfg.Dev {
+		go a.handlers.Watch(ctx, os.DirFS(a.webDir))
+	}
+}
+
+// Close stops the background jobs, letting them finish writing, and closes
+// the database.
+func (a *App) Close() error {
+	if a.stop != nil {
+		a.stop()
+		a.scheduler.Wait()
+	}
+	return a.db.Close()
+}
+
+// openDatabase opens the database with the configured connection pool and
+// applies pending migrations, or with auto-migration off, fails if there are
+// any.
+func openDatabase(cfg config.Database) (*storage.DB, error) {
+	var db *storage.DB
+	var err error
+	if cfg.AutoMigrate {
+		db, err = storage.NewDB(cfg.Path)
+	} else {
+		db, err = storage.Open(cfg.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	db.SetPool(storage.Pool{
+		MaxOpen:     cfg.MaxOpenConns,
+		MaxIdle:     cfg.MaxIdleConns,
+		MaxLifetime: cfg.ConnMaxLifetime,
+	})
+	if cfg.AutoMigrate {
+		return db, nil
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if pending > 0 {
+		db.Close()
+		return nil, fmt.Errorf("%d migrations are pending and DB_AUTO_MIGRATE is off, apply them with expensectl migrate up", pending)
+	}
+	return db, nil
+}
+
+// newServer creates the HTTP server for handler with the configured timeouts
+// and size limits. The http.Server defaults have none, which lets slow or
+// oversized requests tie up an internet-facing server.
+func newServer(addr string, handler http.Handler, cfg config.HTTP) *http.Server {
+	// Headers should arrive well before the whole request is due
+	readHeaderTimeout := 10 * time.Second
+	if cfg.ReadTimeout > 0 {
+		readHeaderTimeout = min(readHeaderTimeout, cfg.ReadTimeout)
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           http.MaxBytesHandler(handler, int64(cfg.MaxBodyBytes)),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    int(cfg.MaxHeaderBytes),
+	}
+}
+
+// newNotifier returns a Notifier sending through every kind of channel. The
+// Web Push key is generated and kept in the database, as browsers
+// subscribed with it would have to subscribe again if it changed.
+func newNotifier(cfg config.Notify, db *storage.DB, mailer *mail.Mailer) (*notify.Notifier, error) {
+	key, err := db.Secret("vapid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Web Push key: %w", err)
+	}
+	push, err := notify.NewWebPush(key, cfg.PushSubject)
+	if err != nil {
+		return nil, err
+	}
+	return notify.New(db,
+		notify.WithSender(models.ChannelNtfy, &notify.Ntfy{Server: cfg.NtfyServer}),
+		notify.WithSender(models.ChannelWebPush, push),
+		notify.WithSender(models.ChannelEmail, &notify.Email{Mailer: mailer, User: db.GetUserByID}),
+		notify.WithBaseURL(cfg.BaseURL),
+	), nil
+}
+
+// mailSender returns the configured SMTP server, or a sender that logs
+// emails when no SMTP host is set.
+func mailSender(cfg config.SMTP) mail.Sender {
+	if cfg.Host == "" {
+		log.Println("SMTP_HOST not set, emails will be written to the log")
+		return mail.LogSender{}
+	}
+	return mail.SMTPSender{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+		TLS:      cfg.TLS,
+	}
+}
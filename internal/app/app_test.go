@@ -0,0 +1,226 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/handlers"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupRouter(t *testing.T) {
+	// Setup dependencies
+	db, err := storage.NewDB(":memory:")
+	require.NoError(t, err, "failed to create database")
+	defer db.Close()
+
+	// Use relative paths for tests running in internal/app
+	h := handlers.NewHandlers(db, handlers.WithTemplateFS(os.DirFS("../../web/templates")))
+
+	// Ensure template directory exists, otherwise skip handler initialization if it panics (handlers might check for templates)
+	if _, err := os.Stat("../../web/templates"); os.IsNotExist(err) {
+		t.Skip("Template directory not found, skipping router test")
+	}
+
+	// Create router - this triggers the panic if routing conflict exists
+	assets, err := handlers.NewStaticAssets(os.DirFS("../../web/static"))
+	require.NoError(t, err)
+	mux := setupRouter(h, assets)
+
+	// Verify routes
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		allowAlt   []int // Alternative acceptable status codes
+	}{
+		{
+			name:       "Root redirects to /expenses",
+			method:     "GET",
+			path:       "/",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Static file access",
+			method:     "GET",
+			path:       "/static/style.css",
+			wantStatus: http.StatusOK,
+			allowAlt:   []int{http.StatusNotFound}, // File might not exist in test env
+		},
+		{
+			name:       "List Expenses requires auth",
+			method:     "GET",
+			path:       "/expenses",
+			wantStatus: http.StatusFound, // Should redirect to login
+		},
+		{
+			name:       "Inline edit requires auth",
+			method:     "POST",
+			path:       "/expenses/1/inline",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Security settings require auth",
+			method:     "GET",
+			path:       "/settings/security",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Passkey registration requires auth",
+			method:     "POST",
+			path:       "/settings/passkeys/options",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Changing email requires auth",
+			method:     "POST",
+			path:       "/settings/email",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Email verification links work without a session",
+			method:     "GET",
+			path:       "/verify-email?token=invalid",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Invites require auth",
+			method:     "GET",
+			path:       "/settings/invites",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Background jobs require auth",
+			method:     "GET",
+			path:       "/settings/jobs",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Read-only mode toggle requires auth",
+			method:     "POST",
+			path:       "/settings/maintenance",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "Invite links are public",
+			method:     "GET",
+			path:       "/invite/unknown",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Passkey login is public",
+			method:     "POST",
+			path:       "/login/passkey/options",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			// Check if status matches expected or any alternative
+			if len(tt.allowAlt) > 0 {
+				acceptableStatuses := append([]int{tt.wantStatus}, tt.allowAlt...)
+				assert.Contains(t, acceptableStatuses, w.Code,
+					"%s %s returned unexpected status", tt.method, tt.path)
+			} else {
+				assert.Equal(t, tt.wantStatus, w.Code,
+					"%s %s returned unexpected status", tt.method, tt.path)
+			}
+		})
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	cfg := config.Default().HTTP
+	cfg.ReadTimeout = 5 * time.Second
+	cfg.MaxHeaderBytes = 16 << 10
+	cfg.MaxBodyBytes = 10
+
+	srv := newServer(":0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), cfg)
+	assert.Equal(t, 5*time.Second, srv.ReadTimeout)
+	assert.Equal(t, 5*time.Second, srv.ReadHeaderTimeout, "capped by the read timeout")
+	assert.Equal(t, 60*time.Second, srv.WriteTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.Equal(t, 16<<10, srv.MaxHeaderBytes)
+
+	for body, want := range map[string]int{"small": http.StatusOK, "far too large": http.StatusRequestEntityTooLarge} {
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		assert.Equal(t, want, w.Code, body)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "expenses.db")
+	cfg.Admin.User = "admin"
+	cfg.Admin.Password = "correct horse battery"
+
+	a, err := NewServer(cfg)
+	require.NoError(t, err)
+	a.Start(context.Background())
+	defer func() { assert.NoError(t, a.Close()) }()
+
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(srv.URL + "/expenses")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "/login", resp.Request.URL.Path, "logging in comes first")
+
+	resp, err = client.PostForm(srv.URL+"/login", url.Values{"username": {"admin"}, "password": {"correct horse battery"}})
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/expenses", resp.Request.URL.Path)
+	assert.Contains(t, string(body), "Spent this month")
+}
+
+func TestOpenDatabase(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Path = filepath.Join(t.TempDir(), "expenses.db")
+	cfg.AutoMigrate = false
+
+	_, err := openDatabase(cfg)
+	require.Error(t, err, "a new database needs migrating")
+	assert.Contains(t, err.Error(), "expensectl migrate up")
+
+	cfg.AutoMigrate = true
+	db, err := openDatabase(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	cfg.AutoMigrate = false
+	db, err = openDatabase(cfg)
+	require.NoError(t, err, "nothing is pending anymore")
+	require.NoError(t, db.Close())
+}
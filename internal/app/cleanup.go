@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"expense-tracker/internal/demo"
+	"expense-tracker/internal/handlers"
+	"expense-tracker/internal/jobs"
+	"expense-tracker/internal/storage"
+	"expvar"
+	"log"
+	"time"
+)
+
+// Cleanup metrics, served by the metrics listener.
+var (
+	sessionCleanupRuns   = expvar.NewInt("session_cleanup_runs")
+	sessionCleanupErrors = expvar.NewInt("session_cleanup_errors")
+	sessionsRemoved      = expvar.NewInt("session_cleanup_removed")
+	accountsPurged       = expvar.NewInt("accounts_purged")
+	deletedPurged        = expvar.NewInt("deleted_expenses_purged")
+	retentionDeleted     = expvar.NewInt("retention_deleted")
+	retentionAnonymized  = expvar.NewInt("retention_anonymized")
+	recurringAdded       = expvar.NewInt("recurring_added")
+)
+
+// cleanSessions removes expired sessions once and records the result.
+func cleanSessions(db *storage.DB) error {
+	sessionCleanupRuns.Add(1)
+	removed, err := db.CleanExpiredSessions()
+	if err != nil {
+		sessionCleanupErrors.Add(1)
+		return err
+	}
+	sessionsRemoved.Add(removed)
+	if removed > 0 {
+		log.Printf("Removed %d expired sessions", removed)
+	}
+	return nil
+}
+
+// purgeAccounts deletes the accounts whose deletion grace period is over.
+func purgeAccounts(db *storage.DB) error {
+	purged, err := db.PurgeDeletedAccounts()
+	accountsPurged.Add(purged)
+	if purged > 0 {
+		log.Printf("Purged %d deleted accounts", purged)
+	}
+	return err
+}
+
+// purgeDeletedExpenses forgets the deleted expenses that can no longer be
+// restored, keeping them a minute longer than the undo window in case a
+// request to undo is on its way.
+func purgeDeletedExpenses(db *storage.DB) error {
+	purged, err := db.PurgeDeletedExpenses(time.Now().Add(-handlers.UndoWindow - time.Minute))
+	deletedPurged.Add(purged)
+	return err
+}
+
+// applyRetention deletes and anonymizes data older than the policy allows.
+func applyRetention(db *storage.DB, policy storage.RetentionPolicy) error {
+	result, err := db.ApplyRetention(policy, time.Now(), false)
+	if err != nil {
+		return err
+	}
+	retentionDeleted.Add(result.LoginEvents + result.Invites)
+	retentionAnonymized.Add(result.Expenses)
+	if result != (storage.RetentionResult{}) {
+		log.Printf("Retention deleted %d login attempts and %d invites, and anonymized %d expenses",
+			result.LoginEvents, result.Invites, result.Expenses)
+	}
+	return nil
+}
+
+// registerCleanup schedules removing expired sessions, deleted accounts, and
+// deleted expenses and applying the retention policy every interval.
+func registerCleanup(scheduler *jobs.Scheduler, db *storage.DB, interval time.Duration, retention storage.RetentionPolicy) {
+	scheduler.Register(jobs.Job{
+		Name:       "session-cleanup",
+		Interval:   interval,
+		RunAtStart: true,
+		Run:        func(context.Context) error { return cleanSessions(db) },
+	})
+	scheduler.Register(jobs.Job{
+		Name:       "account-purge",
+		Interval:   interval,
+		RunAtStart: true,
+		Run:        func(context.Context) error { return purgeAccounts(db) },
+	})
+	scheduler.Register(jobs.Job{
+		Name:       "deleted-expense-purge",
+		Interval:   interval,
+		RunAtStart: true,
+		Run:        func(context.Context) error { return purgeDeletedExpenses(db) },
+	})
+	if retention != (storage.RetentionPolicy{}) {
+		scheduler.Register(jobs.Job{
+			Name:       "retention",
+			Interval:   interval,
+			RunAtStart: true,
+			Run:        func(context.Context) error { return applyRetention(db, retention) },
+		})
+	}
+}
+
+// materializeRecurring adds the recurring expenses that are due.
+func materializeRecurring(db *storage.DB) error {
+	added, err := db.MaterializeRecurring(time.Now())
+	if err != nil {
+		return err
+	}
+	recurringAdded.Add(added)
+	if added > 0 {
+		log.Printf("Added %d recurring expenses", added)
+	}
+	return nil
+}
+
+// resetDemo replaces the demo data with fresh made-up expenses, undoing
+// whatever visitors changed.
+func resetDemo(db *storage.DB) error {
+	if _, err := demo.Reset(db, time.Now()); err != nil {
+		return err
+	}
+	log.Println("Reset the demo data")
+	return nil
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/jobs"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanup(t *testing.T) {
+	db, err := storage.NewDB(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateSession("expired", user.ID, -time.Hour, ""))
+	require.NoError(t, db.CreateSession("active", user.ID, time.Hour, ""))
+	leaving, err := db.CreateUser("bob", "hash")
+	require.NoError(t, err)
+	require.NoError(t, db.ScheduleAccountDeletion(leaving.ID, time.Now().Add(-time.Minute)))
+	require.NoError(t, db.RecordLogin(&user.ID, "alice", "192.0.2.1", "test", true))
+
+	runs, removed, purged := sessionCleanupRuns.Value(), sessionsRemoved.Value(), accountsPurged.Value()
+	deleted := retentionDeleted.Value()
+
+	scheduler := jobs.New()
+	registerCleanup(scheduler, db, time.Hour, storage.RetentionPolicy{LoginHistoryDays: 30})
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.Start(ctx)
+	// The cleanup jobs run right away
+	require.Eventually(t, func() bool {
+		for _, s := range scheduler.Statuses() {
+			if s.Runs == 0 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	scheduler.Wait()
+
+	statuses := scheduler.Statuses()
+	require.Len(t, statuses, 4)
+	for _, s := range statuses {
+		assert.Empty(t, s.LastError, s.Name)
+	}
+	assert.Equal(t, runs+1, sessionCleanupRuns.Value())
+	assert.Equal(t, removed+1, sessionsRemoved.Value())
+	assert.Equal(t, purged+1, accountsPurged.Value())
+	assert.Equal(t, deleted, retentionDeleted.Value(), "recent login history is kept")
+	_, err = db.ValidateSession("active")
+	assert.NoError(t, err)
+}
+
+func TestCleanupWithoutRetention(t *testing.T) {
+	scheduler := jobs.New()
+	registerCleanup(scheduler, nil, time.Hour, storage.RetentionPolicy{})
+	for _, s := range scheduler.Statuses() {
+		assert.NotEqual(t, "retention", s.Name)
+	}
+}
+
+func TestMaterializeRecurring(t *testing.T) {
+	db, err := storage.NewDB(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	user, err := db.CreateUser("alice", "hash")
+	require.NoError(t, err)
+	_, err = db.CreateRecurring(user.ID, 9.99, "Music", "Entertainment", storage.Monthly, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	added := recurringAdded.Value()
+	require.NoError(t, materializeRecurring(db))
+	assert.Equal(t, added+1, recurringAdded.Value())
+	require.NoError(t, materializeRecurring(db))
+	assert.Equal(t, added+1, recurringAdded.Value(), "not due again for a month")
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"expense-tracker/internal/handlers"
+	"net/http"
+)
+
+// setupRouter registers every route of the application.
+func setupRouter(h *handlers.Handlers, static http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	root := handlers.NewGroup(mux).Use(h.Recover, handlers.SecurityHeaders)
+
+	// Static files (public), left out of the request log
+	root.Handle("GET /static/", http.StripPrefix("/static/", static))
+
+	// Auth routes (public)
+	logged := root.Use(h.LogRequests, h.CSRF)
+	public := logged.Use(h.QueryTimeout)
+	public.HandleFunc("GET /login", h.LoginForm)
+	public.HandleFunc("POST /login", h.Login)
+	public.HandleFunc("GET /logout", h.Logout)
+	public.HandleFunc("GET /register", h.RegisterForm)
+	public.HandleFunc("POST /register", h.Register)
+	public.HandleFunc("POST /login/passkey/options", h.PasskeyLoginOptions)
+	public.HandleFunc("POST /login/passkey", h.PasskeyLogin)
+	public.HandleFunc("GET /verify-email", h.VerifyEmail)
+	// Stays open until the page goes away, so without the query timeout
+	logged.HandleFunc("GET /dev/reload", h.DevReload)
+	public.HandleFunc("GET /invite/{token}", h.InviteForm)
+	public.HandleFunc("POST /invite/{token}", h.AcceptInvite)
+	public.HandleFunc("GET /guest/{token}", h.GuestLogin)
+
+	// Root redirect
+	public.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/expenses", http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	// Protected routes (require authentication)
+	authed := public.Use(h.AuthMiddleware)
+	authed.HandleFunc("GET /expenses", h.ListExpenses)
+	authed.HandleFunc("GET /expenses/history", h.ExpenseHistory)
+	authed.HandleFunc("GET /expenses/search", h.SearchExpenses)
+	authed.HandleFunc("GET /expenses/create", h.CreateExpenseForm)
+	authed.HandleFunc("GET /categories/picker", h.CategoryPicker)
+	authed.HandleFunc("GET /expenses/{id}/details", h.ExpenseDetails)
+	authed.HandleFunc("POST /expenses", h.CreateExpense)
+	authed.HandleFunc("GET /expenses/{id}/edit", h.EditExpenseForm)
+	authed.HandleFunc("POST /expenses/{id}", h.UpdateExpense)
+	authed.HandleFunc("GET /expenses/{id}/row", h.ExpenseRow)
+	authed.HandleFunc("GET /expenses/{id}/inline", h.InlineEditForm)
+	authed.HandleFunc("POST /expenses/{id}/inline", h.UpdateExpenseInline)
+	authed.HandleFunc("DELETE /expenses/{id}", h.DeleteExpense)
+	authed.HandleFunc("POST /expenses/undo", h.UndoDeleteExpense)
+	authed.HandleFunc("GET /expenses/import", h.ImportExpensesForm)
+	authed.HandleFunc("POST /expenses/import", h.ImportExpenses)
+	// Streamed over many short queries, which together may take longer than
+	// the query timeout
+	logged.Use(h.AuthMiddleware).HandleFunc("GET /expenses/export", h.ExportExpenses)
+	// Stays open while the list does, so without the query timeout
+	logged.Use(h.AuthMiddleware).HandleFunc("GET /expenses/live", h.ExpenseListChanges)
+	authed.HandleFunc("GET /reimbursements", h.Reimbursements)
+	authed.HandleFunc("POST /reimbursements", h.UpdateReimbursements)
+	authed.HandleFunc("GET /challenges", h.Challenges)
+	authed.HandleFunc("POST /challenges", h.CreateChallenge)
+	authed.HandleFunc("DELETE /challenges/{id}", h.DeleteChallenge)
+	authed.HandleFunc("GET /projects", h.Projects)
+	authed.HandleFunc("POST /projects", h.CreateProject)
+	authed.HandleFunc("GET /projects/options", h.ProjectOptions)
+	authed.HandleFunc("GET /projects/{id}", h.Project)
+	authed.HandleFunc("DELETE /projects/{id}", h.DeleteProject)
+	authed.HandleFunc("GET /ledgers", h.Ledgers)
+	authed.HandleFunc("POST /ledgers", h.CreateLedger)
+	authed.HandleFunc("GET /ledgers/report", h.LedgerReport)
+	authed.HandleFunc("POST /ledgers/{id}/currency", h.SetLedgerCurrency)
+	authed.HandleFunc("POST /ledgers/{id}/archive", h.ArchiveLedger)
+	authed.HandleFunc("POST /ledgers/{id}/unarchive", h.UnarchiveLedger)
+	authed.HandleFunc("DELETE /ledgers/{id}", h.DeleteLedger)
+	authed.HandleFunc("POST /ledger", h.SwitchLedger)
+	authed.HandleFunc("GET /statistics", h.Statistics)
+	authed.HandleFunc("GET /statistics/expenses", h.StatisticsExpenses)
+	authed.HandleFunc("GET /settings", h.Settings)
+	authed.HandleFunc("POST /settings", h.SaveSettings)
+	authed.HandleFunc("GET /settings/profile", h.Profile)
+	authed.HandleFunc("POST /settings/profile/username", h.UpdateUsername)
+	authed.HandleFunc("POST /settings/profile/avatar", h.UploadAvatar)
+	authed.HandleFunc("DELETE /settings/profile/avatar", h.DeleteAvatar)
+	authed.HandleFunc("GET /avatars/{id}", h.Avatar)
+	authed.HandleFunc("GET /settings/security", h.SecuritySettings)
+	authed.HandleFunc("GET /settings/notifications", h.NotificationSettings)
+	authed.HandleFunc("POST /settings/notifications", h.AddNotificationChannel)
+	authed.HandleFunc("POST /settings/notifications/push", h.SubscribePush)
+	authed.HandleFunc("GET /settings/notifications/digest", h.PreviewDigest)
+	authed.HandleFunc("POST /settings/notifications/digest", h.SetWeeklyDigest)
+	authed.HandleFunc("POST /settings/notifications/{id}", h.SetNotificationEvents)
+	authed.HandleFunc("POST /settings/notifications/{id}/test", h.TestNotification)
+	authed.HandleFunc("DELETE /settings/notifications/{id}", h.DeleteNotificationChannel)
+	authed.HandleFunc("POST /settings/email", h.UpdateEmail)
+	authed.HandleFunc("POST /settings/email/resend", h.ResendVerificationEmail)
+	authed.HandleFunc("POST /settings/passkeys/options", h.PasskeyRegistrationOptions)
+	authed.HandleFunc("POST /settings/passkeys", h.RegisterPasskey)
+	authed.HandleFunc("DELETE /settings/passkeys/{id}", h.DeletePasskey)
+	authed.HandleFunc("GET /settings/guests", h.Guests)
+	authed.HandleFunc("POST /settings/guests", h.CreateGuestToken)
+	authed.HandleFunc("DELETE /settings/guests/{id}", h.DeleteGuestToken)
+	authed.HandleFunc("POST /settings/account/delete", h.DeleteAccount)
+
+	// JSON API, for scripts and other clients
+	api := public.Use(h.APIAuthMiddleware)
+	api.HandleFunc("GET /api/v1/expenses", h.APIListExpenses)
+	api.HandleFunc("GET /api/v1/expenses/summary", h.APIExpenseSummary)
+	api.HandleFunc("GET /api/v1/changes", h.APIChanges)
+	api.HandleFunc("POST /api/v1/changes", h.APIUploadChanges)
+	// Stays open while the page does, so without the query timeout
+	logged.Use(h.APIAuthMiddleware).HandleFunc("GET /ws", h.LiveUpdates)
+
+	// Admin routes
+	admin := authed.Use(h.AdminMiddleware)
+	admin.HandleFunc("GET /settings/invites", h.Invites)
+	admin.HandleFunc("POST /settings/invites", h.CreateInvite)
+	admin.HandleFunc("POST /settings/maintenance", h.ToggleMaintenance)
+	admin.HandleFunc("DELETE /settings/invites/{id}", h.DeleteInvite)
+	admin.HandleFunc("GET /settings/jobs", h.Jobs)
+	admin.HandleFunc("POST /settings/jobs/{name}/run", h.RunJob)
+
+	return mux
+}
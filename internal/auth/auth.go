@@ -2,7 +2,15 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,8 +20,55 @@ const (
 	SessionTokenLength = 32
 	// BcryptCost is the cost factor for bcrypt hashing.
 	BcryptCost = 12
+	// MinPasswordLength is the shortest password accepted for new accounts.
+	MinPasswordLength = 8
+	// MaxPasswordLength is bcrypt's input limit in bytes.
+	MaxPasswordLength = 72
+	// MaxFailedLogins is the number of consecutive failed logins allowed
+	// before an account is temporarily locked.
+	MaxFailedLogins = 5
+	// LockoutBase is how long the first lockout lasts.
+	LockoutBase = time.Minute
+	// LockoutMax caps the lockout duration.
+	LockoutMax = time.Hour
 )
 
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,32}$`)
+
+// ValidateUsername checks that a username is 3-32 letters, digits, dots,
+// dashes, or underscores.
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("username must be 3-32 letters, digits, dots, dashes, or underscores")
+	}
+	return nil
+}
+
+// ValidatePassword checks that a password is long enough for a new account
+// and short enough for bcrypt.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(password) > MaxPasswordLength {
+		return errors.New("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// MaxEmailLength is the longest email address accepted, per RFC 5321.
+const MaxEmailLength = 254
+
+// ValidateEmail checks that email is a bare address such as
+// "alice@example.com", without a display name.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > MaxEmailLength {
+		return errors.New("enter a valid email address")
+	}
+	return nil
+}
+
 // HashPassword hashes a password using bcrypt.
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
@@ -38,6 +93,13 @@ func GenerateSessionToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// HashToken returns the SHA-256 hash of a random token, for storing tokens
+// such as invite links without being able to recover them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // GenerateRandomPassword creates a cryptographically secure random password.
 func GenerateRandomPassword() (string, error) {
 	b := make([]byte, 16)
@@ -46,3 +108,39 @@ func GenerateRandomPassword() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b)[:20], nil
 }
+
+// LockoutDuration returns how long to lock an account after the given number
+// of consecutive failed logins. It is zero below MaxFailedLogins and doubles
+// with every further failure, up to LockoutMax.
+func LockoutDuration(failures int) time.Duration {
+	if failures < MaxFailedLogins {
+		return 0
+	}
+	d := LockoutBase
+	for i := MaxFailedLogins; i < failures && d < LockoutMax; i++ {
+		d *= 2
+	}
+	return min(d, LockoutMax)
+}
+
+// versionPattern matches version numbers in User-Agent strings.
+var versionPattern = regexp.MustCompile(`[0-9]+(\.[0-9]+)*`)
+
+// ClientFingerprint hashes the parts of a client that rarely change during a
+// session: the User-Agent with version numbers removed, so browser updates
+// don't change it, and, if ip is not empty, its network prefix (/24 for IPv4,
+// /48 for IPv6) so moving within a network doesn't either.
+func ClientFingerprint(userAgent, ip string) string {
+	h := sha256.New()
+	h.Write([]byte(versionPattern.ReplaceAllString(strings.ToLower(userAgent), "")))
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		bits := 48
+		if addr.Unmap().Is4() {
+			addr, bits = addr.Unmap(), 24
+		}
+		prefix, _ := addr.Prefix(bits)
+		h.Write([]byte{0})
+		h.Write([]byte(prefix.String()))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
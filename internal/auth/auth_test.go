@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientFingerprint(t *testing.T) {
+	chrome120 := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36"
+	chrome121 := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.6167.85 Safari/537.36"
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	assert.Equal(t, ClientFingerprint(chrome120, ""), ClientFingerprint(chrome121, ""), "browser updates keep the fingerprint")
+	assert.NotEqual(t, ClientFingerprint(chrome120, ""), ClientFingerprint(firefox, ""))
+
+	assert.Equal(t, ClientFingerprint(firefox, "192.0.2.10"), ClientFingerprint(firefox, "192.0.2.200"), "same /24")
+	assert.Equal(t, ClientFingerprint(firefox, "192.0.2.10"), ClientFingerprint(firefox, "::ffff:192.0.2.10"), "IPv4-mapped addresses")
+	assert.NotEqual(t, ClientFingerprint(firefox, "192.0.2.10"), ClientFingerprint(firefox, "198.51.100.10"))
+	assert.Equal(t, ClientFingerprint(firefox, "2001:db8:1:1::1"), ClientFingerprint(firefox, "2001:db8:1:ffff::2"), "same /48")
+	assert.NotEqual(t, ClientFingerprint(firefox, "2001:db8:1::1"), ClientFingerprint(firefox, "2001:db8:2::1"))
+	assert.NotEqual(t, ClientFingerprint(firefox, ""), ClientFingerprint(firefox, "192.0.2.10"))
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE key parameters, see https://www.rfc-editor.org/rfc/rfc9053
+const (
+	coseKty   = 1
+	coseCrv   = -1 // Also the modulus n of RSA keys
+	coseX     = -2 // Also the exponent e of RSA keys
+	coseY     = -3
+	coseOKP   = 1
+	coseEC2   = 2
+	coseRSA   = 3
+	coseP256  = 1
+	coseEd255 = 6
+)
+
+// parseCOSEKey parses the COSE_Key at the start of data, as authenticators
+// put it in attested credential data, into a key of the types parsePublicKey
+// accepts. Anything after the key, such as extensions, is ignored.
+func parseCOSEKey(data []byte) (crypto.PublicKey, error) {
+	d := cborDecoder{data: data}
+	params, err := d.intMap()
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	kty, _ := params[coseKty].(int64)
+	switch kty {
+	case coseEC2:
+		crv, _ := params[coseCrv].(int64)
+		x, xOK := params[coseX].([]byte)
+		y, yOK := params[coseY].([]byte)
+		if crv != coseP256 || !xOK || !yOK || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("unsupported EC2 credential public key")
+		}
+		// The uncompressed point encoding checks the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC2 credential public key: %w", err)
+		}
+		return key, nil
+	case coseRSA:
+		n, nOK := params[coseCrv].([]byte)
+		e, eOK := params[coseX].([]byte)
+		if !nOK || !eOK || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA credential public key")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case coseOKP:
+		crv, _ := params[coseCrv].(int64)
+		x, ok := params[coseX].([]byte)
+		if crv != coseEd255 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP credential public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported credential public key type %d", kty)
+	}
+}
+
+// cborDecoder reads the subset of CBOR that COSE keys are written in: maps
+// with integer keys and integer or byte string values.
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// CBOR major types
+const (
+	cborUint  = 0
+	cborNeg   = 1
+	cborBytes = 2
+	cborMap   = 5
+)
+
+// head reads the initial byte and argument of the next item.
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(d.data)-d.pos < size {
+			return 0, 0, errors.New("unexpected end of data")
+		}
+		var buf [8]byte
+		copy(buf[8-size:], d.data[d.pos:d.pos+size])
+		d.pos += size
+		return major, binary.BigEndian.Uint64(buf[:]), nil
+	default:
+		return 0, 0, errors.New("indefinite lengths are not supported")
+	}
+}
+
+// value reads an integer or a byte string.
+func (d *cborDecoder) value() (any, error) {
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint, cborNeg:
+		if arg > 1<<62 {
+			return nil, errors.New("integer out of range")
+		}
+		if major == cborNeg {
+			return -1 - int64(arg), nil
+		}
+		return int64(arg), nil
+	case cborBytes:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errors.New("unexpected end of data")
+		}
+		b := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported CBOR major type %d", major)
+	}
+}
+
+// intMap reads a map with integer keys.
+func (d *cborDecoder) intMap() (map[int64]any, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != cborMap || n > 16 {
+		return nil, errors.New("not a COSE key map")
+	}
+	m := make(map[int64]any, n)
+	for range n {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(int64)
+		if !ok {
+			return nil, errors.New("COSE key labels must be integers")
+		}
+		if m[key], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
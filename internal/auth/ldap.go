@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned when the directory rejects a login.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ldapTimeout bounds each LDAP login, including connecting.
+const ldapTimeout = 10 * time.Second
+
+// LDAP result codes, see RFC 4511 appendix A.
+const (
+	ldapSuccess            = 0
+	ldapNoSuchObject       = 32
+	ldapInvalidCredentials = 49
+)
+
+// BER tags of the LDAP messages used, see RFC 4511 section 4.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berBoolean     = 0x01
+	berSequence    = 0x30
+
+	ldapBindRequest      = 0x60
+	ldapBindResponse     = 0x61
+	ldapUnbindRequest    = 0x42
+	ldapSearchRequest    = 0x63
+	ldapSearchResultDone = 0x65
+	ldapSearchResultItem = 0x64
+	ldapSimpleAuth       = 0x80
+	ldapFilterOr         = 0xa1
+	ldapFilterEquality   = 0xa3
+)
+
+// LDAPConfig authenticates users against an LDAP or Active Directory server
+// with a simple bind as the user.
+type LDAPConfig struct {
+	// URL of the server, ldaps://host[:636] or ldap://host[:389]. Binds
+	// over ldap:// send the password in cleartext.
+	URL string
+	// UserDN is the bind DN with %s for the username, e.g.
+	// "uid=%s,ou=people,dc=example,dc=com" or "CN=%s,OU=Staff,DC=corp,DC=example,DC=com".
+	UserDN string
+	// GroupDN optionally restricts logins to members of this group, matched
+	// on its member or uniqueMember attribute.
+	GroupDN string
+	// TLSConfig is used for ldaps:// connections; nil uses the defaults.
+	TLSConfig *tls.Config
+}
+
+// Authenticate binds as the user and checks group membership. It returns
+// ErrInvalidCredentials if the directory refuses the login, and other errors
+// if the directory could not be asked.
+func (c LDAPConfig) Authenticate(username, password string) error {
+	// An empty password would be an unauthenticated bind, which servers
+	// accept for any DN
+	if username == "" || password == "" {
+		return ErrInvalidCredentials
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("ldap: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(ldapTimeout)); err != nil {
+		return fmt.Errorf("ldap: %w", err)
+	}
+	s := &ldapSession{conn: conn, r: bufio.NewReader(conn)}
+	defer s.send(ber(ldapUnbindRequest))
+
+	userDN := fmt.Sprintf(c.UserDN, escapeDN(username))
+	code, err := s.bind(userDN, password)
+	if err != nil {
+		return fmt.Errorf("ldap: %w", err)
+	}
+	switch code {
+	case ldapSuccess:
+	case ldapInvalidCredentials:
+		return ErrInvalidCredentials
+	default:
+		return fmt.Errorf("ldap: bind failed with result code %d", code)
+	}
+
+	if c.GroupDN == "" {
+		return nil
+	}
+	member, err := s.isMember(c.GroupDN, userDN)
+	if err != nil {
+		return fmt.Errorf("ldap: %w", err)
+	}
+	if !member {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (c LDAPConfig) dial() (net.Conn, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	switch u.Scheme {
+	case "ldap":
+		return dialer.Dial("tcp", hostPort(u, "389"))
+	case "ldaps":
+		cfg := c.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: u.Hostname()}
+		}
+		return tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), cfg)
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// escapeDN escapes a value for use in a DN attribute, see RFC 4514 section 2.4.
+func escapeDN(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(s)-1):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// ldapSession is a connection with its next message ID.
+type ldapSession struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	msgID int
+}
+
+// send wraps op in an LDAPMessage and writes it.
+func (s *ldapSession) send(op []byte) error {
+	s.msgID++
+	_, err := s.conn.Write(ber(berSequence, berInt(berInteger, s.msgID), op))
+	return err
+}
+
+// receive reads the next LDAPMessage and returns its protocol op tag and
+// contents.
+func (s *ldapSession) receive() (byte, []byte, error) {
+	tag, msg, err := readBER(s.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if tag != berSequence {
+		return 0, nil, fmt.Errorf("unexpected message tag %#x", tag)
+	}
+	elems, err := splitBER(msg)
+	if err != nil || len(elems) < 2 {
+		return 0, nil, errors.New("malformed message")
+	}
+	return elems[1].tag, elems[1].value, nil
+}
+
+// bind performs a simple bind and returns the result code.
+func (s *ldapSession) bind(dn, password string) (int, error) {
+	err := s.send(ber(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(berOctetString, dn),
+		berString(ldapSimpleAuth, password),
+	))
+	if err != nil {
+		return 0, err
+	}
+	tag, value, err := s.receive()
+	if err != nil {
+		return 0, err
+	}
+	if tag != ldapBindResponse {
+		return 0, fmt.Errorf("unexpected response tag %#x", tag)
+	}
+	return resultCode(value)
+}
+
+// isMember reports whether groupDN lists memberDN as a member.
+func (s *ldapSession) isMember(groupDN, memberDN string) (bool, error) {
+	err := s.send(ber(ldapSearchRequest,
+		berString(berOctetString, groupDN),
+		berInt(berEnumerated, 0), // baseObject
+		berInt(berEnumerated, 0), // neverDerefAliases
+		berInt(berInteger, 1),    // sizeLimit
+		berInt(berInteger, int(ldapTimeout.Seconds())),
+		ber(berBoolean, []byte{0xff}), // typesOnly
+		ber(ldapFilterOr,
+			ber(ldapFilterEquality, berString(berOctetString, "member"), berString(berOctetString, memberDN)),
+			ber(ldapFilterEquality, berString(berOctetString, "uniqueMember"), berString(berOctetString, memberDN)),
+		),
+		ber(berSequence, berString(berOctetString, "1.1")), // no attributes
+	))
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for {
+		tag, value, err := s.receive()
+		if err != nil {
+			return false, err
+		}
+		switch tag {
+		case ldapSearchResultItem:
+			found = true
+		case ldapSearchResultDone:
+			code, err := resultCode(value)
+			if err != nil {
+				return false, err
+			}
+			switch code {
+			case ldapSuccess:
+				return found, nil
+			case ldapNoSuchObject:
+				return false, fmt.Errorf("group %q not found", groupDN)
+			default:
+				return false, fmt.Errorf("search failed with result code %d", code)
+			}
+		}
+		// Search result references are ignored
+	}
+}
+
+// resultCode extracts the result code from an LDAPResult.
+func resultCode(value []byte) (int, error) {
+	elems, err := splitBER(value)
+	if err != nil || len(elems) == 0 || elems[0].tag != berEnumerated {
+		return 0, errors.New("malformed result")
+	}
+	code := 0
+	for _, b := range elems[0].value {
+		code = code<<8 | int(b)
+	}
+	return code, nil
+}
+
+// berElement is a decoded BER tag-length-value.
+type berElement struct {
+	tag   byte
+	value []byte
+}
+
+// ber encodes a BER element whose value is the concatenation of children.
+func ber(tag byte, children ...[]byte) []byte {
+	var value []byte
+	for _, c := range children {
+		value = append(value, c...)
+	}
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+func berString(tag byte, s string) []byte {
+	return ber(tag, []byte(s))
+}
+
+// berInt encodes a non-negative integer.
+func berInt(tag byte, n int) []byte {
+	b := []byte{byte(n)}
+	for n >>= 8; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return ber(tag, b)
+}
+
+// maxBERLength bounds the size of a message read from the server.
+const maxBERLength = 1 << 20
+
+// readBER reads one BER element from r.
+func readBER(r io.ByteReader) (byte, []byte, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 3 {
+			return 0, nil, errors.New("unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERLength {
+		return 0, nil, errors.New("BER element too large")
+	}
+	value := make([]byte, length)
+	for i := range value {
+		if value[i], err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+	return tag, value, nil
+}
+
+// splitBER decodes the consecutive BER elements in b.
+func splitBER(b []byte) ([]berElement, error) {
+	r := bytes.NewReader(b)
+	var elems []berElement
+	for r.Len() > 0 {
+		tag, value, err := readBER(r)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, berElement{tag: tag, value: value})
+	}
+	return elems, nil
+}
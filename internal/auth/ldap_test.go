@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeDirectory is a minimal LDAP server that accepts simple binds for known
+// users and answers group membership searches.
+type fakeDirectory struct {
+	listener  net.Listener
+	passwords map[string]string          // Bind DN to password
+	groups    map[string]map[string]bool // Group DN to member DNs
+}
+
+func newFakeDirectory(s *LDAPTestSuite) *fakeDirectory {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	d := &fakeDirectory{
+		listener:  l,
+		passwords: map[string]string{},
+		groups:    map[string]map[string]bool{},
+	}
+	go d.serve()
+	return d
+}
+
+func (d *fakeDirectory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *fakeDirectory) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *fakeDirectory) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	boundDN := ""
+	for {
+		_, msg, err := readBER(r)
+		if err != nil {
+			return
+		}
+		elems, err := splitBER(msg)
+		if err != nil || len(elems) < 2 {
+			return
+		}
+		id, op := elems[0].value, elems[1]
+		reply := func(tag byte, children ...[]byte) {
+			_, _ = conn.Write(ber(berSequence, ber(berInteger, id), ber(tag, children...)))
+		}
+		result := func(code int) []byte {
+			return append(berInt(berEnumerated, code), append(berString(berOctetString, ""), berString(berOctetString, "")...)...)
+		}
+
+		fields, _ := splitBER(op.value)
+		switch op.tag {
+		case ldapBindRequest:
+			dn, password := string(fields[1].value), string(fields[2].value)
+			if want, ok := d.passwords[dn]; ok && want == password {
+				boundDN = dn
+				reply(ldapBindResponse, result(ldapSuccess))
+			} else {
+				reply(ldapBindResponse, result(ldapInvalidCredentials))
+			}
+		case ldapSearchRequest:
+			members, ok := d.groups[string(fields[0].value)]
+			if !ok {
+				reply(ldapSearchResultDone, result(ldapNoSuchObject))
+				continue
+			}
+			// The filter is an OR of member and uniqueMember equality
+			// matches for the same DN
+			filter, _ := splitBER(fields[6].value)
+			match, _ := splitBER(filter[0].value)
+			if boundDN != "" && members[string(match[1].value)] {
+				reply(ldapSearchResultItem, berString(berOctetString, string(fields[0].value)), ber(berSequence))
+			}
+			reply(ldapSearchResultDone, result(ldapSuccess))
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+// LDAPTestSuite provides a test suite for LDAP authentication
+type LDAPTestSuite struct {
+	suite.Suite
+	dir *fakeDirectory
+}
+
+// SetupTest runs before each test
+func (s *LDAPTestSuite) SetupTest() {
+	s.dir = newFakeDirectory(s)
+	s.dir.passwords["uid=alice,ou=people,dc=example,dc=com"] = "secret"
+	s.dir.passwords["uid=bob,ou=people,dc=example,dc=com"] = "hunter2"
+	s.dir.groups["cn=expenses,ou=groups,dc=example,dc=com"] = map[string]bool{
+		"uid=alice,ou=people,dc=example,dc=com": true,
+	}
+}
+
+// TearDownTest runs after each test
+func (s *LDAPTestSuite) TearDownTest() {
+	s.dir.listener.Close()
+}
+
+func (s *LDAPTestSuite) TestAuthenticate_Bind() {
+	cfg := LDAPConfig{URL: s.dir.url(), UserDN: "uid=%s,ou=people,dc=example,dc=com"}
+
+	s.NoError(cfg.Authenticate("alice", "secret"))
+	s.NoError(cfg.Authenticate("bob", "hunter2"))
+	s.ErrorIs(cfg.Authenticate("alice", "wrong"), ErrInvalidCredentials)
+	s.ErrorIs(cfg.Authenticate("nobody", "secret"), ErrInvalidCredentials)
+	s.ErrorIs(cfg.Authenticate("alice", ""), ErrInvalidCredentials, "empty passwords must not be sent as unauthenticated binds")
+}
+
+func (s *LDAPTestSuite) TestAuthenticate_GroupMembership() {
+	cfg := LDAPConfig{
+		URL:     s.dir.url(),
+		UserDN:  "uid=%s,ou=people,dc=example,dc=com",
+		GroupDN: "cn=expenses,ou=groups,dc=example,dc=com",
+	}
+
+	s.NoError(cfg.Authenticate("alice", "secret"))
+	s.ErrorIs(cfg.Authenticate("bob", "hunter2"), ErrInvalidCredentials, "bob is not in the group")
+
+	cfg.GroupDN = "cn=missing,ou=groups,dc=example,dc=com"
+	err := cfg.Authenticate("alice", "secret")
+	s.Error(err)
+	s.NotErrorIs(err, ErrInvalidCredentials, "a misconfigured group is not the user's fault")
+}
+
+func (s *LDAPTestSuite) TestAuthenticate_Unreachable() {
+	s.dir.listener.Close()
+	cfg := LDAPConfig{URL: s.dir.url(), UserDN: "uid=%s,ou=people,dc=example,dc=com"}
+
+	err := cfg.Authenticate("alice", "secret")
+	s.Error(err)
+	s.NotErrorIs(err, ErrInvalidCredentials)
+}
+
+func (s *LDAPTestSuite) TestEscapeDN() {
+	s.Equal("alice", escapeDN("alice"))
+	s.Equal(`a\,b\=c`, escapeDN("a,b=c"))
+	s.Equal(`\#admin`, escapeDN("#admin"))
+	s.Equal(`\ x\ `, escapeDN(" x "))
+	s.Equal(`x\\y\+z`, escapeDN(`x\y+z`))
+}
+
+// TestLDAPSuite runs the LDAP authentication test suite
+func TestLDAPSuite(t *testing.T) {
+	suite.Run(t, new(LDAPTestSuite))
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignToken(t *testing.T) {
+	key := []byte("test-key")
+	token := SignToken(key, "verify-email", "42|alice@example.com", time.Now().Add(time.Hour))
+
+	payload, err := VerifyToken(key, "verify-email", token, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "42|alice@example.com", payload)
+
+	_, err = VerifyToken([]byte("other-key"), "verify-email", token, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken, "wrong key")
+
+	_, err = VerifyToken(key, "reset-password", token, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken, "wrong purpose")
+
+	expired := SignToken(key, "verify-email", "42|alice@example.com", time.Now().Add(-time.Second))
+	_, err = VerifyToken(key, "verify-email", expired, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired")
+
+	// Expiry is checked against the given clock
+	_, err = VerifyToken(key, "verify-email", token, time.Now().Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired by the given clock")
+
+	// Swapping in another payload breaks the signature
+	other := SignToken(key, "verify-email", "1|mallory@example.com", time.Now().Add(time.Hour))
+	body, _, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	_, err = VerifyToken(key, "verify-email", body+"."+sig, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidToken, "tampered payload")
+
+	for _, bad := range []string{"", "no-dot", "!!!.!!!"} {
+		_, err = VerifyToken(key, "verify-email", bad, time.Now())
+		assert.ErrorIs(t, err, ErrInvalidToken, bad)
+	}
+}
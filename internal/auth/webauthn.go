@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Authenticator data flags, see https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// minAuthDataLength covers the RP ID hash, flags, and signature counter.
+const minAuthDataLength = 37
+
+// RelyingParty identifies this server to WebAuthn authenticators. ID is the
+// domain passkeys are scoped to and Origin the exact origin pages are served
+// from, e.g. "expenses.example.com" and "https://expenses.example.com".
+type RelyingParty struct {
+	ID     string
+	Origin string
+}
+
+// clientData is the subset of the CollectedClientData JSON that is verified.
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// GenerateChallenge creates a random WebAuthn challenge, base64url encoded
+// without padding as it appears in client data.
+func GenerateChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyRegistration checks a new passkey created in response to challenge.
+// publicKey is the credential's SubjectPublicKeyInfo as returned by the
+// browser's AuthenticatorAttestationResponse.getPublicKey(), and must be the
+// credential public key in the attested authenticator data; attestation
+// statements are not verified.
+func (rp RelyingParty) VerifyRegistration(challenge string, credentialID, clientDataJSON, authData, publicKey []byte) error {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return err
+	}
+	flags, _, err := rp.verifyAuthData(authData)
+	if err != nil {
+		return err
+	}
+	if flags&flagAttestedCredData == 0 {
+		return errors.New("authenticator data has no attested credential")
+	}
+
+	// Attested credential data: AAGUID (16), credential ID length (2),
+	// credential ID, COSE credential public key
+	rest := authData[minAuthDataLength:]
+	if len(rest) < 18 {
+		return errors.New("attested credential data too short")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	if len(rest) < 18+idLen || !bytes.Equal(rest[18:18+idLen], credentialID) {
+		return errors.New("credential ID does not match authenticator data")
+	}
+
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	attested, err := parseCOSEKey(rest[18+idLen:])
+	if err != nil {
+		return err
+	}
+	if k, ok := key.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(attested) {
+		return errors.New("public key does not match the attested credential")
+	}
+	return nil
+}
+
+// VerifyAssertion checks a passkey login made in response to challenge and
+// returns the authenticator's new signature counter. storedCount is the
+// counter saved from the previous use; a counter that does not increase
+// suggests a cloned authenticator. Authenticators that don't implement the
+// counter always report zero.
+func (rp RelyingParty) VerifyAssertion(challenge string, publicKey, clientDataJSON, authData, signature []byte, storedCount uint32) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	_, count, err := rp.verifyAuthData(authData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if !verifySignature(key, signed, signature) {
+		return 0, errors.New("invalid signature")
+	}
+
+	if (count != 0 || storedCount != 0) && count <= storedCount {
+		return 0, errors.New("signature counter did not increase")
+	}
+	return count, nil
+}
+
+// verifyClientData checks the ceremony type, challenge, and origin.
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, wantType, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+	if cd.Type != wantType {
+		return fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	if challenge == "" || cd.Challenge != challenge {
+		return errors.New("challenge mismatch")
+	}
+	if cd.Origin != rp.Origin {
+		return fmt.Errorf("unexpected origin %q", cd.Origin)
+	}
+	return nil
+}
+
+// verifyAuthData checks the RP ID hash and that the user was present and
+// verified, and returns the flags and signature counter.
+func (rp RelyingParty) verifyAuthData(authData []byte) (flags byte, count uint32, err error) {
+	if len(authData) < minAuthDataLength {
+		return 0, 0, errors.New("authenticator data too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return 0, 0, errors.New("authenticator data is for a different relying party")
+	}
+	flags = authData[32]
+	if flags&flagUserPresent == 0 || flags&flagUserVerified == 0 {
+		return 0, 0, errors.New("user was not verified")
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+// parsePublicKey parses a SubjectPublicKeyInfo and checks it is a key type
+// WebAuthn signatures can be verified with.
+func parsePublicKey(der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// verifySignature checks a signature made with the COSE algorithm matching
+// the key type: ES256, RS256, or EdDSA.
+func verifySignature(key crypto.PublicKey, signed, signature []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		return ecdsa.VerifyASN1(k, digest[:], signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, signed, signature)
+	}
+	return false
+}
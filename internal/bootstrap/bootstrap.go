@@ -0,0 +1,136 @@
+// Package bootstrap sets up the admin account when the server starts, so a
+// deployment can declare its admin in the configuration instead of creating
+// it by hand.
+package bootstrap
+
+import (
+	"database/sql"
+	"errors"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"fmt"
+	"log"
+)
+
+// DefaultUsername is the admin's username when none is configured.
+const DefaultUsername = "admin"
+
+// Action is what Admin did.
+type Action string
+
+// Actions of Admin.
+const (
+	Created   Action = "created"   // Created the admin
+	Updated   Action = "updated"   // Changed the admin's password or role
+	Unchanged Action = "unchanged" // The admin was already as configured
+	Skipped   Action = "skipped"   // Nothing configured and users already exist
+)
+
+// Admin makes sure the configured admin exists. With a password configured,
+// the account is created if missing, and otherwise gets that password and the
+// admin role if it doesn't have them yet, so changing the password in the
+// environment takes effect on the next start. Without one, an admin with a
+// random password, printed to the log, is only created in an empty database.
+// Every outcome is logged.
+func Admin(db *storage.DB, admin config.Admin) (Action, error) {
+	username := admin.User
+	if username == "" {
+		username = DefaultUsername
+	}
+	if err := auth.ValidateUsername(username); err != nil {
+		return "", fmt.Errorf("admin user %q: %w", username, err)
+	}
+
+	user, err := db.GetUserByUsername(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		user = nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if admin.Password == "" {
+		return randomAdmin(db, username, user)
+	}
+	if user == nil {
+		if err := create(db, username, admin.Password); err != nil {
+			return "", err
+		}
+		log.Printf("Admin bootstrap: created admin user %s with the configured password", username)
+		return Created, nil
+	}
+	return update(db, user, admin.Password)
+}
+
+// randomAdmin creates the admin with a random password if there are no users
+// at all.
+func randomAdmin(db *storage.DB, username string, user *models.User) (Action, error) {
+	if user != nil {
+		log.Printf("Admin bootstrap: skipped, user %s exists and no admin password is configured", username)
+		return Skipped, nil
+	}
+	count, err := db.UserCount()
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		log.Printf("Admin bootstrap: skipped, users exist and no admin password is configured")
+		return Skipped, nil
+	}
+
+	password, err := auth.GenerateRandomPassword()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate random password: %w", err)
+	}
+	if err := create(db, username, password); err != nil {
+		return "", err
+	}
+	log.Println("=======================================================")
+	log.Printf("Admin bootstrap: created admin user %s with a random password", username)
+	log.Printf("Password: %s", password)
+	log.Println("=======================================================")
+	return Created, nil
+}
+
+// create creates the admin account.
+func create(db *storage.DB, username, password string) error {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if _, err := db.CreateUsers([]storage.NewUser{{Username: username, PasswordHash: hash, Role: models.RoleAdmin}}); err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	return nil
+}
+
+// update gives an existing admin the configured password and the admin role.
+func update(db *storage.DB, user *models.User, password string) (Action, error) {
+	action := Unchanged
+	if !auth.CheckPassword(password, user.PasswordHash) {
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		if err := db.SetPassword(user.ID, hash); err != nil {
+			return "", err
+		}
+		log.Printf("Admin bootstrap: changed the password of admin user %s to the configured one", user.Username)
+		action = Updated
+	}
+	if !user.IsAdmin() {
+		if err := db.SetRole(user.ID, models.RoleAdmin); err != nil {
+			return "", err
+		}
+		log.Printf("Admin bootstrap: made user %s an admin", user.Username)
+		action = Updated
+	}
+	if action == Unchanged {
+		log.Printf("Admin bootstrap: admin user %s already exists with the configured password", user.Username)
+	}
+	if user.Disabled {
+		log.Printf("Warning: admin user %s is disabled and can't sign in, enable it with expensectl users enable", user.Username)
+	}
+	return action, nil
+}
@@ -0,0 +1,97 @@
+package bootstrap
+
+import (
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// BootstrapTestSuite provides a test suite for the admin bootstrap
+type BootstrapTestSuite struct {
+	suite.Suite
+	db *storage.DB
+}
+
+// SetupTest runs before each test
+func (s *BootstrapTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *BootstrapTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// user returns the user with a username.
+func (s *BootstrapTestSuite) user(username string) *models.User {
+	user, err := s.db.GetUserByUsername(username)
+	s.Require().NoError(err)
+	return user
+}
+
+func (s *BootstrapTestSuite) TestRandomPassword() {
+	action, err := Admin(s.db, config.Admin{})
+	s.Require().NoError(err)
+	s.Equal(Created, action)
+	s.True(s.user(DefaultUsername).IsAdmin())
+
+	// Only ever for an empty database
+	action, err = Admin(s.db, config.Admin{User: "root"})
+	s.Require().NoError(err)
+	s.Equal(Skipped, action)
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+func (s *BootstrapTestSuite) TestConfiguredPassword() {
+	_, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+
+	admin := config.Admin{User: "root", Password: "first password"}
+	action, err := Admin(s.db, admin)
+	s.Require().NoError(err)
+	s.Equal(Created, action, "created even though other users exist")
+	s.True(s.user("root").IsAdmin())
+
+	action, err = Admin(s.db, admin)
+	s.Require().NoError(err)
+	s.Equal(Unchanged, action)
+
+	admin.Password = "second password"
+	action, err = Admin(s.db, admin)
+	s.Require().NoError(err)
+	s.Equal(Updated, action)
+	s.True(auth.CheckPassword("second password", s.user("root").PasswordHash))
+}
+
+func (s *BootstrapTestSuite) TestPromotesExistingUser() {
+	_, err := s.db.CreateUser("first", "hash")
+	s.Require().NoError(err)
+	hash, err := auth.HashPassword("secret")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("root", hash)
+	s.Require().NoError(err)
+	s.Require().False(s.user("root").IsAdmin())
+
+	action, err := Admin(s.db, config.Admin{User: "root", Password: "secret"})
+	s.Require().NoError(err)
+	s.Equal(Updated, action)
+	s.True(s.user("root").IsAdmin())
+}
+
+func (s *BootstrapTestSuite) TestInvalidUsername() {
+	_, err := Admin(s.db, config.Admin{User: "a b", Password: "secret"})
+	s.Error(err)
+}
+
+// TestBootstrapSuite runs the admin bootstrap test suite
+func TestBootstrapSuite(t *testing.T) {
+	suite.Run(t, new(BootstrapTestSuite))
+}
@@ -0,0 +1,540 @@
+// Package config loads the server settings from an optional YAML file,
+// environment variables, and command-line flags. Later sources override
+// earlier ones, so a flag beats an environment variable, which beats the file.
+//
+// Every setting has a flag, e.g. -smtp-host, and an environment variable
+// with the flag's name upper-cased and dashes replaced by underscores, e.g.
+// SMTP_HOST. In the file, settings are grouped:
+//
+//	port: 8080
+//	database:
+//	  path: /var/lib/expenses/expenses.db
+//	smtp:
+//	  host: mail.example.com
+//	  from: Expense Tracker <expenses@example.com>
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all server settings.
+type Config struct {
+	Port          string    `yaml:"port"`
+	WebDir        string    `yaml:"web_dir"` // Read templates and static files from disk
+	Dev           bool      `yaml:"dev"`     // Reload templates and static files on change
+	Debug         bool      `yaml:"debug"`   // Log the database queries of each request
+	Database      Database  `yaml:"database"`
+	Cookie        Cookie    `yaml:"cookie"`
+	Session       Session   `yaml:"session"`
+	HTTP          HTTP      `yaml:"http"`
+	SMTP          SMTP      `yaml:"smtp"`
+	LDAP          LDAP      `yaml:"ldap"`
+	Features      Features  `yaml:"features"`
+	Admin         Admin     `yaml:"admin"`
+	Demo          Demo      `yaml:"demo"`
+	Notify        Notify    `yaml:"notify"`
+	Retention     Retention `yaml:"retention"`
+	SecretKey     string    `yaml:"secret_key"`     // Signs emailed links
+	PasskeyOrigin string    `yaml:"passkey_origin"` // Derived from each request when empty
+	MetricsAddr   string    `yaml:"metrics_addr"`   // Metrics are off when empty
+	ReadOnly      bool      `yaml:"read_only"`      // Start in read-only mode
+	// Reverse proxies whose X-Forwarded-For and X-Forwarded-Proto headers
+	// are believed
+	TrustedProxies Prefixes `yaml:"trusted_proxies"`
+}
+
+// Database selects where expenses are stored.
+type Database struct {
+	Driver string `yaml:"driver"` // Only "sqlite" for now
+	Path   string `yaml:"path"`
+	// Apply pending migrations on start. When off, the server refuses to
+	// start until they are applied with expensectl migrate up.
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// Longest a request's queries may take, e.g. waiting for a locked
+	// database, before the request fails. 0 means no limit.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	// Connection pool. SQLite takes one writer at a time, so by default one
+	// connection serves all queries in turn instead of failing on locks.
+	MaxOpenConns    int           `yaml:"max_open_conns"` // 0 means no limit
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // 0 reuses connections for good
+}
+
+// Cookie configures the session cookie.
+type Cookie struct {
+	Secure     bool   `yaml:"secure"` // Only send it over HTTPS
+	Name       string `yaml:"name"`
+	HostPrefix bool   `yaml:"host_prefix"` // Add the __Host- prefix
+	SameSite   string `yaml:"same_site"`   // "lax" or "strict"
+}
+
+// Session configures sessions.
+type Session struct {
+	Binding         string        `yaml:"binding"`          // "off", "browser", or "network"
+	CleanupInterval time.Duration `yaml:"cleanup_interval"` // 0 leaves cleanup to expensectl
+}
+
+// HTTP configures the HTTP server.
+type HTTP struct {
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxHeaderBytes  Size          `yaml:"max_header_bytes"`
+	MaxBodyBytes    Size          `yaml:"max_body_bytes"`
+	CompressMinSize Size          `yaml:"compress_min_size"`
+}
+
+// SMTP configures outgoing email. Emails are logged when Host is empty.
+type SMTP struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	TLS      string `yaml:"tls"` // "starttls", "tls", or "none"
+}
+
+// LDAP configures authentication against a directory server. Local
+// passwords are used when URL is empty.
+type LDAP struct {
+	URL     string `yaml:"url"`
+	UserDN  string `yaml:"user_dn"` // Contains %s for the username
+	GroupDN string `yaml:"group_dn"`
+}
+
+// Features switches optional features on and off.
+type Features struct {
+	// AllowSignup is off by default, since whoever registers sees the
+	// expenses the household shares.
+	AllowSignup bool `yaml:"allow_signup"`
+}
+
+// Demo runs the app as a public demo with made-up data.
+type Demo struct {
+	Enabled       bool          `yaml:"enabled"`
+	ResetInterval time.Duration `yaml:"reset_interval"` // 0 never resets
+}
+
+// Notify configures the notifications users get through ntfy, Web Push,
+// and email.
+type Notify struct {
+	NtfyServer  string        `yaml:"ntfy_server"`  // Where users' topics are
+	Interval    time.Duration `yaml:"interval"`     // How often events are checked, 0 never
+	BaseURL     string        `yaml:"base_url"`     // Public URL notifications link to; no links when empty
+	PushSubject string        `yaml:"push_subject"` // Contact for push services, a mailto: or https: URL
+}
+
+// Retention says how long data is kept. Zero keeps it forever.
+type Retention struct {
+	LoginHistoryDays int `yaml:"login_history_days"`
+	InviteDays       int `yaml:"invite_days"`   // Counted from when the invite expires
+	ExpenseYears     int `yaml:"expense_years"` // Older expenses are anonymized
+}
+
+// Admin is the account set up on start, see package bootstrap. The files,
+// e.g. Docker secrets, hold the username or password instead.
+type Admin struct {
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	UserFile     string `yaml:"user_file"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() *Config {
+	return &Config{
+		Port: "8080",
+		Database: Database{
+			Driver:       "sqlite",
+			Path:         "expenses.db",
+			AutoMigrate:  true,
+			QueryTimeout: 2 * time.Second,
+			MaxOpenConns: 1,
+			MaxIdleConns: 1,
+		},
+		Cookie:  Cookie{Name: "session", SameSite: "lax"},
+		Session: Session{Binding: "off", CleanupInterval: time.Hour},
+		HTTP: HTTP{
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    60 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 5 * time.Second,
+			MaxHeaderBytes:  64 << 10,
+			MaxBodyBytes:    1 << 20,
+			CompressMinSize: 1 << 10,
+		},
+		SMTP:   SMTP{Port: 587, TLS: "starttls"},
+		Demo:   Demo{ResetInterval: time.Hour},
+		Notify: Notify{NtfyServer: "https://ntfy.sh", Interval: time.Hour},
+	}
+}
+
+// flags registers a flag for every setting, bound to c.
+func (c *Config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Port, "port", c.Port, "port or address to listen on")
+	fs.StringVar(&c.WebDir, "web-dir", c.WebDir, "read templates and static files from this directory instead of the binary")
+	fs.BoolVar(&c.Dev, "dev", c.Dev, "development mode: read templates and static files from -web-dir (web by default), reload pages when they change, and turn off caching")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "log how long each request spent on which database queries")
+	fs.StringVar(&c.Database.Driver, "db-driver", c.Database.Driver, "database driver, only sqlite for now")
+	fs.StringVar(&c.Database.Path, "db-path", c.Database.Path, "SQLite database path")
+	fs.BoolVar(&c.Database.AutoMigrate, "db-auto-migrate", c.Database.AutoMigrate, "apply pending database migrations on start; when false, refuse to start until expensectl migrate up applies them")
+	fs.DurationVar(&c.Database.QueryTimeout, "db-query-timeout", c.Database.QueryTimeout, "longest a request's database queries may take before it fails, 0 for no limit")
+	fs.IntVar(&c.Database.MaxOpenConns, "db-max-open-conns", c.Database.MaxOpenConns, "most database connections open at once, 0 for no limit")
+	fs.IntVar(&c.Database.MaxIdleConns, "db-max-idle-conns", c.Database.MaxIdleConns, "most idle database connections kept for reuse")
+	fs.DurationVar(&c.Database.ConnMaxLifetime, "db-conn-max-lifetime", c.Database.ConnMaxLifetime, "close database connections after this long, 0 to reuse them for good")
+	fs.BoolVar(&c.Cookie.Secure, "secure-cookie", c.Cookie.Secure, "only send the session cookie over HTTPS")
+	fs.StringVar(&c.Cookie.Name, "session-cookie-name", c.Cookie.Name, "name of the session cookie")
+	fs.BoolVar(&c.Cookie.HostPrefix, "session-cookie-host-prefix", c.Cookie.HostPrefix, "prefix the session cookie name with __Host-")
+	fs.StringVar(&c.Cookie.SameSite, "session-cookie-samesite", c.Cookie.SameSite, "SameSite mode of the session cookie: lax or strict")
+	fs.StringVar(&c.Session.Binding, "session-binding", c.Session.Binding, "bind sessions to the browser or network that logged in: off, browser, or network")
+	fs.DurationVar(&c.Session.CleanupInterval, "session-cleanup-interval", c.Session.CleanupInterval, "how often expired sessions and deleted accounts are removed and the retention policy is applied, 0 to disable")
+	fs.DurationVar(&c.HTTP.ReadTimeout, "http-read-timeout", c.HTTP.ReadTimeout, "longest time to read a whole request")
+	fs.DurationVar(&c.HTTP.WriteTimeout, "http-write-timeout", c.HTTP.WriteTimeout, "longest time to write a response")
+	fs.DurationVar(&c.HTTP.IdleTimeout, "http-idle-timeout", c.HTTP.IdleTimeout, "how long idle keep-alive connections stay open")
+	fs.DurationVar(&c.HTTP.ShutdownTimeout, "shutdown-timeout", c.HTTP.ShutdownTimeout, "how long requests in progress may take to finish when stopping")
+	fs.Var(&c.HTTP.MaxHeaderBytes, "http-max-header-bytes", "largest request headers accepted")
+	fs.Var(&c.HTTP.MaxBodyBytes, "http-max-body-bytes", "largest request body accepted")
+	fs.Var(&c.HTTP.CompressMinSize, "compress-min-size", "smallest response to compress")
+	fs.StringVar(&c.SMTP.Host, "smtp-host", c.SMTP.Host, "mail server for outgoing email, emails are logged when empty")
+	fs.IntVar(&c.SMTP.Port, "smtp-port", c.SMTP.Port, "mail server port")
+	fs.StringVar(&c.SMTP.Username, "smtp-username", c.SMTP.Username, "mail server login")
+	fs.StringVar(&c.SMTP.Password, "smtp-password", c.SMTP.Password, "mail server password")
+	fs.StringVar(&c.SMTP.From, "smtp-from", c.SMTP.From, "sender of emails")
+	fs.StringVar(&c.SMTP.TLS, "smtp-tls", c.SMTP.TLS, "mail server encryption: starttls, tls, or none")
+	fs.StringVar(&c.LDAP.URL, "ldap-url", c.LDAP.URL, "authenticate against this LDAP server, ldaps:// only since binds send the password")
+	fs.StringVar(&c.LDAP.UserDN, "ldap-user-dn", c.LDAP.UserDN, "bind DN with %s for the username")
+	fs.StringVar(&c.LDAP.GroupDN, "ldap-group-dn", c.LDAP.GroupDN, "only allow members of this group")
+	fs.BoolVar(&c.Features.AllowSignup, "allow-signup", c.Features.AllowSignup, "let visitors register accounts, which see the household's shared expenses (off by default)")
+	fs.StringVar(&c.Admin.User, "admin-user", c.Admin.User, "admin username, created or updated on start")
+	fs.StringVar(&c.Admin.Password, "admin-password", c.Admin.Password, "admin password, set on start; random for a new database when empty")
+	fs.StringVar(&c.Admin.UserFile, "admin-user-file", c.Admin.UserFile, "read the admin username from this file")
+	fs.StringVar(&c.Admin.PasswordFile, "admin-password-file", c.Admin.PasswordFile, "read the admin password from this file")
+	fs.BoolVar(&c.Demo.Enabled, "demo", c.Demo.Enabled, "run as a public demo, replacing all data with a demo account and made-up expenses")
+	fs.DurationVar(&c.Demo.ResetInterval, "demo-reset-interval", c.Demo.ResetInterval, "how often the demo data is reset, 0 to never reset")
+	fs.StringVar(&c.Notify.NtfyServer, "notify-ntfy-server", c.Notify.NtfyServer, "ntfy server that users' topics are on, given by name or by URL")
+	fs.DurationVar(&c.Notify.Interval, "notify-interval", c.Notify.Interval, "how often to check for budget alerts, bill reminders, and weekly digests to send, 0 to never")
+	fs.StringVar(&c.Notify.BaseURL, "notify-base-url", c.Notify.BaseURL, "public URL of the server, e.g. https://expenses.example.com, for links in notifications")
+	fs.StringVar(&c.Notify.PushSubject, "notify-push-subject", c.Notify.PushSubject, "contact for Web Push services, a mailto: or https: URL")
+	fs.IntVar(&c.Retention.LoginHistoryDays, "retention-login-history-days", c.Retention.LoginHistoryDays, "delete login history after this many days, 0 to keep it")
+	fs.IntVar(&c.Retention.InviteDays, "retention-invite-days", c.Retention.InviteDays, "delete invites this many days after they expire, 0 to keep them")
+	fs.IntVar(&c.Retention.ExpenseYears, "retention-expense-years", c.Retention.ExpenseYears, "anonymize expenses after this many years, 0 to keep them as they are")
+	fs.StringVar(&c.SecretKey, "secret-key", c.SecretKey, "key that signs emailed links")
+	fs.StringVar(&c.PasskeyOrigin, "passkey-origin", c.PasskeyOrigin, "origin passkeys are bound to")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "serve metrics on this address")
+	fs.BoolVar(&c.ReadOnly, "read-only", c.ReadOnly, "start in read-only mode, refusing changes until an admin turns it off")
+	fs.Var(&c.TrustedProxies, "trusted-proxies", "comma-separated addresses or CIDR ranges of reverse proxies to trust X-Forwarded-For and X-Forwarded-Proto from")
+}
+
+// envName returns the environment variable for a flag.
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Load reads the settings from the file named by the -config flag or the
+// CONFIG_FILE environment variable, then the environment, then args, and
+// validates them. With -h, it returns flag.ErrHelp after printing usage.
+func Load(args []string) (*Config, error) {
+	c := Default()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	c.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: server [flags]\n\nEvery flag can also be set in the environment, e.g. -db-path as DB_PATH.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	// Remember the flags given, to apply them again over the file and the
+	// environment
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+
+	if *path != "" {
+		if err := c.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		name := envName(f.Name)
+		if v := os.Getenv(name); v != "" {
+			if err := fs.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: %w", name, v, err))
+			}
+		}
+	})
+	for name, v := range given {
+		if err := fs.Set(name, v); err != nil {
+			return nil, err
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := c.Validate(); err != nil {
+		return c, err
+	}
+	return c, c.readSecretFiles()
+}
+
+// readSecretFiles reads the settings that are given as the name of a file
+// holding them, without the trailing newline.
+func (c *Config) readSecretFiles() error {
+	for _, f := range []struct {
+		path  string
+		value *string
+	}{
+		{c.Admin.UserFile, &c.Admin.User},
+		{c.Admin.PasswordFile, &c.Admin.Password},
+	} {
+		if f.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		*f.value = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// readFile reads settings from a YAML file, rejecting unknown keys so typos
+// don't go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks that the settings make sense together.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Port != "", "port is required")
+	check(c.Database.Driver == "sqlite", "unsupported database driver %q, only sqlite is supported", c.Database.Driver)
+	check(c.Database.Path != "", "database path is required")
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0, "database connection limits must not be negative")
+	check(c.Cookie.Name != "", "session cookie name is required")
+	check(oneOf(c.Cookie.SameSite, "lax", "strict"), "invalid session cookie SameSite mode %q, want lax or strict", c.Cookie.SameSite)
+	check(oneOf(c.Session.Binding, "off", "browser", "network"), "invalid session binding %q, want off, browser, or network", c.Session.Binding)
+	for name, d := range map[string]time.Duration{
+		"database query timeout":       c.Database.QueryTimeout,
+		"database connection lifetime": c.Database.ConnMaxLifetime,
+		"session cleanup interval":     c.Session.CleanupInterval,
+		"HTTP read timeout":            c.HTTP.ReadTimeout,
+		"HTTP write timeout":           c.HTTP.WriteTimeout,
+		"HTTP idle timeout":            c.HTTP.IdleTimeout,
+		"shutdown timeout":             c.HTTP.ShutdownTimeout,
+		"demo reset interval":          c.Demo.ResetInterval,
+		"notification interval":        c.Notify.Interval,
+	} {
+		check(d >= 0, "%s must not be negative", name)
+	}
+	for name, s := range map[string]Size{
+		"HTTP max header bytes": c.HTTP.MaxHeaderBytes,
+		"HTTP max body bytes":   c.HTTP.MaxBodyBytes,
+		"compress min size":     c.HTTP.CompressMinSize,
+	} {
+		check(s > 0, "%s must be positive", name)
+	}
+	check(c.Retention.LoginHistoryDays >= 0 && c.Retention.InviteDays >= 0 && c.Retention.ExpenseYears >= 0, "retention periods must not be negative")
+	check(c.SMTP.Port > 0 && c.SMTP.Port < 65536, "invalid SMTP port %d", c.SMTP.Port)
+	check(oneOf(c.SMTP.TLS, "starttls", "tls", "none"), "invalid SMTP TLS mode %q, want starttls, tls, or none", c.SMTP.TLS)
+	check(c.SMTP.Host == "" || c.SMTP.From != "", "SMTP sender (from) is required when an SMTP host is set")
+	check(strings.HasPrefix(c.Notify.NtfyServer, "https://") || strings.HasPrefix(c.Notify.NtfyServer, "http://"), "ntfy server must be an http or https URL")
+	check(c.Notify.BaseURL == "" || strings.HasPrefix(c.Notify.BaseURL, "https://") || strings.HasPrefix(c.Notify.BaseURL, "http://"), "notification base URL must be an http or https URL")
+	check(c.LDAP.URL == "" || strings.Contains(c.LDAP.UserDN, "%s"), "LDAP user DN must contain %%s for the username")
+	check(c.LDAP.URL == "" || strings.HasPrefix(c.LDAP.URL, "ldaps://"), "LDAP URL must be ldaps://, since ldap:// sends passwords in cleartext")
+	check(c.Admin.User == "" || c.Admin.UserFile == "", "set the admin user or the admin user file, not both")
+	check(c.Admin.Password == "" || c.Admin.PasswordFile == "", "set the admin password or the admin password file, not both")
+	check(!c.Demo.Enabled || c.LDAP.URL == "", "demo mode can't be used with LDAP, which rejects the demo account's password")
+	return errors.Join(errs...)
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted replaces secrets in the printed configuration.
+const redacted = "[redacted]"
+
+// Print writes the settings as YAML, with passwords and keys redacted.
+func (c *Config) Print(w io.Writer) error {
+	shown := *c
+	for _, secret := range []*string{&shown.SMTP.Password, &shown.Admin.Password, &shown.SecretKey} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(shown); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Size is a number of bytes, written with an optional KB, MB, or GB suffix
+// such as "64KB".
+type Size int64
+
+// sizeUnits are the suffixes ParseSize accepts, longest first so "KB" is
+// tried before "B".
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a positive size such as "512", "64KB", or "1 MB".
+func ParseSize(v string) (Size, error) {
+	s, unit := strings.ToUpper(strings.TrimSpace(v)), int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = strings.TrimSpace(n), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q, want a size such as 1MB", v)
+	}
+	return Size(n * unit), nil
+}
+
+// String formats the size with the largest unit that divides it evenly.
+func (s Size) String() string {
+	for _, u := range sizeUnits {
+		if int64(s) >= u.bytes && int64(s)%u.bytes == 0 {
+			return strconv.FormatInt(int64(s)/u.bytes, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Set implements flag.Value.
+func (s *Size) Set(v string) error {
+	n, err := ParseSize(v)
+	if err != nil {
+		return err
+	}
+	*s = n
+	return nil
+}
+
+// UnmarshalYAML accepts a plain number of bytes or a string with a unit.
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	return s.Set(node.Value)
+}
+
+// MarshalYAML writes the size with a unit.
+func (s Size) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// Prefixes is a list of IP addresses and CIDR ranges, written separated by
+// commas such as "127.0.0.1, 10.0.0.0/8".
+type Prefixes []netip.Prefix
+
+// ParsePrefixes parses a comma-separated list of addresses and CIDR ranges.
+func ParsePrefixes(v string) (Prefixes, error) {
+	var prefixes Prefixes
+	for part := range strings.SplitSeq(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.Contains(part, "/") {
+			p, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", part)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", part)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// String formats the list separated by commas.
+func (p Prefixes) String() string {
+	parts := make([]string, len(p))
+	for i, prefix := range p {
+		parts[i] = prefix.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value.
+func (p *Prefixes) Set(v string) error {
+	prefixes, err := ParsePrefixes(v)
+	if err != nil {
+		return err
+	}
+	*p = prefixes
+	return nil
+}
+
+// UnmarshalYAML accepts a YAML list or a comma-separated string.
+func (p *Prefixes) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		return p.Set(strings.Join(items, ","))
+	}
+	return p.Set(node.Value)
+}
+
+// MarshalYAML writes the list as a comma-separated string.
+func (p Prefixes) MarshalYAML() (any, error) {
+	return p.String(), nil
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ConfigTestSuite provides a test suite for loading the configuration
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+// writeFile writes a config file and returns its path.
+func (s *ConfigTestSuite) writeFile(content string) string {
+	path := filepath.Join(s.T().TempDir(), "config.yaml")
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func (s *ConfigTestSuite) TestDefaults() {
+	cfg, err := Load(nil)
+	s.Require().NoError(err)
+	s.Equal(Default(), cfg)
+	s.Equal("8080", cfg.Port)
+	s.Equal("expenses.db", cfg.Database.Path)
+	s.Equal(Size(1<<20), cfg.HTTP.MaxBodyBytes)
+	s.False(cfg.Features.AllowSignup, "registrants would see the household's expenses")
+}
+
+func (s *ConfigTestSuite) TestLayering() {
+	path := s.writeFile(`
+port: 9000
+database:
+  path: /data/file.db
+smtp:
+  host: mail.example.com
+  port: 465
+  from: Expenses <expenses@example.com>
+  tls: tls
+http:
+  read_timeout: 10s
+  max_body_bytes: 2MB
+features:
+  allow_signup: true
+`)
+	s.T().Setenv("CONFIG_FILE", path)
+	s.T().Setenv("DB_PATH", "/data/env.db")
+	s.T().Setenv("SMTP_HOST", "relay.example.com")
+	s.T().Setenv("HTTP_MAX_BODY_BYTES", "4MB")
+
+	cfg, err := Load([]string{"-smtp-host", "flag.example.com", "-port=7000"})
+	s.Require().NoError(err)
+
+	s.Equal("7000", cfg.Port, "flags override the file")
+	s.Equal("/data/env.db", cfg.Database.Path, "the environment overrides the file")
+	s.Equal("flag.example.com", cfg.SMTP.Host, "flags override the environment")
+	s.Equal(Size(4<<20), cfg.HTTP.MaxBodyBytes)
+	s.Equal(465, cfg.SMTP.Port, "the file overrides defaults")
+	s.Equal(10*time.Second, cfg.HTTP.ReadTimeout)
+	s.True(cfg.Features.AllowSignup)
+	s.Equal(60*time.Second, cfg.HTTP.WriteTimeout, "unset values keep their default")
+}
+
+func (s *ConfigTestSuite) TestConfigFlag() {
+	path := s.writeFile("port: 9000\n")
+	cfg, err := Load([]string{"-config", path})
+	s.Require().NoError(err)
+	s.Equal("9000", cfg.Port)
+}
+
+func (s *ConfigTestSuite) TestErrors() {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+		want string
+	}{
+		{name: "unknown flag", args: []string{"-colour"}, want: "colour"},
+		{name: "extra argument", args: []string{"serve"}, want: "serve"},
+		{name: "bad environment value", env: map[string]string{"HTTP_IDLE_TIMEOUT": "forever"}, want: "HTTP_IDLE_TIMEOUT"},
+		{name: "bad size", env: map[string]string{"COMPRESS_MIN_SIZE": "0"}, want: "COMPRESS_MIN_SIZE"},
+		{name: "unknown file key", file: "prot: 9000\n", want: "prot"},
+		{name: "bad file value", file: "http:\n  max_body_bytes: lots\n", want: "lots"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.yaml"}, want: "config"},
+		{name: "driver", env: map[string]string{"DB_DRIVER": "postgres"}, want: "postgres"},
+		{name: "same site", env: map[string]string{"SESSION_COOKIE_SAMESITE": "none"}, want: "SameSite"},
+		{name: "binding", env: map[string]string{"SESSION_BINDING": "ip"}, want: "binding"},
+		{name: "negative duration", args: []string{"-shutdown-timeout=-1s"}, want: "shutdown timeout"},
+		{name: "negative query timeout", env: map[string]string{"DB_QUERY_TIMEOUT": "-1s"}, want: "query timeout"},
+		{name: "negative pool", env: map[string]string{"DB_MAX_OPEN_CONNS": "-1"}, want: "connection limits"},
+		{name: "negative retention", env: map[string]string{"RETENTION_EXPENSE_YEARS": "-1"}, want: "retention"},
+		{name: "SMTP TLS", env: map[string]string{"SMTP_TLS": "ssl"}, want: "TLS"},
+		{name: "SMTP sender", env: map[string]string{"SMTP_HOST": "mail.example.com"}, want: "from"},
+		{name: "trusted proxy", args: []string{"-trusted-proxies", "10.0.0.0/33"}, want: "10.0.0.0/33"},
+		{name: "LDAP user DN", env: map[string]string{"LDAP_URL": "ldaps://ldap", "LDAP_USER_DN": "ou=people"}, want: "%s"},
+		{name: "cleartext LDAP", env: map[string]string{"LDAP_URL": "ldap://ldap", "LDAP_USER_DN": "uid=%s"}, want: "ldaps://"},
+		{name: "admin password twice", env: map[string]string{"ADMIN_PASSWORD": "secret", "ADMIN_PASSWORD_FILE": "/run/secrets/admin"}, want: "not both"},
+		{name: "missing admin password file", env: map[string]string{"ADMIN_PASSWORD_FILE": "/nonexistent/admin"}, want: "/nonexistent/admin"},
+		{name: "notification base URL", env: map[string]string{"NOTIFY_BASE_URL": "expenses.example.com"}, want: "base URL"},
+		{name: "demo with LDAP", env: map[string]string{"DEMO": "true", "LDAP_URL": "ldaps://ldap", "LDAP_USER_DN": "uid=%s"}, want: "demo"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			for k, v := range tt.env {
+				s.T().Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", s.writeFile(tt.file))
+			}
+			_, err := Load(args)
+			s.Require().Error(err)
+			s.Contains(err.Error(), tt.want)
+		})
+	}
+}
+
+func (s *ConfigTestSuite) TestAdminFiles() {
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "user"), []byte("root\n"), 0o600))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "password"), []byte("correct horse\r\n"), 0o600))
+	s.T().Setenv("ADMIN_USER_FILE", filepath.Join(dir, "user"))
+	s.T().Setenv("ADMIN_PASSWORD_FILE", filepath.Join(dir, "password"))
+
+	cfg, err := Load(nil)
+	s.Require().NoError(err)
+	s.Equal("root", cfg.Admin.User)
+	s.Equal("correct horse", cfg.Admin.Password, "the trailing newline is dropped")
+}
+
+func (s *ConfigTestSuite) TestHelp() {
+	_, err := Load([]string{"-h"})
+	s.ErrorIs(err, flag.ErrHelp)
+}
+
+func (s *ConfigTestSuite) TestPrint() {
+	cfg := Default()
+	cfg.SMTP.Password = "hunter2"
+	cfg.SecretKey = "very secret"
+
+	var out strings.Builder
+	s.Require().NoError(cfg.Print(&out))
+	s.NotContains(out.String(), "hunter2")
+	s.NotContains(out.String(), "very secret")
+	s.Contains(out.String(), "password: '[redacted]'")
+	s.Contains(out.String(), "max_body_bytes: 1MB")
+	s.Contains(out.String(), "read_timeout: 30s")
+	s.Equal("very secret", cfg.SecretKey, "printing leaves the config alone")
+}
+
+func (s *ConfigTestSuite) TestParseSize() {
+	for v, want := range map[string]Size{
+		"512":   512,
+		"64KB":  64 << 10,
+		"2mb":   2 << 20,
+		"1 GB":  1 << 30,
+		"100 B": 100,
+	} {
+		n, err := ParseSize(v)
+		s.Require().NoError(err, v)
+		s.Equal(want, n, v)
+	}
+
+	for _, v := range []string{"big", "0", "-5MB", "1.5MB", "MB"} {
+		_, err := ParseSize(v)
+		s.Error(err, v)
+	}
+
+	s.Equal("64KB", Size(64<<10).String())
+	s.Equal("1500B", Size(1500).String())
+}
+
+func (s *ConfigTestSuite) TestTrustedProxies() {
+	path := s.writeFile("trusted_proxies:\n  - 127.0.0.1\n  - 10.1.2.3/8\n")
+	cfg, err := Load([]string{"-config", path})
+	s.Require().NoError(err)
+	s.Equal("127.0.0.1/32,10.0.0.0/8", cfg.TrustedProxies.String())
+
+	s.T().Setenv("TRUSTED_PROXIES", "::1, 172.16.0.0/12")
+	cfg, err = Load(nil)
+	s.Require().NoError(err)
+	s.Equal("::1/128,172.16.0.0/12", cfg.TrustedProxies.String())
+
+	s.T().Setenv("TRUSTED_PROXIES", "localhost")
+	_, err = Load(nil)
+	s.ErrorContains(err, "localhost")
+}
+
+// TestConfigSuite runs the configuration test suite
+func TestConfigSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
@@ -0,0 +1,115 @@
+// Package demo fills the database with a demo account and made-up expenses,
+// for hosting a public demo of the app.
+package demo
+
+import (
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Credentials of the demo account, shown on the login page.
+const (
+	Username = "demo"
+	Password = "demo"
+)
+
+// days is how far back the made-up expenses go.
+const days = 90
+
+// monthly are expenses due on a fixed day of every month.
+var monthly = []struct {
+	day         int
+	amount      float64
+	description string
+	category    string
+}{
+	{1, 950, "Rent", "Housing"},
+	{1, 49, "Monthly transit pass", "Transport"},
+	{3, 29.90, "Gym membership", "Sport"},
+	{12, 39.99, "Internet", "Utilities"},
+	{15, 12.99, "Streaming subscription", "Entertainment"},
+	{20, 72, "Electricity", "Utilities"},
+}
+
+// occasional are expenses that happen on some days, with the chance of one
+// on any given day and their amount range.
+var occasional = []struct {
+	chance       float64
+	min, max     float64
+	descriptions []string
+	category     string
+}{
+	{0.35, 8, 85, []string{"Supermarket", "Farmers market", "Bakery", "Organic store"}, "Groceries"},
+	{0.30, 2.5, 6, []string{"Coffee", "Cappuccino", "Tea and croissant"}, "Eating Out"},
+	{0.12, 11, 48, []string{"Lunch with colleagues", "Pizza night", "Sushi", "Burger place", "Thai takeaway"}, "Eating Out"},
+	{0.06, 9, 35, []string{"Taxi", "Bike repair", "Train ticket", "Fuel"}, "Transport"},
+	{0.05, 10, 60, []string{"Cinema", "Concert tickets", "Board game", "Museum"}, "Entertainment"},
+	{0.04, 6, 40, []string{"Pharmacy", "Vitamins", "Dentist co-pay"}, "Health"},
+	{0.02, 15, 70, []string{"Birthday present", "Flowers", "Wedding gift"}, "Gifts"},
+	{0.03, 5, 45, []string{"Hardware store", "Haircut", "Phone case"}, "Other"},
+}
+
+// Reset replaces everything in db with the demo account and expenses for the
+// days up to now. The same now gives the same expenses.
+func Reset(db *storage.DB, now time.Time) (*models.User, error) {
+	if err := db.Reset(); err != nil {
+		return nil, fmt.Errorf("demo: %w", err)
+	}
+	hash, err := auth.HashPassword(Password)
+	if err != nil {
+		return nil, fmt.Errorf("demo: %w", err)
+	}
+	user, err := db.CreateUser(Username, hash)
+	if err != nil {
+		return nil, fmt.Errorf("demo: %w", err)
+	}
+	// Visitors shouldn't see admin pages
+	if err := db.SetRole(user.ID, models.RoleMember); err != nil {
+		return nil, fmt.Errorf("demo: %w", err)
+	}
+	if err := seed(db, user.ID, now); err != nil {
+		return nil, fmt.Errorf("demo: %w", err)
+	}
+	return db.GetUserByID(user.ID)
+}
+
+// seed creates a few months of plausible expenses for userID.
+func seed(db *storage.DB, userID int64, now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rng := rand.New(rand.NewPCG(uint64(today.Unix()), 2666))
+	at := func(day time.Time) time.Time {
+		// Between 8:00 and 21:59, but not later than now
+		t := day.Add(time.Duration(8+rng.IntN(14))*time.Hour + time.Duration(rng.IntN(60))*time.Minute)
+		if t.After(now) {
+			return now
+		}
+		return t
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		for _, e := range monthly {
+			if day.Day() == e.day {
+				if err := db.CreateExpense(e.amount, e.description, e.category, at(day), userID); err != nil {
+					return err
+				}
+			}
+		}
+		for _, e := range occasional {
+			if rng.Float64() >= e.chance {
+				continue
+			}
+			amount := math.Round((e.min+rng.Float64()*(e.max-e.min))*100) / 100
+			description := e.descriptions[rng.IntN(len(e.descriptions))]
+			if err := db.CreateExpense(amount, description, e.category, at(day), userID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
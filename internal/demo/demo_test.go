@@ -0,0 +1,90 @@
+package demo
+
+import (
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DemoTestSuite provides a test suite for the demo data
+type DemoTestSuite struct {
+	suite.Suite
+	db  *storage.DB
+	now time.Time
+}
+
+// SetupTest runs before each test
+func (s *DemoTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Date(2026, 3, 18, 14, 30, 0, 0, time.Local)
+}
+
+// TearDownTest runs after each test
+func (s *DemoTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *DemoTestSuite) TestReset() {
+	other, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateExpense(12, "Real expense", "Groceries", s.now, other.ID))
+	s.Require().NoError(s.db.CreateSession("token", other.ID, time.Hour, ""))
+
+	user, err := Reset(s.db, s.now)
+	s.Require().NoError(err)
+
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count, "other accounts are gone")
+	_, err = s.db.ValidateSession("token")
+	s.Error(err, "and so are their sessions")
+
+	s.Equal(Username, user.Username)
+	s.Equal(models.RoleMember, user.Role)
+	s.True(auth.CheckPassword(Password, user.PasswordHash))
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Greater(len(expenses), days/2)
+	for _, e := range expenses {
+		s.NotEqual("Real expense", e.Description)
+		s.Equal(user.ID, *e.UserID)
+		s.False(e.Date.After(s.now), "no expenses in the future")
+		s.True(e.Date.After(s.now.AddDate(0, 0, -days)))
+		s.Positive(e.Amount)
+	}
+
+	// Statistics come from the aggregates, which must match
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Positive(total)
+}
+
+func (s *DemoTestSuite) TestResetIsRepeatable() {
+	_, err := Reset(s.db, s.now)
+	s.Require().NoError(err)
+	first, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+
+	_, err = Reset(s.db, s.now)
+	s.Require().NoError(err)
+	second, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+
+	s.Require().Equal(len(first), len(second))
+	for i := range first {
+		s.Equal(first[i].Amount, second[i].Amount)
+		s.Equal(first[i].Description, second[i].Description)
+	}
+}
+
+// TestDemoSuite runs the demo data test suite
+func TestDemoSuite(t *testing.T) {
+	suite.Run(t, new(DemoTestSuite))
+}
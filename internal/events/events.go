@@ -0,0 +1,153 @@
+// Package events passes changes to the expenses from the requests making
+// them to the clients following along, within one server process. Changes
+// made by expensectl or another server process aren't seen.
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"expense-tracker/internal/models"
+)
+
+// Types of events.
+const (
+	ExpenseCreated = "expense.created"
+	ExpenseUpdated = "expense.updated"
+	ExpenseDeleted = "expense.deleted"
+)
+
+// Event is a change to an expense.
+type Event struct {
+	Type    string          `json:"type"`
+	ID      int64           `json:"id"`                // Of the expense
+	Expense *models.Expense `json:"expense,omitempty"` // As it is now, nil once deleted
+	UserID  int64           `json:"user_id"`           // Who made the change
+}
+
+// MaxSubscriptions is how many subscriptions a user can have at once, like
+// one per open tab or device, so a runaway client can't tie up the server.
+const MaxSubscriptions = 10
+
+// bufferSize is how many events a subscription holds for its subscriber
+// before it's dropped as too slow.
+const bufferSize = 32
+
+var (
+	// ErrClosed is returned by Subscribe and Subscription.Err once the hub
+	// is closed.
+	ErrClosed = errors.New("events: hub closed")
+	// ErrTooManySubscriptions is returned by Subscribe when the user has
+	// MaxSubscriptions already.
+	ErrTooManySubscriptions = errors.New("events: too many subscriptions")
+	// ErrTooSlow is returned by Subscription.Err when the subscription was
+	// dropped for not keeping up. Its subscriber may have missed events.
+	ErrTooSlow = errors.New("events: subscriber too slow")
+)
+
+// Hub fans events out to subscriptions, which are kept per user.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub returns a hub without subscriptions.
+func NewHub() *Hub {
+	return &Hub{subs: map[int64]map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the events published after it was made.
+type Subscription struct {
+	// C receives the events. It's closed when the subscription ends, see
+	// Err for why.
+	C <-chan Event
+
+	c      chan Event
+	userID int64
+	hub    *Hub
+	err    error // Set before c is closed
+}
+
+// Subscribe starts a subscription of user userID. Cancel it once done.
+func (h *Hub) Subscribe(userID int64) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if len(h.subs[userID]) >= MaxSubscriptions {
+		return nil, ErrTooManySubscriptions
+	}
+	c := make(chan Event, bufferSize)
+	s := &Subscription{C: c, c: c, userID: userID, hub: h}
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][s] = struct{}{}
+	return s, nil
+}
+
+// Publish sends e to every subscription. Everyone shares the expenses, so
+// everyone gets every change. Subscriptions that are too slow to take it are
+// dropped rather than holding up the request publishing it.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.subs {
+		for s := range subs {
+			select {
+			case s.c <- e:
+			default:
+				h.end(s, ErrTooSlow)
+			}
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions userID has.
+func (h *Hub) Subscribers(userID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID])
+}
+
+// Close ends every subscription with ErrClosed and refuses new ones, so the
+// server can shut down without waiting for clients to leave.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.end(s, ErrClosed)
+		}
+	}
+}
+
+// end removes s and closes its channel. The caller holds h.mu.
+func (h *Hub) end(s *Subscription, err error) {
+	delete(h.subs[s.userID], s)
+	if len(h.subs[s.userID]) == 0 {
+		delete(h.subs, s.userID)
+	}
+	s.err = err
+	close(s.c)
+}
+
+// Cancel ends the subscription. It does nothing if it already ended.
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s.userID][s]; ok {
+		s.hub.end(s, nil)
+	}
+}
+
+// Err returns why the subscription ended: ErrClosed, ErrTooSlow, or nil if
+// it was cancelled. It's only meaningful once C is closed.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// EventsTestSuite provides a test suite for the event hub
+type EventsTestSuite struct {
+	suite.Suite
+	hub *Hub
+}
+
+// SetupTest runs before each test
+func (s *EventsTestSuite) SetupTest() {
+	s.hub = NewHub()
+}
+
+func (s *EventsTestSuite) TestPublish() {
+	mine, err := s.hub.Subscribe(1)
+	s.Require().NoError(err)
+	theirs, err := s.hub.Subscribe(2)
+	s.Require().NoError(err)
+
+	s.hub.Publish(Event{Type: ExpenseDeleted, ID: 7, UserID: 2})
+	s.Equal(Event{Type: ExpenseDeleted, ID: 7, UserID: 2}, <-mine.C, "everyone gets everyone's changes")
+	s.Equal(ExpenseDeleted, (<-theirs.C).Type)
+
+	mine.Cancel()
+	_, open := <-mine.C
+	s.False(open)
+	s.NoError(mine.Err())
+	s.Zero(s.hub.Subscribers(1))
+	mine.Cancel()
+
+	s.hub.Publish(Event{Type: ExpenseCreated, ID: 8})
+	s.Equal(int64(8), (<-theirs.C).ID)
+}
+
+func (s *EventsTestSuite) TestTooSlow() {
+	slow, err := s.hub.Subscribe(1)
+	s.Require().NoError(err)
+	for i := range bufferSize + 1 {
+		s.hub.Publish(Event{Type: ExpenseCreated, ID: int64(i)})
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	s.Equal(bufferSize, received, "the buffered events, then the end")
+	s.ErrorIs(slow.Err(), ErrTooSlow)
+	s.Zero(s.hub.Subscribers(1))
+}
+
+func (s *EventsTestSuite) TestLimit() {
+	for range MaxSubscriptions {
+		_, err := s.hub.Subscribe(1)
+		s.Require().NoError(err)
+	}
+	_, err := s.hub.Subscribe(1)
+	s.ErrorIs(err, ErrTooManySubscriptions)
+	_, err = s.hub.Subscribe(2)
+	s.NoError(err, "per user")
+}
+
+func (s *EventsTestSuite) TestClose() {
+	sub, err := s.hub.Subscribe(1)
+	s.Require().NoError(err)
+	s.hub.Close()
+	_, open := <-sub.C
+	s.False(open)
+	s.ErrorIs(sub.Err(), ErrClosed)
+	sub.Cancel()
+
+	_, err = s.hub.Subscribe(1)
+	s.ErrorIs(err, ErrClosed)
+	s.hub.Publish(Event{Type: ExpenseCreated})
+}
+
+// TestEventsTestSuite runs the event hub test suite
+func TestEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
@@ -0,0 +1,125 @@
+// Package exporter writes expenses as CSV or JSON, laid out so the importer
+// reads them back. Expenses are written one at a time, so exporting years of
+// them takes no more memory than exporting a few.
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"expense-tracker/internal/models"
+)
+
+// Supported file formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// DateLayout is how dates are written, which the importer reads back.
+const DateLayout = "2006-01-02T15:04:05"
+
+// Row is how an expense is exported.
+type Row struct {
+	Date        string  `json:"date"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	User        string  `json:"user,omitempty"` // Empty if the owner was deleted or anonymized
+}
+
+// NewRow returns the row of an expense owned by user.
+func NewRow(e models.Expense, user string) Row {
+	return Row{
+		Date:        e.Date.Format(DateLayout),
+		Amount:      e.Amount,
+		Description: e.Description,
+		Category:    e.Category,
+		User:        user,
+	}
+}
+
+// Writer writes rows in one of the formats. Rows are buffered; Flush sends
+// them on, and Close finishes the file.
+type Writer struct {
+	format string
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	count  int
+}
+
+// ContentType returns the media type of files in format.
+func ContentType(format string) string {
+	if format == FormatJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter returns a Writer of format to w.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	ew := &Writer{format: format}
+	switch format {
+	case FormatCSV:
+		ew.csv = csv.NewWriter(w)
+		if err := ew.csv.Write([]string{"date", "amount", "description", "category", "user"}); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		ew.buf = bufio.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown format %q, use csv or json", format)
+	}
+	return ew, nil
+}
+
+// Write adds a row.
+func (w *Writer) Write(r Row) error {
+	w.count++
+	if w.csv != nil {
+		return w.csv.Write([]string{r.Date, strconv.FormatFloat(r.Amount, 'f', 2, 64), r.Description, r.Category, r.User})
+	}
+	// An indented array, written an element at a time
+	data, err := json.MarshalIndent(r, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if w.count == 1 {
+		sep = "[\n  "
+	}
+	w.buf.WriteString(sep)
+	_, err = w.buf.Write(data)
+	return err
+}
+
+// Count returns how many rows were written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush sends the buffered rows on to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return w.buf.Flush()
+}
+
+// Close finishes the file and flushes it. It doesn't close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if w.buf != nil {
+		end := "\n]\n"
+		if w.count == 0 {
+			end = "[]\n"
+		}
+		w.buf.WriteString(end)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ExporterTestSuite provides a test suite for writing exports
+type ExporterTestSuite struct {
+	suite.Suite
+}
+
+var testRows = []Row{
+	NewRow(models.Expense{Amount: 4.5, Description: "Coffee, black", Category: "Eating Out", Date: time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)}, "alice"),
+	NewRow(models.Expense{Amount: 30, Description: "Supermarket", Category: "Groceries", Date: time.Date(2026, 3, 5, 18, 0, 0, 0, time.UTC)}, ""),
+}
+
+// export writes rows in format and returns the file.
+func (s *ExporterTestSuite) export(format string, rows []Row) string {
+	out := new(bytes.Buffer)
+	w, err := NewWriter(out, format)
+	s.Require().NoError(err)
+	for _, r := range rows {
+		s.Require().NoError(w.Write(r))
+	}
+	s.Require().NoError(w.Close())
+	s.Equal(len(rows), w.Count())
+	return out.String()
+}
+
+func (s *ExporterTestSuite) TestCSV() {
+	file := s.export(FormatCSV, testRows)
+	s.Equal("date,amount,description,category,user\n"+
+		"2026-03-02T08:30:00,4.50,\"Coffee, black\",Eating Out,alice\n"+
+		"2026-03-05T18:00:00,30.00,Supermarket,Groceries,\n", file)
+
+	// The importer reads it back
+	result, err := importer.ParseCSV(strings.NewReader(file), importer.DefaultMapping())
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 2)
+	s.Equal("Coffee, black", result.Expenses[0].Description)
+
+	s.Equal("date,amount,description,category,user\n", s.export(FormatCSV, nil))
+}
+
+func (s *ExporterTestSuite) TestJSON() {
+	// The same as encoding the whole array at once
+	want, err := json.MarshalIndent(testRows, "", "  ")
+	s.Require().NoError(err)
+	s.Equal(string(want)+"\n", s.export(FormatJSON, testRows))
+	s.Contains(s.export(FormatJSON, testRows), `"category": "Groceries"
+  }`, "no user key for deleted owners")
+
+	s.Equal("[]\n", s.export(FormatJSON, nil))
+}
+
+func (s *ExporterTestSuite) TestFlush() {
+	out := new(bytes.Buffer)
+	w, err := NewWriter(out, FormatJSON)
+	s.Require().NoError(err)
+	s.Require().NoError(w.Write(testRows[0]))
+	s.Empty(out.String(), "rows are buffered")
+	s.Require().NoError(w.Flush())
+	s.True(strings.HasPrefix(out.String(), "[\n  {\n"))
+}
+
+func (s *ExporterTestSuite) TestUnknownFormat() {
+	_, err := NewWriter(new(bytes.Buffer), "xml")
+	s.Error(err)
+}
+
+// TestExporterTestSuite runs the export writer test suite
+func TestExporterTestSuite(t *testing.T) {
+	suite.Run(t, new(ExporterTestSuite))
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Page sizes of the API's expense list: the default, and the most a client
+// can ask for.
+const (
+	apiPageSize    = 50
+	apiMaxPageSize = 500
+)
+
+// apiExpenseList is a page of the API's expense list.
+type apiExpenseList struct {
+	Expenses []models.Expense `json:"expenses"`
+	// Passed as cursor to get the next page; empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// apiCategoryTotal is a category's share of an expense summary.
+type apiCategoryTotal struct {
+	Category string  `json:"category"`
+	Count    int     `json:"count"`
+	Total    float64 `json:"total"`
+}
+
+// apiExpenseSummary is the API's summary of the expenses matching a filter.
+type apiExpenseSummary struct {
+	Count      int                `json:"count"`
+	Total      float64            `json:"total"`
+	Categories []apiCategoryTotal `json:"categories"` // Largest total first
+}
+
+// apiExpenseFilter reads the from, to, category, and q parameters that
+// narrow down the expenses of an API request, like on the expense list. It
+// returns what's wrong with them, if anything.
+func apiExpenseFilter(r *http.Request) (storage.ExpenseFilter, []fieldError) {
+	query := r.URL.Query()
+	var invalid []fieldError
+	for _, name := range []string{"from", "to"} {
+		if v := query.Get(name); v != "" {
+			if _, err := time.Parse(time.DateOnly, v); err != nil {
+				invalid = append(invalid, fieldError{Field: name, Message: "want a date as YYYY-MM-DD"})
+			}
+		}
+	}
+	if invalid != nil {
+		return storage.ExpenseFilter{}, invalid
+	}
+	from, to, err := parseDateRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		return storage.ExpenseFilter{}, []fieldError{{Field: "to", Message: "must not be before from"}}
+	}
+	return storage.ExpenseFilter{
+		From:     from,
+		To:       to,
+		Category: query.Get("category"),
+		Query:    strings.TrimSpace(query.Get("q")),
+		Viewer:   requestUserID(r),
+	}, nil
+}
+
+// apiLimit reads the limit parameter of an API request, 1 to
+// apiMaxPageSize, or def without one.
+func apiLimit(r *http.Request, def int) (int, []fieldError) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > apiMaxPageSize {
+		return 0, []fieldError{{Field: "limit", Message: "want 1 to " + strconv.Itoa(apiMaxPageSize)}}
+	}
+	return limit, nil
+}
+
+// APIListExpenses returns the expenses in the ledgers the user sees as JSON,
+// newest first, a page at a time. The from, to, category, and q parameters filter them like
+// the expense list, and limit sets the page size. Each page ends with a
+// cursor for the next, which stays valid while expenses are added.
+func (h *Handlers) APIListExpenses(w http.ResponseWriter, r *http.Request) {
+	filter, invalid := apiExpenseFilter(r)
+	limit, invalidLimit := apiLimit(r, apiPageSize)
+	invalid = append(invalid, invalidLimit...)
+	var after storage.ExpenseCursor
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		var err error
+		if after, err = decodeCursor(v); err != nil {
+			invalid = append(invalid, fieldError{Field: "cursor", Message: "want a next_cursor of an earlier page"})
+		}
+	}
+	if invalid != nil {
+		writeAPIError(w, validationFailed(invalid...))
+		return
+	}
+
+	expenses, next, err := h.store(r).ScrollExpenses(filter, after, limit)
+	if err != nil {
+		h.logger.Printf("APIListExpenses error: %v", err)
+		writeAPIError(w, err)
+		return
+	}
+	list := apiExpenseList{Expenses: expenses}
+	if list.Expenses == nil {
+		list.Expenses = []models.Expense{}
+	}
+	if next != (storage.ExpenseCursor{}) {
+		list.NextCursor = encodeCursor(next)
+	}
+	writeJSON(w, list)
+}
+
+// APIExpenseSummary returns how many of the expenses APIListExpenses lists
+// match its filter parameters and what they add up to, in all and per
+// category, without the expenses themselves. It's one aggregate query, cheap
+// enough for badges and widgets to poll.
+func (h *Handlers) APIExpenseSummary(w http.ResponseWriter, r *http.Request) {
+	filter, invalid := apiExpenseFilter(r)
+	if invalid != nil {
+		writeAPIError(w, validationFailed(invalid...))
+		return
+	}
+	totals, err := h.store(r).CategoryTotals(filter)
+	if err != nil {
+		h.logger.Printf("APIExpenseSummary error: %v", err)
+		writeAPIError(w, err)
+		return
+	}
+	summary := apiExpenseSummary{Categories: make([]apiCategoryTotal, 0, len(totals))}
+	for _, t := range totals {
+		summary.Count += t.Count
+		summary.Total += t.Total
+		summary.Categories = append(summary.Categories, apiCategoryTotal{Category: t.Category, Count: t.Count, Total: roundCents(t.Total)})
+	}
+	summary.Total = roundCents(summary.Total)
+	writeJSON(w, summary)
+}
+
+// roundCents rounds an amount to whole cents, dropping the float noise of
+// adding many up.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// encodeCursor turns c into the opaque string clients pass back.
+func encodeCursor(c storage.ExpenseCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.ID, 10) + " " + c.Date))
+}
+
+// decodeCursor reads a cursor made by encodeCursor.
+func decodeCursor(s string) (storage.ExpenseCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return storage.ExpenseCursor{}, err
+	}
+	id, date, ok := strings.Cut(string(data), " ")
+	if !ok || date == "" {
+		return storage.ExpenseCursor{}, errors.New("malformed cursor")
+	}
+	c := storage.ExpenseCursor{Date: date}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil || c.ID < 1 {
+		return storage.ExpenseCursor{}, errors.New("malformed cursor")
+	}
+	return c, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// APITestSuite provides a test suite for the JSON API
+type APITestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	day      time.Time
+}
+
+// SetupTest runs before each test
+func (s *APITestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithLogger(log.New(io.Discard, "", 0)))
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *APITestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// get requests target from handler as user 1.
+func (s *APITestSuite) get(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &models.User{ID: 1, Username: "alice"}))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+// list requests a page of the expense list.
+func (s *APITestSuite) list(target string) apiExpenseList {
+	w := s.get(s.handlers.APIListExpenses, target)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal("application/json", w.Header().Get("Content-Type"))
+	var list apiExpenseList
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &list))
+	return list
+}
+
+func descriptionsOf(expenses []models.Expense) []string {
+	var names []string
+	for _, e := range expenses {
+		names = append(names, e.Description)
+	}
+	return names
+}
+
+func (s *APITestSuite) TestListExpenses_Pages() {
+	for i, description := range []string{"A", "B", "C", "D", "E"} {
+		s.Require().NoError(s.db.CreateExpense(float64(i+1), description, "Other", s.day.Add(time.Duration(i)*time.Hour), int64(i%2+1)))
+	}
+
+	page := s.list("/api/v1/expenses?limit=2")
+	s.Equal([]string{"E", "D"}, descriptionsOf(page.Expenses), "everyone's, newest first")
+	s.NotEmpty(page.NextCursor)
+
+	// Expenses added meanwhile don't shift the pages
+	s.Require().NoError(s.db.CreateExpense(9, "New", "Other", s.day.AddDate(0, 0, 1), 1))
+	page = s.list("/api/v1/expenses?limit=2&cursor=" + page.NextCursor)
+	s.Equal([]string{"C", "B"}, descriptionsOf(page.Expenses))
+	page = s.list("/api/v1/expenses?limit=2&cursor=" + page.NextCursor)
+	s.Equal([]string{"A"}, descriptionsOf(page.Expenses))
+	s.Empty(page.NextCursor, "the last page")
+}
+
+func (s *APITestSuite) TestListExpenses_Filter() {
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", s.day, 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Supermarket", "Groceries", s.day, 1))
+	s.Require().NoError(s.db.CreateExpense(12, "Coffee beans", "Groceries", s.day.AddDate(0, -1, 0), 1))
+
+	page := s.list("/api/v1/expenses?category=groceries&from=2026-03-01&to=2026-03-31")
+	s.Equal([]string{"Supermarket"}, descriptionsOf(page.Expenses))
+	page = s.list("/api/v1/expenses?q=coffee")
+	s.Equal([]string{"Coffee", "Coffee beans"}, descriptionsOf(page.Expenses))
+
+	w := s.get(s.handlers.APIListExpenses, "/api/v1/expenses?q=nothing")
+	s.JSONEq(`{"expenses": []}`, w.Body.String())
+}
+
+func (s *APITestSuite) TestListExpenses_Invalid() {
+	for _, target := range []string{
+		"/api/v1/expenses?limit=0",
+		"/api/v1/expenses?limit=501",
+		"/api/v1/expenses?limit=ten",
+		"/api/v1/expenses?from=March",
+		"/api/v1/expenses?cursor=nonsense",
+		"/api/v1/expenses?cursor=" + encodeCursor(storage.ExpenseCursor{}),
+	} {
+		w := s.get(s.handlers.APIListExpenses, target)
+		s.Equal(http.StatusBadRequest, w.Code, target)
+		s.Contains(w.Body.String(), `"code":"validation_failed"`, target)
+	}
+}
+
+func (s *APITestSuite) TestErrors() {
+	w := s.get(s.handlers.APIListExpenses, "/api/v1/expenses?from=March&limit=0")
+	s.JSONEq(`{"error": {
+		"code": "validation_failed",
+		"message": "invalid from, limit",
+		"details": [
+			{"field": "from", "message": "want a date as YYYY-MM-DD"},
+			{"field": "limit", "message": "want 1 to 500"}
+		]
+	}}`, w.Body.String(), "every invalid field")
+
+	w = s.get(s.handlers.APIExpenseSummary, "/api/v1/expenses/summary?from=2026-03-02&to=2026-03-01")
+	s.JSONEq(`{"error": {
+		"code": "validation_failed",
+		"message": "invalid to: must not be before from",
+		"details": [{"field": "to", "message": "must not be before from"}]
+	}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	writeAPIError(w, errors.New("disk I/O error"))
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.JSONEq(`{"error": {"code": "internal_error", "message": "internal server error"}}`, w.Body.String(), "without the cause")
+
+	w = httptest.NewRecorder()
+	writeAPIError(w, fmt.Errorf("upload: %w", notFound("expense not found")))
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"code": "not_found", "message": "expense not found"}}`, w.Body.String())
+}
+
+func (s *APITestSuite) TestExpenseSummary() {
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", s.day, 1))
+	s.Require().NoError(s.db.CreateExpense(0.1, "Mint", "Groceries", s.day, 2))
+	s.Require().NoError(s.db.CreateExpense(0.2, "Gum", "Groceries", s.day.Add(time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(900, "Rent", "Housing", s.day.AddDate(0, -1, 0), 1))
+
+	w := s.get(s.handlers.APIExpenseSummary, "/api/v1/expenses/summary?from=2026-03-01&to=2026-03-31")
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{
+		"count": 3,
+		"total": 4.8,
+		"categories": [
+			{"category": "Eating Out", "count": 1, "total": 4.5},
+			{"category": "Groceries", "count": 2, "total": 0.3}
+		]
+	}`, w.Body.String(), "everyone's, in whole cents")
+
+	w = s.get(s.handlers.APIExpenseSummary, "/api/v1/expenses/summary?q=nothing")
+	s.JSONEq(`{"count": 0, "total": 0, "categories": []}`, w.Body.String())
+
+	w = s.get(s.handlers.APIExpenseSummary, "/api/v1/expenses/summary?to=March")
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *APITestSuite) TestCursorRoundTrip() {
+	c := storage.ExpenseCursor{Date: "2026-03-02 12:00:00 +0000 UTC", ID: 42}
+	decoded, err := decodeCursor(encodeCursor(c))
+	s.Require().NoError(err)
+	s.Equal(c, decoded)
+}
+
+func (s *APITestSuite) TestAuthMiddleware() {
+	handler := s.handlers.APIAuthMiddleware(http.HandlerFunc(s.handlers.APIListExpenses))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/expenses", http.NoBody))
+	s.Equal(http.StatusUnauthorized, w.Code, "not redirected to the login page")
+	s.JSONEq(`{"error": {"code": "unauthorized", "message": "not signed in"}}`, w.Body.String())
+
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateSession("token", user.ID, time.Hour, ""))
+	req := httptest.NewRequest("GET", "/api/v1/expenses", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: s.handlers.cookieName, Value: "token"})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+// TestAPITestSuite runs the JSON API test suite
+func TestAPITestSuite(t *testing.T) {
+	suite.Run(t, new(APITestSuite))
+}
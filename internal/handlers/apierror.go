@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Codes of API errors, for clients to branch on. Messages are for people
+// and may change.
+const (
+	codeValidationFailed = "validation_failed" // The request is malformed or has invalid values
+	codeNotFound         = "not_found"
+	codeUnauthorized     = "unauthorized" // Not signed in
+	codeDuplicate        = "duplicate"    // An expense with the same date, amount, and description exists
+	codeInternal         = "internal_error"
+)
+
+// apiError is an error an API request or one of its parts ends with. It's
+// sent as {"error": {"code": …, "message": …, "details": […]}}.
+type apiError struct {
+	status  int
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []fieldError `json:"details,omitempty"` // What's wrong with which field, for validation_failed
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+// fieldError is what's wrong with one field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	errNotSignedIn = &apiError{status: http.StatusUnauthorized, Code: codeUnauthorized, Message: "not signed in"}
+	errInternal    = &apiError{status: http.StatusInternalServerError, Code: codeInternal, Message: "internal server error"}
+	errInvalidJSON = &apiError{status: http.StatusBadRequest, Code: codeValidationFailed, Message: "invalid JSON"}
+	errDuplicate   = &apiError{status: http.StatusConflict, Code: codeDuplicate, Message: "an expense with the same date, amount, and description exists"}
+)
+
+// notFound returns a not_found error saying what wasn't found.
+func notFound(message string) *apiError {
+	return &apiError{status: http.StatusNotFound, Code: codeNotFound, Message: message}
+}
+
+// validationFailed returns a validation_failed error for the invalid fields.
+func validationFailed(details ...fieldError) *apiError {
+	message := "invalid " + details[0].Field + ": " + details[0].Message
+	if len(details) > 1 {
+		fields := make([]string, len(details))
+		for i, d := range details {
+			fields[i] = d.Field
+		}
+		message = "invalid " + strings.Join(fields, ", ")
+	}
+	return &apiError{status: http.StatusBadRequest, Code: codeValidationFailed, Message: message, Details: details}
+}
+
+// writeAPIError answers an API request with err in the error envelope.
+// Errors other than an *apiError are internal errors, which the caller
+// logs; their message isn't sent.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = errInternal
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	_ = json.NewEncoder(w).Encode(map[string]*apiError{"error": apiErr})
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"expense-tracker/internal/auth"
-	"log"
+	"expense-tracker/internal/models"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -13,47 +16,74 @@ import (
 // It also implements rolling sessions: if a session is past the halfway point
 // of its lifetime, it automatically renews the session.
 func (h *Handlers) AuthMiddleware(next http.Handler) http.Handler {
+	return h.authenticate(next, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+}
+
+// APIAuthMiddleware is AuthMiddleware for the JSON API, which answers
+// requests without a valid session with 401 rather than the login page.
+func (h *Handlers) APIAuthMiddleware(next http.Handler) http.Handler {
+	return h.authenticate(next, func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, errNotSignedIn)
+	})
+}
+
+// authenticate passes requests with a valid session on to next, with the
+// user in the context, and the others to unauthorized. Without a session,
+// guests get through to the routes they may use, see serveGuest.
+func (h *Handlers) authenticate(next http.Handler, unauthorized http.HandlerFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie(SessionCookieName)
+		cookie, err := r.Cookie(h.cookieName)
 		if err != nil || cookie.Value == "" {
-			http.Redirect(w, r, "/login", http.StatusFound)
+			if !h.serveGuest(w, r, next) {
+				unauthorized(w, r)
+			}
 			return
 		}
 
-		sessionInfo, err := h.db.ValidateSessionWithInfo(cookie.Value)
+		db := h.store(r)
+		sessionInfo, err := db.ValidateSessionWithInfo(cookie.Value)
 		if err != nil {
 			// Invalid or expired session, clear the cookie
-			h.clearSessionCookie(w)
-			http.Redirect(w, r, "/login", http.StatusFound)
+			h.clearSessionCookie(w, r)
+			unauthorized(w, r)
+			return
+		}
+
+		// A bound session presented by another client may be a stolen
+		// cookie, so end it for everyone
+		if h.binding != BindNone && sessionInfo.Fingerprint != "" && sessionInfo.Fingerprint != h.clientFingerprint(r) {
+			h.logger.Printf("Rejected session of user %d from a different client (%s)", sessionInfo.User.ID, clientIP(r))
+			if err := db.DeleteSession(cookie.Value); err != nil {
+				h.logger.Printf("Failed to delete session: %v", err)
+			}
+			h.clearSessionCookie(w, r)
+			unauthorized(w, r)
 			return
 		}
 
 		// Rolling session: renew if past halfway point
 		// This keeps active users logged in while still expiring inactive sessions
-		now := time.Now()
+		now := h.now()
 		timeUntilExpiry := sessionInfo.ExpiresAt.Sub(now)
-		halfSessionDuration := SessionDuration / 2
+		halfSessionDuration := sessionInfo.Duration / 2
 
 		if timeUntilExpiry < halfSessionDuration {
 			// Session is in the second half of its lifetime, renew it
-			newExpiresAt := now.Add(SessionDuration)
-			if err := h.db.RenewSession(cookie.Value, newExpiresAt); err == nil {
+			newExpiresAt := now.Add(sessionInfo.Duration)
+			if err := db.RenewSession(cookie.Value, newExpiresAt); err == nil {
 				// Update the cookie expiration too
-				http.SetCookie(w, &http.Cookie{
-					Name:     SessionCookieName,
-					Value:    cookie.Value,
-					Path:     "/",
-					MaxAge:   int(SessionDuration.Seconds()),
-					HttpOnly: true,
-					Secure:   h.secureCookie,
-					SameSite: http.SameSiteLaxMode,
-				})
+				h.setSessionCookie(w, r, cookie.Value, sessionInfo.Duration)
 			}
 			// If renewal fails, just continue with the current session
 		}
 
-		// Add user to context
+		// Add user to context, along with their preferences and the
+		// categories they use most
 		ctx := context.WithValue(r.Context(), UserContextKey, sessionInfo.User)
+		ctx = h.withPreferences(ctx, db, sessionInfo.User.ID)
+		ctx = h.withCategoryUsage(ctx, db, sessionInfo.User.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -61,19 +91,27 @@ func (h *Handlers) AuthMiddleware(next http.Handler) http.Handler {
 // LoginForm renders the login page.
 func (h *Handlers) LoginForm(w http.ResponseWriter, r *http.Request) {
 	// If already logged in, redirect to expenses
-	if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
-		if _, err := h.db.ValidateSession(cookie.Value); err == nil {
+	if cookie, err := r.Cookie(h.cookieName); err == nil && cookie.Value != "" {
+		if _, err := h.store(r).ValidateSession(cookie.Value); err == nil {
 			http.Redirect(w, r, "/expenses", http.StatusFound)
 			return
 		}
 	}
-	h.render(w, r, "login.html", LoginViewModel{})
+	viewModel := LoginViewModel{AllowSignup: h.allowSignup}
+	if r.URL.Query().Has("deleted") {
+		viewModel.Notice = fmt.Sprintf("Your account will be deleted in %d days. Sign in before then to keep it.", int(accountDeletionGrace.Hours()/24))
+	}
+	if h.demo != nil {
+		viewModel.Notice = fmt.Sprintf("This is a demo. Sign in as %s with the password %s. Everything you enter is reset regularly.", h.demo.username, h.demo.password)
+		viewModel.Username = h.demo.username
+	}
+	h.render(w, r, "login.html", viewModel)
 }
 
 // Login handles the login form submission.
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		h.render(w, r, "login.html", LoginViewModel{Error: "Invalid form submission"})
+		h.render(w, r, "login.html", LoginViewModel{Error: "Invalid form submission", AllowSignup: h.allowSignup})
 		return
 	}
 
@@ -81,65 +119,335 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 
 	if username == "" || password == "" {
-		h.render(w, r, "login.html", LoginViewModel{Error: "Username and password are required"})
+		h.render(w, r, "login.html", LoginViewModel{Error: "Username and password are required", AllowSignup: h.allowSignup})
+		return
+	}
+
+	db := h.store(r)
+	user, err := db.GetUserByUsername(username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("Failed to load user: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again.", AllowSignup: h.allowSignup})
+		return
+	}
+	provisioned := false // Password already checked by the directory
+	if err != nil && h.ldap != nil {
+		user, err = h.provisionLDAPUser(db, username, password)
+		provisioned = err == nil
+		if errors.Is(err, errDirectoryUnavailable) {
+			h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again.", AllowSignup: h.allowSignup})
+			return
+		}
+	}
+	if err != nil {
+		h.recordLogin(r, nil, username, false)
+		h.render(w, r, "login.html", LoginViewModel{Error: "Invalid username or password", AllowSignup: h.allowSignup})
+		return
+	}
+
+	// Refuse locked accounts without checking the password, so guesses made
+	// during the lockout can't succeed
+	lockout, err := db.GetLoginLockout(user.ID)
+	if err != nil {
+		h.logger.Printf("Failed to load login lockout: %v", err)
+		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again.", AllowSignup: h.allowSignup})
+		return
+	}
+	if wait := lockout.LockedUntil.Sub(h.now()); wait > 0 {
+		h.recordLogin(r, &user.ID, username, false)
+		h.render(w, r, "login.html", LoginViewModel{Error: lockedMessage(wait), AllowSignup: h.allowSignup})
+		return
+	}
+
+	valid := provisioned
+	if !valid {
+		valid, err = h.checkPassword(user, password)
+	}
+	if errors.Is(err, errLocalAccount) {
+		h.logger.Printf("Refused directory login to local account %s", username)
+		h.recordLogin(r, &user.ID, username, false)
+		h.render(w, r, "login.html", LoginViewModel{Error: localAccountMessage, AllowSignup: h.allowSignup})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Failed to check password: %v", err)
+		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again.", AllowSignup: h.allowSignup})
+		return
+	}
+	if !valid {
+		h.recordFailedLogin(db, user.ID)
+		h.recordLogin(r, &user.ID, username, false)
+		h.render(w, r, "login.html", LoginViewModel{Error: "Invalid username or password", AllowSignup: h.allowSignup})
+		return
+	}
+	if lockout.FailedLogins > 0 {
+		if err := db.ResetFailedLogins(user.ID); err != nil {
+			h.logger.Printf("Failed to reset failed logins: %v", err)
+		}
+	}
+	// Only tell someone who knows the password that the account is disabled
+	if user.Disabled {
+		h.recordLogin(r, &user.ID, username, false)
+		h.render(w, r, "login.html", LoginViewModel{Error: disabledMessage, AllowSignup: h.allowSignup})
 		return
 	}
 
-	user, err := h.db.GetUserByUsername(username)
-	if err != nil || !auth.CheckPassword(password, user.PasswordHash) {
-		h.render(w, r, "login.html", LoginViewModel{Error: "Invalid username or password"})
+	if err := h.startSession(w, r, user.ID, r.FormValue("remember") == "on"); err != nil {
+		h.logger.Printf("Failed to start session: %v", err)
+		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again.", AllowSignup: h.allowSignup})
 		return
 	}
+	h.recordLogin(r, &user.ID, username, true)
+
+	http.Redirect(w, r, "/expenses", http.StatusFound)
+}
+
+// disabledMessage is shown when a disabled account tries to log in.
+const disabledMessage = "This account has been disabled. Contact your administrator."
+
+// localAccountMessage is shown when a directory user logs in with the name
+// of a local account.
+const localAccountMessage = "This username belongs to a local account, which can't sign in through the directory. Sign in with a passkey or ask your administrator."
+
+// errDirectoryUnavailable is returned when the LDAP server can't be asked.
+var errDirectoryUnavailable = errors.New("directory unavailable")
+
+// errLocalAccount is returned when the directory accepts the password of a
+// user whose account has a local password.
+var errLocalAccount = errors.New("local account")
+
+// checkPassword checks a user's password against the directory when LDAP is
+// configured, or else the local password hash. An error means the password
+// could not be checked. Accounts with a local password, such as the
+// bootstrap admin, were not created for a directory user, so a directory
+// user of the same name doesn't get into them: that returns errLocalAccount.
+func (h *Handlers) checkPassword(user *models.User, password string) (bool, error) {
+	if h.ldap == nil {
+		return auth.CheckPassword(password, user.PasswordHash), nil
+	}
+	err := h.ldap.Authenticate(user.Username, password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return false, nil
+	}
+	if err == nil && user.PasswordHash != "" {
+		return false, errLocalAccount
+	}
+	return err == nil, err
+}
+
+// provisionLDAPUser creates the local account for a directory user logging in
+// for the first time. It has no local password, so it can only log in through
+// the directory.
+func (h *Handlers) provisionLDAPUser(db Store, username, password string) (*models.User, error) {
+	if err := h.ldap.Authenticate(username, password); err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, err
+		}
+		h.logger.Printf("LDAP login failed: %v", err)
+		return nil, errDirectoryUnavailable
+	}
+	user, err := db.CreateUser(username, "")
+	if err != nil {
+		h.logger.Printf("Failed to create LDAP user: %v", err)
+		return nil, errDirectoryUnavailable
+	}
+	h.logger.Printf("Created local account for LDAP user %s", username)
+	return user, nil
+}
+
+// recordLogin adds a login attempt to the security audit log.
+func (h *Handlers) recordLogin(r *http.Request, userID *int64, username string, success bool) {
+	if err := h.store(r).RecordLogin(userID, username, clientIP(r), truncate(r.UserAgent(), maxUserAgentLength), success); err != nil {
+		h.logger.Printf("Failed to record login: %v", err)
+	}
+}
 
+// recordFailedLogin counts a failed login and locks the account once there
+// have been too many in a row.
+func (h *Handlers) recordFailedLogin(db Store, userID int64) {
+	failures, err := db.RecordFailedLogin(userID)
+	if err != nil {
+		h.logger.Printf("Failed to record failed login: %v", err)
+		return
+	}
+	if d := auth.LockoutDuration(failures); d > 0 {
+		h.logger.Printf("Locking user %d for %s after %d failed logins", userID, d, failures)
+		if err := db.LockUser(userID, h.now().Add(d)); err != nil {
+			h.logger.Printf("Failed to lock user: %v", err)
+		}
+	}
+}
+
+// lockedMessage tells a locked out user how long to wait, rounded up to the
+// minute.
+func lockedMessage(wait time.Duration) string {
+	minutes := int((wait + time.Minute - 1) / time.Minute)
+	if minutes == 1 {
+		return "Too many failed attempts. Try again in 1 minute."
+	}
+	return fmt.Sprintf("Too many failed attempts. Try again in %d minutes.", minutes)
+}
+
+// clientFingerprint identifies the client that sent a request, to the
+// precision the session binding mode asks for. It is empty when sessions are
+// not bound.
+func (h *Handlers) clientFingerprint(r *http.Request) string {
+	switch h.binding {
+	case BindUserAgent:
+		return auth.ClientFingerprint(r.UserAgent(), "")
+	case BindUserAgentAndNetwork:
+		return auth.ClientFingerprint(r.UserAgent(), clientIP(r))
+	default:
+		return ""
+	}
+}
+
+// startSession creates a session for the user and sets the session cookie.
+// Remembered sessions last the session duration and survive closing the
+// browser; others last the short session duration.
+func (h *Handlers) startSession(w http.ResponseWriter, r *http.Request, userID int64, remember bool) error {
 	// Generate session token
 	token, err := auth.GenerateSessionToken()
 	if err != nil {
-		log.Printf("Failed to generate session token: %v", err)
-		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again."})
-		return
+		return err
+	}
+
+	duration := h.shortSessionDuration
+	if remember {
+		duration = h.sessionDuration
 	}
 
 	// Create session in database
-	expiresAt := time.Now().Add(SessionDuration)
-	if err := h.db.CreateSession(token, user.ID, expiresAt); err != nil {
-		log.Printf("Failed to create session: %v", err)
-		h.render(w, r, "login.html", LoginViewModel{Error: "An error occurred. Please try again."})
-		return
+	if err := h.store(r).CreateSession(token, userID, duration, h.clientFingerprint(r)); err != nil {
+		return err
+	}
+
+	h.setSessionCookie(w, r, token, duration)
+
+	// Signing in within the grace period keeps an account scheduled for
+	// deletion
+	if cancelled, err := h.store(r).CancelAccountDeletion(userID); err != nil {
+		h.logger.Printf("Failed to cancel account deletion: %v", err)
+	} else if cancelled {
+		h.logger.Printf("User %d signed in and cancelled their account deletion", userID)
 	}
+	return nil
+}
+
+// setSessionCookie sets the session cookie for a session lasting duration.
+// Short sessions get a cookie without Max-Age, which the browser drops when it
+// closes.
+func (h *Handlers) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, duration time.Duration) {
+	cookie := h.sessionCookie(r, token)
+	if duration > h.shortSessionDuration {
+		cookie.MaxAge = int(duration.Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    token,
+// sessionCookie returns the session cookie with the configured attributes,
+// Secure when the request came over HTTPS. Browsers reject __Host- and
+// __Secure- cookies without Secure, so those names always get it.
+func (h *Handlers) sessionCookie(r *http.Request, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     h.cookieName,
+		Value:    value,
 		Path:     "/",
-		MaxAge:   int(SessionDuration.Seconds()),
 		HttpOnly: true,
-		Secure:   h.secureCookie,
-		SameSite: http.SameSiteLaxMode,
-	})
+		Secure:   h.secureCookie || isHTTPS(r) || strings.HasPrefix(h.cookieName, "__Host-") || strings.HasPrefix(h.cookieName, "__Secure-"),
+		SameSite: h.sameSite,
+	}
+}
+
+// RegisterForm renders the self-service registration page. It is only
+// available when signup is enabled.
+func (h *Handlers) RegisterForm(w http.ResponseWriter, r *http.Request) {
+	if !h.allowSignup {
+		http.NotFound(w, r)
+		return
+	}
+	h.render(w, r, "register.html", RegisterViewModel{})
+}
 
+// Register handles the registration form submission, creating the account
+// and logging the new user in.
+func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
+	if !h.allowSignup {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, "register.html", RegisterViewModel{Error: "Invalid form submission"})
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	viewModel := RegisterViewModel{Username: username}
+
+	if viewModel.Error = h.checkNewAccount(h.store(r), username, password, r.FormValue("confirm")); viewModel.Error != "" {
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.logger.Printf("Failed to hash password: %v", err)
+		viewModel.Error = "An error occurred. Please try again."
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+	user, err := h.store(r).CreateUser(username, hash)
+	if err != nil {
+		// Most likely a concurrent signup took the username
+		h.logger.Printf("Failed to create user: %v", err)
+		viewModel.Error = "Could not create the account. Please try again."
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+	h.logger.Printf("Registered user: %s", user.Username)
+
+	if err := h.startSession(w, r, user.ID, true); err != nil {
+		h.logger.Printf("Failed to start session: %v", err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
 	http.Redirect(w, r, "/expenses", http.StatusFound)
 }
 
+// checkNewAccount validates the registration form fields, returning a message
+// for the user or "" if the account can be created.
+func (h *Handlers) checkNewAccount(db Store, username, password, confirm string) string {
+	if err := auth.ValidateUsername(username); err != nil {
+		return capitalize(err.Error())
+	}
+	if err := auth.ValidatePassword(password); err != nil {
+		return capitalize(err.Error())
+	}
+	if password != confirm {
+		return "Passwords do not match"
+	}
+	if _, err := db.GetUserByUsername(username); err == nil {
+		return "Username is already taken"
+	}
+	return ""
+}
+
 // Logout handles user logout.
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
-	if cookie, err := r.Cookie(SessionCookieName); err == nil {
-		if err := h.db.DeleteSession(cookie.Value); err != nil {
-			log.Printf("Failed to delete session: %v", err)
+	if cookie, err := r.Cookie(h.cookieName); err == nil {
+		if err := h.store(r).DeleteSession(cookie.Value); err != nil {
+			h.logger.Printf("Failed to delete session: %v", err)
 		}
 	}
-	h.clearSessionCookie(w)
+	h.clearSessionCookie(w, r)
+	h.clearGuestCookie(w, r)
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
-func (h *Handlers) clearSessionCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   h.secureCookie,
-		SameSite: http.SameSiteLaxMode,
-	})
+func (h *Handlers) clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	cookie := h.sessionCookie(r, "")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
 }
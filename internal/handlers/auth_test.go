@@ -0,0 +1,912 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/mail"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"expense-tracker/internal/storage/memstore"
+	"html"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AuthHandlerTestSuite provides a test suite for authentication handler tests
+type AuthHandlerTestSuite struct {
+	suite.Suite
+	db        *storage.DB
+	templates fs.FS
+}
+
+// SetupTest runs before each test
+func (s *AuthHandlerTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+
+	templateDir := "../../web/templates"
+	if _, err := os.Stat(templateDir); os.IsNotExist(err) {
+		s.T().Skip("Template directory not found, skipping handler integration test")
+	}
+	s.templates = os.DirFS(templateDir)
+}
+
+// TearDownTest runs after each test
+func (s *AuthHandlerTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *AuthHandlerTestSuite) postRegister(h *Handlers, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/register", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.Register(w, req)
+	return w
+}
+
+func (s *AuthHandlerTestSuite) TestRegister_Disabled() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	w := httptest.NewRecorder()
+	h.RegisterForm(w, httptest.NewRequest("GET", "/register", http.NoBody))
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.postRegister(h, url.Values{"username": {"alice"}, "password": {"secret123"}, "confirm": {"secret123"}})
+	s.Equal(http.StatusNotFound, w.Code)
+
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Zero(count, "no account should be created while signup is disabled")
+}
+
+func (s *AuthHandlerTestSuite) TestRegister_CreatesUserAndLogsIn() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithSignup(true))
+
+	w := s.postRegister(h, url.Values{"username": {"alice"}, "password": {"secret123"}, "confirm": {"secret123"}})
+
+	s.Equal(http.StatusFound, w.Code)
+	s.Equal("/expenses", w.Header().Get("Location"))
+
+	user, err := s.db.GetUserByUsername("alice")
+	s.Require().NoError(err)
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			session = c
+		}
+	}
+	s.Require().NotNil(session, "registration should set a session cookie")
+	sessionUser, err := s.db.ValidateSession(session.Value)
+	s.Require().NoError(err)
+	s.Equal(user.ID, sessionUser.ID)
+}
+
+func (s *AuthHandlerTestSuite) TestRegister_Validation() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithSignup(true))
+	_, err := s.db.CreateUser("taken", "hash")
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name      string
+		form      url.Values
+		wantError string
+	}{
+		{"short username", url.Values{"username": {"al"}, "password": {"secret123"}, "confirm": {"secret123"}}, "Username must be"},
+		{"invalid characters", url.Values{"username": {"al ice"}, "password": {"secret123"}, "confirm": {"secret123"}}, "Username must be"},
+		{"short password", url.Values{"username": {"alice"}, "password": {"short"}, "confirm": {"short"}}, "Password must be at least 8 characters"},
+		{"mismatched confirmation", url.Values{"username": {"alice"}, "password": {"secret123"}, "confirm": {"secret124"}}, "Passwords do not match"},
+		{"taken username", url.Values{"username": {"taken"}, "password": {"secret123"}, "confirm": {"secret123"}}, "Username is already taken"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := s.postRegister(h, tt.form)
+
+			s.Equal(http.StatusOK, w.Code)
+			s.Contains(w.Body.String(), tt.wantError)
+			s.Contains(w.Body.String(), `value="`+tt.form.Get("username")+`"`, "the username should be kept")
+			s.Empty(w.Result().Cookies())
+		})
+	}
+
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+func (s *AuthHandlerTestSuite) TestLoginForm_SignupLink() {
+	w := httptest.NewRecorder()
+	NewHandlers(s.db, WithTemplateFS(s.templates)).LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.NotContains(w.Body.String(), `href="/register"`)
+
+	w = httptest.NewRecorder()
+	NewHandlers(s.db, WithTemplateFS(s.templates), WithSignup(true)).LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.Contains(w.Body.String(), `href="/register"`)
+}
+
+func (s *AuthHandlerTestSuite) TestInvites_AdminOnly() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	admin, err := s.db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+	member, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+
+	invites := h.AdminMiddleware(http.HandlerFunc(h.Invites))
+	for _, tt := range []struct {
+		user *models.User
+		want int
+	}{{admin, http.StatusOK}, {member, http.StatusForbidden}} {
+		req := httptest.NewRequest("GET", "/settings/invites", http.NoBody)
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, tt.user))
+		w := httptest.NewRecorder()
+		invites.ServeHTTP(w, req)
+		s.Equal(tt.want, w.Code, tt.user.Username)
+	}
+}
+
+func (s *AuthHandlerTestSuite) TestInvites_CreateAndAccept() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	admin, err := s.db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+
+	req := httptest.NewRequest("POST", "/settings/invites", strings.NewReader("days=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, admin))
+	w := httptest.NewRecorder()
+	h.CreateInvite(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+	match := regexp.MustCompile(`value="http://example.com/invite/([^"]+)"`).FindStringSubmatch(w.Body.String())
+	s.Require().NotNil(match, "the new link should be shown")
+	token := html.UnescapeString(match[1])
+
+	invite := func(method string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/invite/"+token, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("token", token)
+		w := httptest.NewRecorder()
+		if method == "GET" {
+			h.InviteForm(w, req)
+		} else {
+			h.AcceptInvite(w, req)
+		}
+		return w
+	}
+
+	w = invite("GET", nil)
+	s.Contains(w.Body.String(), "invited")
+	s.Contains(w.Body.String(), `action="/invite/`)
+
+	w = invite("POST", url.Values{"username": {"alice"}, "password": {"secret123"}, "confirm": {"wrong"}})
+	s.Contains(w.Body.String(), "Passwords do not match")
+
+	w = invite("POST", url.Values{"username": {"alice"}, "password": {"secret123"}, "confirm": {"secret123"}})
+	s.Require().Equal(http.StatusFound, w.Code)
+	s.Equal("/expenses", w.Header().Get("Location"))
+	alice, err := s.db.GetUserByUsername("alice")
+	s.Require().NoError(err)
+	s.False(alice.IsAdmin())
+
+	w = invite("GET", nil)
+	s.Contains(w.Body.String(), "invalid, has expired, or was already used")
+	s.NotContains(w.Body.String(), `action="/invite/`)
+	w = invite("POST", url.Values{"username": {"mallory"}, "password": {"secret123"}, "confirm": {"secret123"}})
+	s.Contains(w.Body.String(), "invalid, has expired, or was already used")
+	_, err = s.db.GetUserByUsername("mallory")
+	s.Error(err)
+}
+
+func (s *AuthHandlerTestSuite) TestDeleteAccount() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateSession("other-device", user.ID, time.Hour, ""))
+
+	deleteAccount := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/settings/account/delete", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+		w := httptest.NewRecorder()
+		h.DeleteAccount(w, req)
+		return w
+	}
+
+	w := deleteAccount("wrong")
+	s.Contains(w.Body.String(), "Incorrect password")
+	_, err = s.db.ValidateSession("other-device")
+	s.Require().NoError(err)
+
+	w = deleteAccount("secret123")
+	s.Equal("/login?deleted=1", w.Header().Get("HX-Redirect"))
+	_, err = s.db.ValidateSession("other-device")
+	s.Error(err, "every session is ended")
+
+	w = httptest.NewRecorder()
+	h.LoginForm(w, httptest.NewRequest("GET", "/login?deleted=1", http.NoBody))
+	s.Contains(w.Body.String(), "Your account will be deleted in 14 days")
+
+	// Signing in again keeps the account
+	s.Require().Equal(http.StatusFound, s.postLogin(h, "alice", "secret123").Code)
+	user, err = s.db.GetUserByID(user.ID)
+	s.Require().NoError(err)
+	s.Nil(user.DeleteAfter)
+}
+
+func (s *AuthHandlerTestSuite) postLogin(h *Handlers, username, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.Login(w, req)
+	return w
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_LockoutAfterFailures() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	for i := 0; i < auth.MaxFailedLogins; i++ {
+		w := s.postLogin(h, "alice", "wrong")
+		s.Contains(w.Body.String(), "Invalid username or password")
+	}
+
+	// The right password is refused while the account is locked
+	w := s.postLogin(h, "alice", "secret123")
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Too many failed attempts. Try again in 1 minute.")
+	s.Empty(w.Result().Cookies())
+
+	// Once the lock expires, a successful login clears the failure count
+	s.Require().NoError(s.db.LockUser(user.ID, time.Now().Add(-time.Second)))
+	w = s.postLogin(h, "alice", "secret123")
+	s.Equal(http.StatusFound, w.Code)
+
+	lockout, err := s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Zero(lockout.FailedLogins)
+	s.True(lockout.LockedUntil.IsZero())
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_LockoutUsesClock() {
+	now := time.Now()
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithClock(func() time.Time { return now }))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	for i := 0; i < auth.MaxFailedLogins; i++ {
+		s.postLogin(h, "alice", "wrong")
+	}
+	s.Contains(s.postLogin(h, "alice", "secret123").Body.String(), "Too many failed attempts")
+
+	// The lock expires by the handlers' clock, not the wall clock
+	now = now.Add(2 * time.Minute)
+	s.Equal(http.StatusFound, s.postLogin(h, "alice", "secret123").Code)
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_Disabled() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.DisableUser(user.ID))
+
+	// A wrong password doesn't reveal that the account is disabled
+	w := s.postLogin(h, "alice", "wrong")
+	s.Contains(w.Body.String(), "Invalid username or password")
+
+	w = s.postLogin(h, "alice", "secret123")
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "This account has been disabled")
+	s.Empty(w.Result().Cookies())
+
+	_, err = s.db.EnableUser(user.ID)
+	s.Require().NoError(err)
+	w = s.postLogin(h, "alice", "secret123")
+	s.Equal(http.StatusFound, w.Code)
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_LockoutBackoff() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	for i := 0; i < auth.MaxFailedLogins; i++ {
+		s.postLogin(h, "alice", "wrong")
+	}
+
+	// Each failure after the lock expires doubles the next lockout
+	s.Require().NoError(s.db.LockUser(user.ID, time.Now().Add(-time.Second)))
+	s.postLogin(h, "alice", "wrong")
+
+	lockout, err := s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Equal(auth.MaxFailedLogins+1, lockout.FailedLogins)
+	s.InDelta(2*time.Minute, time.Until(lockout.LockedUntil), float64(5*time.Second))
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_RememberMe() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	login := func(form url.Values) (*http.Cookie, *storage.SessionInfo) {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.Login(w, req)
+		s.Require().Equal(http.StatusFound, w.Code)
+		cookie := w.Result().Cookies()[0]
+		info, err := s.db.ValidateSessionWithInfo(cookie.Value)
+		s.Require().NoError(err)
+		return cookie, info
+	}
+
+	cookie, info := login(url.Values{"username": {"alice"}, "password": {"secret123"}})
+	s.Zero(cookie.MaxAge, "a browser session cookie")
+	s.Equal(ShortSessionDuration, info.Duration)
+	s.WithinDuration(time.Now().Add(ShortSessionDuration), info.ExpiresAt, time.Minute)
+
+	cookie, info = login(url.Values{"username": {"alice"}, "password": {"secret123"}, "remember": {"on"}})
+	s.Equal(int(SessionDuration.Seconds()), cookie.MaxAge)
+	s.Equal(SessionDuration, info.Duration)
+}
+
+func (s *AuthHandlerTestSuite) TestSessionDurationAndClock() {
+	now := time.Now()
+	h := NewHandlers(s.db, WithTemplateFS(s.templates),
+		WithSessionDuration(48*time.Hour, 2*time.Hour), WithClock(func() time.Time { return now }))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	cookie := s.postLogin(h, "alice", "secret123").Result().Cookies()[0]
+	s.Zero(cookie.MaxAge, "still a browser session cookie")
+	info, err := s.db.ValidateSessionWithInfo(cookie.Value)
+	s.Require().NoError(err)
+	s.Equal(2*time.Hour, info.Duration)
+
+	// Past half its lifetime by the handlers' clock, the session is renewed
+	now = now.Add(90 * time.Minute)
+	protected := h.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/expenses", http.NoBody)
+	req.AddCookie(cookie)
+	protected.ServeHTTP(httptest.NewRecorder(), req)
+	info, err = s.db.ValidateSessionWithInfo(cookie.Value)
+	s.Require().NoError(err)
+	s.WithinDuration(now.Add(2*time.Hour), info.ExpiresAt, time.Second)
+}
+
+func (s *AuthHandlerTestSuite) TestSessionCookieOptions() {
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	cookie := s.postLogin(h, "alice", "secret123").Result().Cookies()[0]
+	s.Equal(SessionCookieName, cookie.Name)
+	s.False(cookie.Secure)
+	s.Equal(http.SameSiteLaxMode, cookie.SameSite)
+
+	h = NewHandlers(s.db, WithTemplateFS(s.templates),
+		WithSessionCookieName("expenses"), WithHostCookiePrefix(), WithSameSite(http.SameSiteStrictMode))
+	cookie = s.postLogin(h, "alice", "secret123").Result().Cookies()[0]
+	s.Equal("__Host-expenses", cookie.Name)
+	s.True(cookie.Secure, "browsers reject __Host- cookies without Secure")
+	s.Equal("/", cookie.Path)
+	s.Empty(cookie.Domain)
+	s.Equal(http.SameSiteStrictMode, cookie.SameSite)
+
+	// The middleware only accepts the configured cookie
+	protected := h.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for name, want := range map[string]int{"__Host-expenses": http.StatusOK, SessionCookieName: http.StatusFound} {
+		req := httptest.NewRequest("GET", "/expenses", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: name, Value: cookie.Value})
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, req)
+		s.Equal(want, w.Code, name)
+	}
+}
+
+func (s *AuthHandlerTestSuite) TestSessionBinding() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithSessionBinding(BindUserAgentAndNetwork))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	form := url.Values{"username": {"alice"}, "password": {"secret123"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Firefox/120.0")
+	w := httptest.NewRecorder()
+	h.Login(w, req)
+	s.Require().Equal(http.StatusFound, w.Code)
+	session := w.Result().Cookies()[0]
+
+	protected := h.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(userAgent, remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/expenses", http.NoBody)
+		req.AddCookie(session)
+		req.Header.Set("User-Agent", userAgent)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	s.Equal(http.StatusOK, request("Firefox/121.0", "192.0.2.77:1234"), "same browser after an update, same network")
+	s.Equal(http.StatusFound, request("Firefox/121.0", "198.51.100.1:1234"), "different network")
+	s.Equal(http.StatusFound, request("Firefox/121.0", "192.0.2.77:1234"), "a rejected session is ended")
+
+	_, err = s.db.ValidateSession(session.Value)
+	s.Error(err)
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_RecordsHistory() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	s.postLogin(h, "alice", "wrong")
+	s.postLogin(h, "alice", "secret123")
+	s.postLogin(h, "nobody", "secret123")
+
+	events, err := s.db.ListLoginEvents(user.ID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.True(events[0].Success)
+	s.False(events[1].Success)
+	s.Equal("192.0.2.1", events[0].IP, "httptest requests come from 192.0.2.1")
+
+	req := httptest.NewRequest("GET", "/settings/security", http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w := httptest.NewRecorder()
+	h.SecuritySettings(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Recent logins")
+	s.Contains(body, "192.0.2.1")
+	s.Contains(body, "Success")
+	s.Contains(body, "Failed")
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_LDAPUnavailable() {
+	// Nothing listens on the port once the listener is closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	l.Close()
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithLDAP(auth.LDAPConfig{
+		URL:    "ldap://" + l.Addr().String(),
+		UserDN: "uid=%s,ou=people,dc=example,dc=com",
+	}))
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	user, err := s.db.CreateUser("alice", hash)
+	s.Require().NoError(err)
+
+	// The local password is not used while LDAP is configured, and an
+	// unreachable directory does not count towards a lockout
+	w := s.postLogin(h, "alice", "secret123")
+	s.Contains(w.Body.String(), "An error occurred. Please try again.")
+	s.Empty(w.Result().Cookies())
+
+	lockout, err := s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Zero(lockout.FailedLogins)
+
+	// Unknown users are not provisioned
+	s.postLogin(h, "bob", "secret123")
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+// acceptingDirectory starts an LDAP server that accepts every bind and
+// returns its URL.
+func (s *AuthHandlerTestSuite) acceptingDirectory() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// A bind response with message ID 1 and result code success
+				_, _ = conn.Read(make([]byte, 512))
+				_, _ = conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x61, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return "ldap://" + l.Addr().String()
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_LDAPProvisioning() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithLDAP(auth.LDAPConfig{
+		URL:    s.acceptingDirectory(),
+		UserDN: "uid=%s,ou=people,dc=example,dc=com",
+	}))
+
+	w := s.postLogin(h, "carol", "directory secret")
+	s.Equal(http.StatusFound, w.Code)
+	carol, err := s.db.GetUserByUsername("carol")
+	s.Require().NoError(err, "provisioned")
+	s.Empty(carol.PasswordHash)
+	w = s.postLogin(h, "carol", "directory secret")
+	s.Equal(http.StatusFound, w.Code, "signs in to the provisioned account")
+
+	// A local account, like the bootstrap admin, isn't handed to the
+	// directory user of the same name
+	hash, err := auth.HashPassword("secret123")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("admin", hash)
+	s.Require().NoError(err)
+	w = s.postLogin(h, "admin", "directory secret")
+	s.Contains(w.Body.String(), "belongs to a local account")
+	s.Empty(w.Result().Cookies())
+}
+
+func (s *AuthHandlerTestSuite) TestLogin_UserLookupFails() {
+	store := memstore.New()
+	h := NewHandlers(store, WithTemplateFS(s.templates), WithLDAP(auth.LDAPConfig{
+		URL:    s.acceptingDirectory(),
+		UserDN: "uid=%s,ou=people,dc=example,dc=com",
+	}))
+	store.Fail(memstore.ErrDown, "GetUserByUsername")
+
+	w := s.postLogin(h, "carol", "directory secret")
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.Contains(w.Body.String(), "An error occurred. Please try again.")
+	store.Fail(nil, "GetUserByUsername")
+	_, err := store.GetUserByUsername("carol")
+	s.ErrorIs(err, sql.ErrNoRows, "not provisioned")
+}
+
+func (s *AuthHandlerTestSuite) postEmail(h *Handlers, user *models.User, email string) *httptest.ResponseRecorder {
+	form := url.Values{"email": {email}}
+	req := httptest.NewRequest("POST", "/settings/email", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w := httptest.NewRecorder()
+	h.UpdateEmail(w, req)
+	return w
+}
+
+func (s *AuthHandlerTestSuite) verifyEmail(h *Handlers, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.VerifyEmail(w, httptest.NewRequest("GET", "/verify-email?token="+url.QueryEscape(token), http.NoBody))
+	return w
+}
+
+// recordingSender keeps sent emails for inspection.
+type recordingSender struct {
+	sent []mail.Message
+}
+
+func (r *recordingSender) Send(msg mail.Message) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func (s *AuthHandlerTestSuite) TestEmail_AddAndVerify() {
+	sender := &recordingSender{}
+	h := NewHandlers(s.db, WithTemplateFS(s.templates), WithMailer(mail.New(sender, os.DirFS("../../web/templates/email"))))
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+
+	w := s.postEmail(h, user, "not-an-email")
+	s.Contains(w.Body.String(), "Enter a valid email address")
+	s.Empty(sender.sent)
+
+	w = s.postEmail(h, user, "alice@example.com")
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "We sent a verification link to alice@example.com")
+	s.Contains(w.Body.String(), "Not verified")
+
+	s.Require().Len(sender.sent, 1)
+	s.Equal("alice@example.com", sender.sent[0].To)
+	s.Equal("Verify your email address", sender.sent[0].Subject)
+	_, link, found := strings.Cut(sender.sent[0].Body, "http://example.com/verify-email?token=")
+	s.Require().True(found, "the email should contain the verification link")
+	token, err := url.QueryUnescape(strings.Fields(link)[0])
+	s.Require().NoError(err)
+
+	w = s.verifyEmail(h, token)
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "alice@example.com is now verified")
+
+	user, err = s.db.GetUserByID(user.ID)
+	s.Require().NoError(err)
+	s.True(user.EmailVerified)
+}
+
+func (s *AuthHandlerTestSuite) TestEmail_RejectsBadLinks() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.postEmail(h, user, "alice@example.com")
+	link := func(email string, expires time.Time) string {
+		return auth.SignToken(h.secretKey, verifyEmailPurpose, strconv.FormatInt(user.ID, 10)+"|"+email, expires)
+	}
+
+	w := s.verifyEmail(h, link("alice@example.com", time.Now().Add(-time.Minute)))
+	s.Contains(w.Body.String(), "invalid or has expired")
+
+	w = s.verifyEmail(h, auth.SignToken([]byte("other key"), verifyEmailPurpose, strconv.FormatInt(user.ID, 10)+"|alice@example.com", time.Now().Add(time.Hour)))
+	s.Contains(w.Body.String(), "invalid or has expired")
+
+	// A link for an address the user has since replaced no longer works
+	s.postEmail(h, user, "alice@work.example.com")
+	w = s.verifyEmail(h, link("alice@example.com", time.Now().Add(time.Hour)))
+	s.Contains(w.Body.String(), "has changed since this link was sent")
+
+	user, err = s.db.GetUserByID(user.ID)
+	s.Require().NoError(err)
+	s.False(user.EmailVerified)
+}
+
+// testAuthenticator is a software WebAuthn authenticator holding one P-256
+// passkey for the relying party "example.com".
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	count        uint32
+}
+
+func newTestAuthenticator(s *AuthHandlerTestSuite) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	return &testAuthenticator{key: key, credentialID: []byte("test-credential")}
+}
+
+func (a *testAuthenticator) id() string {
+	return base64.RawURLEncoding.EncodeToString(a.credentialID)
+}
+
+func (a *testAuthenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": "http://example.com"})
+	return b
+}
+
+// authData builds authenticator data with the user present and verified
+// flags, plus attested credential data when registering.
+func (a *testAuthenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	data := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		data = append(data, 0x45)
+	} else {
+		data = append(data, 0x05)
+	}
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		// COSE_Key map: kty EC2, alg ES256, crv P-256, x, y
+		point, _ := a.key.PublicKey.Bytes()
+		data = append(data, 0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01)
+		data = append(append(data, 0x21, 0x58, 0x20), point[1:33]...)
+		data = append(append(data, 0x22, 0x58, 0x20), point[33:]...)
+	}
+	return data
+}
+
+func (a *testAuthenticator) register(s *AuthHandlerTestSuite, challenge string) passkeyRegistration {
+	publicKey, err := x509.MarshalPKIXPublicKey(&a.key.PublicKey)
+	s.Require().NoError(err)
+	return passkeyRegistration{
+		ID:                a.id(),
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", challenge)),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(a.authData(true)),
+		PublicKey:         base64.RawURLEncoding.EncodeToString(publicKey),
+		Name:              "Phone",
+	}
+}
+
+func (a *testAuthenticator) assert(s *AuthHandlerTestSuite, challenge string) passkeyAssertion {
+	a.count++
+	clientData := a.clientData("webauthn.get", challenge)
+	authData := a.authData(false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	s.Require().NoError(err)
+	return passkeyAssertion{
+		ID:                a.id(),
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+		Signature:         base64.RawURLEncoding.EncodeToString(signature),
+	}
+}
+
+// passkeyRequest calls a passkey endpoint with a JSON body as user, or
+// anonymously if user is nil.
+func (s *AuthHandlerTestSuite) passkeyRequest(handler http.HandlerFunc, user *models.User, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		s.Require().NoError(json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest("POST", "http://example.com/", &buf)
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+// challenge fetches options from handler and returns their challenge.
+func (s *AuthHandlerTestSuite) challenge(handler http.HandlerFunc, user *models.User) string {
+	w := s.passkeyRequest(handler, user, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var options struct {
+		Challenge string `json:"challenge"`
+	}
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&options))
+	s.Require().NotEmpty(options.Challenge)
+	return options.Challenge
+}
+
+func (s *AuthHandlerTestSuite) TestPasskey_RegisterAndLogin() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	authenticator := newTestAuthenticator(s)
+
+	challenge := s.challenge(h.PasskeyRegistrationOptions, user)
+	w := s.passkeyRequest(h.RegisterPasskey, user, authenticator.register(s, challenge))
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+
+	passkeys, err := s.db.ListPasskeys(user.ID)
+	s.Require().NoError(err)
+	s.Require().Len(passkeys, 1)
+	s.Equal("Phone", passkeys[0].Name)
+
+	// Passkeys work even while password logins are locked out
+	s.Require().NoError(s.db.LockUser(user.ID, time.Now().Add(time.Hour)))
+
+	challenge = s.challenge(h.PasskeyLoginOptions, nil)
+	w = s.passkeyRequest(h.PasskeyLogin, nil, authenticator.assert(s, challenge))
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Contains(w.Body.String(), `"redirect":"/expenses"`)
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			session = c
+		}
+	}
+	s.Require().NotNil(session, "passkey login should set a session cookie")
+	sessionUser, err := s.db.ValidateSession(session.Value)
+	s.Require().NoError(err)
+	s.Equal(user.ID, sessionUser.ID)
+
+	passkey, err := s.db.GetPasskey(authenticator.id())
+	s.Require().NoError(err)
+	s.EqualValues(1, passkey.SignCount)
+	s.NotNil(passkey.LastUsedAt)
+}
+
+func (s *AuthHandlerTestSuite) TestPasskey_RejectsReplayAndForgery() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	authenticator := newTestAuthenticator(s)
+
+	// The browser's key must be the one the authenticator attested
+	challenge := s.challenge(h.PasskeyRegistrationOptions, user)
+	registration := authenticator.register(s, challenge)
+	other, err := x509.MarshalPKIXPublicKey(&newTestAuthenticator(s).key.PublicKey)
+	s.Require().NoError(err)
+	registration.PublicKey = base64.RawURLEncoding.EncodeToString(other)
+	s.Equal(http.StatusBadRequest, s.passkeyRequest(h.RegisterPasskey, user, registration).Code)
+	passkeys, err := s.db.ListPasskeys(user.ID)
+	s.Require().NoError(err)
+	s.Empty(passkeys)
+
+	challenge = s.challenge(h.PasskeyRegistrationOptions, user)
+	s.Require().Equal(http.StatusNoContent, s.passkeyRequest(h.RegisterPasskey, user, authenticator.register(s, challenge)).Code)
+
+	// A challenge can only be answered once
+	challenge = s.challenge(h.PasskeyLoginOptions, nil)
+	assertion := authenticator.assert(s, challenge)
+	s.Equal(http.StatusOK, s.passkeyRequest(h.PasskeyLogin, nil, assertion).Code)
+	s.Equal(http.StatusBadRequest, s.passkeyRequest(h.PasskeyLogin, nil, assertion).Code)
+
+	// Challenges the server never issued are refused
+	s.Equal(http.StatusBadRequest, s.passkeyRequest(h.PasskeyLogin, nil, authenticator.assert(s, "made-up")).Code)
+
+	// A different key cannot sign for the registered credential
+	impostor := newTestAuthenticator(s)
+	challenge = s.challenge(h.PasskeyLoginOptions, nil)
+	w := s.passkeyRequest(h.PasskeyLogin, nil, impostor.assert(s, challenge))
+	s.Equal(http.StatusUnauthorized, w.Code)
+	s.Empty(w.Result().Cookies())
+
+	events, err := s.db.ListLoginEvents(user.ID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.False(events[0].Success, "the forged assertion should be recorded as a failed login")
+	s.True(events[1].Success)
+}
+
+func (s *AuthHandlerTestSuite) TestPasskey_SettingsListAndDelete() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreatePasskey(&models.Passkey{ID: "cred-1", UserID: user.ID, Name: "Work laptop", PublicKey: []byte{1}}))
+
+	req := httptest.NewRequest("GET", "/settings/security", http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w := httptest.NewRecorder()
+	h.SecuritySettings(w, req)
+	s.Contains(w.Body.String(), "Work laptop")
+	s.Contains(w.Body.String(), "Never used")
+
+	req = httptest.NewRequest("DELETE", "/settings/passkeys/cred-1", http.NoBody)
+	req.SetPathValue("id", "cred-1")
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w = httptest.NewRecorder()
+	h.DeletePasskey(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	passkeys, err := s.db.ListPasskeys(user.ID)
+	s.Require().NoError(err)
+	s.Empty(passkeys)
+}
+
+// TestAuthHandlerSuite runs the authentication handler test suite
+func TestAuthHandlerSuite(t *testing.T) {
+	suite.Run(t, new(AuthHandlerTestSuite))
+}
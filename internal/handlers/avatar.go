@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Decodes uploaded GIFs
+	"image/jpeg"
+	_ "image/png" // Decodes uploaded PNGs
+	"io"
+	"net/http"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/models"
+)
+
+// avatarSize is the width and height avatars are stored at, enough for the
+// small sizes pages show them at on high density screens.
+const avatarSize = 128
+
+// avatarMaxPixels limits the size of uploaded images, which are decoded in
+// full before they're resized.
+const avatarMaxPixels = 25_000_000
+
+// errNotAnImage is the message for uploads that can't be used as avatars.
+const errNotAnImage = "Pick a JPEG, PNG, or GIF image"
+
+// UploadAvatar replaces the user's avatar with an uploaded image, cropped
+// to a square and resized.
+func (h *Handlers) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	renderError := func(msg string) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderProfile(w, r, user.ID, func(vm *ProfileViewModel) { vm.AvatarError = msg })
+	}
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			renderError("That image is too large, pick one under " + config.Size(tooLarge.Limit).String())
+			return
+		}
+		renderError(errNotAnImage)
+		return
+	}
+	defer file.Close()
+
+	avatar, err := resizeAvatar(file)
+	if err != nil {
+		renderError(errNotAnImage)
+		return
+	}
+	if err := h.store(r).SetAvatar(user.ID, avatar, "image/jpeg"); err != nil {
+		h.logger.Printf("UploadAvatar error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// A whole new page, so the header shows the new avatar too
+	http.Redirect(w, r, "/settings/profile", http.StatusSeeOther)
+}
+
+// DeleteAvatar removes the user's avatar, bringing back their initials.
+func (h *Handlers) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store(r).DeleteAvatar(user.ID); err != nil {
+		h.logger.Printf("DeleteAvatar error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Redirect", "/settings/profile")
+}
+
+// Avatar serves a user's avatar, or their initials if they didn't upload
+// one, so pages can always link to it. Browsers check back every time,
+// which costs little since unchanged avatars are answered with 304.
+func (h *Handlers) Avatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	db := h.store(r)
+	avatar, err := db.GetAvatar(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.serveInitials(w, r, id)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Avatar error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, avatar.UpdatedAt.UnixNano())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if clientIsCurrent(r, etag, avatar.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", avatar.ContentType)
+	w.Header().Set("Last-Modified", avatar.UpdatedAt.UTC().Format(http.TimeFormat))
+	_, _ = w.Write(avatar.Image)
+}
+
+// initialsAvatar draws the first letter of a username on a circle in one
+// of the category colors, picked by the user's ID.
+var initialsAvatar = template.Must(template.New("initials").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128" viewBox="0 0 128 128">` +
+		`<circle cx="64" cy="64" r="64" fill="{{.Color}}"/>` +
+		`<text x="64" y="64" dy=".35em" text-anchor="middle" font-family="system-ui, sans-serif" font-size="60" fill="#fff">{{.Initial}}</text>` +
+		`</svg>`))
+
+// serveInitials serves the stand-in avatar of a user without one.
+func (h *Handlers) serveInitials(w http.ResponseWriter, r *http.Request, userID int64) {
+	user, err := h.store(r).GetUserByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Avatar error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	initial, _ := utf8.DecodeRuneInString(user.Username)
+	data := struct{ Color, Initial string }{
+		Color:   categories[int(userID)%len(categories)].Color,
+		Initial: string(unicode.ToUpper(initial)),
+	}
+	etag := fmt.Sprintf(`"initials-%d-%x"`, userID, initial)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if clientIsCurrent(r, etag, h.started) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	if err := initialsAvatar.Execute(w, data); err != nil {
+		h.logger.Printf("Avatar error: %v", err)
+	}
+}
+
+// resizeAvatar decodes an uploaded image, crops the largest square out of
+// its middle, and returns it scaled to avatarSize as a JPEG. Transparent
+// parts turn white.
+func resizeAvatar(r io.ReadSeeker) ([]byte, error) {
+	size, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if size.Width*size.Height > avatarMaxPixels {
+		return nil, fmt.Errorf("image is %dx%d pixels, too large to resize", size.Width, size.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	dst := image.NewRGBA(image.Rect(0, 0, avatarSize, avatarSize))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), scaleSquare(src, crop, avatarSize), image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleSquare scales the square crop of src to size by size pixels, each
+// the average of the source pixels it covers, or the nearest one when
+// scaling up.
+func scaleSquare(src image.Image, crop image.Rectangle, size int) *image.RGBA64 {
+	dst := image.NewRGBA64(image.Rect(0, 0, size, size))
+	scale := float64(crop.Dx()) / float64(size)
+	span := func(i, origin int) (int, int) {
+		from := origin + int(float64(i)*scale)
+		return from, max(origin+int(float64(i+1)*scale), from+1)
+	}
+	for y := range size {
+		y0, y1 := span(y, crop.Min.Y)
+		for x := range size {
+			x0, x1 := span(x, crop.Min.X)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
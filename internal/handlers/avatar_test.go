@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AvatarTestSuite provides a test suite for user avatars
+type AvatarTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	user     *models.User
+}
+
+// SetupTest runs before each test
+func (s *AvatarTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(db.CreateSession("token", s.user.ID, time.Hour, ""))
+}
+
+// TearDownTest runs after each test
+func (s *AvatarTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// do sends a request with alice's session cookie through AuthMiddleware.
+func (s *AvatarTestSuite) do(req *http.Request, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "token"})
+	w := httptest.NewRecorder()
+	s.handlers.AuthMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+// upload posts data as the avatar file.
+func (s *AvatarTestSuite) upload(data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", "avatar.png")
+	s.Require().NoError(err)
+	_, _ = part.Write(data)
+	s.Require().NoError(form.Close())
+	req := httptest.NewRequest("POST", "/settings/profile/avatar", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.do(req, s.handlers.UploadAvatar)
+}
+
+// avatar requests the avatar of the user with id.
+func (s *AvatarTestSuite) avatar(id string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/avatars/"+id, http.NoBody)
+	req.SetPathValue("id", id)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return s.do(req, s.handlers.Avatar)
+}
+
+// landscape returns a PNG twice as wide as it's high, red in the middle
+// and blue on the sides.
+func (s *AvatarTestSuite) landscape() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			c := color.RGBA{B: 255, A: 255}
+			if x >= 100 && x < 300 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	s.Require().NoError(png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func (s *AvatarTestSuite) TestUpload() {
+	w := s.upload(s.landscape())
+	s.Require().Equal(http.StatusSeeOther, w.Code, w.Body.String())
+	s.Equal("/settings/profile", w.Header().Get("Location"))
+
+	avatar, err := s.db.GetAvatar(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal("image/jpeg", avatar.ContentType)
+	img, err := jpeg.Decode(bytes.NewReader(avatar.Image))
+	s.Require().NoError(err)
+	s.Equal(image.Rect(0, 0, avatarSize, avatarSize), img.Bounds())
+	r, _, b, _ := img.At(0, 0).RGBA()
+	s.Greater(r, b, "cropped to the middle")
+
+	w = s.avatar("1", nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("image/jpeg", w.Header().Get("Content-Type"))
+	s.Equal(avatar.Image, w.Body.Bytes())
+	w = s.avatar("1", http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	s.Equal(http.StatusNotModified, w.Code)
+
+	w = s.do(httptest.NewRequest("GET", "/settings/profile", http.NoBody), s.handlers.Profile)
+	s.Contains(w.Body.String(), `hx-delete="/settings/profile/avatar"`)
+	s.Contains(w.Body.String(), "/avatars/1?v=")
+}
+
+func (s *AvatarTestSuite) TestUploadInvalid() {
+	w := s.upload([]byte("not an image"))
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Contains(w.Body.String(), errNotAnImage)
+
+	img := image.NewGray(image.Rect(0, 0, 10000, 5000))
+	var buf bytes.Buffer
+	s.Require().NoError(png.Encode(&buf, img))
+	w = s.upload(buf.Bytes())
+	s.Equal(http.StatusUnprocessableEntity, w.Code, "too many pixels")
+
+	_, err := s.db.GetAvatar(s.user.ID)
+	s.Error(err, "nothing saved")
+}
+
+func (s *AvatarTestSuite) TestInitials() {
+	w := s.avatar("1", nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal("image/svg+xml", w.Header().Get("Content-Type"))
+	s.Contains(w.Body.String(), ">A</text>")
+	w = s.avatar("1", http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	s.Equal(http.StatusNotModified, w.Code)
+
+	s.Equal(http.StatusNotFound, s.avatar("404", nil).Code)
+	s.Equal(http.StatusNotFound, s.avatar("x", nil).Code)
+}
+
+func (s *AvatarTestSuite) TestDelete() {
+	s.Require().Equal(http.StatusSeeOther, s.upload(s.landscape()).Code)
+	w := s.do(httptest.NewRequest("DELETE", "/settings/profile/avatar", http.NoBody), s.handlers.DeleteAvatar)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("/settings/profile", w.Header().Get("HX-Redirect"))
+	s.Equal("image/svg+xml", s.avatar("1", nil).Header().Get("Content-Type"), "initials again")
+}
+
+// TestAvatarTestSuite runs the user avatar test suite
+func TestAvatarTestSuite(t *testing.T) {
+	suite.Run(t, new(AvatarTestSuite))
+}
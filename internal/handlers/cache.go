@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// notModified lets clients reuse their copy of a page built from the
+// expenses, such as the list or the statistics. It sets the page's ETag and
+// Last-Modified, and if the request's conditions show the client's copy is
+// still current, answers 304 Not Modified and returns true; the caller then
+// has nothing left to do.
+//
+// The tag covers the data version, the user and every one of their
+// preferences, such as their budget and the ledger they picked and its
+// currency, the day in their time zone (pages title today's expenses),
+// read-only mode, whether a guest is looking, and the server's start, after
+// which templates and assets may differ. Pages are never cached in
+// development mode.
+func (h *Handlers) notModified(w http.ResponseWriter, r *http.Request) bool {
+	if h.dev != nil {
+		return false
+	}
+	version, err := h.store(r).DataVersion()
+	if err != nil {
+		// Serve the page as usual, it just can't be cached
+		h.logger.Printf("DataVersion error: %v", err)
+		return false
+	}
+	var userID int64
+	if user, ok := r.Context().Value(UserContextKey).(*models.User); ok {
+		userID = user.ID
+	}
+	now := h.userNow(r)
+	prefs := h.pagePreferences(r)
+	prefsHash := fnv.New32a()
+	// All of them, so one the pages start showing can't be missed
+	fmt.Fprintf(prefsHash, "%+v", prefs)
+	fragment := r.Header.Get("HX-Request") == "true"
+	etag := fmt.Sprintf(`W/"%d-%d-%x-%s-%x-%t-%t-%t"`, version.Version, userID, prefsHash.Sum32(), now.Format("20060102"), h.started.Unix(), h.ReadOnly(), guest(r) != nil, fragment)
+
+	// Nothing shown changed since the latest of these
+	modified := version.ChangedAt
+	for _, t := range []time.Time{h.started, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())} {
+		if t.After(modified) {
+			modified = t
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	// Browsers must check back every time, and htmx requests get the
+	// content without the layout
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "HX-Request")
+
+	if !clientIsCurrent(r, etag, modified) {
+		return false
+	}
+	// A 304 has no body, so these would only describe the one not sent
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// clientIsCurrent reports whether the conditions of a GET request say the
+// client has the page with etag, last modified at modified. If-None-Match
+// takes precedence over If-Modified-Since, as RFC 9110 requires.
+func clientIsCurrent(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			// Weak comparison, as for If-None-Match
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// Last-Modified only has whole seconds
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage/memstore"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CacheTestSuite provides a test suite for the conditional requests of the
+// expense pages
+type CacheTestSuite struct {
+	suite.Suite
+	store    *memstore.Store
+	handlers *Handlers
+	now      time.Time
+}
+
+// SetupTest runs before each test
+func (s *CacheTestSuite) SetupTest() {
+	s.store = memstore.New()
+	s.now = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(s.store, WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+	s.Require().NoError(s.store.CreateExpense(10, "Coffee", "Eating Out", s.now.Add(-time.Hour), 1))
+}
+
+// get requests the expense list as user 1, with the given extra headers.
+func (s *CacheTestSuite) get(headers ...string) *httptest.ResponseRecorder {
+	return s.getAs(1, headers...)
+}
+
+func (s *CacheTestSuite) getAs(userID int64, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/expenses", http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &models.User{ID: userID, Username: "someone"}))
+	req = req.WithContext(s.handlers.withPreferences(req.Context(), s.store, userID))
+	for i := 0; i < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.handlers.ListExpenses(w, req)
+	return w
+}
+
+func (s *CacheTestSuite) TestIfNoneMatch() {
+	first := s.get()
+	s.Equal(http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	s.NotEmpty(etag)
+	s.Equal("private, no-cache", first.Header().Get("Cache-Control"))
+
+	w := s.get("If-None-Match", etag)
+	s.Equal(http.StatusNotModified, w.Code)
+	s.Empty(w.Body.String())
+	s.Equal(etag, w.Header().Get("ETag"))
+	s.Equal(http.StatusNotModified, s.get("If-None-Match", `"other", `+etag).Code)
+	s.Equal(http.StatusOK, s.get("If-None-Match", `W/"other"`).Code)
+
+	// Anything the page shows changing makes the client's copy stale
+	for name, change := range map[string]func(){
+		"new expense": func() { s.Require().NoError(s.store.CreateExpense(5, "Bus", "Transport", s.now, 2)) },
+		"next day":    func() { s.now = s.now.AddDate(0, 0, 1) },
+		"read-only":   func() { s.handlers.SetReadOnly(true) },
+		"budget": func() {
+			prefs := models.DefaultPreferences()
+			prefs.Budget = 500
+			s.Require().NoError(s.store.SavePreferences(1, prefs))
+		},
+	} {
+		change()
+		w := s.get("If-None-Match", etag)
+		s.Equal(http.StatusOK, w.Code, name)
+		s.Contains(w.Body.String(), "Coffee", name)
+		etag = w.Header().Get("ETag")
+	}
+}
+
+func (s *CacheTestSuite) TestTagPerUserAndFragment() {
+	etag := s.get().Header().Get("ETag")
+	s.Equal(http.StatusOK, s.getAs(2, "If-None-Match", etag).Code, "another user sees other highlights")
+	s.Equal(http.StatusOK, s.get("If-None-Match", etag, "HX-Request", "true").Code, "htmx gets the content alone")
+	s.Contains(s.get().Header().Values("Vary"), "HX-Request")
+}
+
+func (s *CacheTestSuite) TestIfModifiedSince() {
+	modified, err := http.ParseTime(s.get().Header().Get("Last-Modified"))
+	s.Require().NoError(err)
+
+	s.Equal(http.StatusNotModified, s.get("If-Modified-Since", modified.Format(http.TimeFormat)).Code)
+	s.Equal(http.StatusOK, s.get("If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat)).Code)
+	// If-None-Match wins over If-Modified-Since
+	s.Equal(http.StatusOK, s.get("If-Modified-Since", modified.Format(http.TimeFormat), "If-None-Match", `"stale"`).Code)
+}
+
+func (s *CacheTestSuite) TestUncached() {
+	// Without the data version the page is still served
+	s.store.Fail(memstore.ErrDown, "DataVersion")
+	w := s.get("If-None-Match", "*")
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("ETag"))
+
+	s.store.Fail(nil, "DataVersion")
+	h := NewHandlers(s.store, WithDevMode(), WithLogger(log.New(io.Discard, "", 0)))
+	req := httptest.NewRequest("GET", "/statistics", http.NoBody)
+	req.Header.Set("If-None-Match", "*")
+	w = httptest.NewRecorder()
+	h.Statistics(w, req)
+	s.Equal(http.StatusOK, w.Code, "development mode always renders")
+	s.Empty(w.Header().Get("ETag"))
+}
+
+// TestCacheTestSuite runs the conditional request test suite
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// categoryUsageWindow is how far back the category picker looks to find
+// the categories a user picks most.
+const categoryUsageWindow = 90 * 24 * time.Hour
+
+const categoryUsageContextKey contextKey = "categoryUsage"
+
+// categoryUsageLoader loads the categories the signed-in user picks most
+// the first time a request needs them.
+type categoryUsageLoader struct {
+	once  sync.Once
+	load  func() ([]string, error)
+	names []string
+}
+
+// withCategoryUsage returns a context that loads which categories a user
+// picks most from db when asked for them.
+func (h *Handlers) withCategoryUsage(ctx context.Context, db Store, userID int64) context.Context {
+	return context.WithValue(ctx, categoryUsageContextKey, &categoryUsageLoader{
+		load: func() ([]string, error) { return db.CategoryUsage(userID, h.now().Add(-categoryUsageWindow)) },
+	})
+}
+
+// categoryUsage returns the categories the request's user picked lately,
+// most used first, or none when nobody is signed in or they can't be
+// loaded.
+func (h *Handlers) categoryUsage(r *http.Request) []string {
+	loader, ok := r.Context().Value(categoryUsageContextKey).(*categoryUsageLoader)
+	if !ok {
+		return nil
+	}
+	loader.once.Do(func() {
+		names, err := loader.load()
+		if err != nil {
+			// The display order will do
+			h.logger.Printf("CategoryUsage error: %v", err)
+		}
+		loader.names = names
+	})
+	return loader.names
+}
+
+// pickerCategories returns the categories in the order to offer them to
+// the request's user: the ones they picked lately first, most used first,
+// then the rest in display order.
+func (h *Handlers) pickerCategories(r *http.Request) []CategoryDef {
+	defs := h.categoryDefs(h.store(r))
+	usage := h.categoryUsage(r)
+	rank := make(map[string]int, len(usage))
+	for i, name := range usage {
+		rank[name] = i
+	}
+	position := func(name string) int {
+		if i, ok := rank[name]; ok {
+			return i
+		}
+		return len(usage)
+	}
+	sort.SliceStable(defs, func(i, j int) bool { return position(defs[i].Name) < position(defs[j].Name) })
+	return defs
+}
+
+// CategoryPicker renders the options of the category picker in the
+// expense form, ordered for the user, with the given category selected.
+func (h *Handlers) CategoryPicker(w http.ResponseWriter, r *http.Request) {
+	h.renderPartial(w, r, "list.html", "category-options", CategoryPickerViewModel{
+		Categories: h.pickerCategories(r),
+		Selected:   r.URL.Query().Get("selected"),
+	})
+}
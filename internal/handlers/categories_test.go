@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CategoryPickerTestSuite provides a test suite for the category picker
+type CategoryPickerTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	now      time.Time
+}
+
+// SetupTest runs before each test
+func (s *CategoryPickerTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *CategoryPickerTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// picker returns the names of the picker's options in order, and the
+// selected one, as user 1.
+func (s *CategoryPickerTestSuite) picker(selected string) ([]string, string) {
+	req := asUser(httptest.NewRequest("GET", "/categories/picker?selected="+selected, http.NoBody), 1)
+	req = req.WithContext(s.handlers.withCategoryUsage(req.Context(), s.db, 1))
+	w := httptest.NewRecorder()
+	s.handlers.CategoryPicker(w, req)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var names []string
+	var current string
+	for _, m := range regexp.MustCompile(`class="category-option( selected)?" data-name="([^"]+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		names = append(names, m[2])
+		if m[1] != "" {
+			current = m[2]
+		}
+	}
+	return names, current
+}
+
+func (s *CategoryPickerTestSuite) TestOrder() {
+	names, selected := s.picker("Housing")
+	s.Equal(categories[0].Name, names[0], "display order without expenses")
+	s.Equal("Housing", selected)
+
+	for i, category := range []string{"Transport", "Health", "Transport"} {
+		s.Require().NoError(s.db.CreateExpense(1, "", category, s.now.Add(-time.Duration(i+1)*time.Hour), 1))
+	}
+	s.Require().NoError(s.db.CreateExpense(1, "", "Gifts", s.now.AddDate(-1, 0, 0), 1))
+	s.Require().NoError(s.db.CreateExpense(1, "", "Travel", s.now, 2))
+
+	names, _ = s.picker("")
+	s.Require().Len(names, len(categories))
+	s.Equal([]string{"Transport", "Health"}, names[:2], "the user's lately most used first")
+	rest := names[2:]
+	s.Equal(categories[0].Name, rest[0], "then the display order")
+	s.NotEqual("Gifts", rest[0], "too long ago")
+}
+
+// TestCategoryPickerTestSuite runs the category picker test suite
+func TestCategoryPickerTestSuite(t *testing.T) {
+	suite.Run(t, new(CategoryPickerTestSuite))
+}
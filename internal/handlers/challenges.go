@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Challenges renders the user's no-spend streak, this week's challenge, and
+// how earlier ones went.
+func (h *Handlers) Challenges(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderChallenges(w, r, user.ID, ChallengesViewModel{})
+}
+
+// renderChallenges renders the challenges page for a user, filling their
+// streak and challenges into viewModel.
+func (h *Handlers) renderChallenges(w http.ResponseWriter, r *http.Request, userID int64, viewModel ChallengesViewModel) {
+	db := h.store(r)
+	streak, err := db.GetStreak(userID)
+	if err != nil {
+		h.logger.Printf("GetStreak error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	challenges, err := db.ListChallenges(userID)
+	if err != nil {
+		h.logger.Printf("ListChallenges error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	viewModel.Streak = streak
+	week := startOfISOWeek(h.userNow(r)).Format(time.DateOnly)
+	for _, c := range challenges {
+		item := newChallengeItem(c)
+		if c.Week == week {
+			viewModel.Current = &item
+			continue
+		}
+		viewModel.History = append(viewModel.History, item)
+		if c.Status == models.ChallengeCompleted {
+			viewModel.Completed++
+		}
+	}
+	h.render(w, r, "challenges.html", viewModel)
+}
+
+// newChallengeItem converts a challenge for display.
+func newChallengeItem(c models.Challenge) ChallengeItem {
+	item := ChallengeItem{
+		Challenge: c,
+		Left:      max(c.Limit-c.Spent, 0),
+		Over:      max(c.Spent-c.Limit, 0),
+		Updated:   c.UpdatedAt.Local().Format("02 Jan, 15:04"),
+	}
+	if monday, err := time.Parse(time.DateOnly, c.Week); err == nil {
+		item.Title = monday.Format("02 Jan") + " – " + monday.AddDate(0, 0, 6).Format("02 Jan")
+	}
+	if c.Limit > 0 {
+		item.Percent = min(c.Spent/c.Limit*100, 100)
+	}
+	return item
+}
+
+// CreateChallenge takes on the challenge of keeping this week's spending in
+// the user's ledger under the submitted amount. What was spent since Monday
+// counts, so it can't be over the amount already.
+func (h *Handlers) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	typed := strings.TrimSpace(r.FormValue("limit"))
+	limit, err := strconv.ParseFloat(strings.Replace(typed, ",", ".", 1), 64)
+	if err != nil || limit <= 0 || math.IsInf(limit, 0) || math.IsNaN(limit) {
+		h.renderChallenges(w, r, user.ID, ChallengesViewModel{Limit: typed, Error: "Enter the amount to stay under this week"})
+		return
+	}
+
+	db := h.store(r)
+	monday := startOfISOWeek(h.userNow(r))
+	ledger := h.ledger(r)
+	spent, err := db.GetTotalBetween(ledger, monday, monday.AddDate(0, 0, 7))
+	if err != nil {
+		h.logger.Printf("CreateChallenge error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if spent > limit {
+		h.renderChallenges(w, r, user.ID, ChallengesViewModel{Limit: typed, Error: "You've spent more than that this week already"})
+		return
+	}
+
+	err = db.CreateChallenge(&models.Challenge{
+		UserID:   user.ID,
+		LedgerID: ledger,
+		Week:     monday.Format(time.DateOnly),
+		Limit:    limit,
+		Spent:    spent,
+	})
+	if errors.Is(err, storage.ErrChallengeExists) {
+		h.renderChallenges(w, r, user.ID, ChallengesViewModel{Error: "You have a challenge this week already"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("CreateChallenge error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderChallenges(w, r, user.ID, ChallengesViewModel{Notice: "Challenge accepted"})
+}
+
+// DeleteChallenge gives up one of the user's active challenges.
+func (h *Handlers) DeleteChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	err := h.store(r).DeleteChallenge(user.ID, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Challenge not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Printf("DeleteChallenge error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderChallenges(w, r, user.ID, ChallengesViewModel{})
+}
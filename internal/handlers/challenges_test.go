@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ChallengesTestSuite provides a test suite for the challenges page
+type ChallengesTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	now      time.Time
+}
+
+// SetupTest runs before each test
+func (s *ChallengesTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	// Wednesday
+	s.now = time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+	prefs := models.DefaultPreferences()
+	prefs.Timezone = "UTC"
+	s.Require().NoError(db.SavePreferences(1, prefs))
+}
+
+// TearDownTest runs after each test
+func (s *ChallengesTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// signedIn returns r as sent by user 1, whose preferences load like after
+// AuthMiddleware.
+func (s *ChallengesTestSuite) signedIn(r *http.Request) *http.Request {
+	r = asUser(r, 1)
+	return r.WithContext(s.handlers.withPreferences(r.Context(), s.db, 1))
+}
+
+// create submits the challenge form with limit.
+func (s *ChallengesTestSuite) create(limit string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/challenges", strings.NewReader(url.Values{"limit": {limit}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.handlers.CreateChallenge(w, s.signedIn(req))
+	return w
+}
+
+func (s *ChallengesTestSuite) TestPage() {
+	s.Require().NoError(s.db.SaveStreak(&models.Streak{UserID: 1, Current: 3, Longest: 12}))
+	w := httptest.NewRecorder()
+	s.handlers.Challenges(w, s.signedIn(httptest.NewRequest("GET", "/challenges", http.NoBody)))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "<strong>3</strong><small>days in a row</small>")
+	s.Contains(body, "<strong>12</strong><small>longest</small>")
+	s.Contains(body, `hx-post="/challenges"`, "no challenge this week")
+	s.Contains(body, "No challenges yet")
+}
+
+func (s *ChallengesTestSuite) TestCreate() {
+	s.Require().NoError(s.db.CreateExpense(30, "Dinner", "Eating Out", time.Date(2026, 3, 9, 19, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(80, "Groceries", "Groceries", time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC), 1))
+
+	for limit, message := range map[string]string{
+		"":    "Enter the amount to stay under this week",
+		"-5":  "Enter the amount to stay under this week",
+		"abc": "Enter the amount to stay under this week",
+		"25":  "You&#39;ve spent more than that this week already",
+	} {
+		w := s.create(limit)
+		s.Require().Equal(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), message, limit)
+	}
+
+	w := s.create("50,5")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Challenge accepted")
+	s.NotContains(w.Body.String(), `hx-post="/challenges"`)
+	challenges, err := s.db.ListChallenges(1)
+	s.Require().NoError(err)
+	s.Require().Len(challenges, 1)
+	s.Equal("2026-03-09", challenges[0].Week)
+	s.InDelta(50.5, challenges[0].Limit, 0.001)
+	s.InDelta(30, challenges[0].Spent, 0.001, "since Monday")
+	s.Equal(models.ChallengeActive, challenges[0].Status)
+
+	w = s.create("60")
+	s.Contains(w.Body.String(), "You have a challenge this week already")
+}
+
+func (s *ChallengesTestSuite) TestDelete() {
+	current := &models.Challenge{UserID: 1, LedgerID: models.PersonalLedgerID, Week: "2026-03-09", Limit: 50}
+	s.Require().NoError(s.db.CreateChallenge(current))
+	earlier := &models.Challenge{UserID: 1, LedgerID: models.PersonalLedgerID, Week: "2026-03-02", Limit: 100}
+	s.Require().NoError(s.db.CreateChallenge(earlier))
+	s.Require().NoError(s.db.UpdateChallenge(earlier.ID, 80, models.ChallengeCompleted))
+
+	remove := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/challenges/"+id, http.NoBody)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.handlers.DeleteChallenge(w, s.signedIn(req))
+		return w
+	}
+	s.Equal(http.StatusNotFound, remove("2").Code, "completed")
+	s.Equal(http.StatusNotFound, remove("9").Code)
+
+	w := remove("1")
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, `hx-post="/challenges"`, "free to take on another")
+	s.Contains(body, "02 Mar – 08 Mar")
+	s.Contains(body, "1 of 1 completed")
+}
+
+// TestChallengesTestSuite runs the challenges page test suite
+func TestChallengesTestSuite(t *testing.T) {
+	suite.Run(t, new(ChallengesTestSuite))
+}
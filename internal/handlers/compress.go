@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the media types worth compressing. Images other than
+// SVG are already compressed.
+var compressibleTypes = map[string]bool{
+	"text/html":                 true,
+	"text/css":                  true,
+	"text/plain":                true,
+	"text/csv":                  true,
+	"text/javascript":           true,
+	"application/javascript":    true,
+	"application/json":          true,
+	"application/manifest+json": true,
+	"image/svg+xml":             true,
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+// Compress gzip or deflate encodes HTML, CSS, JavaScript, and JSON responses
+// for clients that accept it. Responses shorter than minSize bytes are sent
+// as they are, since compressing them saves little.
+func Compress(next http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		// Byte ranges refer to the uncompressed file
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip, or returns "" if the client accepts neither.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q, hasQ := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if hasQ {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	minSize   int
+	status    int
+	buf       []byte
+	buffering bool // The response is compressible and being buffered
+	decided   bool
+	w         io.WriteCloser // Compressor, nil when writing uncompressed
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// Informational, empty, and unchanged responses have no body to compress
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if !cw.buffering && !cw.compressible(p) {
+			cw.decide(false)
+		} else {
+			cw.buffering = true
+			cw.buf = append(cw.buf, p...)
+			if len(cw.buf) < cw.minSize {
+				return len(p), nil
+			}
+			if err := cw.flushBuffer(true); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+	if cw.w != nil {
+		return cw.w.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// compressible reports whether the response's content type is worth
+// compressing and it isn't encoded already. Like net/http, it sniffs the
+// content type from the first bytes written if the handler didn't set one.
+func (cw *compressWriter) compressible(p []byte) bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if _, ok := h["Content-Type"]; !ok {
+		h.Set("Content-Type", http.DetectContentType(p))
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if !compressibleTypes[mediaType] {
+		return false
+	}
+	h.Add("Vary", "Accept-Encoding")
+	return true
+}
+
+// decide sends the headers, with compression or without.
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	if compress {
+		h := cw.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if cw.encoding == "gzip" {
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(cw.ResponseWriter)
+			cw.w = gz
+		} else {
+			cw.w, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// flushBuffer decides on compression and writes out the buffered start of
+// the response.
+func (cw *compressWriter) flushBuffer(compress bool) error {
+	cw.decide(compress)
+	buf := cw.buf
+	cw.buf = nil
+	if cw.w != nil {
+		_, err := cw.w.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// Close sends a response that stayed below minSize uncompressed and finishes
+// the compressed stream otherwise.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 && !cw.buffering {
+			// The handler wrote nothing; let net/http send its default
+			return nil
+		}
+		return cw.flushBuffer(false)
+	}
+	if cw.w == nil {
+		return nil
+	}
+	err := cw.w.Close()
+	if gz, ok := cw.w.(*gzip.Writer); ok {
+		gzipWriters.Put(gz)
+	}
+	return err
+}
+
+// FlushError sends what was written so far, for responses streamed out a
+// part at a time. The start of the response is compressed even if it's
+// shorter than minSize, since more is to follow.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if !cw.buffering {
+			return nil
+		}
+		if err := cw.flushBuffer(true); err != nil {
+			return err
+		}
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
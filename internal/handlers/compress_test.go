@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CompressTestSuite provides a test suite for the compression middleware
+type CompressTestSuite struct {
+	suite.Suite
+}
+
+var largeCSS = strings.Repeat("body { margin: 0; }\n", 200)
+
+// serve runs a request with acceptEncoding through Compress wrapping handler.
+func (s *CompressTestSuite) serve(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/static/style.css", http.NoBody)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	Compress(handler, 1024).ServeHTTP(w, req)
+	return w
+}
+
+func writeBody(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Content-Length", "12345")
+		io.WriteString(w, body)
+	}
+}
+
+func (s *CompressTestSuite) TestGzip() {
+	w := s.serve(writeBody("text/css; charset=utf-8", largeCSS), "br, gzip, deflate")
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("gzip", w.Header().Get("Content-Encoding"))
+	s.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	s.Empty(w.Header().Get("Content-Length"), "the original length no longer applies")
+	s.Less(w.Body.Len(), len(largeCSS))
+
+	r, err := gzip.NewReader(w.Body)
+	s.Require().NoError(err)
+	body, err := io.ReadAll(r)
+	s.Require().NoError(err)
+	s.Equal(largeCSS, string(body))
+}
+
+func (s *CompressTestSuite) TestDeflate() {
+	w := s.serve(writeBody("application/json", largeCSS), "deflate, gzip;q=0")
+
+	s.Equal("deflate", w.Header().Get("Content-Encoding"))
+	body, err := io.ReadAll(flate.NewReader(w.Body))
+	s.Require().NoError(err)
+	s.Equal(largeCSS, string(body))
+}
+
+func (s *CompressTestSuite) TestSniffsHTML() {
+	page := "<!DOCTYPE html><html>" + strings.Repeat("<p>Coffee</p>", 100)
+	w := s.serve(writeBody("", page), "gzip")
+
+	s.Equal("gzip", w.Header().Get("Content-Encoding"))
+	s.Contains(w.Header().Get("Content-Type"), "text/html")
+}
+
+func (s *CompressTestSuite) TestSkipped() {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		acceptEncoding string
+	}{
+		{"not accepted", writeBody("text/css", largeCSS), ""},
+		{"identity only", writeBody("text/css", largeCSS), "identity, gzip;q=0"},
+		{"below minimum size", writeBody("text/css", "body{}"), "gzip"},
+		{"image", writeBody("image/png", largeCSS), "gzip"},
+		{"already encoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, largeCSS)
+		}, "gzip"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			w := s.serve(tt.handler, tt.acceptEncoding)
+			s.NotContains([]string{"gzip", "deflate"}, w.Header().Get("Content-Encoding"))
+			s.Equal(http.StatusOK, w.Code)
+		})
+	}
+
+	w := s.serve(writeBody("text/css", "body{}"), "gzip")
+	s.Equal("body{}", w.Body.String())
+	s.Equal("12345", w.Header().Get("Content-Length"), "small responses pass through untouched")
+}
+
+func (s *CompressTestSuite) TestKeepsStatus() {
+	w := s.serve(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, strings.Repeat("not found ", 200), http.StatusNotFound)
+	}, "gzip")
+	s.Equal(http.StatusNotFound, w.Code)
+	s.Equal("gzip", w.Header().Get("Content-Encoding"))
+
+	w = s.serve(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}, "gzip")
+	s.Equal(http.StatusFound, w.Code)
+	s.Equal("/login", w.Header().Get("Location"))
+
+	w = s.serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, "gzip")
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Empty(w.Header().Get("Content-Encoding"))
+}
+
+func (s *CompressTestSuite) TestFlush() {
+	// A streamed response is compressed as it goes, from the first flush
+	w := httptest.NewRecorder()
+	var flushed string
+	handler := func(cw http.ResponseWriter, r *http.Request) {
+		cw.Header().Set("Content-Type", "text/csv")
+		io.WriteString(cw, "date,amount\n")
+		s.Require().NoError(http.NewResponseController(cw).Flush())
+		s.True(w.Flushed)
+		part, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		s.Require().NoError(err)
+		data, _ := io.ReadAll(part)
+		flushed = string(data)
+		io.WriteString(cw, "2026-03-02,4.50\n")
+	}
+	req := httptest.NewRequest("GET", "/expenses/export", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	Compress(http.HandlerFunc(handler), 1024).ServeHTTP(w, req)
+
+	s.Equal("gzip", w.Header().Get("Content-Encoding"))
+	s.Equal("date,amount\n", flushed, "the first part can be read before the rest is written")
+	r, err := gzip.NewReader(w.Body)
+	s.Require().NoError(err)
+	body, err := io.ReadAll(r)
+	s.Require().NoError(err)
+	s.Equal("date,amount\n2026-03-02,4.50\n", string(body))
+}
+
+// TestCompressSuite runs the compression middleware test suite
+func TestCompressSuite(t *testing.T) {
+	suite.Run(t, new(CompressTestSuite))
+}
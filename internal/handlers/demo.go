@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// demoAccount is the account visitors of a public demo sign in with.
+type demoAccount struct {
+	username string
+	password string
+}
+
+// WithDemo runs the app as a public demo: the login page shows the demo
+// account's credentials, and account settings can't be changed.
+func WithDemo(username, password string) Option {
+	return func(h *Handlers) { h.demo = &demoAccount{username: username, password: password} }
+}
+
+// DemoMiddleware refuses changes to account settings in demo mode, so
+// visitors can't lock each other out by changing or deleting the shared
+// account. Expenses can still be added, edited, and deleted.
+func (h *Handlers) DemoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.demo != nil && r.Method != http.MethodGet && r.Method != http.MethodHead &&
+			(r.URL.Path == "/settings" || strings.HasPrefix(r.URL.Path, "/settings/") || r.URL.Path == "/register" || strings.HasPrefix(r.URL.Path, "/invite/")) {
+			http.Error(w, "Settings can't be changed in the demo", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DemoTestSuite provides a test suite for demo mode
+type DemoTestSuite struct {
+	suite.Suite
+	db *storage.DB
+	h  *Handlers
+}
+
+// SetupTest runs before each test
+func (s *DemoTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.h = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithDemo("demo", "secret"))
+}
+
+// TearDownTest runs after each test
+func (s *DemoTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *DemoTestSuite) serve(method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.h.DemoMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(w, httptest.NewRequest(method, path, http.NoBody))
+	return w
+}
+
+func (s *DemoTestSuite) TestBlocksSettingsChanges() {
+	for _, path := range []string{"/settings/password", "/settings/delete", "/register", "/invite/abc"} {
+		w := s.serve("POST", path)
+		s.Equal(http.StatusForbidden, w.Code, path)
+		s.Contains(w.Body.String(), "can't be changed in the demo")
+	}
+
+	s.Equal(http.StatusNoContent, s.serve("GET", "/settings/security").Code, "settings can be viewed")
+	s.Equal(http.StatusNoContent, s.serve("POST", "/expenses").Code, "expenses can be added")
+	s.Equal(http.StatusNoContent, s.serve("DELETE", "/expenses/1").Code)
+	s.Equal(http.StatusNoContent, s.serve("POST", "/login").Code)
+}
+
+func (s *DemoTestSuite) TestLoginShowsCredentials() {
+	w := httptest.NewRecorder()
+	s.h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "secret")
+	s.Contains(w.Body.String(), `value="demo"`)
+}
+
+func (s *DemoTestSuite) TestOffByDefault() {
+	s.h = NewHandlers(s.db, WithTemplateFS(os.DirFS("../../web/templates")))
+	s.Equal(http.StatusNoContent, s.serve("POST", "/settings/password").Code)
+
+	w := httptest.NewRecorder()
+	s.h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.NotContains(w.Body.String(), `value="demo"`)
+}
+
+// TestDemoSuite runs the demo mode test suite
+func TestDemoSuite(t *testing.T) {
+	suite.Run(t, new(DemoTestSuite))
+}
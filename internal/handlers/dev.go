@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// devPollInterval is how often development mode checks the web directory
+// for changes.
+const devPollInterval = 500 * time.Millisecond
+
+// devReloader tells open pages to reload when templates or static files
+// change.
+type devReloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+// WithDevMode makes pages reload themselves when Watch sees templates or
+// static files change, and turns off the service worker, which would serve
+// stale copies.
+func WithDevMode() Option {
+	return func(h *Handlers) { h.dev = &devReloader{clients: map[chan struct{}]struct{}{}} }
+}
+
+// Watch polls dir until ctx is cancelled, and when a file in it changes,
+// reparses the templates and reloads open pages. It only works in
+// development mode.
+func (h *Handlers) Watch(ctx context.Context, dir fs.FS) {
+	if h.dev == nil {
+		return
+	}
+	ticker := time.NewTicker(devPollInterval)
+	defer ticker.Stop()
+	last, _ := snapshot(dir)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := snapshot(dir)
+		if err != nil {
+			h.logger.Printf("Watching web files failed: %v", err)
+			continue
+		}
+		if current == last {
+			continue
+		}
+		last = current
+		h.logger.Println("Web files changed, reloading")
+		h.ReloadTemplates()
+		h.dev.notify()
+	}
+}
+
+// snapshot summarizes the names, sizes, and modification times of the files
+// in dir, so comparing two snapshots reveals changes.
+func snapshot(dir fs.FS) (string, error) {
+	var summary []byte
+	err := fs.WalkDir(dir, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		summary = fmt.Appendf(summary, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return string(summary), err
+}
+
+// notify tells every open page to reload.
+func (d *devReloader) notify() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for c := range d.clients {
+		select {
+		case c <- struct{}{}:
+		default: // A reload is already pending
+		}
+	}
+}
+
+// DevReload streams a server-sent event to the page whenever it should
+// reload, for development mode.
+func (h *Handlers) DevReload(w http.ResponseWriter, r *http.Request) {
+	if h.dev == nil {
+		http.NotFound(w, r)
+		return
+	}
+	c := make(chan struct{}, 1)
+	h.dev.mu.Lock()
+	h.dev.clients[c] = struct{}{}
+	h.dev.mu.Unlock()
+	defer func() {
+		h.dev.mu.Lock()
+		delete(h.dev.clients, c)
+		h.dev.mu.Unlock()
+	}()
+
+	// The stream stays open far longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, ": connected\n\n")
+	_ = rc.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "data: reload\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NoCache stops browsers from caching any response, so edits to templates
+// and static files show up on the next load in development mode.
+func NoCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
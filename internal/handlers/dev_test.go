@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DevTestSuite provides a test suite for development mode
+type DevTestSuite struct {
+	suite.Suite
+	db *storage.DB
+}
+
+// SetupTest runs before each test
+func (s *DevTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *DevTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *DevTestSuite) TestTemplatesAreCachedUntilReloaded() {
+	templates := fstest.MapFS{
+		"base.html": {Data: []byte(`{{block "content" .}}{{end}}`)},
+		"page.html": {Data: []byte(`{{define "content"}}first{{end}}`)},
+	}
+	h := NewHandlers(s.db, WithTemplateFS(templates))
+	render := func() string {
+		w := httptest.NewRecorder()
+		h.render(w, httptest.NewRequest("GET", "/", http.NoBody), "page.html", nil)
+		return w.Body.String()
+	}
+
+	s.Equal("first", render())
+	templates["page.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}second{{end}}`)}
+	s.Equal("first", render())
+	h.ReloadTemplates()
+	s.Equal("second", render())
+}
+
+func (s *DevTestSuite) TestReloadsPagesOnChange() {
+	dir := s.T().TempDir()
+	file := filepath.Join(dir, "style.css")
+	s.Require().NoError(os.WriteFile(file, []byte("body {}"), 0o644))
+
+	h := NewHandlers(s.db, WithTemplateFS(os.DirFS("../../web/templates")), WithDevMode())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, os.DirFS(dir))
+
+	srv := httptest.NewServer(http.HandlerFunc(h.DevReload))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewReader(resp.Body)
+	line, err := lines.ReadString('\n')
+	s.Require().NoError(err)
+	s.Equal(": connected\n", line)
+
+	// Let the watcher take its first snapshot
+	time.Sleep(2 * devPollInterval)
+	s.Require().NoError(os.WriteFile(file, []byte("body { color: red }"), 0o644))
+	for {
+		line, err = lines.ReadString('\n')
+		s.Require().NoError(err)
+		if strings.HasPrefix(line, "data:") {
+			break
+		}
+	}
+	s.Equal("data: reload\n", line)
+}
+
+func (s *DevTestSuite) TestOffByDefault() {
+	h := NewHandlers(s.db, WithTemplateFS(os.DirFS("../../web/templates")))
+	w := httptest.NewRecorder()
+	h.DevReload(w, httptest.NewRequest("GET", "/dev/reload", http.NoBody))
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.Contains(w.Body.String(), "serviceWorker.register")
+	s.NotContains(w.Body.String(), "/dev/reload")
+}
+
+func (s *DevTestSuite) TestNoCache() {
+	w := httptest.NewRecorder()
+	NoCache(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest("GET", "/static/style.css", http.NoBody))
+	s.Equal("no-store", w.Header().Get("Cache-Control"))
+}
+
+// TestDevSuite runs the development mode test suite
+func TestDevSuite(t *testing.T) {
+	suite.Run(t, new(DevTestSuite))
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"expense-tracker/internal/events"
 	"expense-tracker/internal/models"
-	"log"
+	"expense-tracker/internal/storage"
+	"math"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ListExpenses renders the list of expenses.
@@ -16,66 +23,383 @@ func (h *Handlers) ListExpenses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expenses, err := h.db.ListExpenses()
+	// Restrict to the selected period, or the current budget period if none
+	// was picked, optionally narrowed by category and search text
+	from, to, err := parseDateRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
 	if err != nil {
-		log.Printf("ListExpenses error: %v", err)
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+	if h.notModified(w, r) {
+		return
+	}
+	inPeriod := !from.IsZero() || !to.IsZero()
+	filter := storage.ExpenseFilter{
+		From:     from,
+		To:       to,
+		Category: r.URL.Query().Get("category"),
+		Query:    strings.TrimSpace(r.URL.Query().Get("q")),
+		Ledger:   h.ledger(r),
+	}
+	now := h.userNow(r)
+	if !inPeriod {
+		filter.From = cycleStart(now, h.preferences(r).CycleStart)
+	}
+	byWeek := r.URL.Query().Get("group") == "week"
+	viewModel, err := h.buildListView(h.store(r), filter, byWeek, user.ID, now)
+	if err != nil {
+		h.logger.Printf("ListExpenses error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if inPeriod {
+		viewModel.From = r.URL.Query().Get("from")
+		viewModel.To = r.URL.Query().Get("to")
+		// A picked period is shown as is, without scrolling into older months
+		viewModel.HasMore = false
+	}
+	// Whole calendar months can be stepped through, like the statistics
+	if month, ok := shownMonth(from, to, now); ok {
+		viewModel.Month = month.Format("January 2006")
+		viewModel.PrevMonth = viewModel.monthURL(month.AddDate(0, -1, 0))
+		if next := month.AddDate(0, 1, 0); !next.After(now) {
+			viewModel.NextMonth = viewModel.monthURL(next)
+		}
+	}
+	summary, err := h.store(r).SummarizeExpenses(filter)
+	if err != nil {
+		h.logger.Printf("ListExpenses error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	viewModel.Count = summary.Count
+	viewModel.AvgPerDay = summary.AveragePerDay
+	if summary.Largest != nil {
+		largest := newExpenseItem(*summary.Largest, user.ID)
+		viewModel.Largest = &largest
+	}
+	// The current budget period's spending against the budget, which
+	// a picked period has nothing to do with
+	if budget := h.preferences(r).Budget; budget > 0 && !inPeriod {
+		spent, err := h.store(r).GetTotalForCycle(filter.Ledger, filter.From)
+		if err != nil {
+			h.logger.Printf("ListExpenses error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		viewModel.Budget = &BudgetProgress{
+			Spent:   spent,
+			Budget:  budget,
+			Percent: min(spent/budget*100, 100),
+			Over:    spent > budget,
+		}
+	}
+	h.listHeader(r, &viewModel)
+
+	// Right after a deletion, offer to undo it
+	if token := r.URL.Query().Get("undo"); token != "" {
+		if _, err := h.parseUndoToken(token, user.ID); err == nil {
+			viewModel.UndoToken = token
+		}
+	}
+
+	// The search box only swaps the results, keeping the input focused
+	if r.Header.Get("HX-Target") == "expense-results" {
+		h.renderPartial(w, r, "list.html", "results", viewModel)
+		return
+	}
+	h.render(w, r, "list.html", viewModel)
+}
+
+// ExpenseHistory renders the groups of an earlier month, appended to the
+// list by infinite scroll.
+func (h *Handlers) ExpenseHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	year, yearErr := strconv.Atoi(r.URL.Query().Get("year"))
+	month, monthErr := strconv.Atoi(r.URL.Query().Get("month"))
+	if yearErr != nil || monthErr != nil || month < 1 || month > 12 {
+		http.Error(w, "Invalid month", http.StatusBadRequest)
+		return
+	}
+
+	if h.notModified(w, r) {
+		return
+	}
+
+	now := h.userNow(r)
+	startOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, now.Location())
+	filter := storage.ExpenseFilter{
+		From:     startOfMonth,
+		To:       startOfMonth.AddDate(0, 1, 0),
+		Category: r.URL.Query().Get("category"),
+		Query:    strings.TrimSpace(r.URL.Query().Get("q")),
+		Ledger:   h.ledger(r),
+	}
+	// The list already shows the current budget period, which may start
+	// within the month
+	if current := cycleStart(now, h.preferences(r).CycleStart); filter.To.After(current) {
+		filter.To = current
+	}
+	byWeek := r.URL.Query().Get("group") == "week"
+	viewModel, err := h.buildListView(h.store(r), filter, byWeek, user.ID, now)
+	if err != nil {
+		h.logger.Printf("ExpenseHistory error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	viewModel.MonthTitle = startOfMonth.Format("January 2006")
+
+	h.renderPartial(w, r, "list.html", "history", viewModel)
+}
+
+// listHeader fills in the ledger switcher of the list's header, which the
+// list can do without; guests only see the ledger they were given.
+func (h *Handlers) listHeader(r *http.Request, viewModel *ListViewModel) {
+	viewModel.Ledger = h.ledger(r)
+	viewModel.Guest = guest(r) != nil
+	if !viewModel.Guest {
+		if ledgers, err := h.store(r).ListLedgers(requestUserID(r)); err == nil {
+			viewModel.Ledgers, _ = splitArchived(ledgers)
+		} else {
+			h.logger.Printf("ListLedgers error: %v", err)
+		}
+	}
+}
+
+// searchLimit is the most expenses a search shows.
+const searchLimit = 200
 
+// SearchExpenses lists the expenses of the ledger shown whose description or
+// category contains every word searched for, from all months, grouped by
+// day like the list.
+func (h *Handlers) SearchExpenses(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.notModified(w, r) {
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var expenses []models.Expense
+	if query != "" {
+		var err error
+		// One more than shown, to tell whether there are more
+		expenses, err = h.store(r).SearchExpenses(query, h.ledger(r), searchLimit+1)
+		if err != nil {
+			h.logger.Printf("SearchExpenses error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	viewModel := ListViewModel{
+		Search:  true,
+		Query:   query,
+		Limited: len(expenses) > searchLimit,
+		UserID:  user.ID,
+	}
+	expenses = expenses[:min(len(expenses), searchLimit)]
+	viewModel.Groups, viewModel.Total = groupExpenses(expenses, false, user.ID, h.userNow(r))
+	viewModel.Count = len(expenses)
+	h.listHeader(r, &viewModel)
+
+	// The search box only swaps the results, keeping the input focused
+	if r.Header.Get("HX-Target") == "expense-results" {
+		h.renderPartial(w, r, "list.html", "search", viewModel)
+		return
+	}
+	h.render(w, r, "list.html", viewModel)
+}
+
+// shownMonth returns the first day of the calendar month the list shows:
+// now's month without a picked period, or the picked one if it spans exactly
+// a month. It reports false for other periods.
+func shownMonth(from, to, now time.Time) (time.Time, bool) {
+	if from.IsZero() && to.IsZero() {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), true
+	}
+	if from.Day() == 1 && to.Equal(from.AddDate(0, 1, 0)) {
+		return from, true
+	}
+	return time.Time{}, false
+}
+
+// parseDateRange parses the inclusive from/to dates (YYYY-MM-DD) of the list's
+// period picker. Either may be empty for an open-ended range. The returned
+// upper bound is exclusive, for use in an ExpenseFilter.
+func parseDateRange(fromStr, toStr string) (from, to time.Time, err error) {
+	if fromStr != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, fromStr, time.Local); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if toStr != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, toStr, time.Local); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from date is after to date")
+	}
+	return from, to, nil
+}
+
+// buildListView loads the expenses matching a filter and groups them by day,
+// or by ISO week if byWeek is set. It also looks up the most recent earlier
+// month with matching expenses so the list can offer to load it. Days are
+// titled relative to now.
+func (h *Handlers) buildListView(db Store, filter storage.ExpenseFilter, byWeek bool, userID int64, now time.Time) (ListViewModel, error) {
+	expenses, err := db.FilterExpenses(filter)
+	if err != nil {
+		return ListViewModel{}, err
+	}
+	groups, total := groupExpenses(expenses, byWeek, userID, now)
+
+	viewModel := ListViewModel{
+		Total:      total,
+		Groups:     groups,
+		Category:   filter.Category,
+		Query:      filter.Query,
+		ByWeek:     byWeek,
+		Categories: h.categoryDefs(db),
+		UserID:     userID,
+	}
+
+	older := filter
+	older.From, older.To = time.Time{}, filter.From
+	latest, err := db.LatestExpenseDate(older)
+	if err != nil {
+		return ListViewModel{}, err
+	}
+	if !latest.IsZero() {
+		viewModel.HasMore = true
+		viewModel.MoreYear = latest.Year()
+		viewModel.MoreMonth = int(latest.Month())
+	}
+
+	return viewModel, nil
+}
+
+// groupExpenses groups expenses by day, or by ISO week (Monday to Sunday) if
+// byWeek is set, newest first, and returns the groups along with the overall
+// total. Weekly items show the weekday alongside the time, and the days of
+// now and the day before are titled TODAY and YESTERDAY.
+func groupExpenses(expenses []models.Expense, byWeek bool, userID int64, now time.Time) (groups []ExpenseGroup, total float64) {
 	groupsMap := make(map[string]*ExpenseGroup)
-	var totalSpent float64
 
 	for _, e := range expenses {
-		dateStr := e.Date.Format("2006-01-02")
-		if _, ok := groupsMap[dateStr]; !ok {
-			groupsMap[dateStr] = &ExpenseGroup{Date: dateStr, Title: formatGroupTitle(e.Date)}
+		start, timeFormat := e.Date, "15:04"
+		if byWeek {
+			start, timeFormat = startOfISOWeek(e.Date), "Mon 15:04"
 		}
-		group := groupsMap[dateStr]
+		key := start.Format("2006-01-02")
+		if _, ok := groupsMap[key]; !ok {
+			title := formatGroupTitle(start, now)
+			if byWeek {
+				title = formatWeekTitle(start)
+			}
+			groupsMap[key] = &ExpenseGroup{Date: key, Title: title}
+		}
+		group := groupsMap[key]
 		group.Total += e.Amount
-		totalSpent += e.Amount
+		total += e.Amount
 
-		// Check if this expense was created by a different user
-		isOtherUser := e.UserID != nil && *e.UserID != user.ID
-
-		group.Items = append(group.Items, ExpenseItem{
-			ID:            e.ID,
-			Amount:        e.Amount,
-			Description:   e.Description,
-			Category:      e.Category,
-			Time:          e.Date.Format("15:04"),
-			DateTime:      e.Date.Format("2006-01-02T15:04:05"),
-			CategoryStyle: getCategoryStyle(e.Category),
-			IsOtherUser:   isOtherUser,
-		})
+		item := newExpenseItem(e, userID)
+		item.Time = e.Date.Format(timeFormat)
+		group.Items = append(group.Items, item)
 	}
 
-	groups := make([]ExpenseGroup, 0, len(groupsMap))
+	groups = make([]ExpenseGroup, 0, len(groupsMap))
 	for _, g := range groupsMap {
 		groups = append(groups, *g)
 	}
 	sort.Slice(groups, func(i, j int) bool { return groups[i].Date > groups[j].Date })
 
-	h.render(w, r, "list.html", ListViewModel{Total: totalSpent, Groups: groups})
+	return groups, total
+}
+
+// newExpenseItem converts an expense for display to the given user.
+func newExpenseItem(e models.Expense, userID int64) ExpenseItem {
+	item := ExpenseItem{
+		ID:            e.ID,
+		Amount:        e.Amount,
+		Description:   e.Description,
+		Category:      e.Category,
+		Time:          e.Date.Format("15:04"),
+		DateTime:      e.Date.Format("2006-01-02T15:04:05"),
+		CategoryStyle: getCategoryStyle(e.Category),
+		// Check if this expense was created by a different user
+		IsOtherUser: e.UserID != nil && *e.UserID != userID,
+	}
+	if e.UserID != nil {
+		item.OwnerID = *e.UserID
+	}
+	return item
 }
 
-// CreateExpenseForm renders the form to create a new expense.
+// CreateExpenseForm renders the form to create a new expense, pre-filled
+// with the user's default category, or else the one they use most, and
+// their current time.
 func (h *Handlers) CreateExpenseForm(w http.ResponseWriter, r *http.Request) {
+	defs := h.pickerCategories(r)
+	now := h.userNow(r)
+	expense := &models.Expense{Date: now}
+	if len(defs) > 0 {
+		expense.Category = defs[0].Name
+	}
+	// The user's pick while it still exists
+	if name := h.preferences(r).DefaultCategory; name != "" && slices.ContainsFunc(defs, func(c CategoryDef) bool { return c.Name == name }) {
+		expense.Category = name
+	}
 	h.render(w, r, "create.html", FormViewModel{
-		IsEdit:     false,
-		Categories: categories,
+		Expense:       expense,
+		IsEdit:        false,
+		FormattedDate: now.Format("2006-01-02T15:04:05"),
+		Categories:    defs,
 	})
 }
 
+// visibleExpense returns an expense in a ledger the request's user sees,
+// sql.ErrNoRows if it doesn't exist, or errHiddenLedger if it's in another
+// user's ledger.
+func (h *Handlers) visibleExpense(r *http.Request, id int64) (*models.Expense, error) {
+	expense, err := h.store(r).GetExpense(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.visibleLedger(r, expense.LedgerID); err != nil {
+		return nil, err
+	}
+	return expense, nil
+}
+
+// expenseError responds to an error of visibleExpense.
+func (h *Handlers) expenseError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Printf("GetExpense error: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
 // EditExpenseForm renders the form to edit an existing expense.
 func (h *Handlers) EditExpenseForm(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
-	if expense, err := h.db.GetExpense(id); err == nil {
+	if expense, err := h.visibleExpense(r, id); err == nil {
 		h.render(w, r, "create.html", FormViewModel{
 			Expense:       expense,
 			IsEdit:        true,
 			FormattedDate: expense.Date.Format("2006-01-02T15:04:05"),
-			Categories:    categories,
+			Categories:    h.pickerCategories(r),
 		})
 	} else {
 		http.Error(w, "Expense not found", http.StatusNotFound)
@@ -89,6 +413,16 @@ func (h *Handlers) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	loc, _, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	projectID, _, err := h.parseProject(r, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	user, ok := r.Context().Value(UserContextKey).(*models.User)
 	if !ok {
@@ -96,39 +430,210 @@ func (h *Handlers) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.CreateExpense(amount, desc, cat, date, user.ID); err != nil {
-		log.Printf("CreateExpense error: %v", err)
+	expense := &models.Expense{Amount: amount, Description: desc, Category: cat, Date: date, UserID: &user.ID, LedgerID: h.ledger(r)}
+	reimbursable, _ := parseReimbursable(r)
+	details := storage.ExpenseDetails{Location: loc, Reimbursable: reimbursable, ProjectID: projectID}
+	if err := h.store(r).InsertExpenseWithDetails(expense, details); err != nil {
+		h.logger.Printf("CreateExpense error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.publishExpense(r, events.ExpenseCreated, expense.ID)
 	w.Header().Set("HX-Location", `{"path":"/expenses", "target":"#content"}`)
 }
 
 // UpdateExpense handles the update of an existing expense.
 func (h *Handlers) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if _, err := h.visibleExpense(r, id); err != nil {
+		h.expenseError(w, err)
+		return
+	}
 	amount, desc, cat, date, err := parseForm(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := h.db.UpdateExpense(&models.Expense{
+	loc, hasLocation, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	projectID, hasProject, err := h.parseProject(r, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.store(r).UpdateExpense(&models.Expense{
 		ID: id, Amount: amount, Description: desc, Category: cat, Date: date,
 	}); err != nil {
-		log.Printf("UpdateExpense error: %v", err)
+		h.logger.Printf("UpdateExpense error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if hasLocation {
+		if err := h.store(r).SetExpenseLocation(id, loc); err != nil {
+			h.logger.Printf("SetExpenseLocation error: %v", err)
+		}
+	}
+	if reimbursable, ok := parseReimbursable(r); ok {
+		if err := h.saveReimbursable(h.store(r), id, reimbursable); err != nil {
+			h.logger.Printf("SetReimbursement error: %v", err)
+		}
+	}
+	if hasProject {
+		if err := h.store(r).SetExpenseProject(id, projectID); err != nil {
+			h.logger.Printf("SetExpenseProject error: %v", err)
+		}
+	}
+	h.publishExpense(r, events.ExpenseUpdated, id)
 	w.Header().Set("HX-Location", `{"path":"/expenses", "target":"#content"}`)
 }
 
-// DeleteExpense handles the deletion of an expense.
+// ExpenseDetails returns the details of an expense kept apart from it as
+// JSON, i.e. where it was made, its reimbursement state, and its project,
+// for the expense form to show when editing it.
+func (h *Handlers) ExpenseDetails(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	db := h.store(r)
+	if _, err := h.visibleExpense(r, id); err != nil {
+		h.expenseError(w, err)
+		return
+	}
+	loc, err := db.GetExpenseLocation(id)
+	if err != nil {
+		h.logger.Printf("GetExpenseLocation error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	status, err := db.GetReimbursement(id)
+	if err != nil {
+		h.logger.Printf("GetReimbursement error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	projectID, err := db.GetExpenseProject(id)
+	if err != nil {
+		h.logger.Printf("GetExpenseProject error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	type location struct {
+		models.Location
+		MapURL string `json:"map_url,omitempty"`
+	}
+	writeJSON(w, struct {
+		Location      location `json:"location"`
+		Reimbursement string   `json:"reimbursement,omitempty"`
+		Project       int64    `json:"project,omitempty"`
+	}{location{loc, mapURL(loc)}, status, projectID})
+}
+
+// ExpenseRow renders a single expense row of the list, e.g. to cancel an
+// inline edit.
+func (h *Handlers) ExpenseRow(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	expense, err := h.visibleExpense(r, id)
+	if err != nil {
+		h.expenseError(w, err)
+		return
+	}
+	h.renderPartial(w, r, "list.html", "item", newExpenseItem(*expense, user.ID))
+}
+
+// InlineEditForm renders the row of an expense as a form for editing its
+// amount and description in place.
+func (h *Handlers) InlineEditForm(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	expense, err := h.visibleExpense(r, id)
+	if err != nil {
+		h.expenseError(w, err)
+		return
+	}
+	h.renderPartial(w, r, "list.html", "item-edit", InlineEditViewModel{
+		Item:        newExpenseItem(*expense, user.ID),
+		Amount:      strconv.FormatFloat(expense.Amount, 'f', 2, 64),
+		Description: expense.Description,
+	})
+}
+
+// UpdateExpenseInline saves the amount and description from an inline edit
+// row. Invalid input re-renders the form with an error message; on success the
+// updated row is returned and the list is told to refresh its totals.
+func (h *Handlers) UpdateExpenseInline(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	expense, err := h.visibleExpense(r, id)
+	if err != nil {
+		h.expenseError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	viewModel := InlineEditViewModel{
+		Item:        newExpenseItem(*expense, user.ID),
+		Amount:      strings.TrimSpace(r.FormValue("amount")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(viewModel.Amount, ",", ".", 1), 64)
+	switch {
+	case err != nil || amount <= 0 || math.IsInf(amount, 0):
+		viewModel.Error = "Enter an amount greater than zero"
+	case viewModel.Description == "":
+		viewModel.Error = "Enter a description"
+	}
+	if viewModel.Error != "" {
+		// htmx only swaps successful responses, so the error row is sent as 200
+		h.renderPartial(w, r, "list.html", "item-edit", viewModel)
+		return
+	}
+
+	expense.Amount = amount
+	expense.Description = viewModel.Description
+	if err := h.store(r).UpdateExpense(expense); err != nil {
+		h.logger.Printf("UpdateExpenseInline error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.publishExpense(r, events.ExpenseUpdated, id)
+	w.Header().Set("HX-Trigger", "expenses-changed")
+	h.renderPartial(w, r, "list.html", "item", newExpenseItem(*expense, user.ID))
+}
+
+// DeleteExpense handles the deletion of an expense. For UndoWindow the list
+// offers to undo it.
 func (h *Handlers) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
-	if err := h.db.DeleteExpense(id); err != nil {
-		log.Printf("DeleteExpense error: %v", err)
+	if _, err := h.visibleExpense(r, id); err != nil {
+		h.expenseError(w, err)
+		return
+	}
+	if err := h.store(r).DeleteExpense(id); err != nil {
+		h.logger.Printf("DeleteExpense error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.publishExpense(r, events.ExpenseDeleted, id)
+	if user, ok := r.Context().Value(UserContextKey).(*models.User); ok {
+		w.Header().Set("HX-Location", h.undoLocation(user.ID, id))
+		return
+	}
 	w.Header().Set("HX-Location", `{"path":"/expenses", "target":"#content"}`)
 }
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"expense-tracker/internal/models"
 	"expense-tracker/internal/storage"
+	"expense-tracker/internal/storage/memstore"
+	"fmt"
+	"io/fs"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -18,8 +23,8 @@ import (
 // ExpenseHandlerTestSuite provides a test suite for expense handler tests
 type ExpenseHandlerTestSuite struct {
 	suite.Suite
-	db          *storage.DB
-	templateDir string
+	db        *storage.DB
+	templates fs.FS
 }
 
 // SetupTest runs before each test
@@ -28,10 +33,11 @@ func (s *ExpenseHandlerTestSuite) SetupTest() {
 	s.Require().NoError(err, "failed to create test database")
 	s.db = db
 
-	s.templateDir = "../../web/templates"
-	if _, err := os.Stat(s.templateDir); os.IsNotExist(err) {
+	templateDir := "../../web/templates"
+	if _, err := os.Stat(templateDir); os.IsNotExist(err) {
 		s.T().Skip("Template directory not found, skipping handler integration test")
 	}
+	s.templates = os.DirFS(templateDir)
 }
 
 // TearDownTest runs after each test
@@ -47,7 +53,7 @@ func (s *ExpenseHandlerTestSuite) addUserContext(req *http.Request) *http.Reques
 }
 
 func (s *ExpenseHandlerTestSuite) TestListExpenses() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	req := httptest.NewRequest("GET", "/expenses", http.NoBody)
 	req = s.addUserContext(req)
@@ -64,7 +70,7 @@ func (s *ExpenseHandlerTestSuite) TestListExpenses() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestListExpenses_Unauthorized() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Request without user context should return 401
 	req := httptest.NewRequest("GET", "/expenses", http.NoBody)
@@ -77,7 +83,7 @@ func (s *ExpenseHandlerTestSuite) TestListExpenses_Unauthorized() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestListExpenses_HighlightOtherUsersExpenses() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Create user 1 first (the current user)
 	user1, err := s.db.CreateUser("testuser", "password123")
@@ -135,8 +141,111 @@ func (s *ExpenseHandlerTestSuite) TestListExpenses_HighlightOtherUsersExpenses()
 	s.Contains(articleSection, "floralwhite", "floralwhite should be in the Other User's expense article tag")
 }
 
+func (s *ExpenseHandlerTestSuite) TestListExpenses_CategoryFilter() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	now := time.Now()
+	s.Require().NoError(s.db.CreateExpense(12.00, "Weekly Shop", "Groceries", now, 1))
+	s.Require().NoError(s.db.CreateExpense(3.00, "Bus Ticket", "Transport", now.Add(-time.Minute), 1))
+
+	req := httptest.NewRequest("GET", "/expenses?category=Groceries", http.NoBody)
+	req = s.addUserContext(req)
+	w := httptest.NewRecorder()
+
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Weekly Shop")
+	s.NotContains(body, "Bus Ticket", "other categories should be filtered out")
+	s.Contains(body, "Spent this month on Groceries")
+	s.Contains(body, `class="chip active"`, "the selected category chip should be highlighted")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_SearchPartial() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	now := time.Now()
+	s.Require().NoError(s.db.CreateExpense(12.00, "Pharmacy", "Health", now, 1))
+	s.Require().NoError(s.db.CreateExpense(3.00, "Bus Ticket", "Transport", now.Add(-time.Minute), 1))
+
+	req := httptest.NewRequest("GET", "/expenses?q=pharm", http.NoBody)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Target", "expense-results")
+	req = s.addUserContext(req)
+	w := httptest.NewRecorder()
+
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Pharmacy")
+	s.NotContains(body, "Bus Ticket")
+	s.Contains(body, "12.00")
+	s.NotContains(body, "search-input", "the partial should not re-render the search box")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_SummaryStats() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	now := time.Now()
+	s.Require().NoError(s.db.CreateExpense(12.00, "Weekly Shop", "Groceries", now, 1))
+	s.Require().NoError(s.db.CreateExpense(48.00, "Concert", "Entertainment", now.Add(-time.Minute), 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "2 expenses")
+	s.Contains(body, fmt.Sprintf("€%.2f / day", 60.00/float64(now.Day())))
+	s.Contains(body, "Largest: Concert €48.00")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_LoadMoreHistory() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	// Leave a gap month so the sentinel has to skip straight to the older data
+	now := time.Now()
+	older := time.Date(now.Year(), now.Month(), 10, 12, 0, 0, 0, time.Local).AddDate(0, -2, 0)
+	oldest := older.AddDate(0, -1, 0)
+	s.Require().NoError(s.db.CreateExpense(9.00, "Older Expense", "Groceries", older, 1))
+	s.Require().NoError(s.db.CreateExpense(7.00, "Oldest Expense", "Groceries", oldest, 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.NotContains(w.Body.String(), "Older Expense")
+	s.Contains(w.Body.String(), fmt.Sprintf("/expenses/history?year=%d&month=%d", older.Year(), int(older.Month())))
+
+	url := fmt.Sprintf("/expenses/history?year=%d&month=%d", older.Year(), int(older.Month()))
+	req = s.addUserContext(httptest.NewRequest("GET", url, http.NoBody))
+	w = httptest.NewRecorder()
+	h.ExpenseHistory(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, older.Format("January 2006"))
+	s.Contains(body, "Older Expense")
+	s.NotContains(body, "Oldest Expense")
+	s.Contains(body, fmt.Sprintf("/expenses/history?year=%d&month=%d", oldest.Year(), int(oldest.Month())))
+}
+
+func (s *ExpenseHandlerTestSuite) TestExpenseHistory_InvalidMonth() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses/history?year=2026&month=13", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ExpenseHistory(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
 func (s *ExpenseHandlerTestSuite) TestCreateExpense() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Simulate form submission with current month's date
 	form := url.Values{}
@@ -169,7 +278,7 @@ func (s *ExpenseHandlerTestSuite) TestCreateExpense() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestCreateExpense_LegacyFormat() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	form := url.Values{}
 	form.Add("amount", "20.00")
@@ -194,7 +303,7 @@ func (s *ExpenseHandlerTestSuite) TestCreateExpense_LegacyFormat() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestCreateExpense_MissingDate() {
-	h := NewHandlers(s.db, "dummy_path", false)
+	h := NewHandlers(s.db, WithTemplateFS(os.DirFS("dummy_path")))
 
 	form := url.Values{}
 	form.Add("amount", "15.00")
@@ -214,7 +323,7 @@ func (s *ExpenseHandlerTestSuite) TestCreateExpense_MissingDate() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_CurrentMonth() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// No query params should default to current month
 	req := httptest.NewRequest("GET", "/statistics", http.NoBody)
@@ -231,7 +340,7 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_CurrentMonth() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_WithExpenses() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Create test expenses for January 2026
 	testExpenses := []struct {
@@ -271,11 +380,12 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_WithExpenses() {
 	s.Contains(body, "groceries", "should show groceries category")
 	s.Contains(body, "transport", "should show transport category")
 	s.Contains(body, "eating out", "should show eating out category")
-	s.Contains(body, "Groceries", "should show expense descriptions")
+	s.NotContains(body, "More Groceries", "expenses are fetched when a category is expanded")
+	s.Contains(body, "/statistics/expenses?year=2026&month=1&category=eating&#43;out")
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_EmptyMonth() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Request statistics for a month with no expenses
 	req := httptest.NewRequest("GET", "/statistics?year=2025&month=5", http.NoBody)
@@ -293,7 +403,7 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_EmptyMonth() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_MonthNavigation() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Request statistics for November 2025 (a past month)
 	req := httptest.NewRequest("GET", "/statistics?year=2025&month=11", http.NoBody)
@@ -312,7 +422,7 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_MonthNavigation() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_CategoryPercentages() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Create expenses with known percentages
 	// Total will be 100, so percentages are easy to verify
@@ -346,7 +456,7 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_CategoryPercentages() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_InvalidMonth() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Request with invalid month should default to current month
 	req := httptest.NewRequest("GET", "/statistics?year=2026&month=13", http.NoBody)
@@ -360,7 +470,7 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_InvalidMonth() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestStatistics_TransactionCount() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Create multiple expenses in same category
 	for i := 1; i <= 3; i++ {
@@ -380,8 +490,56 @@ func (s *ExpenseHandlerTestSuite) TestStatistics_TransactionCount() {
 	s.Contains(body, "3 transactions", "should show transaction count")
 }
 
+func (s *ExpenseHandlerTestSuite) TestStatisticsExpenses() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+	start := parseTestDate("2026-04-01T12:00:00")
+	for i := range statsPageSize + 5 {
+		s.Require().NoError(s.db.CreateExpense(float64(i+1), fmt.Sprintf("Coffee %d", i+1), "eating out", start.Add(time.Duration(i)*time.Hour), 1))
+	}
+	s.Require().NoError(s.db.CreateExpense(30, "Bus", "transport", start, 1))
+	s.Require().NoError(s.db.CreateExpense(9, "Coffee in May", "eating out", start.AddDate(0, 1, 0), 1))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.StatisticsExpenses(w, httptest.NewRequest("GET", target, http.NoBody))
+		return w
+	}
+
+	// The first page is the newest expenses and links the next one
+	w := get("/statistics/expenses?year=2026&month=4&category=eating+out")
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Equal(statsPageSize, strings.Count(body, `class="expense-item"`))
+	s.Contains(body, "Coffee 55")
+	s.NotContains(body, "Coffee 5<")
+	s.NotContains(body, "Bus")
+	s.NotContains(body, "Coffee in May")
+	s.Contains(body, `hx-get="/statistics/expenses?category=eating&#43;out&amp;month=4&amp;offset=50&amp;year=2026"`)
+
+	w = get("/statistics/expenses?category=eating+out&month=4&offset=50&year=2026")
+	body = w.Body.String()
+	s.Equal(5, strings.Count(body, `class="expense-item"`))
+	s.Contains(body, "Coffee 1<")
+	s.NotContains(body, "load-more", "the last page")
+
+	// Without a month the whole year is listed
+	body = get("/statistics/expenses?year=2026&category=eating+out&offset=50").Body.String()
+	s.Equal(6, strings.Count(body, `class="expense-item"`))
+
+	body = get("/statistics/expenses?year=2026&month=4&category=health").Body.String()
+	s.Contains(body, "No transactions")
+
+	for _, target := range []string{
+		"/statistics/expenses?category=transport",
+		"/statistics/expenses?year=2026&month=13",
+		"/statistics/expenses?year=2026&offset=-1",
+	} {
+		s.Equal(http.StatusBadRequest, get(target).Code, target)
+	}
+}
+
 func (s *ExpenseHandlerTestSuite) TestDeleteExpense() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Create an expense first
 	err := s.db.CreateExpense(50.00, "To Delete", "food", parseTestDate("2026-01-10T12:00:00"), 1)
@@ -418,7 +576,7 @@ func (s *ExpenseHandlerTestSuite) TestDeleteExpense() {
 }
 
 func (s *ExpenseHandlerTestSuite) TestDeleteExpense_NonExistent() {
-	h := NewHandlers(s.db, s.templateDir, false)
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
 
 	// Send DELETE request for non-existent expense
 	req := httptest.NewRequest("DELETE", "/expenses/99999", http.NoBody)
@@ -427,9 +585,75 @@ func (s *ExpenseHandlerTestSuite) TestDeleteExpense_NonExistent() {
 
 	h.DeleteExpense(w, req)
 
-	// Should still return OK (no-op for non-existent)
 	resp := w.Result()
-	s.Equal(http.StatusOK, resp.StatusCode)
+	s.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (s *ExpenseHandlerTestSuite) TestChangeExpense_StoreError() {
+	store := memstore.New()
+	var logs bytes.Buffer
+	h := NewHandlers(store, WithTemplateFS(s.templates), WithLogger(log.New(&logs, "", 0)))
+	s.Require().NoError(store.CreateExpense(12.5, "Lunch", "Eating Out", time.Now(), 1))
+	store.Fail(memstore.ErrDown, "GetExpense")
+
+	form := url.Values{"amount": {"15.00"}, "description": {"Dinner"}, "category": {"Eating Out"}, "date": {"2026-01-09T12:00:00"}}
+	req := httptest.NewRequest("POST", "/expenses/1", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	h.UpdateExpense(w, s.addUserContext(req))
+	s.Equal(http.StatusInternalServerError, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/expenses/1", http.NoBody)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	h.DeleteExpense(w, s.addUserContext(req))
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.Equal("GetExpense error: database is down\nGetExpense error: database is down\n", logs.String())
+
+	store.Fail(nil, "GetExpense")
+	expense, err := store.GetExpense(1)
+	s.Require().NoError(err, "not deleted")
+	s.Equal("Lunch", expense.Description, "not changed")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_DatabaseDown() {
+	store := memstore.New()
+	h := NewHandlers(store, WithTemplateFS(s.templates))
+	s.Require().NoError(store.CreateExpense(12.5, "Lunch", "Eating Out", time.Now(), 1))
+
+	list := func() int {
+		w := httptest.NewRecorder()
+		h.ListExpenses(w, s.addUserContext(httptest.NewRequest("GET", "/expenses", http.NoBody)))
+		return w.Code
+	}
+	s.Equal(http.StatusOK, list())
+
+	store.Fail(memstore.ErrDown)
+	s.Equal(http.StatusInternalServerError, list())
+
+	store.Fail(nil)
+	s.Equal(http.StatusOK, list(), "works again once the database is back")
+}
+
+func (s *ExpenseHandlerTestSuite) TestCreateExpense_StoreError() {
+	store := memstore.New()
+	var logs bytes.Buffer
+	h := NewHandlers(store, WithTemplateFS(s.templates), WithLogger(log.New(&logs, "", 0)))
+	store.Fail(memstore.ErrDown, "InsertExpenseWithDetails")
+
+	form := url.Values{"amount": {"15.00"}, "description": {"Lunch"}, "category": {"Eating Out"}, "date": {"2026-01-09T12:00:00"},
+		"latitude": {"52.52"}, "longitude": {"13.405"}, "reimbursable": {"on"}}
+	req := httptest.NewRequest("POST", "/expenses", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.CreateExpense(w, s.addUserContext(req))
+
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.Equal("CreateExpense error: database is down\n", logs.String())
+	expenses, err := store.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Empty(expenses)
 }
 
 func (s *ExpenseHandlerTestSuite) TestIsOtherUserLogic() {
@@ -471,6 +695,279 @@ func (s *ExpenseHandlerTestSuite) TestIsOtherUserLogic() {
 	}
 }
 
+func (s *ExpenseHandlerTestSuite) TestListExpenses_GroupByWeek() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	now := time.Now()
+	s.Require().NoError(s.db.CreateExpense(12.00, "Weekly Shop", "Groceries", now, 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses?group=week", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	_, week := now.ISOWeek()
+	s.Contains(body, fmt.Sprintf("WEEK %d ", week))
+	s.Contains(body, now.Format("Mon 15:04"), "weekly items should show the weekday")
+	s.Contains(body, `name="group" value="week"`, "the search box should keep the grouping")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_DateRange() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	s.Require().NoError(s.db.CreateExpense(5.00, "Before Range", "Groceries", time.Date(2026, 1, 9, 23, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(10.00, "Range Start", "Groceries", time.Date(2026, 1, 10, 8, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(20.00, "Range End", "Groceries", time.Date(2026, 1, 20, 21, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(40.00, "After Range", "Groceries", time.Date(2026, 1, 21, 9, 0, 0, 0, time.Local), 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses?from=2026-01-10&to=2026-01-20", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Spent in period")
+	s.Contains(body, "Range Start")
+	s.Contains(body, "Range End", "the to date should be inclusive")
+	s.NotContains(body, "Before Range")
+	s.NotContains(body, "After Range")
+	s.Contains(body, "30.00")
+	s.Contains(body, `value="2026-01-10"`)
+	s.NotContains(body, "/expenses/history", "a picked period should not scroll into older months")
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_MonthNavigation() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	s.Require().NoError(s.db.CreateExpense(10.00, "January Rent", "Housing", time.Date(2026, 1, 31, 20, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(20.00, "February Rent", "Housing", time.Date(2026, 2, 1, 8, 0, 0, 0, time.Local), 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses?category=Housing&from=2026-01-01&to=2026-01-31", http.NoBody))
+	w := httptest.NewRecorder()
+	h.ListExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, `<h2 class="period-title">January 2026</h2>`)
+	s.Contains(body, "Spent in January 2026 on Housing")
+	s.Contains(body, "January Rent")
+	s.NotContains(body, "February Rent")
+	s.Contains(body, `hx-get="/expenses?category=Housing&amp;from=2025-12-01&amp;to=2025-12-31"`, "the filters should be kept")
+	s.Contains(body, `hx-get="/expenses?category=Housing&amp;from=2026-02-01&amp;to=2026-02-28"`)
+
+	// The current month can't go further
+	now := time.Now()
+	req = s.addUserContext(httptest.NewRequest("GET", "/expenses", http.NoBody))
+	w = httptest.NewRecorder()
+	h.ListExpenses(w, req)
+	body = w.Body.String()
+	s.Contains(body, `<h2 class="period-title">`+now.Format("January 2006")+`</h2>`)
+	s.Contains(body, "from="+time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local).Format(time.DateOnly))
+	s.NotContains(body, "from="+time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.Local).Format(time.DateOnly))
+
+	// Other periods have no months to step through
+	req = s.addUserContext(httptest.NewRequest("GET", "/expenses?from=2026-01-10&to=2026-01-20", http.NoBody))
+	w = httptest.NewRecorder()
+	h.ListExpenses(w, req)
+	s.NotContains(w.Body.String(), "period-title")
+}
+
+func (s *ExpenseHandlerTestSuite) TestSearchExpenses() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	s.Require().NoError(s.db.CreateExpense(12.00, "Pharmacy receipt", "Health", time.Date(2025, 3, 14, 10, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(30.00, "Vitamins", "Health", time.Date(2026, 1, 5, 10, 0, 0, 0, time.Local), 1))
+	s.Require().NoError(s.db.CreateExpense(8.00, "Cinema", "Entertainment", time.Date(2026, 1, 6, 10, 0, 0, 0, time.Local), 1))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses/search?q=health", http.NoBody))
+	w := httptest.NewRecorder()
+	h.SearchExpenses(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "2 expenses matching “health” in all months")
+	s.Contains(body, "Pharmacy receipt", "from any month")
+	s.Contains(body, "Vitamins")
+	s.NotContains(body, "Cinema")
+	s.Contains(body, "FRI, 14 MAR &#39;25", "grouped by day like the list")
+	s.Contains(body, `hx-get="/expenses/search"`, "the search box keeps searching all months")
+
+	// The search box swaps only the results
+	req = s.addUserContext(httptest.NewRequest("GET", "/expenses/search?q=pharmacy+receipt", http.NoBody))
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("HX-Target", "expense-results")
+	w = httptest.NewRecorder()
+	h.SearchExpenses(w, req)
+	body = w.Body.String()
+	s.NotContains(body, "search-input")
+	s.Contains(body, "1 expense matching")
+	s.Contains(body, "Pharmacy receipt")
+
+	req = s.addUserContext(httptest.NewRequest("GET", "/expenses/search?q=dentist", http.NoBody))
+	w = httptest.NewRecorder()
+	h.SearchExpenses(w, req)
+	s.Contains(w.Body.String(), "No expenses match “dentist”")
+
+	// The list offers to search all months
+	req = s.addUserContext(httptest.NewRequest("GET", "/expenses?q=pharmacy", http.NoBody))
+	w = httptest.NewRecorder()
+	h.ListExpenses(w, req)
+	s.Contains(w.Body.String(), `hx-get="/expenses/search?q=pharmacy"`)
+}
+
+func (s *ExpenseHandlerTestSuite) TestListExpenses_InvalidDateRange() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	for _, query := range []string{"from=2026-13-01", "to=yesterday", "from=2026-02-01&to=2026-01-01"} {
+		req := s.addUserContext(httptest.NewRequest("GET", "/expenses?"+query, http.NoBody))
+		w := httptest.NewRecorder()
+		h.ListExpenses(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (s *ExpenseHandlerTestSuite) TestInlineEdit() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	date := time.Now()
+	s.Require().NoError(s.db.CreateExpense(12.50, "Lunch", "Eating Out", date, 1))
+	expenses, err := s.db.ListExpenses()
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	id := fmt.Sprint(expenses[0].ID)
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses/"+id+"/inline", http.NoBody))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	h.InlineEditForm(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `name="amount" value="12.50"`)
+	s.Contains(w.Body.String(), `name="description" value="Lunch"`)
+
+	form := url.Values{"amount": {"14,75"}, "description": {"Team lunch"}}
+	req = httptest.NewRequest("POST", "/expenses/"+id+"/inline", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", id)
+	req = s.addUserContext(req)
+	w = httptest.NewRecorder()
+	h.UpdateExpenseInline(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("expenses-changed", w.Header().Get("HX-Trigger"))
+	s.Contains(w.Body.String(), "Team lunch")
+	s.NotContains(w.Body.String(), "<form", "a successful save should swap back to the plain row")
+
+	updated, err := s.db.GetExpense(expenses[0].ID)
+	s.Require().NoError(err)
+	s.InDelta(14.75, updated.Amount, 0.001)
+	s.Equal("Team lunch", updated.Description)
+	s.Equal("Eating Out", updated.Category, "inline edits should leave the category alone")
+	s.True(updated.Date.Equal(expenses[0].Date), "inline edits should leave the date alone")
+}
+
+func (s *ExpenseHandlerTestSuite) TestInlineEdit_ValidationError() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	s.Require().NoError(s.db.CreateExpense(12.50, "Lunch", "Eating Out", time.Now(), 1))
+	expenses, err := s.db.ListExpenses()
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	id := fmt.Sprint(expenses[0].ID)
+
+	tests := []struct {
+		amount, description, wantError string
+	}{
+		{"abc", "Lunch", "Enter an amount greater than zero"},
+		{"-3", "Lunch", "Enter an amount greater than zero"},
+		{"5.00", "   ", "Enter a description"},
+	}
+	for _, tt := range tests {
+		form := url.Values{"amount": {tt.amount}, "description": {tt.description}}
+		req := httptest.NewRequest("POST", "/expenses/"+id+"/inline", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", id)
+		req = s.addUserContext(req)
+		w := httptest.NewRecorder()
+		h.UpdateExpenseInline(w, req)
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), tt.wantError)
+		s.Contains(w.Body.String(), fmt.Sprintf(`name="amount" value="%s"`, tt.amount), "the typed input should be kept")
+		s.Empty(w.Header().Get("HX-Trigger"))
+	}
+
+	unchanged, err := s.db.GetExpense(expenses[0].ID)
+	s.Require().NoError(err)
+	s.InDelta(12.50, unchanged.Amount, 0.001)
+	s.Equal("Lunch", unchanged.Description)
+}
+
+func (s *ExpenseHandlerTestSuite) TestInlineEdit_NotFound() {
+	h := NewHandlers(s.db, WithTemplateFS(s.templates))
+
+	req := s.addUserContext(httptest.NewRequest("GET", "/expenses/99999/row", http.NoBody))
+	req.SetPathValue("id", "99999")
+	w := httptest.NewRecorder()
+	h.ExpenseRow(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestGroupExpenses_ByWeek(t *testing.T) {
+	expense := func(desc, date string, amount float64) models.Expense {
+		return models.Expense{Description: desc, Amount: amount, Date: parseTestDate(date)}
+	}
+	// Newest first, as returned by storage
+	expenses := []models.Expense{
+		expense("New Year", "2026-01-01T10:00:00", 5.00),
+		expense("New Year's Eve", "2025-12-31T22:00:00", 20.00),
+		expense("Monday morning", "2025-12-29T00:10:00", 1.00),
+		expense("Sunday night", "2025-12-28T23:30:00", 7.00),
+	}
+
+	groups, total := groupExpenses(expenses, true, 1, time.Now())
+
+	if total != 33.00 {
+		t.Errorf("total = %.2f, want 33.00", total)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+
+	// The week spanning the new year is ISO week 1 of 2026 and starts on Monday
+	if groups[0].Title != "WEEK 1 '26 · 29 DEC – 04 JAN" {
+		t.Errorf("groups[0].Title = %q", groups[0].Title)
+	}
+	if groups[0].Total != 26.00 || len(groups[0].Items) != 3 {
+		t.Errorf("groups[0] has total %.2f and %d items, want 26.00 and 3", groups[0].Total, len(groups[0].Items))
+	}
+	if groups[0].Items[0].Time != "Thu 10:00" {
+		t.Errorf("groups[0].Items[0].Time = %q, want weekday and time", groups[0].Items[0].Time)
+	}
+
+	// Sunday still belongs to the previous ISO week
+	if groups[1].Title != "WEEK 52 '25 · 22 DEC – 28 DEC" {
+		t.Errorf("groups[1].Title = %q", groups[1].Title)
+	}
+	if len(groups[1].Items) != 1 || groups[1].Items[0].Description != "Sunday night" {
+		t.Errorf("groups[1] should only hold the Sunday expense, got %+v", groups[1].Items)
+	}
+}
+
+func TestCategories_MatchModels(t *testing.T) {
+	if len(categories) != len(models.Categories) {
+		t.Fatalf("%d styled categories, want %d", len(categories), len(models.Categories))
+	}
+	for i, c := range categories {
+		if c.Name != models.Categories[i] {
+			t.Errorf("categories[%d] = %q, want %q", i, c.Name, models.Categories[i])
+		}
+	}
+}
+
 // Helper function to parse test dates
 func parseTestDate(dateStr string) time.Time {
 	t, _ := time.Parse("2006-01-02T15:04:05", dateStr)
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"expense-tracker/internal/exporter"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// exportFlushRows is how many rows ExportExpenses sends at a time.
+const exportFlushRows = 1000
+
+// ExportExpenses downloads the user's current and archived expenses in the
+// ledger they picked as CSV or JSON, like expensectl export, optionally
+// limited to the from and to days. The rows are sent on as they are read, so exporting years of
+// expenses takes no more memory than a month.
+func (h *Handlers) ExportExpenses(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	from, to, err := parseDateRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid date range", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exporter.FormatCSV
+	}
+	out, err := exporter.NewWriter(w, format)
+	if err != nil {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	// A large export to a slow client takes longer than the write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", exporter.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="expenses-%s.%s"`, h.now().Format(time.DateOnly), format))
+	w.Header().Set("Cache-Control", "no-store")
+
+	filter := storage.ExpenseFilter{From: from, To: to, UserID: user.ID, Ledger: h.ledger(r)}
+	err = h.store(r).StreamExpenses(filter, func(e models.Expense) error {
+		if err := out.Write(exporter.NewRow(e, user.Username)); err != nil {
+			return err
+		}
+		if out.Count()%exportFlushRows != 0 {
+			return nil
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		return
+	}
+	h.logger.Printf("ExportExpenses error: %v", err)
+	if out.Count() == 0 {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Part of the file may be out already, so cut the response short for
+	// the client to see the download failed
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/exporter"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"expense-tracker/internal/storage/memstore"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ExportTestSuite provides a test suite for the expense downloads
+type ExportTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+}
+
+// SetupTest runs before each test
+func (s *ExportTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(db, WithClock(func() time.Time { return now }), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *ExportTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// export downloads target as user 1 from h.
+func (s *ExportTestSuite) export(h *Handlers, w http.ResponseWriter, target string) {
+	req := httptest.NewRequest("GET", target, http.NoBody)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &models.User{ID: 1, Username: "alice"}))
+	h.ExportExpenses(w, req)
+}
+
+func (s *ExportTestSuite) TestCSV() {
+	s.Require().NoError(s.db.CreateExpense(10, "Old", "Other", time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee, black", "Eating Out", time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Theirs", "Groceries", time.Date(2026, 3, 5, 18, 0, 0, 0, time.UTC), 2))
+	_, err := s.db.ArchiveExpenses(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	w := httptest.NewRecorder()
+	s.export(s.handlers, w, "/expenses/export")
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	s.Equal(`attachment; filename="expenses-2026-03-15.csv"`, w.Header().Get("Content-Disposition"))
+	s.Equal("date,amount,description,category,user\n"+
+		"2025-06-01T12:00:00,10.00,Old,Other,alice\n"+
+		"2026-03-02T08:30:00,4.50,\"Coffee, black\",Eating Out,alice\n", w.Body.String(),
+		"archived expenses too, but not other users'")
+
+	w = httptest.NewRecorder()
+	s.export(s.handlers, w, "/expenses/export?from=2026-01-01&to=2026-03-31")
+	s.Equal("date,amount,description,category,user\n"+
+		"2026-03-02T08:30:00,4.50,\"Coffee, black\",Eating Out,alice\n", w.Body.String())
+}
+
+func (s *ExportTestSuite) TestJSON() {
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), 1))
+
+	w := httptest.NewRecorder()
+	s.export(s.handlers, w, "/expenses/export?format=json")
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("application/json", w.Header().Get("Content-Type"))
+	var rows []exporter.Row
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rows))
+	s.Equal([]exporter.Row{{Date: "2026-03-02T08:30:00", Amount: 4.5, Description: "Coffee", Category: "Eating Out", User: "alice"}}, rows)
+
+	w = httptest.NewRecorder()
+	s.export(s.handlers, w, "/expenses/export?format=json&from=2027-01-01")
+	s.Equal("[]\n", w.Body.String())
+}
+
+func (s *ExportTestSuite) TestStreamed() {
+	expenses := make([]models.Expense, 2*exportFlushRows+10)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range expenses {
+		expenses[i] = models.Expense{Amount: 1, Description: "Coffee", Category: "Eating Out", Date: start.Add(time.Duration(i) * time.Minute)}
+	}
+	_, err := s.db.ImportExpenses(expenses, 1, false)
+	s.Require().NoError(err)
+
+	w := httptest.NewRecorder()
+	s.export(s.handlers, w, "/expenses/export")
+	s.True(w.Flushed, "sent on while reading")
+	s.Equal(len(expenses)+1, strings.Count(w.Body.String(), "\n"))
+}
+
+func (s *ExportTestSuite) TestInvalid() {
+	for _, target := range []string{
+		"/expenses/export?format=xml",
+		"/expenses/export?from=March",
+		"/expenses/export?from=2026-03-02&to=2026-03-01",
+	} {
+		w := httptest.NewRecorder()
+		s.export(s.handlers, w, target)
+		s.Equal(http.StatusBadRequest, w.Code, target)
+	}
+
+	w := httptest.NewRecorder()
+	s.handlers.ExportExpenses(w, httptest.NewRequest("GET", "/expenses/export", http.NoBody))
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *ExportTestSuite) TestErrors() {
+	store := memstore.New()
+	h := NewHandlers(store, WithLogger(log.New(io.Discard, "", 0)))
+	store.Fail(errors.New("disk on fire"), "StreamExpenses")
+	w := httptest.NewRecorder()
+	s.export(h, w, "/expenses/export")
+	s.Equal(http.StatusInternalServerError, w.Code)
+
+	// Once part of the file is out, the response is cut short instead
+	store.Fail(nil, "StreamExpenses")
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for i := range exportFlushRows {
+		s.Require().NoError(store.CreateExpense(1, "Coffee", "Eating Out", day.Add(time.Duration(i)*time.Minute), 1))
+	}
+	s.PanicsWithValue(http.ErrAbortHandler, func() {
+		s.export(h, failingWriter{httptest.NewRecorder()}, "/expenses/export")
+	})
+}
+
+// failingWriter is a client that went away.
+type failingWriter struct {
+	http.ResponseWriter
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+// TestExportTestSuite runs the expense download test suite
+func TestExportTestSuite(t *testing.T) {
+	suite.Run(t, new(ExportTestSuite))
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// guestContextKey is the context key for the guest token of a request made
+// with a guest link.
+const guestContextKey contextKey = "guest"
+
+// guestLifetimes are the expiry choices offered when creating a guest link,
+// in days. The first is the default.
+var guestLifetimes = []int{30, 7, 90, 365}
+
+// maxGuestName is the longest name a guest link can be given, in
+// characters.
+const maxGuestName = 64
+
+// guestPatterns are the routes guests can use: the list and statistics of
+// the ledger they were given. Expense details aren't among them, since an
+// expense's ID may belong to another ledger.
+var guestPatterns = map[string]bool{
+	"GET /expenses":            true,
+	"GET /expenses/history":    true,
+	"GET /expenses/search":     true,
+	"GET /expenses/live":       true,
+	"GET /statistics":          true,
+	"GET /statistics/expenses": true,
+}
+
+// guestUnusable is shown for guest links that can't be used.
+const guestUnusable = "This guest link is invalid, has expired, or was revoked. Ask for a new one."
+
+// guest returns the guest token the request was made with, or nil when the
+// user signed in themselves.
+func guest(r *http.Request) *models.GuestToken {
+	token, _ := r.Context().Value(guestContextKey).(*models.GuestToken)
+	return token
+}
+
+// guestCookie returns the cookie that keeps a guest link's token, with the
+// attributes of the session cookie.
+func (h *Handlers) guestCookie(r *http.Request, value string) *http.Cookie {
+	cookie := h.sessionCookie(r, value)
+	cookie.Name = h.cookieName + "_guest"
+	return cookie
+}
+
+func (h *Handlers) clearGuestCookie(w http.ResponseWriter, r *http.Request) {
+	cookie := h.guestCookie(r, "")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// serveGuest serves a request without a session that carries a guest
+// cookie, with the user who shared the ledger in the context and their
+// preferences showing that ledger. Routes other than guestPatterns are
+// refused. It reports whether the request had a valid guest cookie; the
+// caller handles the others.
+func (h *Handlers) serveGuest(w http.ResponseWriter, r *http.Request, next http.Handler) bool {
+	cookie, err := r.Cookie(h.guestCookie(r, "").Name)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	db := h.store(r)
+	token, user, err := db.ValidateGuestToken(auth.HashToken(cookie.Value))
+	if err != nil {
+		if !errors.Is(err, storage.ErrGuestTokenInvalid) {
+			h.logger.Printf("ValidateGuestToken error: %v", err)
+		}
+		h.clearGuestCookie(w, r)
+		return false
+	}
+	if !guestPatterns[r.Pattern] {
+		http.Error(w, "Guests can only look at expenses and statistics", http.StatusForbidden)
+		return true
+	}
+
+	ctx := context.WithValue(r.Context(), UserContextKey, user)
+	ctx = context.WithValue(ctx, guestContextKey, token)
+	ctx = context.WithValue(ctx, preferencesContextKey, &preferencesLoader{
+		userID: user.ID,
+		load: func() (models.Preferences, error) {
+			prefs, err := db.GetPreferences(user.ID)
+			if err != nil {
+				// The defaults would show the personal ledger instead
+				h.logger.Printf("GetPreferences error: %v", err)
+				prefs = models.DefaultPreferences()
+			}
+			prefs.Ledger = token.LedgerID
+			return prefs, nil
+		},
+		ledger: db.GetLedger,
+	})
+	next.ServeHTTP(w, r.WithContext(ctx))
+	return true
+}
+
+// GuestLogin opens a guest link: it keeps the token in a cookie until the
+// link expires and shows the shared ledger. Someone signed in on the same
+// browser keeps seeing their own account.
+func (h *Handlers) GuestLogin(w http.ResponseWriter, r *http.Request) {
+	value := r.PathValue("token")
+	token, _, err := h.store(r).ValidateGuestToken(auth.HashToken(value))
+	if err != nil {
+		if !errors.Is(err, storage.ErrGuestTokenInvalid) {
+			h.logger.Printf("GuestLogin error: %v", err)
+		}
+		h.render(w, r, "login.html", LoginViewModel{Error: guestUnusable, AllowSignup: h.allowSignup})
+		return
+	}
+	cookie := h.guestCookie(r, value)
+	cookie.Expires = token.ExpiresAt
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, "/expenses", http.StatusFound)
+}
+
+// Guests renders the guest access page, where users share a ledger
+// read-only.
+func (h *Handlers) Guests(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderGuests(w, r, user.ID, GuestsViewModel{})
+}
+
+// renderGuests renders the guest access page for a user, filling their
+// guest links and the ledgers they can share into viewModel.
+func (h *Handlers) renderGuests(w http.ResponseWriter, r *http.Request, userID int64, viewModel GuestsViewModel) {
+	db := h.store(r)
+	tokens, err := db.ListGuestTokens(userID)
+	if err != nil {
+		h.logger.Printf("ListGuestTokens error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ledgers, err := db.ListLedgers(userID)
+	if err != nil {
+		h.logger.Printf("ListLedgers error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names := map[int64]string{}
+	for _, l := range ledgers {
+		names[l.ID] = l.Name
+	}
+	viewModel.Ledgers, _ = splitArchived(ledgers)
+	viewModel.Ledger = h.ledger(r)
+	viewModel.Lifetimes = guestLifetimes
+	now := h.now()
+	for _, t := range tokens {
+		item := GuestItem{
+			ID:      t.ID,
+			Name:    t.Name,
+			Ledger:  names[t.LedgerID],
+			Created: t.CreatedAt.Local().Format("02 Jan 2006"),
+			Expires: t.ExpiresAt.Local().Format("02 Jan 2006, 15:04"),
+			Expired: !t.ExpiresAt.After(now),
+		}
+		if t.LastUsedAt != nil {
+			item.LastUsed = t.LastUsedAt.Local().Format("02 Jan 2006, 15:04")
+		}
+		viewModel.Guests = append(viewModel.Guests, item)
+	}
+	h.render(w, r, "guests.html", viewModel)
+}
+
+// CreateGuestToken creates a guest link to the picked ledger, which must be
+// one the user sees, and shows it to the user. Only a hash of the token is stored, so the link can't be
+// shown again.
+func (h *Handlers) CreateGuestToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > maxGuestName {
+		h.renderGuests(w, r, user.ID, GuestsViewModel{Name: name, Error: "Enter who the link is for, in up to 64 characters"})
+		return
+	}
+	ledgerID, _ := strconv.ParseInt(r.FormValue("ledger"), 10, 64)
+	if _, err := h.visibleLedger(r, ledgerID); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Printf("CreateGuestToken error: %v", err)
+		}
+		h.renderGuests(w, r, user.ID, GuestsViewModel{Name: name, Error: "Pick the ledger to share"})
+		return
+	}
+	days := guestLifetimes[0]
+	if d, err := strconv.Atoi(r.FormValue("days")); err == nil && d >= 1 && d <= 365 {
+		days = d
+	}
+
+	value, err := auth.GenerateSessionToken()
+	if err != nil {
+		h.logger.Printf("CreateGuestToken error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	token := &models.GuestToken{UserID: user.ID, LedgerID: ledgerID, Name: name, ExpiresAt: h.now().AddDate(0, 0, days)}
+	if err := h.store(r).CreateGuestToken(token, auth.HashToken(value)); err != nil {
+		h.logger.Printf("CreateGuestToken error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %s created a guest link valid for %d days", user.Username, days)
+	h.renderGuests(w, r, user.ID, GuestsViewModel{Link: h.requestOrigin(r) + "/guest/" + value})
+}
+
+// DeleteGuestToken revokes one of the user's guest links to a ledger they
+// see. Guests using it lose access with their next request.
+func (h *Handlers) DeleteGuestToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	err := h.store(r).DeleteGuestToken(user.ID, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Guest link not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Printf("DeleteGuestToken error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderGuests(w, r, user.ID, GuestsViewModel{})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// GuestsTestSuite provides a test suite for read-only guest links
+type GuestsTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	mux      *http.ServeMux
+	user     *models.User
+	business *models.Ledger
+}
+
+// SetupTest runs before each test
+func (s *GuestsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(db.CreateSession("token", s.user.ID, time.Hour, ""))
+	s.business, err = db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+
+	// The routes guests could try, registered like the app does
+	s.mux = http.NewServeMux()
+	public := NewGroup(s.mux)
+	public.HandleFunc("GET /guest/{token}", s.handlers.GuestLogin)
+	authed := public.Use(s.handlers.AuthMiddleware)
+	authed.HandleFunc("GET /expenses", s.handlers.ListExpenses)
+	authed.HandleFunc("GET /expenses/search", s.handlers.SearchExpenses)
+	authed.HandleFunc("POST /expenses", s.handlers.CreateExpense)
+	authed.HandleFunc("GET /statistics", s.handlers.Statistics)
+	authed.HandleFunc("GET /settings", s.handlers.Settings)
+	authed.HandleFunc("GET /settings/guests", s.handlers.Guests)
+	authed.HandleFunc("POST /settings/guests", s.handlers.CreateGuestToken)
+	authed.HandleFunc("DELETE /settings/guests/{id}", s.handlers.DeleteGuestToken)
+}
+
+// TearDownTest runs after each test
+func (s *GuestsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// do sends a request through the routes with the cookies given.
+func (s *GuestsTestSuite) do(method, target string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	body := io.Reader(http.NoBody)
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	return w
+}
+
+// session is alice's session cookie.
+func (s *GuestsTestSuite) session() *http.Cookie {
+	return &http.Cookie{Name: SessionCookieName, Value: "token"}
+}
+
+// share creates a guest link to a ledger as alice and returns its path.
+func (s *GuestsTestSuite) share(ledgerID int64) string {
+	w := s.do("POST", "/settings/guests", url.Values{"name": {"Accountant"}, "ledger": {strconv.FormatInt(ledgerID, 10)}, "days": {"7"}}, s.session())
+	s.Require().Equal(http.StatusOK, w.Code)
+	link := regexp.MustCompile(`/guest/[A-Za-z0-9_=-]+`).FindString(w.Body.String())
+	s.Require().NotEmpty(link, "the link is shown")
+	return link
+}
+
+// open opens a guest link and returns the guest cookie it sets.
+func (s *GuestsTestSuite) open(link string) *http.Cookie {
+	w := s.do("GET", link, nil)
+	s.Require().Equal(http.StatusFound, w.Code)
+	s.Equal("/expenses", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	s.Require().Len(cookies, 1)
+	s.Equal(SessionCookieName+"_guest", cookies[0].Name)
+	s.True(cookies[0].HttpOnly)
+	return cookies[0]
+}
+
+func (s *GuestsTestSuite) TestReadOnly() {
+	now := time.Now()
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 250, Description: "Printer", Category: "Shopping", Date: now, UserID: &s.user.ID, LedgerID: s.business.ID}))
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 12, Description: "Cinema", Category: "Entertainment", Date: now, UserID: &s.user.ID, LedgerID: models.PersonalLedgerID}))
+
+	cookie := s.open(s.share(s.business.ID))
+	w := s.do("GET", "/expenses", nil, cookie)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Printer")
+	s.NotContains(body, "Cinema", "only the shared ledger")
+	s.Contains(body, "guest-view")
+	s.NotContains(body, `hx-get="/settings"`)
+	s.NotContains(body, "openCreateModal()")
+	s.Equal(http.StatusOK, s.do("GET", "/statistics", nil, cookie).Code)
+	w = s.do("GET", "/expenses/search?q=e", nil, cookie)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Printer")
+	s.NotContains(w.Body.String(), "Cinema", "searches only the shared ledger")
+
+	w = s.do("POST", "/expenses", url.Values{"amount": {"5"}, "description": {"Coffee"}, "category": {"Eating Out"}, "date": {"2026-03-02T08:30:00"}}, cookie)
+	s.Equal(http.StatusForbidden, w.Code)
+	s.Equal(http.StatusForbidden, s.do("GET", "/settings", nil, cookie).Code)
+	s.Equal(http.StatusForbidden, s.do("GET", "/settings/guests", nil, cookie).Code)
+	expenses, err := s.db.ListExpenses()
+	s.Require().NoError(err)
+	s.Len(expenses, 2, "nothing was added")
+
+	prefs, err := s.db.GetPreferences(s.user.ID)
+	s.Require().NoError(err)
+	s.NotEqual(s.business.ID, prefs.Ledger, "alice's own pick is left alone")
+	w = s.do("GET", "/expenses", nil, s.session())
+	s.Contains(w.Body.String(), "Cinema", "alice still sees her ledger")
+	s.NotContains(w.Body.String(), "guest-view")
+}
+
+func (s *GuestsTestSuite) TestRevoke() {
+	cookie := s.open(s.share(models.PersonalLedgerID))
+	s.Equal(http.StatusOK, s.do("GET", "/expenses", nil, cookie).Code)
+
+	tokens, err := s.db.ListGuestTokens(s.user.ID)
+	s.Require().NoError(err)
+	s.Require().Len(tokens, 1)
+	s.NotNil(tokens[0].LastUsedAt)
+	w := s.do("GET", "/settings/guests", nil, s.session())
+	s.Contains(w.Body.String(), "Accountant · Personal")
+	s.Contains(w.Body.String(), "Last used")
+
+	w = s.do("DELETE", "/settings/guests/"+strconv.FormatInt(tokens[0].ID, 10), nil, s.session())
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "No guest links yet")
+	s.Equal(http.StatusNotFound, s.do("DELETE", "/settings/guests/"+strconv.FormatInt(tokens[0].ID, 10), nil, s.session()).Code)
+
+	w = s.do("GET", "/expenses", nil, cookie)
+	s.Equal(http.StatusFound, w.Code)
+	s.Equal("/login", w.Header().Get("Location"))
+}
+
+func (s *GuestsTestSuite) TestOtherUsersLedger() {
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	ledger, err := s.db.CreateLedger("Club", "", bob.ID)
+	s.Require().NoError(err)
+
+	w := s.do("POST", "/settings/guests", url.Values{"name": {"Partner"}, "ledger": {strconv.FormatInt(ledger.ID, 10)}}, s.session())
+	s.Contains(w.Body.String(), "Pick the ledger to share")
+	tokens, err := s.db.ListGuestTokens(s.user.ID)
+	s.Require().NoError(err)
+	s.Empty(tokens)
+
+	// A link alice has to it anyway can neither be used nor revoked by her
+	token := &models.GuestToken{UserID: s.user.ID, LedgerID: ledger.ID, Name: "Partner", ExpiresAt: time.Now().Add(time.Hour)}
+	s.Require().NoError(s.db.CreateGuestToken(token, auth.HashToken("club-link")))
+	s.Equal(http.StatusNotFound, s.do("DELETE", "/settings/guests/"+strconv.FormatInt(token.ID, 10), nil, s.session()).Code)
+	w = s.do("GET", "/guest/club-link", nil)
+	s.Contains(w.Body.String(), "This guest link is invalid")
+	w = s.do("GET", "/expenses", nil, &http.Cookie{Name: SessionCookieName + "_guest", Value: "club-link"})
+	s.Equal(http.StatusFound, w.Code)
+	s.Equal("/login", w.Header().Get("Location"))
+}
+
+func (s *GuestsTestSuite) TestInvalidLinks() {
+	w := s.do("GET", "/guest/nonsense", nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "This guest link is invalid")
+	s.Empty(w.Result().Cookies())
+
+	w = s.do("GET", "/expenses", nil, &http.Cookie{Name: SessionCookieName + "_guest", Value: "nonsense"})
+	s.Equal(http.StatusFound, w.Code)
+	s.Equal("/login", w.Header().Get("Location"))
+
+	w = s.do("POST", "/settings/guests", url.Values{"name": {" "}, "ledger": {"1"}}, s.session())
+	s.Contains(w.Body.String(), "Enter who the link is for")
+	w = s.do("POST", "/settings/guests", url.Values{"name": {"Partner"}, "ledger": {"99"}}, s.session())
+	s.Contains(w.Body.String(), "Pick the ledger to share")
+	s.Contains(w.Body.String(), `value="Partner"`, "kept")
+	tokens, err := s.db.ListGuestTokens(s.user.ID)
+	s.Require().NoError(err)
+	s.Empty(tokens)
+}
+
+// TestGuestsTestSuite runs the guest links test suite
+func TestGuestsTestSuite(t *testing.T) {
+	suite.Run(t, new(GuestsTestSuite))
+}
@@ -1,8 +1,23 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/jobs"
+	"expense-tracker/internal/mail"
 	"expense-tracker/internal/models"
-	"expense-tracker/internal/storage"
+	"expense-tracker/internal/notify"
+	"expense-tracker/web"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,22 +27,224 @@ type contextKey string
 const (
 	// UserContextKey is the context key for the authenticated user.
 	UserContextKey contextKey = "user"
-	// SessionCookieName is the name of the session cookie.
+	// SessionCookieName is the default name of the session cookie.
 	SessionCookieName = "session"
-	// SessionDuration is how long sessions last (30 days).
+	// SessionDuration is how long "remember me" sessions last by default (30
+	// days).
 	SessionDuration = 30 * 24 * time.Hour
+	// ShortSessionDuration is how long other sessions last by default. Their
+	// cookie is also dropped when the browser closes.
+	ShortSessionDuration = 12 * time.Hour
 )
 
 // Handlers holds dependencies for HTTP handlers.
 type Handlers struct {
-	db           *storage.DB
-	templateDir  string
+	db           Store
+	templates    fs.FS
+	viewsMu      sync.RWMutex
+	views        map[string]*template.Template // Parsed views by name and template variant
 	secureCookie bool
+	allowSignup  bool
+	now          func() time.Time // The clock, time.Now unless a test sets one
+	logger       *log.Logger
+	queryTimeout time.Duration // Limit on a request's database queries, 0 for none
+	logQueries   bool          // Log each request's database queries
+
+	sessionDuration      time.Duration // Lifetime of "remember me" sessions
+	shortSessionDuration time.Duration // Lifetime of other sessions
+
+	passkeyOrigin string          // Fixed WebAuthn origin, derived from each request if empty
+	challenges    *challengeStore // Outstanding passkey challenges
+	ldap          *auth.LDAPConfig
+	secretKey     []byte // Signs links sent by email
+	mailer        *mail.Mailer
+	notifier      *notify.Notifier
+	binding       SessionBinding
+	cookieName    string          // Session cookie name
+	hostPrefix    bool            // Prefix cookieName with __Host-
+	sameSite      http.SameSite   // SameSite attribute of the session cookie
+	assets        *StaticAssets   // Fingerprints static file URLs in templates
+	readOnly      atomic.Bool     // Refuse changes, e.g. during a backup
+	demo          *demoAccount    // Set when running as a public demo
+	jobs          *jobs.Scheduler // Background jobs shown to admins
+	live          *events.Hub     // Expense changes sent to LiveUpdates
+	dev           *devReloader    // Set in development mode
+	started       time.Time       // Pages cached before then may use older templates
+}
+
+// SessionBinding controls whether sessions only work from the client that
+// logged in, which limits the use of stolen session cookies.
+type SessionBinding int
+
+const (
+	// BindNone accepts a session cookie from any client.
+	BindNone SessionBinding = iota
+	// BindUserAgent rejects a session cookie sent by a different browser.
+	BindUserAgent
+	// BindUserAgentAndNetwork also rejects it from a different network
+	// (/24 for IPv4, /48 for IPv6). Mobile users switching between Wi-Fi and
+	// cellular will have to log in again.
+	BindUserAgentAndNetwork
+)
+
+// Option configures optional Handlers behaviour.
+type Option func(*Handlers)
+
+// WithTemplateFS sets the page templates, with email templates under
+// email/. By default the templates built into the binary are used.
+func WithTemplateFS(templates fs.FS) Option {
+	return func(h *Handlers) { h.templates = templates }
+}
+
+// WithSecureCookie only sends the session cookie over HTTPS.
+func WithSecureCookie(secure bool) Option {
+	return func(h *Handlers) { h.secureCookie = secure }
+}
+
+// WithSessionDuration sets how long sessions last with "remember me" and
+// without it, instead of SessionDuration and ShortSessionDuration. Only
+// sessions longer than short survive closing the browser.
+func WithSessionDuration(remember, short time.Duration) Option {
+	return func(h *Handlers) { h.sessionDuration, h.shortSessionDuration = remember, short }
+}
+
+// WithClock sets the clock the handlers read the time from, e.g. to test
+// session renewal or what "today" means without waiting. The database keeps
+// its own time for session expiry.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handlers) { h.now = now }
+}
+
+// WithLogger sets where the handlers log errors and events. By default they
+// go to the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(h *Handlers) { h.logger = logger }
+}
+
+// WithQueryTimeout limits how long the database queries of a request
+// behind QueryTimeout may take, so a locked database fails requests instead
+// of piling them up. 0, the default, means no limit.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(h *Handlers) { h.queryTimeout = d }
+}
+
+// WithQueryLog adds how long each request spent on which database queries
+// to its log line, to tell slow queries from slow rendering.
+func WithQueryLog() Option {
+	return func(h *Handlers) { h.logQueries = true }
+}
+
+// WithSignup enables or disables self-service registration at /register.
+func WithSignup(allow bool) Option {
+	return func(h *Handlers) { h.allowSignup = allow }
+}
+
+// WithPasskeyOrigin pins the origin passkeys are registered for, e.g.
+// "https://expenses.example.com". Set it when the app runs behind a proxy
+// that changes the Host header.
+func WithPasskeyOrigin(origin string) Option {
+	return func(h *Handlers) { h.passkeyOrigin = origin }
+}
+
+// WithLDAP checks passwords against a directory server instead of the local
+// password hashes. Directory users get a local account on their first login.
+func WithLDAP(cfg auth.LDAPConfig) Option {
+	return func(h *Handlers) { h.ldap = &cfg }
+}
+
+// WithSecretKey sets the key that signs links sent by email. By default a
+// key is generated and stored in the database.
+func WithSecretKey(key []byte) Option {
+	return func(h *Handlers) { h.secretKey = key }
+}
+
+// WithMailer sets how emails are sent. By default they are written to the
+// log.
+func WithMailer(m *mail.Mailer) Option {
+	return func(h *Handlers) { h.mailer = m }
 }
 
-// NewHandlers creates a new Handlers instance.
-func NewHandlers(db *storage.DB, templateDir string, secureCookie bool) *Handlers {
-	return &Handlers{db: db, templateDir: templateDir, secureCookie: secureCookie}
+// WithSessionBinding binds new sessions to the client that created them.
+func WithSessionBinding(b SessionBinding) Option {
+	return func(h *Handlers) { h.binding = b }
+}
+
+// WithSessionCookieName changes the session cookie name from
+// SessionCookieName, e.g. to avoid clashing with another app on the same
+// host.
+func WithSessionCookieName(name string) Option {
+	return func(h *Handlers) { h.cookieName = name }
+}
+
+// WithHostCookiePrefix adds the __Host- prefix to the session cookie name.
+// Browsers then only accept the cookie over HTTPS, for the exact host, and
+// for all paths, so subdomains and plain HTTP can't plant or overwrite it.
+func WithHostCookiePrefix() Option {
+	return func(h *Handlers) { h.hostPrefix = true }
+}
+
+// WithSameSite sets the SameSite mode of the session cookie, which defaults
+// to http.SameSiteLaxMode. With http.SameSiteStrictMode, following a link to
+// the app from another site shows the login page even when signed in.
+func WithSameSite(mode http.SameSite) Option {
+	return func(h *Handlers) { h.sameSite = mode }
+}
+
+// WithStaticAssets makes templates link to fingerprinted static file URLs,
+// which assets serves with long-lived cache headers.
+func WithStaticAssets(assets *StaticAssets) Option {
+	return func(h *Handlers) { h.assets = assets }
+}
+
+// WithReadOnly starts the app in read-only mode, which admins can switch
+// off from the security settings.
+func WithReadOnly(readOnly bool) Option {
+	return func(h *Handlers) { h.readOnly.Store(readOnly) }
+}
+
+// WithJobs lets admins see the background jobs and start them early.
+func WithJobs(scheduler *jobs.Scheduler) Option {
+	return func(h *Handlers) { h.jobs = scheduler }
+}
+
+// NewHandlers creates a new Handlers instance for db. It renders pages from
+// the built-in templates, and emails from those in their email directory,
+// unless WithTemplateFS says otherwise.
+func NewHandlers(db Store, opts ...Option) *Handlers {
+	h := &Handlers{
+		db:                   db,
+		templates:            web.Templates(),
+		views:                map[string]*template.Template{},
+		live:                 events.NewHub(),
+		now:                  time.Now,
+		logger:               log.Default(),
+		sessionDuration:      SessionDuration,
+		shortSessionDuration: ShortSessionDuration,
+		cookieName:           SessionCookieName,
+		sameSite:             http.SameSiteLaxMode,
+		started:              time.Now(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.challenges = newChallengeStore(h.now)
+	if h.hostPrefix && !strings.HasPrefix(h.cookieName, "__Host-") {
+		h.cookieName = "__Host-" + h.cookieName
+	}
+	if h.secretKey == nil {
+		key, err := db.Secret("signing_key")
+		if err != nil {
+			h.logger.Printf("Failed to load signing key, emailed links will stop working on restart: %v", err)
+			key = make([]byte, 32)
+			_, _ = rand.Read(key)
+		}
+		h.secretKey = key
+	}
+	if h.mailer == nil {
+		emails, _ := fs.Sub(h.templates, "email") // Only fails for invalid names
+		h.mailer = mail.New(mail.LogSender{}, emails)
+	}
+	return h
 }
 
 // CategoryDef defines the properties of a category.
@@ -37,6 +254,7 @@ type CategoryDef struct {
 	Color string
 }
 
+// categories styles models.Categories, in the same order.
 var categories = []CategoryDef{
 	{"Groceries", "🛒", "#60a5fa"},
 	{"Eating Out", "🍴", "#60a5fa"},
@@ -67,7 +285,8 @@ type ExpenseItem struct {
 	DateTime      string // Full datetime for edit modal (2006-01-02T15:04:05)
 	CategoryStyle CategoryStyle
 	IsIncome      bool
-	IsOtherUser   bool // True if this expense was created by a different user
+	IsOtherUser   bool  // True if this expense was created by a different user
+	OwnerID       int64 // Who created it, 0 if nobody knows
 }
 
 // ExpenseGroup groups expenses by date.
@@ -80,8 +299,96 @@ type ExpenseGroup struct {
 
 // ListViewModel is the data passed to the list view template.
 type ListViewModel struct {
-	Total  float64
-	Groups []ExpenseGroup
+	Total      float64
+	Budget     *BudgetProgress // The current budget period against the user's budget, nil without one
+	Count      int
+	AvgPerDay  float64
+	Largest    *ExpenseItem // Largest expense in the period, nil when empty
+	Groups     []ExpenseGroup
+	Category   string // Active category filter, empty for all categories
+	Query      string // Active description search, empty for none
+	ByWeek     bool   // Group by ISO week instead of by day
+	From       string // Start of a picked period (YYYY-MM-DD), empty for the current budget period
+	To         string // Inclusive end of a picked period (YYYY-MM-DD)
+	Month      string // The calendar month shown, e.g. March 2026, empty for other periods
+	PrevMonth  string // List URL of the month before Month
+	NextMonth  string // List URL of the month after Month, empty if that's still to come
+	Categories []CategoryDef
+	MonthTitle string // Heading for a month appended by infinite scroll
+	HasMore    bool   // Whether an earlier month has matching expenses
+	MoreYear   int
+	MoreMonth  int
+	Search     bool   // Lists what searching all months for Query found, instead of a period
+	Limited    bool   // The search found more than it shows
+	UndoToken  string // Offers to undo a deletion, empty for none
+	UndoError  string // Why a deletion couldn't be undone
+	UserID     int64  // The signed-in user, whose avatar the header shows
+	Ledgers    []models.Ledger
+	Ledger     int64 // The ledger shown, picked from Ledgers in the header
+	Guest      bool  // Viewed with a guest link, so without edits or settings
+}
+
+// BudgetProgress is what the user spent in the current budget period, in
+// all categories, against their budget.
+type BudgetProgress struct {
+	Spent   float64
+	Budget  float64
+	Percent float64 // Of the budget spent, at most 100 to fill the bar
+	Over    bool
+}
+
+// ListURL returns the list URL for a category and grouping, keeping the
+// active search and period.
+func (m ListViewModel) ListURL(category string, byWeek bool) string {
+	q := url.Values{}
+	if category != "" {
+		q.Set("category", category)
+	}
+	if m.Query != "" {
+		q.Set("q", m.Query)
+	}
+	if byWeek {
+		q.Set("group", "week")
+	}
+	if m.From != "" {
+		q.Set("from", m.From)
+	}
+	if m.To != "" {
+		q.Set("to", m.To)
+	}
+	if len(q) == 0 {
+		return "/expenses"
+	}
+	return "/expenses?" + q.Encode()
+}
+
+// monthURL returns the list URL for the calendar month starting at month,
+// keeping the active filters.
+func (m ListViewModel) monthURL(month time.Time) string {
+	m.From = month.Format(time.DateOnly)
+	m.To = month.AddDate(0, 1, -1).Format(time.DateOnly)
+	return m.ListURL(m.Category, m.ByWeek)
+}
+
+// ThisMonthURL returns the list URL with the picked period cleared.
+func (m ListViewModel) ThisMonthURL() string {
+	m.From, m.To = "", ""
+	return m.ListURL(m.Category, m.ByWeek)
+}
+
+// InlineEditViewModel is the data passed to the inline edit row template.
+type InlineEditViewModel struct {
+	Item        ExpenseItem
+	Amount      string // Submitted or current amount, as typed
+	Description string
+	Error       string // Validation message, empty when the input is valid
+}
+
+// CategoryPickerViewModel holds data for the options of the category
+// picker.
+type CategoryPickerViewModel struct {
+	Categories []CategoryDef
+	Selected   string
 }
 
 // FormViewModel is the data passed to the create/edit form template.
@@ -94,5 +401,313 @@ type FormViewModel struct {
 
 // LoginViewModel holds data for the login page.
 type LoginViewModel struct {
+	Error       string
+	Notice      string
+	AllowSignup bool   // Whether to link to the registration page
+	Username    string // Filled in for the demo account
+}
+
+// LoginItem represents a login attempt in the security settings page.
+type LoginItem struct {
+	Time      string
+	IP        string
+	UserAgent string
+	Success   bool
+}
+
+// SecurityViewModel holds data for the security settings page.
+type SecurityViewModel struct {
+	Username      string
+	IsAdmin       bool // Links to admin pages
+	Email         string
+	EmailVerified bool
+	EmailNotice   string // Confirmation shown after sending a verification link
+	EmailError    string
+	Passkeys      []PasskeyItem
+	Logins        []LoginItem // Most recent first
+	GraceDays     int         // Days a deleted account can still be restored
+	DeleteError   string
+	ReadOnly      bool // The app is in read-only mode
+}
+
+// SettingsViewModel holds data for the preferences page.
+type SettingsViewModel struct {
+	models.Preferences
+	Currencies []Currency
+	Locales    []Locale
+	Themes     []string
+	CycleDays  []int // Days budget periods can start on
+	Categories []CategoryDef
+	Notice     string
+	Error      string
+}
+
+// ProfileViewModel holds data for the profile page.
+type ProfileViewModel struct {
+	UserID       int64
+	Username     string
+	Role         string
+	Joined       string
+	ExpenseCount int  // Current and archived
+	CanRename    bool // False when a directory manages usernames
+	Notice       string
+	Error        string
+	HasAvatar    bool   // Uploaded one, rather than showing initials
+	AvatarURL    string // Changes with the avatar, so the page shows the latest
+	AvatarError  string
+}
+
+// VerifyEmailViewModel holds data for the email verification result page.
+type VerifyEmailViewModel struct {
+	Email string // The verified address, empty on failure
 	Error string
 }
+
+// PasskeyItem is a registered passkey on the security settings page.
+type PasskeyItem struct {
+	ID       string
+	Name     string
+	Created  string
+	LastUsed string // Empty if never used
+}
+
+// NotificationsViewModel holds data for the notifications page.
+type NotificationsViewModel struct {
+	Channels     []NotificationChannelItem
+	Events       []NotificationEvent
+	Deliveries   []NotificationDeliveryItem // The latest first
+	Ntfy         bool                       // Whether ntfy topics can be added
+	Email        bool                       // Whether the email address can be added
+	EmailAddress string                     // The user's verified address, empty without one
+	PushKey      string                     // Browsers subscribe with, empty without Web Push
+	Topic        string                     // ntfy topic typed, kept when it's rejected
+	WeeklyDigest bool                       // Whether the user opted into the weekly digest
+	Digest       *notify.Notification       // The weekly digest previewed, nil unless asked for
+	Notice       string
+	Error        string
+}
+
+// NotificationChannelItem is a channel on the notifications page.
+type NotificationChannelItem struct {
+	models.NotificationChannel
+	Label string // Where it notifies, e.g. the topic's URL
+	Added string
+}
+
+// NotificationDeliveryItem is a notification that was sent, or failed to be.
+type NotificationDeliveryItem struct {
+	Time    string
+	Channel string
+	Event   string
+	Title   string
+	Failed  bool // Why is only logged
+}
+
+// RegisterViewModel holds data for the registration page.
+type RegisterViewModel struct {
+	Error    string
+	Username string // Submitted username, kept when the form is re-shown
+	Invite   string // Token of the invite link being redeemed, empty for open signup
+	Closed   bool   // Hide the form, e.g. for an unusable invite link
+}
+
+// InviteItem is an invite on the invite management page.
+type InviteItem struct {
+	ID      int64
+	Created string
+	Expires string
+	Status  string // Pending, Used, or Expired
+	Pending bool   // Whether the invite can still be used or revoked
+	UsedBy  string // Username of the account created with it
+}
+
+// JobItem is a background job on the jobs page.
+type JobItem struct {
+	Name     string
+	Interval string
+	Running  bool
+	LastRun  string // Empty if it never ran
+	Duration string
+	Error    string // Error of the last run
+	Failures int64
+	Runs     int64
+	NextRun  string
+}
+
+// JobsViewModel holds data for the background jobs page.
+type JobsViewModel struct {
+	Jobs  []JobItem
+	Error string
+}
+
+// InvitesViewModel holds data for the invite management page.
+type InvitesViewModel struct {
+	Link      string // Link of the invite just created, shown only once
+	Lifetimes []int  // Expiry choices in days
+	Disabled  bool   // Invites don't work with LDAP
+	Invites   []InviteItem
+}
+
+// GuestItem is a guest link on the guest access page.
+type GuestItem struct {
+	ID       int64
+	Name     string
+	Ledger   string // Name of the ledger it shows
+	Created  string
+	Expires  string
+	LastUsed string // Empty if it was never opened
+	Expired  bool
+}
+
+// GuestsViewModel holds data for the guest access page.
+type GuestsViewModel struct {
+	Link      string // Link just created, shown only once
+	Name      string // Name typed for a new link, kept when it's rejected
+	Error     string
+	Lifetimes []int           // Expiry choices in days
+	Ledgers   []models.Ledger // Ledgers that can be shared
+	Ledger    int64           // Picked by default, the one the user looks at
+	Guests    []GuestItem
+}
+
+// ImportViewModel holds data for the CSV import page.
+type ImportViewModel struct {
+	Data        string   // The uploaded file, sent back with each step
+	Columns     []string // From the file's header, empty before it's uploaded
+	Mapping     importer.Mapping
+	DateLayouts []ImportDateLayout
+	Categories  []string
+	Preview     []ImportRow // The first expenses in the file
+	More        int         // Expenses after the preview
+	Rows        int         // Expenses found in the file
+	Credits     int         // Rows skipped as incoming payments
+	Invalid     []importer.RowError
+	Done        bool // The expenses were imported
+	Added       int
+	Duplicates  int // Expenses skipped because they already exist
+	Error       string
+}
+
+// ImportDateLayout is a date format offered on the import page.
+type ImportDateLayout struct {
+	Layout string // In Go's time layout syntax
+	Label  string // An example date
+}
+
+// ImportRow is an expense in the import preview.
+type ImportRow struct {
+	Date        string
+	Description string
+	Category    string
+	Amount      float64
+}
+
+// ReimbursementItem is an expense on the reimbursements page.
+type ReimbursementItem struct {
+	ID          int64
+	Description string
+	Category    string
+	Date        string
+	Amount      float64
+	Since       string // When it entered its state
+}
+
+// ReimbursementGroup holds the outstanding reimbursements in one state.
+type ReimbursementGroup struct {
+	Title string
+	Items []ReimbursementItem
+	Total float64
+}
+
+// ReimbursementsViewModel holds data for the reimbursements page.
+type ReimbursementsViewModel struct {
+	Groups []ReimbursementGroup // Pending, then submitted, without empty ones
+	Total  float64              // Still to be paid back
+	Notice string
+	Error  string
+}
+
+// ChallengesViewModel holds data for the challenges page.
+type ChallengesViewModel struct {
+	Streak    models.Streak
+	Current   *ChallengeItem  // This week's challenge, nil if the user has none
+	History   []ChallengeItem // Earlier challenges, the latest first
+	Completed int             // Of the earlier challenges
+	Limit     string          // Amount typed for a new challenge, kept when it's rejected
+	Notice    string
+	Error     string
+}
+
+// ChallengeItem is a challenge on the challenges page.
+type ChallengeItem struct {
+	models.Challenge
+	Title   string  // The week, e.g. 02 Mar – 08 Mar
+	Percent float64 // Of the limit spent, at most 100 to fill the bar
+	Left    float64 // Still to spend before going over the limit
+	Over    float64 // Spent beyond the limit, for failed challenges
+	Updated string  // When progress was last tracked
+}
+
+// ProjectsViewModel holds data for the projects page.
+type ProjectsViewModel struct {
+	Projects []models.Project
+	Name     string // Name typed for a new project, kept when it's rejected
+	Error    string
+}
+
+// ProjectViewModel holds data for the summary page of a project.
+type ProjectViewModel struct {
+	Project    models.Project
+	Total      float64
+	Count      int
+	Span       string // Dates of the first and last expense, empty without any
+	Days       int    // Days the span covers
+	Categories []StatsCategoryItem
+}
+
+// LedgersViewModel holds data for the ledgers page.
+type LedgersViewModel struct {
+	Ledgers    []models.Ledger
+	Archived   []models.Ledger
+	Current    int64      // The ledger the user's pages show
+	Currencies []Currency // A ledger can be kept in, besides the user's own
+	Name       string     // Name typed for a new ledger, kept when it's rejected
+	Currency   string     // Currency picked for a new ledger, likewise
+	Notice     string
+	Error      string
+}
+
+// LedgerReportViewModel holds data for the report across ledgers.
+type LedgerReportViewModel struct {
+	ViewMode string // "month" or "year"
+	Title    string // The period, e.g. March 2026
+	Year     int
+	Month    int
+	PrevURL  string
+	NextURL  string // Empty for the current period
+	Ledgers  []LedgerReportItem
+	Totals   []CurrencyTotal // Per currency, as amounts aren't converted
+}
+
+// LedgerReportItem is one ledger's row of the report.
+type LedgerReportItem struct {
+	Ledger   models.Ledger
+	Currency string // The one it's kept in, the user's if it has none
+	Total    float64
+	Count    int
+}
+
+// CurrencyTotal adds up the ledgers kept in one currency.
+type CurrencyTotal struct {
+	Currency string
+	Total    float64
+	Count    int
+	Ledgers  int
+}
+
+// ProjectOptionsViewModel holds data for the project options of the
+// expense form.
+type ProjectOptionsViewModel struct {
+	Projects []models.Project
+	Selected int64
+}
@@ -3,13 +3,14 @@ package handlers
 import (
 	"errors"
 	"expense-tracker/internal/models"
+	"fmt"
 	"html/template"
-	"log"
+	"net"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // GetUserFromContext retrieves the authenticated user from request context.
@@ -20,6 +21,73 @@ func GetUserFromContext(r *http.Request) *models.User {
 	return nil
 }
 
+// requestUserID returns the ID of the authenticated user, or zero.
+func requestUserID(r *http.Request) int64 {
+	if user := GetUserFromContext(r); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// maxUserAgentLength caps how much of a User-Agent header is stored.
+const maxUserAgentLength = 512
+
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestOrigin returns the scheme and host the request was sent to, for
+// building absolute links.
+func (h *Handlers) requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if isHTTPS(r) || h.secureCookie {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// capitalize upper-cases the first letter of a message, for showing Go error
+// strings to users.
+func capitalize(msg string) string {
+	if msg == "" {
+		return msg
+	}
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// categoryDefs returns the categories in the database with their styles.
+// Categories added or renamed with expensectl get the default style. If the
+// database can't be read, the built-in categories are used.
+func (h *Handlers) categoryDefs(db Store) []CategoryDef {
+	names, err := db.ListCategories()
+	if err != nil {
+		h.logger.Printf("ListCategories error: %v", err)
+		return categories
+	}
+	defs := make([]CategoryDef, len(names))
+	for i, name := range names {
+		style := getCategoryStyle(name)
+		defs[i] = CategoryDef{Name: name, Icon: style.Icon, Color: style.Color}
+	}
+	return defs
+}
+
 func getCategoryStyle(category string) CategoryStyle {
 	for _, c := range categories {
 		if c.Name == category {
@@ -54,10 +122,57 @@ func parseForm(r *http.Request) (amount float64, desc, category string, date tim
 	return amount, desc, category, date, nil
 }
 
+// parseView returns a view parsed together with the base layout it extends,
+// with functions rendering the way prefs say, parsing it on first use for
+// those preferences. Every request renders a view, so those already parsed
+// are looked up without blocking each other.
+func (h *Handlers) parseView(viewName string, prefs models.Preferences) (*template.Template, error) {
+	key := viewName + "|" + templateVariant(prefs)
+	h.viewsMu.RLock()
+	tmpl, ok := h.views[key]
+	h.viewsMu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	h.viewsMu.Lock()
+	defer h.viewsMu.Unlock()
+	if tmpl, ok := h.views[key]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("base.html").Funcs(template.FuncMap{
+		"asset":    h.assetURL,
+		"readOnly": h.ReadOnly,
+		"devMode":  func() bool { return h.dev != nil },
+	}).Funcs(preferenceFuncs(prefs)).ParseFS(h.templates, "base.html", viewName)
+	if err != nil {
+		return nil, err
+	}
+	h.views[key] = tmpl
+	return tmpl, nil
+}
+
+// ReloadTemplates discards the parsed templates, so the next request for
+// each page parses them again.
+func (h *Handlers) ReloadTemplates() {
+	h.viewsMu.Lock()
+	defer h.viewsMu.Unlock()
+	clear(h.views)
+}
+
+// assetURL returns the URL of a static file for templates, fingerprinted
+// when the handlers know the static assets.
+func (h *Handlers) assetURL(name string) string {
+	if h.assets == nil {
+		return "/static/" + name
+	}
+	return h.assets.URL(name)
+}
+
 func (h *Handlers) render(w http.ResponseWriter, r *http.Request, viewName string, data any) {
-	tmpl, err := template.ParseFiles(filepath.Join(h.templateDir, "base.html"), filepath.Join(h.templateDir, viewName))
+	tmpl, err := h.parseView(viewName, h.pagePreferences(r))
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		h.logger.Printf("Template error: %v", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
 	}
@@ -66,20 +181,48 @@ func (h *Handlers) render(w http.ResponseWriter, r *http.Request, viewName strin
 		target = "content"
 	}
 	if err := tmpl.ExecuteTemplate(w, target, data); err != nil {
-		log.Printf("Template execution error: %v", err)
+		h.logger.Printf("Template execution error: %v", err)
 	}
 }
 
-func formatGroupTitle(date time.Time) string {
+// renderPartial executes a single named template from a view, for htmx
+// requests that swap only part of a page.
+func (h *Handlers) renderPartial(w http.ResponseWriter, r *http.Request, viewName, name string, data any) {
+	tmpl, err := h.parseView(viewName, h.pagePreferences(r))
+	if err != nil {
+		h.logger.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+		h.logger.Printf("Template execution error: %v", err)
+	}
+}
+
+func formatGroupTitle(date, now time.Time) string {
 	dateStr := date.Format("2006-01-02")
-	nowStr := time.Now().Format("2006-01-02")
+	nowStr := now.Format("2006-01-02")
 
 	if dateStr == nowStr {
 		return "TODAY"
 	}
-	yesterdayStr := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	yesterdayStr := now.AddDate(0, 0, -1).Format("2006-01-02")
 	if dateStr == yesterdayStr {
 		return "YESTERDAY"
 	}
 	return strings.ToUpper(date.Format("Mon, 02 Jan '06"))
 }
+
+// startOfISOWeek returns midnight on the Monday of the ISO week containing date.
+func startOfISOWeek(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, date.Location())
+}
+
+// formatWeekTitle formats the heading for the ISO week starting on monday.
+func formatWeekTitle(monday time.Time) string {
+	year, week := monday.ISOWeek()
+	sunday := monday.AddDate(0, 0, 6)
+	return strings.ToUpper(fmt.Sprintf("Week %d '%02d · %s – %s",
+		week, year%100, monday.Format("02 Jan"), sunday.Format("02 Jan")))
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"expense-tracker/internal/config"
+	"expense-tracker/internal/importer"
+	"expense-tracker/internal/models"
+)
+
+// importPreviewRows is how many expenses the import preview shows.
+const importPreviewRows = 10
+
+// importDateLayouts are the date formats offered when importing a CSV file.
+// The first, empty one tries ISO 8601 dates with and without a time.
+var importDateLayouts = []ImportDateLayout{
+	{"", "Automatic (2026-03-31)"},
+	{"02/01/2006", "31/03/2026"},
+	{"01/02/2006", "03/31/2026"},
+	{"02.01.2006", "31.03.2026"},
+	{"02-01-2006", "31-03-2026"},
+	{"2006/01/02", "2026/03/31"},
+}
+
+// ImportExpensesForm renders the first step of importing a CSV file, where
+// the user uploads it.
+func (h *Handlers) ImportExpensesForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "import.html", ImportViewModel{})
+}
+
+// ImportExpenses imports a CSV file in steps. An uploaded file gets columns
+// picked by their names and a preview; changing the mapping shows the
+// preview again, with the file carried along in the form; importing adds the
+// expenses to the user's ledger in one transaction and lists the rows that
+// were skipped.
+func (h *Handlers) ImportExpenses(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	renderError := func(msg string) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.render(w, r, "import.html", ImportViewModel{Error: msg})
+	}
+	tooLarge := func(err error) bool {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			renderError("That file is too large, pick one under " + config.Size(maxBytes.Limit).String())
+			return true
+		}
+		return false
+	}
+	if err := r.ParseForm(); err != nil {
+		if !tooLarge(err) {
+			http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		}
+		return
+	}
+
+	var data string
+	uploaded := false
+	file, _, err := r.FormFile("file")
+	switch {
+	case err == nil:
+		defer file.Close()
+		b, err := io.ReadAll(file)
+		if err != nil {
+			if !tooLarge(err) {
+				renderError("That file couldn't be read")
+			}
+			return
+		}
+		data, uploaded = string(b), true
+	case errors.Is(err, http.ErrNotMultipart):
+		data = r.PostFormValue("data")
+	default:
+		if !tooLarge(err) {
+			renderError("Pick a CSV file to import")
+		}
+		return
+	}
+
+	header, err := importer.Header(strings.NewReader(data))
+	if err != nil {
+		renderError("That file can't be read as CSV: " + err.Error())
+		return
+	}
+	db := h.store(r)
+	categories, err := db.ListCategories()
+	if err != nil {
+		h.logger.Printf("ListCategories error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	m := importer.GuessMapping(header)
+	if !uploaded {
+		m.Date = r.PostFormValue("date")
+		m.Amount = r.PostFormValue("amount")
+		m.Description = r.PostFormValue("description")
+		m.Category = r.PostFormValue("category")
+		m.DateLayout = r.PostFormValue("layout")
+		m.NegativeDebits = r.PostFormValue("negative") != ""
+		m.DefaultCategory = r.PostFormValue("default_category")
+	}
+	if category, ok := importer.MatchCategory(m.DefaultCategory, categories); ok {
+		m.DefaultCategory = category
+	} else {
+		m.DefaultCategory = importer.DefaultMapping().DefaultCategory
+	}
+	m.Categories = categories
+	m.SkipInvalid = true
+
+	viewModel := ImportViewModel{
+		Data:        data,
+		Columns:     header,
+		Mapping:     m,
+		DateLayouts: importDateLayouts,
+		Categories:  categories,
+	}
+	if m.Date == "" || m.Amount == "" || m.Description == "" {
+		viewModel.Error = "Pick the columns holding the date, amount, and description"
+		h.render(w, r, "import.html", viewModel)
+		return
+	}
+	result, err := importer.ParseCSV(strings.NewReader(data), m)
+	if err != nil {
+		viewModel.Error = "That file can't be imported: " + err.Error()
+		h.render(w, r, "import.html", viewModel)
+		return
+	}
+	viewModel.Rows = len(result.Expenses)
+	viewModel.Credits = result.Credits
+	viewModel.Invalid = result.Invalid
+
+	if r.PostFormValue("action") != "import" {
+		for _, e := range result.Expenses[:min(len(result.Expenses), importPreviewRows)] {
+			viewModel.Preview = append(viewModel.Preview, ImportRow{
+				Date:        e.Date.Format("02 Jan 2006"),
+				Description: e.Description,
+				Category:    e.Category,
+				Amount:      e.Amount,
+			})
+		}
+		viewModel.More = viewModel.Rows - len(viewModel.Preview)
+		h.render(w, r, "import.html", viewModel)
+		return
+	}
+
+	ledger := h.ledger(r)
+	for i := range result.Expenses {
+		result.Expenses[i].LedgerID = ledger
+	}
+	added, err := db.ImportExpenses(result.Expenses, user.ID, false)
+	if err != nil {
+		h.logger.Printf("ImportExpenses error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %s imported %d expenses", user.Username, added)
+	viewModel.Data = ""
+	viewModel.Done = true
+	viewModel.Added = added
+	viewModel.Duplicates = len(result.Expenses) - added
+	h.render(w, r, "import.html", viewModel)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// bankStatement is a CSV file as banks export them, with a row that can't
+// be read.
+const bankStatement = `Booking date,Payee,Value,Balance
+02/03/2026,Supermarket,-30.00,970.00
+03/03/2026,Salary,2000.00,2970.00
+04/03/2026,Cinema,-12.00,2958.00
+yesterday,Coffee,-4.50,2953.50
+`
+
+// ImportTestSuite provides a test suite for importing CSV files
+type ImportTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	user     *models.User
+}
+
+// SetupTest runs before each test
+func (s *ImportTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(db.CreateSession("token", s.user.ID, time.Hour, ""))
+}
+
+// TearDownTest runs after each test
+func (s *ImportTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// do sends a request with alice's session cookie through AuthMiddleware.
+func (s *ImportTestSuite) do(req *http.Request) *httptest.ResponseRecorder {
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "token"})
+	w := httptest.NewRecorder()
+	s.handlers.AuthMiddleware(http.HandlerFunc(s.handlers.ImportExpenses)).ServeHTTP(w, req)
+	return w
+}
+
+// upload posts data as the CSV file.
+func (s *ImportTestSuite) upload(data string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "statement.csv")
+	s.Require().NoError(err)
+	_, _ = part.Write([]byte(data))
+	s.Require().NoError(form.Close())
+	req := httptest.NewRequest("POST", "/expenses/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.do(req)
+}
+
+// submit posts the mapping form.
+func (s *ImportTestSuite) submit(form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/expenses/import", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(req)
+}
+
+// mapping returns the bank statement's mapping form as it would be sent.
+func (s *ImportTestSuite) mapping(action string) url.Values {
+	return url.Values{
+		"data":             {bankStatement},
+		"date":             {"Booking date"},
+		"layout":           {"02/01/2006"},
+		"amount":           {"Value"},
+		"negative":         {"1"},
+		"description":      {"Payee"},
+		"category":         {""},
+		"default_category": {"Groceries"},
+		"action":           {action},
+	}
+}
+
+func (s *ImportTestSuite) TestUpload() {
+	w := s.upload(bankStatement)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, `<option value="Booking date" selected>`, "columns are picked by their names")
+	s.Contains(body, `<option value="Payee" selected>`)
+	s.Contains(body, `<option value="Value" selected>`)
+	s.NotContains(body, "Balance\" selected")
+
+	// The data goes back to the browser with the form
+	data := regexp.MustCompile(`name="data" value="([^"]*)"`).FindStringSubmatch(body)
+	s.Require().Len(data, 2)
+	s.Equal(bankStatement, html.UnescapeString(data[1]))
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Empty(expenses, "nothing is imported yet")
+}
+
+func (s *ImportTestSuite) TestPreview() {
+	w := s.submit(s.mapping("preview"))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "<strong>Supermarket</strong>")
+	s.Contains(body, "02 Mar 2026 · Groceries")
+	s.NotContains(body, "<strong>Salary</strong>", "credits are skipped")
+	s.Contains(body, "Line 5: invalid date &#34;yesterday&#34;")
+	s.Contains(body, "Import 2 expenses")
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Empty(expenses)
+}
+
+func (s *ImportTestSuite) TestImport() {
+	ledger, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	prefs := models.DefaultPreferences()
+	prefs.Ledger = ledger.ID
+	s.Require().NoError(s.db.SavePreferences(s.user.ID, prefs))
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 12, Description: "Cinema", Category: "Entertainment",
+		Date: time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), UserID: &s.user.ID, LedgerID: ledger.ID}))
+
+	w := s.submit(s.mapping("import"))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Added 1 expense to your ledger, skipped 1 that already existed and 1 incoming payment.")
+	s.Contains(body, "Line 5: invalid date")
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2)
+	for _, e := range expenses {
+		s.Equal(ledger.ID, e.LedgerID, "the ledger the user looks at")
+	}
+}
+
+func (s *ImportTestSuite) TestInvalidFiles() {
+	w := s.upload("")
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Contains(w.Body.String(), "the file is empty")
+
+	form := s.mapping("import")
+	form.Set("amount", "")
+	w = s.submit(form)
+	s.Contains(w.Body.String(), "Pick the columns holding the date, amount, and description")
+
+	req := httptest.NewRequest("POST", "/expenses/import", strings.NewReader(s.mapping("import").Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Body = http.MaxBytesReader(nil, req.Body, 100)
+	w = s.do(req)
+	s.Equal(http.StatusUnprocessableEntity, w.Code)
+	s.Contains(w.Body.String(), "That file is too large, pick one under")
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Empty(expenses)
+}
+
+// TestImportTestSuite runs the CSV import test suite
+func TestImportTestSuite(t *testing.T) {
+	suite.Run(t, new(ImportTestSuite))
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// inviteLifetimes are the expiry choices offered when creating an invite, in
+// days. The first is the default.
+var inviteLifetimes = []int{7, 1, 30}
+
+// AdminMiddleware only lets admins through. It must be wrapped by
+// AuthMiddleware.
+func (h *Handlers) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(UserContextKey).(*models.User)
+		if !ok || !user.IsAdmin() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Invites renders the invite management page.
+func (h *Handlers) Invites(w http.ResponseWriter, r *http.Request) {
+	h.renderInvites(w, r, "")
+}
+
+// renderInvites renders the invite management page, showing link if an
+// invite was just created.
+func (h *Handlers) renderInvites(w http.ResponseWriter, r *http.Request, link string) {
+	invites, err := h.store(r).ListInvites()
+	if err != nil {
+		h.logger.Printf("Invites error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	viewModel := InvitesViewModel{Link: link, Lifetimes: inviteLifetimes, Disabled: h.ldap != nil}
+	now := h.now()
+	for _, invite := range invites {
+		item := InviteItem{
+			ID:      invite.ID,
+			Created: invite.CreatedAt.Local().Format("02 Jan 2006, 15:04"),
+			Expires: invite.ExpiresAt.Local().Format("02 Jan 2006, 15:04"),
+			UsedBy:  invite.UsedBy,
+		}
+		switch {
+		case invite.UsedAt != nil:
+			item.Status = "Used"
+		case !invite.ExpiresAt.After(now):
+			item.Status = "Expired"
+		default:
+			item.Status = "Pending"
+			item.Pending = true
+		}
+		viewModel.Invites = append(viewModel.Invites, item)
+	}
+	h.render(w, r, "invites.html", viewModel)
+}
+
+// CreateInvite creates a single-use invite link and shows it to the admin.
+// Only a hash of the token is stored, so the link can't be shown again.
+func (h *Handlers) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.ldap != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	days := inviteLifetimes[0]
+	if d, err := strconv.Atoi(r.FormValue("days")); err == nil && d >= 1 && d <= 30 {
+		days = d
+	}
+
+	token, err := auth.GenerateSessionToken()
+	if err != nil {
+		h.logger.Printf("CreateInvite error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.store(r).CreateInvite(auth.HashToken(token), user.ID, h.now().AddDate(0, 0, days)); err != nil {
+		h.logger.Printf("CreateInvite error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %s created an invite valid for %d days", user.Username, days)
+	h.renderInvites(w, r, h.requestOrigin(r)+"/invite/"+token)
+}
+
+// DeleteInvite revokes an invite.
+func (h *Handlers) DeleteInvite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.store(r).DeleteInvite(id); err != nil {
+		h.logger.Printf("DeleteInvite error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Location", `{"path":"/settings/invites", "target":"#content"}`)
+}
+
+// inviteUnusable is shown for invite links that can't be used.
+const inviteUnusable = "This invite link is invalid, has expired, or was already used. Ask for a new one."
+
+// InviteForm renders the registration page for an invite link. Invites work
+// even when open signup is disabled, but not with LDAP, where accounts come
+// from the directory.
+func (h *Handlers) InviteForm(w http.ResponseWriter, r *http.Request) {
+	if h.ldap != nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.PathValue("token")
+	if _, err := h.store(r).GetInvite(auth.HashToken(token)); err != nil {
+		if !errors.Is(err, storage.ErrInviteInvalid) {
+			h.logger.Printf("InviteForm error: %v", err)
+		}
+		h.render(w, r, "register.html", RegisterViewModel{Error: inviteUnusable, Closed: true})
+		return
+	}
+	h.render(w, r, "register.html", RegisterViewModel{Invite: token})
+}
+
+// AcceptInvite creates an account with an invite link and logs the new user
+// in.
+func (h *Handlers) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	if h.ldap != nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.PathValue("token")
+	if err := r.ParseForm(); err != nil {
+		h.render(w, r, "register.html", RegisterViewModel{Error: "Invalid form submission", Invite: token})
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	viewModel := RegisterViewModel{Username: username, Invite: token}
+
+	if viewModel.Error = h.checkNewAccount(h.store(r), username, password, r.FormValue("confirm")); viewModel.Error != "" {
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.logger.Printf("Failed to hash password: %v", err)
+		viewModel.Error = "An error occurred. Please try again."
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+	user, err := h.store(r).RedeemInvite(auth.HashToken(token), username, hash)
+	if errors.Is(err, storage.ErrInviteInvalid) {
+		h.render(w, r, "register.html", RegisterViewModel{Error: inviteUnusable, Closed: true})
+		return
+	}
+	if err != nil {
+		// Most likely a concurrent signup took the username
+		h.logger.Printf("Failed to create user: %v", err)
+		viewModel.Error = "Could not create the account. Please try again."
+		h.render(w, r, "register.html", viewModel)
+		return
+	}
+	h.logger.Printf("Registered user %s with an invite", user.Username)
+
+	if err := h.startSession(w, r, user.ID, true); err != nil {
+		h.logger.Printf("Failed to start session: %v", err)
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/expenses", http.StatusFound)
+}
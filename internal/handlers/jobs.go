@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"expense-tracker/internal/jobs"
+	"net/http"
+	"time"
+)
+
+// Jobs renders the background jobs page for admins.
+func (h *Handlers) Jobs(w http.ResponseWriter, r *http.Request) {
+	h.renderJobs(w, r, "")
+}
+
+// renderJobs renders the background jobs page with an optional error.
+func (h *Handlers) renderJobs(w http.ResponseWriter, r *http.Request, errMsg string) {
+	viewModel := JobsViewModel{Error: errMsg}
+	if h.jobs != nil {
+		for _, s := range h.jobs.Statuses() {
+			item := JobItem{
+				Name:     s.Name,
+				Interval: s.Interval.String(),
+				Running:  s.Running,
+				Error:    s.LastError,
+				Failures: s.Failures,
+				Runs:     s.Runs,
+			}
+			if !s.LastRun.IsZero() {
+				item.LastRun = s.LastRun.Local().Format("02 Jan 2006, 15:04")
+				item.Duration = s.LastDuration.Round(time.Millisecond).String()
+			}
+			if !s.NextRun.IsZero() {
+				item.NextRun = s.NextRun.Local().Format("02 Jan 2006, 15:04")
+			}
+			viewModel.Jobs = append(viewModel.Jobs, item)
+		}
+	}
+	h.render(w, r, "jobs.html", viewModel)
+}
+
+// RunJob starts a background job right away.
+func (h *Handlers) RunJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if h.jobs == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch err := h.jobs.RunNow(name); {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, jobs.ErrRunning):
+		h.renderJobs(w, r, "That job is already running")
+		return
+	case err != nil:
+		h.logger.Printf("RunJob error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %s started job %s", GetUserFromContext(r).Username, name)
+	h.renderJobs(w, r, "")
+}
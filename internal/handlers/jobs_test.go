@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"expense-tracker/internal/jobs"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// JobsTestSuite provides a test suite for the background jobs page
+type JobsTestSuite struct {
+	suite.Suite
+	db        *storage.DB
+	h         *Handlers
+	scheduler *jobs.Scheduler
+	admin     *models.User
+	cancel    context.CancelFunc
+	runs      chan struct{}
+}
+
+// SetupTest runs before each test
+func (s *JobsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.admin, err = db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+
+	s.runs = make(chan struct{}, 1)
+	s.scheduler = jobs.New()
+	s.scheduler.Register(jobs.Job{Name: "cleanup", Interval: time.Hour, Run: func(context.Context) error {
+		s.runs <- struct{}{}
+		return errors.New("database is locked")
+	}})
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.scheduler.Start(ctx)
+	s.h = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithJobs(s.scheduler))
+}
+
+// TearDownTest runs after each test
+func (s *JobsTestSuite) TearDownTest() {
+	s.cancel()
+	s.scheduler.Wait()
+	s.db.Close()
+}
+
+func (s *JobsTestSuite) serve(method, path string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, http.NoBody)
+	req.SetPathValue("name", "cleanup")
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, s.admin))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func (s *JobsTestSuite) TestListAndRun() {
+	w := s.serve("GET", "/settings/jobs", s.h.Jobs)
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "cleanup")
+	s.Contains(w.Body.String(), "Every 1h0m0s")
+	s.Contains(w.Body.String(), "0 runs")
+
+	w = s.serve("POST", "/settings/jobs/cleanup/run", s.h.RunJob)
+	s.Equal(http.StatusOK, w.Code)
+	<-s.runs
+	s.Require().Eventually(func() bool { return s.scheduler.Statuses()[0].Runs == 1 }, 5*time.Second, time.Millisecond)
+
+	w = s.serve("GET", "/settings/jobs", s.h.Jobs)
+	s.Contains(w.Body.String(), "1 run, 1 failed")
+	s.Contains(w.Body.String(), "database is locked")
+}
+
+func (s *JobsTestSuite) TestUnknownJob() {
+	req := httptest.NewRequest("POST", "/settings/jobs/missing/run", http.NoBody)
+	req.SetPathValue("name", "missing")
+	w := httptest.NewRecorder()
+	s.h.RunJob(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+// TestJobsSuite runs the background jobs page test suite
+func TestJobsSuite(t *testing.T) {
+	suite.Run(t, new(JobsTestSuite))
+}
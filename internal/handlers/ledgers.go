@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// maxLedgerNameLength limits ledger names.
+const maxLedgerNameLength = 50
+
+// errHiddenLedger is returned for ledgers of other users, which are treated
+// as missing.
+var errHiddenLedger = fmt.Errorf("another user's ledger: %w", sql.ErrNoRows)
+
+// Ledgers renders the list of the user's and the household's ledgers.
+func (h *Handlers) Ledgers(w http.ResponseWriter, r *http.Request) {
+	h.renderLedgers(w, r, LedgersViewModel{})
+}
+
+// renderLedgers renders the ledgers page, listing the ledgers into
+// viewModel.
+func (h *Handlers) renderLedgers(w http.ResponseWriter, r *http.Request, viewModel LedgersViewModel) {
+	ledgers, err := h.store(r).ListLedgers(requestUserID(r))
+	if err != nil {
+		h.logger.Printf("ListLedgers error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	viewModel.Ledgers, viewModel.Archived = splitArchived(ledgers)
+	viewModel.Current = h.ledger(r)
+	viewModel.Currencies = currencies
+	h.render(w, r, "ledgers.html", viewModel)
+}
+
+// visibleLedger returns a ledger the request's user sees, sql.ErrNoRows if
+// it doesn't exist, or errHiddenLedger if it's another user's.
+func (h *Handlers) visibleLedger(r *http.Request, id int64) (*models.Ledger, error) {
+	ledger, err := h.store(r).GetLedger(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ledger.VisibleTo(requestUserID(r)) {
+		return nil, errHiddenLedger
+	}
+	return ledger, nil
+}
+
+// splitArchived separates the ledgers that can be picked from the archived
+// ones, keeping their order.
+func splitArchived(ledgers []models.Ledger) (active, archived []models.Ledger) {
+	for _, l := range ledgers {
+		if l.ArchivedAt != nil {
+			archived = append(archived, l)
+		} else {
+			active = append(active, l)
+		}
+	}
+	return active, archived
+}
+
+// ledgerCurrency returns the currency a ledger form picked, empty for the
+// user's own, and whether it's one of the listed ones.
+func ledgerCurrency(r *http.Request) (string, bool) {
+	currency := r.FormValue("currency")
+	return currency, currency == "" || validCurrency(currency)
+}
+
+// CreateLedger adds a ledger of the user's own.
+func (h *Handlers) CreateLedger(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	currency, ok := ledgerCurrency(r)
+	switch {
+	case name == "":
+		h.renderLedgers(w, r, LedgersViewModel{Currency: currency, Error: "Name the ledger"})
+		return
+	case utf8.RuneCountInString(name) > maxLedgerNameLength:
+		h.renderLedgers(w, r, LedgersViewModel{Name: name, Currency: currency, Error: fmt.Sprintf("Ledger names are at most %d characters", maxLedgerNameLength)})
+		return
+	case !ok:
+		h.renderLedgers(w, r, LedgersViewModel{Name: name, Error: "Pick one of the listed currencies"})
+		return
+	}
+
+	ledger, err := h.store(r).CreateLedger(name, currency, user.ID)
+	if errors.Is(err, storage.ErrLedgerExists) {
+		h.renderLedgers(w, r, LedgersViewModel{Name: name, Currency: currency, Error: "There's a ledger with that name already"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("CreateLedger error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderLedgers(w, r, LedgersViewModel{Notice: "Added " + ledger.Name})
+}
+
+// DeleteLedger removes a ledger without expenses.
+func (h *Handlers) DeleteLedger(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	// Before deleting it moves users off it
+	shown := h.ledger(r)
+	_, err := h.visibleLedger(r, id)
+	if err == nil {
+		err = h.store(r).DeleteLedger(id)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Ledger not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrLedgerInUse):
+		message := "Only ledgers without expenses can be deleted"
+		if id == models.PersonalLedgerID {
+			message = "The personal ledger can't be deleted"
+		}
+		h.renderLedgers(w, r, LedgersViewModel{Error: message})
+		return
+	case err != nil:
+		h.logger.Printf("DeleteLedger error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Users who had it picked were moved to the personal ledger
+	if id == shown {
+		reloadExpenses(w, r)
+		return
+	}
+	h.renderLedgers(w, r, LedgersViewModel{})
+}
+
+// SetLedgerCurrency changes the currency a ledger is kept in.
+func (h *Handlers) SetLedgerCurrency(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	currency, ok := ledgerCurrency(r)
+	if !ok {
+		h.renderLedgers(w, r, LedgersViewModel{Error: "Pick one of the listed currencies"})
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	_, err := h.visibleLedger(r, id)
+	if err == nil {
+		err = h.store(r).SetLedgerCurrency(id, currency)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Ledger not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Printf("SetLedgerCurrency error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderLedgers(w, r, LedgersViewModel{Notice: "Currency saved"})
+}
+
+// ArchiveLedger puts a ledger away: it's no longer offered, but its
+// expenses stay and still count in the report across ledgers.
+func (h *Handlers) ArchiveLedger(w http.ResponseWriter, r *http.Request) {
+	h.archiveLedger(w, r, true)
+}
+
+// UnarchiveLedger brings an archived ledger back.
+func (h *Handlers) UnarchiveLedger(w http.ResponseWriter, r *http.Request) {
+	h.archiveLedger(w, r, false)
+}
+
+func (h *Handlers) archiveLedger(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	shown := h.ledger(r)
+	_, err := h.visibleLedger(r, id)
+	if err == nil {
+		err = h.store(r).ArchiveLedger(id, archived)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Ledger not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrLedgerInUse):
+		h.renderLedgers(w, r, LedgersViewModel{Error: "The personal ledger can't be archived"})
+		return
+	case err != nil:
+		h.logger.Printf("ArchiveLedger error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Users who had it picked were moved to the personal ledger
+	if archived && id == shown {
+		reloadExpenses(w, r)
+		return
+	}
+	h.renderLedgers(w, r, LedgersViewModel{})
+}
+
+// LedgerReport renders what was spent in every ledger the user sees in a
+// month, or a year with view=year, archived ledgers included, and the totals
+// across them per currency.
+func (h *Handlers) LedgerReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := h.userNow(r)
+	year, month := now.Year(), int(now.Month())
+	if y, err := strconv.Atoi(query.Get("year")); err == nil {
+		year = y
+	}
+	if m, err := strconv.Atoi(query.Get("month")); err == nil && m >= 1 && m <= 12 {
+		month = m
+	}
+
+	if h.notModified(w, r) {
+		return
+	}
+
+	viewModel := LedgerReportViewModel{ViewMode: "month", Year: year, Month: month}
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periodURL := func(t time.Time) string {
+		return fmt.Sprintf("/ledgers/report?year=%d&month=%d", t.Year(), t.Month())
+	}
+	if query.Get("view") == "year" {
+		viewModel.ViewMode = "year"
+		start = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(1, 0, 0)
+		current = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		periodURL = func(t time.Time) string {
+			return fmt.Sprintf("/ledgers/report?view=year&year=%d", t.Year())
+		}
+		viewModel.Title = strconv.Itoa(year)
+		viewModel.PrevURL = periodURL(start.AddDate(-1, 0, 0))
+	} else {
+		viewModel.Title = start.Format("January 2006")
+		viewModel.PrevURL = periodURL(start.AddDate(0, -1, 0))
+	}
+	if start.Before(current) {
+		viewModel.NextURL = periodURL(end)
+	}
+
+	totals, err := h.store(r).LedgerTotals(requestUserID(r), start, end)
+	if err != nil {
+		h.logger.Printf("LedgerTotals error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	own := h.preferences(r).Currency
+	for _, t := range totals {
+		item := LedgerReportItem{Ledger: t.Ledger, Currency: t.Ledger.Currency, Total: t.Total, Count: t.Count}
+		if item.Currency == "" {
+			item.Currency = own
+		}
+		viewModel.Ledgers = append(viewModel.Ledgers, item)
+
+		i := slices.IndexFunc(viewModel.Totals, func(c CurrencyTotal) bool { return c.Currency == item.Currency })
+		if i < 0 {
+			viewModel.Totals = append(viewModel.Totals, CurrencyTotal{Currency: item.Currency})
+			i = len(viewModel.Totals) - 1
+		}
+		viewModel.Totals[i].Total += item.Total
+		viewModel.Totals[i].Count += item.Count
+		viewModel.Totals[i].Ledgers++
+	}
+	h.render(w, r, "ledger-report.html", viewModel)
+}
+
+// SwitchLedger picks the ledger whose expenses and statistics the user's
+// pages show, and reloads the list.
+func (h *Handlers) SwitchLedger(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("ledger"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ledger", http.StatusBadRequest)
+		return
+	}
+	db := h.store(r)
+	ledger, err := h.visibleLedger(r, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Ledger not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Printf("GetLedger error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if ledger.ArchivedAt != nil {
+		http.Error(w, "Ledger is archived", http.StatusBadRequest)
+		return
+	}
+
+	prefs := h.preferences(r)
+	prefs.Ledger = id
+	if err := db.SavePreferences(user.ID, prefs); err != nil {
+		h.logger.Printf("SwitchLedger error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	reloadExpenses(w, r)
+}
+
+// reloadExpenses sends the browser to the expense list, loaded afresh so
+// every part of the page shows the ledger now picked.
+func reloadExpenses(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/expenses")
+		return
+	}
+	http.Redirect(w, r, "/expenses", http.StatusSeeOther)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LedgersTestSuite provides a test suite for ledgers
+type LedgersTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	now      time.Time
+}
+
+// SetupTest runs before each test
+func (s *LedgersTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *LedgersTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// do sends a request to handler as user 1, whose preferences load like
+// after AuthMiddleware, with form as its body unless it's nil.
+func (s *LedgersTestSuite) do(method, target string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	return s.doAs(1, method, target, handler, form)
+}
+
+// doAs sends a request like do as another user.
+func (s *LedgersTestSuite) doAs(userID int64, method, target string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	body := io.Reader(http.NoBody)
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, prefix := range []string{"/ledgers/", "/expenses/"} {
+		if rest, ok := strings.CutPrefix(target, prefix); ok {
+			id, _, _ := strings.Cut(rest, "/")
+			req.SetPathValue("id", id)
+		}
+	}
+	req = asUser(req, userID)
+	req = req.WithContext(s.handlers.withPreferences(req.Context(), s.db, userID))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func (s *LedgersTestSuite) TestSwitch() {
+	business, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 10, Description: "Groceries run", Category: "Groceries", Date: day}))
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 250, Description: "Printer", Category: "Shopping", Date: day, LedgerID: business.ID}))
+
+	w := s.do("GET", "/expenses", s.handlers.ListExpenses, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Groceries run")
+	s.NotContains(body, "Printer", "only the personal ledger")
+	s.Contains(body, `<option value="2">Business</option>`)
+
+	w = s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"2"}})
+	s.Require().Equal(http.StatusSeeOther, w.Code, w.Body.String())
+	s.Equal("/expenses", w.Header().Get("Location"))
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(business.ID, prefs.Ledger)
+
+	w = s.do("GET", "/expenses", s.handlers.ListExpenses, nil)
+	body = w.Body.String()
+	s.Contains(body, "Printer")
+	s.NotContains(body, "Groceries run")
+	s.Contains(body, `<option value="2" selected>Business</option>`)
+
+	w = s.do("GET", "/statistics?year=2026&month=3", s.handlers.Statistics, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "€250.00")
+	s.NotContains(w.Body.String(), "€260.00", "not summed with the personal ledger")
+
+	w = s.do("POST", "/expenses", s.handlers.CreateExpense, url.Values{"amount": {"30"}, "description": {"Paper"}, "category": {"Shopping"}, "date": {"2026-03-03T09:00:00"}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{Query: "Paper"})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(business.ID, expenses[0].LedgerID, "added to the ledger shown")
+
+	w = s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"99"}})
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *LedgersTestSuite) TestPage() {
+	w := s.do("GET", "/expenses", s.handlers.ListExpenses, nil)
+	s.NotContains(w.Body.String(), "ledger-switcher", "nothing to switch between")
+
+	w = s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {" Business "}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Added Business")
+	s.Contains(w.Body.String(), "<strong>Business</strong>")
+	w = s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {"business"}})
+	s.Contains(w.Body.String(), "There&#39;s a ledger with that name already")
+	w = s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {strings.Repeat("a", maxLedgerNameLength+1)}})
+	s.Contains(w.Body.String(), "Ledger names are at most 50 characters")
+
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 250, Description: "Printer", Category: "Shopping", Date: s.now, LedgerID: 2}))
+	w = s.do("DELETE", "/ledgers/2", s.handlers.DeleteLedger, nil)
+	s.Contains(w.Body.String(), "Only ledgers without expenses can be deleted")
+	w = s.do("DELETE", "/ledgers/1", s.handlers.DeleteLedger, nil)
+	s.Contains(w.Body.String(), "The personal ledger can&#39;t be deleted")
+	w = s.do("DELETE", "/ledgers/99", s.handlers.DeleteLedger, nil)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	s.Require().NoError(s.db.DeleteExpense(1))
+	w = s.do("DELETE", "/ledgers/2", s.handlers.DeleteLedger, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.NotContains(w.Body.String(), "<strong>Business</strong>")
+}
+
+func (s *LedgersTestSuite) TestOtherUsersLedgers() {
+	w := s.doAs(2, "POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {"Business"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 250, Description: "Printer", Category: "Shopping", Date: s.now, LedgerID: 2}))
+	w = s.doAs(2, "GET", "/ledgers/report?year=2026&month=3", s.handlers.LedgerReport, nil)
+	s.Contains(w.Body.String(), "€250.00")
+
+	// User 1 neither sees user 2's ledger nor can pick or change it
+	w = s.do("GET", "/ledgers", s.handlers.Ledgers, nil)
+	s.NotContains(w.Body.String(), "<strong>Business</strong>")
+	w = s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"2"}})
+	s.Equal(http.StatusNotFound, w.Code)
+	s.Equal(http.StatusNotFound, s.do("POST", "/ledgers/2/archive", s.handlers.ArchiveLedger, nil).Code)
+	s.Equal(http.StatusNotFound, s.do("POST", "/ledgers/2/currency", s.handlers.SetLedgerCurrency, url.Values{"currency": {"CHF"}}).Code)
+	s.Equal(http.StatusNotFound, s.do("DELETE", "/ledgers/2", s.handlers.DeleteLedger, nil).Code)
+	w = s.do("GET", "/ledgers/report?year=2026&month=3", s.handlers.LedgerReport, nil)
+	s.NotContains(w.Body.String(), "Business")
+	w = s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {"Business"}})
+	s.Contains(w.Body.String(), "Added Business", "names are per user")
+
+	// Nor its expenses
+	s.Equal(http.StatusNotFound, s.do("GET", "/expenses/1/edit", s.handlers.EditExpenseForm, nil).Code)
+	s.Equal(http.StatusNotFound, s.do("DELETE", "/expenses/1", s.handlers.DeleteExpense, nil).Code)
+	_, err := s.db.GetExpense(1)
+	s.NoError(err, "not deleted")
+
+	// A ledger the preferences name but the user can't see isn't shown
+	prefs := models.DefaultPreferences()
+	prefs.Ledger = 2
+	s.Require().NoError(s.db.SavePreferences(1, prefs))
+	w = s.do("GET", "/expenses", s.handlers.ListExpenses, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.NotContains(w.Body.String(), "Printer")
+}
+
+func (s *LedgersTestSuite) TestArchive() {
+	club, err := s.db.CreateLedger("Club", "", 0)
+	s.Require().NoError(err)
+	w := s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"2"}})
+	s.Require().Equal(http.StatusSeeOther, w.Code)
+
+	w = s.do("POST", "/ledgers/2/archive", s.handlers.ArchiveLedger, nil)
+	s.Require().Equal(http.StatusSeeOther, w.Code, "the ledger shown")
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(int64(models.PersonalLedgerID), prefs.Ledger)
+
+	w = s.do("GET", "/ledgers", s.handlers.Ledgers, nil)
+	body := w.Body.String()
+	s.Contains(body, "Archived")
+	s.Contains(body, `hx-post="/ledgers/2/unarchive"`)
+	w = s.do("GET", "/expenses", s.handlers.ListExpenses, nil)
+	s.NotContains(w.Body.String(), "ledger-switcher", "archived ledgers aren't offered")
+	w = s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"2"}})
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = s.do("POST", "/ledgers/1/archive", s.handlers.ArchiveLedger, nil)
+	s.Contains(w.Body.String(), "The personal ledger can&#39;t be archived")
+	w = s.do("POST", "/ledgers/99/archive", s.handlers.ArchiveLedger, nil)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w = s.do("POST", "/ledgers/2/unarchive", s.handlers.UnarchiveLedger, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	got, err := s.db.GetLedger(club.ID)
+	s.Require().NoError(err)
+	s.Nil(got.ArchivedAt)
+}
+
+func (s *LedgersTestSuite) TestCurrency() {
+	w := s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {"Club"}, "currency": {"CHF"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `<option value="CHF" selected>`)
+	w = s.do("POST", "/ledgers", s.handlers.CreateLedger, url.Values{"name": {"Trip"}, "currency": {"XYZ"}})
+	s.Contains(w.Body.String(), "Pick one of the listed currencies")
+
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 40, Description: "Balls", Category: "Shopping", Date: s.now, LedgerID: 2}))
+	w = s.do("POST", "/ledger", s.handlers.SwitchLedger, url.Values{"ledger": {"2"}})
+	s.Require().Equal(http.StatusSeeOther, w.Code)
+	w = s.do("GET", "/statistics?year=2026&month=3", s.handlers.Statistics, nil)
+	s.Contains(w.Body.String(), "CHF\u00a040.00", "in the ledger's currency")
+	s.NotContains(w.Body.String(), "€40.00")
+	w = s.do("GET", "/settings", s.handlers.Settings, nil)
+	s.Contains(w.Body.String(), `<option value="EUR" selected>`, "the user's own stays")
+
+	w = s.do("POST", "/ledgers/2/currency", s.handlers.SetLedgerCurrency, url.Values{"currency": {""}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Currency saved")
+	w = s.do("GET", "/statistics?year=2026&month=3", s.handlers.Statistics, nil)
+	s.Contains(w.Body.String(), "€40.00")
+	w = s.do("POST", "/ledgers/99/currency", s.handlers.SetLedgerCurrency, url.Values{"currency": {"CHF"}})
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *LedgersTestSuite) TestReport() {
+	club, err := s.db.CreateLedger("Club", "CHF", 0)
+	s.Require().NoError(err)
+	business, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	for _, e := range []*models.Expense{
+		{Amount: 10, LedgerID: models.PersonalLedgerID},
+		{Amount: 250, LedgerID: business.ID},
+		{Amount: 40, LedgerID: club.ID},
+	} {
+		e.Description, e.Category, e.Date = "Things", "Shopping", s.now
+		s.Require().NoError(s.db.InsertExpense(e))
+	}
+	s.Require().NoError(s.db.ArchiveLedger(club.ID, true))
+
+	w := s.do("GET", "/ledgers/report", s.handlers.LedgerReport, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "March 2026")
+	s.Contains(body, "€260.00", "the ledgers in euros added up")
+	s.Contains(body, "2 expenses in 2 ledgers")
+	s.Contains(body, "CHF\u00a040.00", "apart from those in francs")
+	s.Contains(body, "added up separately")
+	s.Contains(body, "· archived")
+	s.Contains(body, `hx-get="/ledgers/report?year=2026&amp;month=2"`)
+	s.NotContains(body, "month=4", "nothing after the current month")
+
+	w = s.do("GET", "/ledgers/report?view=year&year=2025", s.handlers.LedgerReport, nil)
+	body = w.Body.String()
+	s.Contains(body, "€0.00")
+	s.Contains(body, `hx-get="/ledgers/report?view=year&amp;year=2026"`)
+}
+
+// TestLedgersTestSuite runs the ledgers test suite
+func TestLedgersTestSuite(t *testing.T) {
+	suite.Run(t, new(LedgersTestSuite))
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/models"
+)
+
+// ExpenseListChanges streams a server-sent "list-changed" event whenever
+// someone adds, edits, or deletes an expense, for the expense list to fetch
+// itself again. It's the htmx page's counterpart of LiveUpdates.
+func (h *Handlers) ExpenseListChanges(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sub, err := h.live.Subscribe(user.ID)
+	switch {
+	case errors.Is(err, events.ErrTooManySubscriptions):
+		http.Error(w, "Too many live connections", http.StatusTooManyRequests)
+		return
+	case err != nil:
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Cancel()
+
+	// The stream stays open far longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, ": connected\n\n")
+	_ = rc.Flush()
+
+	// Comments keep proxies from closing the stream while nothing changes
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Fell behind or shutting down: the browser reconnects, and
+				// the page refreshes in case it missed something
+				return
+			}
+			fmt.Fprintf(w, "event: list-changed\ndata: %s\n\n", e.Type)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
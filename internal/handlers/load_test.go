@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"expense-tracker/internal/loadtest"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loadExpenses sizes the database of the load benchmarks, e.g.
+//
+//	go test ./internal/handlers -run '^$' -bench Load -load.expenses 50000
+var loadExpenses = flag.Int("load.expenses", 500_000, "expenses in the database of the load benchmarks")
+
+// loadNow is the clock of the load benchmarks, so the populated database
+// can be reused between runs.
+var loadNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+var (
+	loadOnce  sync.Once
+	loadDB    *storage.DB
+	loadUsers []*models.User
+	loadErr   error
+)
+
+// openLoadDB returns the database of the load benchmarks, populating it on
+// first use. It is kept in the temporary directory, since filling it takes
+// a while, and shared by all the benchmarks of a run.
+func openLoadDB(b *testing.B) (*storage.DB, []*models.User) {
+	b.Helper()
+	loadOnce.Do(func() {
+		opts := loadtest.Options{Users: 5, Expenses: *loadExpenses, Years: 5, Now: loadNow}
+		path := filepath.Join(os.TempDir(), fmt.Sprintf("expense-tracker-load-%d.db", opts.Expenses))
+		if _, err := os.Stat(path); err != nil {
+			start := time.Now()
+			if loadErr = populateLoadDB(path, opts); loadErr != nil {
+				return
+			}
+			b.Logf("populated %d expenses for %d users in %s", opts.Expenses, opts.Users, time.Since(start).Round(time.Millisecond))
+		}
+		if loadDB, loadErr = storage.NewDB(path); loadErr != nil {
+			return
+		}
+		// The server's pool, so parallel requests aren't served one by one
+		loadDB.SetPool(storage.Pool{MaxOpen: 8, MaxIdle: 8})
+		for i := range opts.Users {
+			user, err := loadDB.GetUserByUsername(fmt.Sprintf("user%d", i+1))
+			if err != nil {
+				loadErr = err
+				return
+			}
+			loadUsers = append(loadUsers, user)
+		}
+	})
+	if loadErr != nil {
+		b.Fatal(loadErr)
+	}
+	return loadDB, loadUsers
+}
+
+// populateLoadDB fills a new database at path, only moving it there once
+// complete so an interrupted run doesn't leave half of one behind.
+func populateLoadDB(path string, opts loadtest.Options) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	db, err := storage.NewDB(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := loadtest.Populate(db, opts); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// newLoadHandlers returns handlers over the load database with the built-in
+// templates.
+func newLoadHandlers(b *testing.B) *Handlers {
+	db, _ := openLoadDB(b)
+	return NewHandlers(db, WithClock(func() time.Time { return loadNow }), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// benchmarkRequests serves the request newRequest makes for each user in
+// turn from parallel goroutines, failing on any other status than want, and
+// reports the throughput.
+func benchmarkRequests(b *testing.B, handler http.HandlerFunc, want int, newRequest func(user *models.User) *http.Request) {
+	_, users := openLoadDB(b)
+	var next sync.Mutex
+	turn := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			next.Lock()
+			user := users[turn%len(users)]
+			turn++
+			next.Unlock()
+
+			rec := httptest.NewRecorder()
+			handler(rec, newRequest(user))
+			if rec.Code != want {
+				b.Errorf("status %d, want %d", rec.Code, want)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "req/s")
+}
+
+// withUser returns a GET request made by user, as AuthMiddleware leaves it.
+func withUser(target string, user *models.User) *http.Request {
+	req := httptest.NewRequest("GET", target, http.NoBody)
+	return req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+}
+
+func BenchmarkLoad_ListExpenses(b *testing.B) {
+	h := newLoadHandlers(b)
+	for _, bench := range []struct{ name, target string }{
+		{"month", "/expenses"},
+		{"search", "/expenses?q=coffee"},
+		{"year", "/expenses?from=2024-06-15&to=2025-06-15"},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			benchmarkRequests(b, h.ListExpenses, http.StatusOK, func(user *models.User) *http.Request {
+				return withUser(bench.target, user)
+			})
+		})
+	}
+}
+
+func BenchmarkLoad_Statistics(b *testing.B) {
+	h := newLoadHandlers(b)
+	for _, bench := range []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{"month", h.Statistics, "/statistics?view=month"},
+		{"year", h.Statistics, "/statistics?view=year"},
+		{"category", h.StatisticsExpenses, "/statistics/expenses?year=2025&category=" + url.QueryEscape(models.Categories[0])},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			benchmarkRequests(b, bench.handler, http.StatusOK, func(user *models.User) *http.Request {
+				return withUser(bench.target, user)
+			})
+		})
+	}
+}
+
+func BenchmarkLoad_Export(b *testing.B) {
+	h := newLoadHandlers(b)
+	benchmarkRequests(b, h.ExportExpenses, http.StatusOK, func(user *models.User) *http.Request {
+		return withUser("/expenses/export", user)
+	})
+}
+
+func BenchmarkLoad_Login(b *testing.B) {
+	h := newLoadHandlers(b)
+	benchmarkRequests(b, h.Login, http.StatusFound, func(user *models.User) *http.Request {
+		form := url.Values{"username": {user.Username}, "password": {loadtest.Password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	})
+}
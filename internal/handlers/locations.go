@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// maxPlaceLength limits the place names typed in the expense form.
+const maxPlaceLength = 100
+
+// placeReportSize is how many places the statistics page lists.
+const placeReportSize = 10
+
+// PlaceItem is a place in the spending by place report.
+type PlaceItem struct {
+	Label  string // The place name, or its coordinates if unnamed
+	MapURL string
+	Total  float64
+	Count  int
+}
+
+// parseLocation reads the location fields of the expense form, after
+// ParseForm. present is false when the form has none, e.g. an inline edit,
+// which leaves the location as it is.
+func parseLocation(r *http.Request) (loc models.Location, present bool, err error) {
+	if _, present = r.Form["place"]; !present {
+		return models.Location{}, false, nil
+	}
+	loc.Place = strings.TrimSpace(r.FormValue("place"))
+	if utf8.RuneCountInString(loc.Place) > maxPlaceLength {
+		return models.Location{}, true, fmt.Errorf("place must be at most %d characters", maxPlaceLength)
+	}
+
+	latitude, longitude := r.FormValue("latitude"), r.FormValue("longitude")
+	if latitude == "" && longitude == "" {
+		return loc, true, nil
+	}
+	lat, latErr := strconv.ParseFloat(latitude, 64)
+	lon, lonErr := strconv.ParseFloat(longitude, 64)
+	if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return models.Location{}, true, errors.New("latitude and longitude must be valid coordinates")
+	}
+	loc.Latitude, loc.Longitude = &lat, &lon
+	return loc, true, nil
+}
+
+// mapURL links to a location on OpenStreetMap: its coordinates if known,
+// or else a search for its name.
+func mapURL(loc models.Location) string {
+	switch {
+	case loc.Latitude != nil:
+		return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%[1]f&mlon=%[2]f#map=17/%[1]f/%[2]f", *loc.Latitude, *loc.Longitude)
+	case loc.Place != "":
+		return "https://www.openstreetmap.org/search?query=" + url.QueryEscape(loc.Place)
+	}
+	return ""
+}
+
+// placeItems returns the places the most was spent at in [from, to), in a
+// ledger.
+func (h *Handlers) placeItems(db Store, ledgerID int64, from, to time.Time) []PlaceItem {
+	totals, err := db.SpendingByPlace(storage.ExpenseFilter{From: from, To: to, Ledger: ledgerID}, placeReportSize)
+	if err != nil {
+		// The rest of the statistics are still worth showing
+		h.logger.Printf("SpendingByPlace error: %v", err)
+		return nil
+	}
+	items := make([]PlaceItem, len(totals))
+	for i, t := range totals {
+		label := t.Location.Place
+		if label == "" {
+			label = fmt.Sprintf("%.3f, %.3f", *t.Location.Latitude, *t.Location.Longitude)
+		}
+		items[i] = PlaceItem{Label: label, MapURL: mapURL(t.Location), Total: t.Total, Count: t.Count}
+	}
+	return items
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LocationsTestSuite provides a test suite for expense locations
+type LocationsTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+}
+
+// SetupTest runs before each test
+func (s *LocationsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *LocationsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// post sends the expense form with the given location fields to handler
+// as user 1.
+func (s *LocationsTestSuite) post(target string, handler http.HandlerFunc, location url.Values) *httptest.ResponseRecorder {
+	form := url.Values{"amount": {"4.50"}, "description": {"Coffee"}, "category": {"Eating Out"}, "date": {"2026-03-02T08:30:00"}}
+	for k, v := range location {
+		form[k] = v
+	}
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id, ok := strings.CutPrefix(target, "/expenses/"); ok {
+		req.SetPathValue("id", id)
+	}
+	w := httptest.NewRecorder()
+	handler(w, asUser(req, 1))
+	return w
+}
+
+// location returns the location of expense 1 as the expense form gets it.
+func (s *LocationsTestSuite) location() map[string]any {
+	req := httptest.NewRequest("GET", "/expenses/1/details", http.NoBody)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	s.handlers.ExpenseDetails(w, asUser(req, 1))
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var details struct{ Location map[string]any }
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &details))
+	return details.Location
+}
+
+func (s *LocationsTestSuite) TestCreateAndEdit() {
+	w := s.post("/expenses", s.handlers.CreateExpense, url.Values{"latitude": {"52.370216"}, "longitude": {"4.895168"}, "place": {" Café "}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(map[string]any{
+		"latitude": 52.370216, "longitude": 4.895168, "place": "Café",
+		"map_url": "https://www.openstreetmap.org/?mlat=52.370216&mlon=4.895168#map=17/52.370216/4.895168",
+	}, s.location())
+
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, url.Values{"place": {"Bakery"}, "latitude": {""}, "longitude": {""}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(map[string]any{"place": "Bakery", "map_url": "https://www.openstreetmap.org/search?query=Bakery"}, s.location())
+
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal("Bakery", s.location()["place"], "kept without location fields")
+
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, url.Values{"place": {""}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Empty(s.location(), "removed")
+}
+
+func (s *LocationsTestSuite) TestInvalid() {
+	for _, location := range []url.Values{
+		{"place": {""}, "latitude": {"91"}, "longitude": {"0"}},
+		{"place": {""}, "latitude": {"52.37"}},
+		{"place": {""}, "latitude": {"x"}, "longitude": {"y"}},
+		{"place": {strings.Repeat("a", maxPlaceLength+1)}},
+	} {
+		w := s.post("/expenses", s.handlers.CreateExpense, location)
+		s.Equal(http.StatusBadRequest, w.Code, location)
+	}
+	_, err := s.db.GetExpense(1)
+	s.Error(err, "nothing saved")
+}
+
+func (s *LocationsTestSuite) TestStatistics() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, place := range []string{"Café", "Café", "Kiosk"} {
+		e := &models.Expense{Amount: float64(i + 1), Description: "Coffee", Category: "Eating Out", Date: day}
+		s.Require().NoError(s.db.InsertExpense(e))
+		s.Require().NoError(s.db.SetExpenseLocation(e.ID, models.Location{Place: place}))
+	}
+
+	w := httptest.NewRecorder()
+	s.handlers.Statistics(w, asUser(httptest.NewRequest("GET", "/statistics?year=2026&month=3", http.NoBody), 1))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Spending by Place")
+	s.Contains(body, `<a href="https://www.openstreetmap.org/search?query=Caf%C3%A9" target="_blank" rel="noopener">Café</a>`)
+	s.Less(strings.Index(body, ">Café<"), strings.Index(body, ">Kiosk<"), "the largest total first")
+
+	w = httptest.NewRecorder()
+	s.handlers.Statistics(w, asUser(httptest.NewRequest("GET", "/statistics?year=2026&month=4", http.NoBody), 1))
+	s.NotContains(w.Body.String(), "Spending by Place", "only the period's")
+}
+
+// TestLocationsTestSuite runs the expense locations test suite
+func TestLocationsTestSuite(t *testing.T) {
+	suite.Run(t, new(LocationsTestSuite))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// readOnlyMessage is shown when a change is refused in read-only mode.
+const readOnlyMessage = "Expense Tracker is in read-only mode for maintenance. Changes can't be saved right now, please try again later."
+
+// readOnlyExempt are the write requests allowed in read-only mode: signing
+// in, so expenses stay readable, and switching read-only mode off.
+var readOnlyExempt = map[string]bool{
+	"/login":                 true,
+	"/login/passkey/options": true,
+	"/login/passkey":         true,
+	"/settings/maintenance":  true,
+}
+
+// ReadOnly reports whether the app is in read-only mode.
+func (h *Handlers) ReadOnly() bool {
+	return h.readOnly.Load()
+}
+
+// SetReadOnly switches read-only mode on or off.
+func (h *Handlers) SetReadOnly(readOnly bool) {
+	h.readOnly.Store(readOnly)
+}
+
+// ReadOnlyMiddleware refuses requests that change data while the app is in
+// read-only mode, e.g. during a backup, and lets reads through.
+func (h *Handlers) ReadOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if h.ReadOnly() && !readOnlyExempt[r.URL.Path] {
+				w.Header().Set("Retry-After", "300")
+				http.Error(w, readOnlyMessage, http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ToggleMaintenance switches read-only mode on or off for everyone. It must
+// be wrapped by AdminMiddleware.
+func (h *Handlers) ToggleMaintenance(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	readOnly := r.FormValue("read_only") == "true"
+	h.SetReadOnly(readOnly)
+	if readOnly {
+		h.logger.Printf("User %s turned on read-only mode", user.Username)
+	} else {
+		h.logger.Printf("User %s turned off read-only mode", user.Username)
+	}
+
+	// Reload the whole page so the banner appears or disappears
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
+		return
+	}
+	http.Redirect(w, r, "/settings/security", http.StatusFound)
+}
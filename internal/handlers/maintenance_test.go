@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// MaintenanceTestSuite provides a test suite for read-only mode
+type MaintenanceTestSuite struct {
+	suite.Suite
+	db *storage.DB
+	h  *Handlers
+}
+
+// SetupTest runs before each test
+func (s *MaintenanceTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.h = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithReadOnly(true))
+}
+
+// TearDownTest runs after each test
+func (s *MaintenanceTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *MaintenanceTestSuite) serve(method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.h.ReadOnlyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(w, httptest.NewRequest(method, path, http.NoBody))
+	return w
+}
+
+func (s *MaintenanceTestSuite) TestBlocksWrites() {
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		w := s.serve(method, "/expenses/1")
+		s.Equal(http.StatusServiceUnavailable, w.Code, method)
+		s.Contains(w.Body.String(), "read-only mode")
+		s.NotEmpty(w.Header().Get("Retry-After"))
+	}
+
+	s.Equal(http.StatusNoContent, s.serve("GET", "/expenses").Code, "reads still work")
+	s.Equal(http.StatusNoContent, s.serve("POST", "/login").Code, "signing in still works")
+	s.Equal(http.StatusNoContent, s.serve("POST", "/settings/maintenance").Code)
+
+	s.h.SetReadOnly(false)
+	s.Equal(http.StatusNoContent, s.serve("POST", "/expenses/1").Code)
+}
+
+func (s *MaintenanceTestSuite) TestBanner() {
+	w := httptest.NewRecorder()
+	s.h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.Contains(w.Body.String(), `id="maintenance-banner" role="status">`)
+
+	s.h.SetReadOnly(false)
+	w = httptest.NewRecorder()
+	s.h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+	s.Contains(w.Body.String(), `id="maintenance-banner" role="status" hidden>`)
+}
+
+func (s *MaintenanceTestSuite) TestToggle() {
+	admin, err := s.db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+	member, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+
+	toggle := func(user *models.User, readOnly string) *httptest.ResponseRecorder {
+		form := url.Values{"read_only": {readOnly}}
+		req := httptest.NewRequest("POST", "/settings/maintenance", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+		w := httptest.NewRecorder()
+		s.h.AdminMiddleware(http.HandlerFunc(s.h.ToggleMaintenance)).ServeHTTP(w, req)
+		return w
+	}
+
+	s.Equal(http.StatusForbidden, toggle(member, "false").Code)
+	s.True(s.h.ReadOnly())
+
+	w := toggle(admin, "false")
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("true", w.Header().Get("HX-Refresh"), "the banner is part of the layout")
+	s.False(s.h.ReadOnly())
+
+	toggle(admin, "true")
+	s.True(s.h.ReadOnly())
+}
+
+// TestMaintenanceSuite runs the read-only mode test suite
+func TestMaintenanceSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceTestSuite))
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/storage"
+)
+
+// Middleware wraps a handler with behaviour shared by many routes.
+type Middleware func(http.Handler) http.Handler
+
+// Chain combines middlewares into one. The first middleware is the
+// outermost, so it sees requests first and responses last.
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Group registers routes on a mux with the same middlewares applied to
+// each of them.
+type Group struct {
+	mux *http.ServeMux
+	mws []Middleware
+}
+
+// NewGroup returns a group that registers routes on mux without any
+// middleware.
+func NewGroup(mux *http.ServeMux) *Group {
+	return &Group{mux: mux}
+}
+
+// Use returns a group that applies mws to its routes after the
+// middlewares of g. g itself is left unchanged.
+func (g *Group) Use(mws ...Middleware) *Group {
+	return &Group{mux: g.mux, mws: append(g.mws[:len(g.mws):len(g.mws)], mws...)}
+}
+
+// Handle registers handler for pattern behind the group's middlewares.
+func (g *Group) Handle(pattern string, handler http.Handler) {
+	g.mux.Handle(pattern, Chain(g.mws...)(handler))
+}
+
+// HandleFunc registers handler for pattern behind the group's middlewares.
+func (g *Group) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	g.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// QueryTimeout gives each request the configured time for its database
+// queries, see WithQueryTimeout. Long-lived requests like DevReload must not
+// go through it.
+func (h *Handlers) QueryTimeout(next http.Handler) http.Handler {
+	if h.queryTimeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), h.queryTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// store returns the store to use for r. The database runs the request's
+// queries under its context, so they stop when the client goes away or the
+// query timeout passes.
+func (h *Handlers) store(r *http.Request) Store {
+	if db, ok := h.db.(*storage.DB); ok {
+		return db.WithContext(r.Context())
+	}
+	return h.db
+}
+
+// statusRecorder remembers the status code of a response for logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(p)
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// LogRequests logs the method, path, status, and duration of every request.
+func (h *Handlers) LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := h.now()
+		var queries *storage.QueryStats
+		if h.logQueries {
+			var ctx context.Context
+			ctx, queries = storage.WithQueryStats(r.Context())
+			r = r.WithContext(ctx)
+		}
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		if queries != nil {
+			h.logger.Printf("%s %s %d %s, %s", r.Method, r.URL.Path, sr.status, h.now().Sub(start).Round(time.Millisecond), describeQueries(queries))
+			return
+		}
+		h.logger.Printf("%s %s %d %s", r.Method, r.URL.Path, sr.status, h.now().Sub(start).Round(time.Millisecond))
+	})
+}
+
+// describeQueries sums up a request's database queries for its log line,
+// e.g. "db 4.2ms in 3 queries: FilterExpenses 3.1ms, DataVersion 2× 1.1ms".
+func describeQueries(stats *storage.QueryStats) string {
+	count, total := stats.Total()
+	if count == 0 {
+		return "no db queries"
+	}
+	var parts []string
+	for _, t := range stats.Timings() {
+		if t.Count == 1 {
+			parts = append(parts, fmt.Sprintf("%s %s", t.Name, t.Total.Round(100*time.Microsecond)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %d× %s", t.Name, t.Count, t.Total.Round(100*time.Microsecond)))
+		}
+	}
+	plural := "queries"
+	if count == 1 {
+		plural = "query"
+	}
+	return fmt.Sprintf("db %s in %d %s: %s", total.Round(100*time.Microsecond), count, plural, strings.Join(parts, ", "))
+}
+
+// Recover turns a panicking handler into a 500 response and logs the panic
+// with its stack, instead of dropping the connection.
+func (h *Handlers) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// The server aborts the response quietly for this one
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+			h.logger.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SecurityHeaders stops browsers from sniffing content types and other
+// sites from framing the pages, and keeps the full URL out of the referrer
+// sent to other sites.
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Content-Security-Policy", "frame-ancestors 'none'")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRF rejects state-changing requests that browsers report as coming from
+// another site, using the Sec-Fetch-Site and Origin headers. The fixed
+// passkey origin is trusted too, for deployments reached under another name.
+func (h *Handlers) CSRF(next http.Handler) http.Handler {
+	protection := http.NewCrossOriginProtection()
+	if h.passkeyOrigin != "" {
+		if err := protection.AddTrustedOrigin(h.passkeyOrigin); err != nil {
+			h.logger.Printf("Warning: passkey origin not trusted for CSRF: %v", err)
+		}
+	}
+	protection.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.logger.Printf("Rejected cross-origin %s %s from %q", r.Method, r.URL.Path, r.Header.Get("Origin"))
+		http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+	}))
+	return protection.Handler(next)
+}
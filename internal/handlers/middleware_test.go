@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+	"expense-tracker/internal/storage/memstore"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// MiddlewareTestSuite provides a test suite for the shared middlewares and
+// route groups
+type MiddlewareTestSuite struct {
+	suite.Suite
+	handlers *Handlers
+	logs     *bytes.Buffer
+}
+
+// SetupTest runs before each test
+func (s *MiddlewareTestSuite) SetupTest() {
+	s.logs = &bytes.Buffer{}
+	s.handlers = NewHandlers(memstore.New(), WithLogger(log.New(s.logs, "", 0)))
+}
+
+// tag returns a middleware that appends name to the X-Order header.
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func (s *MiddlewareTestSuite) TestChain() {
+	handler := Chain(tag("a"), tag("b"), tag("c"))(http.HandlerFunc(okHandler))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", http.NoBody))
+
+	s.Equal([]string{"a", "b", "c"}, rec.Header().Values("X-Order"))
+	s.Equal("ok", rec.Body.String())
+}
+
+func (s *MiddlewareTestSuite) TestGroupUse() {
+	mux := http.NewServeMux()
+	outer := NewGroup(mux).Use(tag("outer"))
+	inner := outer.Use(tag("inner"))
+	// A second group from the same parent mustn't share the inner one's
+	// middlewares
+	other := outer.Use(tag("other"))
+	outer.HandleFunc("GET /outer", okHandler)
+	inner.HandleFunc("GET /inner", okHandler)
+	other.HandleFunc("GET /other", okHandler)
+
+	for path, want := range map[string][]string{
+		"/outer": {"outer"},
+		"/inner": {"outer", "inner"},
+		"/other": {"outer", "other"},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, http.NoBody))
+		s.Equal(want, rec.Header().Values("X-Order"), path)
+	}
+}
+
+func (s *MiddlewareTestSuite) TestLogRequests() {
+	handler := s.handlers.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", http.NoBody))
+	s.Contains(s.logs.String(), "GET /missing 404")
+
+	s.logs.Reset()
+	s.handlers.LogRequests(http.HandlerFunc(okHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/expenses", http.NoBody))
+	s.Contains(s.logs.String(), "POST /expenses 200")
+}
+
+func (s *MiddlewareTestSuite) TestLogRequests_Queries() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err)
+	defer db.Close()
+	h := NewHandlers(db, WithLogger(log.New(s.logs, "", 0)), WithQueryLog())
+
+	h.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := h.store(r).FilterExpenses(storage.ExpenseFilter{})
+		s.NoError(err)
+		s.NoError(h.store(r).CreateExpense(1, "Coffee", "Eating Out", time.Now(), 1))
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/expenses", http.NoBody))
+	s.Regexp(`POST /expenses 200 \S+, db \S+ in \d+ queries: `, s.logs.String())
+	s.Contains(s.logs.String(), "FilterExpenses")
+	s.Contains(s.logs.String(), "CreateExpense", "in a transaction too")
+
+	s.logs.Reset()
+	h.LogRequests(http.HandlerFunc(okHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	s.Contains(s.logs.String(), "GET / 200 0s, no db queries")
+}
+
+func (s *MiddlewareTestSuite) TestLogRequests_Flush() {
+	// Streaming handlers still reach the connection through the recorder
+	handler := s.handlers.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.NoError(http.NewResponseController(w).Flush())
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/dev/reload", http.NoBody))
+	s.True(rec.Flushed)
+}
+
+func (s *MiddlewareTestSuite) TestRecover() {
+	handler := s.handlers.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	s.NotPanics(func() {
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/expenses", http.NoBody))
+	})
+	s.Equal(http.StatusInternalServerError, rec.Code)
+	s.Contains(s.logs.String(), "panic serving GET /expenses: boom")
+
+	// Aborted responses are left to the server
+	handler = s.handlers.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	s.PanicsWithValue(http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	})
+}
+
+func (s *MiddlewareTestSuite) TestSecurityHeaders() {
+	rec := httptest.NewRecorder()
+	SecurityHeaders(http.HandlerFunc(okHandler)).ServeHTTP(rec, httptest.NewRequest("GET", "/", http.NoBody))
+
+	s.Equal("nosniff", rec.Header().Get("X-Content-Type-Options"))
+	s.Equal("DENY", rec.Header().Get("X-Frame-Options"))
+	s.Equal("frame-ancestors 'none'", rec.Header().Get("Content-Security-Policy"))
+	s.Equal("strict-origin-when-cross-origin", rec.Header().Get("Referrer-Policy"))
+}
+
+func (s *MiddlewareTestSuite) TestCSRF() {
+	handler := s.handlers.CSRF(http.HandlerFunc(okHandler))
+	serve := func(method, origin, fetchSite string) int {
+		req := httptest.NewRequest(method, "http://example.com/expenses", strings.NewReader("amount=1"))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if fetchSite != "" {
+			req.Header.Set("Sec-Fetch-Site", fetchSite)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	s.Equal(http.StatusOK, serve("POST", "", ""), "non-browser clients send neither header")
+	s.Equal(http.StatusOK, serve("POST", "http://example.com", "same-origin"))
+	s.Equal(http.StatusOK, serve("GET", "https://evil.example", "cross-site"), "safe methods are allowed")
+	s.Equal(http.StatusForbidden, serve("POST", "https://evil.example", "cross-site"))
+	s.Equal(http.StatusForbidden, serve("DELETE", "https://evil.example", ""))
+	s.Contains(s.logs.String(), "Rejected cross-origin POST /expenses")
+}
+
+func (s *MiddlewareTestSuite) TestCSRF_PasskeyOrigin() {
+	h := NewHandlers(memstore.New(), WithPasskeyOrigin("https://expenses.example"), WithLogger(log.New(s.logs, "", 0)))
+	req := httptest.NewRequest("POST", "http://internal:8080/expenses", http.NoBody)
+	req.Header.Set("Origin", "https://expenses.example")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	rec := httptest.NewRecorder()
+	h.CSRF(http.HandlerFunc(okHandler)).ServeHTTP(rec, req)
+
+	s.Equal(http.StatusOK, rec.Code)
+}
+
+func (s *MiddlewareTestSuite) TestQueryTimeout() {
+	var deadline time.Time
+	var hasDeadline bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	})
+
+	s.handlers.QueryTimeout(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	s.False(hasDeadline, "no limit by default")
+
+	h := NewHandlers(memstore.New(), WithQueryTimeout(2*time.Second))
+	h.QueryTimeout(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	s.True(hasDeadline)
+	s.WithinDuration(time.Now().Add(2*time.Second), deadline, time.Second)
+}
+
+func (s *MiddlewareTestSuite) TestStore_RequestContext() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err)
+	defer db.Close()
+	h := NewHandlers(db, WithLogger(log.New(s.logs, "", 0)))
+
+	// Queries of a request whose time is up fail instead of waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/", http.NoBody).WithContext(ctx)
+	_, err = h.store(req).ListCategories()
+	s.ErrorIs(err, context.Canceled)
+
+	_, err = h.store(httptest.NewRequest("GET", "/", http.NoBody)).ListCategories()
+	s.NoError(err)
+}
+
+// TestMiddlewareTestSuite runs the middleware test suite
+func TestMiddlewareTestSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareTestSuite))
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/notify"
+	"expense-tracker/internal/storage"
+)
+
+// notificationHistoryLimit is how many recent deliveries the notifications
+// page shows.
+const notificationHistoryLimit = 20
+
+// NotificationEvent is an event users can be notified of.
+type NotificationEvent struct {
+	Name  string // From models.NotificationEvents
+	Label string
+}
+
+// notificationEvents are the events in the order the page lists them.
+var notificationEvents = []NotificationEvent{
+	{models.EventBudgetAlert, "Budget alerts"},
+	{models.EventBillReminder, "Bill reminders"},
+	{models.EventWeeklyDigest, "Weekly digest"},
+}
+
+// eventLabel returns how the page names an event; test notifications have
+// none.
+func eventLabel(event string) string {
+	for _, e := range notificationEvents {
+		if e.Name == event {
+			return e.Label
+		}
+	}
+	return "Test"
+}
+
+// channelLabels name the kinds of channels.
+var channelLabels = map[string]string{
+	models.ChannelNtfy:    "ntfy",
+	models.ChannelWebPush: "This or another browser",
+	models.ChannelEmail:   "Email",
+}
+
+// WithNotifier lets users set up notifications, sent through n's channels.
+// Without it the notifications page only lists what was set up before.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(h *Handlers) { h.notifier = n }
+}
+
+// supports reports whether channels of a kind can be added.
+func (h *Handlers) supports(kind string) bool {
+	return h.notifier != nil && h.notifier.Supports(kind)
+}
+
+// NotificationSettings renders the notifications page.
+func (h *Handlers) NotificationSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{})
+}
+
+// renderNotifications renders the notifications page for a user, listing
+// their channels and deliveries into viewModel.
+func (h *Handlers) renderNotifications(w http.ResponseWriter, r *http.Request, userID int64, viewModel NotificationsViewModel) {
+	db := h.store(r)
+	// Reload the user, the one in the context predates any change just made
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		h.logger.Printf("NotificationSettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	channels, err := db.ListNotificationChannels(userID)
+	if err != nil {
+		h.logger.Printf("NotificationSettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	deliveries, err := db.ListNotificationDeliveries(userID, notificationHistoryLimit)
+	if err != nil {
+		h.logger.Printf("NotificationSettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	prefs, err := db.GetPreferences(userID)
+	if err != nil {
+		h.logger.Printf("NotificationSettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	viewModel.Events = notificationEvents
+	viewModel.WeeklyDigest = prefs.WeeklyDigest
+	viewModel.Ntfy = h.supports(models.ChannelNtfy)
+	viewModel.Email = h.supports(models.ChannelEmail)
+	if user.EmailVerified {
+		viewModel.EmailAddress = user.Email
+	}
+	if h.supports(models.ChannelWebPush) {
+		viewModel.PushKey = h.notifier.PushKey()
+	}
+	for _, c := range channels {
+		item := NotificationChannelItem{NotificationChannel: c, Label: c.Address, Added: c.CreatedAt.Local().Format("02 Jan 2006")}
+		switch c.Kind {
+		case models.ChannelWebPush:
+			item.Label = channelLabels[c.Kind]
+		case models.ChannelEmail:
+			item.Label = "Email to " + viewModel.EmailAddress
+			if viewModel.EmailAddress == "" {
+				item.Label = "Email, once your address is verified"
+			}
+		}
+		viewModel.Channels = append(viewModel.Channels, item)
+	}
+	for _, d := range deliveries {
+		viewModel.Deliveries = append(viewModel.Deliveries, NotificationDeliveryItem{
+			Time:    d.CreatedAt.Local().Format("02 Jan 2006, 15:04"),
+			Channel: channelLabels[d.Kind],
+			Event:   eventLabel(d.Event),
+			Title:   d.Title,
+			Failed:  d.Error != "",
+		})
+	}
+	h.render(w, r, "notifications.html", viewModel)
+}
+
+// AddNotificationChannel adds an ntfy topic or the user's email address as
+// a channel, notifying of every event until the user picks.
+func (h *Handlers) AddNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	channel := &models.NotificationChannel{UserID: user.ID, Kind: r.FormValue("kind"), Events: models.NotificationEvents}
+	switch {
+	case channel.Kind != models.ChannelNtfy && channel.Kind != models.ChannelEmail:
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	case !h.supports(channel.Kind):
+		h.renderNotifications(w, r, user.ID, NotificationsViewModel{Error: "This server can't send notifications that way"})
+		return
+	case channel.Kind == models.ChannelNtfy:
+		topic := r.FormValue("topic")
+		address, err := h.notifier.TopicURL(topic)
+		if err != nil {
+			h.renderNotifications(w, r, user.ID, NotificationsViewModel{Topic: topic, Error: "Enter a topic name of letters, digits, - and _, or the topic's URL"})
+			return
+		}
+		channel.Address = address
+	}
+
+	err := h.store(r).AddNotificationChannel(channel)
+	if errors.Is(err, storage.ErrChannelExists) {
+		h.renderNotifications(w, r, user.ID, NotificationsViewModel{Error: "You have that channel already"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("AddNotificationChannel error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{Notice: "Added " + channelLabels[channel.Kind]})
+}
+
+// pushSubscription is a browser's PushSubscription as JSON.
+type pushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// SubscribePush adds the browser that subscribed to Web Push as a channel.
+// Subscribing the same browser again changes nothing.
+func (h *Handlers) SubscribePush(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.supports(models.ChannelWebPush) {
+		http.Error(w, "This server can't send push notifications", http.StatusNotFound)
+		return
+	}
+	var sub pushSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		http.Error(w, "Invalid subscription", http.StatusBadRequest)
+		return
+	}
+	if err := h.notifier.CheckPushEndpoint(r.Context(), sub.Endpoint); err != nil {
+		http.Error(w, "Invalid subscription", http.StatusBadRequest)
+		return
+	}
+
+	err = h.store(r).AddNotificationChannel(&models.NotificationChannel{
+		UserID:  user.ID,
+		Kind:    models.ChannelWebPush,
+		Address: sub.Endpoint,
+		P256dh:  sub.Keys.P256dh,
+		Auth:    sub.Keys.Auth,
+		Events:  models.NotificationEvents,
+	})
+	if err != nil && !errors.Is(err, storage.ErrChannelExists) {
+		h.logger.Printf("SubscribePush error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetNotificationEvents sets the events one of the user's channels notifies
+// of.
+func (h *Handlers) SetNotificationEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	// In the order of models.NotificationEvents, ignoring unknown ones
+	var events []string
+	for _, event := range models.NotificationEvents {
+		if slices.Contains(r.Form["events"], event) {
+			events = append(events, event)
+		}
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	err := h.store(r).SetNotificationEvents(user.ID, id, events)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Printf("SetNotificationEvents error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{Notice: "Saved"})
+}
+
+// SetWeeklyDigest opts the user into the weekly digest, or out of it. Which
+// of their channels send it is up to the channels' events.
+func (h *Handlers) SetWeeklyDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	prefs := h.preferences(r)
+	prefs.WeeklyDigest = r.FormValue("weekly_digest") != ""
+	if err := h.store(r).SavePreferences(user.ID, prefs); err != nil {
+		h.logger.Printf("SetWeeklyDigest error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{Notice: "Saved"})
+}
+
+// PreviewDigest shows the weekly digest the user would be sent now, of last
+// week, without sending it.
+func (h *Handlers) PreviewDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.notifier == nil {
+		h.renderNotifications(w, r, user.ID, NotificationsViewModel{Error: "This server can't send notifications"})
+		return
+	}
+	digest, err := h.notifier.Digest(user.ID)
+	if err != nil {
+		h.logger.Printf("PreviewDigest error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{Digest: &digest})
+}
+
+// TestNotification sends a notification through one of the user's channels,
+// whatever events it notifies of, to check it reaches them.
+func (h *Handlers) TestNotification(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	channels, err := h.store(r).ListNotificationChannels(user.ID)
+	if err != nil {
+		h.logger.Printf("TestNotification error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	i := slices.IndexFunc(channels, func(c models.NotificationChannel) bool { return c.ID == id })
+	if i < 0 {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if h.notifier == nil {
+		h.renderNotifications(w, r, user.ID, NotificationsViewModel{Error: "This server can't send notifications"})
+		return
+	}
+
+	failure, err := h.notifier.SendTo(r.Context(), channels[i], notify.Notification{
+		Title: "Test notification",
+		Body:  "Notifications about your expenses will reach you here.",
+	})
+	if err != nil {
+		h.logger.Printf("TestNotification error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if failure != "" {
+		// The reason may tell of the server's network, so it's only logged
+		h.logger.Printf("Test notification to channel %d failed: %s", id, failure)
+		h.renderNotifications(w, r, user.ID, NotificationsViewModel{Error: "The test notification failed, check the channel's settings"})
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{Notice: "Sent a test notification"})
+}
+
+// DeleteNotificationChannel removes one of the user's channels.
+func (h *Handlers) DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	err := h.store(r).DeleteNotificationChannel(user.ID, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Printf("DeleteNotificationChannel error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.renderNotifications(w, r, user.ID, NotificationsViewModel{})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/notify"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// NotificationsTestSuite provides a test suite for the notifications page
+type NotificationsTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	ntfy     *httptest.Server
+	posted   []string // Paths of the topics posted to
+	handlers *Handlers
+}
+
+// SetupTest runs before each test
+func (s *NotificationsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	_, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+
+	s.posted = nil
+	s.ntfy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.posted = append(s.posted, r.URL.Path)
+	}))
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	s.Require().NoError(err)
+	push, err := notify.NewWebPush(key, "")
+	s.Require().NoError(err)
+	push.LookupIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if host == "internal.example.com" {
+			return []netip.Addr{netip.MustParseAddr("192.168.1.10")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("203.0.113.7")}, nil
+	}
+	notifier := notify.New(db,
+		notify.WithSender(models.ChannelNtfy, &notify.Ntfy{Server: s.ntfy.URL}),
+		notify.WithSender(models.ChannelWebPush, push),
+		notify.WithLogger(log.New(io.Discard, "", 0)),
+	)
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithNotifier(notifier), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *NotificationsTestSuite) TearDownTest() {
+	s.ntfy.Close()
+	s.db.Close()
+}
+
+// do sends a request to handler as user 1, with form as its body unless
+// it's nil.
+func (s *NotificationsTestSuite) do(method, target string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	body := io.Reader(http.NoBody)
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if rest, ok := strings.CutPrefix(target, "/settings/notifications/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		req.SetPathValue("id", id)
+	}
+	req = asUser(req, 1)
+	req = req.WithContext(s.handlers.withPreferences(req.Context(), s.db, 1))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func (s *NotificationsTestSuite) TestChannels() {
+	w := s.do("GET", "/settings/notifications", s.handlers.NotificationSettings, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "No channels yet")
+	s.Contains(w.Body.String(), "data-push-subscribe")
+	s.NotContains(w.Body.String(), `"kind": "email"`, "no email sender")
+
+	w = s.do("POST", "/settings/notifications", s.handlers.AddNotificationChannel, url.Values{"kind": {"ntfy"}, "topic": {"alice_expenses"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Added ntfy")
+	s.Contains(w.Body.String(), s.ntfy.URL+"/alice_expenses")
+	w = s.do("POST", "/settings/notifications", s.handlers.AddNotificationChannel, url.Values{"kind": {"ntfy"}, "topic": {"alice_expenses"}})
+	s.Contains(w.Body.String(), "You have that channel already")
+	w = s.do("POST", "/settings/notifications", s.handlers.AddNotificationChannel, url.Values{"kind": {"ntfy"}, "topic": {"two words"}})
+	s.Contains(w.Body.String(), "Enter a topic name")
+	s.Contains(w.Body.String(), `value="two words"`)
+	w = s.do("POST", "/settings/notifications", s.handlers.AddNotificationChannel, url.Values{"kind": {"ntfy"}, "topic": {"http://169.254.169.254/latest"}})
+	s.Contains(w.Body.String(), "Enter a topic name", "not on the ntfy server")
+	w = s.do("POST", "/settings/notifications", s.handlers.AddNotificationChannel, url.Values{"kind": {"email"}})
+	s.Contains(w.Body.String(), "This server can&#39;t send notifications that way")
+
+	channels, err := s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Require().Len(channels, 1)
+	s.Equal(models.NotificationEvents, channels[0].Events, "every event to start with")
+
+	w = s.do("POST", "/settings/notifications/1", s.handlers.SetNotificationEvents, url.Values{"events": {models.EventWeeklyDigest, "lottery_win"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	channels, err = s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Equal([]string{models.EventWeeklyDigest}, channels[0].Events)
+	s.Contains(w.Body.String(), `value="weekly_digest" checked`)
+	s.NotContains(w.Body.String(), `value="budget_alert" checked`)
+
+	w = s.do("POST", "/settings/notifications/1/test", s.handlers.TestNotification, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Sent a test notification")
+	s.Equal([]string{"/alice_expenses"}, s.posted)
+	s.Contains(w.Body.String(), "<strong>Test notification</strong>", "in the delivery log")
+
+	s.Require().NoError(s.db.AddNotificationChannel(&models.NotificationChannel{UserID: 2, Kind: models.ChannelNtfy, Address: s.ntfy.URL + "/bob"}))
+	s.Equal(http.StatusNotFound, s.do("POST", "/settings/notifications/2/test", s.handlers.TestNotification, nil).Code, "someone else's")
+	s.Equal(http.StatusNotFound, s.do("DELETE", "/settings/notifications/2", s.handlers.DeleteNotificationChannel, nil).Code)
+
+	w = s.do("DELETE", "/settings/notifications/1", s.handlers.DeleteNotificationChannel, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "No channels yet")
+}
+
+func (s *NotificationsTestSuite) TestFailedNotification() {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	s.Require().NoError(s.db.AddNotificationChannel(&models.NotificationChannel{UserID: 1, Kind: models.ChannelNtfy, Address: closed.URL + "/alice"}))
+
+	w := s.do("POST", "/settings/notifications/1/test", s.handlers.TestNotification, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "The test notification failed, check the channel&#39;s settings")
+	s.Contains(w.Body.String(), ">Failed</span>", "in the delivery log")
+	s.NotContains(w.Body.String(), "connection refused", "the reason is only logged")
+}
+
+func (s *NotificationsTestSuite) TestSubscribePush() {
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	p256dh := base64.RawURLEncoding.EncodeToString(browser.PublicKey().Bytes())
+	subscription := `{"endpoint": "https://push.example.com/send/abc", "keys": {"p256dh": "` + p256dh + `", "auth": "c2VjcmV0LWF1dGgtMTIzNA"}}`
+
+	for range 2 {
+		req := asUser(httptest.NewRequest("POST", "/settings/notifications/push", strings.NewReader(subscription)), 1)
+		w := httptest.NewRecorder()
+		s.handlers.SubscribePush(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+	}
+	channels, err := s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Require().Len(channels, 1, "the same browser once")
+	s.Equal(models.ChannelWebPush, channels[0].Kind)
+	s.Equal(p256dh, channels[0].P256dh)
+
+	req := asUser(httptest.NewRequest("POST", "/settings/notifications/push", strings.NewReader(`{"endpoint": "http://push.example.com/send/abc"}`)), 1)
+	w := httptest.NewRecorder()
+	s.handlers.SubscribePush(w, req)
+	s.Equal(http.StatusBadRequest, w.Code)
+	internal := strings.Replace(subscription, "push.example.com", "internal.example.com", 1)
+	req = asUser(httptest.NewRequest("POST", "/settings/notifications/push", strings.NewReader(internal)), 1)
+	w = httptest.NewRecorder()
+	s.handlers.SubscribePush(w, req)
+	s.Equal(http.StatusBadRequest, w.Code, "on a private address")
+
+	w = s.do("GET", "/settings/notifications", s.handlers.NotificationSettings, nil)
+	s.Contains(w.Body.String(), "This or another browser")
+}
+
+func (s *NotificationsTestSuite) TestWeeklyDigest() {
+	w := s.do("GET", "/settings/notifications", s.handlers.NotificationSettings, nil)
+	s.Contains(w.Body.String(), `name="weekly_digest" value="on">`, "not opted in")
+
+	w = s.do("POST", "/settings/notifications/digest", s.handlers.SetWeeklyDigest, url.Values{"weekly_digest": {"on"}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `name="weekly_digest" value="on" checked>`)
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.True(prefs.WeeklyDigest)
+	s.Equal(models.DefaultPreferences().Currency, prefs.Currency, "the other preferences are kept")
+
+	w = s.do("GET", "/settings/notifications/digest", s.handlers.PreviewDigest, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "<strong>Your week: ")
+	s.Contains(w.Body.String(), "You didn&#39;t add any expenses last week.")
+	s.Empty(s.posted, "only previewed")
+
+	s.do("POST", "/settings/notifications/digest", s.handlers.SetWeeklyDigest, url.Values{})
+	prefs, err = s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.False(prefs.WeeklyDigest)
+}
+
+// TestNotificationsTestSuite runs the notifications page test suite
+func TestNotificationsTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationsTestSuite))
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// challengeTTL is how long a passkey ceremony may take before its challenge expires.
+const challengeTTL = 5 * time.Minute
+
+// challengeStore keeps outstanding WebAuthn challenges so each can be
+// answered only once.
+type challengeStore struct {
+	mu      sync.Mutex
+	entries map[string]challengeEntry
+	now     func() time.Time
+}
+
+type challengeEntry struct {
+	userID  int64 // Registering user, zero for login ceremonies
+	expires time.Time
+}
+
+func newChallengeStore(now func() time.Time) *challengeStore {
+	return &challengeStore{entries: make(map[string]challengeEntry), now: now}
+}
+
+// issue creates a challenge for a ceremony, bound to userID if non-zero.
+func (s *challengeStore) issue(userID int64) (string, error) {
+	challenge, err := auth.GenerateChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for c, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, c)
+		}
+	}
+	s.entries[challenge] = challengeEntry{userID: userID, expires: now.Add(challengeTTL)}
+	return challenge, nil
+}
+
+// consume removes a challenge and reports whether it was outstanding,
+// unexpired, and issued for userID.
+func (s *challengeStore) consume(challenge string, userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[challenge]
+	delete(s.entries, challenge)
+	return ok && e.userID == userID && s.now().Before(e.expires)
+}
+
+// relyingParty returns the WebAuthn relying party for a request: the
+// configured passkey origin, or else the origin the request was sent to.
+func (h *Handlers) relyingParty(r *http.Request) auth.RelyingParty {
+	if h.passkeyOrigin != "" {
+		if u, err := url.Parse(h.passkeyOrigin); err == nil {
+			return auth.RelyingParty{ID: u.Hostname(), Origin: h.passkeyOrigin}
+		}
+	}
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return auth.RelyingParty{ID: host, Origin: h.requestOrigin(r)}
+}
+
+// passkeyUserHandle is the opaque WebAuthn user handle for a user ID.
+func passkeyUserHandle(userID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(userID, 10)))
+}
+
+// clientDataChallenge extracts the challenge a WebAuthn response answers, so
+// it can be looked up before the response is verified.
+func clientDataChallenge(clientDataJSON []byte) string {
+	var cd struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return ""
+	}
+	return cd.Challenge
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+// PasskeyRegistrationOptions starts registering a passkey for the logged-in
+// user, returning the options for navigator.credentials.create().
+func (h *Handlers) PasskeyRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	existing, err := h.store(r).ListPasskeys(user.ID)
+	if err != nil {
+		h.logger.Printf("PasskeyRegistrationOptions error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	challenge, err := h.challenges.issue(user.ID)
+	if err != nil {
+		h.logger.Printf("PasskeyRegistrationOptions error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type credential struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	exclude := make([]credential, 0, len(existing))
+	for _, p := range existing {
+		exclude = append(exclude, credential{Type: "public-key", ID: p.ID})
+	}
+	rp := h.relyingParty(r)
+	writeJSON(w, map[string]any{
+		"challenge": challenge,
+		"rp":        map[string]string{"id": rp.ID, "name": "Expense Tracker"},
+		"user": map[string]string{
+			"id":          passkeyUserHandle(user.ID),
+			"name":        user.Username,
+			"displayName": user.Username,
+		},
+		// ES256, EdDSA, RS256
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": -7},
+			{"type": "public-key", "alg": -8},
+			{"type": "public-key", "alg": -257},
+		},
+		"excludeCredentials": exclude,
+		"authenticatorSelection": map[string]string{
+			"residentKey":      "required",
+			"userVerification": "required",
+		},
+		"attestation": "none",
+		"timeout":     challengeTTL.Milliseconds(),
+	})
+}
+
+// passkeyRegistration is the browser's response to a registration ceremony,
+// with binary fields base64url encoded.
+type passkeyRegistration struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	PublicKey         string `json:"publicKey"`
+	Name              string `json:"name"`
+}
+
+// RegisterPasskey verifies and stores a passkey created by the browser.
+func (h *Handlers) RegisterPasskey(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req passkeyRegistration
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	credentialID, err1 := base64.RawURLEncoding.DecodeString(req.ID)
+	clientDataJSON, err2 := base64.RawURLEncoding.DecodeString(req.ClientDataJSON)
+	authData, err3 := base64.RawURLEncoding.DecodeString(req.AuthenticatorData)
+	publicKey, err4 := base64.RawURLEncoding.DecodeString(req.PublicKey)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || len(credentialID) == 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	challenge := clientDataChallenge(clientDataJSON)
+	if !h.challenges.consume(challenge, user.ID) {
+		http.Error(w, "Passkey request expired, please try again", http.StatusBadRequest)
+		return
+	}
+	if err := h.relyingParty(r).VerifyRegistration(challenge, credentialID, clientDataJSON, authData, publicKey); err != nil {
+		h.logger.Printf("Passkey registration rejected: %v", err)
+		http.Error(w, "Passkey could not be verified", http.StatusBadRequest)
+		return
+	}
+
+	name := truncate(strings.TrimSpace(req.Name), 64)
+	if name == "" {
+		name = "Passkey"
+	}
+	if err := h.store(r).CreatePasskey(&models.Passkey{
+		ID:        req.ID,
+		UserID:    user.ID,
+		Name:      name,
+		PublicKey: publicKey,
+	}); err != nil {
+		h.logger.Printf("RegisterPasskey error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeletePasskey removes one of the logged-in user's passkeys.
+func (h *Handlers) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store(r).DeletePasskey(user.ID, r.PathValue("id")); err != nil {
+		h.logger.Printf("DeletePasskey error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Location", `{"path":"/settings/security", "target":"#content"}`)
+}
+
+// PasskeyLoginOptions starts a passkey login, returning the options for
+// navigator.credentials.get(). Any of the user's discoverable passkeys may
+// answer.
+func (h *Handlers) PasskeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.challenges.issue(0)
+	if err != nil {
+		h.logger.Printf("PasskeyLoginOptions error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"challenge":        challenge,
+		"rpId":             h.relyingParty(r).ID,
+		"userVerification": "required",
+		"timeout":          challengeTTL.Milliseconds(),
+	})
+}
+
+// passkeyAssertion is the browser's response to a login ceremony, with
+// binary fields base64url encoded.
+type passkeyAssertion struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+	Remember          bool   `json:"remember"` // "Remember me" checkbox on the login form
+}
+
+// PasskeyLogin verifies a passkey assertion and logs its owner in. Passkeys
+// can't be guessed, so unlike password logins they are accepted while the
+// account is locked out.
+func (h *Handlers) PasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	var req passkeyAssertion
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	clientDataJSON, err1 := base64.RawURLEncoding.DecodeString(req.ClientDataJSON)
+	authData, err2 := base64.RawURLEncoding.DecodeString(req.AuthenticatorData)
+	signature, err3 := base64.RawURLEncoding.DecodeString(req.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	challenge := clientDataChallenge(clientDataJSON)
+	if !h.challenges.consume(challenge, 0) {
+		http.Error(w, "Passkey request expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	db := h.store(r)
+	passkey, err := db.GetPasskey(req.ID)
+	if err != nil {
+		http.Error(w, "Unknown passkey", http.StatusUnauthorized)
+		return
+	}
+	user, err := db.GetUserByID(passkey.UserID)
+	if err != nil {
+		http.Error(w, "Unknown passkey", http.StatusUnauthorized)
+		return
+	}
+	if req.UserHandle != "" && req.UserHandle != passkeyUserHandle(user.ID) {
+		h.recordLogin(r, &user.ID, user.Username, false)
+		http.Error(w, "Passkey could not be verified", http.StatusUnauthorized)
+		return
+	}
+	count, err := h.relyingParty(r).VerifyAssertion(challenge, passkey.PublicKey, clientDataJSON, authData, signature, passkey.SignCount)
+	if err != nil {
+		h.logger.Printf("Passkey login rejected: %v", err)
+		h.recordLogin(r, &user.ID, user.Username, false)
+		http.Error(w, "Passkey could not be verified", http.StatusUnauthorized)
+		return
+	}
+
+	if err := db.UsePasskey(passkey.ID, count); err != nil {
+		h.logger.Printf("Failed to update passkey: %v", err)
+	}
+	if user.Disabled {
+		h.recordLogin(r, &user.ID, user.Username, false)
+		http.Error(w, disabledMessage, http.StatusForbidden)
+		return
+	}
+	if err := h.startSession(w, r, user.ID, req.Remember); err != nil {
+		h.logger.Printf("Failed to start session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.recordLogin(r, &user.ID, user.Username, true)
+	writeJSON(w, map[string]string{"redirect": "/expenses"})
+}
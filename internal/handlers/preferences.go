@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"expense-tracker/internal/models"
+)
+
+// Currency is a currency users can show their expenses in.
+type Currency struct {
+	Code     string // ISO 4217
+	Name     string
+	Symbol   string
+	Decimals int
+}
+
+// currencies are the currencies offered on the settings page.
+var currencies = []Currency{
+	{"EUR", "Euro", "€", 2},
+	{"USD", "US dollar", "$", 2},
+	{"GBP", "British pound", "£", 2},
+	{"CHF", "Swiss franc", "CHF", 2},
+	{"SEK", "Swedish krona", "kr", 2},
+	{"PLN", "Polish złoty", "zł", 2},
+	{"CAD", "Canadian dollar", "CA$", 2},
+	{"AUD", "Australian dollar", "A$", 2},
+	{"JPY", "Japanese yen", "¥", 0},
+}
+
+// Locale is a number format users can pick.
+type Locale struct {
+	Tag         string // Language tag, also set on the page
+	Name        string
+	decimal     string
+	group       string // Between thousands
+	symbolAfter bool   // 12,50 € rather than €12.50
+	symbolSpace bool   // A space between symbol and number even before it
+}
+
+// locales are the number formats offered on the settings page.
+var locales = []Locale{
+	{Tag: "en-US", Name: "English (US)", decimal: ".", group: ","},
+	{Tag: "en-GB", Name: "English (UK)", decimal: ".", group: ","},
+	{Tag: "de-DE", Name: "Deutsch", decimal: ",", group: ".", symbolAfter: true},
+	{Tag: "fr-FR", Name: "Français", decimal: ",", group: "\u202f", symbolAfter: true},
+	{Tag: "nl-NL", Name: "Nederlands", decimal: ",", group: ".", symbolSpace: true},
+	{Tag: "es-ES", Name: "Español", decimal: ",", group: ".", symbolAfter: true},
+}
+
+// themes are the themes offered on the settings page.
+var themes = []string{models.ThemeLight, models.ThemeDark, models.ThemeSystem}
+
+// maxCycleStart is the latest day budget periods can start on, so that
+// every month has it.
+const maxCycleStart = 28
+
+func findCurrency(code string) (Currency, bool) {
+	for _, c := range currencies {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return Currency{}, false
+}
+
+func findLocale(tag string) (Locale, bool) {
+	for _, l := range locales {
+		if l.Tag == tag {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+// moneyFormat formats amounts the way a user's preferences say.
+type moneyFormat struct {
+	currency Currency
+	locale   Locale
+}
+
+func newMoneyFormat(p models.Preferences) moneyFormat {
+	defaults := models.DefaultPreferences()
+	c, ok := findCurrency(p.Currency)
+	if !ok {
+		c, _ = findCurrency(defaults.Currency)
+	}
+	l, ok := findLocale(p.Locale)
+	if !ok {
+		l, _ = findLocale(defaults.Locale)
+	}
+	return moneyFormat{currency: c, locale: l}
+}
+
+// number formats an amount without the currency symbol, with the
+// currency's decimals unless decimals are given, e.g. 0 for whole amounts.
+func (f moneyFormat) number(amount float64, decimals ...int) string {
+	places := f.currency.Decimals
+	if len(decimals) > 0 {
+		places = min(places, decimals[0])
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	whole, fraction, _ := strings.Cut(strconv.FormatFloat(amount, 'f', places, 64), ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.locale.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(f.locale.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// separator returns what goes between the symbol and the number: a
+// non-breaking space after the number, before it for symbols ending in a
+// letter, like CHF 12.50, and where the locale wants one.
+func (f moneyFormat) separator() string {
+	symbol := []rune(f.currency.Symbol)
+	if f.locale.symbolAfter || f.locale.symbolSpace || unicode.IsLetter(symbol[len(symbol)-1]) {
+		return "\u00a0"
+	}
+	return ""
+}
+
+// moneyIn formats an amount in another currency, given by its code, the
+// way money does. Unknown codes format in the user's.
+func (f moneyFormat) moneyIn(code string, amount float64) string {
+	if c, ok := findCurrency(code); ok {
+		f.currency = c
+	}
+	return f.money(amount)
+}
+
+// money formats an amount with the currency symbol, e.g. €1,234.50.
+func (f moneyFormat) money(amount float64, decimals ...int) string {
+	return f.withSymbol(amount, f.currency.Symbol, decimals)
+}
+
+// moneyHTML is money with the symbol in a span of class currency, which
+// pages show smaller.
+func (f moneyFormat) moneyHTML(amount float64, decimals ...int) template.HTML {
+	symbol := `<span class="currency">` + template.HTMLEscapeString(f.currency.Symbol) + `</span>`
+	return template.HTML(f.withSymbol(amount, symbol, decimals))
+}
+
+// withSymbol formats an amount with symbol on the side the locale puts it,
+// and the sign in front of both.
+func (f moneyFormat) withSymbol(amount float64, symbol string, decimals []int) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if f.locale.symbolAfter {
+		return sign + f.number(amount, decimals...) + f.separator() + symbol
+	}
+	return sign + symbol + f.separator() + f.number(amount, decimals...)
+}
+
+const preferencesContextKey contextKey = "preferences"
+
+// preferencesLoader loads the signed-in user's preferences the first time a
+// request needs them, as many don't.
+type preferencesLoader struct {
+	once   sync.Once
+	userID int64
+	load   func() (models.Preferences, error)
+	ledger func(id int64) (*models.Ledger, error)
+	prefs  models.Preferences
+	loc    *time.Location
+	// The currency of the ledger picked, empty if it's kept in the user's
+	currency string
+}
+
+// withPreferences returns a context that loads a user's preferences from db
+// when asked for them.
+func (h *Handlers) withPreferences(ctx context.Context, db Store, userID int64) context.Context {
+	return context.WithValue(ctx, preferencesContextKey, &preferencesLoader{
+		userID: userID,
+		load:   func() (models.Preferences, error) { return db.GetPreferences(userID) },
+		ledger: db.GetLedger,
+	})
+}
+
+// loadedPreferences returns the preferences of the request's user, or the
+// defaults when nobody is signed in or they can't be loaded, along with
+// their time zone and the currency of the ledger they picked, if it has one.
+func (h *Handlers) loadedPreferences(r *http.Request) (models.Preferences, *time.Location, string) {
+	loader, ok := r.Context().Value(preferencesContextKey).(*preferencesLoader)
+	if !ok {
+		return models.DefaultPreferences(), time.Local, ""
+	}
+	loader.once.Do(func() {
+		prefs, err := loader.load()
+		if err != nil {
+			// Still show the page, if in the default format
+			h.logger.Printf("GetPreferences error: %v", err)
+			prefs = models.DefaultPreferences()
+		}
+		loader.prefs, loader.loc = prefs, time.Local
+		if prefs.Timezone != "" {
+			if loc, err := time.LoadLocation(prefs.Timezone); err == nil {
+				loader.loc = loc
+			}
+		}
+		if prefs.Ledger != 0 {
+			ledger, err := loader.ledger(prefs.Ledger)
+			switch {
+			case err != nil:
+				h.logger.Printf("GetLedger error: %v", err)
+			case !ledger.VisibleTo(loader.userID):
+				// Never show another user's ledger
+				loader.prefs.Ledger = models.PersonalLedgerID
+			default:
+				loader.currency = ledger.Currency
+			}
+		}
+	})
+	return loader.prefs, loader.loc, loader.currency
+}
+
+// preferences returns the preferences of the request's user.
+func (h *Handlers) preferences(r *http.Request) models.Preferences {
+	prefs, _, _ := h.loadedPreferences(r)
+	return prefs
+}
+
+// pagePreferences returns the preferences pages render with: the user's,
+// but in the currency of the ledger shown if it's kept in one.
+func (h *Handlers) pagePreferences(r *http.Request) models.Preferences {
+	prefs, _, currency := h.loadedPreferences(r)
+	if currency != "" {
+		prefs.Currency = currency
+	}
+	return prefs
+}
+
+// userNow returns the current time in the time zone of the request's user.
+func (h *Handlers) userNow(r *http.Request) time.Time {
+	_, loc, _ := h.loadedPreferences(r)
+	return h.now().In(loc)
+}
+
+// ledger returns the ID of the ledger the request's user picked, whose
+// expenses and statistics the pages show.
+func (h *Handlers) ledger(r *http.Request) int64 {
+	if id := h.preferences(r).Ledger; id != 0 {
+		return id
+	}
+	return models.PersonalLedgerID
+}
+
+// cycleStart returns the start of the budget period that now is in, for
+// periods starting on the given day of the month.
+func cycleStart(now time.Time, day int) time.Time {
+	day = max(1, min(day, maxCycleStart))
+	start := time.Date(now.Year(), now.Month(), day, 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// templateVariant identifies the preferences that change how templates
+// render, so views are parsed once per combination in use.
+func templateVariant(p models.Preferences) string {
+	return strings.Join([]string{p.Currency, p.Locale, p.Theme, p.DefaultCategory}, "|")
+}
+
+// preferenceFuncs returns the template functions that render the way a
+// user's preferences say.
+func preferenceFuncs(p models.Preferences) template.FuncMap {
+	format := newMoneyFormat(p)
+	return template.FuncMap{
+		"money":           format.money,
+		"moneyIn":         format.moneyIn,
+		"moneyHTML":       format.moneyHTML,
+		"currencySymbol":  func() string { return format.currency.Symbol },
+		"lang":            func() string { return format.locale.Tag },
+		"theme":           func() string { return p.Theme },
+		"defaultCategory": func() string { return p.DefaultCategory },
+	}
+}
+
+// Settings renders the preferences page.
+func (h *Handlers) Settings(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.Context().Value(UserContextKey).(*models.User); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var notice string
+	if r.URL.Query().Has("saved") {
+		notice = "Settings saved"
+	}
+	h.renderSettings(w, r, h.preferences(r), notice, "")
+}
+
+// renderSettings renders the preferences page with the given preferences,
+// which may be rejected ones just submitted, and a notice or an error.
+func (h *Handlers) renderSettings(w http.ResponseWriter, r *http.Request, prefs models.Preferences, notice, errMsg string) {
+	days := make([]int, maxCycleStart)
+	for i := range days {
+		days[i] = i + 1
+	}
+	h.render(w, r, "settings.html", SettingsViewModel{
+		Preferences: prefs,
+		Currencies:  currencies,
+		Locales:     locales,
+		Themes:      themes,
+		CycleDays:   days,
+		Categories:  h.categoryDefs(h.store(r)),
+		Notice:      notice,
+		Error:       errMsg,
+	})
+}
+
+// SaveSettings validates and stores the submitted preferences.
+func (h *Handlers) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	prefs := models.Preferences{
+		Currency:        r.FormValue("currency"),
+		Locale:          r.FormValue("locale"),
+		Timezone:        strings.TrimSpace(r.FormValue("timezone")),
+		Theme:           r.FormValue("theme"),
+		DefaultCategory: r.FormValue("default_category"),
+		// Picked with the switcher in the header, not here
+		Ledger: h.ledger(r),
+		// Opted into on the notifications page
+		WeeklyDigest: h.preferences(r).WeeklyDigest,
+	}
+	cycleStart, err := strconv.Atoi(r.FormValue("cycle_start"))
+	prefs.CycleStart = cycleStart
+	// Empty for none
+	var budgetErr error
+	if budget := strings.TrimSpace(r.FormValue("budget")); budget != "" {
+		prefs.Budget, budgetErr = strconv.ParseFloat(strings.Replace(budget, ",", ".", 1), 64)
+	}
+	var errMsg string
+	switch {
+	case !validCurrency(prefs.Currency):
+		errMsg = "Pick one of the listed currencies"
+	case !validLocale(prefs.Locale):
+		errMsg = "Pick one of the listed number formats"
+	case !validTimezone(prefs.Timezone):
+		errMsg = "Unknown time zone, use a name like Europe/Berlin"
+	case !validTheme(prefs.Theme):
+		errMsg = "Pick one of the listed themes"
+	case err != nil || cycleStart < 1 || cycleStart > maxCycleStart:
+		errMsg = "Budget periods start on a day from 1 to " + strconv.Itoa(maxCycleStart)
+	case budgetErr != nil || prefs.Budget < 0 || math.IsInf(prefs.Budget, 0) || math.IsNaN(prefs.Budget):
+		errMsg = "The budget is an amount, or empty for none"
+	case !h.validCategory(h.store(r), prefs.DefaultCategory):
+		errMsg = "Pick one of the listed categories"
+	}
+	if errMsg != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderSettings(w, r, prefs, "", errMsg)
+		return
+	}
+
+	if err := h.store(r).SavePreferences(user.ID, prefs); err != nil {
+		h.logger.Printf("SaveSettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Reload the whole page, the layout is in the new format and theme too
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/settings?saved")
+		return
+	}
+	http.Redirect(w, r, "/settings?saved", http.StatusSeeOther)
+}
+
+func validCurrency(code string) bool {
+	_, ok := findCurrency(code)
+	return ok
+}
+
+func validLocale(tag string) bool {
+	_, ok := findLocale(tag)
+	return ok
+}
+
+// validTimezone accepts IANA time zone names, and empty for the server's.
+// Local is the server's too, but under a name that means nothing to users.
+func validTimezone(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil && name != "Local"
+}
+
+func validTheme(theme string) bool {
+	for _, t := range themes {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// validCategory accepts the categories there are, and empty for the first.
+func (h *Handlers) validCategory(db Store, name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, c := range h.categoryDefs(db) {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
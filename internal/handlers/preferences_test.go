@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PreferencesTestSuite provides a test suite for user preferences
+type PreferencesTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	now      time.Time
+}
+
+// SetupTest runs before each test
+func (s *PreferencesTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *PreferencesTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// signedIn returns r as sent by user 1, whose preferences load like after
+// AuthMiddleware.
+func (s *PreferencesTestSuite) signedIn(r *http.Request) *http.Request {
+	r = asUser(r, 1)
+	return r.WithContext(s.handlers.withPreferences(r.Context(), s.db, 1))
+}
+
+// save posts the settings form.
+func (s *PreferencesTestSuite) save(form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.handlers.SaveSettings(w, s.signedIn(req))
+	return w
+}
+
+// form returns a valid settings form.
+func (s *PreferencesTestSuite) form() url.Values {
+	return url.Values{
+		"currency":         {"USD"},
+		"locale":           {"de-DE"},
+		"timezone":         {"America/New_York"},
+		"theme":            {"dark"},
+		"cycle_start":      {"25"},
+		"default_category": {"Groceries"},
+		"budget":           {"1500,50"},
+	}
+}
+
+func (s *PreferencesTestSuite) TestMoney() {
+	for _, tc := range []struct {
+		currency, locale string
+		amount           float64
+		decimals         []int
+		want             string
+	}{
+		{"EUR", "en-US", 1234.5, nil, "€1,234.50"},
+		{"EUR", "en-US", 48, nil, "€48.00"},
+		{"EUR", "de-DE", 1234567.891, nil, "1.234.567,89\u00a0€"},
+		{"EUR", "nl-NL", 12.5, nil, "€\u00a012,50"},
+		{"EUR", "fr-FR", 1234.4, []int{0}, "1\u202f234\u00a0€"},
+		{"CHF", "en-US", 12.5, nil, "CHF\u00a012.50"},
+		{"JPY", "en-US", 1234.5, nil, "¥1,234"},
+		{"USD", "en-US", -3, nil, "-$3.00"},
+		{"XXX", "xx", 1, nil, "€1.00"},
+	} {
+		format := newMoneyFormat(models.Preferences{Currency: tc.currency, Locale: tc.locale})
+		s.Equal(tc.want, format.money(tc.amount, tc.decimals...), "%s %s %v", tc.currency, tc.locale, tc.amount)
+	}
+
+	format := newMoneyFormat(models.Preferences{Currency: "EUR", Locale: "de-DE"})
+	s.Equal("12,50\u00a0<span class=\"currency\">€</span>", string(format.moneyHTML(12.5)))
+}
+
+func (s *PreferencesTestSuite) TestCycleStart() {
+	s.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), cycleStart(s.now, 1))
+	s.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), cycleStart(s.now, 10), "starts today")
+	s.Equal(time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC), cycleStart(s.now, 25), "started last month")
+	s.Equal(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), cycleStart(s.now, 31), "clamped to a day every month has")
+}
+
+func (s *PreferencesTestSuite) TestSave() {
+	w := s.save(s.form())
+	s.Require().Equal(http.StatusSeeOther, w.Code, w.Body.String())
+	s.Equal("/settings?saved", w.Header().Get("Location"))
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(models.Preferences{Currency: "USD", Locale: "de-DE", Timezone: "America/New_York", Theme: models.ThemeDark, CycleStart: 25, DefaultCategory: "Groceries", Ledger: models.PersonalLedgerID, Budget: 1500.5}, prefs)
+
+	w = httptest.NewRecorder()
+	s.handlers.Settings(w, s.signedIn(httptest.NewRequest("GET", "/settings?saved", http.NoBody)))
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Settings saved")
+	s.Contains(w.Body.String(), `data-theme="dark"`)
+	s.Contains(w.Body.String(), `<option value="25" selected>`)
+}
+
+func (s *PreferencesTestSuite) TestSaveInvalid() {
+	for field, value := range map[string]string{
+		"currency":         "XXX",
+		"locale":           "tlh",
+		"timezone":         "Mars/Olympus_Mons",
+		"theme":            "neon",
+		"cycle_start":      "29",
+		"default_category": "Yachts",
+		"budget":           "-100",
+	} {
+		form := s.form()
+		form.Set(field, value)
+		w := s.save(form)
+		s.Equal(http.StatusUnprocessableEntity, w.Code, field)
+		s.Contains(w.Body.String(), `class="passkey-error"`, field)
+	}
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(models.DefaultPreferences(), prefs, "nothing saved")
+
+	form := s.form()
+	form.Set("timezone", "")
+	form.Set("default_category", "")
+	form.Set("budget", "")
+	s.Equal(http.StatusSeeOther, s.save(form).Code, "the server's time zone, the first category, and no budget")
+}
+
+func (s *PreferencesTestSuite) TestList() {
+	s.Require().NoError(s.db.CreateExpense(1234.5, "Rent", "Housing", time.Date(2026, 2, 26, 9, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(20, "Dinner", "Eating Out", time.Date(2026, 2, 20, 19, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "de-DE", Theme: models.ThemeLight, CycleStart: 25}))
+
+	w := httptest.NewRecorder()
+	s.handlers.ListExpenses(w, s.signedIn(httptest.NewRequest("GET", "/expenses", http.NoBody)))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "1.234,50\u00a0€", "in the user's format")
+	s.Contains(body, "Rent", "since the 25th of last month")
+	s.NotContains(body, "Dinner", "in the previous budget period")
+	s.Contains(body, `lang="de-DE"`)
+
+	w = httptest.NewRecorder()
+	s.handlers.ExpenseHistory(w, s.signedIn(httptest.NewRequest("GET", "/expenses/history?year=2026&month=2", http.NoBody)))
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Dinner")
+	s.NotContains(w.Body.String(), "Rent", "shown in the current period already")
+}
+
+func (s *PreferencesTestSuite) TestBudgetProgress() {
+	s.Require().NoError(s.db.CreateExpense(600, "Rent", "Housing", time.Date(2026, 2, 26, 9, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(42, "Groceries", "Groceries", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(20, "Dinner", "Eating Out", time.Date(2026, 2, 20, 19, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 25, Budget: 900}))
+
+	list := func(target string) string {
+		w := httptest.NewRecorder()
+		s.handlers.ListExpenses(w, s.signedIn(httptest.NewRequest("GET", target, http.NoBody)))
+		s.Require().Equal(http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	body := list("/expenses")
+	s.Contains(body, "€642.00 of €900.00 budget", "since the 25th of last month")
+	s.Contains(body, `style="width: 71.3%"`)
+	s.NotContains(body, "budget-progress over")
+	s.Contains(list("/expenses?category=Groceries"), "€642.00 of €900.00 budget", "in every category")
+	s.NotContains(list("/expenses?from=2026-02-01&to=2026-02-28"), "budget-bar", "not for a picked period")
+
+	s.Require().NoError(s.db.CreateExpense(300, "Flight", "Travel", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC), 1))
+	body = list("/expenses")
+	s.Contains(body, "€942.00 of €900.00 budget")
+	s.Contains(body, "budget-progress over")
+	s.Contains(body, `style="width: 100.0%"`)
+
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 25}))
+	s.NotContains(list("/expenses"), "budget-bar", "without a budget")
+}
+
+func (s *PreferencesTestSuite) TestTimezone() {
+	s.now = time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC)
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Timezone: "Asia/Tokyo", Theme: models.ThemeLight, CycleStart: 1}))
+	now := s.handlers.userNow(s.signedIn(httptest.NewRequest("GET", "/expenses", http.NoBody)))
+	s.Equal(11, now.Day(), "already tomorrow in Tokyo")
+
+	s.Equal(time.Local, s.handlers.userNow(httptest.NewRequest("GET", "/login", http.NoBody)).Location(), "the server's when signed out")
+}
+
+func (s *PreferencesTestSuite) TestCreateForm() {
+	s.handlers.templates = fstest.MapFS{
+		"base.html":   {Data: []byte(`{{block "content" .}}{{end}}`)},
+		"create.html": {Data: []byte(`{{define "content"}}{{.Expense.Category}} {{.FormattedDate}}{{end}}`)},
+	}
+	form := func() string {
+		w := httptest.NewRecorder()
+		s.handlers.CreateExpenseForm(w, s.signedIn(httptest.NewRequest("GET", "/expenses/create", http.NoBody)))
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+	s.Equal(categories[0].Name+" 2026-03-10T12:00:00", form(), "the first category without a default")
+
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Timezone: "Asia/Tokyo", Theme: models.ThemeLight, CycleStart: 1, DefaultCategory: "Groceries"}))
+	s.Equal("Groceries 2026-03-10T21:00:00", form(), "in the user's time zone")
+
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 1, DefaultCategory: "Gone"}))
+	s.True(strings.HasPrefix(form(), categories[0].Name+" "), "a default that no longer exists")
+}
+
+// TestPreferencesTestSuite runs the user preferences test suite
+func TestPreferencesTestSuite(t *testing.T) {
+	suite.Run(t, new(PreferencesTestSuite))
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Profile renders the profile page with the user's username, when they
+// joined, and how many expenses they entered.
+func (h *Handlers) Profile(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderProfile(w, r, user.ID, func(*ProfileViewModel) {})
+}
+
+// renderProfile renders the profile page for a user, letting the caller add
+// messages to the view model.
+func (h *Handlers) renderProfile(w http.ResponseWriter, r *http.Request, userID int64, decorate func(*ProfileViewModel)) {
+	db := h.store(r)
+	// Reload the user, the one in the context predates a rename just made
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		h.logger.Printf("Profile error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	count, err := db.UserExpenseCount(user.ID)
+	if err != nil {
+		h.logger.Printf("Profile error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, loc, _ := h.loadedPreferences(r)
+	viewModel := ProfileViewModel{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Role:         user.Role,
+		Joined:       user.CreatedAt.In(loc).Format("02 Jan 2006"),
+		ExpenseCount: count,
+		CanRename:    h.ldap == nil,
+		AvatarURL:    "/avatars/" + strconv.FormatInt(user.ID, 10),
+	}
+	avatar, err := db.GetAvatar(user.ID)
+	switch {
+	case err == nil:
+		viewModel.HasAvatar = true
+		viewModel.AvatarURL += "?v=" + strconv.FormatInt(avatar.UpdatedAt.Unix(), 10)
+	case !errors.Is(err, sql.ErrNoRows):
+		h.logger.Printf("Profile error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	decorate(&viewModel)
+	h.render(w, r, "profile.html", viewModel)
+}
+
+// UpdateUsername changes the user's username. They stay signed in, as
+// sessions belong to the account rather than the name.
+func (h *Handlers) UpdateUsername(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// The directory owns the names of its users, who sign in with them
+	if h.ldap != nil {
+		http.Error(w, "Usernames are managed by the directory", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if username == user.Username {
+		h.renderProfile(w, r, user.ID, func(vm *ProfileViewModel) { vm.Notice = "That's your username already" })
+		return
+	}
+	if err := auth.ValidateUsername(username); err != nil {
+		h.renderProfile(w, r, user.ID, func(vm *ProfileViewModel) { vm.Error = capitalize(err.Error()) })
+		return
+	}
+	if err := h.store(r).RenameUser(user.ID, username); err != nil {
+		if errors.Is(err, storage.ErrUsernameTaken) {
+			h.renderProfile(w, r, user.ID, func(vm *ProfileViewModel) { vm.Error = "Username is already taken" })
+			return
+		}
+		h.logger.Printf("UpdateUsername error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %d renamed from %s to %s", user.ID, user.Username, username)
+	h.renderProfile(w, r, user.ID, func(vm *ProfileViewModel) {
+		vm.Notice = "Username changed. Sign in as " + username + " from now on."
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ProfileTestSuite provides a test suite for the profile page
+type ProfileTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	user     *models.User
+}
+
+// SetupTest runs before each test
+func (s *ProfileTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	_, err = db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(db.CreateSession("token", s.user.ID, time.Hour, ""))
+}
+
+// TearDownTest runs after each test
+func (s *ProfileTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// do sends a request with alice's session cookie through AuthMiddleware.
+func (s *ProfileTestSuite) do(req *http.Request, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "token"})
+	w := httptest.NewRecorder()
+	s.handlers.AuthMiddleware(handler).ServeHTTP(w, req)
+	return w
+}
+
+// rename posts a new username.
+func (s *ProfileTestSuite) rename(username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/settings/profile/username", strings.NewReader(url.Values{"username": {username}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(req, s.handlers.UpdateUsername)
+}
+
+func (s *ProfileTestSuite) TestProfile() {
+	for range 3 {
+		s.Require().NoError(s.db.CreateExpense(5, "Coffee", "Eating Out", time.Now(), s.user.ID))
+	}
+
+	w := s.do(httptest.NewRequest("GET", "/settings/profile", http.NoBody), s.handlers.Profile)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "<dd>alice</dd>")
+	s.Contains(body, "<dd>3</dd>", "expenses entered")
+	s.Contains(body, s.user.CreatedAt.Local().Format("02 Jan 2006"))
+}
+
+func (s *ProfileTestSuite) TestRename() {
+	w := s.rename("alice.b")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Sign in as alice.b from now on")
+	s.Contains(w.Body.String(), "<dd>alice.b</dd>")
+
+	w = s.do(httptest.NewRequest("GET", "/settings/profile", http.NoBody), s.handlers.Profile)
+	s.Require().Equal(http.StatusOK, w.Code, "still signed in")
+	s.Contains(w.Body.String(), "<dd>alice.b</dd>")
+}
+
+func (s *ProfileTestSuite) TestRenameRejected() {
+	for username, message := range map[string]string{
+		"bob": "Username is already taken",
+		"BOB": "Username is already taken",
+		"a b": "Username must be",
+		"al":  "Username must be",
+	} {
+		w := s.rename(username)
+		s.Require().Equal(http.StatusOK, w.Code, username)
+		s.Contains(w.Body.String(), message, username)
+	}
+	user, err := s.db.GetUserByID(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal("alice", user.Username)
+}
+
+func (s *ProfileTestSuite) TestRenameWithLDAP() {
+	WithLDAP(auth.LDAPConfig{URL: "ldap://ldap.example.com", UserDN: "uid=%s,dc=example,dc=com"})(s.handlers)
+	s.Equal(http.StatusForbidden, s.rename("alice.b").Code)
+
+	w := s.do(httptest.NewRequest("GET", "/settings/profile", http.NoBody), s.handlers.Profile)
+	s.NotContains(w.Body.String(), `name="username"`, "no form to change it")
+}
+
+// TestProfileTestSuite runs the profile page test suite
+func TestProfileTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfileTestSuite))
+}
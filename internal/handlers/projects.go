@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"expense-tracker/internal/storage"
+)
+
+// maxProjectNameLength limits project names.
+const maxProjectNameLength = 50
+
+// parseProject reads the project field of the form for expense id, zero
+// for a new one, after ParseForm. present is false when the form has none,
+// e.g. an inline edit, which leaves the expense's project as it is. Zero
+// means no project. Only an expense the user sees can be tagged or untagged.
+func (h *Handlers) parseProject(r *http.Request, id int64) (projectID int64, present bool, err error) {
+	if _, present = r.Form["project"]; !present {
+		return 0, false, nil
+	}
+	if id != 0 {
+		if _, err := h.visibleExpense(r, id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, true, errors.New("expense not found")
+			}
+			return 0, true, err
+		}
+	}
+	value := r.FormValue("project")
+	if value == "" {
+		return 0, true, nil
+	}
+	projectID, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, true, errors.New("invalid project")
+	}
+	if _, err := h.store(r).GetProject(projectID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, true, errors.New("project not found")
+		}
+		return 0, true, err
+	}
+	return projectID, true, nil
+}
+
+// Projects renders the list of projects.
+func (h *Handlers) Projects(w http.ResponseWriter, r *http.Request) {
+	h.renderProjects(w, r, ProjectsViewModel{})
+}
+
+// renderProjects renders the projects page, listing the projects into
+// viewModel.
+func (h *Handlers) renderProjects(w http.ResponseWriter, r *http.Request, viewModel ProjectsViewModel) {
+	projects, err := h.store(r).ListProjects()
+	if err != nil {
+		h.logger.Printf("ListProjects error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	viewModel.Projects = projects
+	h.render(w, r, "projects.html", viewModel)
+}
+
+// CreateProject adds a project and opens its page.
+func (h *Handlers) CreateProject(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	switch {
+	case name == "":
+		h.renderProjects(w, r, ProjectsViewModel{Error: "Name the project"})
+		return
+	case utf8.RuneCountInString(name) > maxProjectNameLength:
+		h.renderProjects(w, r, ProjectsViewModel{Name: name, Error: fmt.Sprintf("Project names are at most %d characters", maxProjectNameLength)})
+		return
+	}
+
+	project, err := h.store(r).CreateProject(name)
+	if errors.Is(err, storage.ErrProjectExists) {
+		h.renderProjects(w, r, ProjectsViewModel{Name: name, Error: "There's a project with that name already"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("CreateProject error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Location", fmt.Sprintf(`{"path":"/projects/%d", "target":"#content"}`, project.ID))
+}
+
+// Project renders the summary of a project: its total, the split per
+// category, and the dates it spans.
+func (h *Handlers) Project(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	db := h.store(r)
+	project, err := db.GetProject(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("GetProject error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	summary, err := db.SummarizeProject(id, requestUserID(r))
+	if err != nil {
+		h.logger.Printf("SummarizeProject error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	viewModel := ProjectViewModel{Project: *project, Total: summary.Total, Count: summary.Count}
+	if summary.Count > 0 {
+		first, last := summary.First.Format("02 Jan 2006"), summary.Last.Format("02 Jan 2006")
+		viewModel.Span = first
+		if last != first {
+			viewModel.Span += " – " + last
+		}
+		// Calendar days, both ends included
+		start := time.Date(summary.First.Year(), summary.First.Month(), summary.First.Day(), 0, 0, 0, 0, time.UTC)
+		end := time.Date(summary.Last.Year(), summary.Last.Month(), summary.Last.Day(), 0, 0, 0, 0, time.UTC)
+		viewModel.Days = int(end.Sub(start).Hours()/24) + 1
+	}
+	for _, ct := range summary.Categories {
+		percentage := 0.0
+		if summary.Total > 0 {
+			percentage = ct.Total / summary.Total * 100
+		}
+		viewModel.Categories = append(viewModel.Categories, StatsCategoryItem{
+			Category:      ct.Category,
+			Total:         ct.Total,
+			Count:         ct.Count,
+			Percentage:    percentage,
+			CategoryStyle: getCategoryStyle(ct.Category),
+		})
+	}
+	h.render(w, r, "project.html", viewModel)
+}
+
+// DeleteProject removes a project, leaving its expenses unassigned, and
+// goes back to the list of projects.
+func (h *Handlers) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err := h.store(r).DeleteProject(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Printf("DeleteProject error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Location", `{"path":"/projects", "target":"#content"}`)
+}
+
+// ProjectOptions renders the project options of the expense form, with
+// the one in the selected query parameter selected.
+func (h *Handlers) ProjectOptions(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.store(r).ListProjects()
+	if err != nil {
+		h.logger.Printf("ListProjects error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	selected, _ := strconv.ParseInt(r.URL.Query().Get("selected"), 10, 64)
+	h.renderPartial(w, r, "base.html", "project-options", ProjectOptionsViewModel{Projects: projects, Selected: selected})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ProjectsTestSuite provides a test suite for projects
+type ProjectsTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+}
+
+// SetupTest runs before each test
+func (s *ProjectsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *ProjectsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// post sends a form to handler as user 1.
+func (s *ProjectsTestSuite) post(target string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id, ok := strings.CutPrefix(target, "/expenses/"); ok {
+		req.SetPathValue("id", id)
+	}
+	w := httptest.NewRecorder()
+	handler(w, asUser(req, 1))
+	return w
+}
+
+// get requests target from handler as user 1, with the path value id.
+func (s *ProjectsTestSuite) get(target, id string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, http.NoBody)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler(w, asUser(req, 1))
+	return w
+}
+
+func (s *ProjectsTestSuite) TestCreate() {
+	w := s.post("/projects", s.handlers.CreateProject, url.Values{"name": {" Japan trip 2025 "}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(`{"path":"/projects/1", "target":"#content"}`, w.Header().Get("HX-Location"))
+
+	w = s.post("/projects", s.handlers.CreateProject, url.Values{"name": {"japan trip 2025"}})
+	s.Contains(w.Body.String(), "There&#39;s a project with that name already")
+	w = s.post("/projects", s.handlers.CreateProject, url.Values{"name": {strings.Repeat("a", maxProjectNameLength+1)}})
+	s.Contains(w.Body.String(), "Project names are at most 50 characters")
+	w = s.post("/projects", s.handlers.CreateProject, url.Values{"name": {" "}})
+	s.Contains(w.Body.String(), "Name the project")
+
+	w = s.get("/projects", "", s.handlers.Projects)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal(1, strings.Count(w.Body.String(), "<strong>Japan trip 2025</strong>"))
+}
+
+func (s *ProjectsTestSuite) TestExpenseForm() {
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	form := url.Values{"amount": {"12"}, "description": {"Ramen"}, "category": {"Eating Out"}, "date": {"2026-03-02T08:30:00"}, "project": {"1"}}
+	w := s.post("/expenses", s.handlers.CreateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = s.get("/expenses/1/details", "1", s.handlers.ExpenseDetails)
+	var details struct{ Project int64 }
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &details))
+	s.Equal(japan.ID, details.Project)
+
+	w = s.get("/projects/options?selected=1", "", s.handlers.ProjectOptions)
+	s.Contains(w.Body.String(), `<option value="1" selected>Japan</option>`)
+
+	form.Del("project")
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	projectID, err := s.db.GetExpenseProject(1)
+	s.Require().NoError(err)
+	s.Equal(japan.ID, projectID, "kept without the field")
+
+	form.Set("project", "")
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	projectID, err = s.db.GetExpenseProject(1)
+	s.Require().NoError(err)
+	s.Zero(projectID, "removed")
+
+	form.Set("project", "99")
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	_, err = s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	private, err := s.db.CreateLedger("Private", "", bob.ID)
+	s.Require().NoError(err)
+	hidden := models.Expense{Amount: 5, Description: "Tea", Category: "Eating Out", Date: time.Now(), LedgerID: private.ID}
+	s.Require().NoError(s.db.InsertExpense(&hidden))
+	form.Set("project", "1")
+	w = s.post(fmt.Sprintf("/expenses/%d", hidden.ID), s.handlers.UpdateExpense, form)
+	s.Equal(http.StatusNotFound, w.Code, "bob's expense can't be tagged")
+	projectID, err = s.db.GetExpenseProject(hidden.ID)
+	s.Require().NoError(err)
+	s.Zero(projectID)
+}
+
+func (s *ProjectsTestSuite) TestSummary() {
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	day := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []models.Expense{
+		{Amount: 900, Description: "Flight", Category: "Travel", Date: day},
+		{Amount: 60, Description: "Ramen", Category: "Eating Out", Date: day.AddDate(0, 0, 3)},
+		{Amount: 40, Description: "Sushi", Category: "Eating Out", Date: day.AddDate(0, 1, 0)},
+	} {
+		s.Require().NoError(s.db.InsertExpense(&e))
+		s.Require().NoError(s.db.SetExpenseProject(int64(i+1), japan.ID))
+	}
+	_, err = s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	private, err := s.db.CreateLedger("Private", "", bob.ID)
+	s.Require().NoError(err)
+	hidden := models.Expense{Amount: 5000, Description: "Hotel", Category: "Travel", Date: day, LedgerID: private.ID}
+	s.Require().NoError(s.db.InsertExpense(&hidden))
+	s.Require().NoError(s.db.SetExpenseProject(hidden.ID, japan.ID))
+
+	w := s.get("/projects/1", "1", s.handlers.Project)
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "<dd>€1,000.00</dd>")
+	s.Contains(body, "<dd>01 Apr 2025 – 01 May 2025 (31 days)</dd>")
+	s.Contains(body, "90.0%")
+	s.Less(strings.Index(body, "<strong>Travel</strong>"), strings.Index(body, "<strong>Eating Out</strong>"))
+
+	s.Equal(http.StatusNotFound, s.get("/projects/99", "99", s.handlers.Project).Code)
+}
+
+func (s *ProjectsTestSuite) TestDelete() {
+	_, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	req := httptest.NewRequest("DELETE", "/projects/1", http.NoBody)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	s.handlers.DeleteProject(w, asUser(req, 1))
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Equal(`{"path":"/projects", "target":"#content"}`, w.Header().Get("HX-Location"))
+
+	w = httptest.NewRecorder()
+	s.handlers.DeleteProject(w, asUser(req, 1))
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+// TestProjectsTestSuite runs the projects test suite
+func TestProjectsTestSuite(t *testing.T) {
+	suite.Run(t, new(ProjectsTestSuite))
+}
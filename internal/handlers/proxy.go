@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// forwardedHTTPSKey marks requests a trusted proxy received over HTTPS.
+const forwardedHTTPSKey contextKey = "forwarded-https"
+
+// TrustProxies honours the X-Forwarded-For and X-Forwarded-Proto headers
+// of requests from the trusted proxies, so login history, lockouts, and
+// session binding see the client's address instead of the proxy's, and
+// cookies and links use HTTPS when the proxy does. The headers of other
+// requests are ignored, since anyone can send them.
+func TrustProxies(next http.Handler, trusted []netip.Prefix) http.Handler {
+	if len(trusted) == 0 {
+		return next
+	}
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer, err := netip.ParseAddr(clientIP(r))
+		if err != nil || !isTrusted(peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if proto := lastHeaderValue(r.Header.Values("X-Forwarded-Proto")); strings.EqualFold(proto, "https") {
+			ctx = context.WithValue(ctx, forwardedHTTPSKey, true)
+		}
+		r = r.WithContext(ctx)
+
+		// Each proxy appends the address it received the request from, so
+		// the client is the last address not added by a trusted proxy
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			r.RemoteAddr = net.JoinHostPort(addr.Unmap().String(), "0")
+			if !isTrusted(addr) {
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// lastHeaderValue returns the last comma-separated value of a header that
+// proxies may append to.
+func lastHeaderValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// isHTTPS reports whether the client sent the request over HTTPS, directly
+// or through a trusted proxy.
+func isHTTPS(r *http.Request) bool {
+	forwarded, _ := r.Context().Value(forwardedHTTPSKey).(bool)
+	return r.TLS != nil || forwarded
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ProxyTestSuite provides a test suite for the trusted proxy middleware
+type ProxyTestSuite struct {
+	suite.Suite
+	trusted []netip.Prefix
+}
+
+// SetupTest runs before each test
+func (s *ProxyTestSuite) SetupTest() {
+	s.trusted = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+}
+
+// serve sends a request from remoteAddr with the given forwarding headers and
+// returns the client IP and scheme the handler saw.
+func (s *ProxyTestSuite) serve(remoteAddr, forwardedFor, forwardedProto string) (string, bool) {
+	var ip string
+	var https bool
+	handler := TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, https = clientIP(r), isHTTPS(r)
+	}), s.trusted)
+
+	req := httptest.NewRequest("GET", "/", http.NoBody)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	if forwardedProto != "" {
+		req.Header.Set("X-Forwarded-Proto", forwardedProto)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return ip, https
+}
+
+func (s *ProxyTestSuite) TestTrustedProxy() {
+	ip, https := s.serve("10.0.0.2:4711", "203.0.113.7", "https")
+	s.Equal("203.0.113.7", ip)
+	s.True(https)
+
+	ip, https = s.serve("[::1]:4711", "2001:db8::1", "http")
+	s.Equal("2001:db8::1", ip)
+	s.False(https)
+}
+
+func (s *ProxyTestSuite) TestProxyChain() {
+	// The client made up the first address; the trusted proxies appended
+	// the rest
+	ip, _ := s.serve("10.0.0.2:4711", "198.51.100.1, 203.0.113.7, 10.0.0.3", "")
+	s.Equal("203.0.113.7", ip)
+
+	ip, _ = s.serve("10.0.0.2:4711", "10.0.0.4, 10.0.0.3", "")
+	s.Equal("10.0.0.4", ip, "all hops trusted")
+
+	ip, _ = s.serve("10.0.0.2:4711", "unknown", "")
+	s.Equal("10.0.0.2", ip, "unparsable addresses are ignored")
+
+	ip, _ = s.serve("10.0.0.2:4711", "", "")
+	s.Equal("10.0.0.2", ip)
+}
+
+func (s *ProxyTestSuite) TestUntrustedPeer() {
+	ip, https := s.serve("203.0.113.9:4711", "198.51.100.1", "https")
+	s.Equal("203.0.113.9", ip, "anyone can send forwarding headers")
+	s.False(https)
+}
+
+func (s *ProxyTestSuite) TestNoTrustedProxies() {
+	s.trusted = nil
+	ip, https := s.serve("10.0.0.2:4711", "203.0.113.7", "https")
+	s.Equal("10.0.0.2", ip)
+	s.False(https)
+}
+
+func (s *ProxyTestSuite) TestSecureCookieBehindProxy() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err)
+	defer db.Close()
+	h := NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")))
+
+	for proto, secure := range map[string]bool{"https": true, "http": false} {
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.RemoteAddr = "10.0.0.2:4711"
+		req.Header.Set("X-Forwarded-Proto", proto)
+		TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Equal(secure, h.sessionCookie(r, "token").Secure, proto)
+			s.Equal(proto+"://example.com", h.requestOrigin(r))
+		}), s.trusted).ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// TestProxySuite runs the trusted proxy test suite
+func TestProxySuite(t *testing.T) {
+	suite.Run(t, new(ProxyTestSuite))
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"expense-tracker/internal/models"
+)
+
+// parseReimbursable reads the reimbursable checkbox of the expense form,
+// after ParseForm. The form sends an empty field before the checkbox, so
+// present is false only when it has neither, e.g. an inline edit, which
+// leaves the state as it is.
+func parseReimbursable(r *http.Request) (reimbursable, present bool) {
+	values, present := r.Form["reimbursable"]
+	return slices.Contains(values, "on"), present
+}
+
+// saveReimbursable applies the reimbursable checkbox to an expense. Checking
+// it makes the expense pending unless it already has a state, and
+// unchecking it forgets the state.
+func (h *Handlers) saveReimbursable(db Store, expenseID int64, reimbursable bool) error {
+	status, err := db.GetReimbursement(expenseID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case reimbursable && status == "":
+		return db.SetReimbursement(expenseID, models.ReimbursementPending)
+	case !reimbursable && status != "":
+		return db.SetReimbursement(expenseID, "")
+	}
+	return nil
+}
+
+// Reimbursements renders the outstanding reimbursements of the user.
+func (h *Handlers) Reimbursements(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderReimbursements(w, r, user.ID, func(*ReimbursementsViewModel) {})
+}
+
+// renderReimbursements renders the reimbursements page for a user, letting
+// the caller add messages to the view model.
+func (h *Handlers) renderReimbursements(w http.ResponseWriter, r *http.Request, userID int64, decorate func(*ReimbursementsViewModel)) {
+	outstanding, err := h.store(r).OutstandingReimbursements(userID)
+	if err != nil {
+		h.logger.Printf("OutstandingReimbursements error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	groups := []ReimbursementGroup{{Title: "Pending"}, {Title: "Submitted"}}
+	var viewModel ReimbursementsViewModel
+	for _, o := range outstanding {
+		g := &groups[0]
+		if o.Status == models.ReimbursementSubmitted {
+			g = &groups[1]
+		}
+		g.Items = append(g.Items, ReimbursementItem{
+			ID:          o.Expense.ID,
+			Description: o.Expense.Description,
+			Category:    o.Expense.Category,
+			Date:        o.Expense.Date.Format("02 Jan 2006"),
+			Amount:      o.Expense.Amount,
+			Since:       o.UpdatedAt.Local().Format("02 Jan 2006"),
+		})
+		g.Total += o.Expense.Amount
+		viewModel.Total += o.Expense.Amount
+	}
+	for _, g := range groups {
+		if len(g.Items) > 0 {
+			viewModel.Groups = append(viewModel.Groups, g)
+		}
+	}
+	decorate(&viewModel)
+	h.render(w, r, "reimbursements.html", viewModel)
+}
+
+// UpdateReimbursements moves the checked expenses of the reimbursements page
+// to the state of the button pressed, e.g. marks them reimbursed in bulk.
+func (h *Handlers) UpdateReimbursements(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	status := r.FormValue("status")
+	if status != models.ReimbursementSubmitted && status != models.ReimbursementReimbursed {
+		http.Error(w, "Invalid reimbursement state", http.StatusBadRequest)
+		return
+	}
+	var ids []int64
+	for _, v := range r.Form["id"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		h.renderReimbursements(w, r, user.ID, func(vm *ReimbursementsViewModel) { vm.Error = "Select the expenses to mark first" })
+		return
+	}
+
+	n, err := h.store(r).SetReimbursements(user.ID, ids, status)
+	if err != nil {
+		h.logger.Printf("SetReimbursements error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	noun := "expenses"
+	if n == 1 {
+		noun = "expense"
+	}
+	h.renderReimbursements(w, r, user.ID, func(vm *ReimbursementsViewModel) {
+		vm.Notice = fmt.Sprintf("Marked %d %s %s", n, noun, status)
+	})
+}
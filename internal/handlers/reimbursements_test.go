@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ReimbursementsTestSuite provides a test suite for reimbursable expenses
+type ReimbursementsTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+}
+
+// SetupTest runs before each test
+func (s *ReimbursementsTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithLogger(log.New(io.Discard, "", 0)))
+}
+
+// TearDownTest runs after each test
+func (s *ReimbursementsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// post sends a form to handler as user 1.
+func (s *ReimbursementsTestSuite) post(target string, handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id, ok := strings.CutPrefix(target, "/expenses/"); ok {
+		req.SetPathValue("id", id)
+	}
+	w := httptest.NewRecorder()
+	handler(w, asUser(req, 1))
+	return w
+}
+
+// status returns the reimbursement state of an expense.
+func (s *ReimbursementsTestSuite) status(id int64) string {
+	status, err := s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	return status
+}
+
+func (s *ReimbursementsTestSuite) TestExpenseForm() {
+	form := url.Values{"amount": {"12"}, "description": {"Taxi"}, "category": {"Transport"}, "date": {"2026-03-02T08:30:00"}, "reimbursable": {"", "on"}}
+	w := s.post("/expenses", s.handlers.CreateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(models.ReimbursementPending, s.status(1))
+
+	s.Require().NoError(s.db.SetReimbursement(1, models.ReimbursementSubmitted))
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(models.ReimbursementSubmitted, s.status(1), "still checked keeps the state")
+
+	form.Del("reimbursable")
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Equal(models.ReimbursementSubmitted, s.status(1), "kept without the field")
+
+	form.Set("reimbursable", "")
+	w = s.post("/expenses/1", s.handlers.UpdateExpense, form)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	s.Empty(s.status(1), "unchecked")
+}
+
+func (s *ReimbursementsTestSuite) TestPage() {
+	userID := int64(1)
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, status := range []string{models.ReimbursementPending, models.ReimbursementSubmitted, models.ReimbursementReimbursed} {
+		e := &models.Expense{Amount: float64(10 * (i + 1)), Description: "Trip " + status, Category: "Transport", Date: day, UserID: &userID}
+		s.Require().NoError(s.db.InsertExpense(e))
+		s.Require().NoError(s.db.SetReimbursement(e.ID, status))
+	}
+
+	w := httptest.NewRecorder()
+	s.handlers.Reimbursements(w, asUser(httptest.NewRequest("GET", "/reimbursements", http.NoBody), 1))
+	s.Require().Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	s.Contains(body, "Trip pending")
+	s.Contains(body, "Trip submitted")
+	s.NotContains(body, "Trip reimbursed")
+	s.Contains(body, "Outstanding <strong>€30.00</strong>")
+	s.Less(strings.Index(body, "Pending"), strings.Index(body, "Submitted"))
+
+	w = s.post("/reimbursements", s.handlers.UpdateReimbursements, url.Values{"id": {"1", "2"}, "status": {models.ReimbursementReimbursed}})
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Marked 2 expenses reimbursed")
+	s.Contains(w.Body.String(), "Nothing to be paid back")
+	s.Equal(models.ReimbursementReimbursed, s.status(1))
+	s.Equal(models.ReimbursementReimbursed, s.status(2))
+}
+
+func (s *ReimbursementsTestSuite) TestInvalidUpdate() {
+	w := s.post("/reimbursements", s.handlers.UpdateReimbursements, url.Values{"id": {"1"}, "status": {"lost"}})
+	s.Equal(http.StatusBadRequest, w.Code)
+	w = s.post("/reimbursements", s.handlers.UpdateReimbursements, url.Values{"id": {"x"}, "status": {models.ReimbursementReimbursed}})
+	s.Equal(http.StatusBadRequest, w.Code)
+	w = s.post("/reimbursements", s.handlers.UpdateReimbursements, url.Values{"status": {models.ReimbursementReimbursed}})
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "Select the expenses to mark first")
+}
+
+// TestReimbursementsTestSuite runs the reimbursable expenses test suite
+func TestReimbursementsTestSuite(t *testing.T) {
+	suite.Run(t, new(ReimbursementsTestSuite))
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginHistoryLimit is how many recent login attempts the security page shows.
+const loginHistoryLimit = 20
+
+// emailVerificationTTL is how long an email verification link stays valid.
+const emailVerificationTTL = 24 * time.Hour
+
+// accountDeletionGrace is how long a deleted account can still be restored by
+// signing in.
+const accountDeletionGrace = 14 * 24 * time.Hour
+
+// verifyEmailPurpose scopes signed tokens to email verification links.
+const verifyEmailPurpose = "verify-email"
+
+// SecuritySettings renders the security settings page with the user's email
+// address, passkeys, and recent login history.
+func (h *Handlers) SecuritySettings(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.renderSecurity(w, r, user.ID, func(*SecurityViewModel) {})
+}
+
+// renderSecurity renders the security settings page for a user, letting the
+// caller add messages to the view model.
+func (h *Handlers) renderSecurity(w http.ResponseWriter, r *http.Request, userID int64, decorate func(*SecurityViewModel)) {
+	db := h.store(r)
+	// Reload the user, the one in the context predates any change just made
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		h.logger.Printf("SecuritySettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := db.ListLoginEvents(user.ID, loginHistoryLimit)
+	if err != nil {
+		h.logger.Printf("SecuritySettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	passkeys, err := db.ListPasskeys(user.ID)
+	if err != nil {
+		h.logger.Printf("SecuritySettings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	viewModel := SecurityViewModel{
+		Username:      user.Username,
+		IsAdmin:       user.IsAdmin(),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		GraceDays:     int(accountDeletionGrace.Hours() / 24),
+		ReadOnly:      h.ReadOnly(),
+	}
+	for _, p := range passkeys {
+		item := PasskeyItem{
+			ID:      p.ID,
+			Name:    p.Name,
+			Created: p.CreatedAt.Local().Format("02 Jan 2006"),
+		}
+		if p.LastUsedAt != nil {
+			item.LastUsed = p.LastUsedAt.Local().Format("02 Jan 2006, 15:04")
+		}
+		viewModel.Passkeys = append(viewModel.Passkeys, item)
+	}
+	for _, e := range events {
+		viewModel.Logins = append(viewModel.Logins, LoginItem{
+			Time:      e.CreatedAt.Local().Format("02 Jan 2006, 15:04"),
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Success:   e.Success,
+		})
+	}
+	decorate(&viewModel)
+	h.render(w, r, "security.html", viewModel)
+}
+
+// UpdateEmail sets the user's email address and sends a link to verify it.
+func (h *Handlers) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if err := auth.ValidateEmail(email); err != nil {
+		h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.EmailError = capitalize(err.Error()) })
+		return
+	}
+	if err := h.store(r).SetEmail(user.ID, email); err != nil {
+		if errors.Is(err, storage.ErrEmailTaken) {
+			h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.EmailError = "That email address is already in use" })
+			return
+		}
+		h.logger.Printf("UpdateEmail error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sendVerificationEmail(r, user, email); err != nil {
+		h.logger.Printf("Failed to send verification email: %v", err)
+		h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.EmailError = emailSendFailed })
+		return
+	}
+	h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) {
+		vm.EmailNotice = "We sent a verification link to " + email
+	})
+}
+
+// ResendVerificationEmail sends another verification link for the user's
+// unverified email address.
+func (h *Handlers) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.Email == "" || user.EmailVerified {
+		h.renderSecurity(w, r, user.ID, func(*SecurityViewModel) {})
+		return
+	}
+	if err := h.sendVerificationEmail(r, user, user.Email); err != nil {
+		h.logger.Printf("Failed to send verification email: %v", err)
+		h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.EmailError = emailSendFailed })
+		return
+	}
+	h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) {
+		vm.EmailNotice = "We sent a new verification link to " + user.Email
+	})
+}
+
+// emailSendFailed is shown when a verification email could not be sent.
+const emailSendFailed = "We couldn't send the verification email. Please try again later."
+
+// sendVerificationEmail sends the user a signed link that verifies email.
+func (h *Handlers) sendVerificationEmail(r *http.Request, user *models.User, email string) error {
+	token := auth.SignToken(h.secretKey, verifyEmailPurpose,
+		strconv.FormatInt(user.ID, 10)+"|"+email, h.now().Add(emailVerificationTTL))
+	return h.mailer.Send(email, "verify-email.txt", map[string]string{
+		"Username": user.Username,
+		"Email":    email,
+		"Link":     h.requestOrigin(r) + "/verify-email?token=" + url.QueryEscape(token),
+	})
+}
+
+// VerifyEmail handles the link sent by sendVerificationEmail. It does not
+// require a session, so the link works from a mail app's browser.
+func (h *Handlers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := h.parseVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		h.render(w, r, "verify-email.html", VerifyEmailViewModel{Error: "This link is invalid or has expired. Request a new one from your security settings."})
+		return
+	}
+
+	switch err := h.store(r).VerifyEmail(userID, email); {
+	case err == nil:
+		h.render(w, r, "verify-email.html", VerifyEmailViewModel{Email: email})
+	case errors.Is(err, sql.ErrNoRows):
+		h.render(w, r, "verify-email.html", VerifyEmailViewModel{Error: "Your email address has changed since this link was sent."})
+	case errors.Is(err, storage.ErrEmailTaken):
+		h.render(w, r, "verify-email.html", VerifyEmailViewModel{Error: "This email address is already in use by another account."})
+	default:
+		h.logger.Printf("VerifyEmail error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseVerificationToken returns the user ID and email address a
+// verification link was issued for.
+func (h *Handlers) parseVerificationToken(token string) (int64, string, error) {
+	payload, err := auth.VerifyToken(h.secretKey, verifyEmailPurpose, token, h.now())
+	if err != nil {
+		return 0, "", err
+	}
+	id, email, ok := strings.Cut(payload, "|")
+	if !ok {
+		return 0, "", errors.New("malformed verification token")
+	}
+	userID, err := strconv.ParseInt(id, 10, 64)
+	return userID, email, err
+}
+
+// DeleteAccount schedules the user's account for deletion after they confirm
+// with their password, and signs them out everywhere. Signing in again within
+// accountDeletionGrace cancels it; after that the account and its data are
+// purged.
+func (h *Handlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	valid, err := h.checkPassword(user, r.FormValue("password"))
+	if errors.Is(err, errLocalAccount) {
+		// The directory user of the same name isn't the account's owner
+		valid, err = false, nil
+	}
+	if err != nil {
+		h.logger.Printf("DeleteAccount error: %v", err)
+		h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.DeleteError = "An error occurred. Please try again." })
+		return
+	}
+	if !valid {
+		h.renderSecurity(w, r, user.ID, func(vm *SecurityViewModel) { vm.DeleteError = "Incorrect password" })
+		return
+	}
+
+	if err := h.store(r).ScheduleAccountDeletion(user.ID, h.now().Add(accountDeletionGrace)); err != nil {
+		h.logger.Printf("DeleteAccount error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Printf("User %s scheduled their account for deletion", user.Username)
+
+	h.clearSessionCookie(w, r)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/login?deleted=1")
+		return
+	}
+	http.Redirect(w, r, "/login?deleted=1", http.StatusFound)
+}
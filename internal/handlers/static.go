@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// fingerprintLength is how many hex digits of a file's SHA-256 hash go into
+// its fingerprinted URL.
+const fingerprintLength = 10
+
+// StaticAssets serves the files under /static/ and builds fingerprinted URLs
+// for them, e.g. /static/style.3f2a9c1b07.css. A fingerprinted URL changes
+// whenever the file does, so browsers may cache it forever.
+type StaticAssets struct {
+	fsys   fs.FS
+	hashes map[string]string // File name to fingerprint
+}
+
+// NewStaticAssets hashes every file in fsys. Files changed afterwards keep
+// their old fingerprint until the server restarts.
+func NewStaticAssets(fsys fs.FS) (*StaticAssets, error) {
+	a := &StaticAssets{fsys: fsys, hashes: map[string]string{}}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return err
+		}
+		a.hashes[name] = hex.EncodeToString(hash.Sum(nil))[:fingerprintLength]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// URL returns the fingerprinted URL of a static file, or its plain URL if
+// the file wasn't there at startup.
+func (a *StaticAssets) URL(name string) string {
+	hash, ok := a.hashes[name]
+	if !ok {
+		return "/static/" + name
+	}
+	ext := path.Ext(name)
+	return "/static/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// ServeHTTP serves a static file by its name relative to /static/. Requests
+// with the current fingerprint may be cached forever; plain and outdated
+// URLs must be revalidated, which the ETag makes cheap.
+func (a *StaticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if original, hash, ok := a.unfingerprint(name); ok {
+		name = original
+		if hash == a.hashes[name] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+	}
+	hash, ok := a.hashes[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeFileFS(w, r, a.fsys, name)
+}
+
+// unfingerprint splits a fingerprinted file name into the original name and
+// the fingerprint.
+func (a *StaticAssets) unfingerprint(name string) (string, string, bool) {
+	ext := path.Ext(name)
+	// The fingerprint goes before the extension, or at the end of file names
+	// without one
+	for _, suffix := range []string{ext, ""} {
+		i := len(name) - len(suffix) - fingerprintLength - 1
+		if i <= 0 || name[i] != '.' {
+			continue
+		}
+		original := name[:i] + suffix
+		if _, known := a.hashes[original]; known {
+			return original, name[i+1 : len(name)-len(suffix)], true
+		}
+	}
+	return "", "", false
+}
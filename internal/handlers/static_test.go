@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"expense-tracker/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// StaticAssetsTestSuite provides a test suite for static file fingerprinting
+type StaticAssetsTestSuite struct {
+	suite.Suite
+	assets *StaticAssets
+}
+
+// SetupTest runs before each test
+func (s *StaticAssetsTestSuite) SetupTest() {
+	assets, err := NewStaticAssets(fstest.MapFS{
+		"style.css":        {Data: []byte("body { margin: 0; }")},
+		"icons/coffee.svg": {Data: []byte("<svg></svg>")},
+		"LICENSE":          {Data: []byte("MIT")},
+	})
+	s.Require().NoError(err)
+	s.assets = assets
+}
+
+func (s *StaticAssetsTestSuite) get(path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, http.NoBody)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	http.StripPrefix("/static/", s.assets).ServeHTTP(w, req)
+	return w
+}
+
+func (s *StaticAssetsTestSuite) TestURL() {
+	s.Regexp(`^/static/style\.[0-9a-f]{10}\.css$`, s.assets.URL("style.css"))
+	s.Regexp(`^/static/icons/coffee\.[0-9a-f]{10}\.svg$`, s.assets.URL("icons/coffee.svg"))
+	s.Regexp(`^/static/LICENSE\.[0-9a-f]{10}$`, s.assets.URL("LICENSE"))
+	s.Equal("/static/missing.js", s.assets.URL("missing.js"))
+}
+
+func (s *StaticAssetsTestSuite) TestFingerprintChangesWithContent() {
+	other, err := NewStaticAssets(fstest.MapFS{"style.css": {Data: []byte("body { margin: 1em; }")}})
+	s.Require().NoError(err)
+	s.NotEqual(s.assets.URL("style.css"), other.URL("style.css"))
+}
+
+func (s *StaticAssetsTestSuite) TestServeFingerprinted() {
+	for _, name := range []string{"style.css", "icons/coffee.svg", "LICENSE"} {
+		w := s.get(s.assets.URL(name))
+		s.Equal(http.StatusOK, w.Code, name)
+		s.Contains(w.Header().Get("Cache-Control"), "immutable", name)
+	}
+	w := s.get(s.assets.URL("style.css"))
+	s.Equal("body { margin: 0; }", w.Body.String())
+	s.Contains(w.Header().Get("Content-Type"), "text/css")
+}
+
+func (s *StaticAssetsTestSuite) TestServePlainAndOutdated() {
+	w := s.get("/static/style.css")
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("no-cache", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	s.NotEmpty(etag)
+
+	w = s.get("/static/style.0123456789.css")
+	s.Equal(http.StatusOK, w.Code, "pages cached before a deploy still get their styles")
+	s.Equal("no-cache", w.Header().Get("Cache-Control"))
+
+	w = s.get("/static/style.css", "If-None-Match", etag)
+	s.Equal(http.StatusNotModified, w.Code)
+}
+
+func (s *StaticAssetsTestSuite) TestServeMissing() {
+	s.Equal(http.StatusNotFound, s.get("/static/missing.js").Code)
+	s.Equal(http.StatusNotFound, s.get("/static/icons/").Code)
+	s.Equal(http.StatusNotFound, s.get("/static/missing.0123456789.js").Code)
+}
+
+func (s *StaticAssetsTestSuite) TestTemplatesLinkFingerprintedURLs() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err)
+	defer db.Close()
+	assets, err := NewStaticAssets(fstest.MapFS{"style.css": {Data: []byte("body {}")}})
+	s.Require().NoError(err)
+	h := NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")), WithStaticAssets(assets))
+
+	w := httptest.NewRecorder()
+	h.LoginForm(w, httptest.NewRequest("GET", "/login", http.NoBody))
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `href="`+assets.URL("style.css")+`"`)
+	s.Contains(w.Body.String(), `src="/static/passkeys.js"`, "files unknown at startup keep their plain URL")
+}
+
+// TestStaticAssetsSuite runs the static assets test suite
+func TestStaticAssetsSuite(t *testing.T) {
+	suite.Run(t, new(StaticAssetsTestSuite))
+}
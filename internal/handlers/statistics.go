@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"log"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
 )
 
 // StatsCategoryItem represents a category with its spending statistics.
@@ -37,7 +39,6 @@ type StatsViewModel struct {
 	AverageSpending  float64
 	AverageLabel     string
 	Categories       []StatsCategoryItem
-	Expenses         []ExpenseItem
 	ChartData        []ChartPoint
 	MaxChartValue    float64
 	PrevYear         int
@@ -45,6 +46,8 @@ type StatsViewModel struct {
 	NextYear         int
 	NextMonth        int
 	IsCurrentPeriod  bool
+	Places           []PlaceItem // Where the most was spent
+	Guest            bool        // Viewed with a guest link, so without edits
 }
 
 // Statistics renders the statistics page.
@@ -58,7 +61,7 @@ func (h *Handlers) Statistics(w http.ResponseWriter, r *http.Request) {
 	yearStr := r.URL.Query().Get("year")
 	monthStr := r.URL.Query().Get("month")
 
-	now := time.Now()
+	now := h.userNow(r)
 	year := now.Year()
 	month := int(now.Month())
 
@@ -73,45 +76,38 @@ func (h *Handlers) Statistics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var viewModel StatsViewModel
+	if h.notModified(w, r) {
+		return
+	}
 
+	var viewModel StatsViewModel
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
 	if viewMode == "year" {
-		viewModel = h.buildYearView(year, now)
+		viewModel = h.buildYearView(h.store(r), h.ledger(r), year, now)
+		from = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(1, 0, 0)
 	} else {
-		viewModel = h.buildMonthView(year, month, now)
+		viewModel = h.buildMonthView(h.store(r), h.ledger(r), year, month, now)
 	}
+	viewModel.Places = h.placeItems(h.store(r), h.ledger(r), from, to)
+	viewModel.Guest = guest(r) != nil
 
 	h.render(w, r, "stats.html", viewModel)
 }
 
-// buildMonthView builds the view model for month view.
-func (h *Handlers) buildMonthView(year, month int, now time.Time) StatsViewModel {
-	// Get category totals
-	categoryTotals, err := h.db.GetCategoryTotalsByMonth(year, month)
-	if err != nil {
-		log.Printf("GetCategoryTotalsByMonth error: %v", err)
-		return StatsViewModel{}
-	}
-
-	// Get expenses for the month
-	expenses, err := h.db.GetExpensesByMonth(year, month)
+// buildMonthView builds the view model for month view of a ledger.
+func (h *Handlers) buildMonthView(db Store, ledgerID int64, year, month int, now time.Time) StatsViewModel {
+	// Fetch everything for the month in one round trip
+	stats, err := db.GetMonthStats(ledgerID, year, month)
 	if err != nil {
-		log.Printf("GetExpensesByMonth error: %v", err)
+		h.logger.Printf("GetMonthStats error: %v", err)
 		return StatsViewModel{}
 	}
+	categoryTotals, dailyTotals := stats.Categories, stats.Daily
+	total, prevTotal := stats.Total, stats.PrevTotal
 
-	// Get daily totals for chart
-	dailyTotals, err := h.db.GetDailyTotalsForMonth(year, month)
-	if err != nil {
-		log.Printf("GetDailyTotalsForMonth error: %v", err)
-	}
-
-	// Calculate total
-	total, _ := h.db.GetTotalForPeriod(year, month)
-
-	// Get previous month total for percentage change
 	prevDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
-	prevTotal, _ := h.db.GetTotalForPeriod(prevDate.Year(), int(prevDate.Month()))
 
 	// Calculate percentage change
 	percentageChange := 0.0
@@ -173,21 +169,6 @@ func (h *Handlers) buildMonthView(year, month int, now time.Time) StatsViewModel
 		})
 	}
 
-	// Prepare expense items
-	expenseItems := make([]ExpenseItem, 0, len(expenses))
-	for _, e := range expenses {
-		expenseItems = append(expenseItems, ExpenseItem{
-			ID:            e.ID,
-			Amount:        e.Amount,
-			Description:   e.Description,
-			Category:      e.Category,
-			Time:          e.Date.Format("Jan 02, 15:04"),
-			DateTime:      e.Date.Format("2006-01-02T15:04:05"),
-			CategoryStyle: getCategoryStyle(e.Category),
-			IsIncome:      strings.Contains(e.Description, "[Income]"),
-		})
-	}
-
 	// Calculate previous and next month
 	nextDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
 
@@ -208,7 +189,6 @@ func (h *Handlers) buildMonthView(year, month int, now time.Time) StatsViewModel
 		AverageSpending:  averageSpending,
 		AverageLabel:     "SPENT/DAY",
 		Categories:       categoryItems,
-		Expenses:         expenseItems,
 		ChartData:        chartData,
 		MaxChartValue:    maxValue,
 		PrevYear:         prevDate.Year(),
@@ -219,33 +199,26 @@ func (h *Handlers) buildMonthView(year, month int, now time.Time) StatsViewModel
 	}
 }
 
-// buildYearView builds the view model for year view.
-func (h *Handlers) buildYearView(year int, now time.Time) StatsViewModel {
+// buildYearView builds the view model for year view of a ledger.
+func (h *Handlers) buildYearView(db Store, ledgerID int64, year int, now time.Time) StatsViewModel {
 	// Get category totals for the year
-	categoryTotals, err := h.db.GetCategoryTotalsByYear(year)
-	if err != nil {
-		log.Printf("GetCategoryTotalsByYear error: %v", err)
-		return StatsViewModel{}
-	}
-
-	// Get expenses for the year
-	expenses, err := h.db.GetExpensesByYear(year)
+	categoryTotals, err := db.GetCategoryTotalsByYear(ledgerID, year)
 	if err != nil {
-		log.Printf("GetExpensesByYear error: %v", err)
+		h.logger.Printf("GetCategoryTotalsByYear error: %v", err)
 		return StatsViewModel{}
 	}
 
 	// Get monthly totals for chart
-	monthlyTotals, err := h.db.GetMonthlyTotalsForYear(year)
+	monthlyTotals, err := db.GetMonthlyTotalsForYear(ledgerID, year)
 	if err != nil {
-		log.Printf("GetMonthlyTotalsForYear error: %v", err)
+		h.logger.Printf("GetMonthlyTotalsForYear error: %v", err)
 	}
 
 	// Calculate total
-	total, _ := h.db.GetTotalForPeriod(year, 0)
+	total, _ := db.GetTotalForPeriod(ledgerID, year, 0)
 
 	// Get previous year total for percentage change
-	prevTotal, _ := h.db.GetTotalForPeriod(year-1, 0)
+	prevTotal, _ := db.GetTotalForPeriod(ledgerID, year-1, 0)
 
 	// Calculate percentage change
 	percentageChange := 0.0
@@ -301,21 +274,6 @@ func (h *Handlers) buildYearView(year int, now time.Time) StatsViewModel {
 		})
 	}
 
-	// Prepare expense items
-	expenseItems := make([]ExpenseItem, 0, len(expenses))
-	for _, e := range expenses {
-		expenseItems = append(expenseItems, ExpenseItem{
-			ID:            e.ID,
-			Amount:        e.Amount,
-			Description:   e.Description,
-			Category:      e.Category,
-			Time:          e.Date.Format("Jan 02, 15:04"),
-			DateTime:      e.Date.Format("2006-01-02T15:04:05"),
-			CategoryStyle: getCategoryStyle(e.Category),
-			IsIncome:      strings.Contains(e.Description, "[Income]"),
-		})
-	}
-
 	// Check if this is the current year
 	isCurrentPeriod := year == now.Year()
 
@@ -331,7 +289,6 @@ func (h *Handlers) buildYearView(year int, now time.Time) StatsViewModel {
 		AverageSpending:  averageSpending,
 		AverageLabel:     "SPENT/MTH",
 		Categories:       categoryItems,
-		Expenses:         expenseItems,
 		ChartData:        chartData,
 		MaxChartValue:    maxValue,
 		PrevYear:         year - 1,
@@ -341,3 +298,82 @@ func (h *Handlers) buildYearView(year int, now time.Time) StatsViewModel {
 		IsCurrentPeriod:  isCurrentPeriod,
 	}
 }
+
+// statsPageSize is how many of a category's expenses the statistics page
+// fetches at a time.
+const statsPageSize = 50
+
+// StatsExpensesViewModel is the data for a page of a category's expenses on
+// the statistics page.
+type StatsExpensesViewModel struct {
+	Expenses []ExpenseItem
+	NextURL  string // Fetches the following page, empty on the last one
+}
+
+// StatisticsExpenses renders a page of the expenses of a category in a month,
+// or in a whole year without one. The statistics page fetches them when a
+// category is expanded, so it renders as fast for a year of expenses as for
+// a day of them.
+func (h *Handlers) StatisticsExpenses(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	year, err := strconv.Atoi(query.Get("year"))
+	if err != nil {
+		http.Error(w, "Invalid year", http.StatusBadRequest)
+		return
+	}
+	month := 0
+	if s := query.Get("month"); s != "" && s != "0" {
+		if month, err = strconv.Atoi(s); err != nil || month < 1 || month > 12 {
+			http.Error(w, "Invalid month", http.StatusBadRequest)
+			return
+		}
+	}
+	offset := 0
+	if s := query.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if h.notModified(w, r) {
+		return
+	}
+
+	filter := storage.ExpenseFilter{
+		From:     time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+		Category: query.Get("category"),
+		Ledger:   h.ledger(r),
+	}
+	filter.To = filter.From.AddDate(1, 0, 0)
+	if month != 0 {
+		filter.From = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		filter.To = filter.From.AddDate(0, 1, 0)
+	}
+	// One more than a page tells whether there is another
+	expenses, err := h.store(r).PageExpenses(filter, statsPageSize+1, offset)
+	if err != nil {
+		h.logger.Printf("PageExpenses error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var viewModel StatsExpensesViewModel
+	if len(expenses) > statsPageSize {
+		expenses = expenses[:statsPageSize]
+		query.Set("offset", strconv.Itoa(offset+statsPageSize))
+		viewModel.NextURL = "/statistics/expenses?" + query.Encode()
+	}
+	// Others' expenses show who entered them
+	var userID int64
+	if user, ok := r.Context().Value(UserContextKey).(*models.User); ok {
+		userID = user.ID
+	}
+	for _, e := range expenses {
+		item := newExpenseItem(e, userID)
+		item.Time = e.Date.Format("Jan 02, 15:04")
+		item.IsIncome = strings.Contains(e.Description, "[Income]")
+		viewModel.Expenses = append(viewModel.Expenses, item)
+	}
+	h.renderPartial(w, r, "stats.html", "category-expenses", viewModel)
+}
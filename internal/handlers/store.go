@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Store is the data the handlers work with. *storage.DB is the real one;
+// tests can use the in-memory memstore.Store instead, which can also be made
+// to fail.
+type Store interface {
+	// Expenses
+	CreateExpense(amount float64, description, category string, date time.Time, userID int64) error
+	InsertExpense(e *models.Expense) error
+	InsertExpenseWithDetails(e *models.Expense, d storage.ExpenseDetails) error
+	ImportExpenses(expenses []models.Expense, userID int64, dryRun bool) (int, error)
+	GetExpense(id int64) (*models.Expense, error)
+	UpdateExpense(e *models.Expense) error
+	DeleteExpense(id int64) error
+	RestoreExpense(id int64) error
+	UpdateExpenseIfUnchanged(e *models.Expense, version int64) error
+	DeleteExpenseIfUnchanged(id, version int64) error
+	ExpenseChanges(since int64, limit int) ([]storage.ExpenseChange, error)
+	FilterExpenses(f storage.ExpenseFilter) ([]models.Expense, error)
+	PageExpenses(f storage.ExpenseFilter, limit, offset int) ([]models.Expense, error)
+	SearchExpenses(query string, ledgerID int64, limit int) ([]models.Expense, error)
+	ScrollExpenses(f storage.ExpenseFilter, after storage.ExpenseCursor, limit int) ([]models.Expense, storage.ExpenseCursor, error)
+	StreamExpenses(f storage.ExpenseFilter, fn func(models.Expense) error) error
+	LatestExpenseDate(f storage.ExpenseFilter) (time.Time, error)
+	SummarizeExpenses(f storage.ExpenseFilter) (*storage.ExpenseSummary, error)
+	CategoryTotals(f storage.ExpenseFilter) ([]storage.CategoryTotal, error)
+	ListCategories() ([]string, error)
+	CategoryUsage(userID int64, since time.Time) ([]string, error)
+	GetExpenseLocation(expenseID int64) (models.Location, error)
+	SetExpenseLocation(expenseID int64, loc models.Location) error
+	SpendingByPlace(f storage.ExpenseFilter, limit int) ([]storage.PlaceTotal, error)
+	GetReimbursement(expenseID int64) (string, error)
+	SetReimbursement(expenseID int64, status string) error
+	SetReimbursements(userID int64, ids []int64, status string) (int64, error)
+	OutstandingReimbursements(userID int64) ([]storage.Reimbursable, error)
+	CreateProject(name string) (*models.Project, error)
+	ListProjects() ([]models.Project, error)
+	GetProject(id int64) (*models.Project, error)
+	DeleteProject(id int64) error
+	GetExpenseProject(expenseID int64) (int64, error)
+	SetExpenseProject(expenseID, projectID int64) error
+	SummarizeProject(projectID, userID int64) (*storage.ProjectSummary, error)
+	CreateLedger(name, currency string, ownerID int64) (*models.Ledger, error)
+	ListLedgers(userID int64) ([]models.Ledger, error)
+	GetLedger(id int64) (*models.Ledger, error)
+	SetLedgerCurrency(id int64, currency string) error
+	ArchiveLedger(id int64, archived bool) error
+	DeleteLedger(id int64) error
+	LedgerTotals(userID int64, start, end time.Time) ([]storage.LedgerTotal, error)
+	DataVersion() (storage.DataVersion, error)
+
+	// Statistics, of one ledger
+	GetMonthStats(ledgerID int64, year, month int) (*storage.MonthStats, error)
+	GetTotalForPeriod(ledgerID int64, year, month int) (float64, error)
+	GetTotalForCycle(ledgerID int64, start time.Time) (float64, error)
+	GetTotalBetween(ledgerID int64, start, end time.Time) (float64, error)
+	GetMonthlyTotalsForYear(ledgerID int64, year int) ([]storage.MonthlyTotal, error)
+	GetCategoryTotalsByYear(ledgerID int64, year int) ([]storage.CategoryTotal, error)
+
+	// Users and accounts
+	CreateUser(username, passwordHash string) (*models.User, error)
+	GetUserByID(id int64) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	RenameUser(userID int64, username string) error
+	UserExpenseCount(userID int64) (int, error)
+	SetEmail(userID int64, email string) error
+	VerifyEmail(userID int64, email string) error
+	ScheduleAccountDeletion(userID int64, at time.Time) error
+	CancelAccountDeletion(userID int64) (bool, error)
+	GetPreferences(userID int64) (models.Preferences, error)
+	SavePreferences(userID int64, p models.Preferences) error
+	SetAvatar(userID int64, image []byte, contentType string) error
+	GetAvatar(userID int64) (*models.Avatar, error)
+	DeleteAvatar(userID int64) error
+
+	// Logins
+	GetLoginLockout(userID int64) (*storage.LoginLockout, error)
+	RecordFailedLogin(userID int64) (int, error)
+	LockUser(userID int64, until time.Time) error
+	ResetFailedLogins(userID int64) error
+	RecordLogin(userID *int64, username, ip, userAgent string, success bool) error
+	ListLoginEvents(userID int64, limit int) ([]models.LoginEvent, error)
+
+	// Sessions
+	CreateSession(token string, userID int64, duration time.Duration, fingerprint string) error
+	ValidateSession(token string) (*models.User, error)
+	ValidateSessionWithInfo(token string) (*storage.SessionInfo, error)
+	RenewSession(token string, newExpiresAt time.Time) error
+	DeleteSession(token string) error
+
+	// Passkeys
+	CreatePasskey(p *models.Passkey) error
+	GetPasskey(id string) (*models.Passkey, error)
+	ListPasskeys(userID int64) ([]models.Passkey, error)
+	UsePasskey(id string, signCount uint32) error
+	DeletePasskey(userID int64, id string) error
+
+	// Notifications
+	AddNotificationChannel(c *models.NotificationChannel) error
+	ListNotificationChannels(userID int64) ([]models.NotificationChannel, error)
+	SetNotificationEvents(userID, channelID int64, events []string) error
+	DeleteNotificationChannel(userID, channelID int64) error
+	ListNotificationDeliveries(userID int64, limit int) ([]models.NotificationDelivery, error)
+
+	// Challenges, whose progress the analytics job tracks
+	CreateChallenge(c *models.Challenge) error
+	ListChallenges(userID int64) ([]models.Challenge, error)
+	DeleteChallenge(userID, id int64) error
+	GetStreak(userID int64) (models.Streak, error)
+
+	// Guest tokens, for read-only access to one ledger
+	CreateGuestToken(t *models.GuestToken, tokenHash string) error
+	ListGuestTokens(userID int64) ([]models.GuestToken, error)
+	ValidateGuestToken(tokenHash string) (*models.GuestToken, *models.User, error)
+	DeleteGuestToken(userID, id int64) error
+
+	// Invites
+	CreateInvite(tokenHash string, createdBy int64, expiresAt time.Time) (*models.Invite, error)
+	GetInvite(tokenHash string) (*models.Invite, error)
+	ListInvites() ([]models.Invite, error)
+	RedeemInvite(tokenHash, username, passwordHash string) (*models.User, error)
+	DeleteInvite(id int64) error
+
+	// Secret returns the named server secret, see storage.DB.Secret.
+	Secret(name string) ([]byte, error)
+}
+
+var _ Store = (*storage.DB)(nil)
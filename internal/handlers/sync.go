@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Outcomes of an uploaded change.
+const (
+	syncApplied  = "applied"
+	syncConflict = "conflict" // The server's copy changed meanwhile and was kept
+	syncRejected = "rejected" // See the result's error
+)
+
+// apiChange is an expense's latest entry in the change feed.
+type apiChange struct {
+	ID      int64           `json:"id"`
+	Version int64           `json:"version"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Expense *models.Expense `json:"expense,omitempty"`
+}
+
+// apiChangeFeed is a page of the change feed.
+type apiChangeFeed struct {
+	Changes []apiChange `json:"changes"`
+	// Passed as since to get the changes after these
+	Checkpoint int64 `json:"checkpoint"`
+	// More changes follow right away, rather than once something changes
+	More bool `json:"more"`
+}
+
+// apiUpload is a batch of changes a client made, e.g. while offline.
+type apiUpload struct {
+	Changes []apiUploadChange `json:"changes"`
+}
+
+// apiUploadChange creates an expense when ID is 0, and otherwise updates or
+// deletes the expense as the client last saw it, at BaseVersion.
+type apiUploadChange struct {
+	Ref         string          `json:"ref,omitempty"` // The client's name for the change, echoed in its result
+	ID          int64           `json:"id,omitempty"`
+	BaseVersion int64           `json:"base_version,omitempty"`
+	Deleted     bool            `json:"deleted,omitempty"`
+	Expense     *models.Expense `json:"expense,omitempty"` // Its ID and user are ignored, and its ledger when updating
+}
+
+// apiUploadResult is what became of an uploaded change. On a conflict it
+// holds the server's copy of the expense, or Deleted.
+type apiUploadResult struct {
+	Ref     string          `json:"ref,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Status  string          `json:"status"`
+	Error   *apiError       `json:"error,omitempty"` // Why it was rejected
+	Deleted bool            `json:"deleted,omitempty"`
+	Expense *models.Expense `json:"expense,omitempty"`
+}
+
+// APIChanges returns the expenses in the ledgers the user sees that were
+// added, changed, or deleted after the since checkpoint, oldest change
+// first, for clients that keep a copy, like an offline-first app. Each
+// expense appears once, as it is now, or as a tombstone once deleted.
+// Without since it returns every such expense. A client pages with the returned checkpoint while more
+// is true, then stores it to ask for what changed next time.
+func (h *Handlers) APIChanges(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	var invalid []fieldError
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+			invalid = append(invalid, fieldError{Field: "since", Message: "want a checkpoint"})
+		}
+	}
+	limit, invalidLimit := apiLimit(r, apiMaxPageSize)
+	if invalid = append(invalid, invalidLimit...); invalid != nil {
+		writeAPIError(w, validationFailed(invalid...))
+		return
+	}
+
+	changes, err := h.store(r).ExpenseChanges(since, limit+1)
+	if err != nil {
+		h.logger.Printf("APIChanges error: %v", err)
+		writeAPIError(w, err)
+		return
+	}
+	ledgers, err := h.store(r).ListLedgers(requestUserID(r))
+	if err != nil {
+		h.logger.Printf("APIChanges error: %v", err)
+		writeAPIError(w, err)
+		return
+	}
+	visible := map[int64]bool{}
+	for _, l := range ledgers {
+		visible[l.ID] = true
+	}
+	feed := apiChangeFeed{Changes: make([]apiChange, 0, min(len(changes), limit)), Checkpoint: since}
+	if len(changes) > limit {
+		changes, feed.More = changes[:limit], true
+	}
+	for _, c := range changes {
+		feed.Checkpoint = c.Version
+		// Expenses in other users' ledgers are left out
+		if c.Expense != nil && !visible[c.Expense.LedgerID] {
+			continue
+		}
+		feed.Changes = append(feed.Changes, apiChange{ID: c.ID, Version: c.Version, Deleted: c.Expense == nil, Expense: c.Expense})
+	}
+	writeJSON(w, feed)
+}
+
+// APIUploadChanges applies a batch of changes a client made to its copy of
+// the expenses, in order, and returns what became of each. Changes to an
+// expense that changed on the server after the client's base version are
+// conflicts: the server's copy wins and is returned, for the client to keep
+// or to change again. Uploading the same new expense twice, e.g. retrying
+// after a lost response, creates it once.
+func (h *Handlers) APIUploadChanges(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		writeAPIError(w, errNotSignedIn)
+		return
+	}
+	var upload apiUpload
+	if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+		writeAPIError(w, errInvalidJSON)
+		return
+	}
+	if len(upload.Changes) > apiMaxPageSize {
+		writeAPIError(w, validationFailed(fieldError{Field: "changes", Message: "upload at most " + strconv.Itoa(apiMaxPageSize) + " at a time"}))
+		return
+	}
+
+	results := make([]apiUploadResult, 0, len(upload.Changes))
+	for _, c := range upload.Changes {
+		result, err := h.applyChange(r, user, c)
+		if err != nil {
+			h.logger.Printf("APIUploadChanges error: %v", err)
+			writeAPIError(w, err)
+			return
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, map[string][]apiUploadResult{"results": results})
+}
+
+// applyChange applies an uploaded change. Only failures of the store are
+// returned as errors; the client's mistakes and conflicts are results.
+func (h *Handlers) applyChange(r *http.Request, user *models.User, c apiUploadChange) (apiUploadResult, error) {
+	result := apiUploadResult{Ref: c.Ref, ID: c.ID, Status: syncApplied}
+	db := h.store(r)
+	var e models.Expense
+	if !c.Deleted {
+		if invalid := validateUploadedExpense(c.Expense); invalid != nil {
+			result.Status, result.Error = syncRejected, validationFailed(invalid...)
+			return result, nil
+		}
+		e = *c.Expense
+		e.ID = c.ID
+		if e.Description == "" {
+			e.Description = e.Category
+		}
+		// Like the expense form, dates are the wall-clock time of the expense
+		e.Date = time.Date(e.Date.Year(), e.Date.Month(), e.Date.Day(), e.Date.Hour(), e.Date.Minute(), e.Date.Second(), 0, time.UTC)
+	}
+
+	// Expenses in other users' ledgers are as good as missing
+	if c.ID != 0 {
+		if _, err := h.visibleExpense(r, c.ID); errors.Is(err, errHiddenLedger) {
+			result.Status, result.Error = syncRejected, notFound("expense not found or archived")
+			return result, nil
+		}
+	}
+
+	var err error
+	switch {
+	case c.ID == 0 && c.Deleted:
+		result.Status, result.Error = syncRejected, validationFailed(fieldError{Field: "id", Message: "required to delete an expense"})
+		return result, nil
+	case c.ID == 0:
+		if e.LedgerID != 0 {
+			ledger, err := h.visibleLedger(r, e.LedgerID)
+			if errors.Is(err, sql.ErrNoRows) {
+				result.Status, result.Error = syncRejected, validationFailed(fieldError{Field: "expense.ledger_id", Message: "no such ledger"})
+				return result, nil
+			} else if err != nil {
+				return result, err
+			}
+			if ledger.ArchivedAt != nil {
+				result.Status, result.Error = syncRejected, validationFailed(fieldError{Field: "expense.ledger_id", Message: "the ledger is archived"})
+				return result, nil
+			}
+		}
+		e.UserID = &user.ID
+		err = db.InsertExpense(&e)
+		if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return h.existingExpense(r, result, e)
+		}
+		result.ID = e.ID
+	case c.Deleted:
+		err = db.DeleteExpenseIfUnchanged(c.ID, c.BaseVersion)
+	default:
+		err = db.UpdateExpenseIfUnchanged(&e, c.BaseVersion)
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrConflict):
+		result.Status = syncConflict
+		result.Expense, err = db.GetExpense(c.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Deleted, err = true, nil
+		}
+		return result, err
+	case errors.Is(err, sql.ErrNoRows):
+		result.Status, result.Error = syncRejected, notFound("expense not found or archived")
+		return result, nil
+	case err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		result.Status, result.Error = syncRejected, errDuplicate
+		return result, nil
+	case err != nil:
+		return result, err
+	}
+
+	switch {
+	case c.ID == 0:
+		h.publishExpense(r, events.ExpenseCreated, result.ID)
+	case c.Deleted:
+		h.publishExpense(r, events.ExpenseDeleted, result.ID)
+	default:
+		h.publishExpense(r, events.ExpenseUpdated, result.ID)
+	}
+	return result, nil
+}
+
+// existingExpense finishes the result of uploading a new expense that
+// exists already, which is how a retried upload looks, with its ID.
+func (h *Handlers) existingExpense(r *http.Request, result apiUploadResult, e models.Expense) (apiUploadResult, error) {
+	same, err := h.store(r).FilterExpenses(storage.ExpenseFilter{From: e.Date, To: e.Date.Add(time.Second), Query: e.Description, Viewer: *e.UserID})
+	if err != nil {
+		return result, err
+	}
+	for _, existing := range same {
+		if existing.Amount == e.Amount && existing.Description == e.Description {
+			result.ID = existing.ID
+			return result, nil
+		}
+	}
+	// Archived, where uploads can't reach it
+	result.Status, result.Error = syncRejected, errDuplicate
+	return result, nil
+}
+
+// validateUploadedExpense returns what's wrong with an uploaded expense, or
+// nil if nothing is.
+func validateUploadedExpense(e *models.Expense) []fieldError {
+	if e == nil {
+		return []fieldError{{Field: "expense", Message: "required"}}
+	}
+	var invalid []fieldError
+	if !(e.Amount > 0) || math.IsInf(e.Amount, 0) {
+		invalid = append(invalid, fieldError{Field: "expense.amount", Message: "must be greater than zero"})
+	}
+	if strings.TrimSpace(e.Category) == "" {
+		invalid = append(invalid, fieldError{Field: "expense.category", Message: "required"})
+	}
+	if e.Date.IsZero() {
+		invalid = append(invalid, fieldError{Field: "expense.date", Message: "required"})
+	}
+	return invalid
+}
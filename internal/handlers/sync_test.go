@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SyncTestSuite provides a test suite for the sync API
+type SyncTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	day      time.Time
+}
+
+// SetupTest runs before each test
+func (s *SyncTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithLogger(log.New(io.Discard, "", 0)))
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *SyncTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// feed requests a page of the change feed.
+func (s *SyncTestSuite) feed(target string) apiChangeFeed {
+	w := httptest.NewRecorder()
+	s.handlers.APIChanges(w, httptest.NewRequest("GET", target, http.NoBody))
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var feed apiChangeFeed
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &feed))
+	return feed
+}
+
+// upload sends changes as user 1 and returns the response.
+func (s *SyncTestSuite) upload(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/changes", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &models.User{ID: 1, Username: "alice"}))
+	w := httptest.NewRecorder()
+	s.handlers.APIUploadChanges(w, req)
+	return w
+}
+
+// results uploads changes and returns their results.
+func (s *SyncTestSuite) results(body string) []apiUploadResult {
+	w := s.upload(body)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct{ Results []apiUploadResult }
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Results
+}
+
+func (s *SyncTestSuite) TestChanges() {
+	for i, description := range []string{"A", "B", "C"} {
+		s.Require().NoError(s.db.CreateExpense(1, description, "Other", s.day.Add(time.Duration(i)*time.Hour), int64(i%2+1)))
+	}
+
+	feed := s.feed("/api/v1/changes?limit=2")
+	s.Require().Len(feed.Changes, 2)
+	s.Equal("A", feed.Changes[0].Expense.Description, "everyone's, oldest change first")
+	s.True(feed.More)
+	feed = s.feed(fmt.Sprintf("/api/v1/changes?since=%d&limit=2", feed.Checkpoint))
+	s.Require().Len(feed.Changes, 1)
+	s.Equal("C", feed.Changes[0].Expense.Description)
+	s.False(feed.More)
+
+	s.Require().NoError(s.db.DeleteExpense(feed.Changes[0].ID))
+	checkpoint := feed.Checkpoint
+	feed = s.feed(fmt.Sprintf("/api/v1/changes?since=%d", checkpoint))
+	s.Require().Len(feed.Changes, 1)
+	s.True(feed.Changes[0].Deleted)
+	s.Nil(feed.Changes[0].Expense)
+
+	feed = s.feed(fmt.Sprintf("/api/v1/changes?since=%d", feed.Checkpoint))
+	s.Empty(feed.Changes)
+	s.Greater(feed.Checkpoint, checkpoint, "kept when nothing changed")
+
+	for _, target := range []string{"/api/v1/changes?since=-1", "/api/v1/changes?since=x", "/api/v1/changes?limit=501"} {
+		w := httptest.NewRecorder()
+		s.handlers.APIChanges(w, httptest.NewRequest("GET", target, http.NoBody))
+		s.Equal(http.StatusBadRequest, w.Code, target)
+	}
+}
+
+func (s *SyncTestSuite) TestUpload() {
+	create := `{"changes": [{"ref": "new-1", "expense": {"amount": 4.5, "description": "Coffee", "category": "Eating Out", "date": "2026-03-02T08:30:00+01:00"}}]}`
+	results := s.results(create)
+	s.Require().Len(results, 1)
+	s.Equal(apiUploadResult{Ref: "new-1", ID: results[0].ID, Status: syncApplied}, results[0])
+	id := results[0].ID
+	s.Equal([]apiUploadResult{{Ref: "new-1", ID: id, Status: syncApplied}}, s.results(create), "a retry creates nothing")
+	stored, err := s.db.GetExpense(id)
+	s.Require().NoError(err)
+	s.Equal(time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), stored.Date.UTC(), "the wall-clock time")
+	s.Equal(int64(1), *stored.UserID)
+
+	base := s.feed("/api/v1/changes").Checkpoint
+	results = s.results(fmt.Sprintf(`{"changes": [
+		{"id": %[1]d, "base_version": %[2]d, "expense": {"amount": 5, "description": "Latte", "category": "Eating Out", "date": "2026-03-02T08:30:00Z"}},
+		{"id": %[1]d, "base_version": %[2]d, "expense": {"amount": 6, "description": "Mocha", "category": "Eating Out", "date": "2026-03-02T08:30:00Z"}},
+		{"id": %[1]d, "base_version": %[2]d, "deleted": true}
+	]}`, id, base))
+	s.Require().Len(results, 3)
+	s.Equal(syncApplied, results[0].Status)
+	s.Equal(syncConflict, results[1].Status, "changed after the base version")
+	s.Require().NotNil(results[1].Expense)
+	s.Equal("Latte", results[1].Expense.Description, "the server's copy")
+	s.Equal(syncConflict, results[2].Status)
+
+	base = s.feed("/api/v1/changes").Checkpoint
+	s.Equal(syncApplied, s.results(fmt.Sprintf(`{"changes": [{"id": %d, "base_version": %d, "deleted": true}]}`, id, base))[0].Status)
+	results = s.results(fmt.Sprintf(`{"changes": [{"id": %d, "base_version": %d, "expense": {"amount": 1, "category": "Other", "date": "2026-03-02T08:30:00Z"}}]}`, id, base))
+	s.Equal(apiUploadResult{ID: id, Status: syncConflict, Deleted: true}, results[0])
+	_, err = s.db.GetExpense(id)
+	s.Error(err, "stays deleted")
+}
+
+func (s *SyncTestSuite) TestUploadRejected() {
+	results := s.results(`{"changes": [
+		{"ref": "a", "expense": {"amount": 0, "category": "Other", "date": "2026-03-02T08:30:00Z"}},
+		{"ref": "b", "expense": {"amount": 1, "category": "", "date": "2026-03-02T08:30:00Z"}},
+		{"ref": "c", "expense": {"amount": 1, "category": "Other"}},
+		{"ref": "d", "deleted": true},
+		{"ref": "e", "id": 404, "base_version": 1, "deleted": true}
+	]}`)
+	s.Require().Len(results, 5)
+	for i, code := range []string{codeValidationFailed, codeValidationFailed, codeValidationFailed, codeValidationFailed, codeNotFound} {
+		s.Equal(syncRejected, results[i].Status, results[i].Ref)
+		s.Require().NotNil(results[i].Error, results[i].Ref)
+		s.Equal(code, results[i].Error.Code, results[i].Ref)
+	}
+	s.Equal([]fieldError{{Field: "expense.amount", Message: "must be greater than zero"}}, results[0].Error.Details)
+	s.Equal([]fieldError{{Field: "id", Message: "required to delete an expense"}}, results[3].Error.Details)
+
+	s.Equal(http.StatusBadRequest, s.upload(`{"changes": `).Code)
+	s.Equal(http.StatusBadRequest, s.upload(`{"changes": [`+strings.Repeat(`{},`, apiMaxPageSize)+`{}]}`).Code)
+}
+
+// TestSyncTestSuite runs the sync API test suite
+func TestSyncTestSuite(t *testing.T) {
+	suite.Run(t, new(SyncTestSuite))
+}
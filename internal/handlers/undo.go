@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/models"
+)
+
+// UndoWindow is how long a deletion can be undone from the expense list.
+// Deleted expenses must be kept at least this long.
+const UndoWindow = 30 * time.Second
+
+// undoDeletePurpose scopes signed tokens to undoing a deletion.
+const undoDeletePurpose = "undo-delete"
+
+// undoLocation returns the HX-Location that shows the list after a user
+// deleted expense id, offering to undo it.
+func (h *Handlers) undoLocation(userID, id int64) string {
+	token := auth.SignToken(h.secretKey, undoDeletePurpose,
+		strconv.FormatInt(userID, 10)+"|"+strconv.FormatInt(id, 10), h.now().Add(UndoWindow))
+	// htmx sends the values as query parameters but keeps them out of the
+	// address bar, so reloading the page doesn't offer to undo again
+	location, _ := json.Marshal(map[string]any{
+		"path":   "/expenses",
+		"target": "#content",
+		"values": map[string]string{"undo": token},
+	})
+	return string(location)
+}
+
+// parseUndoToken returns the expense an undo token from undoLocation
+// restores, if the token is valid and was issued to the user.
+func (h *Handlers) parseUndoToken(token string, userID int64) (int64, error) {
+	payload, err := auth.VerifyToken(h.secretKey, undoDeletePurpose, token, h.now())
+	if err != nil {
+		return 0, err
+	}
+	user, id, ok := strings.Cut(payload, "|")
+	if !ok || user != strconv.FormatInt(userID, 10) {
+		return 0, errors.New("undo token of another user")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
+
+// UndoDeleteExpense restores the expense deleted with the undo token posted
+// from the list's toast, and shows the list again. When it's too late, the
+// toast says so instead.
+func (h *Handlers) UndoDeleteExpense(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form submission", http.StatusBadRequest)
+		return
+	}
+
+	tooLate := ListViewModel{UndoError: "It's too late to undo this"}
+	id, err := h.parseUndoToken(r.FormValue("token"), user.ID)
+	if err != nil {
+		h.renderPartial(w, r, "list.html", "undo-toast", tooLate)
+		return
+	}
+	switch err := h.store(r).RestoreExpense(id); {
+	case err == nil:
+	case errors.Is(err, sql.ErrNoRows):
+		h.renderPartial(w, r, "list.html", "undo-toast", tooLate)
+		return
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		h.renderPartial(w, r, "list.html", "undo-toast", ListViewModel{UndoError: "The same expense was added again meanwhile"})
+		return
+	default:
+		h.logger.Printf("UndoDeleteExpense error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.publishExpense(r, events.ExpenseCreated, id)
+	w.Header().Set("HX-Location", `{"path":"/expenses", "target":"#content"}`)
+}
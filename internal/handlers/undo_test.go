@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// UndoTestSuite provides a test suite for undoing deletions
+type UndoTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	now      time.Time
+	id       int64
+}
+
+// SetupTest runs before each test
+func (s *UndoTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Now()
+	s.handlers = NewHandlers(db, WithTemplateFS(os.DirFS("../../web/templates")),
+		WithClock(func() time.Time { return s.now }), WithLogger(log.New(io.Discard, "", 0)))
+	s.Require().NoError(db.CreateExpense(12.5, "Lunch", "Eating Out", s.now, 1))
+	expenses, err := db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.id = expenses[0].ID
+}
+
+// TearDownTest runs after each test
+func (s *UndoTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// deleteExpense deletes the expense as user 1 and returns the undo token.
+func (s *UndoTestSuite) deleteExpense() string {
+	req := asUser(httptest.NewRequest("DELETE", "/expenses/1", http.NoBody), 1)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	s.handlers.DeleteExpense(w, req)
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var location struct {
+		Path   string
+		Target string
+		Values map[string]string
+	}
+	s.Require().NoError(json.Unmarshal([]byte(w.Header().Get("HX-Location")), &location))
+	s.Equal("/expenses", location.Path)
+	s.Equal("#content", location.Target)
+	s.Require().NotEmpty(location.Values["undo"])
+	return location.Values["undo"]
+}
+
+// undo posts an undo token as user.
+func (s *UndoTestSuite) undo(token string, user int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/expenses/undo", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.handlers.UndoDeleteExpense(w, asUser(req, user))
+	return w
+}
+
+// list renders the expense list as user with an undo token.
+func (s *UndoTestSuite) list(token string, user int64) string {
+	w := httptest.NewRecorder()
+	s.handlers.ListExpenses(w, asUser(httptest.NewRequest("GET", "/expenses?undo="+url.QueryEscape(token), http.NoBody), user))
+	s.Require().Equal(http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func (s *UndoTestSuite) TestUndo() {
+	token := s.deleteExpense()
+	s.Contains(s.list(token, 1), `hx-post="/expenses/undo"`)
+	s.NotContains(s.list(token, 2), `hx-post="/expenses/undo"`, "only for who deleted it")
+
+	w := s.undo(token, 2)
+	s.Contains(w.Body.String(), "too late")
+	w = s.undo(token, 1)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal(`{"path":"/expenses", "target":"#content"}`, w.Header().Get("HX-Location"))
+	restored, err := s.db.GetExpense(s.id)
+	s.Require().NoError(err)
+	s.Equal("Lunch", restored.Description)
+
+	w = s.undo(token, 1)
+	s.Empty(w.Header().Get("HX-Location"))
+	s.Contains(w.Body.String(), "too late", "restored already")
+}
+
+func (s *UndoTestSuite) TestExpired() {
+	token := s.deleteExpense()
+	s.now = s.now.Add(UndoWindow + time.Second)
+	s.NotContains(s.list(token, 1), `hx-post="/expenses/undo"`)
+	s.Contains(s.undo(token, 1).Body.String(), "too late")
+	_, err := s.db.GetExpense(s.id)
+	s.Error(err, "stays deleted")
+
+	s.Contains(s.undo("forged", 1).Body.String(), "too late")
+}
+
+func (s *UndoTestSuite) TestAddedAgain() {
+	token := s.deleteExpense()
+	s.Require().NoError(s.db.CreateExpense(12.5, "Lunch", "Eating Out", s.now, 1))
+	s.Contains(s.undo(token, 1).Body.String(), "added again")
+}
+
+// TestUndoTestSuite runs the undo test suite
+func TestUndoTestSuite(t *testing.T) {
+	suite.Run(t, new(UndoTestSuite))
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/models"
+)
+
+// websocketGUID is appended to the client's key to accept a WebSocket
+// handshake (RFC 6455, section 1.3).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	// wsPingInterval is how often the server pings, which keeps proxies
+	// from closing an idle connection and finds clients that went away.
+	wsPingInterval = 30 * time.Second
+	// wsReadTimeout is how long a client may stay silent, pongs included,
+	// before it's taken for gone.
+	wsReadTimeout = 2 * wsPingInterval
+	// wsWriteTimeout limits writing a frame to a slow client.
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxFrame is the largest frame, and message, read from a client,
+	// which has nothing to send but control frames.
+	wsMaxFrame = 4096
+)
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes.
+const (
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001 // The server shuts down
+	wsCloseProtocol  = 1002
+	wsCloseTooBig    = 1009
+	wsCloseTryAgain  = 1013 // Dropped for falling behind, reconnect and reload
+)
+
+// wsCloseError ends a WebSocket connection with a close frame.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with %d %s", e.code, e.reason)
+}
+
+// LiveUpdates upgrades the request to a WebSocket and sends the changes to
+// expenses in the ledgers the user sees over it as they happen, as JSON
+// events.Event messages, so pages open on other devices can update without
+// reloading. Clients only need to send pongs and close frames; messages
+// they send are ignored, but must keep to the protocol.
+func (h *Handlers) LiveUpdates(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		http.Error(w, "Invalid WebSocket key", http.StatusBadRequest)
+		return
+	}
+	if !h.sameOrigin(r) {
+		h.logger.Printf("Rejected cross-origin WebSocket from %q", r.Header.Get("Origin"))
+		http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+		return
+	}
+
+	sub, err := h.live.Subscribe(user.ID)
+	switch {
+	case errors.Is(err, events.ErrTooManySubscriptions):
+		http.Error(w, "Too many live connections", http.StatusTooManyRequests)
+		return
+	case err != nil:
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Cancel()
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		h.logger.Printf("LiveUpdates error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	// The connection outlives the server's read and write timeouts
+	_ = conn.SetDeadline(time.Time{})
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	ws := &wsConn{conn: conn, r: rw.Reader}
+	pings := make(chan []byte, 1)
+	done := make(chan error, 1)
+	go ws.readLoop(pings, done)
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				if errors.Is(sub.Err(), events.ErrTooSlow) {
+					_ = ws.close(wsCloseTryAgain, "too slow")
+				} else {
+					_ = ws.close(wsCloseGoingAway, "shutting down")
+				}
+				return
+			}
+			// Changes in other users' ledgers aren't theirs to see
+			if e.Expense != nil {
+				if _, err := h.visibleLedger(r, e.Expense.LedgerID); err != nil {
+					continue
+				}
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				h.logger.Printf("LiveUpdates error: %v", err)
+				continue
+			}
+			if err := ws.write(wsText, data); err != nil {
+				return
+			}
+		case payload := <-pings:
+			if err := ws.write(wsPong, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := ws.write(wsPing, nil); err != nil {
+				return
+			}
+		case err := <-done:
+			var closeErr *wsCloseError
+			if errors.As(err, &closeErr) {
+				_ = ws.close(closeErr.code, closeErr.reason)
+			}
+			return
+		}
+	}
+}
+
+// CloseLiveUpdates ends the live update connections, which a graceful
+// shutdown would otherwise wait for. Register it with
+// http.Server.RegisterOnShutdown.
+func (h *Handlers) CloseLiveUpdates() {
+	h.live.Close()
+}
+
+// publishExpense tells the clients following live updates that expense id
+// changed, sending created and updated expenses as they are now.
+func (h *Handlers) publishExpense(r *http.Request, eventType string, id int64) {
+	e := events.Event{Type: eventType, ID: id}
+	if user, ok := r.Context().Value(UserContextKey).(*models.User); ok {
+		e.UserID = user.ID
+	}
+	if eventType != events.ExpenseDeleted {
+		expense, err := h.store(r).GetExpense(id)
+		if err != nil {
+			h.logger.Printf("Failed to publish change to expense %d: %v", id, err)
+			return
+		}
+		e.Expense = expense
+	}
+	h.live.Publish(e)
+}
+
+// sameOrigin reports whether r comes from a page of the app, or from no page
+// at all. Browsers send WebSocket handshakes from other sites with cookies
+// and the CSRF check lets GET requests through, so they must be checked here.
+func (h *Handlers) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == h.passkeyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, ignoring case.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for part := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is the server end of a WebSocket connection. One goroutine reads
+// from it and another writes.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// readLoop reads the client's frames, passing on pings to answer, until the
+// connection fails or the client closes it. Then it sends why to done.
+// Messages are discarded, but their fragments must come in order and add up
+// to at most wsMaxFrame bytes.
+func (c *wsConn) readLoop(pings chan []byte, done chan<- error) {
+	message := -1 // Bytes of the fragmented message being read, -1 between messages
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		final, opcode, payload, err := c.readFrame()
+		if err != nil {
+			done <- err
+			return
+		}
+		switch opcode {
+		case wsContinuation, wsText, wsBinary:
+			switch {
+			case opcode == wsContinuation && message < 0:
+				done <- &wsCloseError{wsCloseProtocol, "continuation without a message"}
+				return
+			case opcode != wsContinuation && message >= 0:
+				done <- &wsCloseError{wsCloseProtocol, "message not finished"}
+				return
+			}
+			message = max(message, 0) + len(payload)
+			if message > wsMaxFrame {
+				done <- &wsCloseError{wsCloseTooBig, "message too big"}
+				return
+			}
+			if final {
+				message = -1
+			}
+		case wsPing:
+			// Only the latest ping needs an answer
+			select {
+			case <-pings:
+			default:
+			}
+			pings <- payload
+		case wsClose:
+			code := wsCloseNormal
+			switch {
+			case len(payload) == 1:
+				code = wsCloseProtocol
+			case len(payload) >= 2:
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			done <- &wsCloseError{code: code}
+			return
+		case wsPong:
+		default:
+			done <- &wsCloseError{wsCloseProtocol, "unknown opcode"}
+			return
+		}
+	}
+}
+
+// readFrame reads a frame from the client and unmasks its payload. final is
+// false for all but the last fragment of a message.
+func (c *wsConn) readFrame() (final bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	final, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, &wsCloseError{wsCloseProtocol, "reserved bits set"}
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, &wsCloseError{wsCloseProtocol, "frame not masked"}
+	}
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (size > 125 || !final) {
+		return false, 0, nil, &wsCloseError{wsCloseProtocol, "invalid control frame"}
+	}
+	if size > wsMaxFrame {
+		return false, 0, nil, &wsCloseError{wsCloseTooBig, "frame too big"}
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return final, opcode, payload, nil
+}
+
+// write sends payload as a single unmasked frame.
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|opcode) // Final fragment
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// close sends a close frame with code and reason.
+func (c *wsConn) close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.write(wsClose, append(payload, reason...))
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/events"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LiveUpdatesTestSuite provides a test suite for the live update WebSocket
+type LiveUpdatesTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	handlers *Handlers
+	server   *httptest.Server
+}
+
+// SetupTest runs before each test
+func (s *LiveUpdatesTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.handlers = NewHandlers(db, WithLogger(log.New(io.Discard, "", 0)))
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handlers.LiveUpdates(w, asUser(r, 1))
+	}))
+}
+
+// TearDownTest runs after each test
+func (s *LiveUpdatesTestSuite) TearDownTest() {
+	s.server.Close()
+	s.db.Close()
+}
+
+// asUser returns r as made by user id.
+func asUser(r *http.Request, id int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), UserContextKey, &models.User{ID: id, Username: "alice"}))
+}
+
+// wsClient is the client end of a WebSocket connection.
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// handshake sends a WebSocket handshake with header to the test server and
+// returns the response and, if it was accepted, the connection.
+func (s *LiveUpdatesTestSuite) handshake(header http.Header) (*http.Response, *wsClient) {
+	conn, err := net.Dial("tcp", s.server.Listener.Addr().String())
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := httptest.NewRequest("GET", s.server.URL+"/ws", http.NoBody)
+	req.Header = http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.RequestURI = ""
+	s.Require().NoError(req.Write(conn))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	s.Require().NoError(err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return resp, nil
+	}
+	return resp, &wsClient{conn: conn, r: r}
+}
+
+// connect opens a WebSocket to the test server.
+func (s *LiveUpdatesTestSuite) connect() *wsClient {
+	resp, c := s.handshake(nil)
+	s.Require().Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	s.Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"), "the example of RFC 6455")
+	return c
+}
+
+// send writes a masked frame.
+func (c *wsClient) send(header byte, payload []byte) error {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{header}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// receive reads a frame from the server, skipping its pings.
+func (c *wsClient) receive() (opcode byte, payload []byte, err error) {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.r, head[:]); err != nil {
+			return 0, nil, err
+		}
+		if head[1]&0x80 != 0 {
+			return 0, nil, fmt.Errorf("masked server frame")
+		}
+		size := uint64(head[1] & 0x7f)
+		if size == 126 {
+			var ext [2]byte
+			if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			size = uint64(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload = make([]byte, size)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return 0, nil, err
+		}
+		if opcode = head[0] & 0x0f; opcode != wsPing {
+			return opcode, payload, nil
+		}
+	}
+}
+
+// event reads the next event.
+func (s *LiveUpdatesTestSuite) event(c *wsClient) events.Event {
+	opcode, payload, err := c.receive()
+	s.Require().NoError(err)
+	s.Require().Equal(byte(wsText), opcode)
+	var e events.Event
+	s.Require().NoError(json.Unmarshal(payload, &e))
+	return e
+}
+
+// closeCode reads a close frame and returns its code.
+func (s *LiveUpdatesTestSuite) closeCode(c *wsClient) int {
+	opcode, payload, err := c.receive()
+	s.Require().NoError(err)
+	s.Require().Equal(byte(wsClose), opcode)
+	s.Require().GreaterOrEqual(len(payload), 2)
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func (s *LiveUpdatesTestSuite) TestEvents() {
+	c := s.connect()
+
+	form := url.Values{"amount": {"4.50"}, "description": {"Coffee"}, "category": {"Eating Out"}, "date": {"2026-03-02T08:30:00"}}
+	req := httptest.NewRequest("POST", "/expenses", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.handlers.CreateExpense(w, asUser(req, 2))
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	e := s.event(c)
+	s.Equal(events.ExpenseCreated, e.Type, "other users' changes too")
+	s.Equal(int64(2), e.UserID)
+	s.Require().NotNil(e.Expense)
+	s.Equal(e.ID, e.Expense.ID)
+	s.Equal("Coffee", e.Expense.Description)
+
+	req = httptest.NewRequest("DELETE", "/expenses/{id}", http.NoBody)
+	req.SetPathValue("id", fmt.Sprint(e.ID))
+	s.handlers.DeleteExpense(httptest.NewRecorder(), asUser(req, 1))
+	deleted := s.event(c)
+	s.Equal(events.Event{Type: events.ExpenseDeleted, ID: e.ID, UserID: 1}, deleted)
+}
+
+func (s *LiveUpdatesTestSuite) TestPingAndClose() {
+	c := s.connect()
+	s.Require().NoError(c.send(0x80|wsPing, []byte("hello")))
+	opcode, payload, err := c.receive()
+	s.Require().NoError(err)
+	s.Equal(byte(wsPong), opcode)
+	s.Equal("hello", string(payload))
+
+	s.Require().NoError(c.send(0x80|wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal)))
+	s.Equal(wsCloseNormal, s.closeCode(c), "echoed")
+	s.Eventually(func() bool { return s.handlers.live.Subscribers(1) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *LiveUpdatesTestSuite) TestProtocolErrors() {
+	c := s.connect()
+	_, err := c.conn.Write([]byte{0x80 | wsText, 2, 'h', 'i'})
+	s.Require().NoError(err)
+	s.Equal(wsCloseProtocol, s.closeCode(c), "unmasked")
+
+	c = s.connect()
+	_, err = c.conn.Write([]byte{0x80 | wsPing, 0})
+	s.Require().NoError(err)
+	s.Equal(wsCloseProtocol, s.closeCode(c), "unmasked control frame")
+
+	c = s.connect()
+	_, err = c.conn.Write([]byte{0x80 | wsText, 0x80 | 127, 0, 0, 0, 0, 0, 1, 0, 0})
+	s.Require().NoError(err)
+	s.Equal(wsCloseTooBig, s.closeCode(c))
+
+	c = s.connect()
+	s.Require().NoError(c.send(0x80|wsBinary, make([]byte, wsMaxFrame+1)))
+	s.Equal(wsCloseTooBig, s.closeCode(c), "one byte over")
+
+	c = s.connect()
+	s.Require().NoError(c.send(0x80|wsPing, make([]byte, 126)))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "control frames carry up to 125 bytes")
+
+	c = s.connect()
+	s.Require().NoError(c.send(0x80|0x3, nil))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "reserved opcode")
+
+	c = s.connect()
+	s.Require().NoError(c.send(0x80|0x40|wsText, []byte("hi")))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "reserved bit")
+}
+
+func (s *LiveUpdatesTestSuite) TestFragments() {
+	// A message in fragments with a ping between them is read and ignored
+	c := s.connect()
+	s.Require().NoError(c.send(wsText, []byte("hel")))
+	s.Require().NoError(c.send(0x80|wsPing, []byte("between")))
+	opcode, payload, err := c.receive()
+	s.Require().NoError(err)
+	s.Equal(byte(wsPong), opcode)
+	s.Equal("between", string(payload))
+	s.Require().NoError(c.send(wsContinuation, []byte("l")))
+	s.Require().NoError(c.send(0x80|wsContinuation, []byte("o")))
+	s.Require().NoError(c.send(0x80|wsText, []byte("next")))
+	s.Require().NoError(c.send(0x80|wsPing, []byte("still open")))
+	opcode, payload, err = c.receive()
+	s.Require().NoError(err)
+	s.Equal(byte(wsPong), opcode)
+	s.Equal("still open", string(payload))
+
+	c = s.connect()
+	s.Require().NoError(c.send(0x80|wsContinuation, []byte("lo")))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "continuation without a message")
+
+	c = s.connect()
+	s.Require().NoError(c.send(wsText, []byte("hel")))
+	s.Require().NoError(c.send(0x80|wsText, []byte("lo")))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "message not finished")
+
+	c = s.connect()
+	s.Require().NoError(c.send(wsPing, []byte("hello")))
+	s.Equal(wsCloseProtocol, s.closeCode(c), "control frames can't be fragmented")
+
+	// Fragments within the frame limit can't add up to a larger message
+	c = s.connect()
+	s.Require().NoError(c.send(wsBinary, make([]byte, wsMaxFrame)))
+	s.Require().NoError(c.send(0x80|wsContinuation, []byte{0}))
+	s.Equal(wsCloseTooBig, s.closeCode(c))
+}
+
+func (s *LiveUpdatesTestSuite) TestShutdown() {
+	c := s.connect()
+	s.handlers.CloseLiveUpdates()
+	s.Equal(wsCloseGoingAway, s.closeCode(c))
+
+	resp, _ := s.handshake(nil)
+	s.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func (s *LiveUpdatesTestSuite) TestRejected() {
+	resp, _ := s.handshake(http.Header{"Upgrade": nil})
+	s.Equal(http.StatusUpgradeRequired, resp.StatusCode)
+	resp, _ = s.handshake(http.Header{"Sec-Websocket-Version": {"8"}})
+	s.Equal(http.StatusUpgradeRequired, resp.StatusCode)
+	resp, _ = s.handshake(http.Header{"Sec-Websocket-Key": {"short"}})
+	s.Equal(http.StatusBadRequest, resp.StatusCode)
+	resp, _ = s.handshake(http.Header{"Origin": {"https://evil.example"}})
+	s.Equal(http.StatusForbidden, resp.StatusCode)
+	resp, _ = s.handshake(http.Header{"Origin": {s.server.URL}})
+	s.Equal(http.StatusSwitchingProtocols, resp.StatusCode, "from the app's own pages")
+
+	for range events.MaxSubscriptions - 1 {
+		_, err := s.handlers.live.Subscribe(1)
+		s.Require().NoError(err)
+	}
+	resp, _ = s.handshake(nil)
+	s.Equal(http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func (s *LiveUpdatesTestSuite) TestListChanges() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handlers.ExpenseListChanges(w, asUser(r, 1))
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	lines := bufio.NewReader(resp.Body)
+	line, err := lines.ReadString('\n')
+	s.Require().NoError(err)
+	s.Equal(": connected\n", line)
+	_, _ = lines.ReadString('\n')
+
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", time.Now(), 2))
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	req := httptest.NewRequest("DELETE", "/expenses/{id}", http.NoBody)
+	req.SetPathValue("id", fmt.Sprint(expenses[0].ID))
+	s.handlers.DeleteExpense(httptest.NewRecorder(), asUser(req, 2))
+	event, err := lines.ReadString('\n')
+	s.Require().NoError(err)
+	data, err := lines.ReadString('\n')
+	s.Require().NoError(err)
+	s.Equal("event: list-changed\ndata: expense.deleted\n", event+data, "other users' changes too")
+
+	s.handlers.CloseLiveUpdates()
+	_, err = io.ReadAll(lines)
+	s.NoError(err, "ends on shutdown")
+}
+
+// TestLiveUpdatesTestSuite runs the live update WebSocket test suite
+func TestLiveUpdatesTestSuite(t *testing.T) {
+	suite.Run(t, new(LiveUpdatesTestSuite))
+}
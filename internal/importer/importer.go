@@ -0,0 +1,326 @@
+// Package importer reads expenses from CSV exports and OFX bank statements.
+// Parsing is separate from storing them, so the same files can be checked
+// before anything is written.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// Supported file formats.
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+)
+
+// defaultDateLayouts are tried in order when a CSV mapping has no DateLayout.
+var defaultDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// Mapping says how to turn rows into expenses.
+type Mapping struct {
+	// CSV header names of the columns, matched regardless of case. Only
+	// Category may be missing from the file.
+	Date, Amount, Description, Category string
+	// DateLayout parses the date column, in Go's time layout syntax. Empty
+	// tries ISO 8601 dates with and without a time.
+	DateLayout string
+	// DefaultCategory is used for rows without a known category.
+	DefaultCategory string
+	// Categories are the known categories, models.Categories when nil.
+	Categories []string
+	// NegativeDebits means spending is negative, as in bank statements.
+	// Otherwise spending is positive, as in the app's own files. Rows with
+	// the other sign are credits and skipped.
+	NegativeDebits bool
+	// SkipInvalid reports CSV rows with a date or amount that can't be read
+	// in Result.Invalid and goes on, instead of failing the whole file.
+	SkipInvalid bool
+}
+
+// DefaultMapping reads the columns the app's own CSV files use.
+func DefaultMapping() Mapping {
+	return Mapping{
+		Date:            "date",
+		Amount:          "amount",
+		Description:     "description",
+		Category:        "category",
+		DefaultCategory: "Other",
+	}
+}
+
+// Result is what a file contained.
+type Result struct {
+	Expenses []models.Expense
+	Credits  int        // Skipped incoming payments and refunds
+	Invalid  []RowError // Skipped rows, with Mapping.SkipInvalid
+}
+
+// RowError is a CSV row that couldn't be read.
+type RowError struct {
+	Line int // In the file, the header being line 1
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// columnNames are the header names GuessMapping recognizes for each column,
+// in lower case.
+var columnNames = struct{ date, amount, description, category []string }{
+	date:        []string{"date", "booking date", "transaction date", "day", "when"},
+	amount:      []string{"amount", "value", "sum", "price", "cost", "total"},
+	description: []string{"description", "payee", "memo", "details", "note", "name", "merchant"},
+	category:    []string{"category", "type", "tag"},
+}
+
+// Header reads the column names from the header row of a CSV file.
+func Header(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range header {
+		header[i] = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+	}
+	return header, nil
+}
+
+// GuessMapping returns DefaultMapping with the columns picked from header by
+// their names, e.g. "Payee" for the description. Columns it can't find are
+// left empty.
+func GuessMapping(header []string) Mapping {
+	m := DefaultMapping()
+	pick := func(names []string) string {
+		for _, name := range names {
+			for _, column := range header {
+				if strings.EqualFold(column, name) {
+					return column
+				}
+			}
+		}
+		return ""
+	}
+	m.Date = pick(columnNames.date)
+	m.Amount = pick(columnNames.amount)
+	m.Description = pick(columnNames.description)
+	m.Category = pick(columnNames.category)
+	return m
+}
+
+// DetectFormat returns the format of a file from its extension.
+func DetectFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ofx", ".qfx":
+		return FormatOFX, nil
+	default:
+		return "", fmt.Errorf("can't tell the format of %q, use csv or ofx", path)
+	}
+}
+
+// Parse reads expenses from r in the given format.
+func Parse(r io.Reader, format string, m Mapping) (Result, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r, m)
+	case FormatOFX:
+		return ParseOFX(r, m)
+	default:
+		return Result{}, fmt.Errorf("unknown format %q, use csv or ofx", format)
+	}
+}
+
+// ParseCSV reads expenses from a CSV file with a header row.
+func ParseCSV(r io.Reader, m Mapping) (Result, error) {
+	var result Result
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return result, errors.New("the file is empty")
+		}
+		return result, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheets like to start files with a byte order mark
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+		columns[strings.ToLower(name)] = i
+	}
+	column := func(name string, required bool) (int, error) {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok && required {
+			return 0, fmt.Errorf("no %q column in the header", name)
+		}
+		if !ok {
+			return -1, nil
+		}
+		return i, nil
+	}
+	dateCol, err := column(m.Date, true)
+	if err != nil {
+		return result, err
+	}
+	amountCol, err := column(m.Amount, true)
+	if err != nil {
+		return result, err
+	}
+	descCol, err := column(m.Description, true)
+	if err != nil {
+		return result, err
+	}
+	catCol, _ := column(m.Category, false)
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		date, amount, err := parseRow(field(dateCol), field(amountCol), m.DateLayout)
+		if err != nil {
+			if !m.SkipInvalid {
+				return result, RowError{Line: line, Err: err}
+			}
+			result.Invalid = append(result.Invalid, RowError{Line: line, Err: err})
+			continue
+		}
+		expense, credit := m.expense(date, amount, field(descCol), field(catCol))
+		if credit {
+			result.Credits++
+			continue
+		}
+		result.Expenses = append(result.Expenses, expense)
+	}
+	return result, nil
+}
+
+// expense builds an expense from a parsed row, or reports that the row is a
+// credit to skip.
+func (m Mapping) expense(date time.Time, amount float64, description, category string) (models.Expense, bool) {
+	if m.NegativeDebits {
+		amount = -amount
+	}
+	if amount <= 0 {
+		return models.Expense{}, true
+	}
+	categories := m.Categories
+	if categories == nil {
+		categories = models.Categories
+	}
+	name, ok := MatchCategory(category, categories)
+	if !ok {
+		name = m.DefaultCategory
+	}
+	if description == "" {
+		description = name
+	}
+	return models.Expense{Amount: amount, Description: description, Category: name, Date: date}, false
+}
+
+// MatchCategory returns the category matching name regardless of case.
+func MatchCategory(name string, categories []string) (string, bool) {
+	for _, c := range categories {
+		if strings.EqualFold(c, strings.TrimSpace(name)) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// parseRow parses the date and amount of a row.
+func parseRow(date, amount, layout string) (time.Time, float64, error) {
+	d, err := parseDate(date, layout)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	a, err := parseAmount(amount)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return d, a, nil
+}
+
+// parseDate parses a date with layout, or the default layouts if it's empty.
+func parseDate(value, layout string) (time.Time, error) {
+	layouts := defaultDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if date, err := time.Parse(l, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// parseAmount parses an amount written with either a decimal point or a
+// decimal comma, ignoring currency symbols and thousands separators.
+func parseAmount(value string) (float64, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '-' || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, value)
+	switch {
+	case strings.Contains(cleaned, ".") && strings.Contains(cleaned, ","):
+		// Whichever comes last is the decimal separator
+		if strings.LastIndex(cleaned, ",") > strings.LastIndex(cleaned, ".") {
+			cleaned = strings.ReplaceAll(cleaned, ".", "")
+			cleaned = strings.Replace(cleaned, ",", ".", 1)
+		} else {
+			cleaned = strings.ReplaceAll(cleaned, ",", "")
+		}
+	case strings.Contains(cleaned, ","):
+		cleaned = strings.Replace(cleaned, ",", ".", 1)
+	}
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || cleaned == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ImporterTestSuite provides a test suite for reading CSV files
+type ImporterTestSuite struct {
+	suite.Suite
+}
+
+func (s *ImporterTestSuite) TestParseCSV_DefaultMapping() {
+	file := "\ufeffDate,Amount,Description,Category\n" +
+		"2026-03-02T12:30:00,4.50,Coffee,eating out\n" +
+		"2026-03-03,\"1,200.00\",Rent,Housing\n" +
+		"\n" +
+		"2026-03-04,12,,Snacks\n" +
+		"2026-03-05,-20,Refund,Other\n"
+
+	result, err := ParseCSV(strings.NewReader(file), DefaultMapping())
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 3)
+	s.Equal(1, result.Credits, "refunds are skipped")
+
+	s.Equal("Coffee", result.Expenses[0].Description)
+	s.Equal("Eating Out", result.Expenses[0].Category)
+	s.Equal(time.Date(2026, 3, 2, 12, 30, 0, 0, time.UTC), result.Expenses[0].Date)
+	s.InDelta(1200, result.Expenses[1].Amount, 0.001)
+	s.Equal("Other", result.Expenses[2].Category, "unknown categories fall back to the default")
+	s.Equal("Other", result.Expenses[2].Description, "the description defaults to the category")
+}
+
+func (s *ImporterTestSuite) TestParseCSV_BankMapping() {
+	file := "Booking date,Value,Payee\n" +
+		"02.03.2026,\"-4,50 €\",Café\n" +
+		"03.03.2026,\"2.500,00 €\",Salary\n"
+
+	m := DefaultMapping()
+	m.Date, m.Amount, m.Description = "booking date", "value", "payee"
+	m.DateLayout = "02.01.2006"
+	m.DefaultCategory = "Eating Out"
+	m.NegativeDebits = true
+	result, err := ParseCSV(strings.NewReader(file), m)
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 1)
+	s.Equal(1, result.Credits)
+	s.InDelta(4.5, result.Expenses[0].Amount, 0.001)
+	s.Equal("Café", result.Expenses[0].Description)
+	s.Equal("Eating Out", result.Expenses[0].Category)
+}
+
+func (s *ImporterTestSuite) TestParseCSV_Errors() {
+	_, err := ParseCSV(strings.NewReader(""), DefaultMapping())
+	s.ErrorContains(err, "empty")
+
+	_, err = ParseCSV(strings.NewReader("date,description\n"), DefaultMapping())
+	s.ErrorContains(err, `no "amount" column`)
+
+	_, err = ParseCSV(strings.NewReader("date,amount,description\n2026-03-02,ten,Coffee\n"), DefaultMapping())
+	s.ErrorContains(err, "line 2: invalid amount")
+
+	_, err = ParseCSV(strings.NewReader("date,amount,description\n03/02/2026,10,Coffee\n"), DefaultMapping())
+	s.ErrorContains(err, "line 2: invalid date")
+}
+
+func (s *ImporterTestSuite) TestParseCSV_SkipInvalid() {
+	file := "date,amount,description\n" +
+		"2026-03-02,4.50,Coffee\n" +
+		"2026-03-03,ten,Lunch\n" +
+		"03/04/2026,10,Taxi\n" +
+		"2026-03-05,8,Cinema\n"
+
+	m := DefaultMapping()
+	m.SkipInvalid = true
+	result, err := ParseCSV(strings.NewReader(file), m)
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 2)
+	s.Equal("Cinema", result.Expenses[1].Description)
+	s.Require().Len(result.Invalid, 2)
+	s.Equal(3, result.Invalid[0].Line)
+	s.EqualError(result.Invalid[0], `line 3: invalid amount "ten"`)
+	s.Equal(4, result.Invalid[1].Line)
+	s.ErrorContains(result.Invalid[1], "invalid date")
+}
+
+func (s *ImporterTestSuite) TestHeader() {
+	header, err := Header(strings.NewReader("\ufeffBooking date, Value,Payee,Notes\n02.03.2026,-4,Café,\n"))
+	s.Require().NoError(err)
+	s.Equal([]string{"Booking date", "Value", "Payee", "Notes"}, header)
+
+	m := GuessMapping(header)
+	s.Equal("Booking date", m.Date)
+	s.Equal("Value", m.Amount)
+	s.Equal("Payee", m.Description)
+	s.Empty(m.Category, "no such column")
+	s.Equal("Other", m.DefaultCategory)
+
+	_, err = Header(strings.NewReader(""))
+	s.ErrorContains(err, "empty")
+}
+
+func (s *ImporterTestSuite) TestDetectFormat() {
+	format, err := DetectFormat("statement.QFX")
+	s.Require().NoError(err)
+	s.Equal(FormatOFX, format)
+	format, err = DetectFormat("/tmp/expenses.csv")
+	s.Require().NoError(err)
+	s.Equal(FormatCSV, format)
+	_, err = DetectFormat("statement.pdf")
+	s.Error(err)
+}
+
+// TestImporterSuite runs the importer test suite
+func TestImporterSuite(t *testing.T) {
+	suite.Run(t, new(ImporterTestSuite))
+}
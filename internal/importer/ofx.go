@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ofxTransaction matches one transaction of an OFX statement. OFX 1 files
+// are SGML, where leaf elements aren't closed, so the fields are read with
+// ofxField instead of an XML decoder.
+var ofxTransaction = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+
+// ofxFields match the transaction fields ParseOFX reads.
+var ofxFields = map[string]*regexp.Regexp{}
+
+func init() {
+	for _, name := range []string{"DTPOSTED", "TRNAMT", "NAME", "MEMO"} {
+		ofxFields[name] = regexp.MustCompile(`(?i)<` + name + `>([^<\r\n]*)`)
+	}
+}
+
+// ParseOFX reads the transactions of an OFX or QFX bank statement. Debits
+// are negative in OFX, so the mapping's NegativeDebits is always assumed and
+// credits are skipped. Statements have no categories, so every expense gets
+// the mapping's DefaultCategory.
+func ParseOFX(r io.Reader, m Mapping) (Result, error) {
+	var result Result
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return result, err
+	}
+	m.NegativeDebits = true
+
+	transactions := ofxTransaction.FindAllStringSubmatch(string(data), -1)
+	if len(transactions) == 0 && !strings.Contains(strings.ToUpper(string(data)), "<OFX>") {
+		return result, fmt.Errorf("not an OFX file")
+	}
+	for i, t := range transactions {
+		posted := ofxField(t[1], "DTPOSTED")
+		// YYYYMMDD, optionally followed by a time and time zone; the day is
+		// what matters for duplicate detection
+		if len(posted) < 8 {
+			return result, fmt.Errorf("transaction %d: invalid date %q", i+1, posted)
+		}
+		date, err := time.Parse("20060102", posted[:8])
+		if err != nil {
+			return result, fmt.Errorf("transaction %d: invalid date %q", i+1, posted)
+		}
+		amount, err := parseAmount(ofxField(t[1], "TRNAMT"))
+		if err != nil {
+			return result, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+		description := ofxField(t[1], "NAME")
+		if description == "" {
+			description = ofxField(t[1], "MEMO")
+		}
+
+		expense, credit := m.expense(date, amount, description, "")
+		if credit {
+			result.Credits++
+			continue
+		}
+		result.Expenses = append(result.Expenses, expense)
+	}
+	return result, nil
+}
+
+// ofxField returns the value of a leaf element in an OFX fragment.
+func ofxField(fragment, name string) string {
+	match := ofxFields[name].FindStringSubmatch(fragment)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(match[1]))
+}
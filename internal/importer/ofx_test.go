@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// OFXTestSuite provides a test suite for reading bank statements
+type OFXTestSuite struct {
+	suite.Suite
+}
+
+// sgmlStatement is an OFX 1 statement, whose leaf elements aren't closed.
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20260302120000.000[-5:EST]
+<TRNAMT>-12.34
+<FITID>1
+<NAME>Corner Shop &amp; Deli
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20260303
+<TRNAMT>2500.00
+<FITID>2
+<NAME>Salary
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20260304
+<TRNAMT>-3.00
+<FITID>3
+<MEMO>Parking meter
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func (s *OFXTestSuite) TestParseSGML() {
+	result, err := ParseOFX(strings.NewReader(sgmlStatement), DefaultMapping())
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 2)
+	s.Equal(1, result.Credits)
+
+	s.Equal("Corner Shop & Deli", result.Expenses[0].Description)
+	s.InDelta(12.34, result.Expenses[0].Amount, 0.001)
+	s.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), result.Expenses[0].Date)
+	s.Equal("Other", result.Expenses[0].Category)
+	s.Equal("Parking meter", result.Expenses[1].Description, "the memo stands in for a missing name")
+}
+
+func (s *OFXTestSuite) TestParseXML() {
+	statement := `<?xml version="1.0"?><OFX><BANKTRANLIST>` +
+		`<STMTTRN><DTPOSTED>20260302</DTPOSTED><TRNAMT>-5.00</TRNAMT><NAME>Bakery</NAME></STMTTRN>` +
+		`</BANKTRANLIST></OFX>`
+	result, err := Parse(strings.NewReader(statement), FormatOFX, DefaultMapping())
+	s.Require().NoError(err)
+	s.Require().Len(result.Expenses, 1)
+	s.Equal("Bakery", result.Expenses[0].Description)
+}
+
+func (s *OFXTestSuite) TestParseInvalid() {
+	_, err := ParseOFX(strings.NewReader("date,amount\n"), DefaultMapping())
+	s.ErrorContains(err, "not an OFX file")
+
+	_, err = ParseOFX(strings.NewReader("<OFX><STMTTRN><DTPOSTED>2026<TRNAMT>-1</STMTTRN></OFX>"), DefaultMapping())
+	s.ErrorContains(err, "transaction 1: invalid date")
+}
+
+// TestOFXSuite runs the OFX test suite
+func TestOFXSuite(t *testing.T) {
+	suite.Run(t, new(OFXTestSuite))
+}
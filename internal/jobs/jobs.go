@@ -0,0 +1,224 @@
+// Package jobs runs background tasks, such as cleaning up expired sessions,
+// at regular intervals.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrUnknownJob is returned by RunNow for a name that wasn't registered.
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrRunning is returned by RunNow when the job is already running.
+var ErrRunning = errors.New("job is already running")
+
+// defaultJitter is how much each interval is randomly lengthened or
+// shortened, as a fraction of it, so jobs with the same interval don't all
+// hit the database at once.
+const defaultJitter = 0.1
+
+// Job is a task to run every Interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+	// RunAtStart runs the job as soon as the scheduler starts instead of
+	// after the first interval.
+	RunAtStart bool
+}
+
+// Status is what a job last did, for display on the admin page.
+type Status struct {
+	Name         string
+	Interval     time.Duration
+	Running      bool
+	Runs         int64
+	Failures     int64
+	LastRun      time.Time // Start of the last run, zero if it never ran
+	LastDuration time.Duration
+	LastError    string // Empty if the last run succeeded
+	NextRun      time.Time
+}
+
+// job is a registered Job and its state.
+type job struct {
+	Job
+	lock   sync.Mutex // Held while running, so runs never overlap
+	mu     sync.Mutex // Guards status
+	status Status
+}
+
+// Scheduler runs registered jobs in the background.
+type Scheduler struct {
+	jobs   []*job
+	jitter float64
+	paused func() bool
+
+	ctx context.Context // Set by Start
+	wg  sync.WaitGroup
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithJitter sets how much intervals vary, as a fraction of the interval.
+func WithJitter(fraction float64) Option {
+	return func(s *Scheduler) { s.jitter = fraction }
+}
+
+// WithPause skips scheduled runs while paused returns true, e.g. in
+// read-only mode.
+func WithPause(paused func() bool) Option {
+	return func(s *Scheduler) { s.paused = paused }
+}
+
+// New creates a Scheduler without any jobs.
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{jitter: defaultJitter, paused: func() bool { return false }}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds a job. It must be called before Start, and panics if the
+// name is taken or the interval isn't positive.
+func (s *Scheduler) Register(j Job) {
+	if j.Interval <= 0 {
+		panic(fmt.Sprintf("jobs: interval of %s must be positive", j.Name))
+	}
+	for _, existing := range s.jobs {
+		if existing.Name == j.Name {
+			panic(fmt.Sprintf("jobs: %s registered twice", j.Name))
+		}
+	}
+	s.jobs = append(s.jobs, &job{Job: j, status: Status{Name: j.Name, Interval: j.Interval}})
+}
+
+// Start runs every job on its schedule until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.wg.Go(func() { s.loop(ctx, j) })
+	}
+}
+
+// Wait blocks until the jobs have stopped after the context passed to Start
+// is cancelled, letting runs in progress finish.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// loop runs one job every interval until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	delay := s.nextDelay(j.Interval)
+	if j.RunAtStart {
+		delay = 0
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		j.mu.Lock()
+		j.status.NextRun = time.Now().Add(delay)
+		j.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if !s.paused() {
+			// A manual run still in progress takes this run's place
+			_ = s.run(ctx, j)
+		}
+		delay = s.nextDelay(j.Interval)
+		timer.Reset(delay)
+	}
+}
+
+// nextDelay returns the interval, randomly lengthened or shortened by up to
+// the jitter.
+func (s *Scheduler) nextDelay(interval time.Duration) time.Duration {
+	return interval + time.Duration((rand.Float64()*2-1)*s.jitter*float64(interval))
+}
+
+// run runs a job once and records the result, or returns ErrRunning if it
+// is already running.
+func (s *Scheduler) run(ctx context.Context, j *job) error {
+	if !j.lock.TryLock() {
+		return ErrRunning
+	}
+	defer j.lock.Unlock()
+
+	start := time.Now()
+	j.mu.Lock()
+	j.status.Running = true
+	j.status.LastRun = start
+	j.mu.Unlock()
+
+	err := safeRun(ctx, j)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDuration = time.Since(start)
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		log.Printf("Job %s failed: %v", j.Name, err)
+	}
+	return nil
+}
+
+// safeRun runs a job, turning a panic into an error so one broken job
+// doesn't take the server down.
+func safeRun(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.Run(ctx)
+}
+
+// RunNow starts a job outside its schedule, e.g. from the admin page, and
+// returns without waiting for it to finish. The scheduler must be started.
+func (s *Scheduler) RunNow(name string) error {
+	for _, j := range s.jobs {
+		if j.Name != name {
+			continue
+		}
+		if s.ctx == nil || s.ctx.Err() != nil {
+			return errors.New("jobs: scheduler is not running")
+		}
+		j.mu.Lock()
+		running := j.status.Running
+		j.mu.Unlock()
+		if running {
+			return ErrRunning
+		}
+		s.wg.Go(func() { _ = s.run(s.ctx, j) })
+		return nil
+	}
+	return ErrUnknownJob
+}
+
+// Statuses returns the status of every job in the order they were
+// registered.
+func (s *Scheduler) Statuses() []Status {
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		statuses = append(statuses, j.status)
+		j.mu.Unlock()
+	}
+	return statuses
+}
@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// JobsTestSuite provides a test suite for the job scheduler
+type JobsTestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// SetupTest runs before each test
+func (s *JobsTestSuite) SetupTest() {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+// TearDownTest runs after each test
+func (s *JobsTestSuite) TearDownTest() {
+	s.cancel()
+}
+
+// waitForRuns waits until the named job has finished n runs.
+func (s *JobsTestSuite) waitForRuns(scheduler *Scheduler, name string, n int64) Status {
+	var status Status
+	s.Require().Eventually(func() bool {
+		for _, st := range scheduler.Statuses() {
+			if st.Name == name {
+				status = st
+			}
+		}
+		return status.Runs >= n && !status.Running
+	}, 5*time.Second, time.Millisecond)
+	return status
+}
+
+func (s *JobsTestSuite) TestRunsOnSchedule() {
+	var runs atomic.Int64
+	scheduler := New(WithJitter(0))
+	scheduler.Register(Job{Name: "tick", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	scheduler.Start(s.ctx)
+
+	status := s.waitForRuns(scheduler, "tick", 3)
+	s.Equal(time.Duration(5*time.Millisecond), status.Interval)
+	s.Zero(status.Failures)
+	s.False(status.LastRun.IsZero())
+
+	s.cancel()
+	scheduler.Wait()
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	s.Equal(stopped, runs.Load(), "no runs after stopping")
+}
+
+func (s *JobsTestSuite) TestRecordsFailuresAndPanics() {
+	scheduler := New()
+	scheduler.Register(Job{Name: "fails", Interval: time.Hour, RunAtStart: true, Run: func(context.Context) error {
+		return errors.New("disk full")
+	}})
+	scheduler.Register(Job{Name: "panics", Interval: time.Hour, RunAtStart: true, Run: func(context.Context) error {
+		panic("oops")
+	}})
+	scheduler.Start(s.ctx)
+
+	status := s.waitForRuns(scheduler, "fails", 1)
+	s.Equal(int64(1), status.Failures)
+	s.Equal("disk full", status.LastError)
+	s.True(status.NextRun.After(time.Now().Add(50*time.Minute)), "next run is about an hour away")
+
+	status = s.waitForRuns(scheduler, "panics", 1)
+	s.Equal("panic: oops", status.LastError)
+}
+
+func (s *JobsTestSuite) TestRunNowDoesNotOverlap() {
+	release := make(chan struct{})
+	var runs atomic.Int64
+	scheduler := New()
+	scheduler.Register(Job{Name: "slow", Interval: time.Hour, Run: func(context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}})
+
+	s.Error(scheduler.RunNow("slow"), "not started yet")
+	scheduler.Start(s.ctx)
+	s.ErrorIs(scheduler.RunNow("missing"), ErrUnknownJob)
+
+	s.Require().NoError(scheduler.RunNow("slow"))
+	s.Require().Eventually(func() bool { return scheduler.Statuses()[0].Running }, 5*time.Second, time.Millisecond)
+	s.ErrorIs(scheduler.RunNow("slow"), ErrRunning)
+	close(release)
+
+	s.waitForRuns(scheduler, "slow", 1)
+	s.Equal(int64(1), runs.Load())
+	s.Require().NoError(scheduler.RunNow("slow"))
+	s.waitForRuns(scheduler, "slow", 2)
+}
+
+func (s *JobsTestSuite) TestPause() {
+	var paused atomic.Bool
+	paused.Store(true)
+	var runs atomic.Int64
+	scheduler := New(WithPause(paused.Load))
+	scheduler.Register(Job{Name: "tick", Interval: time.Millisecond, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	scheduler.Start(s.ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	s.Zero(runs.Load())
+	paused.Store(false)
+	s.waitForRuns(scheduler, "tick", 1)
+}
+
+func (s *JobsTestSuite) TestRegisterPanics() {
+	scheduler := New()
+	s.Panics(func() { scheduler.Register(Job{Name: "never", Run: func(context.Context) error { return nil }}) })
+	scheduler.Register(Job{Name: "tick", Interval: time.Minute, Run: func(context.Context) error { return nil }})
+	s.Panics(func() {
+		scheduler.Register(Job{Name: "tick", Interval: time.Minute, Run: func(context.Context) error { return nil }})
+	})
+}
+
+func (s *JobsTestSuite) TestJitter() {
+	scheduler := New(WithJitter(0.1))
+	for range 100 {
+		d := scheduler.nextDelay(time.Hour)
+		s.GreaterOrEqual(d, 54*time.Minute)
+		s.LessOrEqual(d, 66*time.Minute)
+	}
+}
+
+// TestJobsSuite runs the job scheduler test suite
+func TestJobsSuite(t *testing.T) {
+	suite.Run(t, new(JobsTestSuite))
+}
@@ -0,0 +1,84 @@
+// Package loadtest fills a database with made-up expenses at scale, for the
+// benchmarks of the expense list, statistics, and login.
+package loadtest
+
+import (
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Password of every user Populate creates.
+const Password = "load test password"
+
+// Options say how much data Populate creates.
+type Options struct {
+	Users    int       // Named user1, user2, ...
+	Expenses int       // Spread evenly over the users
+	Years    int       // How far back the expenses go
+	Now      time.Time // The newest possible expense
+}
+
+// descriptions are picked at random for the made-up expenses.
+var descriptions = []string{
+	"Supermarket", "Coffee", "Lunch", "Train ticket", "Cinema", "Pharmacy",
+	"Bakery", "Fuel", "Books", "Dinner with friends", "Hardware store", "Taxi",
+}
+
+// batchSize is how many expenses are imported per transaction, which keeps
+// memory flat however many there are.
+const batchSize = 10000
+
+// Populate creates the users and expenses of opts in db. The same options
+// give the same data. The rare expense that matches another one's date,
+// amount, and description is skipped as a duplicate.
+func Populate(db *storage.DB, opts Options) ([]*models.User, error) {
+	if opts.Users < 1 || opts.Years < 1 {
+		return nil, fmt.Errorf("loadtest: need at least one user and one year")
+	}
+	// Hashing is slow on purpose, so every user shares one hash
+	hash, err := auth.HashPassword(Password)
+	if err != nil {
+		return nil, fmt.Errorf("loadtest: %w", err)
+	}
+	users := make([]*models.User, opts.Users)
+	for i := range users {
+		users[i], err = db.CreateUser(fmt.Sprintf("user%d", i+1), hash)
+		if err != nil {
+			return nil, fmt.Errorf("loadtest: %w", err)
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(uint64(opts.Now.Unix()), uint64(opts.Expenses)))
+	span := opts.Now.Sub(opts.Now.AddDate(-opts.Years, 0, 0))
+	batch := make([]models.Expense, 0, batchSize)
+	for u, user := range users {
+		n := opts.Expenses / opts.Users
+		if u < opts.Expenses%opts.Users {
+			n++
+		}
+		for i := range n {
+			// Each expense falls at random within its own slice of the span,
+			// so a batch covers a short stretch of days and importing it
+			// refreshes only their aggregates
+			offset := time.Duration((float64(i) + rng.Float64()) / float64(n) * float64(span))
+			batch = append(batch, models.Expense{
+				Date:        opts.Now.Add(-offset).Truncate(time.Second),
+				Amount:      math.Round(rng.Float64()*20000) / 100,
+				Description: descriptions[rng.IntN(len(descriptions))],
+				Category:    models.Categories[rng.IntN(len(models.Categories))],
+			})
+			if len(batch) == batchSize || i == n-1 {
+				if _, err := db.ImportExpenses(batch, user.ID, false); err != nil {
+					return nil, fmt.Errorf("loadtest: %w", err)
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+	return users, nil
+}
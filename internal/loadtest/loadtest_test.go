@@ -0,0 +1,70 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/auth"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LoadTestSuite provides a test suite for the load-test data generator
+type LoadTestSuite struct {
+	suite.Suite
+	db *storage.DB
+}
+
+// SetupTest runs before each test
+func (s *LoadTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *LoadTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *LoadTestSuite) TestPopulate() {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	users, err := Populate(s.db, Options{Users: 3, Expenses: 1000, Years: 2, Now: now})
+	s.Require().NoError(err)
+	s.Require().Len(users, 3)
+	s.Equal("user1", users[0].Username)
+	s.True(auth.CheckPassword(Password, users[2].PasswordHash))
+
+	expenses, err := s.db.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.InDelta(1000, len(expenses), 5, "only the odd duplicate is skipped")
+	perUser := map[int64]int{}
+	for _, e := range expenses {
+		s.False(e.Date.After(now))
+		s.False(e.Date.Before(now.AddDate(-2, 0, 0)))
+		perUser[*e.UserID]++
+	}
+	s.Len(perUser, 3)
+
+	// The aggregates the statistics read match the expenses
+	total, err := s.db.GetTotalForPeriod(0, 2024, 0)
+	s.Require().NoError(err)
+	var want float64
+	for _, e := range expenses {
+		if e.Date.Year() == 2024 {
+			want += e.Amount
+		}
+	}
+	s.InDelta(want, total, 0.01)
+}
+
+func (s *LoadTestSuite) TestPopulate_Invalid() {
+	_, err := Populate(s.db, Options{Expenses: 10, Years: 1, Now: time.Now()})
+	s.Error(err)
+}
+
+// TestLoadTestSuite runs the load-test data generator test suite
+func TestLoadTestSuite(t *testing.T) {
+	suite.Run(t, new(LoadTestSuite))
+}
@@ -0,0 +1,85 @@
+// Package mail sends notification emails rendered from templates.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender writes messages to the log instead of sending them, for
+// development without a mail server.
+type LogSender struct{}
+
+// Send logs msg.
+func (LogSender) Send(msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Mailer renders templates into messages and hands them to a Sender.
+//
+// Templates are text/template files, each rendering a "Subject:" line, a
+// blank line, and the body:
+//
+//	Subject: Verify your email address
+//
+//	Hi {{.Username}}, ...
+type Mailer struct {
+	sender    Sender
+	templates fs.FS
+}
+
+// New creates a Mailer that sends through sender using the templates in
+// templates.
+func New(sender Sender, templates fs.FS) *Mailer {
+	return &Mailer{sender: sender, templates: templates}
+}
+
+// Send renders the named template with data and sends it to the address to.
+func (m *Mailer) Send(to, name string, data any) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return errors.New("mail: invalid recipient")
+	}
+	msg, err := m.render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.sender.Send(msg)
+}
+
+// render executes a template and splits its output into subject and body.
+func (m *Mailer) render(name string, data any) (Message, error) {
+	tmpl, err := template.ParseFS(m.templates, name)
+	if err != nil {
+		return Message{}, fmt.Errorf("mail: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("mail: %w", err)
+	}
+
+	header, body, ok := strings.Cut(buf.String(), "\n\n")
+	subject, found := strings.CutPrefix(header, "Subject:")
+	if !ok || !found || strings.Contains(header, "\n") {
+		return Message{}, fmt.Errorf("mail: template %s must start with a Subject line and a blank line", name)
+	}
+	return Message{Subject: strings.TrimSpace(subject), Body: body}, nil
+}
@@ -0,0 +1,155 @@
+package mail
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingSender keeps sent messages for inspection.
+type recordingSender struct {
+	sent []Message
+}
+
+func (s *recordingSender) Send(msg Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// MailTestSuite provides a test suite for the mailer
+type MailTestSuite struct {
+	suite.Suite
+	templateDir string
+}
+
+// SetupTest runs before each test
+func (s *MailTestSuite) SetupTest() {
+	s.templateDir = s.T().TempDir()
+	s.writeTemplate("hello.txt", "Subject: Hello {{.Name}}\n\nHi {{.Name}},\nwelcome aboard.\n")
+}
+
+func (s *MailTestSuite) writeTemplate(name, content string) {
+	s.Require().NoError(os.WriteFile(filepath.Join(s.templateDir, name), []byte(content), 0o644))
+}
+
+func (s *MailTestSuite) TestSend_RendersTemplate() {
+	sender := &recordingSender{}
+	m := New(sender, os.DirFS(s.templateDir))
+
+	s.Require().NoError(m.Send("alice@example.com", "hello.txt", map[string]string{"Name": "Alice"}))
+
+	s.Require().Len(sender.sent, 1)
+	s.Equal(Message{To: "alice@example.com", Subject: "Hello Alice", Body: "Hi Alice,\nwelcome aboard.\n"}, sender.sent[0])
+}
+
+func (s *MailTestSuite) TestSend_Errors() {
+	sender := &recordingSender{}
+	m := New(sender, os.DirFS(s.templateDir))
+	s.writeTemplate("no-subject.txt", "Hi there\n\nBody\n")
+
+	s.Error(m.Send("alice@example.com", "missing.txt", nil))
+	s.Error(m.Send("alice@example.com", "no-subject.txt", nil))
+	s.Error(m.Send("alice@example.com\r\nBcc: mallory@example.com", "hello.txt", map[string]string{"Name": "Alice"}))
+	s.Empty(sender.sent)
+}
+
+// fakeSMTPServer accepts one message and passes its DATA to the returned channel.
+func (s *MailTestSuite) fakeSMTPServer() (int, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { l.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port, received
+}
+
+func (s *MailTestSuite) TestSMTPSender() {
+	port, received := s.fakeSMTPServer()
+
+	sender := SMTPSender{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "Expense Tracker <expenses@example.com>",
+		TLS:  TLSNone,
+	}
+	s.Require().NoError(sender.Send(Message{To: "alice@example.com", Subject: "Ausgaben für März", Body: "Total: 12 €\nSee you\n"}))
+
+	msg, err := netmail.ReadMessage(strings.NewReader(<-received))
+	s.Require().NoError(err)
+	s.Equal(`"Expense Tracker" <expenses@example.com>`, msg.Header.Get("From"))
+	s.Equal("<alice@example.com>", msg.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	s.Require().NoError(err)
+	s.Equal("Ausgaben für März", subject)
+
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	s.Require().NoError(err)
+	s.Equal("Total: 12 €\r\nSee you\r\n", string(body))
+}
+
+func (s *MailTestSuite) TestSMTPSender_Check() {
+	port, _ := s.fakeSMTPServer()
+	s.NoError(SMTPSender{Host: "127.0.0.1", Port: port, TLS: TLSNone}.Check())
+
+	// The fake server can't upgrade to TLS
+	port, _ = s.fakeSMTPServer()
+	s.Error(SMTPSender{Host: "127.0.0.1", Port: port, TLS: TLSStartTLS}.Check())
+}
+
+func (s *MailTestSuite) TestSMTPSender_InvalidFrom() {
+	sender := SMTPSender{Host: "127.0.0.1", Port: 25, From: "", TLS: TLSNone}
+	s.Error(sender.Send(Message{To: "alice@example.com", Subject: "Hi", Body: "Hi"}))
+}
+
+// TestMailSuite runs the mailer test suite
+func TestMailSuite(t *testing.T) {
+	suite.Run(t, new(MailTestSuite))
+}
@@ -0,0 +1,158 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// TLS modes for SMTPSender.
+const (
+	TLSStartTLS = "starttls" // Upgrade a plain connection, usually on port 587
+	TLSImplicit = "tls"      // Connect with TLS, usually on port 465
+	TLSNone     = "none"     // Never encrypt, for local relays only
+)
+
+// smtpTimeout bounds connecting to and talking with the SMTP server.
+const smtpTimeout = 30 * time.Second
+
+// SMTPSender delivers messages through an SMTP server.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string // Authenticates with PLAIN when set
+	Password string
+	From     string // Sender address, e.g. "Expense Tracker <expenses@example.com>"
+	TLS      string // TLSStartTLS, TLSImplicit, or TLSNone; defaults to TLSStartTLS
+}
+
+// Send delivers msg.
+func (s SMTPSender) Send(msg Message) error {
+	from, err := netmail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("mail: invalid from address: %w", err)
+	}
+	data, err := s.format(from, msg)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer c.Close()
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	return c.Quit()
+}
+
+// Check connects and authenticates to the server without sending anything,
+// to find configuration problems before an email is due.
+func (s SMTPSender) Check() error {
+	c, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer c.Close()
+	return c.Quit()
+}
+
+// connect dials the server, upgrades the connection to TLS unless it's
+// encrypted already or TLS is off, and authenticates.
+func (s SMTPSender) connect() (*smtp.Client, error) {
+	c, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	if s.TLS == "" || s.TLS == TLSStartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// dial connects to the server, with TLS from the start in implicit mode.
+func (s SMTPSender) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	switch s.TLS {
+	case "", TLSStartTLS, TLSNone:
+		conn, err = dialer.Dial("tcp", addr)
+	case TLSImplicit:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.Host})
+	default:
+		return nil, fmt.Errorf("unknown TLS mode %q", s.TLS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// format builds the RFC 5322 message with a quoted-printable UTF-8 body.
+func (s SMTPSender) format(from *netmail.Address, msg Message) ([]byte, error) {
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid recipient: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write(bytes.ReplaceAll([]byte(msg.Body), []byte("\n"), []byte("\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
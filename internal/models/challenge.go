@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Challenge is a user's goal of keeping the spending in a ledger under an
+// amount for one week, Monday to Sunday.
+type Challenge struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	LedgerID  int64     `json:"ledger_id"`
+	Week      string    `json:"week"`  // The Monday it starts, YYYY-MM-DD
+	Limit     float64   `json:"limit"` // Spending to stay under
+	Spent     float64   `json:"spent"` // As of the last time progress was tracked
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Challenge statuses.
+const (
+	ChallengeActive    = "active"    // The week isn't over and spending is under the limit
+	ChallengeCompleted = "completed" // The week ended under the limit
+	ChallengeFailed    = "failed"    // Spending went over the limit
+)
+
+// Streak is a user's run of no-spend days: days without expenses in the
+// ledger they picked.
+type Streak struct {
+	UserID    int64     `json:"user_id"`
+	Current   int       `json:"current"` // No-spend days in a row up to yesterday
+	Longest   int       `json:"longest"` // The longest run since the user signed up
+	UpdatedAt time.Time `json:"updated_at"`
+}
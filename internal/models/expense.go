@@ -10,14 +10,86 @@ type Expense struct {
 	Category    string    `json:"category"`
 	Date        time.Time `json:"date"`
 	UserID      *int64    `json:"user_id,omitempty"`
+	LedgerID    int64     `json:"ledger_id,omitempty"` // Zero when inserting means PersonalLedgerID
+}
+
+// Ledger keeps a set of expenses apart from the others, with statistics of
+// its own, e.g. a business next to the household's personal expenses.
+type Ledger struct {
+	ID         int64      `json:"id"`
+	OwnerID    *int64     `json:"owner_id,omitempty"` // Nil for a ledger the household shares
+	Name       string     `json:"name"`
+	Currency   string     `json:"currency,omitempty"` // ISO 4217, empty for each user's own
+	CreatedAt  time.Time  `json:"created_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"` // Set once it's put away
+}
+
+// VisibleTo reports whether a user can see the ledger: it's theirs or the
+// household's.
+func (l Ledger) VisibleTo(userID int64) bool {
+	return l.OwnerID == nil || *l.OwnerID == userID
+}
+
+// PersonalLedgerID is the ledger expenses are kept in unless another is
+// picked. It always exists.
+const PersonalLedgerID = 1
+
+// Location is where an expense was made: coordinates from the browser's
+// geolocation, a place name, or both.
+type Location struct {
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Place     string   `json:"place,omitempty"`
+}
+
+// IsZero reports whether no location was recorded.
+func (l Location) IsZero() bool {
+	return l.Latitude == nil && l.Longitude == nil && l.Place == ""
+}
+
+// Reimbursement states of expenses someone else pays back, e.g. an
+// employer.
+const (
+	ReimbursementPending    = "pending"    // Still to be claimed
+	ReimbursementSubmitted  = "submitted"  // Claimed, waiting to be paid
+	ReimbursementReimbursed = "reimbursed" // Paid back
+)
+
+// Project groups expenses across categories and months, e.g. a trip.
+type Project struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Categories are the expense categories the app offers, in display order.
+var Categories = []string{
+	"Groceries", "Eating Out", "Transport", "Housing", "Utilities", "Sport",
+	"Health", "Entertainment", "Travel", "Gifts", "Other",
 }
 
 // User represents a user account.
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	PasswordHash  string     `json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Email         string     `json:"email,omitempty"`        // Empty if never set
+	EmailVerified bool       `json:"email_verified"`         // Required before email is used for resets or notifications
+	Role          string     `json:"role"`                   // RoleAdmin or RoleMember
+	DeleteAfter   *time.Time `json:"delete_after,omitempty"` // Set while the account is scheduled for deletion
+	Disabled      bool       `json:"disabled"`               // Disabled accounts can't log in
+}
+
+// User roles.
+const (
+	RoleAdmin  = "admin"  // Can invite new users
+	RoleMember = "member" // Regular user
+)
+
+// IsAdmin reports whether the user has the admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 // Session represents a user session.
@@ -26,3 +98,56 @@ type Session struct {
 	UserID    int64     `json:"user_id"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
+
+// LoginEvent records a login attempt for the security audit log.
+type LoginEvent struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id,omitempty"` // nil for unknown usernames
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Invite is a single-use link that lets someone create an account.
+type Invite struct {
+	ID        int64      `json:"id"`
+	CreatedBy int64      `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	UsedBy    string     `json:"used_by,omitempty"` // Username of the account created with it
+}
+
+// GuestToken lets someone without an account, e.g. an accountant, look at a
+// user's expenses and statistics in one ledger without changing anything.
+// Only a hash of the token is stored.
+type GuestToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	LedgerID   int64      `json:"ledger_id"`
+	Name       string     `json:"name"` // Who it's for, e.g. Accountant
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Passkey represents a WebAuthn credential registered for passwordless login.
+type Passkey struct {
+	ID         string     `json:"id"` // Credential ID, base64url encoded
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	PublicKey  []byte     `json:"-"` // SubjectPublicKeyInfo (DER)
+	SignCount  uint32     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Avatar is the picture shown for a user, e.g. next to their expenses.
+type Avatar struct {
+	UserID      int64     `json:"user_id"`
+	Image       []byte    `json:"-"`
+	ContentType string    `json:"content_type"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// NotificationChannel is a way a user gets notified, and of which events.
+type NotificationChannel struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Kind   string `json:"kind"` // ChannelNtfy, ChannelWebPush, or ChannelEmail
+	// The ntfy topic URL or the push service endpoint; empty for email,
+	// which goes to the user's verified address
+	Address string `json:"address,omitempty"`
+	// Keys of a Web Push subscription, base64url encoded
+	P256dh    string    `json:"-"`
+	Auth      string    `json:"-"`
+	Events    []string  `json:"events"` // Those notified of, from NotificationEvents
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifies reports whether the channel is used for event.
+func (c NotificationChannel) Notifies(event string) bool {
+	return slices.Contains(c.Events, event)
+}
+
+// Notification channel kinds.
+const (
+	ChannelNtfy    = "ntfy"    // Posts to an ntfy topic
+	ChannelWebPush = "webpush" // Pushes to a browser
+	ChannelEmail   = "email"
+)
+
+// NotificationChannels are the valid channel kinds.
+var NotificationChannels = []string{ChannelNtfy, ChannelWebPush, ChannelEmail}
+
+// Events users can be notified of.
+const (
+	EventBudgetAlert  = "budget_alert"  // Spending in a budget period reached the budget
+	EventBillReminder = "bill_reminder" // A recurring expense is due soon
+	EventWeeklyDigest = "weekly_digest" // What was spent last week, on Mondays
+)
+
+// NotificationEvents are the events users can be notified of.
+var NotificationEvents = []string{EventBudgetAlert, EventBillReminder, EventWeeklyDigest}
+
+// NotificationDelivery records a notification sent, or tried, through a
+// channel.
+type NotificationDelivery struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	ChannelID int64     `json:"channel_id"`
+	Kind      string    `json:"kind"`
+	Event     string    `json:"event"`
+	Key       string    `json:"-"` // Sends each occurrence of an event once, e.g. per budget period
+	Title     string    `json:"title"`
+	Error     string    `json:"error,omitempty"` // Why it failed, empty if it was delivered
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// Preferences are a user's settings for how the app shows their expenses.
+type Preferences struct {
+	Currency        string  `json:"currency"`         // ISO 4217 code, e.g. EUR
+	Locale          string  `json:"locale"`           // Language tag setting the number format, e.g. en-US
+	Timezone        string  `json:"timezone"`         // IANA name, e.g. Europe/Berlin; empty for the server's
+	Theme           string  `json:"theme"`            // ThemeLight, ThemeDark, or ThemeSystem
+	CycleStart      int     `json:"cycle_start"`      // Day of the month budget periods start on, 1 to 28
+	DefaultCategory string  `json:"default_category"` // Preselected for new expenses; empty for the first category
+	Ledger          int64   `json:"ledger"`           // ID of the ledger whose expenses the pages show
+	Budget          float64 `json:"budget"`           // Spending per budget period to be alerted at, 0 for none
+	WeeklyDigest    bool    `json:"weekly_digest"`    // Whether to be sent a digest of each week on Mondays
+}
+
+// Themes.
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system" // Follows the device
+)
+
+// DefaultPreferences returns the preferences of users who never saved any.
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Currency:   "EUR",
+		Locale:     "en-US",
+		Theme:      ThemeLight,
+		CycleStart: 1,
+		Ledger:     PersonalLedgerID,
+	}
+}
@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"expense-tracker/internal/mail"
+	"expense-tracker/internal/models"
+)
+
+// Email sends notifications to the verified address of a channel's user, so
+// the channel follows when they change it.
+type Email struct {
+	Mailer *mail.Mailer
+	User   func(id int64) (*models.User, error)
+}
+
+// Send emails msg.
+func (s *Email) Send(_ context.Context, c models.NotificationChannel, msg Notification) error {
+	user, err := s.User(c.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" || !user.EmailVerified {
+		return errors.New("email: no verified address")
+	}
+	return s.Mailer.Send(user.Email, "notification.txt", msg)
+}
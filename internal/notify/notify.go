@@ -0,0 +1,185 @@
+// Package notify tells users about their expenses through the channels they
+// set up: an ntfy topic, Web Push to their browsers, or email. Each channel
+// notifies of the events its user picked, such as budget alerts, and every
+// delivery is logged, so users can see what was sent and what failed.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// ErrGone is returned by senders for a channel that no longer exists, such
+// as a push subscription the browser dropped. The Notifier removes it.
+var ErrGone = errors.New("notify: channel is gone")
+
+// Notification is a message about one event.
+type Notification struct {
+	Event string // From models.NotificationEvents
+	// Sends the notification once per channel, e.g. once per budget
+	// period; empty sends it every time
+	Key   string
+	Title string
+	Body  string
+	URL   string // Absolute URL of the page it's about, empty for none
+}
+
+// Sender delivers notifications through one kind of channel.
+type Sender interface {
+	Send(ctx context.Context, c models.NotificationChannel, n Notification) error
+}
+
+// Notifier sends notifications through the channels of a user, and checks
+// for the events they're notified of.
+type Notifier struct {
+	db      *storage.DB
+	senders map[string]Sender
+	baseURL string
+	now     func() time.Time
+	logger  *log.Logger
+}
+
+// Option configures a Notifier.
+type Option func(*Notifier)
+
+// WithSender delivers the channels of a kind, one of
+// models.NotificationChannels, through s. Channels of kinds without a sender
+// can't be added, and their deliveries fail.
+func WithSender(kind string, s Sender) Option {
+	return func(n *Notifier) { n.senders[kind] = s }
+}
+
+// WithBaseURL sets the server's public URL, e.g. https://expenses.example.com,
+// which notifications link to. Without it they don't link anywhere.
+func WithBaseURL(url string) Option {
+	return func(n *Notifier) { n.baseURL = strings.TrimSuffix(url, "/") }
+}
+
+// WithClock sets the clock events are checked against, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(n *Notifier) { n.now = now }
+}
+
+// WithLogger sets the logger failed deliveries are reported to.
+func WithLogger(l *log.Logger) Option {
+	return func(n *Notifier) { n.logger = l }
+}
+
+// New creates a Notifier that keeps its channels and deliveries in db.
+func New(db *storage.DB, opts ...Option) *Notifier {
+	n := &Notifier{db: db, senders: map[string]Sender{}, now: time.Now, logger: log.Default()}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Supports reports whether channels of a kind can be used.
+func (n *Notifier) Supports(kind string) bool {
+	_, ok := n.senders[kind]
+	return ok
+}
+
+// PushKey returns the public key browsers subscribe to Web Push with, empty
+// without a Web Push sender.
+func (n *Notifier) PushKey() string {
+	if push, ok := n.senders[models.ChannelWebPush].(*WebPush); ok {
+		return push.PublicKey()
+	}
+	return ""
+}
+
+// TopicURL returns the URL of an ntfy topic given by name or URL, see
+// Ntfy.TopicURL.
+func (n *Notifier) TopicURL(topic string) (string, error) {
+	ntfy, ok := n.senders[models.ChannelNtfy].(*Ntfy)
+	if !ok {
+		return "", errors.New("notify: ntfy isn't set up on this server")
+	}
+	return ntfy.TopicURL(topic)
+}
+
+// CheckPushEndpoint returns an error unless a browser's push endpoint can be
+// sent to, see WebPush.CheckEndpoint.
+func (n *Notifier) CheckPushEndpoint(ctx context.Context, endpoint string) error {
+	push, ok := n.senders[models.ChannelWebPush].(*WebPush)
+	if !ok {
+		return errors.New("notify: Web Push isn't set up on this server")
+	}
+	return push.CheckEndpoint(ctx, endpoint)
+}
+
+// url returns the absolute URL of a page, empty without a base URL.
+func (n *Notifier) url(path string) string {
+	if n.baseURL == "" || path == "" {
+		return ""
+	}
+	return n.baseURL + path
+}
+
+// Send delivers a notification through each of the user's channels that
+// notify of its event and haven't delivered it yet. Failed deliveries are
+// logged rather than returned; the error is about keeping track of them.
+func (n *Notifier) Send(ctx context.Context, userID int64, msg Notification) error {
+	channels, err := n.db.ListNotificationChannels(userID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range channels {
+		if !c.Notifies(msg.Event) {
+			continue
+		}
+		if msg.Key != "" {
+			sent, err := n.db.NotificationSent(c.ID, msg.Key)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if sent {
+				continue
+			}
+		}
+		if _, err := n.SendTo(ctx, c, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendTo delivers a notification through one channel whatever events it
+// notifies of, e.g. to try it out, and logs the delivery. It returns why the
+// delivery failed, if it did, and an error if logging it failed.
+func (n *Notifier) SendTo(ctx context.Context, c models.NotificationChannel, msg Notification) (string, error) {
+	sender, ok := n.senders[c.Kind]
+	var sendErr error
+	if ok {
+		sendErr = sender.Send(ctx, c, msg)
+	} else {
+		sendErr = fmt.Errorf("notify: %s isn't set up on this server", c.Kind)
+	}
+	delivery := &models.NotificationDelivery{
+		UserID: c.UserID, ChannelID: c.ID, Kind: c.Kind,
+		Event: msg.Event, Key: msg.Key, Title: msg.Title,
+	}
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+		n.logger.Printf("Notification %s to user %d by %s failed: %v", msg.Event, c.UserID, c.Kind, sendErr)
+	}
+	if err := n.db.LogNotification(delivery); err != nil {
+		return delivery.Error, err
+	}
+	if errors.Is(sendErr, ErrGone) {
+		if err := n.db.DeleteNotificationChannel(c.UserID, c.ID); err != nil {
+			return delivery.Error, err
+		}
+	}
+	return delivery.Error, nil
+}
@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingSender keeps sent notifications for inspection, and fails with
+// err when set.
+type recordingSender struct {
+	sent []Notification
+	err  error
+}
+
+func (s *recordingSender) Send(_ context.Context, _ models.NotificationChannel, n Notification) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+// titles returns the titles of the sent notifications.
+func (s *recordingSender) titles() []string {
+	var titles []string
+	for _, n := range s.sent {
+		titles = append(titles, n.Title)
+	}
+	return titles
+}
+
+// NotifyTestSuite provides a test suite for the notifier
+type NotifyTestSuite struct {
+	suite.Suite
+	db       *storage.DB
+	sender   *recordingSender
+	notifier *Notifier
+	userID   int64
+	channel  *models.NotificationChannel
+}
+
+// SetupTest runs before each test
+func (s *NotifyTestSuite) SetupTest() {
+	db, err := storage.NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	user, err := db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.userID = user.ID
+	s.channel = &models.NotificationChannel{UserID: user.ID, Kind: models.ChannelNtfy, Address: "https://ntfy.sh/alice", Events: models.NotificationEvents}
+	s.Require().NoError(db.AddNotificationChannel(s.channel))
+
+	s.sender = &recordingSender{}
+	// Monday, March 9
+	now := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	s.notifier = New(db,
+		WithSender(models.ChannelNtfy, s.sender),
+		WithBaseURL("https://expenses.example.com/"),
+		WithClock(func() time.Time { return now }),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+}
+
+// TearDownTest runs after each test
+func (s *NotifyTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *NotifyTestSuite) TestSendOnce() {
+	msg := Notification{Event: models.EventBudgetAlert, Key: "budget:2026-03-01", Title: "Budget reached"}
+	s.Require().NoError(s.notifier.Send(context.Background(), s.userID, msg))
+	s.Require().NoError(s.notifier.Send(context.Background(), s.userID, msg))
+	s.Len(s.sender.sent, 1, "once per key")
+
+	s.Require().NoError(s.db.SetNotificationEvents(s.userID, s.channel.ID, []string{models.EventWeeklyDigest}))
+	s.Require().NoError(s.notifier.Send(context.Background(), s.userID, Notification{Event: models.EventBudgetAlert, Title: "Budget reached"}))
+	s.Len(s.sender.sent, 1, "not subscribed to the event")
+
+	deliveries, err := s.db.ListNotificationDeliveries(s.userID, 10)
+	s.Require().NoError(err)
+	s.Len(deliveries, 1)
+}
+
+func (s *NotifyTestSuite) TestSendToLogsFailures() {
+	s.sender.err = io.ErrUnexpectedEOF
+	failure, err := s.notifier.SendTo(context.Background(), *s.channel, Notification{Event: models.EventBillReminder, Title: "Test"})
+	s.Require().NoError(err)
+	s.Equal("unexpected EOF", failure)
+
+	s.sender.err = ErrGone
+	_, err = s.notifier.SendTo(context.Background(), *s.channel, Notification{Event: models.EventBillReminder, Title: "Test"})
+	s.Require().NoError(err)
+	channels, err := s.db.ListNotificationChannels(s.userID)
+	s.Require().NoError(err)
+	s.Empty(channels, "gone channels are removed")
+
+	deliveries, err := s.db.ListNotificationDeliveries(s.userID, 10)
+	s.Require().NoError(err)
+	s.Len(deliveries, 2)
+
+	failure, err = s.notifier.SendTo(context.Background(), models.NotificationChannel{UserID: s.userID, Kind: models.ChannelEmail}, Notification{Title: "Test"})
+	s.Require().NoError(err)
+	s.Contains(failure, "isn't set up", "no sender for the kind")
+}
+
+func (s *NotifyTestSuite) TestCheck() {
+	prefs := models.DefaultPreferences()
+	prefs.Timezone = "UTC"
+	prefs.Budget = 100
+	prefs.WeeklyDigest = true
+	s.Require().NoError(s.db.SavePreferences(s.userID, prefs))
+	s.Require().NoError(s.db.CreateExpense(70, "Groceries", "Food", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(50, "Dinner", "Eating Out", time.Date(2026, 3, 6, 20, 0, 0, 0, time.UTC), s.userID))
+	_, err := s.db.CreateRecurring(s.userID, 900, "Rent", "Housing", storage.Monthly, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	_, err = s.db.CreateRecurring(s.userID, 12, "Streaming", "Entertainment", storage.Monthly, time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.notifier.Check(context.Background()))
+	s.ElementsMatch([]string{"Budget reached", "Upcoming: Rent", "Your week: March 2 to March 8"}, s.sender.titles())
+	for _, n := range s.sender.sent {
+		switch n.Event {
+		case models.EventBudgetAlert:
+			s.Equal("You've spent 120.00 EUR since March 1, over your budget of 100.00 EUR.", n.Body)
+			s.Equal("https://expenses.example.com/expenses", n.URL)
+		case models.EventWeeklyDigest:
+			s.Contains(n.Body, "2 expenses totalling 120.00 EUR")
+			s.Equal("https://expenses.example.com/expenses?from=2026-03-02&to=2026-03-08", n.URL)
+		}
+	}
+
+	s.Require().NoError(s.notifier.Check(context.Background()))
+	s.Len(s.sender.sent, 3, "nothing is sent twice")
+}
+
+func (s *NotifyTestSuite) TestDigestOptIn() {
+	s.Require().NoError(s.db.CreateExpense(70, "Groceries", "Food", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.notifier.Check(context.Background()))
+	s.Empty(s.sender.sent, "not opted in")
+
+	prefs := models.DefaultPreferences()
+	prefs.WeeklyDigest = true
+	s.Require().NoError(s.db.SavePreferences(s.userID, prefs))
+	s.Require().NoError(s.notifier.Check(context.Background()))
+	s.Equal([]string{"Your week: March 2 to March 8"}, s.sender.titles())
+}
+
+func (s *NotifyTestSuite) TestDigest() {
+	prefs := models.DefaultPreferences()
+	prefs.Timezone = "UTC"
+	prefs.CycleStart = 25
+	prefs.Budget = 900
+	s.Require().NoError(s.db.SavePreferences(s.userID, prefs))
+	s.Require().NoError(s.db.CreateExpense(600, "Rent", "Housing", time.Date(2026, 2, 26, 9, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(30, "Groceries", "Food", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(12, "Market", "Food", time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(20, "Dinner", "Eating Out", time.Date(2026, 3, 6, 20, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(7, "Cinema", "Entertainment", time.Date(2026, 3, 7, 20, 0, 0, 0, time.UTC), s.userID))
+	s.Require().NoError(s.db.CreateExpense(3, "Bus", "Transport", time.Date(2026, 3, 8, 8, 0, 0, 0, time.UTC), s.userID))
+
+	msg, err := s.notifier.Digest(s.userID)
+	s.Require().NoError(err)
+	s.Equal("Your week: March 2 to March 8", msg.Title)
+	s.Equal("5 expenses totalling 72.00 EUR, 10.29 EUR a day. The largest was Groceries (30.00 EUR).\n"+
+		"Top categories: Food 42.00 EUR, Eating Out 20.00 EUR, Entertainment 7.00 EUR.\n"+
+		"Budget: 672.00 EUR of 900.00 EUR spent since February 25, 228.00 EUR left.", msg.Body)
+	s.Empty(s.sender.sent, "only previewed")
+
+	s.Require().NoError(s.db.CreateExpense(300, "Flight", "Travel", time.Date(2026, 3, 9, 7, 0, 0, 0, time.UTC), s.userID))
+	msg, err = s.notifier.Digest(s.userID)
+	s.Require().NoError(err)
+	s.Contains(msg.Body, "Budget: 972.00 EUR spent since February 25, 72.00 EUR over your budget of 900.00 EUR.")
+
+	s.Require().NoError(s.db.SavePreferences(s.userID, models.DefaultPreferences()))
+	s.Require().NoError(s.db.ClearExpenses())
+	msg, err = s.notifier.Digest(s.userID)
+	s.Require().NoError(err)
+	s.Equal("You didn't add any expenses last week.", msg.Body, "nothing to report without a budget")
+}
+
+func (s *NotifyTestSuite) TestPeriodStart() {
+	s.Equal(time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), 25))
+	s.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), 0))
+}
+
+// TestNotifyTestSuite runs the notifier test suite
+func TestNotifyTestSuite(t *testing.T) {
+	suite.Run(t, new(NotifyTestSuite))
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// sendTimeout bounds each delivery to an ntfy server or push service.
+const sendTimeout = 30 * time.Second
+
+// defaultClient sends when Ntfy isn't given a client.
+var defaultClient = &http.Client{Timeout: sendTimeout}
+
+// ntfyTopic matches the topic names ntfy accepts.
+var ntfyTopic = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ntfyTags are the emoji ntfy shows with each event.
+var ntfyTags = map[string]string{
+	models.EventBudgetAlert:  "money_with_wings",
+	models.EventBillReminder: "calendar",
+	models.EventWeeklyDigest: "bar_chart",
+}
+
+// Ntfy posts notifications to ntfy topics, which the ntfy app on a phone
+// or desktop subscribes to. A channel's address is the topic's URL.
+type Ntfy struct {
+	Server string       // Where topics are, e.g. https://ntfy.sh
+	Client *http.Client // defaultClient when nil
+}
+
+// TopicURL returns the URL of a topic on the server, given by name or by
+// URL. Topics on other servers are refused, so users can't have the server
+// post to addresses of their choosing, such as ones on its own network.
+func (s *Ntfy) TopicURL(topic string) (string, error) {
+	topic = strings.TrimSpace(topic)
+	if ntfyTopic.MatchString(topic) {
+		topic = strings.TrimSuffix(s.Server, "/") + "/" + topic
+	}
+	u, err := url.Parse(topic)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", errors.New("notify: not a topic name or URL")
+	}
+	if name := strings.TrimPrefix(u.Path, "/"); !ntfyTopic.MatchString(name) {
+		return "", errors.New("notify: not a topic name or URL")
+	}
+	server, err := url.Parse(s.Server)
+	if err != nil || u.Scheme != server.Scheme || !strings.EqualFold(u.Host, server.Host) {
+		return "", errors.New("notify: topic isn't on the ntfy server")
+	}
+	return u.String(), nil
+}
+
+// Send posts msg to the channel's topic.
+func (s *Ntfy) Send(ctx context.Context, c models.NotificationChannel, msg Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Address, strings.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	// Headers are ASCII, ntfy decodes encoded words
+	req.Header.Set("Title", mime.BEncoding.Encode("UTF-8", msg.Title))
+	if tag, ok := ntfyTags[msg.Event]; ok {
+		req.Header.Set("Tags", tag)
+	}
+	if msg.URL != "" {
+		req.Header.Set("Click", msg.URL)
+	}
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("ntfy: %s", resp.Status)
+	}
+	return nil
+}
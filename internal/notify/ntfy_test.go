@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// NtfyTestSuite provides a test suite for the ntfy sender
+type NtfyTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+// SetupTest runs before each test
+func (s *NtfyTestSuite) SetupTest() {
+	s.requests, s.bodies, s.status = nil, nil, http.StatusOK
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		w.WriteHeader(s.status)
+	}))
+}
+
+// TearDownTest runs after each test
+func (s *NtfyTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *NtfyTestSuite) TestTopicURL() {
+	ntfy := &Ntfy{Server: "https://ntfy.sh/"}
+	topic, err := ntfy.TopicURL(" expenses_alice ")
+	s.Require().NoError(err)
+	s.Equal("https://ntfy.sh/expenses_alice", topic)
+
+	topic, err = ntfy.TopicURL("https://NTFY.sh/alice")
+	s.Require().NoError(err)
+	s.Equal("https://NTFY.sh/alice", topic, "by URL")
+
+	for _, bad := range []string{"", "two words", "ftp://ntfy.sh/alice", "https://ntfy.sh/", "https://ntfy.sh/a/b",
+		"https://ntfy.example.com/alice", "http://ntfy.sh/alice", "http://169.254.169.254/latest", "https://ntfy.sh:8443/alice"} {
+		_, err := ntfy.TopicURL(bad)
+		s.Error(err, bad)
+	}
+}
+
+func (s *NtfyTestSuite) TestSend() {
+	ntfy := &Ntfy{Server: s.server.URL}
+	topic, err := ntfy.TopicURL("alice")
+	s.Require().NoError(err)
+	channel := models.NotificationChannel{Kind: models.ChannelNtfy, Address: topic}
+
+	s.Require().NoError(ntfy.Send(context.Background(), channel, Notification{
+		Event: models.EventBudgetAlert,
+		Title: "Budget reached: 120 €",
+		Body:  "You've spent 120.00 EUR.",
+		URL:   "https://expenses.example.com/expenses",
+	}))
+	s.Require().Len(s.requests, 1)
+	s.Equal("/alice", s.requests[0].URL.Path)
+	s.Equal("You've spent 120.00 EUR.", s.bodies[0])
+	s.Equal("=?UTF-8?b?QnVkZ2V0IHJlYWNoZWQ6IDEyMCDigqw=?=", s.requests[0].Header.Get("Title"))
+	s.Equal("money_with_wings", s.requests[0].Header.Get("Tags"))
+	s.Equal("https://expenses.example.com/expenses", s.requests[0].Header.Get("Click"))
+
+	s.status = http.StatusTooManyRequests
+	s.ErrorContains(ntfy.Send(context.Background(), channel, Notification{Title: "Again"}), "429")
+}
+
+// TestNtfyTestSuite runs the ntfy sender test suite
+func TestNtfyTestSuite(t *testing.T) {
+	suite.Run(t, new(NtfyTestSuite))
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// billNotice is how long before a recurring expense is due its bill
+// reminder is sent.
+const billNotice = 3 * 24 * time.Hour
+
+// digestHour is the hour on Mondays, in the user's timezone, from which the
+// weekly digest of the week before is sent.
+const digestHour = 8
+
+// digestCategories is how many of the week's top categories the digest
+// lists.
+const digestCategories = 3
+
+// deliveryRetention is how long deliveries are kept in the log.
+const deliveryRetention = 90 * 24 * time.Hour
+
+// Check sends the notifications that are due to every user with channels:
+// an alert once spending in a budget period reaches the user's budget,
+// reminders of recurring expenses due within three days, and on Mondays a
+// digest of the week before to users who opted into it. Each is sent once per channel, so Check can run
+// as often as needed. It also forgets deliveries older than 90 days.
+func (n *Notifier) Check(ctx context.Context) error {
+	users, err := n.db.NotifiedUsers()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := n.checkUser(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+		}
+	}
+	if _, err := n.db.PurgeNotificationDeliveries(n.now().Add(-deliveryRetention)); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkUser sends the notifications due to one user.
+func (n *Notifier) checkUser(ctx context.Context, userID int64) error {
+	prefs, err := n.db.GetPreferences(userID)
+	if err != nil {
+		return err
+	}
+	now, ledger := n.userNow(prefs)
+
+	var errs []error
+	if prefs.Budget > 0 {
+		errs = append(errs, n.checkBudget(ctx, userID, prefs, ledger, now))
+	}
+	errs = append(errs, n.checkBills(ctx, userID, prefs, now))
+	if prefs.WeeklyDigest && now.Weekday() == time.Monday && now.Hour() >= digestHour {
+		errs = append(errs, n.sendDigest(ctx, userID, prefs, ledger, now))
+	}
+	return errors.Join(errs...)
+}
+
+// userNow returns the current time in the user's time zone, and the ledger
+// they picked, which their notifications are about.
+func (n *Notifier) userNow(prefs models.Preferences) (time.Time, int64) {
+	loc := time.Local
+	if prefs.Timezone != "" {
+		if l, err := time.LoadLocation(prefs.Timezone); err == nil {
+			loc = l
+		}
+	}
+	ledger := prefs.Ledger
+	if ledger == 0 {
+		ledger = models.PersonalLedgerID
+	}
+	return n.now().In(loc), ledger
+}
+
+// checkBudget alerts the user once spending in the current budget period
+// has reached their budget.
+func (n *Notifier) checkBudget(ctx context.Context, userID int64, prefs models.Preferences, ledger int64, now time.Time) error {
+	start := periodStart(now, prefs.CycleStart)
+	spent, err := n.db.GetTotalForCycle(ledger, start)
+	if err != nil {
+		return err
+	}
+	if spent < prefs.Budget {
+		return nil
+	}
+	return n.Send(ctx, userID, Notification{
+		Event: models.EventBudgetAlert,
+		Key:   "budget:" + start.Format(time.DateOnly),
+		Title: "Budget reached",
+		Body: fmt.Sprintf("You've spent %s since %s, over your budget of %s.",
+			amount(spent, prefs.Currency), start.Format("January 2"), amount(prefs.Budget, prefs.Currency)),
+		URL: n.url("/expenses"),
+	})
+}
+
+// checkBills reminds the user of their recurring expenses that are due soon.
+func (n *Notifier) checkBills(ctx context.Context, userID int64, prefs models.Preferences, now time.Time) error {
+	bills, err := n.db.ListRecurring(userID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, bill := range bills {
+		if bill.Paused || bill.NextDate.After(now.Add(billNotice)) {
+			continue
+		}
+		due := bill.NextDate.Format(time.DateOnly)
+		errs = append(errs, n.Send(ctx, userID, Notification{
+			Event: models.EventBillReminder,
+			Key:   fmt.Sprintf("bill:%d:%s", bill.ID, due),
+			Title: "Upcoming: " + bill.Description,
+			Body:  fmt.Sprintf("%s (%s) is due on %s.", bill.Description, amount(bill.Amount, prefs.Currency), bill.NextDate.Format("Monday, January 2")),
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// sendDigest sends the user a summary of the week before the one now is in.
+func (n *Notifier) sendDigest(ctx context.Context, userID int64, prefs models.Preferences, ledger int64, now time.Time) error {
+	msg, err := n.digest(prefs, ledger, now)
+	if err != nil {
+		return err
+	}
+	return n.Send(ctx, userID, msg)
+}
+
+// Digest returns the weekly digest the user would be sent now, whether or
+// not they opted into it, so they can preview it.
+func (n *Notifier) Digest(userID int64) (Notification, error) {
+	prefs, err := n.db.GetPreferences(userID)
+	if err != nil {
+		return Notification{}, err
+	}
+	now, ledger := n.userNow(prefs)
+	return n.digest(prefs, ledger, now)
+}
+
+// digest summarizes the week before the one now is in: what was spent, in
+// which categories most, and how the current budget period is going.
+func (n *Notifier) digest(prefs models.Preferences, ledger int64, now time.Time) (Notification, error) {
+	monday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monday = monday.AddDate(0, 0, -(int(monday.Weekday())+6)%7)
+	from := monday.AddDate(0, 0, -7)
+	week := storage.ExpenseFilter{From: from, To: monday, Ledger: ledger}
+	summary, err := n.db.SummarizeExpenses(week)
+	if err != nil {
+		return Notification{}, err
+	}
+	lines := []string{"You didn't add any expenses last week."}
+	if summary.Count > 0 {
+		lines[0] = fmt.Sprintf("%d expenses totalling %s, %s a day.",
+			summary.Count, amount(summary.Total, prefs.Currency), amount(summary.AveragePerDay, prefs.Currency))
+		if summary.Largest != nil {
+			lines[0] += fmt.Sprintf(" The largest was %s (%s).", summary.Largest.Description, amount(summary.Largest.Amount, prefs.Currency))
+		}
+		totals, err := n.db.CategoryTotals(week)
+		if err != nil {
+			return Notification{}, err
+		}
+		var top []string
+		for _, t := range totals[:min(len(totals), digestCategories)] {
+			top = append(top, t.Category+" "+amount(t.Total, prefs.Currency))
+		}
+		lines = append(lines, "Top categories: "+strings.Join(top, ", ")+".")
+	}
+	if prefs.Budget > 0 {
+		start := periodStart(now, prefs.CycleStart)
+		spent, err := n.db.GetTotalForCycle(ledger, start)
+		if err != nil {
+			return Notification{}, err
+		}
+		since := start.Format("January 2")
+		if spent > prefs.Budget {
+			lines = append(lines, fmt.Sprintf("Budget: %s spent since %s, %s over your budget of %s.",
+				amount(spent, prefs.Currency), since, amount(spent-prefs.Budget, prefs.Currency), amount(prefs.Budget, prefs.Currency)))
+		} else {
+			lines = append(lines, fmt.Sprintf("Budget: %s of %s spent since %s, %s left.",
+				amount(spent, prefs.Currency), amount(prefs.Budget, prefs.Currency), since, amount(prefs.Budget-spent, prefs.Currency)))
+		}
+	}
+
+	sunday := monday.AddDate(0, 0, -1)
+	return Notification{
+		Event: models.EventWeeklyDigest,
+		Key:   "digest:" + monday.Format(time.DateOnly),
+		Title: "Your week: " + from.Format("January 2") + " to " + sunday.Format("January 2"),
+		Body:  strings.Join(lines, "\n"),
+		URL:   n.url("/expenses?from=" + from.Format(time.DateOnly) + "&to=" + sunday.Format(time.DateOnly)),
+	}, nil
+}
+
+// periodStart returns the start of the budget period now is in, for periods
+// starting on the given day of the month.
+func periodStart(now time.Time, day int) time.Time {
+	day = max(1, min(day, 28))
+	start := time.Date(now.Year(), now.Month(), day, 0, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// amount formats an amount of money for a notification.
+func amount(v float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", v, currency)
+}
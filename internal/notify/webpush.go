@@ -0,0 +1,278 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// pushTTL is how long push services keep a notification for a browser
+// that's offline.
+const pushTTL = 24 * time.Hour
+
+// pushRecordSize is the record size of the encrypted payload. Payloads fit
+// in a single record.
+const pushRecordSize = 4096
+
+// WebPush pushes notifications to browsers that subscribed with its public
+// key (RFC 8030), encrypted for each browser (RFC 8291) and signed with the
+// key (VAPID, RFC 8292). A channel's address is the push service endpoint
+// of the subscription, along with its keys.
+type WebPush struct {
+	Key     *ecdsa.PrivateKey // P-256
+	Subject string            // Contact for push services, a mailto: or https: URL; optional
+	Client  *http.Client      // pushClient when nil
+	// Resolves the hosts of endpoints, net.DefaultResolver when nil
+	LookupIP func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// ErrPrivateEndpoint is returned for push endpoints on addresses that aren't
+// public, such as ones on the server's own network.
+var ErrPrivateEndpoint = errors.New("webpush: endpoint isn't on a public address")
+
+// pushClient sends when WebPush isn't given a client. Browsers pick the
+// endpoints, so it only connects to public addresses, whatever their hosts
+// resolve to by then.
+var pushClient = &http.Client{
+	Timeout: sendTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: sendTimeout, Control: publicOnly}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip.Addr.IsPrivate leaves out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether ip is on the internet rather than loopback,
+// private, link-local, or otherwise not routed there.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// publicOnly is a net.Dialer Control that refuses connections to addresses
+// that aren't public.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addr.Addr()) {
+		return ErrPrivateEndpoint
+	}
+	return nil
+}
+
+// CheckEndpoint returns an error unless endpoint is an https URL whose host
+// only resolves to public addresses. Sending checks each connection again,
+// so a host that later resolves elsewhere still isn't reached.
+func (s *WebPush) CheckEndpoint(ctx context.Context, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("webpush: invalid endpoint")
+	}
+	lookup := s.LookupIP
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		}
+	}
+	addrs, err := lookup(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return ErrPrivateEndpoint
+		}
+	}
+	return nil
+}
+
+// NewWebPush creates a WebPush signing with the P-256 private key encoded in
+// key, such as a stored random secret.
+func NewWebPush(key []byte, subject string) (*WebPush, error) {
+	private, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), key)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: %w", err)
+	}
+	return &WebPush{Key: private, Subject: subject}, nil
+}
+
+// PublicKey returns the key browsers subscribe with, base64url encoded.
+func (s *WebPush) PublicKey() string {
+	public, err := s.Key.PublicKey.Bytes()
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(public)
+}
+
+// pushPayload is what the service worker gets.
+type pushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Send pushes msg to the channel's browser. It returns ErrGone when the push
+// service says the subscription has expired.
+func (s *WebPush) Send(ctx context.Context, c models.NotificationChannel, msg Notification) error {
+	endpoint, err := url.Parse(c.Address)
+	if err != nil || endpoint.Scheme != "https" {
+		return errors.New("webpush: invalid endpoint")
+	}
+	payload, err := json.Marshal(pushPayload{Title: msg.Title, Body: msg.Body, URL: msg.URL})
+	if err != nil {
+		return err
+	}
+	body, err := encryptPush(c.P256dh, c.Auth, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.vapid(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+	req.Header.Set("Authorization", authorization)
+	client := s.Client
+	if client == nil {
+		client = pushClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("webpush: %s", resp.Status)
+	}
+	return nil
+}
+
+// vapid returns the Authorization header identifying the server to the push
+// service at audience, with a token valid for 12 hours.
+func (s *WebPush) vapid(audience string) (string, error) {
+	claims := map[string]any{"aud": audience, "exp": time.Now().Add(12 * time.Hour).Unix()}
+	if s.Subject != "" {
+		claims["sub"] = s.Subject
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.Key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("webpush: %w", err)
+	}
+	// JWS signatures are r and s, 32 bytes each
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, s.PublicKey()), nil
+}
+
+// encryptPush encrypts payload for the browser with the subscription's keys,
+// as a single aes128gcm record (RFC 8291 and RFC 8188).
+func encryptPush(p256dh, auth string, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := base64.RawURLEncoding.DecodeString(p256dh)
+	if err != nil {
+		return nil, errors.New("webpush: invalid p256dh key")
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, errors.New("webpush: invalid p256dh key")
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, errors.New("webpush: invalid auth secret")
+	}
+
+	// A key pair of our own for each message
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: %w", err)
+	}
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, "WebPush: info\x00"+string(uaPublicBytes)+string(asPublic), 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The header: salt, record size, and our public key
+	body := make([]byte, 0, 16+4+1+len(asPublic)+len(payload)+1+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, pushRecordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+	// The payload ends with the delimiter of the last record
+	return gcm.Seal(body, nonce, append(payload, 2), nil), nil
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// WebPushTestSuite provides a test suite for the Web Push sender
+type WebPushTestSuite struct {
+	suite.Suite
+	push    *WebPush
+	browser *ecdh.PrivateKey
+	auth    []byte
+	channel models.NotificationChannel
+}
+
+// SetupTest runs before each test
+func (s *WebPushTestSuite) SetupTest() {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	s.Require().NoError(err)
+	s.push, err = NewWebPush(key, "mailto:admin@example.com")
+	s.Require().NoError(err)
+
+	// The browser's subscription
+	s.browser, err = ecdh.P256().GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	s.auth = make([]byte, 16)
+	_, err = rand.Read(s.auth)
+	s.Require().NoError(err)
+	s.channel = models.NotificationChannel{
+		Kind:   models.ChannelWebPush,
+		P256dh: base64.RawURLEncoding.EncodeToString(s.browser.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(s.auth),
+	}
+}
+
+// decrypt decrypts a push message the way the browser does.
+func (s *WebPushTestSuite) decrypt(body []byte) []byte {
+	s.Require().Greater(len(body), 86)
+	salt, keyLen := body[:16], int(body[20])
+	s.Equal(uint32(pushRecordSize), binary.BigEndian.Uint32(body[16:20]))
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+keyLen])
+	s.Require().NoError(err)
+
+	shared, err := s.browser.ECDH(serverKey)
+	s.Require().NoError(err)
+	info := "WebPush: info\x00" + string(s.browser.PublicKey().Bytes()) + string(serverKey.Bytes())
+	ikm, err := hkdf.Key(sha256.New, shared, s.auth, info, 32)
+	s.Require().NoError(err)
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	s.Require().NoError(err)
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	s.Require().NoError(err)
+	block, err := aes.NewCipher(cek)
+	s.Require().NoError(err)
+	gcm, err := cipher.NewGCM(block)
+	s.Require().NoError(err)
+	plaintext, err := gcm.Open(nil, nonce, body[21+keyLen:], nil)
+	s.Require().NoError(err)
+	s.Equal(byte(2), plaintext[len(plaintext)-1], "the last record")
+	return plaintext[:len(plaintext)-1]
+}
+
+func (s *WebPushTestSuite) TestSend() {
+	var body []byte
+	var header http.Header
+	status := http.StatusCreated
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	s.push.Client = server.Client()
+	s.channel.Address = server.URL + "/push/abc"
+
+	s.Require().NoError(s.push.Send(context.Background(), s.channel, Notification{Title: "Budget reached", Body: "You've spent 120.00 EUR.", URL: "https://expenses.example.com/expenses"}))
+	s.Equal("aes128gcm", header.Get("Content-Encoding"))
+	s.Equal("86400", header.Get("TTL"))
+	var payload map[string]string
+	s.Require().NoError(json.Unmarshal(s.decrypt(body), &payload))
+	s.Equal(map[string]string{"title": "Budget reached", "body": "You've spent 120.00 EUR.", "url": "https://expenses.example.com/expenses"}, payload)
+
+	// The VAPID token is signed with the key browsers subscribed with
+	token, key, ok := strings.Cut(strings.TrimPrefix(header.Get("Authorization"), "vapid t="), ", k=")
+	s.Require().True(ok)
+	s.Equal(s.push.PublicKey(), key)
+	parts := strings.Split(token, ".")
+	s.Require().Len(parts, 3)
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	s.Require().NoError(err)
+	s.Contains(string(claims), `"aud":"`+server.URL+`"`)
+	s.Contains(string(claims), `"sub":"mailto:admin@example.com"`)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	s.Require().NoError(err)
+	s.Require().Len(signature, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, sig := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	s.True(ecdsa.Verify(&s.push.Key.PublicKey, digest[:], r, sig))
+
+	status = http.StatusGone
+	s.ErrorIs(s.push.Send(context.Background(), s.channel, Notification{Title: "Again"}), ErrGone)
+}
+
+func (s *WebPushTestSuite) TestInvalidSubscription() {
+	s.channel.Address = "http://push.example.com/abc"
+	s.ErrorContains(s.push.Send(context.Background(), s.channel, Notification{}), "invalid endpoint")
+	s.channel.Address = "https://push.example.com/abc"
+	s.channel.Auth = "short"
+	s.ErrorContains(s.push.Send(context.Background(), s.channel, Notification{}), "invalid auth secret")
+	s.channel.P256dh = "bm90IGEga2V5"
+	s.ErrorContains(s.push.Send(context.Background(), s.channel, Notification{}), "invalid p256dh key")
+}
+
+func (s *WebPushTestSuite) TestCheckEndpoint() {
+	hosts := map[string]string{
+		"push.example.com":     "203.0.113.7",
+		"internal.example.com": "10.0.0.5",
+		"metadata.example.com": "169.254.169.254",
+		"cgnat.example.com":    "100.64.0.1",
+		"local.example.com":    "::1",
+		"mapped.example.com":   "::ffff:127.0.0.1",
+	}
+	s.push.LookupIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr(hosts[host])}, nil
+	}
+	s.NoError(s.push.CheckEndpoint(context.Background(), "https://push.example.com/send/abc"))
+	for host := range hosts {
+		if host != "push.example.com" {
+			s.ErrorIs(s.push.CheckEndpoint(context.Background(), "https://"+host+"/send/abc"), ErrPrivateEndpoint, host)
+		}
+	}
+	s.ErrorContains(s.push.CheckEndpoint(context.Background(), "http://push.example.com/send/abc"), "invalid endpoint")
+
+	// Without a client of its own, sending doesn't connect to the server's
+	// network whatever the endpoint resolves to
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Fail("reached a loopback address")
+	}))
+	defer server.Close()
+	s.push.Client = nil
+	s.channel.Address = server.URL + "/push/abc"
+	s.ErrorIs(s.push.Send(context.Background(), s.channel, Notification{Title: "Budget reached"}), ErrPrivateEndpoint)
+}
+
+// TestWebPushTestSuite runs the Web Push sender test suite
+func TestWebPushTestSuite(t *testing.T) {
+	suite.Run(t, new(WebPushTestSuite))
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScheduleAccountDeletion marks a user's account for deletion at the given
+// time and ends all of its sessions. Until then the account is kept, and
+// CancelAccountDeletion restores it.
+func (db *DB) ScheduleAccountDeletion(userID int64, at time.Time) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("UPDATE users SET delete_after = ? WHERE id = ?", at, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelAccountDeletion keeps an account that was scheduled for deletion. It
+// reports whether a deletion was pending.
+func (db *DB) CancelAccountDeletion(userID int64) (bool, error) {
+	result, err := db.conn.Exec("UPDATE users SET delete_after = NULL WHERE id = ? AND delete_after IS NOT NULL", userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// PurgeDeletedAccounts permanently deletes the accounts whose scheduled
+// deletion time has passed, together with their expenses, sessions, passkeys,
+// login history, and invites. It returns the number of accounts deleted.
+func (db *DB) PurgeDeletedAccounts() (int64, error) {
+	rows, err := db.conn.Query("SELECT id FROM users WHERE delete_after <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		if err := db.purgeAccount(id, 0); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+// DeleteUser deletes an account right away, without a grace period. With an
+// heir, the account's expenses are given to the heir instead of being deleted.
+func (db *DB) DeleteUser(userID, heirID int64) error {
+	return db.purgeAccount(userID, heirID)
+}
+
+// DisableUser keeps an account and its data but ends its sessions, and login
+// refuses it until EnableUser.
+func (db *DB) DisableUser(userID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("UPDATE users SET disabled_at = ? WHERE id = ?", time.Now(), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EnableUser lets a disabled account log in again. It reports whether the
+// account was disabled.
+func (db *DB) EnableUser(userID int64) (bool, error) {
+	n, err := rowsAffected(db.conn.Exec("UPDATE users SET disabled_at = NULL WHERE id = ? AND disabled_at IS NOT NULL", userID))
+	return n > 0, err
+}
+
+// UserExpenseCount returns how many current and archived expenses a user has.
+func (db *DB) UserExpenseCount(userID int64) (int, error) {
+	var count int
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM all_expenses WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+// purgeAccount deletes one account and everything that belongs to it in a
+// single transaction. If heirID isn't zero, the expenses and ledgers go to
+// that user instead, which leaves the statistics unchanged.
+func (db *DB) purgeAccount(userID, heirID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if heirID != 0 {
+		// Ledger names are unique per owner, so ones the heir sees a ledger
+		// named like get the account's name added
+		if _, err := tx.Exec(
+			`UPDATE ledgers SET name = name || ' (' || (SELECT username FROM users WHERE id = ?1) || ')'
+			WHERE owner_id = ?1 AND name IN (SELECT name FROM ledgers WHERE owner_id IS NULL OR owner_id = ?2)`,
+			userID, heirID,
+		); err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			"UPDATE expenses SET user_id = ? WHERE user_id = ?",
+			"UPDATE archived_expenses SET user_id = ? WHERE user_id = ?",
+			"UPDATE ledgers SET owner_id = ? WHERE owner_id = ?",
+		} {
+			if _, err := tx.Exec(stmt, heirID, userID); err != nil {
+				return err
+			}
+		}
+	}
+	days, err := userExpenseDays(tx, userID)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"DELETE FROM expenses WHERE user_id = ?",
+		"DELETE FROM archived_expenses WHERE user_id = ?",
+		"DELETE FROM deleted_expenses WHERE user_id = ?",
+		"DELETE FROM recurring_expenses WHERE user_id = ?",
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM passkeys WHERE user_id = ?",
+		"DELETE FROM user_settings WHERE user_id = ?",
+		"DELETE FROM avatars WHERE user_id = ?",
+		"DELETE FROM login_events WHERE user_id = ?",
+		"DELETE FROM notification_channels WHERE user_id = ?",
+		"DELETE FROM notification_deliveries WHERE user_id = ?",
+		"DELETE FROM challenges WHERE user_id = ?",
+		"DELETE FROM spending_streaks WHERE user_id = ?",
+		"DELETE FROM guest_tokens WHERE user_id = ?",
+		// Only the owner's expenses can be in their ledgers
+		"DELETE FROM guest_tokens WHERE ledger_id IN (SELECT id FROM ledgers WHERE owner_id = ?)",
+		"DELETE FROM ledgers WHERE owner_id = ?",
+		"DELETE FROM invites WHERE created_by = ?",
+		"UPDATE invites SET used_by = NULL WHERE used_by = ?",
+		"DELETE FROM users WHERE id = ?",
+	} {
+		if _, err := tx.Exec(stmt, userID); err != nil {
+			return err
+		}
+	}
+	if err := forgetOrphanDetails(tx); err != nil {
+		return err
+	}
+	if err := refreshAggregates(tx, days...); err != nil {
+		return err
+	}
+
+	// Someone has to be able to invite people, so if the last admin left,
+	// the oldest remaining account takes over
+	if _, err := tx.Exec(
+		`UPDATE users SET role = 'admin'
+		WHERE id = (SELECT MIN(id) FROM users WHERE disabled_at IS NULL) AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin')`,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// userExpenseDays returns the days a user has current or archived expenses
+// on.
+func userExpenseDays(tx *sql.Tx, userID int64) ([]string, error) {
+	rows, err := tx.Query("SELECT DISTINCT SUBSTR(date, 1, 10) FROM all_expenses WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []string
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
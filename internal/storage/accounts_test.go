@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AccountTestSuite provides a test suite for account deletion
+type AccountTestSuite struct {
+	suite.Suite
+	db    *DB
+	admin *models.User
+	alice *models.User
+}
+
+// SetupTest runs before each test
+func (s *AccountTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+
+	s.admin, err = s.db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+	s.alice, err = s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+}
+
+// TearDownTest runs after each test
+func (s *AccountTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *AccountTestSuite) TestScheduleAndCancel() {
+	s.Require().NoError(s.db.CreateSession("alice-session", s.alice.ID, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("admin-session", s.admin.ID, time.Hour, ""))
+
+	s.Require().NoError(s.db.ScheduleAccountDeletion(s.alice.ID, time.Now().Add(time.Hour)))
+
+	_, err := s.db.ValidateSession("alice-session")
+	s.Error(err, "scheduling deletion signs the user out")
+	_, err = s.db.ValidateSession("admin-session")
+	s.NoError(err)
+	alice, err := s.db.GetUserByID(s.alice.ID)
+	s.Require().NoError(err)
+	s.NotNil(alice.DeleteAfter)
+
+	cancelled, err := s.db.CancelAccountDeletion(s.alice.ID)
+	s.Require().NoError(err)
+	s.True(cancelled)
+	cancelled, err = s.db.CancelAccountDeletion(s.alice.ID)
+	s.Require().NoError(err)
+	s.False(cancelled, "nothing left to cancel")
+
+	alice, err = s.db.GetUserByID(s.alice.ID)
+	s.Require().NoError(err)
+	s.Nil(alice.DeleteAfter)
+}
+
+func (s *AccountTestSuite) TestPurgeDeletedAccounts() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", day, s.alice.ID))
+	s.Require().NoError(s.db.CreateExpense(20, "Bus", "Transport", day, s.admin.ID))
+	s.Require().NoError(s.db.CreatePasskey(&models.Passkey{ID: "cred", UserID: s.alice.ID, Name: "Phone", PublicKey: []byte{1}}))
+	s.Require().NoError(s.db.RecordLogin(&s.alice.ID, "alice", "192.0.2.1", "", true))
+
+	// Not yet due
+	s.Require().NoError(s.db.ScheduleAccountDeletion(s.alice.ID, time.Now().Add(time.Hour)))
+	purged, err := s.db.PurgeDeletedAccounts()
+	s.Require().NoError(err)
+	s.Zero(purged)
+
+	s.Require().NoError(s.db.ScheduleAccountDeletion(s.alice.ID, time.Now().Add(-time.Minute)))
+	purged, err = s.db.PurgeDeletedAccounts()
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	_, err = s.db.GetUserByID(s.alice.ID)
+	s.Error(err)
+	expenses, err := s.db.GetExpensesByMonth(2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1, "other users' expenses are kept")
+	s.Equal("Bus", expenses[0].Description)
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(20, total, 0.001, "aggregates are refreshed")
+	_, err = s.db.GetPasskey("cred")
+	s.Error(err)
+	events, err := s.db.ListLoginEvents(s.alice.ID, 10)
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *AccountTestSuite) TestPurgeLastAdminPromotesOldestUser() {
+	s.Require().NoError(s.db.ScheduleAccountDeletion(s.admin.ID, time.Now().Add(-time.Minute)))
+	_, err := s.db.PurgeDeletedAccounts()
+	s.Require().NoError(err)
+
+	alice, err := s.db.GetUserByID(s.alice.ID)
+	s.Require().NoError(err)
+	s.True(alice.IsAdmin())
+}
+
+func (s *AccountTestSuite) TestDeleteUserReassignsExpenses() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", day, s.alice.ID))
+	_, err := s.db.ArchiveExpenses(day.AddDate(0, 0, 1))
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateExpense(20, "Bus", "Transport", day, s.alice.ID))
+
+	s.Require().NoError(s.db.DeleteUser(s.alice.ID, s.admin.ID))
+
+	_, err = s.db.GetUserByID(s.alice.ID)
+	s.Error(err)
+	count, err := s.db.UserExpenseCount(s.admin.ID)
+	s.Require().NoError(err)
+	s.Equal(2, count, "current and archived expenses go to the heir")
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(30, total, 0.001)
+}
+
+func (s *AccountTestSuite) TestDisableAndEnable() {
+	s.Require().NoError(s.db.CreateSession("alice-session", s.alice.ID, time.Hour, ""))
+
+	s.Require().NoError(s.db.DisableUser(s.alice.ID))
+	alice, err := s.db.GetUserByID(s.alice.ID)
+	s.Require().NoError(err)
+	s.True(alice.Disabled)
+	_, err = s.db.ValidateSession("alice-session")
+	s.Error(err, "disabling signs the user out")
+
+	enabled, err := s.db.EnableUser(s.alice.ID)
+	s.Require().NoError(err)
+	s.True(enabled)
+	enabled, err = s.db.EnableUser(s.alice.ID)
+	s.Require().NoError(err)
+	s.False(enabled, "already enabled")
+	alice, err = s.db.GetUserByID(s.alice.ID)
+	s.Require().NoError(err)
+	s.False(alice.Disabled)
+}
+
+// TestAccountSuite runs the account test suite
+func TestAccountSuite(t *testing.T) {
+	suite.Run(t, new(AccountTestSuite))
+}
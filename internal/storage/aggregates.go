@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// execer is satisfied by both the queryRunner of a DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// refreshAggregates recomputes the daily_aggregates rows for the given days
+// (formatted as YYYY-MM-DD) from the current and archived expenses.
+func refreshAggregates(ex execer, days ...string) error {
+	spans, err := daySpans(days)
+	if err != nil {
+		return err
+	}
+	for _, span := range spans {
+		if _, err := ex.Exec("DELETE FROM daily_aggregates WHERE day >= ? AND day < ?", span[0], span[1]); err != nil {
+			return err
+		}
+		// Expense dates are stored as ISO 8601 text, so a string range on the
+		// date prefix selects whole days and can use the date indexes.
+		_, err := ex.Exec(
+			`INSERT INTO daily_aggregates (ledger_id, day, category, total, count)
+			 SELECT ledger_id, SUBSTR(date, 1, 10), category, SUM(amount), COUNT(*)
+			 FROM all_expenses
+			 WHERE date >= ? AND date < ?
+			 GROUP BY ledger_id, SUBSTR(date, 1, 10), category`,
+			span[0], span[1],
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spanGap is how many untouched days may lie between two days that are
+// still refreshed together. Recomputing a few extra days costs less than
+// the two statements a separate span needs.
+const spanGap = 7
+
+// daySpans sorts days into the fewest [start, end) ranges covering them,
+// merging days at most spanGap apart. Empty days are ignored.
+func daySpans(days []string) ([][2]string, error) {
+	dates := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		if day == "" {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	slices.SortFunc(dates, time.Time.Compare)
+
+	var spans [][2]string
+	for i := 0; i < len(dates); {
+		start, last := dates[i], dates[i]
+		for i++; i < len(dates) && dates[i].Sub(last) <= spanGap*24*time.Hour; i++ {
+			last = dates[i]
+		}
+		spans = append(spans, [2]string{start.Format(time.DateOnly), last.AddDate(0, 0, 1).Format(time.DateOnly)})
+	}
+	return spans, nil
+}
+
+// expenseDay returns the YYYY-MM-DD day an expense is stored under.
+func expenseDay(ex execer, id int64) (string, error) {
+	var day string
+	err := ex.QueryRow("SELECT SUBSTR(date, 1, 10) FROM expenses WHERE id = ?", id).Scan(&day)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return day, err
+}
+
+// RebuildAggregates discards and recomputes the whole daily_aggregates table
+// from the current and archived expenses. It returns the number of aggregate
+// rows written.
+func (db *DB) RebuildAggregates() (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM daily_aggregates"); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(
+		`INSERT INTO daily_aggregates (ledger_id, day, category, total, count)
+		 SELECT ledger_id, SUBSTR(date, 1, 10), category, SUM(amount), COUNT(*)
+		 FROM all_expenses
+		 GROUP BY ledger_id, SUBSTR(date, 1, 10), category`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
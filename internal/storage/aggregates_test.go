@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AggregatesTestSuite provides a test suite for the statistics aggregates table
+type AggregatesTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *AggregatesTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *AggregatesTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *AggregatesTestSuite) TestCreateUpdatesAggregates() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "eating out", day, 1))
+	s.Require().NoError(s.db.CreateExpense(15, "Lunch", "eating out", day.Add(time.Hour), 1))
+
+	daily, err := s.db.GetDailyTotalsForMonth(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(daily, 1)
+	s.Equal(10, daily[0].Day)
+	s.InDelta(25.0, daily[0].Total, 0.001)
+}
+
+func (s *AggregatesTestSuite) TestUpdateMovesAggregatesBetweenDays() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "eating out", day, 1))
+
+	expenses, err := s.db.GetExpensesByMonth(2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+
+	s.Require().NoError(s.db.UpdateExpense(&models.Expense{
+		ID: expenses[0].ID, Amount: 12, Description: "Coffee", Category: "groceries", Date: day.AddDate(0, 1, 0),
+	}))
+
+	march, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(0.0, march, 0.001)
+
+	april, err := s.db.GetCategoryTotalsByMonth(0, 2026, 4)
+	s.Require().NoError(err)
+	s.Require().Len(april, 1)
+	s.Equal("groceries", april[0].Category)
+	s.InDelta(12.0, april[0].Total, 0.001)
+}
+
+func (s *AggregatesTestSuite) TestDeleteUpdatesAggregates() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "eating out", day, 1))
+
+	expenses, err := s.db.GetExpensesByMonth(2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Require().NoError(s.db.DeleteExpense(expenses[0].ID))
+
+	totals, err := s.db.GetCategoryTotalsByYear(0, 2026)
+	s.Require().NoError(err)
+	s.Empty(totals)
+}
+
+func (s *AggregatesTestSuite) TestRebuildAggregates() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "eating out", day, 1))
+	s.Require().NoError(s.db.CreateExpense(20, "Bus", "transport", day.AddDate(0, 0, 1), 1))
+
+	// Simulate drift by wiping the aggregates behind the storage layer's back
+	_, err := s.db.conn.Exec("DELETE FROM daily_aggregates")
+	s.Require().NoError(err)
+
+	rows, err := s.db.RebuildAggregates()
+	s.Require().NoError(err)
+	s.Equal(int64(2), rows)
+
+	total, err := s.db.GetTotalForPeriod(0, 2026, 0)
+	s.Require().NoError(err)
+	s.InDelta(30.0, total, 0.001)
+}
+
+func (s *AggregatesTestSuite) TestImportUpdatesAggregates() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(5, "Bread", "groceries", day.AddDate(0, 0, 2), 1))
+	added, err := s.db.ImportExpenses([]models.Expense{
+		{Amount: 10, Description: "Coffee", Category: "eating out", Date: day},
+		{Amount: 20, Description: "Bus", Category: "transport", Date: day.AddDate(0, 0, 4)},
+		{Amount: 30, Description: "Rent", Category: "housing", Date: day.AddDate(0, 2, 0)},
+	}, 1, false)
+	s.Require().NoError(err)
+	s.Equal(3, added)
+
+	// Days between the imported ones keep their totals
+	daily, err := s.db.GetDailyTotalsForMonth(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(daily, 3)
+	total, err := s.db.GetTotalForPeriod(0, 2026, 0)
+	s.Require().NoError(err)
+	s.InDelta(65.0, total, 0.001)
+}
+
+func (s *AggregatesTestSuite) TestDaySpans() {
+	spans, err := daySpans([]string{"2026-03-20", "", "2026-03-10", "2026-03-12", "2026-03-10", "2026-05-01"})
+	s.Require().NoError(err)
+	s.Equal([][2]string{
+		{"2026-03-10", "2026-03-13"},
+		{"2026-03-20", "2026-03-21"},
+		{"2026-05-01", "2026-05-02"},
+	}, spans)
+
+	spans, err = daySpans(nil)
+	s.Require().NoError(err)
+	s.Empty(spans)
+	_, err = daySpans([]string{"10/03/2026"})
+	s.Error(err)
+}
+
+// Test suite runner
+func TestAggregatesSuite(t *testing.T) {
+	suite.Run(t, new(AggregatesTestSuite))
+}
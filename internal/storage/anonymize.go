@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"database/sql"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"unicode"
+)
+
+// Anonymize scrambles everything personal in the database so a copy of it
+// can be shared, e.g. with a bug report. Usernames become user<id>, project
+// names project<id>, and ledger names ledger<id>, and passwords, email
+// addresses, sessions, guest links, passkeys, login history, invites,
+// notification channels and deliveries, secrets, avatars, and deleted
+// expenses are removed.
+// Descriptions keep their shape but get random letters and digits, with
+// equal descriptions staying equal. Amounts are changed by up to jitter, as
+// a fraction of the amount. It is meant for a copy, never for the database
+// the server uses.
+func (db *DB) Anonymize(jitter float64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range []string{
+		`UPDATE users SET username = 'user' || id, password_hash = '', email = NULL, email_verified_at = NULL,
+			failed_logins = 0, locked_until = NULL`,
+		"UPDATE projects SET name = 'project' || id",
+		"UPDATE ledgers SET name = 'ledger' || id",
+		"DELETE FROM sessions",
+		"DELETE FROM guest_tokens",
+		"DELETE FROM passkeys",
+		"DELETE FROM login_events",
+		"DELETE FROM invites",
+		"DELETE FROM secrets",
+		"DELETE FROM expense_locations",
+		"DELETE FROM notification_channels",
+		"DELETE FROM notification_deliveries",
+		"DELETE FROM deleted_expenses",
+		"DELETE FROM avatars",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"expenses", "archived_expenses", "recurring_expenses"} {
+		if err := scrambleDescriptions(tx, table); err != nil {
+			return err
+		}
+		if err := jitterAmounts(tx, table, jitter); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if _, err := db.RebuildAggregates(); err != nil {
+		return err
+	}
+	// Deleted and overwritten data stays in free pages until the file is
+	// rewritten
+	_, err = db.conn.Exec("VACUUM")
+	return err
+}
+
+// scrambleDescriptions replaces every description in a table with a
+// scrambled one, the same for equal descriptions.
+func scrambleDescriptions(tx *sql.Tx, table string) error {
+	descriptions, err := queryStrings(tx, "SELECT DISTINCT description FROM "+table)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool, len(descriptions))
+	for _, description := range descriptions {
+		scrambled := scramble(description)
+		// Short descriptions can come out the same, which would merge them
+		for tries := 0; used[scrambled] && tries < 10; tries++ {
+			scrambled = scramble(description)
+		}
+		used[scrambled] = true
+		if _, err := tx.Exec("UPDATE "+table+" SET description = ? WHERE description = ?", scrambled, description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scramble replaces letters and digits with random ones of the same kind,
+// keeping spaces and punctuation.
+func scramble(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			b.WriteRune('A' + rand.N[rune](26))
+		case unicode.IsLetter(r):
+			b.WriteRune('a' + rand.N[rune](26))
+		case unicode.IsDigit(r):
+			b.WriteRune('0' + rand.N[rune](10))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// jitterAmounts changes every amount in a table by a random fraction of up
+// to jitter, rounded to cents.
+func jitterAmounts(tx *sql.Tx, table string, jitter float64) error {
+	if jitter == 0 {
+		return nil
+	}
+	rows, err := tx.Query("SELECT id, amount FROM " + table)
+	if err != nil {
+		return err
+	}
+	amounts := map[int64]float64{}
+	for rows.Next() {
+		var id int64
+		var amount float64
+		if err := rows.Scan(&id, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		amounts[id] = amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, amount := range amounts {
+		changed := math.Round(amount*(1+(rand.Float64()*2-1)*jitter)*100) / 100
+		if _, err := tx.Exec("UPDATE "+table+" SET amount = ? WHERE id = ?", changed, id); err != nil {
+			// Another expense of the same day and description already has
+			// that amount, this one keeps its own
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// queryStrings returns the single text column of a query's rows.
+func queryStrings(tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
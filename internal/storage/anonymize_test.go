@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AnonymizeTestSuite provides a test suite for anonymizing a database
+type AnonymizeTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *AnonymizeTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *AnonymizeTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *AnonymizeTestSuite) TestAnonymize() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.SetEmail(user.ID, "alice@example.com"))
+	s.Require().NoError(s.db.CreateSession("token", user.ID, time.Hour, ""))
+	s.Require().NoError(s.db.SetAvatar(user.ID, []byte("\x89PNG"), "image/png"))
+	guest := &models.GuestToken{UserID: user.ID, LedgerID: models.PersonalLedgerID, Name: "Accountant", ExpiresAt: time.Now().Add(time.Hour)}
+	s.Require().NoError(s.db.CreateGuestToken(guest, "hash"))
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(100, "Dr. Smith 42", "Health", day, user.ID))
+	s.Require().NoError(s.db.CreateExpense(100, "Dr. Smith 42", "Health", day.Add(time.Hour), user.ID))
+	project, err := s.db.CreateProject("Knee surgery")
+	s.Require().NoError(err)
+	ledger, err := s.db.CreateLedger("Alice's clinic", "", user.ID)
+	s.Require().NoError(err)
+	deleted := &models.Expense{Amount: 80, Description: "Therapy", Category: "Health", Date: day, UserID: &user.ID}
+	s.Require().NoError(s.db.InsertExpense(deleted))
+	s.Require().NoError(s.db.DeleteExpense(deleted.ID))
+
+	s.Require().NoError(s.db.Anonymize(0.1))
+
+	anonymous, err := s.db.GetUserByID(user.ID)
+	s.Require().NoError(err)
+	s.Equal("user1", anonymous.Username)
+	s.Empty(anonymous.PasswordHash)
+	s.Empty(anonymous.Email)
+	_, err = s.db.ValidateSession("token")
+	s.Error(err)
+	s.ErrorIs(s.db.RestoreExpense(deleted.ID), sql.ErrNoRows, "deleted expenses are gone")
+	_, err = s.db.GetAvatar(user.ID)
+	s.ErrorIs(err, sql.ErrNoRows, "avatars are gone")
+	guests, err := s.db.ListGuestTokens(user.ID)
+	s.Require().NoError(err)
+	s.Empty(guests, "guest links are gone")
+	anonymousProject, err := s.db.GetProject(project.ID)
+	s.Require().NoError(err)
+	s.Equal("project1", anonymousProject.Name)
+	anonymousLedger, err := s.db.GetLedger(ledger.ID)
+	s.Require().NoError(err)
+	s.Equal(fmt.Sprintf("ledger%d", ledger.ID), anonymousLedger.Name)
+
+	expenses, err := s.db.GetExpensesByMonth(2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2)
+	s.NotEqual("Dr. Smith 42", expenses[0].Description)
+	s.Regexp(`^[A-Z][a-z]\. [A-Z][a-z]{4} \d\d$`, expenses[0].Description, "the shape is kept")
+	s.Equal(expenses[0].Description, expenses[1].Description, "equal descriptions stay equal")
+	var total float64
+	for _, e := range expenses {
+		s.InDelta(100, e.Amount, 10)
+		total += e.Amount
+	}
+
+	monthTotal, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(total, monthTotal, 0.001, "statistics follow")
+}
+
+// TestAnonymizeSuite runs the anonymize test suite
+func TestAnonymizeSuite(t *testing.T) {
+	suite.Run(t, new(AnonymizeTestSuite))
+}
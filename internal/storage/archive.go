@@ -0,0 +1,36 @@
+package storage
+
+import "time"
+
+// ArchiveExpenses moves the expenses dated before cutoff from the expenses
+// table into archived_expenses and returns how many it moved. Archived
+// expenses no longer show up in expense lists and can't be edited, but still
+// count towards the statistics, so lists and searches stay fast after years
+// of use.
+func (db *DB) ArchiveExpenses(cutoff time.Time) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(
+		`INSERT INTO archived_expenses (id, amount, description, category, date, user_id, ledger_id)
+		 SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE date < ?`,
+		cutoff,
+	); err != nil {
+		return 0, err
+	}
+	moved, err := rowsAffected(tx.Exec("DELETE FROM expenses WHERE date < ?", cutoff))
+	if err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// ArchivedExpenseCount returns how many expenses have been archived.
+func (db *DB) ArchivedExpenseCount() (int64, error) {
+	var count int64
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM archived_expenses").Scan(&count)
+	return count, err
+}
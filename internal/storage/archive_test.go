@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ArchiveTestSuite provides a test suite for archiving old expenses
+type ArchiveTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *ArchiveTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *ArchiveTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *ArchiveTestSuite) TestArchiveKeepsStatistics() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	old := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", old, user.ID))
+	s.Require().NoError(s.db.CreateExpense(20, "Bus", "Transport", old, user.ID))
+	s.Require().NoError(s.db.CreateExpense(30, "Groceries", "Groceries", recent, user.ID))
+
+	moved, err := s.db.ArchiveExpenses(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Equal(int64(2), moved)
+	count, err := s.db.ArchivedExpenseCount()
+	s.Require().NoError(err)
+	s.Equal(int64(2), count)
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1, "archived expenses are out of the lists")
+	s.Equal("Groceries", expenses[0].Description)
+
+	total, err := s.db.GetTotalForPeriod(0, 2023, 5)
+	s.Require().NoError(err)
+	s.Equal(30.0, total)
+
+	// Backdating an expense into an archived day and rebuilding both keep
+	// the archived amounts
+	s.Require().NoError(s.db.CreateExpense(5, "Tea", "Eating Out", old.Add(time.Hour), user.ID))
+	total, err = s.db.GetTotalForPeriod(0, 2023, 5)
+	s.Require().NoError(err)
+	s.Equal(35.0, total)
+	_, err = s.db.RebuildAggregates()
+	s.Require().NoError(err)
+	total, err = s.db.GetTotalForPeriod(0, 2023, 5)
+	s.Require().NoError(err)
+	s.Equal(35.0, total)
+
+	problems, err := s.db.Check()
+	s.Require().NoError(err)
+	s.Empty(problems)
+}
+
+func (s *ArchiveTestSuite) TestPurgeDeletesArchivedExpenses() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	old := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", old, user.ID))
+	_, err = s.db.ArchiveExpenses(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.db.ScheduleAccountDeletion(user.ID, time.Now().Add(-time.Minute)))
+	purged, err := s.db.PurgeDeletedAccounts()
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	count, err := s.db.ArchivedExpenseCount()
+	s.Require().NoError(err)
+	s.Zero(count)
+	total, err := s.db.GetTotalForPeriod(0, 2023, 5)
+	s.Require().NoError(err)
+	s.Zero(total)
+}
+
+// TestArchiveSuite runs the archive test suite
+func TestArchiveSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveTestSuite))
+}
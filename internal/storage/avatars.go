@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// SetAvatar stores a user's avatar, replacing the one they had.
+func (db *DB) SetAvatar(userID int64, image []byte, contentType string) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO avatars (user_id, image, content_type, updated_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET image = excluded.image, content_type = excluded.content_type, updated_at = excluded.updated_at`,
+		userID, image, contentType, time.Now().UTC(),
+	)
+	return err
+}
+
+// GetAvatar returns a user's avatar, or sql.ErrNoRows if they have none.
+func (db *DB) GetAvatar(userID int64) (*models.Avatar, error) {
+	a := models.Avatar{UserID: userID}
+	err := db.conn.QueryRow("SELECT image, content_type, updated_at FROM avatars WHERE user_id = ?", userID).
+		Scan(&a.Image, &a.ContentType, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteAvatar removes a user's avatar, if they have one.
+func (db *DB) DeleteAvatar(userID int64) error {
+	_, err := db.conn.Exec("DELETE FROM avatars WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// AvatarsTestSuite provides a test suite for user avatars
+type AvatarsTestSuite struct {
+	suite.Suite
+	db   *DB
+	user *models.User
+}
+
+// SetupTest runs before each test
+func (s *AvatarsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+}
+
+// TearDownTest runs after each test
+func (s *AvatarsTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *AvatarsTestSuite) TestAvatar() {
+	_, err := s.db.GetAvatar(s.user.ID)
+	s.ErrorIs(err, sql.ErrNoRows, "none yet")
+
+	s.Require().NoError(s.db.SetAvatar(s.user.ID, []byte("first"), "image/jpeg"))
+	s.Require().NoError(s.db.SetAvatar(s.user.ID, []byte("second"), "image/png"))
+	avatar, err := s.db.GetAvatar(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal([]byte("second"), avatar.Image, "replaced")
+	s.Equal("image/png", avatar.ContentType)
+	s.False(avatar.UpdatedAt.IsZero())
+
+	s.Require().NoError(s.db.DeleteAvatar(s.user.ID))
+	_, err = s.db.GetAvatar(s.user.ID)
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.NoError(s.db.DeleteAvatar(s.user.ID), "deleting none is fine")
+}
+
+func (s *AvatarsTestSuite) TestDeleteUser() {
+	s.Require().NoError(s.db.SetAvatar(s.user.ID, []byte("image"), "image/jpeg"))
+	s.Require().NoError(s.db.DeleteUser(s.user.ID, 0))
+	_, err := s.db.GetAvatar(s.user.ID)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+// TestAvatarsTestSuite runs the user avatars test suite
+func TestAvatarsTestSuite(t *testing.T) {
+	suite.Run(t, new(AvatarsTestSuite))
+}
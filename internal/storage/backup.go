@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// Backup writes a consistent copy of the database to path, which must not
+// exist yet. It can run while the server is using the database: the copy is
+// taken in a single read transaction, so it never contains half a write.
+func (db *DB) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	_, err := db.conn.Exec("VACUUM INTO ?", path)
+	return err
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// BackupTestSuite provides a test suite for database backups
+type BackupTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *BackupTestSuite) SetupTest() {
+	db, err := NewDB(filepath.Join(s.T().TempDir(), "expenses.db"))
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *BackupTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *BackupTestSuite) TestBackup() {
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), 1))
+	path := filepath.Join(s.T().TempDir(), "backup.db")
+
+	s.Require().NoError(s.db.Backup(path))
+	s.Error(s.db.Backup(path), "an existing backup isn't overwritten")
+
+	backup, err := NewDB(path)
+	s.Require().NoError(err)
+	defer backup.Close()
+	total, err := backup.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(10, total, 0.001)
+}
+
+// TestBackupSuite runs the backup test suite
+func TestBackupSuite(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrCategoryExists is returned when a category name is already taken,
+// regardless of case.
+var ErrCategoryExists = errors.New("category already exists")
+
+// ErrCategoryNotFound is returned for a category that doesn't exist.
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrCategoryInUse is returned when deleting a category expenses still use.
+var ErrCategoryInUse = errors.New("category is used by expenses")
+
+// ListCategories returns the category names in display order.
+func (db *DB) ListCategories() ([]string, error) {
+	rows, err := db.conn.Query("SELECT name FROM categories ORDER BY position, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// CategoryUsage returns the categories of the user's expenses on or after
+// since, most used first and, among equally used ones, most recently used
+// first. Categories without expenses in that time are left out.
+func (db *DB) CategoryUsage(userID int64, since time.Time) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT category FROM expenses WHERE user_id = ? AND date >= ?
+		GROUP BY category ORDER BY COUNT(*) DESC, MAX(date) DESC, category`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// AddCategory adds a category after the existing ones.
+func (db *DB) AddCategory(name string) error {
+	_, err := db.conn.Exec(
+		"INSERT INTO categories (name, position) SELECT ?, COALESCE(MAX(position), -1) + 1 FROM categories",
+		name,
+	)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+// RenameCategory renames a category and moves its current and archived
+// expenses along, returning how many expenses it moved. Renaming to a
+// different category that exists fails with ErrCategoryExists, merge them
+// instead.
+func (db *DB) RenameCategory(from, to string) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, err := categoryName(tx, from)
+	if err != nil {
+		return 0, err
+	}
+	if existing, err := categoryName(tx, to); err == nil && existing != current {
+		return 0, ErrCategoryExists
+	} else if err != nil && !errors.Is(err, ErrCategoryNotFound) {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE categories SET name = ? WHERE name = ?", to, current); err != nil {
+		return 0, err
+	}
+	moved, err := moveExpenses(tx, current, to)
+	if err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// MergeCategories moves the current and archived expenses of category from
+// into category into and deletes from, returning how many expenses it moved.
+func (db *DB) MergeCategories(from, into string) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	source, err := categoryName(tx, from)
+	if err != nil {
+		return 0, err
+	}
+	target, err := categoryName(tx, into)
+	if err != nil {
+		return 0, err
+	}
+	if source == target {
+		return 0, errors.New("can't merge a category into itself")
+	}
+
+	moved, err := moveExpenses(tx, source, target)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE name = ?", source); err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// DeleteCategory deletes a category no expense or recurring expense uses.
+func (db *DB) DeleteCategory(name string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, err := categoryName(tx, name)
+	if err != nil {
+		return err
+	}
+	var used bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM all_expenses WHERE category = ?)
+		OR EXISTS (SELECT 1 FROM recurring_expenses WHERE category = ?)`, current, current).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return ErrCategoryInUse
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE name = ?", current); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// categoryName returns the stored spelling of a category, matched
+// regardless of case.
+func categoryName(tx *sql.Tx, name string) (string, error) {
+	var stored string
+	err := tx.QueryRow("SELECT name FROM categories WHERE name = ?", name).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCategoryNotFound
+	}
+	return stored, err
+}
+
+// moveExpenses changes the category of current, archived, and recurring
+// expenses and refreshes the statistics of the days they are on.
+func moveExpenses(tx *sql.Tx, from, to string) (int64, error) {
+	rows, err := tx.Query("SELECT DISTINCT SUBSTR(date, 1, 10) FROM all_expenses WHERE category = ?", from)
+	if err != nil {
+		return 0, err
+	}
+	var days []string
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		days = append(days, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	for _, table := range []string{"expenses", "archived_expenses"} {
+		n, err := rowsAffected(tx.Exec("UPDATE "+table+" SET category = ? WHERE category = ?", to, from))
+		if err != nil {
+			return 0, err
+		}
+		moved += n
+	}
+	// Recurring expenses follow, so future ones don't bring the old name back
+	if _, err := tx.Exec("UPDATE recurring_expenses SET category = ? WHERE category = ?", to, from); err != nil {
+		return 0, err
+	}
+	return moved, refreshAggregates(tx, days...)
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CategoryTestSuite provides a test suite for category management
+type CategoryTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *CategoryTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *CategoryTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *CategoryTestSuite) TestBuiltInCategories() {
+	names, err := s.db.ListCategories()
+	s.Require().NoError(err)
+	s.Equal(models.Categories, names)
+}
+
+func (s *CategoryTestSuite) TestCategoryUsage() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, e := range []struct {
+		category string
+		userID   int64
+	}{
+		{"Transport", 1}, {"Groceries", 1}, {"Transport", 1}, {"Health", 1},
+		{"Travel", 2}, {"Travel", 2}, {"Travel", 2},
+	} {
+		s.Require().NoError(s.db.CreateExpense(1, "", e.category, day.Add(time.Duration(i)*time.Hour), e.userID))
+	}
+	s.Require().NoError(s.db.CreateExpense(1, "", "Gifts", day.AddDate(0, -6, 0), 1))
+
+	names, err := s.db.CategoryUsage(1, day.AddDate(0, -3, 0))
+	s.Require().NoError(err)
+	s.Equal([]string{"Transport", "Health", "Groceries"}, names, "most used, then most recently used")
+	names, err = s.db.CategoryUsage(3, day.AddDate(0, -3, 0))
+	s.Require().NoError(err)
+	s.Empty(names)
+}
+
+func (s *CategoryTestSuite) TestAddCategory() {
+	s.Require().NoError(s.db.AddCategory("Pets"))
+	s.ErrorIs(s.db.AddCategory("pets"), ErrCategoryExists)
+
+	names, err := s.db.ListCategories()
+	s.Require().NoError(err)
+	s.Equal("Pets", names[len(names)-1], "added last")
+}
+
+func (s *CategoryTestSuite) TestRenameCategory() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Cinema", "Entertainment", day, 1))
+
+	moved, err := s.db.RenameCategory("entertainment", "Fun")
+	s.Require().NoError(err)
+	s.Equal(int64(1), moved)
+
+	totals, err := s.db.GetCategoryTotalsByMonth(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(totals, 1)
+	s.Equal("Fun", totals[0].Category, "statistics follow")
+
+	_, err = s.db.RenameCategory("Fun", "Travel")
+	s.ErrorIs(err, ErrCategoryExists)
+	_, err = s.db.RenameCategory("Fun", "FUN")
+	s.NoError(err, "changing only the case is a rename")
+	_, err = s.db.RenameCategory("Nonexistent", "Other")
+	s.ErrorIs(err, ErrCategoryNotFound)
+}
+
+func (s *CategoryTestSuite) TestMergeCategories() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Lunch", "Eating Out", day, 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Market", "Groceries", day, 1))
+	s.Require().NoError(s.db.CreateExpense(5, "Old lunch", "Eating Out", day.AddDate(-2, 0, 0), 1))
+	_, err := s.db.ArchiveExpenses(day.AddDate(-1, 0, 0))
+	s.Require().NoError(err)
+
+	moved, err := s.db.MergeCategories("Eating Out", "Groceries")
+	s.Require().NoError(err)
+	s.Equal(int64(2), moved, "archived expenses move too")
+
+	totals, err := s.db.GetCategoryTotalsByMonth(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Require().Len(totals, 1)
+	s.Equal("Groceries", totals[0].Category)
+	s.InDelta(40.0, totals[0].Total, 0.001)
+	s.Equal(2, totals[0].Count)
+
+	names, err := s.db.ListCategories()
+	s.Require().NoError(err)
+	s.NotContains(names, "Eating Out")
+
+	_, err = s.db.MergeCategories("Groceries", "groceries")
+	s.Error(err)
+}
+
+func (s *CategoryTestSuite) TestDeleteCategory() {
+	s.Require().NoError(s.db.CreateExpense(10, "Flowers", "Gifts", time.Now(), 1))
+	s.ErrorIs(s.db.DeleteCategory("Gifts"), ErrCategoryInUse)
+	s.Require().NoError(s.db.DeleteCategory("sport"))
+	s.ErrorIs(s.db.DeleteCategory("Sport"), ErrCategoryNotFound)
+}
+
+// TestCategorySuite runs the category test suite
+func TestCategorySuite(t *testing.T) {
+	suite.Run(t, new(CategoryTestSuite))
+}
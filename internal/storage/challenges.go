@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrChallengeExists is returned when a user takes on a second challenge
+// for the same week.
+var ErrChallengeExists = errors.New("challenge for that week already exists")
+
+const challengeColumns = "id, user_id, ledger_id, week, spending_limit, spent, status, created_at, updated_at"
+
+func scanChallenges(rows *sql.Rows) ([]models.Challenge, error) {
+	defer rows.Close()
+	var challenges []models.Challenge
+	for rows.Next() {
+		var c models.Challenge
+		if err := rows.Scan(&c.ID, &c.UserID, &c.LedgerID, &c.Week, &c.Limit, &c.Spent, &c.Status, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+	return challenges, rows.Err()
+}
+
+// CreateChallenge adds an active challenge and sets its ID, status, and
+// times. It returns ErrChallengeExists if the user has one for the week
+// already.
+func (db *DB) CreateChallenge(c *models.Challenge) error {
+	c.Status = models.ChallengeActive
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+	result, err := db.conn.Exec(
+		`INSERT INTO challenges (user_id, ledger_id, week, spending_limit, spent, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.UserID, c.LedgerID, c.Week, c.Limit, c.Spent, c.Status, c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrChallengeExists
+		}
+		return err
+	}
+	c.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListChallenges returns a user's challenges, the latest week first.
+func (db *DB) ListChallenges(userID int64) ([]models.Challenge, error) {
+	rows, err := db.conn.Query("SELECT "+challengeColumns+" FROM challenges WHERE user_id = ? ORDER BY week DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanChallenges(rows)
+}
+
+// ActiveChallenges returns every user's active challenges, whose progress
+// is still to be tracked.
+func (db *DB) ActiveChallenges() ([]models.Challenge, error) {
+	rows, err := db.conn.Query("SELECT "+challengeColumns+" FROM challenges WHERE status = ? ORDER BY id", models.ChallengeActive)
+	if err != nil {
+		return nil, err
+	}
+	return scanChallenges(rows)
+}
+
+// UpdateChallenge records a challenge's progress: what was spent in its
+// week so far, and whether that completed or failed it.
+func (db *DB) UpdateChallenge(id int64, spent float64, status string) error {
+	n, err := rowsAffected(db.conn.Exec(
+		"UPDATE challenges SET spent = ?, status = ?, updated_at = ? WHERE id = ?",
+		spent, status, time.Now().UTC(), id,
+	))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteChallenge gives up one of the user's active challenges; completed
+// and failed ones stay in their history. It returns sql.ErrNoRows if the
+// user has no such active challenge.
+func (db *DB) DeleteChallenge(userID, id int64) error {
+	n, err := rowsAffected(db.conn.Exec(
+		"DELETE FROM challenges WHERE id = ? AND user_id = ? AND status = ?",
+		id, userID, models.ChallengeActive,
+	))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetStreak returns a user's no-spend streak, zero if it was never
+// computed.
+func (db *DB) GetStreak(userID int64) (models.Streak, error) {
+	s := models.Streak{UserID: userID}
+	err := db.conn.QueryRow(
+		"SELECT current, longest, updated_at FROM spending_streaks WHERE user_id = ?", userID,
+	).Scan(&s.Current, &s.Longest, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, nil
+	}
+	return s, err
+}
+
+// SaveStreak stores a user's no-spend streak, replacing the one before, and
+// sets its update time.
+func (db *DB) SaveStreak(s *models.Streak) error {
+	s.UpdatedAt = time.Now().UTC()
+	_, err := db.conn.Exec(
+		`INSERT INTO spending_streaks (user_id, current, longest, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET current = excluded.current, longest = excluded.longest, updated_at = excluded.updated_at`,
+		s.UserID, s.Current, s.Longest, s.UpdatedAt,
+	)
+	return err
+}
+
+// SpendingDays returns the days, as YYYY-MM-DD, from the day of start up to
+// the day of end, exclusive, with current or archived expenses in a ledger,
+// the earliest first.
+func (db *DB) SpendingDays(ledgerID int64, start, end time.Time) ([]string, error) {
+	rows, err := db.conn.Query(
+		`SELECT DISTINCT day FROM daily_aggregates
+		WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?) AND count > 0
+		ORDER BY day`,
+		start.Format(time.DateOnly), end.Format(time.DateOnly), ledgerID, ledgerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []string
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
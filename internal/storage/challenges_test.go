@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ChallengesTestSuite provides a test suite for challenges and no-spend
+// streaks
+type ChallengesTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *ChallengesTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *ChallengesTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *ChallengesTestSuite) TestChallenges() {
+	earlier := &models.Challenge{UserID: 1, LedgerID: models.PersonalLedgerID, Week: "2026-03-02", Limit: 100}
+	s.Require().NoError(s.db.CreateChallenge(earlier))
+	s.Equal(models.ChallengeActive, earlier.Status)
+	current := &models.Challenge{UserID: 1, LedgerID: models.PersonalLedgerID, Week: "2026-03-09", Limit: 50, Spent: 12}
+	s.Require().NoError(s.db.CreateChallenge(current))
+	s.ErrorIs(s.db.CreateChallenge(&models.Challenge{UserID: 1, LedgerID: models.PersonalLedgerID, Week: "2026-03-09", Limit: 80}), ErrChallengeExists)
+	s.Require().NoError(s.db.CreateChallenge(&models.Challenge{UserID: 2, LedgerID: models.PersonalLedgerID, Week: "2026-03-09", Limit: 80}))
+
+	challenges, err := s.db.ListChallenges(1)
+	s.Require().NoError(err)
+	s.Require().Len(challenges, 2)
+	s.Equal("2026-03-09", challenges[0].Week, "the latest week first")
+	s.InDelta(12, challenges[0].Spent, 0.001)
+	s.InDelta(50, challenges[0].Limit, 0.001)
+
+	s.Require().NoError(s.db.UpdateChallenge(earlier.ID, 80, models.ChallengeCompleted))
+	active, err := s.db.ActiveChallenges()
+	s.Require().NoError(err)
+	s.Len(active, 2, "of both users")
+
+	s.ErrorIs(s.db.DeleteChallenge(1, earlier.ID), sql.ErrNoRows, "completed ones stay")
+	s.ErrorIs(s.db.DeleteChallenge(2, current.ID), sql.ErrNoRows, "someone else's")
+	s.Require().NoError(s.db.DeleteChallenge(1, current.ID))
+	challenges, err = s.db.ListChallenges(1)
+	s.Require().NoError(err)
+	s.Len(challenges, 1)
+	s.ErrorIs(s.db.UpdateChallenge(current.ID, 0, models.ChallengeFailed), sql.ErrNoRows)
+}
+
+func (s *ChallengesTestSuite) TestStreak() {
+	streak, err := s.db.GetStreak(1)
+	s.Require().NoError(err)
+	s.Equal(models.Streak{UserID: 1}, streak, "never computed")
+
+	s.Require().NoError(s.db.SaveStreak(&models.Streak{UserID: 1, Current: 3, Longest: 9}))
+	s.Require().NoError(s.db.SaveStreak(&models.Streak{UserID: 1, Current: 4, Longest: 9}))
+	streak, err = s.db.GetStreak(1)
+	s.Require().NoError(err)
+	s.Equal(4, streak.Current, "replaced")
+	s.Equal(9, streak.Longest)
+	s.False(streak.UpdatedAt.IsZero())
+}
+
+func (s *ChallengesTestSuite) TestSpendingDays() {
+	s.Require().NoError(s.db.CreateExpense(5, "Coffee", "Eating Out", time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(9, "Lunch", "Eating Out", time.Date(2026, 3, 2, 13, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Groceries", "Groceries", time.Date(2026, 3, 5, 18, 0, 0, 0, time.UTC), 2))
+	s.Require().NoError(s.db.CreateExpense(60, "Shoes", "Shopping", time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC), 1))
+	_, err := s.db.ArchiveExpenses(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	days, err := s.db.SpendingDays(models.PersonalLedgerID, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Equal([]string{"2026-03-02", "2026-03-05"}, days, "archived ones and everyone's count, the end doesn't")
+
+	days, err = s.db.SpendingDays(models.PersonalLedgerID+1, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Empty(days, "another ledger")
+}
+
+// TestChallengesTestSuite runs the challenges test suite
+func TestChallengesTestSuite(t *testing.T) {
+	suite.Run(t, new(ChallengesTestSuite))
+}
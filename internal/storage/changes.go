@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrConflict is returned by UpdateExpenseIfUnchanged and
+// DeleteExpenseIfUnchanged when the expense changed after the version the
+// caller knew.
+var ErrConflict = errors.New("expense changed in the meantime")
+
+// ExpenseChange is an expense's latest entry in the change feed.
+type ExpenseChange struct {
+	Version int64 // Increases with every change to any expense
+	ID      int64
+	Expense *models.Expense // As it is now, nil if deleted
+}
+
+// ExpenseChanges returns at most limit of the expenses, current or archived,
+// that changed after version since, in the order they last changed. Deleted
+// ones are included without their Expense, so clients keeping a copy can
+// drop theirs. Since 0 returns every expense there is.
+func (db *DB) ExpenseChanges(since int64, limit int) ([]ExpenseChange, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The feed first, then the expenses it names: joining it to
+	// all_expenses would read every expense for each page
+	rows, err := tx.Query(
+		"SELECT version, expense_id, deleted FROM expense_changes WHERE version > ? ORDER BY version LIMIT ?",
+		since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var changes []ExpenseChange
+	var ids []any
+	for rows.Next() {
+		var c ExpenseChange
+		var deleted bool
+		if err := rows.Scan(&c.Version, &c.ID, &deleted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes = append(changes, c)
+		if !deleted {
+			ids = append(ids, c.ID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return changes, err
+	}
+
+	placeholders := strings.Repeat(", ?", len(ids))[2:]
+	rows, err = tx.Query(
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE id IN ("+placeholders+")"+
+			" UNION ALL SELECT id, amount, description, category, date, user_id, ledger_id FROM archived_expenses WHERE id IN ("+placeholders+")",
+		append(ids, ids...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := scanExpenses(rows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*models.Expense, len(expenses))
+	for i := range expenses {
+		byID[expenses[i].ID] = &expenses[i]
+	}
+	for i := range changes {
+		changes[i].Expense = byID[changes[i].ID]
+	}
+	return changes, nil
+}
+
+// UpdateExpenseIfUnchanged updates an expense like UpdateExpense, unless it
+// changed after version, when it returns ErrConflict. An expense that doesn't
+// exist, or is archived, gives sql.ErrNoRows.
+func (db *DB) UpdateExpenseIfUnchanged(e *models.Expense, version int64) error {
+	return db.updateExpense(e, &version)
+}
+
+// DeleteExpenseIfUnchanged deletes an expense like DeleteExpense, unless it
+// changed after version, when it returns ErrConflict. An expense that doesn't
+// exist, or is archived, gives sql.ErrNoRows.
+func (db *DB) DeleteExpenseIfUnchanged(id, version int64) error {
+	return db.deleteExpense(id, &version)
+}
+
+// checkUnchanged returns ErrConflict if the expense id changed after
+// version, and sql.ErrNoRows if it isn't a current expense.
+func checkUnchanged(tx *sql.Tx, id, version int64) error {
+	var latest int64
+	var deleted bool
+	if err := tx.QueryRow("SELECT version, deleted FROM expense_changes WHERE expense_id = ?", id).Scan(&latest, &deleted); err != nil {
+		return err
+	}
+	if latest > version {
+		return ErrConflict
+	}
+	if deleted {
+		return sql.ErrNoRows
+	}
+	return tx.QueryRow("SELECT id FROM expenses WHERE id = ?", id).Scan(&id)
+}
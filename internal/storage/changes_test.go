@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ChangesTestSuite provides a test suite for the expense change feed
+type ChangesTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *ChangesTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *ChangesTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// insert adds an expense and returns it.
+func (s *ChangesTestSuite) insert(description string, date time.Time) *models.Expense {
+	userID := int64(1)
+	e := &models.Expense{Amount: 1, Description: description, Category: "Other", Date: date, UserID: &userID}
+	s.Require().NoError(s.db.InsertExpense(e))
+	return e
+}
+
+// changes returns the feed after since.
+func (s *ChangesTestSuite) changes(since int64) []ExpenseChange {
+	changes, err := s.db.ExpenseChanges(since, 100)
+	s.Require().NoError(err)
+	return changes
+}
+
+func (s *ChangesTestSuite) TestFeed() {
+	coffee := s.insert("Coffee", s.day)
+	bus := s.insert("Bus", s.day.Add(time.Hour))
+	old := s.insert("Old", s.day.AddDate(-2, 0, 0))
+	all := s.changes(0)
+	s.Require().Len(all, 3)
+	s.Equal(coffee.ID, all[0].ID)
+	s.Equal("Coffee", all[0].Expense.Description)
+	checkpoint := all[2].Version
+
+	coffee.Description = "Espresso"
+	s.Require().NoError(s.db.UpdateExpense(coffee))
+	s.Require().NoError(s.db.DeleteExpense(bus.ID))
+	_, err := s.db.ArchiveExpenses(s.day.AddDate(-1, 0, 0))
+	s.Require().NoError(err)
+	changes := s.changes(checkpoint)
+	s.Require().Len(changes, 2, "archiving isn't a change")
+	s.Equal("Espresso", changes[0].Expense.Description)
+	s.Equal(bus.ID, changes[1].ID)
+	s.Nil(changes[1].Expense, "a tombstone")
+	s.Empty(s.changes(changes[1].Version))
+
+	all = s.changes(0)
+	s.Len(all, 3, "one entry per expense")
+	s.Equal(old.ID, all[0].ID)
+	s.Equal("Old", all[0].Expense.Description, "archived expenses too")
+
+	page, err := s.db.ExpenseChanges(0, 2)
+	s.Require().NoError(err)
+	s.Len(page, 2)
+}
+
+func (s *ChangesTestSuite) TestIfUnchanged() {
+	coffee := s.insert("Coffee", s.day)
+	version := s.changes(0)[0].Version
+
+	coffee.Amount = 2
+	s.Require().NoError(s.db.UpdateExpenseIfUnchanged(coffee, version))
+	coffee.Amount = 3
+	s.ErrorIs(s.db.UpdateExpenseIfUnchanged(coffee, version), ErrConflict, "changed since")
+	s.ErrorIs(s.db.DeleteExpenseIfUnchanged(coffee.ID, version), ErrConflict)
+	stored, err := s.db.GetExpense(coffee.ID)
+	s.Require().NoError(err)
+	s.Equal(2.0, stored.Amount)
+
+	version = s.changes(version)[0].Version
+	s.Require().NoError(s.db.DeleteExpenseIfUnchanged(coffee.ID, version))
+	s.ErrorIs(s.db.UpdateExpenseIfUnchanged(coffee, version), ErrConflict, "deleted since")
+	tombstone := s.changes(version)[0].Version
+	s.ErrorIs(s.db.UpdateExpenseIfUnchanged(coffee, tombstone), sql.ErrNoRows)
+	s.ErrorIs(s.db.DeleteExpenseIfUnchanged(404, tombstone), sql.ErrNoRows)
+
+	old := s.insert("Old", s.day.AddDate(-2, 0, 0))
+	_, err = s.db.ArchiveExpenses(s.day)
+	s.Require().NoError(err)
+	s.ErrorIs(s.db.DeleteExpenseIfUnchanged(old.ID, s.changes(tombstone)[0].Version), sql.ErrNoRows, "archived")
+}
+
+// TestChangesTestSuite runs the expense change feed test suite
+func TestChangesTestSuite(t *testing.T) {
+	suite.Run(t, new(ChangesTestSuite))
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxListedIDs is how many row IDs a Problem lists before summarizing.
+const maxListedIDs = 10
+
+// Problem is something wrong with the database found by Check.
+type Problem struct {
+	Description string
+	Fix         string // Suggested way to fix it
+}
+
+// Check runs SQLite's integrity check and looks for data the app doesn't
+// expect: rows of deleted users, negative amounts, and outdated statistics.
+// Foreign keys aren't enforced, so such rows can come from editing the
+// database by hand or from old versions of the app.
+func (db *DB) Check() ([]Problem, error) {
+	var problems []Problem
+
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []string
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return nil, err
+		}
+		if message != "ok" {
+			messages = append(messages, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) > 0 {
+		problems = append(problems, Problem{
+			Description: "SQLite integrity check failed: " + strings.Join(messages, "; "),
+			Fix:         "Stop the server and restore the latest backup, or try salvaging the data with sqlite3's .recover command",
+		})
+	}
+
+	for _, c := range []struct {
+		query       string // Selects an ID for each offending row
+		idLabel     string // What the IDs are
+		description string
+		fix         string
+	}{
+		{
+			`SELECT user_id FROM sessions WHERE user_id NOT IN (SELECT id FROM users)`,
+			"user IDs",
+			"sessions belong to users that no longer exist",
+			"DELETE FROM sessions WHERE user_id NOT IN (SELECT id FROM users)",
+		},
+		{
+			`SELECT user_id FROM passkeys WHERE user_id NOT IN (SELECT id FROM users)`,
+			"user IDs",
+			"passkeys belong to users that no longer exist",
+			"DELETE FROM passkeys WHERE user_id NOT IN (SELECT id FROM users)",
+		},
+		{
+			`SELECT id FROM expenses WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users)`,
+			"IDs",
+			"expenses belong to users that no longer exist",
+			"Give them to an existing user with UPDATE expenses SET user_id = <id> WHERE user_id NOT IN (SELECT id FROM users), or delete them",
+		},
+		{
+			`SELECT id FROM all_expenses WHERE ledger_id NOT IN (SELECT id FROM ledgers)`,
+			"IDs",
+			"expenses are in ledgers that no longer exist",
+			"Move them to the personal ledger with UPDATE expenses SET ledger_id = 1 WHERE ledger_id NOT IN (SELECT id FROM ledgers), and likewise for archived_expenses",
+		},
+		{
+			`SELECT id FROM expenses WHERE amount < 0`,
+			"IDs",
+			"expenses have a negative amount",
+			"Correct or delete them in the app",
+		},
+	} {
+		ids, err := db.queryIDs(c.query)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			problems = append(problems, Problem{
+				Description: describeRows(ids, c.idLabel, c.description),
+				Fix:         c.fix,
+			})
+		}
+	}
+
+	// Compare with what RebuildAggregates would write, rounded to ignore
+	// floating point noise from summing in a different order
+	var stale int
+	err = db.conn.QueryRow(
+		`WITH expected AS (
+			SELECT ledger_id, SUBSTR(date, 1, 10) AS day, category, ROUND(SUM(amount), 6) AS total, COUNT(*) AS count
+			FROM all_expenses GROUP BY ledger_id, SUBSTR(date, 1, 10), category
+		), actual AS (
+			SELECT ledger_id, day, category, ROUND(total, 6) AS total, count FROM daily_aggregates
+		)
+		SELECT COUNT(*) FROM (
+			SELECT ledger_id, day, category FROM (SELECT * FROM expected EXCEPT SELECT * FROM actual)
+			UNION
+			SELECT ledger_id, day, category FROM (SELECT * FROM actual EXCEPT SELECT * FROM expected)
+		)`,
+	).Scan(&stale)
+	if err != nil {
+		return nil, err
+	}
+	if stale > 0 {
+		problems = append(problems, Problem{
+			Description: fmt.Sprintf("Statistics for %d days and categories don't match the expenses", stale),
+			Fix:         "expensectl aggregates rebuild",
+		})
+	}
+	return problems, nil
+}
+
+// queryIDs returns the integer IDs a query selects.
+func (db *DB) queryIDs(query string) ([]int64, error) {
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// describeRows prefixes a description with how many rows it applies to and
+// follows it with the first few distinct IDs.
+func describeRows(ids []int64, idLabel, description string) string {
+	var listed []string
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if len(listed) == maxListedIDs {
+			listed = append(listed, "…")
+			break
+		}
+		listed = append(listed, strconv.FormatInt(id, 10))
+	}
+	return fmt.Sprintf("%d %s (%s %s)", len(ids), description, idLabel, strings.Join(listed, ", "))
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CheckTestSuite provides a test suite for the database sanity checks
+type CheckTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *CheckTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *CheckTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *CheckTestSuite) TestHealthy() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateExpense(0.1, "Gum", "Groceries", time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), user.ID))
+	s.Require().NoError(s.db.CreateExpense(0.2, "Mints", "Groceries", time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC), user.ID))
+	s.Require().NoError(s.db.CreateSession("token", user.ID, time.Hour, ""))
+
+	problems, err := s.db.Check()
+	s.Require().NoError(err)
+	s.Empty(problems)
+}
+
+func (s *CheckTestSuite) TestFindsProblems() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(-5, "Refund", "Other", day, user.ID))
+	s.Require().NoError(s.db.CreateExpense(12, "Lunch", "Eating Out", day, 42))
+	s.Require().NoError(s.db.CreateSession("a", 42, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("b", 42, time.Hour, ""))
+	_, err = s.db.conn.Exec("UPDATE daily_aggregates SET total = 100 WHERE category = 'Eating Out'")
+	s.Require().NoError(err)
+
+	problems, err := s.db.Check()
+	s.Require().NoError(err)
+	s.Require().Len(problems, 4)
+	s.Equal("2 sessions belong to users that no longer exist (user IDs 42)", problems[0].Description)
+	s.Contains(problems[0].Fix, "DELETE FROM sessions")
+	s.Contains(problems[1].Description, "1 expenses belong to users that no longer exist")
+	s.Contains(problems[2].Description, "1 expenses have a negative amount")
+	s.Equal("Statistics for 1 days and categories don't match the expenses", problems[3].Description)
+	s.Equal("expensectl aggregates rebuild", problems[3].Fix)
+
+	_, err = s.db.RebuildAggregates()
+	s.Require().NoError(err)
+	problems, err = s.db.Check()
+	s.Require().NoError(err)
+	s.Len(problems, 3)
+}
+
+func (s *CheckTestSuite) TestDescribeRows() {
+	ids := []int64{1, 2, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	s.Equal("13 rows are odd (IDs 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, …)", describeRows(ids, "IDs", "rows are odd"))
+}
+
+// TestCheckSuite runs the database check test suite
+func TestCheckSuite(t *testing.T) {
+	suite.Run(t, new(CheckTestSuite))
+}
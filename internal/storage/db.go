@@ -1,37 +1,187 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"sync"
+	"time"
 
 	// Import sqlite driver
 	_ "modernc.org/sqlite"
 )
 
+//go:generate go run ./sqlgen
+
 // DB wraps a sql.DB connection.
 type DB struct {
-	conn *sql.DB
+	pool *sql.DB
+	conn queryRunner
 }
 
 // NewDB opens a database connection and runs migrations.
 func NewDB(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path)
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Open opens a database connection without running migrations, e.g. to
+// check which are pending.
+func Open(path string) (*DB, error) {
+	sqlite, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, err
 	}
+	// Only for its driver, to time the queries
+	sqlite.Close()
+	conn := sql.OpenDB(timedConnector{driver: sqlite.Driver(), name: path})
 
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, err
 	}
+	return &DB{pool: conn, conn: queryRunner{pool: conn, ctx: context.Background(), stmts: &stmtCache{}}}, nil
+}
+
+// Pool limits the connections a DB keeps to the database.
+type Pool struct {
+	MaxOpen     int           // 0 means no limit
+	MaxIdle     int           // Kept open for the next query
+	MaxLifetime time.Duration // 0 reuses connections for good
+}
+
+// SetPool applies the connection limits. SQLite takes one writer at a time,
+// so more connections mostly wait on each other's locks.
+func (db *DB) SetPool(p Pool) {
+	db.pool.SetMaxOpenConns(p.MaxOpen)
+	db.pool.SetMaxIdleConns(p.MaxIdle)
+	db.pool.SetConnMaxLifetime(p.MaxLifetime)
+}
+
+// WithContext returns a view of db whose queries run under ctx: they give
+// up once ctx is cancelled or its deadline passes, e.g. while waiting for a
+// locked database, instead of hanging. The connection is shared, so closing
+// either closes both.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	view := *db
+	view.conn.ctx = ctx
+	return &view
+}
+
+// querier runs statements, on the connection pool of a DB or in a
+// transaction. The functions generated from queries/*.sql take one.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// queryRunner runs statements on the connection pool under the context of
+// the DB it belongs to.
+type queryRunner struct {
+	pool  *sql.DB
+	ctx   context.Context
+	stmts *stmtCache // Shared by all views of the DB
+}
 
-	db := &DB{conn: conn}
-	if err := db.migrate(); err != nil {
+func (q queryRunner) Exec(query string, args ...any) (sql.Result, error) {
+	return q.pool.ExecContext(q.ctx, query, args...)
+}
+
+func (q queryRunner) Query(query string, args ...any) (*sql.Rows, error) {
+	return q.pool.QueryContext(q.ctx, query, args...)
+}
+
+func (q queryRunner) QueryRow(query string, args ...any) *sql.Row {
+	return q.pool.QueryRowContext(q.ctx, query, args...)
+}
+
+// QueryPrepared is Query with a cached prepared statement, for the queries
+// that run on nearly every request. Building query from a few fixed parts is
+// fine, but it must not embed values, or the cache grows without bound.
+func (q queryRunner) QueryPrepared(query string, args ...any) (*sql.Rows, error) {
+	stmt, err := q.stmts.prepare(q.pool, query)
+	if err != nil {
 		return nil, err
 	}
+	return stmt.QueryContext(q.ctx, args...)
+}
 
-	return db, nil
+// QueryRowPrepared is QueryRow with a cached prepared statement, see
+// QueryPrepared.
+func (q queryRunner) QueryRowPrepared(query string, args ...any) preparedRow {
+	stmt, err := q.stmts.prepare(q.pool, query)
+	if err != nil {
+		return preparedRow{err: err}
+	}
+	return preparedRow{row: stmt.QueryRowContext(q.ctx, args...)}
+}
+
+// preparedRow is a *sql.Row, or the error preparing its statement.
+type preparedRow struct {
+	row *sql.Row
+	err error
+}
+
+func (r preparedRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Scan(dest...)
+}
+
+// stmtCache holds the prepared statements of a DB by query. database/sql
+// prepares them again on each pooled connection as needed.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
 }
 
-func (db *DB) migrate() error {
+func (c *stmtCache) prepare(pool *sql.DB, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	// Preparing isn't bound to a request's context, since the statement
+	// outlives it
+	stmt, err := pool.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if c.stmts == nil {
+		c.stmts = make(map[string]*sql.Stmt)
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// close closes all prepared statements.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stmt := range c.stmts {
+		stmt.Close()
+	}
+	c.stmts = nil
+}
+
+// Begin starts a transaction that is rolled back if the context ends
+// before it commits.
+func (q queryRunner) Begin() (*sql.Tx, error) {
+	return q.pool.BeginTx(q.ctx, nil)
+}
+
+// baselineSchema creates the schema as it was before numbered migrations,
+// adding whatever an older database lacks. Every step can run again, so it
+// suits both new and existing databases.
+func (db *DB) baselineSchema() error {
 	migrations := []string{
 		`CREATE TABLE IF NOT EXISTS expenses (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -52,6 +202,57 @@ func (db *DB) migrate() error {
 			expires_at DATETIME NOT NULL,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS daily_aggregates (
+			day TEXT NOT NULL,
+			category TEXT NOT NULL,
+			total REAL NOT NULL,
+			count INTEGER NOT NULL,
+			PRIMARY KEY (day, category)
+		)`,
+		`CREATE TABLE IF NOT EXISTS login_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+			username TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			user_agent TEXT NOT NULL,
+			success BOOLEAN NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS login_events_user_id_created_at_index ON login_events (user_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS passkeys (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			public_key BLOB NOT NULL,
+			sign_count INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			last_used_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS passkeys_user_id_index ON passkeys (user_id)`,
+		`CREATE TABLE IF NOT EXISTS secrets (
+			name TEXT PRIMARY KEY,
+			value BLOB NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS invites (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT UNIQUE NOT NULL,
+			created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			used_at DATETIME
+		)`,
+		// Old expenses moved out of the way by ArchiveExpenses
+		`CREATE TABLE IF NOT EXISTS archived_expenses (
+			id INTEGER PRIMARY KEY,
+			amount REAL NOT NULL,
+			description TEXT NOT NULL,
+			category TEXT NOT NULL,
+			date DATETIME NOT NULL,
+			user_id INTEGER REFERENCES users(id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS archived_expenses_date_index ON archived_expenses (date)`,
+		`CREATE INDEX IF NOT EXISTS archived_expenses_user_id_index ON archived_expenses (user_id)`,
 	}
 
 	for _, m := range migrations {
@@ -64,15 +265,69 @@ func (db *DB) migrate() error {
 	// We ignore the error here because the column might already exist
 	_, _ = db.conn.Exec(`ALTER TABLE expenses ADD COLUMN user_id INTEGER REFERENCES users(id)`)
 
+	// Statistics are computed from current and archived expenses alike
+	if _, err := db.conn.Exec(
+		`CREATE VIEW IF NOT EXISTS all_expenses AS
+		 SELECT id, amount, description, category, date, user_id FROM expenses
+		 UNION ALL
+		 SELECT id, amount, description, category, date, user_id FROM archived_expenses`,
+	); err != nil {
+		return err
+	}
+
 	// Add last_activity column to sessions for rolling sessions
 	_, _ = db.conn.Exec(`ALTER TABLE sessions ADD COLUMN last_activity DATETIME DEFAULT CURRENT_TIMESTAMP`)
 
+	// Hash of the client a session was created from, for session binding
+	_, _ = db.conn.Exec(`ALTER TABLE sessions ADD COLUMN fingerprint TEXT`)
+
+	// Lifetime of each session in seconds; sessions from before it existed
+	// were all 30 days
+	_, _ = db.conn.Exec(`ALTER TABLE sessions ADD COLUMN duration INTEGER NOT NULL DEFAULT 2592000`)
+
+	// Track failed logins per user for temporary account lockout
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN locked_until DATETIME`)
+
+	// Existing username-only users start without an email address
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN email TEXT`)
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN email_verified_at DATETIME`)
+	// An address can be pending on several accounts but verified on only one
+	_, _ = db.conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS users_verified_email_uindex ON users (email COLLATE NOCASE) WHERE email_verified_at IS NOT NULL`)
+
+	// Admins can invite users. When upgrading, the oldest account (usually
+	// the bootstrap admin) becomes the admin.
+	if _, err := db.conn.Exec(`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'member'`); err == nil {
+		if _, err := db.conn.Exec(`UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users)`); err != nil {
+			return err
+		}
+	}
+
+	// When set, the account is deleted for good after this time
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN delete_after DATETIME`)
+	// When set, the account can't log in but its data is kept
+	_, _ = db.conn.Exec(`ALTER TABLE users ADD COLUMN disabled_at DATETIME`)
+
 	// Add unique constraint on date, amount, description for expenses
 	_, _ = db.conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS expenses_date_amount_description_uindex ON expenses (date, amount, description)`)
+
+	// Populate the aggregates table for databases created before it existed
+	var needsRebuild bool
+	if err := db.conn.QueryRow(
+		`SELECT NOT EXISTS (SELECT 1 FROM daily_aggregates) AND EXISTS (SELECT 1 FROM expenses)`,
+	).Scan(&needsRebuild); err != nil {
+		return err
+	}
+	if needsRebuild {
+		if _, err := db.RebuildAggregates(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Close closes the database connection.
 func (db *DB) Close() error {
-	return db.conn.Close()
+	db.conn.stmts.close()
+	return db.pool.Close()
 }
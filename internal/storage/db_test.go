@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DBTestSuite provides a test suite for the database wrapper
+type DBTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *DBTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *DBTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *DBTestSuite) TestWithContext_Cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db := s.db.WithContext(ctx)
+
+	_, err := db.ListCategories()
+	s.ErrorIs(err, context.Canceled)
+	err = db.CreateExpense(10, "Lunch", "Eating Out", time.Now(), 0)
+	s.ErrorIs(err, context.Canceled)
+
+	// The database itself isn't affected
+	_, err = s.db.ListCategories()
+	s.NoError(err)
+}
+
+func (s *DBTestSuite) TestWithContext_Deadline() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, err := s.db.WithContext(ctx).GetMonthStats(0, 2024, 1)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *DBTestSuite) TestWithContext_Transaction() {
+	// Transactions commit as usual while the context is live
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	db := s.db.WithContext(ctx)
+
+	s.Require().NoError(db.CreateExpense(10, "Lunch", "Eating Out", time.Now(), 0))
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Len(expenses, 1)
+}
+
+func (s *DBTestSuite) TestPreparedStatements() {
+	s.Require().NoError(s.db.CreateExpense(10, "Lunch", "Eating Out", time.Now(), 0))
+	for range 3 {
+		expenses, err := s.db.FilterExpenses(ExpenseFilter{Category: "eating out"})
+		s.Require().NoError(err)
+		s.Len(expenses, 1)
+	}
+	_, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Len(s.db.conn.stmts.stmts, 2, "one statement per distinct query")
+
+	// Views of the DB share the cache, and a bad query isn't cached
+	_, err = s.db.WithContext(context.Background()).conn.QueryPrepared("SELECT nonsense FROM nowhere")
+	s.Error(err)
+	s.Len(s.db.conn.stmts.stmts, 2)
+	err = s.db.conn.QueryRowPrepared("SELECT nonsense FROM nowhere").Scan()
+	s.Error(err)
+}
+
+func (s *DBTestSuite) TestSetPool() {
+	s.db.SetPool(Pool{MaxOpen: 1, MaxIdle: 1, MaxLifetime: time.Hour})
+	s.Equal(1, s.db.pool.Stats().MaxOpenConnections)
+
+	// Queries take turns on the one connection
+	for range 3 {
+		_, err := s.db.FilterExpenses(ExpenseFilter{})
+		s.Require().NoError(err)
+	}
+	s.Equal(1, s.db.pool.Stats().OpenConnections)
+}
+
+// TestDBTestSuite runs the database wrapper test suite
+func TestDBTestSuite(t *testing.T) {
+	suite.Run(t, new(DBTestSuite))
+}
+
+// newBenchDB creates a database file, so parallel benchmarks use several
+// connections like the server does.
+func newBenchDB(b *testing.B) *DB {
+	db, err := NewDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RestoreExpense puts back an expense deleted with DeleteExpense, with its
+// ID, as long as it hasn't been purged. It returns sql.ErrNoRows if it has,
+// or was never deleted, and a UNIQUE constraint error if an identical
+// expense was added meanwhile.
+func (db *DB) RestoreExpense(id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	restored, err := rowsAffected(tx.Exec(
+		`INSERT INTO expenses (id, amount, description, category, date, user_id, ledger_id)
+		 SELECT id, amount, description, category, date, user_id, ledger_id FROM deleted_expenses WHERE id = ?`,
+		id,
+	))
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM deleted_expenses WHERE id = ?", id); err != nil {
+		return err
+	}
+	day, err := expenseDay(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := refreshAggregates(tx, day); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeDeletedExpenses forgets the expenses deleted before cutoff for good,
+// so they can no longer be restored, and returns how many it forgot.
+func (db *DB) PurgeDeletedExpenses(cutoff time.Time) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	purged, err := rowsAffected(tx.Exec("DELETE FROM deleted_expenses WHERE deleted_at < ?", cutoff.UTC()))
+	if err != nil {
+		return 0, err
+	}
+	if err := forgetOrphanDetails(tx); err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
+
+// expenseDetailTables keep details of expenses by expense ID.
+var expenseDetailTables = []string{"expense_locations", "expense_reimbursements", "expense_projects"}
+
+// forgetOrphanDetails deletes the details of expenses that are gone for
+// good, i.e. neither current, archived, nor restorable.
+func forgetOrphanDetails(ex execer) error {
+	for _, table := range expenseDetailTables {
+		if _, err := ex.Exec(`DELETE FROM ` + table + `
+			WHERE expense_id NOT IN (SELECT id FROM all_expenses) AND expense_id NOT IN (SELECT id FROM deleted_expenses)`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
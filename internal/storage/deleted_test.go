@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DeletedTestSuite provides a test suite for restoring deleted expenses
+type DeletedTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *DeletedTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *DeletedTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *DeletedTestSuite) TestRestore() {
+	s.Require().NoError(s.db.CreateExpense(12.5, "Lunch", "Eating Out", s.day, 1))
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	id := expenses[0].ID
+	s.Require().NoError(s.db.DeleteExpense(id))
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Zero(total)
+	version := s.latestChange()
+
+	s.Require().NoError(s.db.RestoreExpense(id))
+	restored, err := s.db.GetExpense(id)
+	s.Require().NoError(err)
+	s.Equal("Lunch", restored.Description, "with its ID")
+	total, err = s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(12.5, total)
+	changes, err := s.db.ExpenseChanges(version, 10)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1, "synced clients get it back")
+	s.NotNil(changes[0].Expense)
+
+	s.ErrorIs(s.db.RestoreExpense(id), sql.ErrNoRows, "restored already")
+	s.ErrorIs(s.db.RestoreExpense(404), sql.ErrNoRows)
+
+	s.Require().NoError(s.db.DeleteExpense(id))
+	s.Require().NoError(s.db.CreateExpense(12.5, "Lunch", "Eating Out", s.day, 1))
+	s.ErrorContains(s.db.RestoreExpense(id), "UNIQUE constraint failed", "added again meanwhile")
+}
+
+func (s *DeletedTestSuite) TestPurge() {
+	s.Require().NoError(s.db.CreateExpense(1, "Bus", "Transport", s.day, 1))
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.DeleteExpense(expenses[0].ID))
+
+	purged, err := s.db.PurgeDeletedExpenses(time.Now().Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Zero(purged, "deleted too recently")
+	purged, err = s.db.PurgeDeletedExpenses(time.Now().Add(time.Minute))
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+	s.ErrorIs(s.db.RestoreExpense(expenses[0].ID), sql.ErrNoRows)
+}
+
+// latestChange returns the version of the latest change to any expense.
+func (s *DeletedTestSuite) latestChange() int64 {
+	changes, err := s.db.ExpenseChanges(0, 100)
+	s.Require().NoError(err)
+	return changes[len(changes)-1].Version
+}
+
+// TestDeletedTestSuite runs the deleted expenses test suite
+func TestDeletedTestSuite(t *testing.T) {
+	suite.Run(t, new(DeletedTestSuite))
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// FindDuplicates returns groups of probable duplicates among the current
+// expenses: those of the same user with the same amount, dated at most window
+// after the first of their group. Groups are oldest first, and each is
+// ordered by ID, so the first expense is the one that was added first. A
+// userID other than 0 only looks at that user's expenses.
+func (db *DB) FindDuplicates(userID int64, window time.Duration) ([][]models.Expense, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses
+		WHERE ? = 0 OR user_id = ?
+		ORDER BY user_id, amount, date, id`,
+		userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := scanExpenses(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]models.Expense
+	var group []models.Expense
+	flush := func() {
+		if len(group) > 1 {
+			sort.Slice(group, func(i, j int) bool { return group[i].ID < group[j].ID })
+			groups = append(groups, group)
+		}
+		group = nil
+	}
+	for _, e := range expenses {
+		if len(group) > 0 && (!sameOwner(group[0], e) || group[0].Amount != e.Amount || e.Date.Sub(group[0].Date) > window) {
+			flush()
+		}
+		group = append(group, e)
+	}
+	flush()
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i][0].Date.Before(groups[j][0].Date) })
+	return groups, nil
+}
+
+// sameOwner reports whether two expenses belong to the same user, or both to
+// nobody.
+func sameOwner(a, b models.Expense) bool {
+	if a.UserID == nil || b.UserID == nil {
+		return a.UserID == nil && b.UserID == nil
+	}
+	return *a.UserID == *b.UserID
+}
+
+// DeleteExpenses deletes current expenses in one transaction and returns how
+// many it deleted.
+func (db *DB) DeleteExpenses(ids []int64) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var deleted int64
+	var days []string
+	for _, id := range ids {
+		day, err := expenseDay(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		n, err := rowsAffected(tx.Exec("DELETE FROM expenses WHERE id = ?", id))
+		if err != nil {
+			return 0, err
+		}
+		deleted += n
+		days = append(days, day)
+	}
+	if err := forgetOrphanDetails(tx); err != nil {
+		return 0, err
+	}
+	if err := refreshAggregates(tx, days...); err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DuplicatesTestSuite provides a test suite for finding duplicate expenses
+type DuplicatesTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *DuplicatesTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *DuplicatesTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *DuplicatesTestSuite) TestFindAndDelete() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(20, "Market", "Groceries", day, 1))
+	s.Require().NoError(s.db.CreateExpense(20, "MARKET 0310", "Groceries", day.Add(3*time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(20, "Bob's market", "Groceries", day, 2))            // Another user
+	s.Require().NoError(s.db.CreateExpense(20, "Market", "Groceries", day.AddDate(0, 0, 3), 1)) // Too late
+	s.Require().NoError(s.db.CreateExpense(21, "Market", "Groceries", day, 1))                  // Another amount
+
+	groups, err := s.db.FindDuplicates(0, 24*time.Hour)
+	s.Require().NoError(err)
+	s.Require().Len(groups, 1)
+	s.Require().Len(groups[0], 2)
+	s.Equal("Market", groups[0][0].Description, "the first added comes first")
+
+	none, err := s.db.FindDuplicates(2, 24*time.Hour)
+	s.Require().NoError(err)
+	s.Empty(none)
+
+	deleted, err := s.db.DeleteExpenses([]int64{groups[0][1].ID})
+	s.Require().NoError(err)
+	s.Equal(int64(1), deleted)
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(81.0, total, "statistics follow")
+}
+
+// TestDuplicatesSuite runs the duplicate expense test suite
+func TestDuplicatesSuite(t *testing.T) {
+	suite.Run(t, new(DuplicatesTestSuite))
+}
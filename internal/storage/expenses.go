@@ -1,6 +1,12 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"expense-tracker/internal/models"
@@ -8,25 +14,181 @@ import (
 
 // CreateExpense inserts a new expense into the database.
 func (db *DB) CreateExpense(amount float64, description, category string, date time.Time, userID int64) error {
-	if date.IsZero() {
-		date = time.Now()
+	return db.InsertExpense(&models.Expense{Amount: amount, Description: description, Category: category, Date: date, UserID: &userID})
+}
+
+// InsertExpense inserts e into the database, dated now if its date is zero
+// and in the personal ledger if it has none, and sets its ID.
+func (db *DB) InsertExpense(e *models.Expense) error {
+	return db.InsertExpenseWithDetails(e, ExpenseDetails{})
+}
+
+// ExpenseDetails are what InsertExpenseWithDetails records along with an
+// expense. The zero value records nothing.
+type ExpenseDetails struct {
+	Location     models.Location // Where it was made
+	Reimbursable bool            // To be paid back, pending for now
+	ProjectID    int64           // The project it belongs to
+}
+
+// InsertExpenseWithDetails inserts e like InsertExpense together with its
+// details, in one transaction so the expense isn't saved without them. It
+// returns sql.ErrNoRows if the project doesn't exist.
+func (db *DB) InsertExpenseWithDetails(e *models.Expense, d ExpenseDetails) error {
+	if e.Date.IsZero() {
+		e.Date = time.Now()
 	}
-	_, err := db.conn.Exec(
-		"INSERT INTO expenses (amount, description, category, date, user_id) VALUES (?, ?, ?, ?, ?)",
-		amount, description, category, date, userID,
+	if e.LedgerID == 0 {
+		e.LedgerID = models.PersonalLedgerID
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(
+		"INSERT INTO expenses (amount, description, category, date, user_id, ledger_id) VALUES (?, ?, ?, ?, ?, ?)",
+		e.Amount, e.Description, e.Category, e.Date, e.UserID, e.LedgerID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if !d.Location.IsZero() {
+		if err := setExpenseLocation(tx, id, d.Location); err != nil {
+			return err
+		}
+	}
+	if d.Reimbursable {
+		if err := setReimbursement(tx, id, models.ReimbursementPending); err != nil {
+			return err
+		}
+	}
+	if d.ProjectID != 0 {
+		if err := setExpenseProject(tx, id, d.ProjectID); err != nil {
+			return err
+		}
+	}
+	day, err := expenseDay(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := refreshAggregates(tx, day); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// ImportExpenses adds expenses for a user in one transaction, skipping those
+// that already exist with the same date, amount, and description, current or
+// archived. Expenses without a ledger go in the personal one. It returns how
+// many were added. With dryRun nothing is written, but the count is the
+// same.
+func (db *DB) ImportExpenses(expenses []models.Expense, userID int64, dryRun bool) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Parsed once rather than for every expense, which took a third of a
+	// large import's time
+	insert, err := tx.Prepare(
+		`INSERT INTO expenses (amount, description, category, date, user_id, ledger_id)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM all_expenses WHERE date = ? AND amount = ? AND description = ?)
+		RETURNING SUBSTR(date, 1, 10)`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	var days []string
+	for _, e := range expenses {
+		ledger := e.LedgerID
+		if ledger == 0 {
+			ledger = models.PersonalLedgerID
+		}
+		var day string
+		err := insert.QueryRow(e.Amount, e.Description, e.Category, e.Date, userID, ledger, e.Date, e.Amount, e.Description).Scan(&day)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		days = append(days, day)
+	}
+	if dryRun {
+		return len(days), nil
+	}
+	if err := refreshAggregates(tx, days...); err != nil {
+		return 0, err
+	}
+	return len(days), tx.Commit()
+}
+
+// ReassignResult counts the expenses ReassignExpenses moved.
+type ReassignResult struct {
+	Expenses int64   // Current expenses
+	Archived int64   // Archived expenses
+	Total    float64 // Sum of their amounts
+}
+
+// ReassignExpenses gives the current and archived expenses matching a filter
+// to another user in one transaction. The filter must name the user they are
+// taken from. With dryRun nothing is changed, but the result is the same.
+func (db *DB) ReassignExpenses(f ExpenseFilter, toUserID int64, dryRun bool) (ReassignResult, error) {
+	var result ReassignResult
+	if f.UserID == 0 {
+		return result, errors.New("reassigning needs the user the expenses belong to")
+	}
+	where, args := f.where()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM all_expenses WHERE "+where, args...).Scan(&result.Total); err != nil {
+		return result, err
+	}
+	for _, moved := range []struct {
+		table string
+		count *int64
+	}{{"expenses", &result.Expenses}, {"archived_expenses", &result.Archived}} {
+		n, err := rowsAffected(tx.Exec("UPDATE "+moved.table+" SET user_id = ? WHERE "+where, append([]any{toUserID}, args...)...))
+		if err != nil {
+			return result, err
+		}
+		*moved.count = n
+	}
+	if dryRun {
+		return result, nil
+	}
+	// The statistics don't depend on who an expense belongs to
+	return result, tx.Commit()
 }
 
 // GetExpense retrieves a single expense by ID.
 func (db *DB) GetExpense(id int64) (*models.Expense, error) {
 	row := db.conn.QueryRow(
-		"SELECT id, amount, description, category, date, user_id FROM expenses WHERE id = ?",
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE id = ?",
 		id,
 	)
 
 	var e models.Expense
-	if err := row.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID); err != nil {
+	if err := row.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID); err != nil {
 		return nil, err
 	}
 	return &e, nil
@@ -34,17 +196,81 @@ func (db *DB) GetExpense(id int64) (*models.Expense, error) {
 
 // UpdateExpense updates an existing expense in the database.
 func (db *DB) UpdateExpense(e *models.Expense) error {
-	_, err := db.conn.Exec(
+	return db.updateExpense(e, nil)
+}
+
+// updateExpense updates an expense, if version is set only while it hasn't
+// changed after it.
+func (db *DB) updateExpense(e *models.Expense, version *int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if version != nil {
+		if err := checkUnchanged(tx, e.ID, *version); err != nil {
+			return err
+		}
+	}
+	oldDay, err := expenseDay(tx, e.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
 		"UPDATE expenses SET amount = ?, description = ?, category = ?, date = ? WHERE id = ?",
 		e.Amount, e.Description, e.Category, e.Date, e.ID,
-	)
-	return err
+	); err != nil {
+		return err
+	}
+	newDay, err := expenseDay(tx, e.ID)
+	if err != nil {
+		return err
+	}
+	if err := refreshAggregates(tx, oldDay, newDay); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// DeleteExpense removes an expense from the database by ID.
+// DeleteExpense removes an expense from the database by ID. It stays
+// restorable with RestoreExpense until PurgeDeletedExpenses.
 func (db *DB) DeleteExpense(id int64) error {
-	_, err := db.conn.Exec("DELETE FROM expenses WHERE id = ?", id)
-	return err
+	return db.deleteExpense(id, nil)
+}
+
+// deleteExpense deletes an expense, if version is set only while it hasn't
+// changed after it.
+func (db *DB) deleteExpense(id int64, version *int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if version != nil {
+		if err := checkUnchanged(tx, id, *version); err != nil {
+			return err
+		}
+	}
+	day, err := expenseDay(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO deleted_expenses (id, amount, description, category, date, user_id, ledger_id, deleted_at)
+		 SELECT id, amount, description, category, date, user_id, ledger_id, ? FROM expenses WHERE id = ?`,
+		time.Now().UTC(), id,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM expenses WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := refreshAggregates(tx, day); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // ListExpenses retrieves expenses for the current month from the database, ordered by date descending.
@@ -53,30 +279,318 @@ func (db *DB) ListExpenses() ([]models.Expense, error) {
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 
+	return db.FilterExpenses(ExpenseFilter{From: startOfMonth})
+}
+
+// ExpenseFilter narrows down an expense listing. Zero values apply no restriction.
+type ExpenseFilter struct {
+	From     time.Time // Inclusive lower bound on the expense date
+	To       time.Time // Exclusive upper bound on the expense date
+	Category string    // Matched case-insensitively
+	Query    string    // Case-insensitive substring of the description
+	UserID   int64     // Owner of the expenses, zero for everyone's
+	Ledger   int64     // Ledger of the expenses, zero for all of them
+	Viewer   int64     // User who must see the ledger of the expenses, zero for anyone
+}
+
+// likeEscaper escapes LIKE wildcards so user input is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// FilterExpenses retrieves the expenses matching a filter, ordered by date descending.
+func (db *DB) FilterExpenses(f ExpenseFilter) ([]models.Expense, error) {
+	// Runs on every page of the expense list. The filter only picks which
+	// conditions to include, so there are few distinct queries to prepare.
+	where, args := f.where()
+	rows, err := db.conn.QueryPrepared(
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE "+where+" ORDER BY date DESC",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanExpenses(rows)
+}
+
+// PageExpenses retrieves at most limit of the expenses matching a filter,
+// newest first, after skipping offset of them. Expenses at the same time are
+// ordered by ID, so consecutive pages neither overlap nor leave gaps.
+func (db *DB) PageExpenses(f ExpenseFilter, limit, offset int) ([]models.Expense, error) {
+	where, args := f.where()
+	rows, err := db.conn.QueryPrepared(
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE "+where+" ORDER BY date DESC, id DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanExpenses(rows)
+}
+
+// SearchExpenses retrieves at most limit expenses of a ledger, from any date,
+// whose description or category contains every word of query, regardless of
+// case. They are ordered newest first. Ledger zero searches all ledgers.
+func (db *DB) SearchExpenses(query string, ledgerID int64, limit int) ([]models.Expense, error) {
+	conds := []string{"1 = 1"}
+	var args []any
+	for _, word := range strings.Fields(query) {
+		pattern := "%" + likeEscaper.Replace(word) + "%"
+		conds = append(conds, `(description LIKE ? ESCAPE '\' OR category LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if ledgerID != 0 {
+		conds = append(conds, "ledger_id = ?")
+		args = append(args, ledgerID)
+	}
 	rows, err := db.conn.Query(
-		"SELECT id, amount, description, category, date, user_id FROM expenses WHERE date >= ? ORDER BY date DESC",
-		startOfMonth,
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE "+strings.Join(conds, " AND ")+" ORDER BY date DESC, id DESC LIMIT ?",
+		append(args, limit)...,
 	)
 	if err != nil {
 		return nil, err
 	}
+	return scanExpenses(rows)
+}
+
+// ExpenseCursor marks a place in the newest-first order of the expenses, for
+// ScrollExpenses to continue from. The zero cursor is the start.
+type ExpenseCursor struct {
+	Date string // As stored, so it compares exactly
+	ID   int64
+}
+
+// ScrollExpenses retrieves at most limit of the expenses matching a filter,
+// newest first, starting after a cursor. It also returns the cursor for the
+// next page, which is zero after the last one. Unlike an offset, the cursor
+// stays put when expenses are added in front of it, so a client paging
+// through a long history while others add expenses neither sees one twice
+// nor misses one.
+func (db *DB) ScrollExpenses(f ExpenseFilter, after ExpenseCursor, limit int) ([]models.Expense, ExpenseCursor, error) {
+	where, args := f.where()
+	if after != (ExpenseCursor{}) {
+		// The upper bound on the date lets the page start from the index
+		where += " AND date <= ? AND (date < ? OR id < ?)"
+		args = append(args, after.Date, after.Date, after.ID)
+	}
+	rows, err := db.conn.QueryPrepared(
+		"SELECT id, amount, description, category, date, user_id, ledger_id, date || '' FROM expenses WHERE "+where+" ORDER BY date DESC, id DESC LIMIT ?",
+		append(args, limit+1)...,
+	)
+	if err != nil {
+		return nil, ExpenseCursor{}, err
+	}
 	defer rows.Close()
 
 	var expenses []models.Expense
+	var next ExpenseCursor
 	for rows.Next() {
 		var e models.Expense
-		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID); err != nil {
-			return nil, err
+		var stored string
+		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID, &stored); err != nil {
+			return nil, ExpenseCursor{}, err
+		}
+		// The extra expense only tells there is another page
+		if len(expenses) == limit {
+			return expenses, next, rows.Err()
 		}
 		expenses = append(expenses, e)
+		next = ExpenseCursor{Date: stored, ID: e.ID}
 	}
+	return expenses, ExpenseCursor{}, rows.Err()
+}
 
-	return expenses, rows.Err()
+// exportBatchSize is how many expenses StreamExpenses reads per query.
+const exportBatchSize = 1000
+
+// ExportExpenses retrieves the current and archived expenses matching a
+// filter, oldest first.
+func (db *DB) ExportExpenses(f ExpenseFilter) ([]models.Expense, error) {
+	var expenses []models.Expense
+	err := db.StreamExpenses(f, func(e models.Expense) error {
+		expenses = append(expenses, e)
+		return nil
+	})
+	return expenses, err
+}
+
+// StreamExpenses calls fn with each of the current and archived expenses
+// matching a filter, oldest first, and stops at the first error fn returns.
+// The expenses are read a batch at a time, continuing after the last one
+// seen, so memory stays flat however many there are and no connection is
+// held while fn writes them out to a slow client.
+func (db *DB) StreamExpenses(f ExpenseFilter, fn func(models.Expense) error) error {
+	where, args := f.where()
+	// The date is also read as stored, to continue from it exactly. The
+	// lower bound on it lets each batch start from the index rather than
+	// scan every expense before it.
+	query := "SELECT id, amount, description, category, date, user_id, ledger_id, date || '' FROM all_expenses WHERE " + where +
+		" AND date >= ? AND (date > ? OR id > ?) ORDER BY date, id LIMIT ?"
+	batch := make([]models.Expense, 0, exportBatchSize)
+	var lastDate string
+	var lastID int64
+	for {
+		rows, err := db.conn.QueryPrepared(query, append(args, lastDate, lastDate, lastID, exportBatchSize)...)
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+		for rows.Next() {
+			var e models.Expense
+			if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID, &lastDate); err != nil {
+				rows.Close()
+				return err
+			}
+			lastID = e.ID
+			batch = append(batch, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, e := range batch {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// LatestExpenseDate returns the date of the most recent expense matching a
+// filter, or the zero time if nothing matches.
+func (db *DB) LatestExpenseDate(f ExpenseFilter) (time.Time, error) {
+	where, args := f.where()
+	var date time.Time
+	err := db.conn.QueryRowPrepared(
+		"SELECT date FROM expenses WHERE "+where+" ORDER BY date DESC LIMIT 1",
+		args...,
+	).Scan(&date)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return date, err
+}
+
+// ExpenseSummary holds headline figures for the expenses matching a filter.
+type ExpenseSummary struct {
+	Count         int
+	Total         float64
+	AveragePerDay float64
+	Largest       *models.Expense // nil when nothing matches
+}
+
+// SummarizeExpenses computes the count, total, average per day, and largest
+// expense for a filter. The average spreads the total over the calendar days
+// of the filter's period, which ends today if the period is still open. With
+// no lower bound the period starts at the earliest matching expense.
+func (db *DB) SummarizeExpenses(f ExpenseFilter) (*ExpenseSummary, error) {
+	where, args := f.where()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	summary := &ExpenseSummary{}
+	if err := tx.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM expenses WHERE "+where,
+		args...,
+	).Scan(&summary.Count, &summary.Total); err != nil {
+		return nil, err
+	}
+	if summary.Count == 0 {
+		return summary, tx.Commit()
+	}
+
+	rows, err := tx.Query(
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE "+where+" ORDER BY amount DESC, date DESC LIMIT 1",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	largest, err := scanExpenses(rows)
+	if err != nil {
+		return nil, err
+	}
+	summary.Largest = &largest[0]
+
+	from := f.From
+	if from.IsZero() {
+		if err := tx.QueryRow(
+			"SELECT date FROM expenses WHERE "+where+" ORDER BY date LIMIT 1",
+			args...,
+		).Scan(&from); err != nil {
+			return nil, err
+		}
+	}
+	summary.AveragePerDay = summary.Total / float64(periodDays(from, f.To, time.Now()))
+
+	return summary, tx.Commit()
+}
+
+// periodDays counts the calendar days from the day of from up to the
+// exclusive bound to, or through the day of now if to is unset or later.
+func periodDays(from, to, now time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if !to.IsZero() && to.Before(end) {
+		end = to
+	}
+	// Round rather than truncate so DST transitions don't drop a day
+	days := int(math.Round(end.Sub(start).Hours() / 24))
+	return max(days, 1)
+}
+
+// where builds the SQL condition and bind arguments for the filter.
+func (f ExpenseFilter) where() (string, []any) {
+	conds := []string{"1 = 1"}
+	var args []any
+	if !f.From.IsZero() {
+		conds = append(conds, "date >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "date < ?")
+		args = append(args, f.To)
+	}
+	if f.Category != "" {
+		conds = append(conds, "category = ? COLLATE NOCASE")
+		args = append(args, f.Category)
+	}
+	if f.Query != "" {
+		conds = append(conds, `description LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	if f.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Ledger != 0 {
+		conds = append(conds, "ledger_id = ?")
+		args = append(args, f.Ledger)
+	}
+	if f.Viewer != 0 {
+		conds = append(conds, "ledger_id "+visibleLedgers)
+		args = append(args, f.Viewer)
+	}
+	return strings.Join(conds, " AND "), args
 }
 
 // ClearExpenses deletes all expenses from the database (used for testing).
 func (db *DB) ClearExpenses() error {
-	_, err := db.conn.Exec("DELETE FROM expenses")
+	if _, err := db.conn.Exec("DELETE FROM expenses"); err != nil {
+		return err
+	}
+	if _, err := db.conn.Exec("DELETE FROM archived_expenses"); err != nil {
+		return err
+	}
+	if err := forgetOrphanDetails(db.conn); err != nil {
+		return err
+	}
+	_, err := db.conn.Exec("DELETE FROM daily_aggregates")
 	return err
 }
 
@@ -86,7 +600,7 @@ func (db *DB) GetExpensesByMonth(year, month int) ([]models.Expense, error) {
 	endOfMonth := startOfMonth.AddDate(0, 1, 0)
 
 	rows, err := db.conn.Query(
-		"SELECT id, amount, description, category, date, user_id FROM expenses WHERE date >= ? AND date < ? ORDER BY date DESC",
+		"SELECT id, amount, description, category, date, user_id, ledger_id FROM expenses WHERE date >= ? AND date < ? ORDER BY date DESC",
 		startOfMonth, endOfMonth,
 	)
 	if err != nil {
@@ -97,7 +611,7 @@ func (db *DB) GetExpensesByMonth(year, month int) ([]models.Expense, error) {
 	var expenses []models.Expense
 	for rows.Next() {
 		var e models.Expense
-		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID); err != nil {
+		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID); err != nil {
 			return nil, err
 		}
 		expenses = append(expenses, e)
@@ -113,18 +627,48 @@ type CategoryTotal struct {
 	Count    int
 }
 
+// CategoryTotals sums up the expenses matching a filter per category, the
+// largest total first.
+func (db *DB) CategoryTotals(f ExpenseFilter) ([]CategoryTotal, error) {
+	where, args := f.where()
+	rows, err := db.conn.QueryPrepared(
+		"SELECT category, SUM(amount) AS total, COUNT(*) FROM expenses WHERE "+where+" GROUP BY category ORDER BY total DESC, category",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []CategoryTotal
+	for rows.Next() {
+		var t CategoryTotal
+		if err := rows.Scan(&t.Category, &t.Total, &t.Count); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// The statistics below are those of one ledger, or of all of them for
+// ledger zero.
+
 // GetCategoryTotalsByMonth retrieves spending totals by category for a specific month.
-func (db *DB) GetCategoryTotalsByMonth(year, month int) ([]CategoryTotal, error) {
+func (db *DB) GetCategoryTotalsByMonth(ledgerID int64, year, month int) ([]CategoryTotal, error) {
 	startOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	endOfMonth := startOfMonth.AddDate(0, 1, 0)
+	return db.categoryTotalsBetween(ledgerID, startOfMonth, startOfMonth.AddDate(0, 1, 0))
+}
 
+// categoryTotalsBetween sums the daily aggregates per category for days in [start, end).
+func (db *DB) categoryTotalsBetween(ledgerID int64, start, end time.Time) ([]CategoryTotal, error) {
 	rows, err := db.conn.Query(
-		`SELECT category, SUM(amount) as total, COUNT(*) as count 
-		 FROM expenses 
-		 WHERE date >= ? AND date < ? 
-		 GROUP BY category 
+		`SELECT category, SUM(total) as total, SUM(count) as count
+		 FROM daily_aggregates
+		 WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)
+		 GROUP BY category
 		 ORDER BY total DESC`,
-		startOfMonth, endOfMonth,
+		start.Format(time.DateOnly), end.Format(time.DateOnly), ledgerID, ledgerID,
 	)
 	if err != nil {
 		return nil, err
@@ -150,18 +694,18 @@ type MonthlyTotal struct {
 }
 
 // GetMonthlyTotalsForYear retrieves spending totals by month for a specific year.
-func (db *DB) GetMonthlyTotalsForYear(year int) ([]MonthlyTotal, error) {
+func (db *DB) GetMonthlyTotalsForYear(ledgerID int64, year int) ([]MonthlyTotal, error) {
 	startOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
 	endOfYear := startOfYear.AddDate(1, 0, 0)
 
-	// Use SUBSTR to extract month from ISO 8601 format (YYYY-MM-DDTHH:MM:SSZ)
+	// Use SUBSTR to extract month from the aggregate day (YYYY-MM-DD)
 	rows, err := db.conn.Query(
-		`SELECT CAST(SUBSTR(date, 6, 2) AS INTEGER) as month, SUM(amount) as total 
-		 FROM expenses 
-		 WHERE date >= ? AND date < ? 
-		 GROUP BY SUBSTR(date, 6, 2) 
+		`SELECT CAST(SUBSTR(day, 6, 2) AS INTEGER) as month, SUM(total) as total
+		 FROM daily_aggregates
+		 WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)
+		 GROUP BY SUBSTR(day, 6, 2)
 		 ORDER BY month`,
-		startOfYear, endOfYear,
+		startOfYear.Format(time.DateOnly), endOfYear.Format(time.DateOnly), ledgerID, ledgerID,
 	)
 	if err != nil {
 		return nil, err
@@ -187,18 +731,18 @@ type DailyTotal struct {
 }
 
 // GetDailyTotalsForMonth retrieves spending totals by day for a specific month.
-func (db *DB) GetDailyTotalsForMonth(year, month int) ([]DailyTotal, error) {
+func (db *DB) GetDailyTotalsForMonth(ledgerID int64, year, month int) ([]DailyTotal, error) {
 	startOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 	endOfMonth := startOfMonth.AddDate(0, 1, 0)
 
-	// Use SUBSTR to extract day from ISO 8601 format (YYYY-MM-DDTHH:MM:SSZ)
+	// Use SUBSTR to extract day of month from the aggregate day (YYYY-MM-DD)
 	rows, err := db.conn.Query(
-		`SELECT CAST(SUBSTR(date, 9, 2) AS INTEGER) as day, SUM(amount) as total 
-		 FROM expenses 
-		 WHERE date >= ? AND date < ? 
-		 GROUP BY SUBSTR(date, 9, 2) 
-		 ORDER BY day`,
-		startOfMonth, endOfMonth,
+		`SELECT CAST(SUBSTR(day, 9, 2) AS INTEGER) as dom, SUM(total) as total
+		 FROM daily_aggregates
+		 WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)
+		 GROUP BY day
+		 ORDER BY dom`,
+		startOfMonth.Format(time.DateOnly), endOfMonth.Format(time.DateOnly), ledgerID, ledgerID,
 	)
 	if err != nil {
 		return nil, err
@@ -220,7 +764,7 @@ func (db *DB) GetDailyTotalsForMonth(year, month int) ([]DailyTotal, error) {
 // GetTotalForPeriod retrieves the total spending for a period.
 // If month is 0, it returns the total for the entire year.
 // Otherwise, it returns the total for the specific month.
-func (db *DB) GetTotalForPeriod(year, month int) (float64, error) {
+func (db *DB) GetTotalForPeriod(ledgerID int64, year, month int) (float64, error) {
 	var startDate, endDate time.Time
 
 	if month == 0 {
@@ -235,65 +779,133 @@ func (db *DB) GetTotalForPeriod(year, month int) (float64, error) {
 
 	var total float64
 	err := db.conn.QueryRow(
-		`SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE date >= ? AND date < ?`,
-		startDate, endDate,
+		`SELECT COALESCE(SUM(total), 0) FROM daily_aggregates WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)`,
+		startDate.Format(time.DateOnly), endDate.Format(time.DateOnly), ledgerID, ledgerID,
 	).Scan(&total)
 
 	return total, err
 }
 
-// GetExpensesByYear retrieves all expenses for a specific year.
-func (db *DB) GetExpensesByYear(year int) ([]models.Expense, error) {
+// GetTotalForCycle retrieves the total spending for the budget period that
+// begins on the day of start and runs for a month, as the list header
+// compares it to the user's budget.
+func (db *DB) GetTotalForCycle(ledgerID int64, start time.Time) (float64, error) {
+	return db.GetTotalBetween(ledgerID, start, start.AddDate(0, 1, 0))
+}
+
+// GetTotalBetween retrieves the total spending for the days from the day of
+// start up to the day of end, exclusive.
+func (db *DB) GetTotalBetween(ledgerID int64, start, end time.Time) (float64, error) {
+	var total float64
+	err := db.conn.QueryRow(
+		`SELECT COALESCE(SUM(total), 0) FROM daily_aggregates WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)`,
+		start.Format(time.DateOnly), end.Format(time.DateOnly), ledgerID, ledgerID,
+	).Scan(&total)
+
+	return total, err
+}
+
+// GetCategoryTotalsByYear retrieves spending totals by category for a specific year.
+func (db *DB) GetCategoryTotalsByYear(ledgerID int64, year int) ([]CategoryTotal, error) {
 	startOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
-	endOfYear := startOfYear.AddDate(1, 0, 0)
+	return db.categoryTotalsBetween(ledgerID, startOfYear, startOfYear.AddDate(1, 0, 0))
+}
+
+// MonthStats bundles the totals the month statistics view needs. Its
+// expenses are fetched a page at a time with PageExpenses.
+type MonthStats struct {
+	Total      float64
+	PrevTotal  float64
+	Categories []CategoryTotal
+	Daily      []DailyTotal
+}
+
+// GetMonthStats retrieves the totals, category breakdown, and daily totals
+// for a month, plus the previous month's total, in one pass over the daily
+// aggregates.
+func (db *DB) GetMonthStats(ledgerID int64, year, month int) (*MonthStats, error) {
+	startOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	endOfMonth := startOfMonth.AddDate(0, 1, 0)
+	startOfPrev := startOfMonth.AddDate(0, -1, 0)
 
 	rows, err := db.conn.Query(
-		"SELECT id, amount, description, category, date, user_id FROM expenses WHERE date >= ? AND date < ? ORDER BY date DESC",
-		startOfYear, endOfYear,
+		`SELECT day, category, total, count
+		 FROM daily_aggregates
+		 WHERE day >= ? AND day < ? AND (? = 0 OR ledger_id = ?)
+		 ORDER BY day`,
+		startOfPrev.Format(time.DateOnly), endOfMonth.Format(time.DateOnly), ledgerID, ledgerID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var expenses []models.Expense
+	stats := &MonthStats{}
+	monthStart := startOfMonth.Format(time.DateOnly)
+	categories := make(map[string]*CategoryTotal)
+	dailyIndex := make(map[int]int)
 	for rows.Next() {
-		var e models.Expense
-		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID); err != nil {
+		var day, category string
+		var total float64
+		var count int
+		if err := rows.Scan(&day, &category, &total, &count); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		expenses = append(expenses, e)
-	}
-
-	return expenses, rows.Err()
-}
+		if day < monthStart {
+			stats.PrevTotal += total
+			continue
+		}
+		stats.Total += total
 
-// GetCategoryTotalsByYear retrieves spending totals by category for a specific year.
-func (db *DB) GetCategoryTotalsByYear(year int) ([]CategoryTotal, error) {
-	startOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
-	endOfYear := startOfYear.AddDate(1, 0, 0)
+		ct, ok := categories[category]
+		if !ok {
+			ct = &CategoryTotal{Category: category}
+			categories[category] = ct
+		}
+		ct.Total += total
+		ct.Count += count
 
-	rows, err := db.conn.Query(
-		`SELECT category, SUM(amount) as total, COUNT(*) as count 
-		 FROM expenses 
-		 WHERE date >= ? AND date < ? 
-		 GROUP BY category 
-		 ORDER BY total DESC`,
-		startOfYear, endOfYear,
-	)
-	if err != nil {
+		// Day of month from YYYY-MM-DD
+		dom, err := strconv.Atoi(day[8:10])
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i, ok := dailyIndex[dom]; ok {
+			stats.Daily[i].Total += total
+		} else {
+			dailyIndex[dom] = len(stats.Daily)
+			stats.Daily = append(stats.Daily, DailyTotal{Day: dom, Total: total})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+
+	for _, ct := range categories {
+		stats.Categories = append(stats.Categories, *ct)
+	}
+	sort.Slice(stats.Categories, func(i, j int) bool {
+		if stats.Categories[i].Total != stats.Categories[j].Total {
+			return stats.Categories[i].Total > stats.Categories[j].Total
+		}
+		return stats.Categories[i].Category < stats.Categories[j].Category
+	})
+	return stats, nil
+}
+
+// scanExpenses reads all rows of an expense SELECT and closes them.
+func scanExpenses(rows *sql.Rows) ([]models.Expense, error) {
 	defer rows.Close()
 
-	var totals []CategoryTotal
+	var expenses []models.Expense
 	for rows.Next() {
-		var ct CategoryTotal
-		if err := rows.Scan(&ct.Category, &ct.Total, &ct.Count); err != nil {
+		var e models.Expense
+		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID); err != nil {
 			return nil, err
 		}
-		totals = append(totals, ct)
+		expenses = append(expenses, e)
 	}
-
-	return totals, rows.Err()
+	return expenses, rows.Err()
 }
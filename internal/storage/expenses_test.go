@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
+	"expense-tracker/internal/models"
+
 	"github.com/stretchr/testify/suite"
 )
 
@@ -32,6 +37,47 @@ func (s *ExpenseTestSuite) TestCreateExpense() {
 	s.NoError(err)
 }
 
+func (s *ExpenseTestSuite) TestInsertExpense() {
+	userID := int64(1)
+	e := &models.Expense{Amount: 10.50, Description: "Lunch", Category: "Eating Out", UserID: &userID}
+	s.Require().NoError(s.db.InsertExpense(e))
+	s.NotZero(e.ID)
+	s.False(e.Date.IsZero(), "dated now")
+
+	stored, err := s.db.GetExpense(e.ID)
+	s.Require().NoError(err)
+	s.Equal("Lunch", stored.Description)
+
+	again := *e
+	s.Error(s.db.InsertExpense(&again), "same date, amount, and description")
+}
+
+func (s *ExpenseTestSuite) TestInsertExpenseWithDetails() {
+	project, err := s.db.CreateProject("Japan trip")
+	s.Require().NoError(err)
+	e := &models.Expense{Amount: 30, Description: "Ramen", Category: "Eating Out"}
+	loc := at(35.6762, 139.6503, "Tokyo")
+	s.Require().NoError(s.db.InsertExpenseWithDetails(e, ExpenseDetails{Location: loc, Reimbursable: true, ProjectID: project.ID}))
+
+	stored, err := s.db.GetExpenseLocation(e.ID)
+	s.Require().NoError(err)
+	s.Equal("Tokyo", stored.Place)
+	status, err := s.db.GetReimbursement(e.ID)
+	s.Require().NoError(err)
+	s.Equal(models.ReimbursementPending, status)
+	projectID, err := s.db.GetExpenseProject(e.ID)
+	s.Require().NoError(err)
+	s.Equal(project.ID, projectID)
+
+	// Nothing is saved when a detail can't be
+	e = &models.Expense{Amount: 12, Description: "Sushi", Category: "Eating Out"}
+	err = s.db.InsertExpenseWithDetails(e, ExpenseDetails{Location: loc, ProjectID: project.ID + 1})
+	s.ErrorIs(err, sql.ErrNoRows)
+	expenses, err := s.db.ListExpenses()
+	s.Require().NoError(err)
+	s.Len(expenses, 1)
+}
+
 func (s *ExpenseTestSuite) TestDeleteExpense() {
 	// Create an expense
 	err := s.db.CreateExpense(25.00, "Dinner", "food", time.Now(), 1)
@@ -258,7 +304,7 @@ func (s *ExpenseTestSuite) TestGetCategoryTotalsByMonth() {
 	}
 
 	// Test getting category totals for January 2026
-	totals, err := s.db.GetCategoryTotalsByMonth(2026, 1)
+	totals, err := s.db.GetCategoryTotalsByMonth(0, 2026, 1)
 	s.Require().NoError(err)
 	s.Len(totals, 3, "expected 3 categories in January 2026")
 
@@ -287,7 +333,7 @@ func (s *ExpenseTestSuite) TestGetCategoryTotalsByMonth() {
 	s.Equal("eating out", totals[2].Category)
 
 	// Test getting category totals for February 2026
-	febTotals, err := s.db.GetCategoryTotalsByMonth(2026, 2)
+	febTotals, err := s.db.GetCategoryTotalsByMonth(0, 2026, 2)
 	s.Require().NoError(err)
 	s.Len(febTotals, 1, "expected 1 category in February 2026")
 	if s.Len(febTotals, 1) {
@@ -297,7 +343,7 @@ func (s *ExpenseTestSuite) TestGetCategoryTotalsByMonth() {
 	}
 
 	// Test getting category totals for a month with no expenses
-	novTotals, err := s.db.GetCategoryTotalsByMonth(2025, 11)
+	novTotals, err := s.db.GetCategoryTotalsByMonth(0, 2025, 11)
 	s.Require().NoError(err)
 	s.Empty(novTotals, "expected 0 categories in November 2025")
 }
@@ -321,7 +367,7 @@ func (s *ExpenseTestSuite) TestGetCategoryTotalsByMonth_SingleCategory() {
 		s.Require().NoError(err)
 	}
 
-	totals, err := s.db.GetCategoryTotalsByMonth(2026, 1)
+	totals, err := s.db.GetCategoryTotalsByMonth(0, 2026, 1)
 	s.Require().NoError(err)
 	s.Len(totals, 1, "expected 1 category")
 	if s.Len(totals, 1) {
@@ -360,7 +406,441 @@ func (s *ExpenseTestSuite) TestGetExpensesByMonth_EdgeCases() {
 	}
 }
 
+func (s *ExpenseTestSuite) TestFilterExpenses_Category() {
+	jan2026 := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	s.Require().NoError(s.db.CreateExpense(10.00, "Apples", "Groceries", jan2026, 1))
+	s.Require().NoError(s.db.CreateExpense(20.00, "Bus", "Transport", jan2026.Add(time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(30.00, "Bread", "groceries", jan2026.Add(2*time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(40.00, "Old Milk", "Groceries", jan2026.AddDate(0, -1, 0), 1))
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{
+		From:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		Category: "groceries",
+	})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2, "category match should be case-insensitive and respect the date range")
+	s.Equal("Bread", expenses[0].Description)
+	s.Equal("Apples", expenses[1].Description)
+
+	all, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Len(all, 4, "an empty filter should return everything")
+}
+
+func (s *ExpenseTestSuite) TestPageExpenses() {
+	jan2026 := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	for i, desc := range []string{"First", "Second", "Third", "Fourth", "Fifth"} {
+		s.Require().NoError(s.db.CreateExpense(float64(i+1), desc, "Groceries", jan2026.Add(time.Duration(i)*time.Hour), 1))
+	}
+	// Same time as the newest, so only the ID tells them apart
+	s.Require().NoError(s.db.CreateExpense(9, "Also fifth", "groceries", jan2026.Add(4*time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(7, "Bus", "Transport", jan2026, 1))
+
+	filter := ExpenseFilter{Category: "groceries"}
+	var pages [][]string
+	for offset := 0; ; offset += 4 {
+		page, err := s.db.PageExpenses(filter, 4, offset)
+		s.Require().NoError(err)
+		if len(page) == 0 {
+			break
+		}
+		var descs []string
+		for _, e := range page {
+			descs = append(descs, e.Description)
+		}
+		pages = append(pages, descs)
+	}
+	s.Equal([][]string{{"Also fifth", "Fifth", "Fourth", "Third"}, {"Second", "First"}}, pages)
+}
+
+func (s *ExpenseTestSuite) TestFilterExpenses_Query() {
+	jan2026 := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	s.Require().NoError(s.db.CreateExpense(10.00, "Pharmacy receipt", "Health", jan2026, 1))
+	s.Require().NoError(s.db.CreateExpense(20.00, "PHARMACY vitamins", "Health", jan2026.Add(time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(30.00, "100% juice", "Groceries", jan2026.Add(2*time.Hour), 1))
+	s.Require().NoError(s.db.CreateExpense(40.00, "Bus", "Transport", jan2026.Add(3*time.Hour), 1))
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{Query: "pharmacy"})
+	s.Require().NoError(err)
+	s.Len(expenses, 2, "search should be case-insensitive")
+
+	// LIKE wildcards in the search text must be matched literally
+	expenses, err = s.db.FilterExpenses(ExpenseFilter{Query: "%"})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal("100% juice", expenses[0].Description)
+
+	expenses, err = s.db.FilterExpenses(ExpenseFilter{Query: "'; DROP TABLE expenses; --"})
+	s.Require().NoError(err)
+	s.Empty(expenses)
+}
+
+func (s *ExpenseTestSuite) TestSearchExpenses() {
+	jan2025 := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	mar2026 := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	s.Require().NoError(s.db.CreateExpense(10.00, "Pharmacy receipt", "Health", jan2025, 1))
+	s.Require().NoError(s.db.CreateExpense(20.00, "Vitamins", "Health", mar2026, 1))
+	s.Require().NoError(s.db.CreateExpense(30.00, "Pharmacy", "Shopping", mar2026.Add(time.Hour), 1))
+	ledger, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.InsertExpense(&models.Expense{Amount: 40, Description: "Pharmacy", Category: "Health", Date: mar2026.Add(2 * time.Hour), LedgerID: ledger.ID}))
+
+	expenses, err := s.db.SearchExpenses("pharmacy", models.PersonalLedgerID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2, "from any month, in the ledger given")
+	s.Equal("Pharmacy", expenses[0].Description, "newest first")
+	s.Equal("Pharmacy receipt", expenses[1].Description)
+
+	expenses, err = s.db.SearchExpenses("health", models.PersonalLedgerID, 10)
+	s.Require().NoError(err)
+	s.Len(expenses, 2, "categories match too")
+
+	expenses, err = s.db.SearchExpenses("  HEALTH  pharmacy ", models.PersonalLedgerID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1, "every word has to match")
+	s.Equal("Pharmacy receipt", expenses[0].Description)
+
+	expenses, err = s.db.SearchExpenses("pharmacy", 0, 1)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(ledger.ID, expenses[0].LedgerID)
+
+	expenses, err = s.db.SearchExpenses("%", 0, 10)
+	s.Require().NoError(err)
+	s.Empty(expenses, "LIKE wildcards are matched literally")
+}
+
+func (s *ExpenseTestSuite) TestGetMonthStats() {
+	testExpenses := []struct {
+		amount   float64
+		desc     string
+		category string
+		date     time.Time
+	}{
+		{100.00, "Groceries 1", "groceries", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{50.00, "Bus", "transport", time.Date(2026, 3, 2, 13, 0, 0, 0, time.UTC)},
+		{25.00, "Groceries 2", "groceries", time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)},
+		// Previous month, only counts towards PrevTotal
+		{80.00, "February", "groceries", time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC)},
+		// Next month, ignored entirely
+		{999.00, "April", "groceries", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, exp := range testExpenses {
+		s.Require().NoError(s.db.CreateExpense(exp.amount, exp.desc, exp.category, exp.date, 1))
+	}
+
+	stats, err := s.db.GetMonthStats(0, 2026, 3)
+	s.Require().NoError(err)
+
+	s.InDelta(175.00, stats.Total, 0.001)
+	s.InDelta(80.00, stats.PrevTotal, 0.001)
+
+	s.Require().Len(stats.Categories, 2)
+	s.Equal("groceries", stats.Categories[0].Category)
+	s.InDelta(125.00, stats.Categories[0].Total, 0.001)
+	s.Equal(2, stats.Categories[0].Count)
+	s.Equal("transport", stats.Categories[1].Category)
+
+	s.Require().Len(stats.Daily, 2)
+	s.Equal(2, stats.Daily[0].Day)
+	s.InDelta(150.00, stats.Daily[0].Total, 0.001)
+	s.Equal(20, stats.Daily[1].Day)
+}
+
+func (s *ExpenseTestSuite) TestExportExpenses() {
+	s.Require().NoError(s.db.CreateExpense(10, "Old", "Other", time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(20, "Mine", "Other", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Theirs", "Other", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), 2))
+	_, err := s.db.ArchiveExpenses(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	all, err := s.db.ExportExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(all, 3, "archived expenses are included")
+	s.Equal([]string{"Old", "Theirs", "Mine"}, []string{all[0].Description, all[1].Description, all[2].Description})
+
+	mine, err := s.db.ExportExpenses(ExpenseFilter{From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), UserID: 1})
+	s.Require().NoError(err)
+	s.Require().Len(mine, 1)
+	s.Equal("Mine", mine[0].Description)
+}
+
+func (s *ExpenseTestSuite) TestScrollExpenses() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, description := range []string{"A", "B", "C", "D", "E"} {
+		// Two at the same time, which the ID tells apart
+		s.Require().NoError(s.db.CreateExpense(float64(i+1), description, "Other", day.Add(time.Duration(min(i, 3))*time.Hour), 1))
+	}
+	descriptions := func(expenses []models.Expense) []string {
+		var names []string
+		for _, e := range expenses {
+			names = append(names, e.Description)
+		}
+		return names
+	}
+
+	page, next, err := s.db.ScrollExpenses(ExpenseFilter{}, ExpenseCursor{}, 2)
+	s.Require().NoError(err)
+	s.Equal([]string{"E", "D"}, descriptions(page))
+	s.NotZero(next)
+
+	// A new expense doesn't shift the following pages
+	s.Require().NoError(s.db.CreateExpense(6, "New", "Other", day.Add(time.Hour*24), 1))
+	page, next, err = s.db.ScrollExpenses(ExpenseFilter{}, next, 2)
+	s.Require().NoError(err)
+	s.Equal([]string{"C", "B"}, descriptions(page))
+	page, next, err = s.db.ScrollExpenses(ExpenseFilter{}, next, 2)
+	s.Require().NoError(err)
+	s.Equal([]string{"A"}, descriptions(page))
+	s.Zero(next, "the last page")
+
+	page, next, err = s.db.ScrollExpenses(ExpenseFilter{Query: "new"}, ExpenseCursor{}, 1)
+	s.Require().NoError(err)
+	s.Equal([]string{"New"}, descriptions(page))
+	s.Zero(next, "no empty page after an exactly full one")
+}
+
+func (s *ExpenseTestSuite) TestStreamExpenses() {
+	// More than a batch, with several expenses at the same time, some of
+	// them archived
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	expenses := make([]models.Expense, 2500)
+	for i := range expenses {
+		expenses[i] = models.Expense{
+			Amount:      1,
+			Description: strconv.Itoa(i),
+			Category:    "Other",
+			Date:        start.Add(time.Duration(i/3) * time.Hour),
+		}
+	}
+	_, err := s.db.ImportExpenses(expenses, 1, false)
+	s.Require().NoError(err)
+	_, err = s.db.ArchiveExpenses(start.Add(100 * time.Hour))
+	s.Require().NoError(err)
+
+	var seen []string
+	err = s.db.StreamExpenses(ExpenseFilter{}, func(e models.Expense) error {
+		seen = append(seen, e.Description)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Require().Len(seen, len(expenses), "each expense once")
+	for i, description := range seen {
+		s.Equal(strconv.Itoa(i), description, "oldest first")
+	}
+
+	// Stops at the first error of the callback
+	stop := errors.New("stop")
+	calls := 0
+	err = s.db.StreamExpenses(ExpenseFilter{UserID: 1}, func(models.Expense) error {
+		calls++
+		return stop
+	})
+	s.ErrorIs(err, stop)
+	s.Equal(1, calls)
+}
+
+func (s *ExpenseTestSuite) TestImportExpenses() {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", day, 1))
+	expenses := []models.Expense{
+		{Amount: 4.5, Description: "Coffee", Category: "Eating Out", Date: day},
+		{Amount: 30, Description: "Supermarket", Category: "Groceries", Date: day},
+		{Amount: 30, Description: "Supermarket", Category: "Groceries", Date: day},
+		{Amount: 12, Description: "Cinema", Category: "Entertainment", Date: day.AddDate(0, 0, 1)},
+	}
+
+	added, err := s.db.ImportExpenses(expenses, 1, true)
+	s.Require().NoError(err)
+	s.Equal(2, added, "existing expenses and repeats in the file are skipped")
+	total, err := s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(4.5, total, 0.001, "a dry run writes nothing")
+
+	added, err = s.db.ImportExpenses(expenses, 1, false)
+	s.Require().NoError(err)
+	s.Equal(2, added)
+	total, err = s.db.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(46.5, total, 0.001, "the statistics include the imported expenses")
+
+	added, err = s.db.ImportExpenses(expenses, 1, false)
+	s.Require().NoError(err)
+	s.Zero(added, "importing the same file again adds nothing")
+
+	ledger, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	added, err = s.db.ImportExpenses([]models.Expense{{Amount: 250, Description: "Printer", Category: "Shopping", Date: day, LedgerID: ledger.ID}}, 1, false)
+	s.Require().NoError(err)
+	s.Equal(1, added)
+	total, err = s.db.GetTotalForPeriod(ledger.ID, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(250, total, 0.001, "in the ledger given")
+}
+
+func (s *ExpenseTestSuite) TestReassignExpenses() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(900, "Rent", "Housing", day, 1))
+	s.Require().NoError(s.db.CreateExpense(850, "Old rent", "Housing", day.AddDate(-2, 0, 0), 1))
+	s.Require().NoError(s.db.CreateExpense(12, "Lunch", "Eating Out", day, 1))
+	s.Require().NoError(s.db.CreateExpense(700, "Other rent", "Housing", day, 3))
+	_, err := s.db.ArchiveExpenses(day.AddDate(-1, 0, 0))
+	s.Require().NoError(err)
+
+	filter := ExpenseFilter{UserID: 1, Category: "housing"}
+	result, err := s.db.ReassignExpenses(filter, 2, true)
+	s.Require().NoError(err)
+	s.Equal(ReassignResult{Expenses: 1, Archived: 1, Total: 1750}, result)
+	mine, err := s.db.ExportExpenses(ExpenseFilter{UserID: 2})
+	s.Require().NoError(err)
+	s.Empty(mine, "a dry run changes nothing")
+
+	result, err = s.db.ReassignExpenses(filter, 2, false)
+	s.Require().NoError(err)
+	s.Equal(ReassignResult{Expenses: 1, Archived: 1, Total: 1750}, result)
+	mine, err = s.db.ExportExpenses(ExpenseFilter{UserID: 2})
+	s.Require().NoError(err)
+	s.Len(mine, 2)
+
+	_, err = s.db.ReassignExpenses(ExpenseFilter{Category: "Housing"}, 2, false)
+	s.Error(err, "only one user's expenses")
+}
+
+func (s *ExpenseTestSuite) TestCategoryTotals() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(4.5, "Coffee", "Eating Out", day, 1))
+	s.Require().NoError(s.db.CreateExpense(30, "Supermarket", "Groceries", day, 1))
+	s.Require().NoError(s.db.CreateExpense(12, "Bakery", "Groceries", day.Add(time.Hour), 2))
+	s.Require().NoError(s.db.CreateExpense(50, "Old", "Housing", day.AddDate(0, -1, 0), 1))
+
+	totals, err := s.db.CategoryTotals(ExpenseFilter{From: day.AddDate(0, 0, -1)})
+	s.Require().NoError(err)
+	s.Equal([]CategoryTotal{
+		{Category: "Groceries", Total: 42, Count: 2},
+		{Category: "Eating Out", Total: 4.5, Count: 1},
+	}, totals)
+
+	totals, err = s.db.CategoryTotals(ExpenseFilter{Query: "nothing"})
+	s.Require().NoError(err)
+	s.Empty(totals)
+}
+
+func (s *ExpenseTestSuite) TestSummarizeExpenses() {
+	s.Require().NoError(s.db.CreateExpense(40.00, "Bus pass", "Transport", time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(12.00, "Lunch", "Eating Out", time.Date(2026, 2, 10, 13, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(4.00, "Coffee", "Eating Out", time.Date(2026, 2, 27, 8, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(500.00, "March rent", "Housing", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 1))
+
+	feb := ExpenseFilter{
+		From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	summary, err := s.db.SummarizeExpenses(feb)
+	s.Require().NoError(err)
+	s.Equal(3, summary.Count)
+	s.InDelta(56.00, summary.Total, 0.001)
+	s.InDelta(2.00, summary.AveragePerDay, 0.001, "a closed period averages over all of its 28 days")
+	s.Require().NotNil(summary.Largest)
+	s.Equal("Bus pass", summary.Largest.Description)
+
+	feb.Category = "eating out"
+	summary, err = s.db.SummarizeExpenses(feb)
+	s.Require().NoError(err)
+	s.Equal(2, summary.Count)
+	s.Equal("Lunch", summary.Largest.Description)
+
+	summary, err = s.db.SummarizeExpenses(ExpenseFilter{Category: "Gifts"})
+	s.Require().NoError(err)
+	s.Zero(summary.Count)
+	s.Zero(summary.AveragePerDay)
+	s.Nil(summary.Largest)
+}
+
+func (s *ExpenseTestSuite) TestGetTotalForCycle() {
+	s.Require().NoError(s.db.CreateExpense(30, "Before", "Groceries", time.Date(2026, 2, 24, 12, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(20, "First day", "Groceries", time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(15, "Last day", "Transport", time.Date(2026, 3, 24, 23, 0, 0, 0, time.UTC), 1))
+	s.Require().NoError(s.db.CreateExpense(40, "Next cycle", "Transport", time.Date(2026, 3, 25, 9, 0, 0, 0, time.UTC), 1))
+	_, err := s.db.ArchiveExpenses(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+
+	total, err := s.db.GetTotalForCycle(models.PersonalLedgerID, time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.InDelta(35, total, 0.001, "archived expenses count too")
+
+	total, err = s.db.GetTotalForCycle(models.PersonalLedgerID+1, time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Zero(total, "another ledger")
+}
+
+func TestPeriodDays(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"closed month", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 28},
+		{"open period ends today", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}, 10},
+		{"upper bound after today", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), 10},
+		{"starts mid-day", time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC), time.Time{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodDays(tt.from, tt.to, now); got != tt.want {
+				t.Errorf("periodDays() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
 // Test suite runner
 func TestExpenseSuite(t *testing.T) {
 	suite.Run(t, new(ExpenseTestSuite))
 }
+
+// BenchmarkFilterExpenses compares the prepared query behind every page of
+// the expense list with the same query prepared each time.
+func BenchmarkFilterExpenses(b *testing.B) {
+	db := newBenchDB(b)
+	user, err := db.CreateUser("bench", "hash")
+	if err != nil {
+		b.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 200 {
+		if err := db.CreateExpense(float64(i), "Coffee", "Eating Out", start.AddDate(0, 0, -i), user.ID); err != nil {
+			b.Fatal(err)
+		}
+	}
+	filter := ExpenseFilter{From: start.AddDate(0, 0, -30), To: start.AddDate(0, 0, 1), UserID: user.ID}
+
+	b.Run("prepared", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := db.FilterExpenses(filter); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+	b.Run("unprepared", func(b *testing.B) {
+		where, args := filter.where()
+		query := "SELECT id, amount, description, category, date, user_id FROM expenses WHERE " + where + " ORDER BY date DESC"
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				rows, err := db.conn.Query(query, args...)
+				if err == nil {
+					_, err = scanExpenses(rows)
+				}
+				if err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrGuestTokenInvalid is returned when a guest token does not exist, has
+// expired, or belongs to a disabled account.
+var ErrGuestTokenInvalid = errors.New("guest token is invalid or expired")
+
+const guestTokenColumns = "guest_tokens.id, guest_tokens.user_id, guest_tokens.ledger_id, guest_tokens.name, guest_tokens.created_at, guest_tokens.expires_at, guest_tokens.last_used_at"
+
+// CreateGuestToken stores a guest token, identified by the hash of the
+// token, and sets its ID and creation time.
+func (db *DB) CreateGuestToken(t *models.GuestToken, tokenHash string) error {
+	t.CreatedAt = time.Now().UTC()
+	result, err := db.conn.Exec(
+		`INSERT INTO guest_tokens (token_hash, user_id, ledger_id, name, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		tokenHash, t.UserID, t.LedgerID, t.Name, t.CreatedAt, t.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListGuestTokens returns a user's guest tokens to ledgers they see, expired
+// ones included, newest first.
+func (db *DB) ListGuestTokens(userID int64) ([]models.GuestToken, error) {
+	rows, err := db.conn.Query("SELECT "+guestTokenColumns+" FROM guest_tokens WHERE user_id = ? AND ledger_id "+visibleLedgers+" ORDER BY created_at DESC, id DESC", userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []models.GuestToken
+	for rows.Next() {
+		var t models.GuestToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.LedgerID, &t.Name, &t.CreatedAt, &t.ExpiresAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// ValidateGuestToken looks up a guest token that can still be used by its
+// hash, along with the user whose expenses it shows, and records that it was
+// used. A token to a ledger its user no longer sees can't be used. It
+// returns ErrGuestTokenInvalid otherwise.
+func (db *DB) ValidateGuestToken(tokenHash string) (*models.GuestToken, *models.User, error) {
+	now := time.Now().UTC()
+	row := db.conn.QueryRow(`
+		SELECT `+userColumns+`, `+guestTokenColumns+`
+		FROM guest_tokens
+		JOIN users ON guest_tokens.user_id = users.id
+		JOIN ledgers ON guest_tokens.ledger_id = ledgers.id
+		WHERE guest_tokens.token_hash = ? AND guest_tokens.expires_at > ? AND users.disabled_at IS NULL
+			AND (ledgers.owner_id IS NULL OR ledgers.owner_id = users.id)`,
+		tokenHash, now,
+	)
+	var t models.GuestToken
+	var lastUsed sql.NullTime
+	u, err := scanUser(row, &t.ID, &t.UserID, &t.LedgerID, &t.Name, &t.CreatedAt, &t.ExpiresAt, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrGuestTokenInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := db.conn.Exec("UPDATE guest_tokens SET last_used_at = ? WHERE id = ?", now, t.ID); err != nil {
+		return nil, nil, err
+	}
+	t.LastUsedAt = &now
+	return &t, u, nil
+}
+
+// DeleteGuestToken revokes one of the user's guest tokens. It returns
+// sql.ErrNoRows if the user has no such token to a ledger they see.
+func (db *DB) DeleteGuestToken(userID, id int64) error {
+	n, err := rowsAffected(db.conn.Exec("DELETE FROM guest_tokens WHERE id = ? AND user_id = ? AND ledger_id "+visibleLedgers, id, userID, userID))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// GuestsTestSuite provides a test suite for guest tokens
+type GuestsTestSuite struct {
+	suite.Suite
+	db   *DB
+	user *models.User
+}
+
+// SetupTest runs before each test
+func (s *GuestsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+}
+
+// TearDownTest runs after each test
+func (s *GuestsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *GuestsTestSuite) TestGuestTokens() {
+	accountant := &models.GuestToken{UserID: s.user.ID, LedgerID: models.PersonalLedgerID, Name: "Accountant", ExpiresAt: time.Now().Add(time.Hour)}
+	s.Require().NoError(s.db.CreateGuestToken(accountant, "hash-1"))
+	s.NotZero(accountant.ID)
+	expired := &models.GuestToken{UserID: s.user.ID, LedgerID: models.PersonalLedgerID, Name: "Old", ExpiresAt: time.Now().Add(-time.Hour)}
+	s.Require().NoError(s.db.CreateGuestToken(expired, "hash-2"))
+
+	token, user, err := s.db.ValidateGuestToken("hash-1")
+	s.Require().NoError(err)
+	s.Equal(accountant.ID, token.ID)
+	s.Equal("Accountant", token.Name)
+	s.Equal(s.user.ID, user.ID)
+	s.Equal("alice", user.Username)
+	s.NotNil(token.LastUsedAt)
+
+	_, _, err = s.db.ValidateGuestToken("hash-2")
+	s.ErrorIs(err, ErrGuestTokenInvalid, "expired")
+	_, _, err = s.db.ValidateGuestToken("unknown")
+	s.ErrorIs(err, ErrGuestTokenInvalid)
+
+	tokens, err := s.db.ListGuestTokens(s.user.ID)
+	s.Require().NoError(err)
+	s.Require().Len(tokens, 2)
+	s.Equal("Old", tokens[0].Name, "newest first")
+	s.Nil(tokens[0].LastUsedAt)
+	s.NotNil(tokens[1].LastUsedAt)
+
+	s.ErrorIs(s.db.DeleteGuestToken(s.user.ID+1, accountant.ID), sql.ErrNoRows, "someone else's")
+	s.Require().NoError(s.db.DeleteGuestToken(s.user.ID, accountant.ID))
+	_, _, err = s.db.ValidateGuestToken("hash-1")
+	s.ErrorIs(err, ErrGuestTokenInvalid, "revoked")
+}
+
+func (s *GuestsTestSuite) TestDisabledAccount() {
+	s.Require().NoError(s.db.CreateGuestToken(&models.GuestToken{UserID: s.user.ID, LedgerID: models.PersonalLedgerID, Name: "Partner", ExpiresAt: time.Now().Add(time.Hour)}, "hash"))
+	s.Require().NoError(s.db.DisableUser(s.user.ID))
+	_, _, err := s.db.ValidateGuestToken("hash")
+	s.ErrorIs(err, ErrGuestTokenInvalid)
+}
+
+func (s *GuestsTestSuite) TestDeleteLedger() {
+	ledger, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateGuestToken(&models.GuestToken{UserID: s.user.ID, LedgerID: ledger.ID, Name: "Accountant", ExpiresAt: time.Now().Add(time.Hour)}, "hash"))
+	s.Require().NoError(s.db.DeleteLedger(ledger.ID))
+	_, _, err = s.db.ValidateGuestToken("hash")
+	s.ErrorIs(err, ErrGuestTokenInvalid, "not the personal ledger instead")
+}
+
+func (s *GuestsTestSuite) TestOtherUsersLedger() {
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	ledger, err := s.db.CreateLedger("Club", "", bob.ID)
+	s.Require().NoError(err)
+	token := &models.GuestToken{UserID: s.user.ID, LedgerID: ledger.ID, Name: "Partner", ExpiresAt: time.Now().Add(time.Hour)}
+	s.Require().NoError(s.db.CreateGuestToken(token, "hash"))
+
+	_, _, err = s.db.ValidateGuestToken("hash")
+	s.ErrorIs(err, ErrGuestTokenInvalid)
+	tokens, err := s.db.ListGuestTokens(s.user.ID)
+	s.Require().NoError(err)
+	s.Empty(tokens)
+	s.ErrorIs(s.db.DeleteGuestToken(s.user.ID, token.ID), sql.ErrNoRows)
+}
+
+// TestGuestsTestSuite runs the guest tokens test suite
+func TestGuestsTestSuite(t *testing.T) {
+	suite.Run(t, new(GuestsTestSuite))
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrInviteInvalid is returned when an invite does not exist, has expired, or
+// was already used.
+var ErrInviteInvalid = errors.New("invite is invalid or expired")
+
+// CreateInvite stores an invite, identified by the hash of its token, that
+// can be redeemed until expiresAt.
+func (db *DB) CreateInvite(tokenHash string, createdBy int64, expiresAt time.Time) (*models.Invite, error) {
+	now := time.Now()
+	id, err := createInvite(db.conn, tokenHash, createdBy, now, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Invite{ID: id, CreatedBy: createdBy, CreatedAt: now, ExpiresAt: expiresAt}, nil
+}
+
+// GetInvite retrieves an invite that can still be redeemed by the hash of its
+// token. It returns ErrInviteInvalid otherwise.
+func (db *DB) GetInvite(tokenHash string) (*models.Invite, error) {
+	invite, err := getUsableInvite(db.conn, tokenHash, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInviteInvalid
+	}
+	return invite, err
+}
+
+// ListInvites retrieves all invites, newest first.
+func (db *DB) ListInvites() ([]models.Invite, error) {
+	return listInvites(db.conn)
+}
+
+// RedeemInvite uses up an invite and creates the member account it was
+// meant for. It returns ErrInviteInvalid if the invite can't be used.
+func (db *DB) RedeemInvite(tokenHash, username, passwordHash string) (*models.User, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	inviteID, err := useInvite(tx, now, tokenHash, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := createUser(tx, username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := setInviteUser(tx, userID, inviteID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetUserByID(userID)
+}
+
+// DeleteInvite revokes an invite.
+func (db *DB) DeleteInvite(id int64) error {
+	return deleteInvite(db.conn, id)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// InviteTestSuite provides a test suite for invite operations
+type InviteTestSuite struct {
+	suite.Suite
+	db    *DB
+	admin *models.User
+}
+
+// SetupTest runs before each test
+func (s *InviteTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+
+	admin, err := s.db.CreateUser("admin", "hash")
+	s.Require().NoError(err)
+	s.admin = admin
+}
+
+// TearDownTest runs after each test
+func (s *InviteTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *InviteTestSuite) TestFirstUserIsAdmin() {
+	s.True(s.admin.IsAdmin())
+
+	member, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Equal(models.RoleMember, member.Role)
+
+	s.Require().NoError(s.db.SetRole(member.ID, models.RoleAdmin))
+	member, err = s.db.GetUserByID(member.ID)
+	s.Require().NoError(err)
+	s.True(member.IsAdmin())
+}
+
+func (s *InviteTestSuite) TestRedeemInvite() {
+	_, err := s.db.CreateInvite("hash1", s.admin.ID, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+
+	_, err = s.db.GetInvite("hash1")
+	s.Require().NoError(err)
+
+	user, err := s.db.RedeemInvite("hash1", "alice", "pwhash")
+	s.Require().NoError(err)
+	s.Equal("alice", user.Username)
+	s.Equal(models.RoleMember, user.Role)
+
+	_, err = s.db.GetInvite("hash1")
+	s.ErrorIs(err, ErrInviteInvalid, "invites are single-use")
+	_, err = s.db.RedeemInvite("hash1", "mallory", "pwhash")
+	s.ErrorIs(err, ErrInviteInvalid)
+
+	invites, err := s.db.ListInvites()
+	s.Require().NoError(err)
+	s.Require().Len(invites, 1)
+	s.NotNil(invites[0].UsedAt)
+	s.Equal("alice", invites[0].UsedBy)
+}
+
+func (s *InviteTestSuite) TestRedeemInvite_ExpiredOrRevoked() {
+	_, err := s.db.CreateInvite("expired", s.admin.ID, time.Now().Add(-time.Minute))
+	s.Require().NoError(err)
+	revoked, err := s.db.CreateInvite("revoked", s.admin.ID, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.DeleteInvite(revoked.ID))
+
+	for _, hash := range []string{"expired", "revoked", "unknown"} {
+		_, err := s.db.RedeemInvite(hash, "alice", "pwhash")
+		s.ErrorIs(err, ErrInviteInvalid, hash)
+	}
+	count, err := s.db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+func (s *InviteTestSuite) TestRedeemInvite_TakenUsernameKeepsInvite() {
+	_, err := s.db.CreateInvite("hash1", s.admin.ID, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+
+	_, err = s.db.RedeemInvite("hash1", "admin", "pwhash")
+	s.Require().Error(err)
+	s.NotErrorIs(err, ErrInviteInvalid)
+
+	_, err = s.db.GetInvite("hash1")
+	s.NoError(err, "a failed signup must not use up the invite")
+}
+
+// TestInviteSuite runs the invite test suite
+func TestInviteSuite(t *testing.T) {
+	suite.Run(t, new(InviteTestSuite))
+}
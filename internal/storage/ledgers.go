@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+var (
+	// ErrLedgerExists is returned when a ledger name is already taken,
+	// regardless of case.
+	ErrLedgerExists = errors.New("ledger already exists")
+	// ErrLedgerInUse is returned when deleting the personal ledger or one
+	// that still has expenses, and when archiving the personal ledger.
+	ErrLedgerInUse = errors.New("ledger is in use")
+)
+
+// ledgerColumns are the columns scanLedger reads.
+const ledgerColumns = "id, owner_id, name, currency, created_at, archived_at"
+
+// visibleLedgers is the condition on a ledger ID for the ledgers a user,
+// the query's argument, can see.
+const visibleLedgers = "IN (SELECT id FROM ledgers WHERE owner_id IS NULL OR owner_id = ?)"
+
+func scanLedger(row interface{ Scan(...any) error }) (*models.Ledger, error) {
+	l := &models.Ledger{}
+	if err := row.Scan(&l.ID, &l.OwnerID, &l.Name, &l.Currency, &l.CreatedAt, &l.ArchivedAt); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// CreateLedger adds a ledger kept in a currency, or in each user's own if
+// currency is empty. Only its owner sees it, or the whole household if
+// ownerID is zero. It returns ErrLedgerExists if the owner already sees a
+// ledger of that name.
+func (db *DB) CreateLedger(name, currency string, ownerID int64) (*models.Ledger, error) {
+	l := &models.Ledger{Name: name, Currency: currency, CreatedAt: time.Now().UTC()}
+	if ownerID != 0 {
+		l.OwnerID = &ownerID
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The unique index only covers ledgers of the same owner, the shared
+	// ones must not be named alike either
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM ledgers WHERE name = ? AND id "+visibleLedgers+")", name, ownerID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrLedgerExists
+	}
+	result, err := tx.Exec("INSERT INTO ledgers (owner_id, name, currency, created_at) VALUES (?, ?, ?, ?)", l.OwnerID, l.Name, l.Currency, l.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrLedgerExists
+		}
+		return nil, err
+	}
+	if l.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return l, tx.Commit()
+}
+
+// ListLedgers returns the ledgers a user sees, archived ones included, the
+// personal one first and the others by name.
+func (db *DB) ListLedgers(userID int64) ([]models.Ledger, error) {
+	rows, err := db.conn.Query(
+		"SELECT "+ledgerColumns+" FROM ledgers WHERE id "+visibleLedgers+" ORDER BY id <> ?, name COLLATE NOCASE",
+		userID, models.PersonalLedgerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ledgers []models.Ledger
+	for rows.Next() {
+		l, err := scanLedger(rows)
+		if err != nil {
+			return nil, err
+		}
+		ledgers = append(ledgers, *l)
+	}
+	return ledgers, rows.Err()
+}
+
+// GetLedger returns a ledger by ID, or sql.ErrNoRows.
+func (db *DB) GetLedger(id int64) (*models.Ledger, error) {
+	return scanLedger(db.conn.QueryRow("SELECT "+ledgerColumns+" FROM ledgers WHERE id = ?", id))
+}
+
+// SetLedgerCurrency changes the currency a ledger is kept in, empty for each
+// user's own. Amounts stay as they are. It returns sql.ErrNoRows if the
+// ledger doesn't exist.
+func (db *DB) SetLedgerCurrency(id int64, currency string) error {
+	n, err := rowsAffected(db.conn.Exec("UPDATE ledgers SET currency = ? WHERE id = ?", currency, id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ArchiveLedger puts a ledger away, or brings it back if archived is false.
+// An archived ledger keeps its expenses and still counts in reports across
+// ledgers, but can't be picked; users who had it picked are back in the
+// personal one. It returns ErrLedgerInUse for the personal ledger, and
+// sql.ErrNoRows if the ledger doesn't exist.
+func (db *DB) ArchiveLedger(id int64, archived bool) error {
+	if id == models.PersonalLedgerID {
+		return ErrLedgerInUse
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var n int64
+	if archived {
+		// Archiving again keeps when it was first put away
+		n, err = rowsAffected(tx.Exec("UPDATE ledgers SET archived_at = COALESCE(archived_at, ?) WHERE id = ?", time.Now().UTC(), id))
+	} else {
+		n, err = rowsAffected(tx.Exec("UPDATE ledgers SET archived_at = NULL WHERE id = ?", id))
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	if archived {
+		if _, err := tx.Exec("UPDATE user_settings SET ledger_id = ? WHERE ledger_id = ?", models.PersonalLedgerID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteLedger removes a ledger without current or archived expenses. Its
+// recently deleted expenses are restored to the personal ledger instead, and
+// users who had it picked, and their challenges in it, are back in the
+// personal one. Guest links to it stop working. It returns ErrLedgerInUse
+// for the personal ledger or one with expenses, and sql.ErrNoRows if the
+// ledger doesn't exist.
+func (db *DB) DeleteLedger(id int64) error {
+	if id == models.PersonalLedgerID {
+		return ErrLedgerInUse
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var inUse bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM all_expenses WHERE ledger_id = ?)", id).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		return ErrLedgerInUse
+	}
+	for _, table := range []string{"deleted_expenses", "user_settings", "challenges"} {
+		if _, err := tx.Exec("UPDATE "+table+" SET ledger_id = ? WHERE ledger_id = ?", models.PersonalLedgerID, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM guest_tokens WHERE ledger_id = ?", id); err != nil {
+		return err
+	}
+	n, err := rowsAffected(tx.Exec("DELETE FROM ledgers WHERE id = ?", id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// LedgerTotal is what was spent in one ledger.
+type LedgerTotal struct {
+	Ledger models.Ledger
+	Total  float64
+	Count  int
+}
+
+// LedgerTotals sums up the current and archived expenses between start and
+// end, exclusive, per ledger a user sees. Every such ledger is listed,
+// archived ones and those without expenses then included, in the order of
+// ListLedgers.
+func (db *DB) LedgerTotals(userID int64, start, end time.Time) ([]LedgerTotal, error) {
+	rows, err := db.conn.Query(
+		`SELECT l.id, l.owner_id, l.name, l.currency, l.created_at, l.archived_at, COALESCE(SUM(a.total), 0), COALESCE(SUM(a.count), 0)
+		FROM ledgers l LEFT JOIN daily_aggregates a ON a.ledger_id = l.id AND a.day >= ? AND a.day < ?
+		WHERE l.id `+visibleLedgers+`
+		GROUP BY l.id
+		ORDER BY l.id <> ?, l.name COLLATE NOCASE`,
+		start.Format(time.DateOnly), end.Format(time.DateOnly), userID, models.PersonalLedgerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []LedgerTotal
+	for rows.Next() {
+		var t LedgerTotal
+		l := &t.Ledger
+		if err := rows.Scan(&l.ID, &l.OwnerID, &l.Name, &l.Currency, &l.CreatedAt, &l.ArchivedAt, &t.Total, &t.Count); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
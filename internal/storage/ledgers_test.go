@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LedgersTestSuite provides a test suite for ledgers
+type LedgersTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *LedgersTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *LedgersTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// expense adds an expense to a ledger on the day plus days, and returns it.
+func (s *LedgersTestSuite) expense(ledgerID int64, amount float64, days int) *models.Expense {
+	e := &models.Expense{Amount: amount, Description: "Lunch", Category: "Eating Out", Date: s.day.AddDate(0, 0, days), LedgerID: ledgerID}
+	s.Require().NoError(s.db.InsertExpense(e))
+	return e
+}
+
+func (s *LedgersTestSuite) TestCreateAndList() {
+	ledgers, err := s.db.ListLedgers(0)
+	s.Require().NoError(err)
+	s.Require().Len(ledgers, 1)
+	s.Equal(int64(models.PersonalLedgerID), ledgers[0].ID)
+	s.Equal("Personal", ledgers[0].Name)
+
+	business, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	_, err = s.db.CreateLedger("allotment", "", 0)
+	s.Require().NoError(err)
+	_, err = s.db.CreateLedger("BUSINESS", "", 0)
+	s.ErrorIs(err, ErrLedgerExists)
+
+	ledgers, err = s.db.ListLedgers(0)
+	s.Require().NoError(err)
+	var names []string
+	for _, l := range ledgers {
+		names = append(names, l.Name)
+	}
+	s.Equal([]string{"Personal", "allotment", "Business"}, names, "the personal one first")
+
+	got, err := s.db.GetLedger(business.ID)
+	s.Require().NoError(err)
+	s.Equal("Business", got.Name)
+	_, err = s.db.GetLedger(99)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *LedgersTestSuite) TestScoped() {
+	business, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	personal := s.expense(0, 10, 0)
+	s.Equal(int64(models.PersonalLedgerID), personal.LedgerID, "the personal ledger by default")
+	work := s.expense(business.ID, 25, 1)
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{Ledger: business.ID})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(work.ID, expenses[0].ID)
+	s.Equal(business.ID, expenses[0].LedgerID)
+	expenses, err = s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Len(expenses, 2, "all ledgers")
+
+	for ledger, want := range map[int64]float64{0: 35, models.PersonalLedgerID: 10, business.ID: 25} {
+		total, err := s.db.GetTotalForPeriod(ledger, 2026, 3)
+		s.Require().NoError(err)
+		s.Equal(want, total, ledger)
+		stats, err := s.db.GetMonthStats(ledger, 2026, 3)
+		s.Require().NoError(err)
+		s.Equal(want, stats.Total, ledger)
+	}
+
+	// Archived, deleted, and restored expenses stay in their ledger
+	_, err = s.db.ArchiveExpenses(s.day.AddDate(0, 0, 1))
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.DeleteExpense(work.ID))
+	s.Require().NoError(s.db.RestoreExpense(work.ID))
+	restored, err := s.db.GetExpense(work.ID)
+	s.Require().NoError(err)
+	s.Equal(business.ID, restored.LedgerID)
+	total, err := s.db.GetTotalForPeriod(models.PersonalLedgerID, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(10.0, total, "archived")
+	exported, err := s.db.ExportExpenses(ExpenseFilter{Ledger: models.PersonalLedgerID})
+	s.Require().NoError(err)
+	s.Require().Len(exported, 1)
+	s.Equal(personal.ID, exported[0].ID)
+
+	problems, err := s.db.Check()
+	s.Require().NoError(err)
+	s.Empty(problems)
+}
+
+func (s *LedgersTestSuite) TestDelete() {
+	business, err := s.db.CreateLedger("Business", "", 0)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 1, Ledger: business.ID}))
+	e := s.expense(business.ID, 25, 0)
+
+	s.ErrorIs(s.db.DeleteLedger(business.ID), ErrLedgerInUse, "it has an expense")
+	s.ErrorIs(s.db.DeleteLedger(models.PersonalLedgerID), ErrLedgerInUse)
+	s.ErrorIs(s.db.DeleteLedger(99), sql.ErrNoRows)
+
+	s.Require().NoError(s.db.DeleteExpense(e.ID))
+	s.Require().NoError(s.db.DeleteLedger(business.ID))
+	_, err = s.db.GetLedger(business.ID)
+	s.ErrorIs(err, sql.ErrNoRows)
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(int64(models.PersonalLedgerID), prefs.Ledger, "back in the personal ledger")
+
+	s.Require().NoError(s.db.RestoreExpense(e.ID))
+	restored, err := s.db.GetExpense(e.ID)
+	s.Require().NoError(err)
+	s.Equal(int64(models.PersonalLedgerID), restored.LedgerID, "restored to the personal ledger")
+}
+
+func (s *LedgersTestSuite) TestArchive() {
+	club, err := s.db.CreateLedger("Club", "CHF", 0)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.SavePreferences(1, models.Preferences{Currency: "EUR", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 1, Ledger: club.ID}))
+	s.expense(club.ID, 25, 0)
+
+	s.Require().NoError(s.db.ArchiveLedger(club.ID, true))
+	got, err := s.db.GetLedger(club.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(got.ArchivedAt)
+	archivedAt := *got.ArchivedAt
+	s.Equal("CHF", got.Currency)
+	prefs, err := s.db.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(int64(models.PersonalLedgerID), prefs.Ledger, "back in the personal ledger")
+	total, err := s.db.GetTotalForPeriod(club.ID, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(25.0, total, "its expenses stay")
+
+	s.Require().NoError(s.db.ArchiveLedger(club.ID, true))
+	got, err = s.db.GetLedger(club.ID)
+	s.Require().NoError(err)
+	s.Equal(archivedAt, *got.ArchivedAt, "archived once")
+
+	s.Require().NoError(s.db.ArchiveLedger(club.ID, false))
+	got, err = s.db.GetLedger(club.ID)
+	s.Require().NoError(err)
+	s.Nil(got.ArchivedAt)
+
+	s.ErrorIs(s.db.ArchiveLedger(models.PersonalLedgerID, true), ErrLedgerInUse)
+	s.ErrorIs(s.db.ArchiveLedger(99, true), sql.ErrNoRows)
+}
+
+func (s *LedgersTestSuite) TestCurrency() {
+	club, err := s.db.CreateLedger("Club", "CHF", 0)
+	s.Require().NoError(err)
+	s.Equal("CHF", club.Currency)
+
+	s.Require().NoError(s.db.SetLedgerCurrency(club.ID, ""))
+	got, err := s.db.GetLedger(club.ID)
+	s.Require().NoError(err)
+	s.Empty(got.Currency, "each user's own")
+	s.ErrorIs(s.db.SetLedgerCurrency(99, "CHF"), sql.ErrNoRows)
+}
+
+func (s *LedgersTestSuite) TestTotals() {
+	club, err := s.db.CreateLedger("Club", "CHF", 0)
+	s.Require().NoError(err)
+	empty, err := s.db.CreateLedger("Allotment", "", 0)
+	s.Require().NoError(err)
+	s.expense(0, 10, 0)
+	s.expense(0, 5, 1)
+	s.expense(club.ID, 25, 2)
+	s.expense(club.ID, 40, 40)
+	s.Require().NoError(s.db.ArchiveLedger(club.ID, true))
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.db.LedgerTotals(0, start, start.AddDate(0, 1, 0))
+	s.Require().NoError(err)
+	s.Require().Len(totals, 3)
+	s.Equal(int64(models.PersonalLedgerID), totals[0].Ledger.ID)
+	s.Equal(15.0, totals[0].Total)
+	s.Equal(2, totals[0].Count)
+	s.Equal(empty.ID, totals[1].Ledger.ID)
+	s.Zero(totals[1].Total, "listed without expenses")
+	s.Equal(club.ID, totals[2].Ledger.ID)
+	s.Equal(25.0, totals[2].Total, "only the period's")
+	s.Equal("CHF", totals[2].Ledger.Currency)
+	s.NotNil(totals[2].Ledger.ArchivedAt, "archived ones included")
+}
+
+func (s *LedgersTestSuite) TestOwners() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	business, err := s.db.CreateLedger("Business", "", alice.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(business.OwnerID)
+	s.Equal(alice.ID, *business.OwnerID)
+	_, err = s.db.CreateLedger("business", "", bob.ID)
+	s.Require().NoError(err, "names are unique per owner")
+	_, err = s.db.CreateLedger("BUSINESS", "", alice.ID)
+	s.ErrorIs(err, ErrLedgerExists)
+	_, err = s.db.CreateLedger("personal", "", alice.ID)
+	s.ErrorIs(err, ErrLedgerExists, "named like a shared one")
+
+	names := func(userID int64) []string {
+		ledgers, err := s.db.ListLedgers(userID)
+		s.Require().NoError(err)
+		var names []string
+		for _, l := range ledgers {
+			names = append(names, l.Name)
+		}
+		return names
+	}
+	s.Equal([]string{"Personal", "Business"}, names(alice.ID))
+	s.Equal([]string{"Personal", "business"}, names(bob.ID))
+	s.Equal([]string{"Personal"}, names(0), "only the shared ones")
+
+	s.expense(0, 10, 0)
+	s.expense(business.ID, 25, 0)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.db.LedgerTotals(bob.ID, start, start.AddDate(0, 1, 0))
+	s.Require().NoError(err)
+	s.Require().Len(totals, 2)
+	s.Equal(10.0, totals[0].Total)
+	s.Zero(totals[1].Total, "bob's own ledger")
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{Viewer: bob.ID})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1, "alice's business expense is left out")
+	expenses, err = s.db.FilterExpenses(ExpenseFilter{Viewer: alice.ID})
+	s.Require().NoError(err)
+	s.Len(expenses, 2)
+
+	// An heir takes over the ledgers, otherwise they go with the account
+	s.Require().NoError(s.db.DeleteUser(alice.ID, bob.ID))
+	got, err := s.db.GetLedger(business.ID)
+	s.Require().NoError(err)
+	s.Equal(bob.ID, *got.OwnerID)
+	s.Equal("Business (alice)", got.Name, "bob has a ledger of that name")
+	s.Require().NoError(s.db.DeleteUser(bob.ID, 0))
+	s.Equal([]string{"Personal"}, names(0))
+	_, err = s.db.GetLedger(business.ID)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+// TestLedgersTestSuite runs the ledgers test suite
+func TestLedgersTestSuite(t *testing.T) {
+	suite.Run(t, new(LedgersTestSuite))
+}
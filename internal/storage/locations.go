@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"expense-tracker/internal/models"
+)
+
+// GetExpenseLocation returns where an expense was made, or the zero
+// Location if that wasn't recorded.
+func (db *DB) GetExpenseLocation(expenseID int64) (models.Location, error) {
+	var loc models.Location
+	err := db.conn.QueryRow(
+		"SELECT latitude, longitude, place FROM expense_locations WHERE expense_id = ?", expenseID,
+	).Scan(&loc.Latitude, &loc.Longitude, &loc.Place)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Location{}, nil
+	}
+	return loc, err
+}
+
+// SetExpenseLocation records where an expense was made, replacing what was
+// recorded before. The zero Location forgets it.
+func (db *DB) SetExpenseLocation(expenseID int64, loc models.Location) error {
+	return setExpenseLocation(db.conn, expenseID, loc)
+}
+
+func setExpenseLocation(ex execer, expenseID int64, loc models.Location) error {
+	if loc.IsZero() {
+		_, err := ex.Exec("DELETE FROM expense_locations WHERE expense_id = ?", expenseID)
+		return err
+	}
+	_, err := ex.Exec(
+		`INSERT INTO expense_locations (expense_id, latitude, longitude, place) VALUES (?, ?, ?, ?)
+		ON CONFLICT (expense_id) DO UPDATE SET latitude = excluded.latitude, longitude = excluded.longitude, place = excluded.place`,
+		expenseID, loc.Latitude, loc.Longitude, loc.Place,
+	)
+	return err
+}
+
+// PlaceTotal is what was spent at one place.
+type PlaceTotal struct {
+	// The place name, and the average coordinates it was recorded at, if
+	// any. Unnamed places only have coordinates.
+	Location models.Location
+	Total    float64
+	Count    int
+}
+
+// SpendingByPlace sums up the current and archived expenses matching a
+// filter per place, the largest total first, up to limit places. Places are
+// told apart by name regardless of case, and unnamed ones by coordinates
+// rounded to about 100 meters.
+func (db *DB) SpendingByPlace(f ExpenseFilter, limit int) ([]PlaceTotal, error) {
+	where, args := f.where()
+	rows, err := db.conn.Query(
+		`SELECT MIN(l.place), AVG(l.latitude), AVG(l.longitude), SUM(e.amount) AS total, COUNT(*)
+		FROM all_expenses e JOIN expense_locations l ON l.expense_id = e.id
+		WHERE `+where+`
+		GROUP BY CASE WHEN l.place <> '' THEN LOWER(l.place) ELSE printf('%.3f,%.3f', l.latitude, l.longitude) END
+		ORDER BY total DESC, MIN(l.place)
+		LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []PlaceTotal
+	for rows.Next() {
+		var t PlaceTotal
+		if err := rows.Scan(&t.Location.Place, &t.Location.Latitude, &t.Location.Longitude, &t.Total, &t.Count); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LocationsTestSuite provides a test suite for expense locations
+type LocationsTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *LocationsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *LocationsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// at returns a location at the given coordinates with the given name.
+func at(lat, lon float64, place string) models.Location {
+	return models.Location{Latitude: &lat, Longitude: &lon, Place: place}
+}
+
+// expense adds an expense of amount at loc and returns its ID.
+func (s *LocationsTestSuite) expense(amount float64, loc models.Location) int64 {
+	e := &models.Expense{Amount: amount, Description: "Coffee", Category: "Eating Out", Date: s.day.Add(time.Duration(amount) * time.Minute)}
+	s.Require().NoError(s.db.InsertExpense(e))
+	s.Require().NoError(s.db.SetExpenseLocation(e.ID, loc))
+	return e.ID
+}
+
+func (s *LocationsTestSuite) TestSetExpenseLocation() {
+	id := s.expense(3, at(52.37, 4.89, "Café"))
+	loc, err := s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.Equal(at(52.37, 4.89, "Café"), loc)
+
+	s.Require().NoError(s.db.SetExpenseLocation(id, models.Location{Place: "Bakery"}))
+	loc, err = s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.Equal(models.Location{Place: "Bakery"}, loc, "replaced")
+
+	s.Require().NoError(s.db.SetExpenseLocation(id, models.Location{}))
+	loc, err = s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.True(loc.IsZero(), "forgotten")
+}
+
+func (s *LocationsTestSuite) TestFollowsExpense() {
+	id := s.expense(3, at(52.37, 4.89, "Café"))
+	s.Require().NoError(s.db.DeleteExpense(id))
+	s.Require().NoError(s.db.RestoreExpense(id))
+	loc, err := s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.Equal("Café", loc.Place, "restored along with the expense")
+
+	s.Require().NoError(s.db.DeleteExpense(id))
+	_, err = s.db.PurgeDeletedExpenses(time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	loc, err = s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.True(loc.IsZero(), "gone with the expense")
+}
+
+func (s *LocationsTestSuite) TestSpendingByPlace() {
+	s.expense(3, at(52.37, 4.89, "Café"))
+	s.expense(4, models.Location{Place: "café"})
+	s.expense(10, at(52.3701, 4.8901, ""))
+	s.expense(2, at(52.3702, 4.8902, ""))
+	s.expense(1, models.Location{Place: "Kiosk"})
+	s.Require().NoError(s.db.CreateExpense(50, "Rent", "Housing", s.day, 1))
+
+	totals, err := s.db.SpendingByPlace(ExpenseFilter{}, 10)
+	s.Require().NoError(err)
+	s.Require().Len(totals, 3)
+	s.Equal(12.0, totals[0].Total, "nearby unnamed places together")
+	s.Equal(2, totals[0].Count)
+	s.Empty(totals[0].Location.Place)
+	s.InDelta(52.37015, *totals[0].Location.Latitude, 1e-9)
+	s.Equal(7.0, totals[1].Total, "names regardless of case")
+	s.Equal("Café", totals[1].Location.Place)
+	s.InDelta(52.37, *totals[1].Location.Latitude, 1e-9, "where it was recorded")
+	s.Equal(PlaceTotal{Location: models.Location{Place: "Kiosk"}, Total: 1, Count: 1}, totals[2])
+
+	totals, err = s.db.SpendingByPlace(ExpenseFilter{From: s.day.Add(5 * time.Minute)}, 10)
+	s.Require().NoError(err)
+	s.Len(totals, 1, "filtered")
+	totals, err = s.db.SpendingByPlace(ExpenseFilter{}, 1)
+	s.Require().NoError(err)
+	s.Len(totals, 1, "limited")
+}
+
+func (s *LocationsTestSuite) TestRetention() {
+	id := s.expense(3, at(52.37, 4.89, "Café"))
+	_, err := s.db.ApplyRetention(RetentionPolicy{ExpenseYears: 1}, s.day.AddDate(2, 0, 0), false)
+	s.Require().NoError(err)
+	loc, err := s.db.GetExpenseLocation(id)
+	s.Require().NoError(err)
+	s.True(loc.IsZero(), "anonymized expenses forget where they were made")
+}
+
+// TestLocationsTestSuite runs the expense locations test suite
+func TestLocationsTestSuite(t *testing.T) {
+	suite.Run(t, new(LocationsTestSuite))
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// RecordLogin appends a login attempt to the audit log. userID is nil when
+// the username does not belong to any account.
+func (db *DB) RecordLogin(userID *int64, username, ip, userAgent string, success bool) error {
+	_, err := db.conn.Exec(
+		"INSERT INTO login_events (user_id, username, ip, user_agent, success, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, username, ip, userAgent, success, time.Now(),
+	)
+	return err
+}
+
+// ListLoginEvents retrieves a user's most recent login attempts, newest first.
+func (db *DB) ListLoginEvents(userID int64, limit int) ([]models.LoginEvent, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, user_id, username, ip, user_agent, success, created_at
+		 FROM login_events
+		 WHERE user_id = ?
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.LoginEvent
+	for rows.Next() {
+		var e models.LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.IP, &e.UserAgent, &e.Success, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LoginEventTestSuite provides a test suite for the login audit log
+type LoginEventTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *LoginEventTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *LoginEventTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *LoginEventTestSuite) TestRecordAndListLogins() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.db.RecordLogin(&alice.ID, "alice", "192.0.2.1", "Firefox", false))
+	s.Require().NoError(s.db.RecordLogin(&alice.ID, "alice", "192.0.2.1", "Firefox", true))
+	s.Require().NoError(s.db.RecordLogin(&bob.ID, "bob", "198.51.100.7", "Safari", true))
+	s.Require().NoError(s.db.RecordLogin(nil, "mallory", "203.0.113.9", "curl", false))
+
+	events, err := s.db.ListLoginEvents(alice.ID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(events, 2, "only the user's own attempts should be listed")
+	s.True(events[0].Success, "newest attempt first")
+	s.False(events[1].Success)
+	s.Equal("192.0.2.1", events[0].IP)
+	s.Equal("Firefox", events[0].UserAgent)
+	s.Equal(alice.ID, *events[0].UserID)
+	s.False(events[0].CreatedAt.IsZero())
+
+	events, err = s.db.ListLoginEvents(alice.ID, 1)
+	s.Require().NoError(err)
+	s.Len(events, 1, "the limit should be respected")
+}
+
+// Test suite runner
+func TestLoginEventSuite(t *testing.T) {
+	suite.Run(t, new(LoginEventTestSuite))
+}
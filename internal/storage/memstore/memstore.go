@@ -0,0 +1,2282 @@
+// Package memstore keeps the expense tracker's data in memory instead of
+// SQLite. It implements what the handlers need from a store, and can be made
+// to fail, so handler tests run fast and can check what happens when the
+// database is down.
+package memstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+)
+
+// Store holds users, expenses, sessions, passkeys, invites, and login
+// history in memory. It is safe for concurrent use. Like storage.DB, it
+// returns sql.ErrNoRows for records that don't exist and the storage
+// package's errors where storage.DB does. Unique constraints fail with the
+// same message as in SQLite.
+type Store struct {
+	mu       sync.Mutex
+	failures map[string]error // By method, "" for every method
+
+	users      map[int64]*user
+	expenses   map[int64]*models.Expense
+	sessions   map[string]*session
+	passkeys   map[string]*models.Passkey
+	invites    map[int64]*invite
+	logins     []models.LoginEvent
+	secrets    map[string][]byte
+	categories []string
+	version    storage.DataVersion
+	changes    map[int64]change          // Latest change by expense ID
+	deleted    map[int64]*models.Expense // Restorable by RestoreExpense
+	prefs      map[int64]models.Preferences
+	avatars    map[int64]models.Avatar
+	locations  map[int64]models.Location // By expense ID
+	reimburse  map[int64]reimbursement   // By expense ID
+	projects   map[int64]models.Project
+	assigned   map[int64]int64 // Project ID by expense ID
+	ledgers    map[int64]models.Ledger
+	channels   map[int64]models.NotificationChannel
+	deliveries []models.NotificationDelivery // Oldest first
+	challenges map[int64]models.Challenge
+	streaks    map[int64]models.Streak // By user ID
+	guests     map[int64]*guestToken
+
+	nextUserID    int64
+	nextExpenseID int64
+	nextInviteID  int64
+	nextLoginID   int64
+	nextProjectID int64
+	nextLedgerID  int64
+	nextChannelID int64
+	nextChallenge int64
+	nextGuestID   int64
+}
+
+// change is an expense's entry in the change feed.
+type change struct {
+	version int64
+	deleted bool
+}
+
+// reimbursement is the reimbursement state of a reimbursable expense.
+type reimbursement struct {
+	status    string
+	updatedAt time.Time
+}
+
+type user struct {
+	models.User
+	failedLogins int
+	lockedUntil  time.Time
+}
+
+type session struct {
+	userID       int64
+	expiresAt    time.Time
+	lastActivity time.Time
+	duration     time.Duration
+	fingerprint  string
+}
+
+type invite struct {
+	models.Invite
+	tokenHash string
+	usedBy    int64
+}
+
+type guestToken struct {
+	models.GuestToken
+	tokenHash string
+}
+
+// New returns an empty store with the built-in categories and the personal
+// ledger.
+func New() *Store {
+	return &Store{
+		failures:   map[string]error{},
+		users:      map[int64]*user{},
+		expenses:   map[int64]*models.Expense{},
+		sessions:   map[string]*session{},
+		passkeys:   map[string]*models.Passkey{},
+		invites:    map[int64]*invite{},
+		secrets:    map[string][]byte{},
+		categories: append([]string(nil), models.Categories...),
+		version:    storage.DataVersion{ChangedAt: time.Now()},
+		changes:    map[int64]change{},
+		deleted:    map[int64]*models.Expense{},
+		prefs:      map[int64]models.Preferences{},
+		avatars:    map[int64]models.Avatar{},
+		locations:  map[int64]models.Location{},
+		reimburse:  map[int64]reimbursement{},
+		projects:   map[int64]models.Project{},
+		assigned:   map[int64]int64{},
+		ledgers: map[int64]models.Ledger{
+			models.PersonalLedgerID: {ID: models.PersonalLedgerID, Name: "Personal", CreatedAt: time.Now().UTC()},
+		},
+		channels:     map[int64]models.NotificationChannel{},
+		challenges:   map[int64]models.Challenge{},
+		streaks:      map[int64]models.Streak{},
+		guests:       map[int64]*guestToken{},
+		nextLedgerID: models.PersonalLedgerID,
+	}
+}
+
+// ErrDown is an error for Fail, standing in for a database that can't be
+// reached.
+var ErrDown = errors.New("database is down")
+
+// Fail makes the named methods, e.g. "CreateExpense", return err from now on
+// without doing anything. Without names every method fails, as if the
+// database were down. A nil err makes them work again.
+func (s *Store) Fail(err error, methods ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(methods) == 0 {
+		methods = []string{""}
+	}
+	for _, m := range methods {
+		if err == nil {
+			delete(s.failures, m)
+		} else {
+			s.failures[m] = err
+		}
+	}
+}
+
+// failure returns the error a method was told to fail with. The caller holds
+// s.mu.
+func (s *Store) failure(method string) error {
+	if err, ok := s.failures[method]; ok {
+		return err
+	}
+	return s.failures[""]
+}
+
+// errUnique mimics SQLite's unique constraint error.
+func errUnique(columns ...string) error {
+	return fmt.Errorf("UNIQUE constraint failed: %s", strings.Join(columns, ", "))
+}
+
+// Expenses
+
+// CreateExpense adds an expense, dated now if date is zero.
+func (s *Store) CreateExpense(amount float64, description, category string, date time.Time, userID int64) error {
+	return s.insertExpense(&models.Expense{Amount: amount, Description: description, Category: category, Date: date, UserID: &userID}, "CreateExpense")
+}
+
+// InsertExpense adds e, dated now if its date is zero and in the personal
+// ledger if it has none, and sets its ID.
+func (s *Store) InsertExpense(e *models.Expense) error {
+	return s.insertExpense(e, "InsertExpense")
+}
+
+// InsertExpenseWithDetails adds e like InsertExpense together with its
+// details, all or nothing, like storage.DB.InsertExpenseWithDetails.
+func (s *Store) InsertExpenseWithDetails(e *models.Expense, d storage.ExpenseDetails) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("InsertExpenseWithDetails"); err != nil {
+		return err
+	}
+	if _, ok := s.projects[d.ProjectID]; d.ProjectID != 0 && !ok {
+		return sql.ErrNoRows
+	}
+	if err := s.addExpense(e); err != nil {
+		return err
+	}
+	if !d.Location.IsZero() {
+		s.locations[e.ID] = copyLocation(d.Location)
+	}
+	if d.Reimbursable {
+		s.reimburse[e.ID] = reimbursement{models.ReimbursementPending, time.Now().UTC()}
+	}
+	if d.ProjectID != 0 {
+		s.assigned[e.ID] = d.ProjectID
+	}
+	return nil
+}
+
+// insertExpense adds e unless method was made to fail.
+func (s *Store) insertExpense(e *models.Expense, method string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure(method); err != nil {
+		return err
+	}
+	return s.addExpense(e)
+}
+
+// addExpense adds e, dated now if its date is zero and in the personal
+// ledger if it has none, and sets its ID. The caller holds s.mu.
+func (s *Store) addExpense(e *models.Expense) error {
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+	if e.LedgerID == 0 {
+		e.LedgerID = models.PersonalLedgerID
+	}
+	if s.duplicate(e) {
+		return errUnique("expenses.date", "expenses.amount", "expenses.description")
+	}
+	s.nextExpenseID++
+	e.ID = s.nextExpenseID
+	s.expenses[e.ID] = copyExpense(e)
+	s.changed(e.ID, false)
+	return nil
+}
+
+// duplicate reports whether another expense has the same date, amount, and
+// description. The caller holds s.mu.
+func (s *Store) duplicate(e *models.Expense) bool {
+	for _, other := range s.expenses {
+		if other.ID != e.ID && other.Date.Equal(e.Date) && other.Amount == e.Amount && other.Description == e.Description {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportExpenses adds expenses for a user at once, skipping those that
+// already exist with the same date, amount, and description, and returns
+// how many were added. With dryRun nothing is written, but the count is the
+// same.
+func (s *Store) ImportExpenses(expenses []models.Expense, userID int64, dryRun bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ImportExpenses"); err != nil {
+		return 0, err
+	}
+	var added []*models.Expense
+	for _, e := range expenses {
+		e.ID = 0
+		e.UserID = &userID
+		if e.LedgerID == 0 {
+			e.LedgerID = models.PersonalLedgerID
+		}
+		if s.duplicate(&e) || slices.ContainsFunc(added, func(a *models.Expense) bool {
+			return a.Date.Equal(e.Date) && a.Amount == e.Amount && a.Description == e.Description
+		}) {
+			continue
+		}
+		added = append(added, &e)
+	}
+	if dryRun {
+		return len(added), nil
+	}
+	for _, e := range added {
+		s.nextExpenseID++
+		e.ID = s.nextExpenseID
+		s.expenses[e.ID] = copyExpense(e)
+		s.changed(e.ID, false)
+	}
+	return len(added), nil
+}
+
+// GetExpense returns an expense by ID.
+func (s *Store) GetExpense(id int64) (*models.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetExpense"); err != nil {
+		return nil, err
+	}
+	e, ok := s.expenses[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyExpense(e), nil
+}
+
+// UpdateExpense changes the amount, description, category, and date of an
+// expense. Updating one that doesn't exist does nothing.
+func (s *Store) UpdateExpense(e *models.Expense) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("UpdateExpense"); err != nil {
+		return err
+	}
+	return s.updateExpense(e)
+}
+
+// UpdateExpenseIfUnchanged updates an expense like UpdateExpense, unless it
+// changed after version, when it returns storage.ErrConflict. An expense
+// that doesn't exist gives sql.ErrNoRows.
+func (s *Store) UpdateExpenseIfUnchanged(e *models.Expense, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("UpdateExpenseIfUnchanged"); err != nil {
+		return err
+	}
+	if err := s.checkUnchanged(e.ID, version); err != nil {
+		return err
+	}
+	return s.updateExpense(e)
+}
+
+// updateExpense updates e if it exists. The caller holds s.mu.
+func (s *Store) updateExpense(e *models.Expense) error {
+	current, ok := s.expenses[e.ID]
+	if !ok {
+		return nil
+	}
+	if s.duplicate(e) {
+		return errUnique("expenses.date", "expenses.amount", "expenses.description")
+	}
+	current.Amount, current.Description, current.Category, current.Date = e.Amount, e.Description, e.Category, e.Date
+	s.changed(e.ID, false)
+	return nil
+}
+
+// DeleteExpense removes an expense by ID.
+func (s *Store) DeleteExpense(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteExpense"); err != nil {
+		return err
+	}
+	s.deleteExpense(id)
+	return nil
+}
+
+// DeleteExpenseIfUnchanged deletes an expense like DeleteExpense, unless it
+// changed after version, when it returns storage.ErrConflict. An expense
+// that doesn't exist gives sql.ErrNoRows.
+func (s *Store) DeleteExpenseIfUnchanged(id, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteExpenseIfUnchanged"); err != nil {
+		return err
+	}
+	if err := s.checkUnchanged(id, version); err != nil {
+		return err
+	}
+	s.deleteExpense(id)
+	return nil
+}
+
+// deleteExpense deletes the expense id if it exists. The caller holds s.mu.
+func (s *Store) deleteExpense(id int64) {
+	if e, ok := s.expenses[id]; ok {
+		delete(s.expenses, id)
+		s.deleted[id] = e
+		s.changed(id, true)
+	}
+}
+
+// RestoreExpense puts back a deleted expense with its ID. It returns
+// sql.ErrNoRows if it wasn't deleted, and a unique constraint error if an
+// identical expense was added meanwhile.
+func (s *Store) RestoreExpense(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RestoreExpense"); err != nil {
+		return err
+	}
+	e, ok := s.deleted[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if s.duplicate(e) {
+		return errUnique("expenses.date", "expenses.amount", "expenses.description")
+	}
+	delete(s.deleted, id)
+	s.expenses[id] = e
+	s.changed(id, false)
+	return nil
+}
+
+// checkUnchanged returns storage.ErrConflict if the expense id changed after
+// version, and sql.ErrNoRows if it doesn't exist. The caller holds s.mu.
+func (s *Store) checkUnchanged(id, version int64) error {
+	c, ok := s.changes[id]
+	switch {
+	case !ok:
+		return sql.ErrNoRows
+	case c.version > version:
+		return storage.ErrConflict
+	case c.deleted:
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ExpenseChanges returns at most limit of the expenses that changed after
+// version since, in the order they last changed, with the deleted ones as
+// tombstones.
+func (s *Store) ExpenseChanges(since int64, limit int) ([]storage.ExpenseChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ExpenseChanges"); err != nil {
+		return nil, err
+	}
+	var changes []storage.ExpenseChange
+	for id, c := range s.changes {
+		if c.version <= since {
+			continue
+		}
+		change := storage.ExpenseChange{Version: c.version, ID: id}
+		if !c.deleted {
+			change.Expense = copyExpense(s.expenses[id])
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Version < changes[j].Version })
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+// changed moves the data version on after a write to the expense id, and
+// moves the expense to the end of the change feed. Versions of both are the
+// same. The caller holds s.mu.
+func (s *Store) changed(id int64, deleted bool) {
+	s.version.Version++
+	s.version.ChangedAt = time.Now()
+	s.changes[id] = change{version: s.version.Version, deleted: deleted}
+}
+
+// DataVersion returns the data version, which every change to the expenses
+// increases.
+func (s *Store) DataVersion() (storage.DataVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DataVersion"); err != nil {
+		return storage.DataVersion{}, err
+	}
+	return s.version, nil
+}
+
+// FilterExpenses returns the expenses matching a filter, newest first.
+func (s *Store) FilterExpenses(f storage.ExpenseFilter) ([]models.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("FilterExpenses"); err != nil {
+		return nil, err
+	}
+	return s.filter(f), nil
+}
+
+// PageExpenses returns at most limit of the expenses matching f, newest
+// first, after skipping offset of them.
+func (s *Store) PageExpenses(f storage.ExpenseFilter, limit, offset int) ([]models.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("PageExpenses"); err != nil {
+		return nil, err
+	}
+	matched := s.filter(f)
+	if offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[offset:]
+	return matched[:min(limit, len(matched))], nil
+}
+
+// SearchExpenses returns at most limit expenses of a ledger, newest first,
+// whose description or category contains every word of query, regardless of
+// case. Ledger zero searches all ledgers.
+func (s *Store) SearchExpenses(query string, ledgerID int64, limit int) ([]models.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SearchExpenses"); err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(query))
+	var found []models.Expense
+	for _, e := range s.filter(storage.ExpenseFilter{Ledger: ledgerID}) {
+		text := strings.ToLower(e.Description + "\x00" + e.Category)
+		if !slices.ContainsFunc(words, func(word string) bool { return !strings.Contains(text, word) }) {
+			found = append(found, e)
+		}
+	}
+	return found[:min(limit, len(found))], nil
+}
+
+// ScrollExpenses returns at most limit of the expenses matching f, newest
+// first, starting after a cursor, and the cursor for the next page, which is
+// zero after the last one. Its cursors hold the date in RFC 3339.
+func (s *Store) ScrollExpenses(f storage.ExpenseFilter, after storage.ExpenseCursor, limit int) ([]models.Expense, storage.ExpenseCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ScrollExpenses"); err != nil {
+		return nil, storage.ExpenseCursor{}, err
+	}
+	matched := s.filter(f)
+	if after != (storage.ExpenseCursor{}) {
+		date, err := time.Parse(time.RFC3339Nano, after.Date)
+		if err != nil {
+			return nil, storage.ExpenseCursor{}, fmt.Errorf("invalid cursor: %w", err)
+		}
+		matched = slices.DeleteFunc(matched, func(e models.Expense) bool {
+			return !e.Date.Before(date) && (e.Date.After(date) || e.ID >= after.ID)
+		})
+	}
+	if len(matched) <= limit {
+		return matched, storage.ExpenseCursor{}, nil
+	}
+	last := matched[limit-1]
+	return matched[:limit], storage.ExpenseCursor{Date: last.Date.Format(time.RFC3339Nano), ID: last.ID}, nil
+}
+
+// StreamExpenses calls fn with each of the expenses matching f, oldest
+// first, and stops at the first error fn returns. The store has no archive,
+// so these are all the expenses there are.
+func (s *Store) StreamExpenses(f storage.ExpenseFilter, fn func(models.Expense) error) error {
+	s.mu.Lock()
+	err := s.failure("StreamExpenses")
+	matched := s.filter(f)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, e := range slices.Backward(matched) {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns copies of the expenses matching f, newest first. The caller
+// holds s.mu.
+func (s *Store) filter(f storage.ExpenseFilter) []models.Expense {
+	var matched []models.Expense
+	for _, e := range s.expenses {
+		if s.matches(f, e) {
+			matched = append(matched, *copyExpense(e))
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].Date.Equal(matched[j].Date) {
+			return matched[i].Date.After(matched[j].Date)
+		}
+		return matched[i].ID > matched[j].ID
+	})
+	return matched
+}
+
+// matches reports whether e passes filter f. The caller holds s.mu.
+func (s *Store) matches(f storage.ExpenseFilter, e *models.Expense) bool {
+	switch {
+	case !f.From.IsZero() && e.Date.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Date.Before(f.To):
+		return false
+	case f.Category != "" && !strings.EqualFold(e.Category, f.Category):
+		return false
+	case f.Query != "" && !strings.Contains(strings.ToLower(e.Description), strings.ToLower(f.Query)):
+		return false
+	case f.UserID != 0 && (e.UserID == nil || *e.UserID != f.UserID):
+		return false
+	case f.Ledger != 0 && e.LedgerID != f.Ledger:
+		return false
+	case f.Viewer != 0 && !s.ledgers[e.LedgerID].VisibleTo(f.Viewer):
+		return false
+	}
+	return true
+}
+
+// LatestExpenseDate returns the date of the newest expense matching a
+// filter, or the zero time if nothing matches.
+func (s *Store) LatestExpenseDate(f storage.ExpenseFilter) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("LatestExpenseDate"); err != nil {
+		return time.Time{}, err
+	}
+	matched := s.filter(f)
+	if len(matched) == 0 {
+		return time.Time{}, nil
+	}
+	return matched[0].Date, nil
+}
+
+// SummarizeExpenses computes the headline figures for a filter like
+// storage.DB.SummarizeExpenses.
+func (s *Store) SummarizeExpenses(f storage.ExpenseFilter) (*storage.ExpenseSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SummarizeExpenses"); err != nil {
+		return nil, err
+	}
+	matched := s.filter(f)
+	summary := &storage.ExpenseSummary{Count: len(matched)}
+	if len(matched) == 0 {
+		return summary, nil
+	}
+	// Newest first, so the first of the largest is the newest of them
+	largest := matched[0]
+	for _, e := range matched {
+		summary.Total += e.Amount
+		if e.Amount > largest.Amount {
+			largest = e
+		}
+	}
+	summary.Largest = &largest
+
+	from := f.From
+	if from.IsZero() {
+		from = matched[len(matched)-1].Date
+	}
+	summary.AveragePerDay = summary.Total / float64(periodDays(from, f.To, time.Now()))
+	return summary, nil
+}
+
+// CategoryTotals sums up the expenses matching f per category, the largest
+// total first.
+func (s *Store) CategoryTotals(f storage.ExpenseFilter) ([]storage.CategoryTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CategoryTotals"); err != nil {
+		return nil, err
+	}
+	var totals []storage.CategoryTotal
+	index := map[string]int{}
+	for _, e := range s.filter(f) {
+		i, ok := index[e.Category]
+		if !ok {
+			i = len(totals)
+			index[e.Category] = i
+			totals = append(totals, storage.CategoryTotal{Category: e.Category})
+		}
+		totals[i].Total += e.Amount
+		totals[i].Count++
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Total != totals[j].Total {
+			return totals[i].Total > totals[j].Total
+		}
+		return totals[i].Category < totals[j].Category
+	})
+	return totals, nil
+}
+
+// GetExpenseLocation returns where an expense was made, or the zero
+// Location if that wasn't recorded.
+func (s *Store) GetExpenseLocation(expenseID int64) (models.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetExpenseLocation"); err != nil {
+		return models.Location{}, err
+	}
+	return copyLocation(s.locations[expenseID]), nil
+}
+
+// SetExpenseLocation records where an expense was made. The zero Location
+// forgets it.
+func (s *Store) SetExpenseLocation(expenseID int64, loc models.Location) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetExpenseLocation"); err != nil {
+		return err
+	}
+	if loc.IsZero() {
+		delete(s.locations, expenseID)
+	} else {
+		s.locations[expenseID] = copyLocation(loc)
+	}
+	// Not a change of the expense, but of the pages showing it
+	s.version.Version++
+	s.version.ChangedAt = time.Now()
+	return nil
+}
+
+// SpendingByPlace sums up the expenses matching f per place like
+// storage.DB.SpendingByPlace.
+func (s *Store) SpendingByPlace(f storage.ExpenseFilter, limit int) ([]storage.PlaceTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SpendingByPlace"); err != nil {
+		return nil, err
+	}
+	type place struct {
+		total          storage.PlaceTotal
+		lat, lon       float64
+		withCoordinate int
+	}
+	var places []*place
+	index := map[string]*place{}
+	for _, e := range s.filter(f) {
+		loc, ok := s.locations[e.ID]
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(loc.Place)
+		if key == "" {
+			key = fmt.Sprintf("%.3f,%.3f", *loc.Latitude, *loc.Longitude)
+		}
+		p, ok := index[key]
+		if !ok {
+			p = &place{total: storage.PlaceTotal{Location: models.Location{Place: loc.Place}}}
+			index[key] = p
+			places = append(places, p)
+		}
+		if loc.Place < p.total.Location.Place {
+			p.total.Location.Place = loc.Place
+		}
+		if loc.Latitude != nil {
+			p.lat, p.lon, p.withCoordinate = p.lat+*loc.Latitude, p.lon+*loc.Longitude, p.withCoordinate+1
+		}
+		p.total.Total += e.Amount
+		p.total.Count++
+	}
+	totals := make([]storage.PlaceTotal, 0, len(places))
+	for _, p := range places {
+		if p.withCoordinate > 0 {
+			lat, lon := p.lat/float64(p.withCoordinate), p.lon/float64(p.withCoordinate)
+			p.total.Location.Latitude, p.total.Location.Longitude = &lat, &lon
+		}
+		totals = append(totals, p.total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Total != totals[j].Total {
+			return totals[i].Total > totals[j].Total
+		}
+		return totals[i].Location.Place < totals[j].Location.Place
+	})
+	return totals[:min(limit, len(totals))], nil
+}
+
+// GetReimbursement returns the reimbursement state of an expense, empty if
+// it isn't reimbursable.
+func (s *Store) GetReimbursement(expenseID int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetReimbursement"); err != nil {
+		return "", err
+	}
+	return s.reimburse[expenseID].status, nil
+}
+
+// SetReimbursement sets the reimbursement state of an expense. An empty
+// status makes it not reimbursable.
+func (s *Store) SetReimbursement(expenseID int64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetReimbursement"); err != nil {
+		return err
+	}
+	switch status {
+	case "":
+		delete(s.reimburse, expenseID)
+	case models.ReimbursementPending, models.ReimbursementSubmitted, models.ReimbursementReimbursed:
+		s.reimburse[expenseID] = reimbursement{status, time.Now().UTC()}
+	default:
+		return errors.New("CHECK constraint failed: status IN ('pending', 'submitted', 'reimbursed')")
+	}
+	return nil
+}
+
+// SetReimbursements moves the user's reimbursable expenses among ids to a
+// reimbursement state like storage.DB.SetReimbursements.
+func (s *Store) SetReimbursements(userID int64, ids []int64, status string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetReimbursements"); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, id := range ids {
+		r, ok := s.reimburse[id]
+		e := s.expenses[id]
+		if !ok || r.status == status || e == nil || e.UserID == nil || *e.UserID != userID {
+			continue
+		}
+		s.reimburse[id] = reimbursement{status, time.Now().UTC()}
+		n++
+	}
+	return n, nil
+}
+
+// OutstandingReimbursements returns the user's expenses that are still to be
+// paid back, pending ones first, oldest first.
+func (s *Store) OutstandingReimbursements(userID int64) ([]storage.Reimbursable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("OutstandingReimbursements"); err != nil {
+		return nil, err
+	}
+	var outstanding []storage.Reimbursable
+	for _, e := range s.filter(storage.ExpenseFilter{UserID: userID}) {
+		r := s.reimburse[e.ID]
+		if r.status == models.ReimbursementPending || r.status == models.ReimbursementSubmitted {
+			outstanding = append(outstanding, storage.Reimbursable{Expense: e, Status: r.status, UpdatedAt: r.updatedAt})
+		}
+	}
+	sort.Slice(outstanding, func(i, j int) bool {
+		a, b := outstanding[i], outstanding[j]
+		if (a.Status == models.ReimbursementPending) != (b.Status == models.ReimbursementPending) {
+			return a.Status == models.ReimbursementPending
+		}
+		if !a.Expense.Date.Equal(b.Expense.Date) {
+			return a.Expense.Date.Before(b.Expense.Date)
+		}
+		return a.Expense.ID < b.Expense.ID
+	})
+	return outstanding, nil
+}
+
+// CreateProject adds a project.
+func (s *Store) CreateProject(name string) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateProject"); err != nil {
+		return nil, err
+	}
+	for _, p := range s.projects {
+		if strings.EqualFold(p.Name, name) {
+			return nil, storage.ErrProjectExists
+		}
+	}
+	s.nextProjectID++
+	p := models.Project{ID: s.nextProjectID, Name: name, CreatedAt: time.Now().UTC()}
+	s.projects[p.ID] = p
+	return &p, nil
+}
+
+// ListProjects returns all projects by name.
+func (s *Store) ListProjects() ([]models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListProjects"); err != nil {
+		return nil, err
+	}
+	var projects []models.Project
+	for _, p := range s.projects {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return strings.ToLower(projects[i].Name) < strings.ToLower(projects[j].Name)
+	})
+	return projects, nil
+}
+
+// GetProject returns a project by ID, or sql.ErrNoRows.
+func (s *Store) GetProject(id int64) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetProject"); err != nil {
+		return nil, err
+	}
+	p, ok := s.projects[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &p, nil
+}
+
+// DeleteProject removes a project, unassigning its expenses. It returns
+// sql.ErrNoRows if the project doesn't exist.
+func (s *Store) DeleteProject(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteProject"); err != nil {
+		return err
+	}
+	if _, ok := s.projects[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.projects, id)
+	for expenseID, projectID := range s.assigned {
+		if projectID == id {
+			delete(s.assigned, expenseID)
+		}
+	}
+	return nil
+}
+
+// GetExpenseProject returns the ID of the project an expense is assigned
+// to, zero if none.
+func (s *Store) GetExpenseProject(expenseID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetExpenseProject"); err != nil {
+		return 0, err
+	}
+	return s.assigned[expenseID], nil
+}
+
+// SetExpenseProject assigns an expense to a project. Project zero
+// unassigns it, and a project that doesn't exist gives sql.ErrNoRows.
+func (s *Store) SetExpenseProject(expenseID, projectID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetExpenseProject"); err != nil {
+		return err
+	}
+	if projectID == 0 {
+		delete(s.assigned, expenseID)
+		return nil
+	}
+	if _, ok := s.projects[projectID]; !ok {
+		return sql.ErrNoRows
+	}
+	s.assigned[expenseID] = projectID
+	return nil
+}
+
+// SummarizeProject sums up the expenses of a project like
+// storage.DB.SummarizeProject.
+func (s *Store) SummarizeProject(projectID, userID int64) (*storage.ProjectSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SummarizeProject"); err != nil {
+		return nil, err
+	}
+	var expenses []*models.Expense
+	for _, e := range s.expenses {
+		if s.assigned[e.ID] == projectID && s.ledgers[e.LedgerID].VisibleTo(userID) {
+			expenses = append(expenses, e)
+		}
+	}
+	summary := &storage.ProjectSummary{Categories: categoryTotals(expenses)}
+	for _, e := range expenses {
+		summary.Count++
+		summary.Total += e.Amount
+		if summary.First.IsZero() || e.Date.Before(summary.First) {
+			summary.First = e.Date
+		}
+		if e.Date.After(summary.Last) {
+			summary.Last = e.Date
+		}
+	}
+	return summary, nil
+}
+
+// CreateLedger adds a ledger like storage.DB.CreateLedger.
+func (s *Store) CreateLedger(name, currency string, ownerID int64) (*models.Ledger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateLedger"); err != nil {
+		return nil, err
+	}
+	for _, l := range s.ledgers {
+		if strings.EqualFold(l.Name, name) && l.VisibleTo(ownerID) {
+			return nil, storage.ErrLedgerExists
+		}
+	}
+	s.nextLedgerID++
+	l := models.Ledger{ID: s.nextLedgerID, Name: name, Currency: currency, CreatedAt: time.Now().UTC()}
+	if ownerID != 0 {
+		l.OwnerID = &ownerID
+	}
+	s.ledgers[l.ID] = l
+	return &l, nil
+}
+
+// ListLedgers returns the ledgers a user sees, archived ones included, the
+// personal one first and the others by name.
+func (s *Store) ListLedgers(userID int64) ([]models.Ledger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListLedgers"); err != nil {
+		return nil, err
+	}
+	return s.sortedLedgers(userID), nil
+}
+
+// sortedLedgers returns the ledgers a user sees in the order of ListLedgers.
+func (s *Store) sortedLedgers(userID int64) []models.Ledger {
+	var ledgers []models.Ledger
+	for _, l := range s.ledgers {
+		if l.VisibleTo(userID) {
+			ledgers = append(ledgers, l)
+		}
+	}
+	sort.Slice(ledgers, func(i, j int) bool {
+		if personal := ledgers[i].ID == models.PersonalLedgerID; personal || ledgers[j].ID == models.PersonalLedgerID {
+			return personal
+		}
+		return strings.ToLower(ledgers[i].Name) < strings.ToLower(ledgers[j].Name)
+	})
+	return ledgers
+}
+
+// GetLedger returns a ledger by ID, or sql.ErrNoRows.
+func (s *Store) GetLedger(id int64) (*models.Ledger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetLedger"); err != nil {
+		return nil, err
+	}
+	l, ok := s.ledgers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &l, nil
+}
+
+// SetLedgerCurrency changes the currency a ledger is kept in, or returns
+// sql.ErrNoRows.
+func (s *Store) SetLedgerCurrency(id int64, currency string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetLedgerCurrency"); err != nil {
+		return err
+	}
+	l, ok := s.ledgers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	l.Currency = currency
+	s.ledgers[id] = l
+	return nil
+}
+
+// ArchiveLedger puts a ledger away or brings it back like
+// storage.DB.ArchiveLedger.
+func (s *Store) ArchiveLedger(id int64, archived bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ArchiveLedger"); err != nil {
+		return err
+	}
+	if id == models.PersonalLedgerID {
+		return storage.ErrLedgerInUse
+	}
+	l, ok := s.ledgers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	switch {
+	case !archived:
+		l.ArchivedAt = nil
+	case l.ArchivedAt == nil:
+		now := time.Now().UTC()
+		l.ArchivedAt = &now
+	}
+	s.ledgers[id] = l
+	if archived {
+		for userID, p := range s.prefs {
+			if p.Ledger == id {
+				p.Ledger = models.PersonalLedgerID
+				s.prefs[userID] = p
+			}
+		}
+	}
+	return nil
+}
+
+// LedgerTotals sums up the expenses between start and end per ledger a user
+// sees, every such ledger listed.
+func (s *Store) LedgerTotals(userID int64, start, end time.Time) ([]storage.LedgerTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("LedgerTotals"); err != nil {
+		return nil, err
+	}
+	var totals []storage.LedgerTotal
+	for _, l := range s.sortedLedgers(userID) {
+		t := storage.LedgerTotal{Ledger: l}
+		for _, e := range s.between(l.ID, start, end) {
+			t.Total += e.Amount
+			t.Count++
+		}
+		totals = append(totals, t)
+	}
+	return totals, nil
+}
+
+// DeleteLedger removes a ledger without expenses like storage.DB.DeleteLedger.
+func (s *Store) DeleteLedger(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteLedger"); err != nil {
+		return err
+	}
+	if id == models.PersonalLedgerID {
+		return storage.ErrLedgerInUse
+	}
+	if _, ok := s.ledgers[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, e := range s.expenses {
+		if e.LedgerID == id {
+			return storage.ErrLedgerInUse
+		}
+	}
+	for _, e := range s.deleted {
+		if e.LedgerID == id {
+			e.LedgerID = models.PersonalLedgerID
+		}
+	}
+	for userID, p := range s.prefs {
+		if p.Ledger == id {
+			p.Ledger = models.PersonalLedgerID
+			s.prefs[userID] = p
+		}
+	}
+	for challengeID, c := range s.challenges {
+		if c.LedgerID == id {
+			c.LedgerID = models.PersonalLedgerID
+			s.challenges[challengeID] = c
+		}
+	}
+	for guestID, t := range s.guests {
+		if t.LedgerID == id {
+			delete(s.guests, guestID)
+		}
+	}
+	delete(s.ledgers, id)
+	return nil
+}
+
+// periodDays counts the calendar days from the day of from up to the
+// exclusive bound to, or through the day of now if to is unset or later.
+func periodDays(from, to, now time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if !to.IsZero() && to.Before(end) {
+		end = to
+	}
+	return max(int(math.Round(end.Sub(start).Hours()/24)), 1)
+}
+
+// ListCategories returns the category names in display order.
+func (s *Store) ListCategories() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListCategories"); err != nil {
+		return nil, err
+	}
+	return append([]string(nil), s.categories...), nil
+}
+
+// CategoryUsage returns the categories of the user's expenses on or after
+// since, most used first and, among equally used ones, most recently used
+// first.
+func (s *Store) CategoryUsage(userID int64, since time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CategoryUsage"); err != nil {
+		return nil, err
+	}
+	count := map[string]int{}
+	last := map[string]time.Time{}
+	for _, e := range s.expenses {
+		if e.UserID == nil || *e.UserID != userID || e.Date.Before(since) {
+			continue
+		}
+		count[e.Category]++
+		if e.Date.After(last[e.Category]) {
+			last[e.Category] = e.Date
+		}
+	}
+	names := make([]string, 0, len(count))
+	for name := range count {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if count[a] != count[b] {
+			return count[a] > count[b]
+		}
+		if !last[a].Equal(last[b]) {
+			return last[a].After(last[b])
+		}
+		return a < b
+	})
+	return names, nil
+}
+
+// Statistics
+
+// day returns the YYYY-MM-DD day an expense counts towards, like the
+// aggregates storage.DB keeps.
+func day(e *models.Expense) string {
+	return e.Date.Format(time.DateOnly)
+}
+
+// between returns the expenses of a ledger, or of all of them for ledger
+// zero, on days in [start, end). The caller holds s.mu.
+func (s *Store) between(ledgerID int64, start, end time.Time) []*models.Expense {
+	from, to := start.Format(time.DateOnly), end.Format(time.DateOnly)
+	var matched []*models.Expense
+	for _, e := range s.expenses {
+		if d := day(e); d >= from && d < to && (ledgerID == 0 || e.LedgerID == ledgerID) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// categoryTotals sums expenses per category, largest total first.
+func categoryTotals(expenses []*models.Expense) []storage.CategoryTotal {
+	byCategory := map[string]*storage.CategoryTotal{}
+	var totals []storage.CategoryTotal
+	for _, e := range expenses {
+		ct, ok := byCategory[e.Category]
+		if !ok {
+			ct = &storage.CategoryTotal{Category: e.Category}
+			byCategory[e.Category] = ct
+		}
+		ct.Total += e.Amount
+		ct.Count++
+	}
+	for _, ct := range byCategory {
+		totals = append(totals, *ct)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Total != totals[j].Total {
+			return totals[i].Total > totals[j].Total
+		}
+		return totals[i].Category < totals[j].Category
+	})
+	return totals
+}
+
+// sorted returns copies of expenses, newest first.
+func sorted(expenses []*models.Expense) []models.Expense {
+	var copies []models.Expense
+	for _, e := range expenses {
+		copies = append(copies, *copyExpense(e))
+	}
+	sort.Slice(copies, func(i, j int) bool { return copies[i].Date.After(copies[j].Date) })
+	return copies
+}
+
+// GetMonthStats returns the totals, category breakdown, and daily totals of
+// a month, plus the previous month's total, of a ledger or of all of them
+// for ledger zero, as do the statistics below.
+func (s *Store) GetMonthStats(ledgerID int64, year, month int) (*storage.MonthStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetMonthStats"); err != nil {
+		return nil, err
+	}
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	stats := &storage.MonthStats{}
+	for _, e := range s.between(ledgerID, start.AddDate(0, -1, 0), start) {
+		stats.PrevTotal += e.Amount
+	}
+	expenses := s.between(ledgerID, start, end)
+	daily := map[int]float64{}
+	for _, e := range expenses {
+		stats.Total += e.Amount
+		daily[e.Date.Day()] += e.Amount
+	}
+	stats.Categories = categoryTotals(expenses)
+	for d, total := range daily {
+		stats.Daily = append(stats.Daily, storage.DailyTotal{Day: d, Total: total})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool { return stats.Daily[i].Day < stats.Daily[j].Day })
+	return stats, nil
+}
+
+// GetTotalForPeriod returns the total of a month, or of a year if month is 0.
+func (s *Store) GetTotalForPeriod(ledgerID int64, year, month int) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetTotalForPeriod"); err != nil {
+		return 0, err
+	}
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	if month != 0 {
+		start = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	}
+	var total float64
+	for _, e := range s.between(ledgerID, start, end) {
+		total += e.Amount
+	}
+	return total, nil
+}
+
+// GetTotalForCycle returns the total of the month-long budget period
+// beginning on the day of start.
+func (s *Store) GetTotalForCycle(ledgerID int64, start time.Time) (float64, error) {
+	return s.total("GetTotalForCycle", ledgerID, start, start.AddDate(0, 1, 0))
+}
+
+// GetTotalBetween returns the total of the days from the day of start up to
+// the day of end, exclusive.
+func (s *Store) GetTotalBetween(ledgerID int64, start, end time.Time) (float64, error) {
+	return s.total("GetTotalBetween", ledgerID, start, end)
+}
+
+// total sums the expenses of the days from the day of start up to the day
+// of end, failing as op when told to.
+func (s *Store) total(op string, ledgerID int64, start, end time.Time) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure(op); err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, e := range s.between(ledgerID, start, end) {
+		total += e.Amount
+	}
+	return total, nil
+}
+
+// GetMonthlyTotalsForYear returns the totals of the months of a year that
+// have expenses.
+func (s *Store) GetMonthlyTotalsForYear(ledgerID int64, year int) ([]storage.MonthlyTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetMonthlyTotalsForYear"); err != nil {
+		return nil, err
+	}
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	byMonth := map[int]float64{}
+	for _, e := range s.between(ledgerID, start, start.AddDate(1, 0, 0)) {
+		byMonth[int(e.Date.Month())] += e.Amount
+	}
+	var totals []storage.MonthlyTotal
+	for m, total := range byMonth {
+		totals = append(totals, storage.MonthlyTotal{Month: m, Total: total})
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Month < totals[j].Month })
+	return totals, nil
+}
+
+// GetCategoryTotalsByYear returns the totals per category of a year, largest
+// first.
+func (s *Store) GetCategoryTotalsByYear(ledgerID int64, year int) ([]storage.CategoryTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetCategoryTotalsByYear"); err != nil {
+		return nil, err
+	}
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return categoryTotals(s.between(ledgerID, start, start.AddDate(1, 0, 0))), nil
+}
+
+// Users and accounts
+
+// CreateUser adds a user. The first one becomes an admin, everyone after a
+// member.
+func (s *Store) CreateUser(username, passwordHash string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateUser"); err != nil {
+		return nil, err
+	}
+	u, err := s.createUser(username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(u), nil
+}
+
+// createUser adds a user. The caller holds s.mu.
+func (s *Store) createUser(username, passwordHash string) (*user, error) {
+	for _, u := range s.users {
+		if u.Username == username {
+			return nil, errUnique("users.username")
+		}
+	}
+	role := models.RoleMember
+	if len(s.users) == 0 {
+		role = models.RoleAdmin
+	}
+	s.nextUserID++
+	u := &user{User: models.User{ID: s.nextUserID, Username: username, PasswordHash: passwordHash, CreatedAt: time.Now(), Role: role}}
+	s.users[u.ID] = u
+	return u, nil
+}
+
+// GetUserByID returns a user by ID.
+func (s *Store) GetUserByID(id int64) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetUserByID"); err != nil {
+		return nil, err
+	}
+	u, ok := s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyUser(u), nil
+}
+
+// GetUserByUsername returns a user by username.
+func (s *Store) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetUserByUsername"); err != nil {
+		return nil, err
+	}
+	for _, u := range s.users {
+		if u.Username == username {
+			return copyUser(u), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// RenameUser changes a user's username, see storage.DB.RenameUser.
+func (s *Store) RenameUser(userID int64, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RenameUser"); err != nil {
+		return err
+	}
+	for _, u := range s.users {
+		if u.ID != userID && strings.EqualFold(u.Username, username) {
+			return storage.ErrUsernameTaken
+		}
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.Username = username
+	return nil
+}
+
+// UserExpenseCount returns how many expenses a user has.
+func (s *Store) UserExpenseCount(userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("UserExpenseCount"); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, e := range s.expenses {
+		if e.UserID != nil && *e.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// emailTaken reports whether another user verified email. The caller holds
+// s.mu.
+func (s *Store) emailTaken(userID int64, email string) bool {
+	for _, u := range s.users {
+		if u.ID != userID && u.EmailVerified && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEmail changes a user's email address, unverified until VerifyEmail.
+func (s *Store) SetEmail(userID int64, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetEmail"); err != nil {
+		return err
+	}
+	if s.emailTaken(userID, email) {
+		return storage.ErrEmailTaken
+	}
+	if u, ok := s.users[userID]; ok {
+		u.Email, u.EmailVerified = email, false
+	}
+	return nil
+}
+
+// VerifyEmail marks a user's email address as verified, see
+// storage.DB.VerifyEmail.
+func (s *Store) VerifyEmail(userID int64, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("VerifyEmail"); err != nil {
+		return err
+	}
+	u, ok := s.users[userID]
+	if !ok || u.Email != email {
+		return sql.ErrNoRows
+	}
+	if !u.EmailVerified && s.emailTaken(userID, email) {
+		return storage.ErrEmailTaken
+	}
+	u.EmailVerified = true
+	return nil
+}
+
+// GetPreferences returns a user's preferences, or
+// models.DefaultPreferences if they never saved any.
+func (s *Store) GetPreferences(userID int64) (models.Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetPreferences"); err != nil {
+		return models.Preferences{}, err
+	}
+	if p, ok := s.prefs[userID]; ok {
+		return p, nil
+	}
+	return models.DefaultPreferences(), nil
+}
+
+// SavePreferences stores a user's preferences, replacing those saved before.
+func (s *Store) SavePreferences(userID int64, p models.Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SavePreferences"); err != nil {
+		return err
+	}
+	s.prefs[userID] = p
+	return nil
+}
+
+// SetAvatar stores a user's avatar, replacing the one they had.
+func (s *Store) SetAvatar(userID int64, image []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetAvatar"); err != nil {
+		return err
+	}
+	s.avatars[userID] = models.Avatar{UserID: userID, Image: bytes.Clone(image), ContentType: contentType, UpdatedAt: time.Now()}
+	return nil
+}
+
+// GetAvatar returns a user's avatar, or sql.ErrNoRows if they have none.
+func (s *Store) GetAvatar(userID int64) (*models.Avatar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetAvatar"); err != nil {
+		return nil, err
+	}
+	a, ok := s.avatars[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	a.Image = bytes.Clone(a.Image)
+	return &a, nil
+}
+
+// DeleteAvatar removes a user's avatar, if they have one.
+func (s *Store) DeleteAvatar(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteAvatar"); err != nil {
+		return err
+	}
+	delete(s.avatars, userID)
+	return nil
+}
+
+// ScheduleAccountDeletion marks an account for deletion at the given time
+// and ends its sessions.
+func (s *Store) ScheduleAccountDeletion(userID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ScheduleAccountDeletion"); err != nil {
+		return err
+	}
+	if u, ok := s.users[userID]; ok {
+		u.DeleteAfter = &at
+	}
+	for token, sess := range s.sessions {
+		if sess.userID == userID {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
+
+// CancelAccountDeletion keeps an account scheduled for deletion and reports
+// whether a deletion was pending.
+func (s *Store) CancelAccountDeletion(userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CancelAccountDeletion"); err != nil {
+		return false, err
+	}
+	u, ok := s.users[userID]
+	if !ok || u.DeleteAfter == nil {
+		return false, nil
+	}
+	u.DeleteAfter = nil
+	return true, nil
+}
+
+// Logins
+
+// GetLoginLockout returns a user's failed login state.
+func (s *Store) GetLoginLockout(userID int64) (*storage.LoginLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetLoginLockout"); err != nil {
+		return nil, err
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &storage.LoginLockout{FailedLogins: u.failedLogins, LockedUntil: u.lockedUntil}, nil
+}
+
+// RecordFailedLogin counts a failed login and returns the new count.
+func (s *Store) RecordFailedLogin(userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RecordFailedLogin"); err != nil {
+		return 0, err
+	}
+	u, ok := s.users[userID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	u.failedLogins++
+	return u.failedLogins, nil
+}
+
+// LockUser blocks logins for a user until the given time.
+func (s *Store) LockUser(userID int64, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("LockUser"); err != nil {
+		return err
+	}
+	if u, ok := s.users[userID]; ok {
+		u.lockedUntil = until
+	}
+	return nil
+}
+
+// ResetFailedLogins clears a user's failed login count and any lock.
+func (s *Store) ResetFailedLogins(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ResetFailedLogins"); err != nil {
+		return err
+	}
+	if u, ok := s.users[userID]; ok {
+		u.failedLogins, u.lockedUntil = 0, time.Time{}
+	}
+	return nil
+}
+
+// RecordLogin adds a login attempt to the history.
+func (s *Store) RecordLogin(userID *int64, username, ip, userAgent string, success bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RecordLogin"); err != nil {
+		return err
+	}
+	s.nextLoginID++
+	event := models.LoginEvent{ID: s.nextLoginID, Username: username, IP: ip, UserAgent: userAgent, Success: success, CreatedAt: time.Now()}
+	if userID != nil {
+		id := *userID
+		event.UserID = &id
+	}
+	s.logins = append(s.logins, event)
+	return nil
+}
+
+// ListLoginEvents returns a user's most recent login attempts, newest first.
+func (s *Store) ListLoginEvents(userID int64, limit int) ([]models.LoginEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListLoginEvents"); err != nil {
+		return nil, err
+	}
+	var events []models.LoginEvent
+	// Recorded in order, so newest last
+	for i := len(s.logins) - 1; i >= 0 && len(events) < limit; i-- {
+		if e := s.logins[i]; e.UserID != nil && *e.UserID == userID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Sessions
+
+// CreateSession starts a session for a user that expires after duration.
+func (s *Store) CreateSession(token string, userID int64, duration time.Duration, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateSession"); err != nil {
+		return err
+	}
+	if _, ok := s.sessions[token]; ok {
+		return errUnique("sessions.token")
+	}
+	now := time.Now()
+	s.sessions[token] = &session{
+		userID:       userID,
+		expiresAt:    now.Add(duration),
+		lastActivity: now,
+		duration:     duration.Truncate(time.Second),
+		fingerprint:  fingerprint,
+	}
+	return nil
+}
+
+// ValidateSession returns the user of a session that hasn't expired.
+func (s *Store) ValidateSession(token string) (*models.User, error) {
+	info, err := s.ValidateSessionWithInfo(token)
+	if err != nil {
+		return nil, err
+	}
+	return info.User, nil
+}
+
+// ValidateSessionWithInfo returns the user and details of a session that
+// hasn't expired.
+func (s *Store) ValidateSessionWithInfo(token string) (*storage.SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ValidateSessionWithInfo"); err != nil {
+		return nil, err
+	}
+	sess, ok := s.sessions[token]
+	if !ok || !sess.expiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	u, ok := s.users[sess.userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &storage.SessionInfo{
+		User:         copyUser(u),
+		LastActivity: sess.lastActivity,
+		ExpiresAt:    sess.expiresAt,
+		Duration:     sess.duration,
+		Fingerprint:  sess.fingerprint,
+	}, nil
+}
+
+// RenewSession marks a session as used now and moves its expiry.
+func (s *Store) RenewSession(token string, newExpiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RenewSession"); err != nil {
+		return err
+	}
+	if sess, ok := s.sessions[token]; ok {
+		sess.lastActivity, sess.expiresAt = time.Now(), newExpiresAt
+	}
+	return nil
+}
+
+// DeleteSession ends a session.
+func (s *Store) DeleteSession(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteSession"); err != nil {
+		return err
+	}
+	delete(s.sessions, token)
+	return nil
+}
+
+// Passkeys
+
+// CreatePasskey stores a newly registered passkey.
+func (s *Store) CreatePasskey(p *models.Passkey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreatePasskey"); err != nil {
+		return err
+	}
+	if _, ok := s.passkeys[p.ID]; ok {
+		return errUnique("passkeys.id")
+	}
+	stored := copyPasskey(p)
+	stored.CreatedAt, stored.LastUsedAt = time.Now(), nil
+	s.passkeys[p.ID] = stored
+	return nil
+}
+
+// GetPasskey returns a passkey by credential ID.
+func (s *Store) GetPasskey(id string) (*models.Passkey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetPasskey"); err != nil {
+		return nil, err
+	}
+	p, ok := s.passkeys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyPasskey(p), nil
+}
+
+// ListPasskeys returns a user's passkeys, oldest first.
+func (s *Store) ListPasskeys(userID int64) ([]models.Passkey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListPasskeys"); err != nil {
+		return nil, err
+	}
+	var passkeys []models.Passkey
+	for _, p := range s.passkeys {
+		if p.UserID == userID {
+			passkeys = append(passkeys, *copyPasskey(p))
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool {
+		if !passkeys[i].CreatedAt.Equal(passkeys[j].CreatedAt) {
+			return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt)
+		}
+		return passkeys[i].ID < passkeys[j].ID
+	})
+	return passkeys, nil
+}
+
+// UsePasskey records a login with a passkey and its new signature counter.
+func (s *Store) UsePasskey(id string, signCount uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("UsePasskey"); err != nil {
+		return err
+	}
+	if p, ok := s.passkeys[id]; ok {
+		now := time.Now()
+		p.SignCount, p.LastUsedAt = signCount, &now
+	}
+	return nil
+}
+
+// DeletePasskey removes one of a user's passkeys.
+func (s *Store) DeletePasskey(userID int64, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeletePasskey"); err != nil {
+		return err
+	}
+	if p, ok := s.passkeys[id]; ok && p.UserID == userID {
+		delete(s.passkeys, id)
+	}
+	return nil
+}
+
+// Notifications
+
+// AddNotificationChannel adds a notification channel and sets its ID and
+// creation time.
+func (s *Store) AddNotificationChannel(c *models.NotificationChannel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("AddNotificationChannel"); err != nil {
+		return err
+	}
+	for _, other := range s.channels {
+		if other.UserID == c.UserID && other.Kind == c.Kind && other.Address == c.Address {
+			return storage.ErrChannelExists
+		}
+	}
+	s.nextChannelID++
+	c.ID, c.CreatedAt = s.nextChannelID, time.Now().UTC()
+	stored := *c
+	stored.Events = slices.Clone(c.Events)
+	s.channels[c.ID] = stored
+	return nil
+}
+
+// ListNotificationChannels returns a user's notification channels, the
+// oldest first.
+func (s *Store) ListNotificationChannels(userID int64) ([]models.NotificationChannel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListNotificationChannels"); err != nil {
+		return nil, err
+	}
+	var channels []models.NotificationChannel
+	for _, c := range s.channels {
+		if c.UserID == userID {
+			c.Events = slices.Clone(c.Events)
+			channels = append(channels, c)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+	return channels, nil
+}
+
+// SetNotificationEvents sets the events one of the user's channels notifies
+// of.
+func (s *Store) SetNotificationEvents(userID, channelID int64, events []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SetNotificationEvents"); err != nil {
+		return err
+	}
+	c, ok := s.channels[channelID]
+	if !ok || c.UserID != userID {
+		return sql.ErrNoRows
+	}
+	c.Events = slices.Clone(events)
+	s.channels[channelID] = c
+	return nil
+}
+
+// DeleteNotificationChannel removes one of the user's channels.
+func (s *Store) DeleteNotificationChannel(userID, channelID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteNotificationChannel"); err != nil {
+		return err
+	}
+	if c, ok := s.channels[channelID]; !ok || c.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(s.channels, channelID)
+	return nil
+}
+
+// LogNotification records a delivery and sets its ID and time.
+func (s *Store) LogNotification(d *models.NotificationDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("LogNotification"); err != nil {
+		return err
+	}
+	d.ID, d.CreatedAt = int64(len(s.deliveries)+1), time.Now().UTC()
+	s.deliveries = append(s.deliveries, *d)
+	return nil
+}
+
+// ListNotificationDeliveries returns a user's latest deliveries, the newest
+// first, up to limit.
+func (s *Store) ListNotificationDeliveries(userID int64, limit int) ([]models.NotificationDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListNotificationDeliveries"); err != nil {
+		return nil, err
+	}
+	var deliveries []models.NotificationDelivery
+	for i := len(s.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if s.deliveries[i].UserID == userID {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// Challenges
+
+// CreateChallenge adds an active challenge and sets its ID, status, and
+// times.
+func (s *Store) CreateChallenge(c *models.Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateChallenge"); err != nil {
+		return err
+	}
+	for _, other := range s.challenges {
+		if other.UserID == c.UserID && other.Week == c.Week {
+			return storage.ErrChallengeExists
+		}
+	}
+	s.nextChallenge++
+	c.ID, c.Status = s.nextChallenge, models.ChallengeActive
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+	s.challenges[c.ID] = *c
+	return nil
+}
+
+// ListChallenges returns a user's challenges, the latest week first.
+func (s *Store) ListChallenges(userID int64) ([]models.Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListChallenges"); err != nil {
+		return nil, err
+	}
+	var challenges []models.Challenge
+	for _, c := range s.challenges {
+		if c.UserID == userID {
+			challenges = append(challenges, c)
+		}
+	}
+	sort.Slice(challenges, func(i, j int) bool { return challenges[i].Week > challenges[j].Week })
+	return challenges, nil
+}
+
+// DeleteChallenge gives up one of the user's active challenges.
+func (s *Store) DeleteChallenge(userID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteChallenge"); err != nil {
+		return err
+	}
+	if c, ok := s.challenges[id]; !ok || c.UserID != userID || c.Status != models.ChallengeActive {
+		return sql.ErrNoRows
+	}
+	delete(s.challenges, id)
+	return nil
+}
+
+// GetStreak returns a user's no-spend streak, zero if it was never
+// computed.
+func (s *Store) GetStreak(userID int64) (models.Streak, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetStreak"); err != nil {
+		return models.Streak{}, err
+	}
+	if streak, ok := s.streaks[userID]; ok {
+		return streak, nil
+	}
+	return models.Streak{UserID: userID}, nil
+}
+
+// SaveStreak stores a user's no-spend streak and sets its update time.
+func (s *Store) SaveStreak(streak *models.Streak) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("SaveStreak"); err != nil {
+		return err
+	}
+	streak.UpdatedAt = time.Now().UTC()
+	s.streaks[streak.UserID] = *streak
+	return nil
+}
+
+// Guest tokens
+
+// CreateGuestToken stores a guest token, identified by the hash of the
+// token, and sets its ID and creation time.
+func (s *Store) CreateGuestToken(t *models.GuestToken, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateGuestToken"); err != nil {
+		return err
+	}
+	s.nextGuestID++
+	t.ID, t.CreatedAt = s.nextGuestID, time.Now().UTC()
+	s.guests[t.ID] = &guestToken{GuestToken: *t, tokenHash: tokenHash}
+	return nil
+}
+
+// ListGuestTokens returns a user's guest tokens to ledgers they see, newest
+// first.
+func (s *Store) ListGuestTokens(userID int64) ([]models.GuestToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListGuestTokens"); err != nil {
+		return nil, err
+	}
+	var tokens []models.GuestToken
+	for _, t := range s.guests {
+		if t.UserID == userID && s.ledgers[t.LedgerID].VisibleTo(userID) {
+			tokens = append(tokens, t.GuestToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+// ValidateGuestToken returns a guest token that can still be used by its
+// hash and the user whose expenses it shows, or
+// storage.ErrGuestTokenInvalid.
+func (s *Store) ValidateGuestToken(tokenHash string) (*models.GuestToken, *models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ValidateGuestToken"); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().UTC()
+	for _, t := range s.guests {
+		if t.tokenHash != tokenHash || !t.ExpiresAt.After(now) {
+			continue
+		}
+		u, ok := s.users[t.UserID]
+		ledger, exists := s.ledgers[t.LedgerID]
+		if !ok || u.Disabled || !exists || !ledger.VisibleTo(u.ID) {
+			break
+		}
+		t.LastUsedAt = &now
+		token := t.GuestToken
+		return &token, copyUser(u), nil
+	}
+	return nil, nil, storage.ErrGuestTokenInvalid
+}
+
+// DeleteGuestToken revokes one of the user's guest tokens to a ledger they
+// see.
+func (s *Store) DeleteGuestToken(userID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteGuestToken"); err != nil {
+		return err
+	}
+	if t, ok := s.guests[id]; !ok || t.UserID != userID || !s.ledgers[t.LedgerID].VisibleTo(userID) {
+		return sql.ErrNoRows
+	}
+	delete(s.guests, id)
+	return nil
+}
+
+// Invites
+
+// CreateInvite stores an invite, identified by the hash of its token, that
+// can be redeemed until expiresAt.
+func (s *Store) CreateInvite(tokenHash string, createdBy int64, expiresAt time.Time) (*models.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("CreateInvite"); err != nil {
+		return nil, err
+	}
+	for _, inv := range s.invites {
+		if inv.tokenHash == tokenHash {
+			return nil, errUnique("invites.token_hash")
+		}
+	}
+	s.nextInviteID++
+	inv := &invite{
+		Invite:    models.Invite{ID: s.nextInviteID, CreatedBy: createdBy, CreatedAt: time.Now(), ExpiresAt: expiresAt},
+		tokenHash: tokenHash,
+	}
+	s.invites[inv.ID] = inv
+	return s.copyInvite(inv), nil
+}
+
+// usable returns the invite with a token hash if it can still be redeemed.
+// The caller holds s.mu.
+func (s *Store) usable(tokenHash string) (*invite, bool) {
+	for _, inv := range s.invites {
+		if inv.tokenHash == tokenHash && inv.UsedAt == nil && inv.ExpiresAt.After(time.Now()) {
+			return inv, true
+		}
+	}
+	return nil, false
+}
+
+// GetInvite returns an invite that can still be redeemed by the hash of its
+// token, or storage.ErrInviteInvalid.
+func (s *Store) GetInvite(tokenHash string) (*models.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("GetInvite"); err != nil {
+		return nil, err
+	}
+	inv, ok := s.usable(tokenHash)
+	if !ok {
+		return nil, storage.ErrInviteInvalid
+	}
+	return s.copyInvite(inv), nil
+}
+
+// ListInvites returns all invites, newest first.
+func (s *Store) ListInvites() ([]models.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ListInvites"); err != nil {
+		return nil, err
+	}
+	var invites []models.Invite
+	for _, inv := range s.invites {
+		invites = append(invites, *s.copyInvite(inv))
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		if !invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+			return invites[i].CreatedAt.After(invites[j].CreatedAt)
+		}
+		return invites[i].ID > invites[j].ID
+	})
+	return invites, nil
+}
+
+// RedeemInvite uses up an invite and creates the member account it was
+// meant for, or returns storage.ErrInviteInvalid.
+func (s *Store) RedeemInvite(tokenHash, username, passwordHash string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("RedeemInvite"); err != nil {
+		return nil, err
+	}
+	inv, ok := s.usable(tokenHash)
+	if !ok {
+		return nil, storage.ErrInviteInvalid
+	}
+	u, err := s.createUser(username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	inv.UsedAt, inv.usedBy = &now, u.ID
+	return copyUser(u), nil
+}
+
+// DeleteInvite revokes an invite.
+func (s *Store) DeleteInvite(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("DeleteInvite"); err != nil {
+		return err
+	}
+	delete(s.invites, id)
+	return nil
+}
+
+// Secret returns the named server secret, generating it on first use.
+func (s *Store) Secret(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("Secret"); err != nil {
+		return nil, err
+	}
+	if _, ok := s.secrets[name]; !ok {
+		value := make([]byte, 32)
+		if _, err := rand.Read(value); err != nil {
+			return nil, err
+		}
+		s.secrets[name] = value
+	}
+	return append([]byte(nil), s.secrets[name]...), nil
+}
+
+// Copies, so callers can't change what the store holds
+
+func copyExpense(e *models.Expense) *models.Expense {
+	c := *e
+	if e.UserID != nil {
+		id := *e.UserID
+		c.UserID = &id
+	}
+	return &c
+}
+
+func copyLocation(l models.Location) models.Location {
+	if l.Latitude != nil {
+		lat, lon := *l.Latitude, *l.Longitude
+		l.Latitude, l.Longitude = &lat, &lon
+	}
+	return l
+}
+
+func copyUser(u *user) *models.User {
+	c := u.User
+	if u.DeleteAfter != nil {
+		at := *u.DeleteAfter
+		c.DeleteAfter = &at
+	}
+	return &c
+}
+
+func copyPasskey(p *models.Passkey) *models.Passkey {
+	c := *p
+	c.PublicKey = append([]byte(nil), p.PublicKey...)
+	if p.LastUsedAt != nil {
+		at := *p.LastUsedAt
+		c.LastUsedAt = &at
+	}
+	return &c
+}
+
+// copyInvite also fills in the username of the account created with the
+// invite. The caller holds s.mu.
+func (s *Store) copyInvite(inv *invite) *models.Invite {
+	c := inv.Invite
+	if inv.UsedAt != nil {
+		at := *inv.UsedAt
+		c.UsedAt = &at
+	}
+	if u, ok := s.users[inv.usedBy]; ok {
+		c.UsedBy = u.Username
+	}
+	return &c
+}
@@ -0,0 +1,455 @@
+package memstore
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/storage"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// MemstoreTestSuite provides a test suite for the in-memory store
+type MemstoreTestSuite struct {
+	suite.Suite
+	store *Store
+}
+
+// SetupTest runs before each test
+func (s *MemstoreTestSuite) SetupTest() {
+	s.store = New()
+}
+
+func (s *MemstoreTestSuite) TestExpenses() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(20, "Market", "Groceries", day, 1))
+	s.Require().NoError(s.store.CreateExpense(35, "Cinema", "Entertainment", day.AddDate(0, 0, 1), 1))
+	s.Require().NoError(s.store.CreateExpense(8, "Bus", "Transport", day.AddDate(0, -1, 0), 2))
+
+	err := s.store.CreateExpense(20, "Market", "Groceries", day, 2)
+	s.Require().Error(err, "same date, amount, and description")
+	s.Contains(err.Error(), "UNIQUE constraint failed")
+
+	expenses, err := s.store.FilterExpenses(storage.ExpenseFilter{UserID: 1, Query: "MAR"})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal("Market", expenses[0].Description)
+
+	expenses, err = s.store.FilterExpenses(storage.ExpenseFilter{From: day})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2)
+	s.Equal("Cinema", expenses[0].Description, "newest first")
+
+	page, err := s.store.PageExpenses(storage.ExpenseFilter{}, 2, 1)
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal("Market", page[0].Description)
+	page, err = s.store.PageExpenses(storage.ExpenseFilter{}, 2, 3)
+	s.Require().NoError(err)
+	s.Empty(page)
+
+	page, next, err := s.store.ScrollExpenses(storage.ExpenseFilter{}, storage.ExpenseCursor{}, 2)
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal("Market", page[1].Description)
+	page, next, err = s.store.ScrollExpenses(storage.ExpenseFilter{}, next, 2)
+	s.Require().NoError(err)
+	s.Require().Len(page, 1)
+	s.Equal("Bus", page[0].Description)
+	s.Zero(next)
+
+	var streamed []string
+	err = s.store.StreamExpenses(storage.ExpenseFilter{UserID: 1}, func(e models.Expense) error {
+		streamed = append(streamed, e.Description)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"Market", "Cinema"}, streamed, "oldest first")
+
+	expenses[0].Description = "Changed"
+	stored, err := s.store.GetExpense(expenses[0].ID)
+	s.Require().NoError(err)
+	s.Equal("Cinema", stored.Description, "callers get copies")
+
+	_, err = s.store.GetExpense(99)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *MemstoreTestSuite) TestImportExpenses() {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(4.5, "Coffee", "Eating Out", day, 1))
+	expenses := []models.Expense{
+		{Amount: 4.5, Description: "Coffee", Category: "Eating Out", Date: day},
+		{Amount: 30, Description: "Supermarket", Category: "Groceries", Date: day, LedgerID: 2},
+		{Amount: 30, Description: "Supermarket", Category: "Groceries", Date: day, LedgerID: 2},
+	}
+
+	added, err := s.store.ImportExpenses(expenses, 1, true)
+	s.Require().NoError(err)
+	s.Equal(1, added, "existing expenses and repeats in the file are skipped")
+	all, err := s.store.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Len(all, 1, "a dry run writes nothing")
+
+	added, err = s.store.ImportExpenses(expenses, 1, false)
+	s.Require().NoError(err)
+	s.Equal(1, added)
+	all, err = s.store.FilterExpenses(storage.ExpenseFilter{Ledger: 2})
+	s.Require().NoError(err)
+	s.Require().Len(all, 1)
+	s.Equal("Supermarket", all[0].Description)
+	s.Equal(int64(1), *all[0].UserID)
+}
+
+func (s *MemstoreTestSuite) TestSearchExpenses() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(10, "Pharmacy receipt", "Health", day.AddDate(-1, 0, 0), 1))
+	s.Require().NoError(s.store.CreateExpense(20, "Vitamins", "Health", day, 1))
+	s.Require().NoError(s.store.InsertExpense(&models.Expense{Amount: 30, Description: "Pharmacy", Category: "Health", Date: day, LedgerID: 2}))
+
+	expenses, err := s.store.SearchExpenses("health PHARMACY", models.PersonalLedgerID, 10)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal("Pharmacy receipt", expenses[0].Description)
+
+	expenses, err = s.store.SearchExpenses("health", 0, 2)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 2)
+	s.Equal("Vitamins", expenses[1].Description, "newest first")
+}
+
+func (s *MemstoreTestSuite) TestMonthStats() {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(20, "Market", "Groceries", day, 1))
+	s.Require().NoError(s.store.CreateExpense(5, "Bakery", "Groceries", day, 1))
+	s.Require().NoError(s.store.CreateExpense(35, "Cinema", "Entertainment", day.AddDate(0, 0, 1), 1))
+	s.Require().NoError(s.store.CreateExpense(8, "Bus", "Transport", day.AddDate(0, -1, 0), 2))
+
+	totals, err := s.store.CategoryTotals(storage.ExpenseFilter{UserID: 1})
+	s.Require().NoError(err)
+	s.Equal([]storage.CategoryTotal{
+		{Category: "Entertainment", Total: 35, Count: 1},
+		{Category: "Groceries", Total: 25, Count: 2},
+	}, totals)
+
+	stats, err := s.store.GetMonthStats(0, 2026, 3)
+	s.Require().NoError(err)
+	s.InDelta(60, stats.Total, 0.001)
+	s.InDelta(8, stats.PrevTotal, 0.001)
+	s.Equal([]storage.CategoryTotal{{Category: "Entertainment", Total: 35, Count: 1}, {Category: "Groceries", Total: 25, Count: 2}}, stats.Categories)
+	s.Equal([]storage.DailyTotal{{Day: 10, Total: 25}, {Day: 11, Total: 35}}, stats.Daily)
+
+	total, err := s.store.GetTotalForPeriod(0, 2026, 0)
+	s.Require().NoError(err)
+	s.InDelta(68, total, 0.001)
+	months, err := s.store.GetMonthlyTotalsForYear(0, 2026)
+	s.Require().NoError(err)
+	s.Equal([]storage.MonthlyTotal{{Month: 2, Total: 8}, {Month: 3, Total: 60}}, months)
+}
+
+func (s *MemstoreTestSuite) TestUsersAndSessions() {
+	admin, err := s.store.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Equal(models.RoleAdmin, admin.Role, "the first user is an admin")
+	member, err := s.store.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Equal(models.RoleMember, member.Role)
+	_, err = s.store.CreateUser("bob", "hash")
+	s.Error(err, "usernames are unique")
+
+	s.Require().NoError(s.store.CreateSession("token", member.ID, time.Hour, ""))
+	s.Require().NoError(s.store.CreateSession("expired", member.ID, -time.Hour, ""))
+	user, err := s.store.ValidateSession("token")
+	s.Require().NoError(err)
+	s.Equal("bob", user.Username)
+	_, err = s.store.ValidateSession("expired")
+	s.ErrorIs(err, sql.ErrNoRows)
+
+	s.Require().NoError(s.store.ScheduleAccountDeletion(member.ID, time.Now().Add(time.Hour)))
+	_, err = s.store.ValidateSession("token")
+	s.ErrorIs(err, sql.ErrNoRows, "scheduling deletion signs the user out")
+	cancelled, err := s.store.CancelAccountDeletion(member.ID)
+	s.Require().NoError(err)
+	s.True(cancelled)
+
+	s.Require().NoError(s.store.SetEmail(admin.ID, "alice@example.com"))
+	s.Require().NoError(s.store.VerifyEmail(admin.ID, "alice@example.com"))
+	s.ErrorIs(s.store.SetEmail(member.ID, "ALICE@example.com"), storage.ErrEmailTaken)
+
+	s.ErrorIs(s.store.RenameUser(member.ID, "Alice"), storage.ErrUsernameTaken)
+	s.Require().NoError(s.store.CreateSession("renamed", member.ID, time.Hour, ""))
+	s.Require().NoError(s.store.RenameUser(member.ID, "bobby"))
+	user, err = s.store.ValidateSession("renamed")
+	s.Require().NoError(err)
+	s.Equal("bobby", user.Username)
+}
+
+func (s *MemstoreTestSuite) TestInvites() {
+	admin, err := s.store.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	_, err = s.store.CreateInvite("hash", admin.ID, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+
+	user, err := s.store.RedeemInvite("hash", "bob", "hash")
+	s.Require().NoError(err)
+	s.Equal(models.RoleMember, user.Role)
+	_, err = s.store.RedeemInvite("hash", "carol", "hash")
+	s.ErrorIs(err, storage.ErrInviteInvalid, "invites are single-use")
+
+	invites, err := s.store.ListInvites()
+	s.Require().NoError(err)
+	s.Require().Len(invites, 1)
+	s.Equal("bob", invites[0].UsedBy)
+}
+
+func (s *MemstoreTestSuite) TestChanges() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(1, "Coffee", "Eating Out", day, 1))
+	s.Require().NoError(s.store.CreateExpense(2, "Bus", "Transport", day, 1))
+	changes, err := s.store.ExpenseChanges(0, 10)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 2)
+	coffee := *changes[0].Expense
+
+	coffee.Amount = 3
+	s.Require().NoError(s.store.UpdateExpenseIfUnchanged(&coffee, changes[0].Version))
+	s.ErrorIs(s.store.DeleteExpenseIfUnchanged(coffee.ID, changes[0].Version), storage.ErrConflict)
+	s.Require().NoError(s.store.DeleteExpenseIfUnchanged(changes[1].ID, changes[1].Version))
+	s.ErrorIs(s.store.DeleteExpenseIfUnchanged(404, changes[1].Version), sql.ErrNoRows)
+
+	changes, err = s.store.ExpenseChanges(changes[1].Version, 10)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 2)
+	s.Equal(3.0, changes[0].Expense.Amount)
+	s.Nil(changes[1].Expense, "a tombstone")
+}
+
+func (s *MemstoreTestSuite) TestRestore() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.CreateExpense(1, "Coffee", "Eating Out", day, 1))
+	expenses, err := s.store.FilterExpenses(storage.ExpenseFilter{})
+	s.Require().NoError(err)
+	id := expenses[0].ID
+	s.Require().NoError(s.store.DeleteExpense(id))
+
+	s.Require().NoError(s.store.RestoreExpense(id))
+	restored, err := s.store.GetExpense(id)
+	s.Require().NoError(err)
+	s.Equal("Coffee", restored.Description)
+	s.ErrorIs(s.store.RestoreExpense(id), sql.ErrNoRows, "restored already")
+
+	s.Require().NoError(s.store.DeleteExpense(id))
+	s.Require().NoError(s.store.CreateExpense(1, "Coffee", "Eating Out", day, 1))
+	s.ErrorContains(s.store.RestoreExpense(id), "UNIQUE constraint failed")
+}
+
+func (s *MemstoreTestSuite) TestPreferences() {
+	prefs, err := s.store.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(models.DefaultPreferences(), prefs)
+
+	prefs.Currency = "USD"
+	s.Require().NoError(s.store.SavePreferences(1, prefs))
+	saved, err := s.store.GetPreferences(1)
+	s.Require().NoError(err)
+	s.Equal(prefs, saved)
+}
+
+func (s *MemstoreTestSuite) TestCategoryUsage() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, category := range []string{"Transport", "Groceries", "Transport", "Health"} {
+		s.Require().NoError(s.store.CreateExpense(1, "", category, day.Add(time.Duration(i)*time.Hour), 1))
+	}
+	s.Require().NoError(s.store.CreateExpense(2, "", "Travel", day, 2))
+	names, err := s.store.CategoryUsage(1, day.AddDate(0, -3, 0))
+	s.Require().NoError(err)
+	s.Equal([]string{"Transport", "Health", "Groceries"}, names)
+}
+
+func (s *MemstoreTestSuite) TestLocations() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	lat, lon := 52.37, 4.89
+	for i, loc := range []models.Location{{Place: "Café"}, {Place: "café"}, {Latitude: &lat, Longitude: &lon}} {
+		e := &models.Expense{Amount: float64(i + 2), Description: "Coffee", Category: "Eating Out", Date: day}
+		s.Require().NoError(s.store.InsertExpense(e))
+		s.Require().NoError(s.store.SetExpenseLocation(e.ID, loc))
+	}
+	loc, err := s.store.GetExpenseLocation(3)
+	s.Require().NoError(err)
+	s.Equal(52.37, *loc.Latitude)
+
+	totals, err := s.store.SpendingByPlace(storage.ExpenseFilter{}, 10)
+	s.Require().NoError(err)
+	s.Require().Len(totals, 2)
+	s.Equal(storage.PlaceTotal{Location: models.Location{Place: "Café"}, Total: 5, Count: 2}, totals[0])
+	s.Equal(4.0, totals[1].Total)
+
+	s.Require().NoError(s.store.SetExpenseLocation(3, models.Location{}))
+	loc, err = s.store.GetExpenseLocation(3)
+	s.Require().NoError(err)
+	s.True(loc.IsZero())
+}
+
+func (s *MemstoreTestSuite) TestReimbursements() {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	userID := int64(1)
+	for i, status := range []string{models.ReimbursementSubmitted, models.ReimbursementPending, models.ReimbursementPending, ""} {
+		e := &models.Expense{Amount: float64(i + 1), Description: "Train", Category: "Transport", Date: day.AddDate(0, 0, -i), UserID: &userID}
+		s.Require().NoError(s.store.InsertExpense(e))
+		s.Require().NoError(s.store.SetReimbursement(e.ID, status))
+	}
+	s.Error(s.store.SetReimbursement(1, "lost"))
+
+	outstanding, err := s.store.OutstandingReimbursements(1)
+	s.Require().NoError(err)
+	s.Require().Len(outstanding, 3)
+	s.Equal([]int64{3, 2, 1}, []int64{outstanding[0].Expense.ID, outstanding[1].Expense.ID, outstanding[2].Expense.ID})
+
+	n, err := s.store.SetReimbursements(1, []int64{1, 2, 4}, models.ReimbursementReimbursed)
+	s.Require().NoError(err)
+	s.Equal(int64(2), n)
+	status, err := s.store.GetReimbursement(2)
+	s.Require().NoError(err)
+	s.Equal(models.ReimbursementReimbursed, status)
+	n, err = s.store.SetReimbursements(2, []int64{3}, models.ReimbursementReimbursed)
+	s.Require().NoError(err)
+	s.Zero(n, "someone else's")
+}
+
+func (s *MemstoreTestSuite) TestProjects() {
+	japan, err := s.store.CreateProject("Japan")
+	s.Require().NoError(err)
+	_, err = s.store.CreateProject("japan")
+	s.ErrorIs(err, storage.ErrProjectExists)
+
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for i, category := range []string{"Travel", "Eating Out", "Eating Out"} {
+		e := &models.Expense{Amount: float64(10 * (i + 1)), Description: "Trip", Category: category, Date: day.AddDate(0, 0, i)}
+		s.Require().NoError(s.store.InsertExpense(e))
+		s.Require().NoError(s.store.SetExpenseProject(e.ID, japan.ID))
+	}
+	s.ErrorIs(s.store.SetExpenseProject(1, 99), sql.ErrNoRows)
+
+	summary, err := s.store.SummarizeProject(japan.ID, 1)
+	s.Require().NoError(err)
+	s.Equal(60.0, summary.Total)
+	s.Equal(day, summary.First)
+	s.Equal(day.AddDate(0, 0, 2), summary.Last)
+	s.Equal("Eating Out", summary.Categories[0].Category)
+
+	s.Require().NoError(s.store.DeleteProject(japan.ID))
+	projectID, err := s.store.GetExpenseProject(1)
+	s.Require().NoError(err)
+	s.Zero(projectID)
+}
+
+func (s *MemstoreTestSuite) TestLedgers() {
+	business, err := s.store.CreateLedger("Business", "", 1)
+	s.Require().NoError(err)
+	_, err = s.store.CreateLedger("business", "", 1)
+	s.ErrorIs(err, storage.ErrLedgerExists)
+	ledgers, err := s.store.ListLedgers(1)
+	s.Require().NoError(err)
+	s.Require().Len(ledgers, 2)
+	s.Equal("Personal", ledgers[0].Name)
+	ledgers, err = s.store.ListLedgers(2)
+	s.Require().NoError(err)
+	s.Len(ledgers, 1, "another user's ledger")
+
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	personal := &models.Expense{Amount: 10, Description: "Lunch", Category: "Eating Out", Date: day}
+	s.Require().NoError(s.store.InsertExpense(personal))
+	s.Equal(int64(models.PersonalLedgerID), personal.LedgerID)
+	work := &models.Expense{Amount: 25, Description: "Client lunch", Category: "Eating Out", Date: day, LedgerID: business.ID}
+	s.Require().NoError(s.store.InsertExpense(work))
+
+	expenses, err := s.store.FilterExpenses(storage.ExpenseFilter{Ledger: business.ID})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(work.ID, expenses[0].ID)
+	total, err := s.store.GetTotalForPeriod(business.ID, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(25.0, total)
+	total, err = s.store.GetTotalForPeriod(0, 2026, 3)
+	s.Require().NoError(err)
+	s.Equal(35.0, total, "all ledgers")
+	expenses, err = s.store.FilterExpenses(storage.ExpenseFilter{Viewer: 2})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(personal.ID, expenses[0].ID)
+
+	s.Require().NoError(s.store.SetLedgerCurrency(business.ID, "CHF"))
+	s.Require().NoError(s.store.ArchiveLedger(business.ID, true))
+	s.ErrorIs(s.store.ArchiveLedger(models.PersonalLedgerID, true), storage.ErrLedgerInUse)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.store.LedgerTotals(1, start, start.AddDate(0, 1, 0))
+	s.Require().NoError(err)
+	s.Require().Len(totals, 2)
+	s.Equal(10.0, totals[0].Total)
+	s.Equal(25.0, totals[1].Total)
+	s.Equal("CHF", totals[1].Ledger.Currency)
+	s.NotNil(totals[1].Ledger.ArchivedAt)
+
+	s.ErrorIs(s.store.DeleteLedger(business.ID), storage.ErrLedgerInUse)
+	s.ErrorIs(s.store.DeleteLedger(models.PersonalLedgerID), storage.ErrLedgerInUse)
+	s.Require().NoError(s.store.DeleteExpense(work.ID))
+	s.Require().NoError(s.store.DeleteLedger(business.ID))
+	_, err = s.store.GetLedger(business.ID)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *MemstoreTestSuite) TestNotifications() {
+	ntfy := &models.NotificationChannel{UserID: 1, Kind: models.ChannelNtfy, Address: "https://ntfy.sh/alice"}
+	s.Require().NoError(s.store.AddNotificationChannel(ntfy))
+	s.ErrorIs(s.store.AddNotificationChannel(&models.NotificationChannel{UserID: 1, Kind: models.ChannelNtfy, Address: "https://ntfy.sh/alice"}), storage.ErrChannelExists)
+	s.Require().NoError(s.store.SetNotificationEvents(1, ntfy.ID, []string{models.EventWeeklyDigest}))
+	s.ErrorIs(s.store.SetNotificationEvents(2, ntfy.ID, nil), sql.ErrNoRows)
+	channels, err := s.store.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Require().Len(channels, 1)
+	s.True(channels[0].Notifies(models.EventWeeklyDigest))
+
+	s.Require().NoError(s.store.LogNotification(&models.NotificationDelivery{UserID: 1, ChannelID: ntfy.ID, Title: "First"}))
+	s.Require().NoError(s.store.LogNotification(&models.NotificationDelivery{UserID: 1, ChannelID: ntfy.ID, Title: "Second"}))
+	deliveries, err := s.store.ListNotificationDeliveries(1, 1)
+	s.Require().NoError(err)
+	s.Require().Len(deliveries, 1)
+	s.Equal("Second", deliveries[0].Title)
+
+	s.ErrorIs(s.store.DeleteNotificationChannel(2, ntfy.ID), sql.ErrNoRows)
+	s.Require().NoError(s.store.DeleteNotificationChannel(1, ntfy.ID))
+}
+
+func (s *MemstoreTestSuite) TestAvatar() {
+	_, err := s.store.GetAvatar(1)
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.Require().NoError(s.store.SetAvatar(1, []byte("image"), "image/jpeg"))
+	avatar, err := s.store.GetAvatar(1)
+	s.Require().NoError(err)
+	s.Equal([]byte("image"), avatar.Image)
+	s.Require().NoError(s.store.DeleteAvatar(1))
+	_, err = s.store.GetAvatar(1)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *MemstoreTestSuite) TestFail() {
+	s.store.Fail(ErrDown)
+	_, err := s.store.CreateUser("alice", "hash")
+	s.ErrorIs(err, ErrDown)
+
+	s.store.Fail(nil)
+	s.store.Fail(ErrDown, "CreateExpense")
+	s.ErrorIs(s.store.CreateExpense(1, "Coffee", "Eating Out", time.Now(), 1), ErrDown)
+	_, err = s.store.CreateUser("alice", "hash")
+	s.NoError(err, "only the named methods fail")
+
+	s.store.Fail(nil, "CreateExpense")
+	s.NoError(s.store.CreateExpense(1, "Coffee", "Eating Out", time.Now(), 1))
+}
+
+// TestMemstoreSuite runs the in-memory store test suite
+func TestMemstoreSuite(t *testing.T) {
+	suite.Run(t, new(MemstoreTestSuite))
+}
@@ -0,0 +1,813 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrIrreversible is returned by MigrateDown for a migration that can't be
+// undone.
+var ErrIrreversible = errors.New("migration can't be undone")
+
+// migration is a numbered change to the schema. Down is nil when the change
+// can't be undone.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+	down        func(tx *sql.Tx) error
+}
+
+// migrations are applied in order and recorded in schema_migrations. Add new
+// ones at the end with the next version, and never change one that shipped.
+var migrations = []migration{
+	{version: 1, description: "baseline schema"},
+	{
+		version:     2,
+		description: "categories table",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE categories (
+				name TEXT PRIMARY KEY COLLATE NOCASE,
+				position INTEGER NOT NULL
+			)`); err != nil {
+				return err
+			}
+			// The built-in categories, then any others expenses already use
+			for i, name := range models.Categories {
+				if _, err := tx.Exec("INSERT INTO categories (name, position) VALUES (?, ?)", name, i); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(
+				`INSERT OR IGNORE INTO categories (name, position)
+				SELECT category, ? + ROW_NUMBER() OVER (ORDER BY category) FROM (SELECT DISTINCT category FROM all_expenses)`,
+				len(models.Categories),
+			)
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE categories")
+			return err
+		},
+	},
+	{
+		version:     3,
+		description: "recurring expenses",
+		up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE recurring_expenses (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				amount REAL NOT NULL,
+				description TEXT NOT NULL,
+				category TEXT NOT NULL,
+				frequency TEXT NOT NULL,
+				start_date DATETIME NOT NULL,
+				occurrences INTEGER NOT NULL DEFAULT 0,
+				next_date DATETIME NOT NULL,
+				paused INTEGER NOT NULL DEFAULT 0
+			)`)
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE recurring_expenses")
+			return err
+		},
+	},
+	{
+		version:     4,
+		description: "index expenses by user and date",
+		up: func(tx *sql.Tx) error {
+			// Exports and reassignments filter by user, which otherwise
+			// scans every expense and sorts the user's by date
+			_, err := tx.Exec("CREATE INDEX expenses_user_id_date_index ON expenses (user_id, date)")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP INDEX expenses_user_id_date_index")
+			return err
+		},
+	},
+	{
+		version:     5,
+		description: "data version for cached pages",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE data_version (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				version INTEGER NOT NULL,
+				changed_at DATETIME NOT NULL
+			)`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO data_version (id, version, changed_at) VALUES (1, 0, ?)", time.Now().UTC()); err != nil {
+				return err
+			}
+			// Triggers catch every write, including those of expensectl
+			// and of hand edits, so no page is served stale
+			for _, table := range versionedTables {
+				if err := createVersionTriggers(tx, table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *sql.Tx) error {
+			for _, table := range versionedTables {
+				if err := dropVersionTriggers(tx, table); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("DROP TABLE data_version")
+			return err
+		},
+	},
+	{
+		version:     6,
+		description: "expense change feed for syncing clients",
+		up: func(tx *sql.Tx) error {
+			// One row per expense, moved to the end of the feed by each change
+			// to it; deleted expenses stay as tombstones
+			if _, err := tx.Exec(`CREATE TABLE expense_changes (
+				version INTEGER PRIMARY KEY AUTOINCREMENT,
+				expense_id INTEGER NOT NULL UNIQUE,
+				deleted INTEGER NOT NULL DEFAULT 0
+			)`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO expense_changes (expense_id) SELECT id FROM all_expenses ORDER BY id"); err != nil {
+				return err
+			}
+			// Like the data version, triggers catch writes from anywhere.
+			// Archiving moves an expense without changing it.
+			for _, trigger := range []struct{ name, event, when, row, deleted string }{
+				{"expenses_insert_change", "INSERT ON expenses", "", "NEW", "0"},
+				{"expenses_update_change", "UPDATE ON expenses", "", "NEW", "0"},
+				{"expenses_delete_change", "DELETE ON expenses", "WHEN NOT EXISTS (SELECT 1 FROM archived_expenses WHERE id = OLD.id)", "OLD", "1"},
+				{"archived_expenses_update_change", "UPDATE ON archived_expenses", "", "NEW", "0"},
+				{"archived_expenses_delete_change", "DELETE ON archived_expenses", "", "OLD", "1"},
+			} {
+				if _, err := tx.Exec(fmt.Sprintf(
+					`CREATE TRIGGER %s AFTER %s %s
+					BEGIN
+						INSERT OR REPLACE INTO expense_changes (expense_id, deleted) VALUES (%s.id, %s);
+					END`, trigger.name, trigger.event, trigger.when, trigger.row, trigger.deleted,
+				)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *sql.Tx) error {
+			for _, name := range []string{
+				"expenses_insert_change", "expenses_update_change", "expenses_delete_change",
+				"archived_expenses_update_change", "archived_expenses_delete_change",
+			} {
+				if _, err := tx.Exec("DROP TRIGGER " + name); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("DROP TABLE expense_changes")
+			return err
+		},
+	},
+	{
+		version:     7,
+		description: "recently deleted expenses for undo",
+		up: func(tx *sql.Tx) error {
+			// Deleted expenses stay restorable here until PurgeDeletedExpenses
+			if _, err := tx.Exec(`CREATE TABLE deleted_expenses (
+				id INTEGER PRIMARY KEY,
+				amount REAL NOT NULL,
+				description TEXT NOT NULL,
+				category TEXT NOT NULL,
+				date DATETIME NOT NULL,
+				user_id INTEGER REFERENCES users(id),
+				deleted_at DATETIME NOT NULL
+			)`); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX deleted_expenses_deleted_at_index ON deleted_expenses (deleted_at)")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE deleted_expenses")
+			return err
+		},
+	},
+	{
+		version:     8,
+		description: "user preferences",
+		up: func(tx *sql.Tx) error {
+			// Users without a row get models.DefaultPreferences
+			_, err := tx.Exec(`CREATE TABLE user_settings (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				currency TEXT NOT NULL,
+				locale TEXT NOT NULL,
+				timezone TEXT NOT NULL DEFAULT '',
+				theme TEXT NOT NULL,
+				cycle_start INTEGER NOT NULL DEFAULT 1,
+				default_category TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME NOT NULL
+			)`)
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE user_settings")
+			return err
+		},
+	},
+	{
+		version:     9,
+		description: "user avatars",
+		up: func(tx *sql.Tx) error {
+			// Resized when uploaded, so they're small enough to keep here
+			_, err := tx.Exec(`CREATE TABLE avatars (
+				user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+				image BLOB NOT NULL,
+				content_type TEXT NOT NULL,
+				updated_at DATETIME NOT NULL
+			)`)
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE avatars")
+			return err
+		},
+	},
+	{
+		version:     10,
+		description: "expense locations",
+		up: func(tx *sql.Tx) error {
+			// Keyed by the expense ID without a foreign key, so a location
+			// follows its expense when it's archived, deleted, or restored
+			if _, err := tx.Exec(`CREATE TABLE expense_locations (
+				expense_id INTEGER PRIMARY KEY,
+				latitude REAL,
+				longitude REAL,
+				place TEXT NOT NULL DEFAULT '',
+				CHECK ((latitude IS NULL) = (longitude IS NULL))
+			)`); err != nil {
+				return err
+			}
+			// The spending by place report is cached like the statistics
+			return createVersionTriggers(tx, "expense_locations")
+		},
+		down: func(tx *sql.Tx) error {
+			if err := dropVersionTriggers(tx, "expense_locations"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("DROP TABLE expense_locations")
+			return err
+		},
+	},
+	{
+		version:     11,
+		description: "reimbursable expenses",
+		up: func(tx *sql.Tx) error {
+			// Like expense_locations, kept by expense ID
+			if _, err := tx.Exec(`CREATE TABLE expense_reimbursements (
+				expense_id INTEGER PRIMARY KEY,
+				status TEXT NOT NULL CHECK (status IN ('pending', 'submitted', 'reimbursed')),
+				updated_at DATETIME NOT NULL
+			)`); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX expense_reimbursements_status_index ON expense_reimbursements (status)")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE expense_reimbursements")
+			return err
+		},
+	},
+	{
+		version:     12,
+		description: "projects",
+		up: func(tx *sql.Tx) error {
+			// Shared by the household like the categories
+			if _, err := tx.Exec(`CREATE TABLE projects (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+				created_at DATETIME NOT NULL
+			)`); err != nil {
+				return err
+			}
+			// Like expense_locations, kept by expense ID
+			if _, err := tx.Exec(`CREATE TABLE expense_projects (
+				expense_id INTEGER PRIMARY KEY,
+				project_id INTEGER NOT NULL REFERENCES projects(id)
+			)`); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX expense_projects_project_index ON expense_projects (project_id)")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("DROP TABLE expense_projects"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("DROP TABLE projects")
+			return err
+		},
+	},
+	{
+		version:     13,
+		description: "ledgers",
+		up: func(tx *sql.Tx) error {
+			// The personal ledger holds every expense from before
+			// ledgers. Migration 19 gives the others owners.
+			if _, err := tx.Exec(`CREATE TABLE ledgers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+				created_at DATETIME NOT NULL
+			)`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO ledgers (id, name, created_at) VALUES (?, 'Personal', ?)", models.PersonalLedgerID, time.Now().UTC()); err != nil {
+				return err
+			}
+			// The ledger each user's pages show
+			for _, table := range []string{"expenses", "archived_expenses", "deleted_expenses", "user_settings"} {
+				if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN ledger_id INTEGER NOT NULL DEFAULT %d", table, models.PersonalLedgerID)); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec("CREATE INDEX expenses_ledger_id_date_index ON expenses (ledger_id, date)"); err != nil {
+				return err
+			}
+			if err := createAllExpensesView(tx, "id, amount, description, category, date, user_id, ledger_id"); err != nil {
+				return err
+			}
+			// Statistics are kept per ledger
+			return rebuildDailyAggregates(tx,
+				`CREATE TABLE daily_aggregates (
+					ledger_id INTEGER NOT NULL,
+					day TEXT NOT NULL,
+					category TEXT NOT NULL,
+					total REAL NOT NULL,
+					count INTEGER NOT NULL,
+					PRIMARY KEY (ledger_id, day, category)
+				)`,
+				fmt.Sprintf("SELECT %d, day, category, total, count FROM old_daily_aggregates", models.PersonalLedgerID),
+			)
+		},
+		down: func(tx *sql.Tx) error {
+			if err := rebuildDailyAggregates(tx,
+				`CREATE TABLE daily_aggregates (
+					day TEXT NOT NULL,
+					category TEXT NOT NULL,
+					total REAL NOT NULL,
+					count INTEGER NOT NULL,
+					PRIMARY KEY (day, category)
+				)`,
+				"SELECT day, category, SUM(total), SUM(count) FROM old_daily_aggregates GROUP BY day, category",
+			); err != nil {
+				return err
+			}
+			if err := createAllExpensesView(tx, "id, amount, description, category, date, user_id"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DROP INDEX expenses_ledger_id_date_index"); err != nil {
+				return err
+			}
+			for _, table := range []string{"expenses", "archived_expenses", "deleted_expenses", "user_settings"} {
+				if _, err := tx.Exec("ALTER TABLE " + table + " DROP COLUMN ledger_id"); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("DROP TABLE ledgers")
+			return err
+		},
+	},
+	{
+		version:     14,
+		description: "ledger currencies and archiving",
+		up: func(tx *sql.Tx) error {
+			// An empty currency shows the ledger in each user's own
+			for _, column := range []string{"currency TEXT NOT NULL DEFAULT ''", "archived_at DATETIME"} {
+				if _, err := tx.Exec("ALTER TABLE ledgers ADD COLUMN " + column); err != nil {
+					return err
+				}
+			}
+			// The list's ledger switcher and formats depend on them
+			return createVersionTriggers(tx, "ledgers")
+		},
+		down: func(tx *sql.Tx) error {
+			if err := dropVersionTriggers(tx, "ledgers"); err != nil {
+				return err
+			}
+			for _, column := range []string{"currency", "archived_at"} {
+				if _, err := tx.Exec("ALTER TABLE ledgers DROP COLUMN " + column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     15,
+		description: "notifications",
+		up: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				// Events are a comma-separated list
+				`CREATE TABLE notification_channels (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id INTEGER NOT NULL,
+					kind TEXT NOT NULL,
+					address TEXT NOT NULL,
+					p256dh TEXT NOT NULL DEFAULT '',
+					auth TEXT NOT NULL DEFAULT '',
+					events TEXT NOT NULL DEFAULT '',
+					created_at DATETIME NOT NULL,
+					UNIQUE (user_id, kind, address)
+				)`,
+				`CREATE TABLE notification_deliveries (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id INTEGER NOT NULL,
+					channel_id INTEGER NOT NULL,
+					kind TEXT NOT NULL,
+					event TEXT NOT NULL,
+					dedupe_key TEXT NOT NULL DEFAULT '',
+					title TEXT NOT NULL,
+					error TEXT NOT NULL DEFAULT '',
+					created_at DATETIME NOT NULL
+				)`,
+				"CREATE INDEX notification_deliveries_channel_id_dedupe_key_index ON notification_deliveries (channel_id, dedupe_key)",
+				"CREATE INDEX notification_deliveries_user_id_created_at_index ON notification_deliveries (user_id, created_at)",
+				"ALTER TABLE user_settings ADD COLUMN budget REAL NOT NULL DEFAULT 0",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				"ALTER TABLE user_settings DROP COLUMN budget",
+				"DROP TABLE notification_deliveries",
+				"DROP TABLE notification_channels",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     16,
+		description: "weekly digest opt-in",
+		up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE user_settings ADD COLUMN weekly_digest INTEGER NOT NULL DEFAULT 0")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE user_settings DROP COLUMN weekly_digest")
+			return err
+		},
+	},
+	{
+		version:     17,
+		description: "challenges and no-spend streaks",
+		up: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				`CREATE TABLE challenges (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id INTEGER NOT NULL,
+					ledger_id INTEGER NOT NULL,
+					week TEXT NOT NULL,
+					spending_limit REAL NOT NULL,
+					spent REAL NOT NULL DEFAULT 0,
+					status TEXT NOT NULL DEFAULT 'active',
+					created_at DATETIME NOT NULL,
+					updated_at DATETIME NOT NULL,
+					UNIQUE (user_id, week)
+				)`,
+				"CREATE INDEX challenges_status_index ON challenges (status)",
+				`CREATE TABLE spending_streaks (
+					user_id INTEGER PRIMARY KEY,
+					current INTEGER NOT NULL,
+					longest INTEGER NOT NULL,
+					updated_at DATETIME NOT NULL
+				)`,
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				"DROP TABLE spending_streaks",
+				"DROP TABLE challenges",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     18,
+		description: "read-only guest tokens",
+		up: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				`CREATE TABLE guest_tokens (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					token_hash TEXT NOT NULL UNIQUE,
+					user_id INTEGER NOT NULL,
+					ledger_id INTEGER NOT NULL,
+					name TEXT NOT NULL,
+					created_at DATETIME NOT NULL,
+					expires_at DATETIME NOT NULL,
+					last_used_at DATETIME
+				)`,
+				"CREATE INDEX guest_tokens_user_index ON guest_tokens (user_id)",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE guest_tokens")
+			return err
+		},
+	},
+	{
+		version:     19,
+		description: "ledger owners",
+		up: func(tx *sql.Tx) error {
+			// A ledger without an owner is shared by the household, like
+			// the personal one. Who added the ledgers from before owners
+			// wasn't recorded, so they stay shared. Names are unique per
+			// owner.
+			return rebuildLedgers(tx,
+				`CREATE TABLE ledgers (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					owner_id INTEGER,
+					name TEXT NOT NULL COLLATE NOCASE,
+					currency TEXT NOT NULL DEFAULT '',
+					created_at DATETIME NOT NULL,
+					archived_at DATETIME
+				)`,
+				"CREATE UNIQUE INDEX ledgers_owner_id_name_index ON ledgers (COALESCE(owner_id, 0), name)",
+			)
+		},
+		down: func(tx *sql.Tx) error {
+			return rebuildLedgers(tx,
+				`CREATE TABLE ledgers (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+					created_at DATETIME NOT NULL,
+					currency TEXT NOT NULL DEFAULT '',
+					archived_at DATETIME
+				)`,
+			)
+		},
+	},
+}
+
+// versionedTables are the tables whose changes bump the data version. Tables
+// added later create their triggers in their own migration.
+var versionedTables = []string{"expenses", "archived_expenses", "categories"}
+
+// createVersionTriggers makes every write to table bump the data version.
+func createVersionTriggers(tx *sql.Tx, table string) error {
+	for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+		if _, err := tx.Exec(fmt.Sprintf(
+			`CREATE TRIGGER %[1]s_%[2]s_version AFTER %[2]s ON %[1]s
+			BEGIN
+				UPDATE data_version SET version = version + 1, changed_at = strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now') WHERE id = 1;
+			END`, table, strings.ToLower(event),
+		)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropVersionTriggers drops the triggers of createVersionTriggers.
+func dropVersionTriggers(tx *sql.Tx, table string) error {
+	for _, event := range []string{"insert", "update", "delete"} {
+		if _, err := tx.Exec(fmt.Sprintf("DROP TRIGGER %s_%s_version", table, event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createAllExpensesView replaces the all_expenses view with one of the given
+// columns of the current and archived expenses.
+func createAllExpensesView(tx *sql.Tx, columns string) error {
+	if _, err := tx.Exec("DROP VIEW all_expenses"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(fmt.Sprintf(
+		`CREATE VIEW all_expenses AS
+		SELECT %[1]s FROM expenses
+		UNION ALL
+		SELECT %[1]s FROM archived_expenses`, columns,
+	))
+	return err
+}
+
+// rebuildDailyAggregates replaces the daily_aggregates table with the one
+// create makes, filled by a SELECT from the old one, renamed
+// old_daily_aggregates.
+func rebuildDailyAggregates(tx *sql.Tx, create, fill string) error {
+	if _, err := tx.Exec("ALTER TABLE daily_aggregates RENAME TO old_daily_aggregates"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(create); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO daily_aggregates " + fill); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DROP TABLE old_daily_aggregates")
+	return err
+}
+
+// rebuildLedgers replaces the ledgers table with the one create makes, and
+// then runs the other statements, e.g. to add indexes. The ledgers keep
+// their IDs, names, currencies, and dates.
+func rebuildLedgers(tx *sql.Tx, create string, stmts ...string) error {
+	if err := dropVersionTriggers(tx, "ledgers"); err != nil {
+		return err
+	}
+	for _, stmt := range append([]string{
+		"ALTER TABLE ledgers RENAME TO old_ledgers",
+		create,
+		`INSERT INTO ledgers (id, name, currency, created_at, archived_at)
+		SELECT id, name, currency, created_at, archived_at FROM old_ledgers`,
+		"DROP TABLE old_ledgers",
+	}, stmts...) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return createVersionTriggers(tx, "ledgers")
+}
+
+// MigrationStatus is a migration and whether it was applied.
+type MigrationStatus struct {
+	Version     int
+	Description string     // Empty for a migration unknown to this version of the app
+	AppliedAt   *time.Time // Nil while pending
+	Reversible  bool
+}
+
+// Migrate applies the pending migrations and returns how many it applied.
+func (db *DB) Migrate() (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration runs one migration and records it.
+func (db *DB) applyMigration(m migration) error {
+	// The baseline runs outside a transaction, it tolerates failed steps
+	if m.up == nil {
+		if err := db.baselineSchema(); err != nil {
+			return err
+		}
+		_, err := db.conn.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", m.version, time.Now())
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", m.version, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateDown undoes the last steps applied migrations, newest first, and
+// returns how many it undid. It stops with ErrIrreversible at a migration
+// that can't be undone.
+func (db *DB) MigrateDown(steps int) (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if m.down == nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.version, m.description, ErrIrreversible)
+		}
+		tx, err := db.conn.Begin()
+		if err != nil {
+			return count, err
+		}
+		if err := m.down(tx); err != nil {
+			_ = tx.Rollback()
+			return count, fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+			_ = tx.Rollback()
+			return count, err
+		}
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Migrations returns every migration in order with whether it was applied,
+// followed by any applied by a newer version of the app.
+func (db *DB) Migrations() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	latest := 0
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.version, Description: m.description, Reversible: m.down != nil}
+		if at, ok := applied[m.version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+		latest = m.version
+	}
+	for version, at := range applied {
+		if version > latest {
+			statuses = append(statuses, MigrationStatus{Version: version, AppliedAt: &at})
+		}
+	}
+	return statuses, nil
+}
+
+// PendingMigrations returns how many migrations haven't been applied.
+func (db *DB) PendingMigrations() (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations returns when each applied migration was applied.
+func (db *DB) appliedMigrations() (map[int]time.Time, error) {
+	if _, err := db.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return nil, err
+	}
+	rows, err := db.conn.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// MigrationTestSuite provides a test suite for schema migrations
+type MigrationTestSuite struct {
+	suite.Suite
+	path     string
+	original []migration
+}
+
+// SetupTest runs before each test
+func (s *MigrationTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "expenses.db")
+	s.original = migrations
+	// A reversible migration after the baseline, as future ones will be
+	migrations = append(append([]migration(nil), s.original...), migration{
+		version:     len(s.original) + 1,
+		description: "add notes",
+		up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE notes (id INTEGER PRIMARY KEY)")
+			return err
+		},
+		down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE notes")
+			return err
+		},
+	})
+}
+
+// TearDownTest runs after each test
+func (s *MigrationTestSuite) TearDownTest() {
+	migrations = s.original
+}
+
+// open opens the test database without migrating it.
+func (s *MigrationTestSuite) open() *DB {
+	db, err := Open(s.path)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { db.Close() })
+	return db
+}
+
+func (s *MigrationTestSuite) TestUpAndDown() {
+	db := s.open()
+	pending, err := db.PendingMigrations()
+	s.Require().NoError(err)
+	s.Equal(len(migrations), pending)
+
+	applied, err := db.Migrate()
+	s.Require().NoError(err)
+	s.Equal(len(migrations), applied)
+	_, err = db.conn.Exec("INSERT INTO notes (id) VALUES (1)")
+	s.Require().NoError(err)
+
+	applied, err = db.Migrate()
+	s.Require().NoError(err)
+	s.Zero(applied, "nothing is applied twice")
+
+	undone, err := db.MigrateDown(1)
+	s.Require().NoError(err)
+	s.Equal(1, undone)
+	_, err = db.conn.Exec("INSERT INTO notes (id) VALUES (2)")
+	s.Error(err, "the table is gone")
+
+	statuses, err := db.Migrations()
+	s.Require().NoError(err)
+	s.Require().Len(statuses, len(migrations))
+	s.NotNil(statuses[0].AppliedAt)
+	s.False(statuses[0].Reversible)
+	s.Nil(statuses[len(statuses)-1].AppliedAt)
+	s.True(statuses[len(statuses)-1].Reversible)
+
+	undone, err = db.MigrateDown(len(migrations))
+	s.ErrorIs(err, ErrIrreversible, "the baseline stays")
+	s.Equal(len(migrations)-2, undone, "everything after the baseline was undone")
+}
+
+func (s *MigrationTestSuite) TestExistingDatabase() {
+	// A database created before numbered migrations has the schema but no
+	// record of it
+	db := s.open()
+	s.Require().NoError(db.baselineSchema())
+	_, err := db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+
+	applied, err := db.Migrate()
+	s.Require().NoError(err)
+	s.Equal(len(migrations), applied)
+	count, err := db.UserCount()
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+func (s *MigrationTestSuite) TestNewerDatabase() {
+	db := s.open()
+	_, err := db.Migrate()
+	s.Require().NoError(err)
+	migrations = s.original
+
+	statuses, err := db.Migrations()
+	s.Require().NoError(err)
+	s.Require().Len(statuses, len(s.original)+1)
+	last := statuses[len(statuses)-1]
+	s.Empty(last.Description, "applied by a newer version")
+	s.NotNil(last.AppliedAt)
+}
+
+// TestMigrationSuite runs the migration test suite
+func TestMigrationSuite(t *testing.T) {
+	suite.Run(t, new(MigrationTestSuite))
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrChannelExists is returned when a user adds a notification channel they
+// already have, such as the same ntfy topic twice.
+var ErrChannelExists = errors.New("notification channel already exists")
+
+// AddNotificationChannel adds a notification channel and sets its ID and
+// creation time.
+func (db *DB) AddNotificationChannel(c *models.NotificationChannel) error {
+	c.CreatedAt = time.Now().UTC()
+	result, err := db.conn.Exec(
+		`INSERT INTO notification_channels (user_id, kind, address, p256dh, auth, events, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.UserID, c.Kind, c.Address, c.P256dh, c.Auth, strings.Join(c.Events, ","), c.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrChannelExists
+		}
+		return err
+	}
+	c.ID, err = result.LastInsertId()
+	return err
+}
+
+// ListNotificationChannels returns a user's notification channels, the
+// oldest first.
+func (db *DB) ListNotificationChannels(userID int64) ([]models.NotificationChannel, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, user_id, kind, address, p256dh, auth, events, created_at
+		FROM notification_channels WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []models.NotificationChannel
+	for rows.Next() {
+		var c models.NotificationChannel
+		var events string
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Kind, &c.Address, &c.P256dh, &c.Auth, &events, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			c.Events = strings.Split(events, ",")
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// SetNotificationEvents sets the events one of the user's channels notifies
+// of. It returns sql.ErrNoRows if the user has no such channel.
+func (db *DB) SetNotificationEvents(userID, channelID int64, events []string) error {
+	n, err := rowsAffected(db.conn.Exec(
+		"UPDATE notification_channels SET events = ? WHERE id = ? AND user_id = ?",
+		strings.Join(events, ","), channelID, userID,
+	))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteNotificationChannel removes one of the user's channels, keeping the
+// record of what was sent through it. It returns sql.ErrNoRows if the user
+// has no such channel.
+func (db *DB) DeleteNotificationChannel(userID, channelID int64) error {
+	n, err := rowsAffected(db.conn.Exec("DELETE FROM notification_channels WHERE id = ? AND user_id = ?", channelID, userID))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LogNotification records a delivery and sets its ID and time.
+func (db *DB) LogNotification(d *models.NotificationDelivery) error {
+	d.CreatedAt = time.Now().UTC()
+	result, err := db.conn.Exec(
+		`INSERT INTO notification_deliveries (user_id, channel_id, kind, event, dedupe_key, title, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.UserID, d.ChannelID, d.Kind, d.Event, d.Key, d.Title, d.Error, d.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	d.ID, err = result.LastInsertId()
+	return err
+}
+
+// NotificationSent reports whether the notification with key was delivered
+// through a channel, so it isn't sent again. Failed deliveries don't count.
+func (db *DB) NotificationSent(channelID int64, key string) (bool, error) {
+	var sent bool
+	err := db.conn.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM notification_deliveries WHERE channel_id = ? AND dedupe_key = ? AND error = '')",
+		channelID, key,
+	).Scan(&sent)
+	return sent, err
+}
+
+// ListNotificationDeliveries returns a user's latest deliveries, the newest
+// first, up to limit.
+func (db *DB) ListNotificationDeliveries(userID int64, limit int) ([]models.NotificationDelivery, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, user_id, channel_id, kind, event, dedupe_key, title, error, created_at
+		FROM notification_deliveries WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.NotificationDelivery
+	for rows.Next() {
+		var d models.NotificationDelivery
+		if err := rows.Scan(&d.ID, &d.UserID, &d.ChannelID, &d.Kind, &d.Event, &d.Key, &d.Title, &d.Error, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// PurgeNotificationDeliveries forgets the deliveries made before a time and
+// returns how many.
+func (db *DB) PurgeNotificationDeliveries(before time.Time) (int64, error) {
+	return rowsAffected(db.conn.Exec("DELETE FROM notification_deliveries WHERE created_at < ?", before.UTC()))
+}
+
+// NotifiedUsers returns the IDs of the users with notification channels.
+func (db *DB) NotifiedUsers() ([]int64, error) {
+	rows, err := db.conn.Query("SELECT DISTINCT user_id FROM notification_channels ORDER BY user_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
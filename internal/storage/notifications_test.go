@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// NotificationsTestSuite provides a test suite for notification channels
+// and deliveries
+type NotificationsTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *NotificationsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *NotificationsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+func (s *NotificationsTestSuite) TestChannels() {
+	ntfy := &models.NotificationChannel{UserID: 1, Kind: models.ChannelNtfy, Address: "https://ntfy.sh/expenses", Events: []string{models.EventBudgetAlert}}
+	s.Require().NoError(s.db.AddNotificationChannel(ntfy))
+	s.NotZero(ntfy.ID)
+	s.ErrorIs(s.db.AddNotificationChannel(&models.NotificationChannel{UserID: 1, Kind: models.ChannelNtfy, Address: "https://ntfy.sh/expenses"}), ErrChannelExists)
+	email := &models.NotificationChannel{UserID: 1, Kind: models.ChannelEmail}
+	s.Require().NoError(s.db.AddNotificationChannel(email))
+	s.Require().NoError(s.db.AddNotificationChannel(&models.NotificationChannel{UserID: 2, Kind: models.ChannelEmail}))
+
+	channels, err := s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Require().Len(channels, 2)
+	s.Equal("https://ntfy.sh/expenses", channels[0].Address)
+	s.Equal([]string{models.EventBudgetAlert}, channels[0].Events)
+	s.Empty(channels[1].Events)
+
+	s.Require().NoError(s.db.SetNotificationEvents(1, email.ID, []string{models.EventBillReminder, models.EventWeeklyDigest}))
+	channels, err = s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.True(channels[1].Notifies(models.EventWeeklyDigest))
+	s.False(channels[1].Notifies(models.EventBudgetAlert))
+	s.ErrorIs(s.db.SetNotificationEvents(2, email.ID, nil), sql.ErrNoRows, "someone else's")
+
+	users, err := s.db.NotifiedUsers()
+	s.Require().NoError(err)
+	s.Equal([]int64{1, 2}, users)
+
+	s.ErrorIs(s.db.DeleteNotificationChannel(2, ntfy.ID), sql.ErrNoRows)
+	s.Require().NoError(s.db.DeleteNotificationChannel(1, ntfy.ID))
+	channels, err = s.db.ListNotificationChannels(1)
+	s.Require().NoError(err)
+	s.Len(channels, 1)
+}
+
+func (s *NotificationsTestSuite) TestDeliveries() {
+	failed := &models.NotificationDelivery{UserID: 1, ChannelID: 7, Kind: models.ChannelNtfy, Event: models.EventBudgetAlert, Key: "budget:2026-03-01", Title: "Over budget", Error: "503 Service Unavailable"}
+	s.Require().NoError(s.db.LogNotification(failed))
+	sent, err := s.db.NotificationSent(7, "budget:2026-03-01")
+	s.Require().NoError(err)
+	s.False(sent, "failed deliveries are tried again")
+
+	s.Require().NoError(s.db.LogNotification(&models.NotificationDelivery{UserID: 1, ChannelID: 7, Kind: models.ChannelNtfy, Event: models.EventBudgetAlert, Key: "budget:2026-03-01", Title: "Over budget"}))
+	sent, err = s.db.NotificationSent(7, "budget:2026-03-01")
+	s.Require().NoError(err)
+	s.True(sent)
+	sent, err = s.db.NotificationSent(8, "budget:2026-03-01")
+	s.Require().NoError(err)
+	s.False(sent, "per channel")
+
+	deliveries, err := s.db.ListNotificationDeliveries(1, 10)
+	s.Require().NoError(err)
+	s.Require().Len(deliveries, 2)
+	s.Empty(deliveries[0].Error, "the newest first")
+	s.Equal("503 Service Unavailable", deliveries[1].Error)
+
+	purged, err := s.db.PurgeNotificationDeliveries(time.Now().Add(time.Minute))
+	s.Require().NoError(err)
+	s.Equal(int64(2), purged)
+}
+
+// TestNotificationsTestSuite runs the notification channels and deliveries
+// test suite
+func TestNotificationsTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationsTestSuite))
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// CreatePasskey stores a newly registered passkey for a user.
+func (db *DB) CreatePasskey(p *models.Passkey) error {
+	return createPasskey(db.conn, p.ID, p.UserID, p.Name, p.PublicKey, p.SignCount, time.Now())
+}
+
+// GetPasskey retrieves a passkey by credential ID.
+func (db *DB) GetPasskey(id string) (*models.Passkey, error) {
+	return getPasskey(db.conn, id)
+}
+
+// ListPasskeys retrieves a user's passkeys, oldest first.
+func (db *DB) ListPasskeys(userID int64) ([]models.Passkey, error) {
+	return listPasskeys(db.conn, userID)
+}
+
+// UsePasskey records a successful login with a passkey and its new signature counter.
+func (db *DB) UsePasskey(id string, signCount uint32) error {
+	return usePasskey(db.conn, signCount, time.Now(), id)
+}
+
+// DeletePasskey removes one of a user's passkeys. Passkeys of other users are
+// left untouched.
+func (db *DB) DeletePasskey(userID int64, id string) error {
+	return deletePasskey(db.conn, id, userID)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PasskeyTestSuite provides a test suite for passkey storage
+type PasskeyTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *PasskeyTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *PasskeyTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *PasskeyTestSuite) TestCreateAndUsePasskey() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.db.CreatePasskey(&models.Passkey{ID: "cred-1", UserID: alice.ID, Name: "Phone", PublicKey: []byte{1, 2, 3}}))
+	s.Require().NoError(s.db.CreatePasskey(&models.Passkey{ID: "cred-2", UserID: alice.ID, Name: "Laptop", PublicKey: []byte{4, 5, 6}}))
+	s.Error(s.db.CreatePasskey(&models.Passkey{ID: "cred-1", UserID: alice.ID, Name: "Dup", PublicKey: []byte{7}}), "credential IDs are unique")
+
+	p, err := s.db.GetPasskey("cred-1")
+	s.Require().NoError(err)
+	s.Equal(alice.ID, p.UserID)
+	s.Equal("Phone", p.Name)
+	s.Equal([]byte{1, 2, 3}, p.PublicKey)
+	s.Zero(p.SignCount)
+	s.Nil(p.LastUsedAt)
+
+	s.Require().NoError(s.db.UsePasskey("cred-1", 7))
+	p, err = s.db.GetPasskey("cred-1")
+	s.Require().NoError(err)
+	s.EqualValues(7, p.SignCount)
+	s.NotNil(p.LastUsedAt)
+
+	passkeys, err := s.db.ListPasskeys(alice.ID)
+	s.Require().NoError(err)
+	s.Require().Len(passkeys, 2)
+	s.Equal("cred-1", passkeys[0].ID)
+	s.Equal("cred-2", passkeys[1].ID)
+}
+
+func (s *PasskeyTestSuite) TestDeletePasskey_OnlyOwnPasskeys() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreatePasskey(&models.Passkey{ID: "alice-key", UserID: alice.ID, Name: "Phone", PublicKey: []byte{1}}))
+
+	s.Require().NoError(s.db.DeletePasskey(bob.ID, "alice-key"))
+	_, err = s.db.GetPasskey("alice-key")
+	s.NoError(err, "another user's passkey must not be deleted")
+
+	s.Require().NoError(s.db.DeletePasskey(alice.ID, "alice-key"))
+	_, err = s.db.GetPasskey("alice-key")
+	s.Error(err)
+}
+
+// TestPasskeySuite runs the passkey storage test suite
+func TestPasskeySuite(t *testing.T) {
+	suite.Run(t, new(PasskeyTestSuite))
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// GetPreferences returns a user's preferences, or models.DefaultPreferences
+// if they never saved any.
+func (db *DB) GetPreferences(userID int64) (models.Preferences, error) {
+	var p models.Preferences
+	err := db.conn.QueryRow(
+		"SELECT currency, locale, timezone, theme, cycle_start, default_category, ledger_id, budget, weekly_digest FROM user_settings WHERE user_id = ?",
+		userID,
+	).Scan(&p.Currency, &p.Locale, &p.Timezone, &p.Theme, &p.CycleStart, &p.DefaultCategory, &p.Ledger, &p.Budget, &p.WeeklyDigest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DefaultPreferences(), nil
+	}
+	return p, err
+}
+
+// SavePreferences stores a user's preferences, replacing those saved before.
+// Callers validate them first.
+func (db *DB) SavePreferences(userID int64, p models.Preferences) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO user_settings (user_id, currency, locale, timezone, theme, cycle_start, default_category, ledger_id, budget, weekly_digest, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET
+			currency = excluded.currency,
+			locale = excluded.locale,
+			timezone = excluded.timezone,
+			theme = excluded.theme,
+			cycle_start = excluded.cycle_start,
+			default_category = excluded.default_category,
+			ledger_id = excluded.ledger_id,
+			budget = excluded.budget,
+			weekly_digest = excluded.weekly_digest,
+			updated_at = excluded.updated_at`,
+		userID, p.Currency, p.Locale, p.Timezone, p.Theme, p.CycleStart, p.DefaultCategory, p.Ledger, p.Budget, p.WeeklyDigest, time.Now().UTC(),
+	)
+	return err
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PreferencesTestSuite provides a test suite for user preferences
+type PreferencesTestSuite struct {
+	suite.Suite
+	db   *DB
+	user *models.User
+}
+
+// SetupTest runs before each test
+func (s *PreferencesTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.user, err = db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+}
+
+// TearDownTest runs after each test
+func (s *PreferencesTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *PreferencesTestSuite) TestSave() {
+	prefs, err := s.db.GetPreferences(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal(models.DefaultPreferences(), prefs, "never saved")
+
+	saved := models.Preferences{Currency: "USD", Locale: "de-DE", Timezone: "America/New_York", Theme: models.ThemeDark, CycleStart: 25, DefaultCategory: "Groceries", Budget: 900, WeeklyDigest: true}
+	s.Require().NoError(s.db.SavePreferences(s.user.ID, saved))
+	prefs, err = s.db.GetPreferences(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal(saved, prefs)
+
+	saved.Currency = "GBP"
+	s.Require().NoError(s.db.SavePreferences(s.user.ID, saved))
+	prefs, err = s.db.GetPreferences(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal("GBP", prefs.Currency, "replaced")
+}
+
+func (s *PreferencesTestSuite) TestDeleteUser() {
+	s.Require().NoError(s.db.SavePreferences(s.user.ID, models.Preferences{Currency: "USD", Locale: "en-US", Theme: models.ThemeLight, CycleStart: 1}))
+	s.Require().NoError(s.db.DeleteUser(s.user.ID, 0))
+
+	var count int
+	s.Require().NoError(s.db.conn.QueryRow("SELECT COUNT(*) FROM user_settings").Scan(&count))
+	s.Zero(count)
+}
+
+// TestPreferencesTestSuite runs the user preferences test suite
+func TestPreferencesTestSuite(t *testing.T) {
+	suite.Run(t, new(PreferencesTestSuite))
+}
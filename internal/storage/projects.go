@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ErrProjectExists is returned when a project name is already taken,
+// regardless of case.
+var ErrProjectExists = errors.New("project already exists")
+
+// CreateProject adds a project.
+func (db *DB) CreateProject(name string) (*models.Project, error) {
+	p := &models.Project{Name: name, CreatedAt: time.Now().UTC()}
+	result, err := db.conn.Exec("INSERT INTO projects (name, created_at) VALUES (?, ?)", p.Name, p.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrProjectExists
+		}
+		return nil, err
+	}
+	p.ID, err = result.LastInsertId()
+	return p, err
+}
+
+// ListProjects returns all projects by name.
+func (db *DB) ListProjects() ([]models.Project, error) {
+	rows, err := db.conn.Query("SELECT id, name, created_at FROM projects ORDER BY name COLLATE NOCASE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var projects []models.Project
+	for rows.Next() {
+		var p models.Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// GetProject returns a project by ID, or sql.ErrNoRows.
+func (db *DB) GetProject(id int64) (*models.Project, error) {
+	p := &models.Project{}
+	err := db.conn.QueryRow("SELECT id, name, created_at FROM projects WHERE id = ?", id).Scan(&p.ID, &p.Name, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeleteProject removes a project. Its expenses stay, just no longer
+// assigned to it. It returns sql.ErrNoRows if the project doesn't exist.
+func (db *DB) DeleteProject(id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM expense_projects WHERE project_id = ?", id); err != nil {
+		return err
+	}
+	n, err := rowsAffected(tx.Exec("DELETE FROM projects WHERE id = ?", id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// GetExpenseProject returns the ID of the project an expense is assigned
+// to, zero if none.
+func (db *DB) GetExpenseProject(expenseID int64) (int64, error) {
+	var projectID int64
+	err := db.conn.QueryRow("SELECT project_id FROM expense_projects WHERE expense_id = ?", expenseID).Scan(&projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return projectID, err
+}
+
+// SetExpenseProject assigns an expense to a project, replacing the one it
+// was assigned to. Project zero unassigns it. It returns sql.ErrNoRows if
+// the project doesn't exist.
+func (db *DB) SetExpenseProject(expenseID, projectID int64) error {
+	return setExpenseProject(db.conn, expenseID, projectID)
+}
+
+func setExpenseProject(ex execer, expenseID, projectID int64) error {
+	if projectID == 0 {
+		_, err := ex.Exec("DELETE FROM expense_projects WHERE expense_id = ?", expenseID)
+		return err
+	}
+	n, err := rowsAffected(ex.Exec(
+		`INSERT INTO expense_projects (expense_id, project_id) SELECT ?, id FROM projects WHERE id = ?
+		ON CONFLICT (expense_id) DO UPDATE SET project_id = excluded.project_id`,
+		expenseID, projectID,
+	))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ProjectSummary holds the figures of a project's expenses.
+type ProjectSummary struct {
+	Count      int
+	Total      float64
+	First      time.Time // Date of the earliest expense, zero without any
+	Last       time.Time // Date of the latest expense
+	Categories []CategoryTotal
+}
+
+// SummarizeProject sums up the current and archived expenses of a project
+// in the ledgers a user sees, in total and per category, the largest first.
+func (db *DB) SummarizeProject(projectID, userID int64) (*ProjectSummary, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const from = "FROM all_expenses e JOIN expense_projects p ON p.expense_id = e.id WHERE p.project_id = ? AND e.ledger_id " + visibleLedgers
+	rows, err := tx.Query("SELECT e.category, SUM(e.amount) AS total, COUNT(*) "+from+" GROUP BY e.category ORDER BY total DESC, e.category", projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summary := &ProjectSummary{}
+	for rows.Next() {
+		var t CategoryTotal
+		if err := rows.Scan(&t.Category, &t.Total, &t.Count); err != nil {
+			return nil, err
+		}
+		summary.Categories = append(summary.Categories, t)
+		summary.Total += t.Total
+		summary.Count += t.Count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if summary.Count == 0 {
+		return summary, tx.Commit()
+	}
+
+	if err := tx.QueryRow("SELECT e.date "+from+" ORDER BY e.date LIMIT 1", projectID, userID).Scan(&summary.First); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow("SELECT e.date "+from+" ORDER BY e.date DESC LIMIT 1", projectID, userID).Scan(&summary.Last); err != nil {
+		return nil, err
+	}
+	return summary, tx.Commit()
+}
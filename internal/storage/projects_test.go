@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ProjectsTestSuite provides a test suite for projects
+type ProjectsTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *ProjectsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *ProjectsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// expense adds an expense on the day plus days and assigns it to a
+// project, and returns its ID.
+func (s *ProjectsTestSuite) expense(amount float64, category string, days int, projectID int64) int64 {
+	e := &models.Expense{Amount: amount, Description: "Trip", Category: category, Date: s.day.AddDate(0, 0, days)}
+	s.Require().NoError(s.db.InsertExpense(e))
+	s.Require().NoError(s.db.SetExpenseProject(e.ID, projectID))
+	return e.ID
+}
+
+func (s *ProjectsTestSuite) TestCreateAndList() {
+	japan, err := s.db.CreateProject("Japan trip 2025")
+	s.Require().NoError(err)
+	s.NotZero(japan.ID)
+	_, err = s.db.CreateProject("kitchen")
+	s.Require().NoError(err)
+	_, err = s.db.CreateProject("JAPAN TRIP 2025")
+	s.ErrorIs(err, ErrProjectExists)
+
+	projects, err := s.db.ListProjects()
+	s.Require().NoError(err)
+	s.Require().Len(projects, 2)
+	s.Equal("Japan trip 2025", projects[0].Name)
+	s.Equal("kitchen", projects[1].Name)
+
+	p, err := s.db.GetProject(japan.ID)
+	s.Require().NoError(err)
+	s.Equal(japan.Name, p.Name)
+	_, err = s.db.GetProject(99)
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *ProjectsTestSuite) TestAssign() {
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	kitchen, err := s.db.CreateProject("Kitchen")
+	s.Require().NoError(err)
+
+	id := s.expense(10, "Travel", 0, japan.ID)
+	projectID, err := s.db.GetExpenseProject(id)
+	s.Require().NoError(err)
+	s.Equal(japan.ID, projectID)
+
+	s.Require().NoError(s.db.SetExpenseProject(id, kitchen.ID))
+	projectID, err = s.db.GetExpenseProject(id)
+	s.Require().NoError(err)
+	s.Equal(kitchen.ID, projectID)
+
+	s.ErrorIs(s.db.SetExpenseProject(id, 99), sql.ErrNoRows)
+	s.Require().NoError(s.db.SetExpenseProject(id, 0))
+	projectID, err = s.db.GetExpenseProject(id)
+	s.Require().NoError(err)
+	s.Zero(projectID)
+}
+
+func (s *ProjectsTestSuite) TestSummarize() {
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	s.expense(100, "Travel", 5, japan.ID)
+	s.expense(30, "Eating Out", 0, japan.ID)
+	s.expense(20, "Eating Out", 40, japan.ID)
+	s.expense(999, "Travel", 3, 0)
+
+	summary, err := s.db.SummarizeProject(japan.ID, 1)
+	s.Require().NoError(err)
+	s.Equal(3, summary.Count)
+	s.Equal(150.0, summary.Total)
+	s.Equal(s.day, summary.First.UTC())
+	s.Equal(s.day.AddDate(0, 0, 40), summary.Last.UTC())
+	s.Equal([]CategoryTotal{{Category: "Travel", Total: 100, Count: 1}, {Category: "Eating Out", Total: 50, Count: 2}}, summary.Categories)
+
+	empty, err := s.db.CreateProject("Empty")
+	s.Require().NoError(err)
+	summary, err = s.db.SummarizeProject(empty.ID, 1)
+	s.Require().NoError(err)
+	s.Zero(summary.Count)
+	s.True(summary.First.IsZero())
+}
+
+func (s *ProjectsTestSuite) TestSummarizeHiddenLedger() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	private, err := s.db.CreateLedger("Private", "", bob.ID)
+	s.Require().NoError(err)
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	s.expense(100, "Travel", 0, japan.ID)
+	e := &models.Expense{Amount: 900, Description: "Hotel", Category: "Travel", Date: s.day.AddDate(0, 0, 10), LedgerID: private.ID}
+	s.Require().NoError(s.db.InsertExpense(e))
+	s.Require().NoError(s.db.SetExpenseProject(e.ID, japan.ID))
+
+	summary, err := s.db.SummarizeProject(japan.ID, alice.ID)
+	s.Require().NoError(err)
+	s.Equal(1, summary.Count, "bob's private expense is left out")
+	s.Equal(100.0, summary.Total)
+	s.Equal(s.day, summary.Last.UTC())
+
+	summary, err = s.db.SummarizeProject(japan.ID, bob.ID)
+	s.Require().NoError(err)
+	s.Equal(1000.0, summary.Total)
+}
+
+func (s *ProjectsTestSuite) TestDelete() {
+	japan, err := s.db.CreateProject("Japan")
+	s.Require().NoError(err)
+	id := s.expense(10, "Travel", 0, japan.ID)
+
+	s.Require().NoError(s.db.DeleteProject(japan.ID))
+	s.ErrorIs(s.db.DeleteProject(japan.ID), sql.ErrNoRows)
+	projectID, err := s.db.GetExpenseProject(id)
+	s.Require().NoError(err)
+	s.Zero(projectID, "unassigned")
+	_, err = s.db.GetExpense(id)
+	s.NoError(err, "the expense stays")
+}
+
+// TestProjectsTestSuite runs the projects test suite
+func TestProjectsTestSuite(t *testing.T) {
+	suite.Run(t, new(ProjectsTestSuite))
+}
@@ -0,0 +1,32 @@
+-- name: CreateInvite :execlastid
+-- params: tokenHash string, createdBy int64, createdAt time.Time, expiresAt time.Time
+INSERT INTO invites (token_hash, created_by, created_at, expires_at) VALUES (?, ?, ?, ?);
+
+-- name: GetUsableInvite :one models.Invite
+-- params: tokenHash string, now time.Time
+SELECT invites.id, invites.created_by, invites.created_at, invites.expires_at, invites.used_at,
+	COALESCE(users.username, '') AS used_by
+FROM invites
+LEFT JOIN users ON users.id = invites.used_by
+WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?;
+
+-- name: ListInvites :many models.Invite
+SELECT invites.id, invites.created_by, invites.created_at, invites.expires_at, invites.used_at,
+	COALESCE(users.username, '') AS used_by
+FROM invites
+LEFT JOIN users ON users.id = invites.used_by
+ORDER BY invites.created_at DESC, invites.id DESC;
+
+-- name: UseInvite :one int64
+-- params: usedAt time.Time, tokenHash string, now time.Time
+UPDATE invites SET used_at = ?
+WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+RETURNING id;
+
+-- name: SetInviteUser :exec
+-- params: userID int64, id int64
+UPDATE invites SET used_by = ? WHERE id = ?;
+
+-- name: DeleteInvite :exec
+-- params: id int64
+DELETE FROM invites WHERE id = ?;
@@ -0,0 +1,22 @@
+-- name: CreatePasskey :exec
+-- params: id string, userID int64, name string, publicKey []byte, signCount uint32, createdAt time.Time
+INSERT INTO passkeys (id, user_id, name, public_key, sign_count, created_at) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetPasskey :one models.Passkey
+-- params: id string
+SELECT id, user_id, name, public_key, sign_count, created_at, last_used_at FROM passkeys WHERE id = ?;
+
+-- name: ListPasskeys :many models.Passkey
+-- params: userID int64
+SELECT id, user_id, name, public_key, sign_count, created_at, last_used_at
+FROM passkeys
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: UsePasskey :exec
+-- params: signCount uint32, lastUsedAt time.Time, id string
+UPDATE passkeys SET sign_count = ?, last_used_at = ? WHERE id = ?;
+
+-- name: DeletePasskey :exec
+-- params: id string, userID int64
+DELETE FROM passkeys WHERE id = ? AND user_id = ?;
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrRecurringNotFound is returned for a recurring expense that doesn't
+// exist.
+var ErrRecurringNotFound = errors.New("recurring expense not found")
+
+// Frequencies of recurring expenses.
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// Frequencies are the valid frequencies of recurring expenses.
+var Frequencies = []string{Daily, Weekly, Monthly, Yearly}
+
+// RecurringExpense is an expense that is added again at a regular interval,
+// such as rent or a subscription.
+type RecurringExpense struct {
+	ID          int64
+	UserID      int64
+	Username    string
+	Amount      float64
+	Description string
+	Category    string
+	Frequency   string    // Daily, Weekly, Monthly, or Yearly
+	StartDate   time.Time // Date of the first occurrence
+	NextDate    time.Time // Date of the next occurrence to be added
+	Paused      bool
+}
+
+// CreateRecurring adds a recurring expense whose first occurrence is on
+// start, and returns its ID. Occurrences are only added by
+// MaterializeRecurring.
+func (db *DB) CreateRecurring(userID int64, amount float64, description, category, frequency string, start time.Time) (int64, error) {
+	if !slices.Contains(Frequencies, frequency) {
+		return 0, fmt.Errorf("unknown frequency %q", frequency)
+	}
+	result, err := db.conn.Exec(
+		`INSERT INTO recurring_expenses (user_id, amount, description, category, frequency, start_date, next_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, amount, description, category, frequency, start, start,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ListRecurring returns the recurring expenses by next occurrence. A userID
+// other than 0 only returns that user's.
+func (db *DB) ListRecurring(userID int64) ([]RecurringExpense, error) {
+	rows, err := db.conn.Query(
+		`SELECT r.id, r.user_id, users.username, r.amount, r.description, r.category, r.frequency, r.start_date, r.next_date, r.paused
+		FROM recurring_expenses r
+		JOIN users ON r.user_id = users.id
+		WHERE ? = 0 OR r.user_id = ?
+		ORDER BY r.next_date, r.id`,
+		userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []RecurringExpense
+	for rows.Next() {
+		var r RecurringExpense
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Username, &r.Amount, &r.Description, &r.Category, &r.Frequency, &r.StartDate, &r.NextDate, &r.Paused); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// PauseRecurring stops adding occurrences of a recurring expense.
+func (db *DB) PauseRecurring(id int64) error {
+	n, err := rowsAffected(db.conn.Exec("UPDATE recurring_expenses SET paused = 1 WHERE id = ?", id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecurringNotFound
+	}
+	return nil
+}
+
+// ResumeRecurring starts adding occurrences of a paused recurring expense
+// again. Occurrences missed while paused, up to now, are skipped rather than
+// added all at once.
+func (db *DB) ResumeRecurring(id int64, now time.Time) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var frequency string
+	var start time.Time
+	var occurrences int
+	err = tx.QueryRow("SELECT frequency, start_date, occurrences FROM recurring_expenses WHERE id = ?", id).Scan(&frequency, &start, &occurrences)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecurringNotFound
+	} else if err != nil {
+		return err
+	}
+	next := occurrence(start, frequency, occurrences)
+	for !next.After(now) {
+		occurrences++
+		next = occurrence(start, frequency, occurrences)
+	}
+	if _, err := tx.Exec(
+		"UPDATE recurring_expenses SET paused = 0, occurrences = ?, next_date = ? WHERE id = ?",
+		occurrences, next, id,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MaterializeRecurring adds every occurrence of the recurring expenses that
+// is due by now, in one transaction, and returns how many expenses it added.
+// Paused recurring expenses and those of disabled users are left alone.
+func (db *DB) MaterializeRecurring(now time.Time) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	type due struct {
+		id, userID                       int64
+		amount                           float64
+		description, category, frequency string
+		start                            time.Time
+		occurrences                      int
+	}
+	rows, err := tx.Query(
+		`SELECT r.id, r.user_id, r.amount, r.description, r.category, r.frequency, r.start_date, r.occurrences
+		FROM recurring_expenses r
+		JOIN users ON r.user_id = users.id
+		WHERE r.paused = 0 AND users.disabled_at IS NULL AND r.next_date <= ?`,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var list []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.userID, &d.amount, &d.description, &d.category, &d.frequency, &d.start, &d.occurrences); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		list = append(list, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var added int64
+	var days []string
+	for _, d := range list {
+		next := occurrence(d.start, d.frequency, d.occurrences)
+		for !next.After(now) {
+			if _, err := tx.Exec(
+				"INSERT INTO expenses (amount, description, category, date, user_id) VALUES (?, ?, ?, ?, ?)",
+				d.amount, d.description, d.category, next, d.userID,
+			); err != nil {
+				return 0, err
+			}
+			days = append(days, next.Format(time.DateOnly))
+			added++
+			d.occurrences++
+			next = occurrence(d.start, d.frequency, d.occurrences)
+		}
+		if _, err := tx.Exec(
+			"UPDATE recurring_expenses SET occurrences = ?, next_date = ? WHERE id = ?",
+			d.occurrences, next, d.id,
+		); err != nil {
+			return 0, err
+		}
+	}
+	if err := refreshAggregates(tx, days...); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+// occurrence returns the date of the nth occurrence after start, counting
+// from 0. Monthly and yearly occurrences fall on the last day of shorter
+// months rather than spilling into the next one.
+func occurrence(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case Daily:
+		return start.AddDate(0, 0, n)
+	case Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case Yearly:
+		return addMonths(start, 12*n)
+	default:
+		return addMonths(start, n)
+	}
+}
+
+// addMonths adds months to t, keeping the day of the month where the target
+// month has it and using its last day otherwise.
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
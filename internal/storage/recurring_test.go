@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RecurringTestSuite provides a test suite for recurring expenses
+type RecurringTestSuite struct {
+	suite.Suite
+	db     *DB
+	userID int64
+}
+
+// SetupTest runs before each test
+func (s *RecurringTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	user, err := db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.userID = user.ID
+}
+
+// TearDownTest runs after each test
+func (s *RecurringTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *RecurringTestSuite) TestMaterializeCatchesUp() {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	_, err := s.db.CreateRecurring(s.userID, 900, "Rent", "Housing", Monthly, start)
+	s.Require().NoError(err)
+
+	added, err := s.db.MaterializeRecurring(time.Date(2026, 3, 31, 8, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Equal(int64(2), added, "January and February, March is due later that day")
+
+	febTotal, err := s.db.GetTotalForPeriod(0, 2026, 2)
+	s.Require().NoError(err)
+	s.Equal(900.0, febTotal)
+	expenses, err := s.db.GetExpensesByMonth(2026, 2)
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+	s.Equal(28, expenses[0].Date.Day(), "the last day of a shorter month")
+
+	added, err = s.db.MaterializeRecurring(time.Date(2026, 3, 31, 8, 0, 0, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Equal(int64(0), added, "nothing is added twice")
+
+	list, err := s.db.ListRecurring(s.userID)
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	s.Equal("alice", list[0].Username)
+	s.Equal(time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), list[0].NextDate.UTC())
+}
+
+func (s *RecurringTestSuite) TestPauseAndResume() {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	id, err := s.db.CreateRecurring(s.userID, 5, "Gym", "Sport", Weekly, start)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.PauseRecurring(id))
+
+	added, err := s.db.MaterializeRecurring(start.AddDate(0, 0, 20))
+	s.Require().NoError(err)
+	s.Equal(int64(0), added, "paused")
+
+	s.Require().NoError(s.db.ResumeRecurring(id, start.AddDate(0, 0, 20)))
+	added, err = s.db.MaterializeRecurring(start.AddDate(0, 0, 22))
+	s.Require().NoError(err)
+	s.Equal(int64(1), added, "the weeks missed while paused are skipped")
+
+	s.ErrorIs(s.db.PauseRecurring(999), ErrRecurringNotFound)
+	s.ErrorIs(s.db.ResumeRecurring(999, start), ErrRecurringNotFound)
+}
+
+func (s *RecurringTestSuite) TestUnknownFrequency() {
+	_, err := s.db.CreateRecurring(s.userID, 5, "Coffee", "Eating Out", "hourly", time.Now())
+	s.Error(err)
+}
+
+func (s *RecurringTestSuite) TestCategoryFollowsRename() {
+	_, err := s.db.CreateRecurring(s.userID, 10, "Streaming", "Entertainment", Monthly, time.Now())
+	s.Require().NoError(err)
+	s.ErrorIs(s.db.DeleteCategory("Entertainment"), ErrCategoryInUse)
+
+	_, err = s.db.RenameCategory("Entertainment", "Subscriptions")
+	s.Require().NoError(err)
+	list, err := s.db.ListRecurring(0)
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	s.Equal("Subscriptions", list[0].Category)
+}
+
+// TestRecurringSuite runs the recurring expense test suite
+func TestRecurringSuite(t *testing.T) {
+	suite.Run(t, new(RecurringTestSuite))
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// GetReimbursement returns the reimbursement state of an expense, empty if
+// it isn't reimbursable.
+func (db *DB) GetReimbursement(expenseID int64) (string, error) {
+	var status string
+	err := db.conn.QueryRow("SELECT status FROM expense_reimbursements WHERE expense_id = ?", expenseID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+// SetReimbursement sets the reimbursement state of an expense. An empty
+// status makes it not reimbursable.
+func (db *DB) SetReimbursement(expenseID int64, status string) error {
+	return setReimbursement(db.conn, expenseID, status)
+}
+
+func setReimbursement(ex execer, expenseID int64, status string) error {
+	if status == "" {
+		_, err := ex.Exec("DELETE FROM expense_reimbursements WHERE expense_id = ?", expenseID)
+		return err
+	}
+	_, err := ex.Exec(
+		`INSERT INTO expense_reimbursements (expense_id, status, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (expense_id) DO UPDATE SET status = excluded.status, updated_at = excluded.updated_at`,
+		expenseID, status, time.Now().UTC(),
+	)
+	return err
+}
+
+// SetReimbursements moves the user's reimbursable expenses among ids, current
+// or archived, to a reimbursement state in one go, and returns how many it
+// moved. Expenses of others and those that aren't reimbursable are left
+// alone.
+func (db *DB) SetReimbursements(userID int64, ids []int64, status string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := []any{status, time.Now().UTC(), status, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return rowsAffected(db.conn.Exec(
+		`UPDATE expense_reimbursements SET status = ?, updated_at = ?
+		WHERE status <> ? AND expense_id IN (SELECT id FROM all_expenses WHERE user_id = ?)
+		AND expense_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`,
+		args...,
+	))
+}
+
+// Reimbursable is a reimbursable expense and its state.
+type Reimbursable struct {
+	Expense   models.Expense
+	Status    string
+	UpdatedAt time.Time // When it last changed state
+}
+
+// OutstandingReimbursements returns the user's current and archived expenses
+// that are still to be paid back, pending ones first, oldest first.
+func (db *DB) OutstandingReimbursements(userID int64) ([]Reimbursable, error) {
+	rows, err := db.conn.Query(
+		`SELECT e.id, e.amount, e.description, e.category, e.date, e.user_id, e.ledger_id, r.status, r.updated_at
+		FROM all_expenses e JOIN expense_reimbursements r ON r.expense_id = e.id
+		WHERE e.user_id = ? AND r.status IN (?, ?)
+		ORDER BY r.status = ? DESC, e.date, e.id`,
+		userID, models.ReimbursementPending, models.ReimbursementSubmitted, models.ReimbursementPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outstanding []Reimbursable
+	for rows.Next() {
+		var r Reimbursable
+		e := &r.Expense
+		if err := rows.Scan(&e.ID, &e.Amount, &e.Description, &e.Category, &e.Date, &e.UserID, &e.LedgerID, &r.Status, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		outstanding = append(outstanding, r)
+	}
+	return outstanding, rows.Err()
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"expense-tracker/internal/models"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ReimbursementsTestSuite provides a test suite for reimbursable expenses
+type ReimbursementsTestSuite struct {
+	suite.Suite
+	db  *DB
+	day time.Time
+}
+
+// SetupTest runs before each test
+func (s *ReimbursementsTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.day = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *ReimbursementsTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// expense adds an expense of user on the day plus days with the given
+// reimbursement state, and returns its ID.
+func (s *ReimbursementsTestSuite) expense(userID int64, days int, status string) int64 {
+	e := &models.Expense{Amount: float64(days + 1), Description: "Train", Category: "Transport", Date: s.day.AddDate(0, 0, days), UserID: &userID}
+	s.Require().NoError(s.db.InsertExpense(e))
+	s.Require().NoError(s.db.SetReimbursement(e.ID, status))
+	return e.ID
+}
+
+func (s *ReimbursementsTestSuite) TestSetReimbursement() {
+	id := s.expense(1, 0, models.ReimbursementPending)
+	status, err := s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	s.Equal(models.ReimbursementPending, status)
+
+	s.Require().NoError(s.db.SetReimbursement(id, models.ReimbursementSubmitted))
+	status, err = s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	s.Equal(models.ReimbursementSubmitted, status)
+
+	s.Require().NoError(s.db.SetReimbursement(id, ""))
+	status, err = s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	s.Empty(status, "no longer reimbursable")
+
+	s.Error(s.db.SetReimbursement(id, "lost"), "an unknown state")
+}
+
+func (s *ReimbursementsTestSuite) TestOutstanding() {
+	submitted := s.expense(1, 0, models.ReimbursementSubmitted)
+	later := s.expense(1, 2, models.ReimbursementPending)
+	earlier := s.expense(1, 1, models.ReimbursementPending)
+	s.expense(1, 3, models.ReimbursementReimbursed)
+	s.expense(1, 4, "")
+	s.expense(2, 5, models.ReimbursementPending)
+
+	outstanding, err := s.db.OutstandingReimbursements(1)
+	s.Require().NoError(err)
+	s.Require().Len(outstanding, 3)
+	var ids []int64
+	for _, r := range outstanding {
+		ids = append(ids, r.Expense.ID)
+	}
+	s.Equal([]int64{earlier, later, submitted}, ids, "pending first, oldest first")
+	s.Equal("Train", outstanding[0].Expense.Description)
+	s.Equal(s.day.AddDate(0, 0, 1), outstanding[0].Expense.Date.UTC())
+	s.False(outstanding[0].UpdatedAt.IsZero())
+}
+
+func (s *ReimbursementsTestSuite) TestSetReimbursements() {
+	pending := s.expense(1, 0, models.ReimbursementPending)
+	submitted := s.expense(1, 1, models.ReimbursementSubmitted)
+	plain := s.expense(1, 2, "")
+	others := s.expense(2, 3, models.ReimbursementPending)
+
+	n, err := s.db.SetReimbursements(1, []int64{pending, submitted, plain, others}, models.ReimbursementReimbursed)
+	s.Require().NoError(err)
+	s.Equal(int64(2), n)
+	for id, want := range map[int64]string{
+		pending:   models.ReimbursementReimbursed,
+		submitted: models.ReimbursementReimbursed,
+		plain:     "",
+		others:    models.ReimbursementPending,
+	} {
+		status, err := s.db.GetReimbursement(id)
+		s.Require().NoError(err)
+		s.Equal(want, status, id)
+	}
+
+	n, err = s.db.SetReimbursements(1, []int64{pending}, models.ReimbursementReimbursed)
+	s.Require().NoError(err)
+	s.Zero(n, "already reimbursed")
+	n, err = s.db.SetReimbursements(1, nil, models.ReimbursementReimbursed)
+	s.Require().NoError(err)
+	s.Zero(n)
+}
+
+func (s *ReimbursementsTestSuite) TestForgotten() {
+	id := s.expense(1, 0, models.ReimbursementPending)
+	s.Require().NoError(s.db.DeleteExpense(id))
+	s.Require().NoError(s.db.RestoreExpense(id))
+	status, err := s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	s.Equal(models.ReimbursementPending, status, "restored along with the expense")
+
+	s.Require().NoError(s.db.DeleteExpense(id))
+	_, err = s.db.PurgeDeletedExpenses(time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	status, err = s.db.GetReimbursement(id)
+	s.Require().NoError(err)
+	s.Empty(status, "gone with the expense")
+}
+
+// TestReimbursementsTestSuite runs the reimbursable expenses test suite
+func TestReimbursementsTestSuite(t *testing.T) {
+	suite.Run(t, new(ReimbursementsTestSuite))
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "expense-tracker/internal/models"
+
+// Reset deletes all users and everything they created, leaving an empty
+// database with its secrets intact. It is meant for demo instances.
+func (db *DB) Reset() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{
+		"expenses",
+		"archived_expenses",
+		"deleted_expenses",
+		"expense_locations",
+		"expense_reimbursements",
+		"expense_projects",
+		"projects",
+		"daily_aggregates",
+		"sessions",
+		"passkeys",
+		"user_settings",
+		"avatars",
+		"login_events",
+		"notification_channels",
+		"notification_deliveries",
+		"challenges",
+		"spending_streaks",
+		"guest_tokens",
+		"invites",
+		"users",
+	} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	// The personal ledger always exists
+	if _, err := tx.Exec("DELETE FROM ledgers WHERE id <> ?", models.PersonalLedgerID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE ledgers SET currency = ''"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RetentionPolicy says how long data is kept. Zero keeps it forever.
+type RetentionPolicy struct {
+	LoginHistoryDays int // Login attempts older than this are deleted
+	InviteDays       int // Invites are deleted this long after they expire
+	ExpenseYears     int // Older expenses lose their description, owner, and location
+}
+
+// RetentionResult counts the rows a retention run deleted or anonymized.
+type RetentionResult struct {
+	LoginEvents int64
+	Invites     int64
+	Expenses    int64
+}
+
+// ApplyRetention deletes login history and invites and anonymizes expenses
+// that are older than the policy allows at now. With dryRun, it only counts
+// them. Anonymized expenses keep their amount, category, and date, so the
+// statistics don't change, but are described by their category, no longer
+// belong to anyone, and forget where they were made.
+func (db *DB) ApplyRetention(p RetentionPolicy, now time.Time, dryRun bool) (RetentionResult, error) {
+	var result RetentionResult
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if p.LoginHistoryDays > 0 {
+		if result.LoginEvents, err = rowsAffected(tx.Exec(
+			"DELETE FROM login_events WHERE created_at < ?", now.AddDate(0, 0, -p.LoginHistoryDays),
+		)); err != nil {
+			return result, err
+		}
+	}
+	if p.InviteDays > 0 {
+		if result.Invites, err = rowsAffected(tx.Exec(
+			"DELETE FROM invites WHERE expires_at < ?", now.AddDate(0, 0, -p.InviteDays),
+		)); err != nil {
+			return result, err
+		}
+	}
+	if p.ExpenseYears > 0 {
+		cutoff := now.AddDate(-p.ExpenseYears, 0, 0)
+		// Expenses on the same date with the same amount and category would
+		// clash in the unique index, so those also get their ID
+		const notAnonymized = "date < ? AND (user_id IS NOT NULL OR (description <> category AND description <> category || ' #' || id))"
+		for _, table := range []string{"expenses", "archived_expenses"} {
+			first, err := rowsAffected(tx.Exec(
+				"UPDATE OR IGNORE "+table+" SET description = category, user_id = NULL WHERE "+notAnonymized, cutoff,
+			))
+			if err != nil {
+				return result, err
+			}
+			clashing, err := rowsAffected(tx.Exec(
+				"UPDATE "+table+" SET description = category || ' #' || id, user_id = NULL WHERE "+notAnonymized, cutoff,
+			))
+			if err != nil {
+				return result, err
+			}
+			result.Expenses += first + clashing
+		}
+		// Where they were made is as personal as who made them
+		if _, err := tx.Exec("DELETE FROM expense_locations WHERE expense_id IN (SELECT id FROM all_expenses WHERE date < ?)", cutoff); err != nil {
+			return result, err
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// rowsAffected returns how many rows a statement changed.
+func rowsAffected(result sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// RetentionTestSuite provides a test suite for the retention policy
+type RetentionTestSuite struct {
+	suite.Suite
+	db  *DB
+	now time.Time
+}
+
+// SetupTest runs before each test
+func (s *RetentionTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+	s.now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+}
+
+// TearDownTest runs after each test
+func (s *RetentionTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *RetentionTestSuite) recordLogin(at time.Time) {
+	_, err := s.db.conn.Exec(
+		"INSERT INTO login_events (user_id, username, ip, user_agent, success, created_at) VALUES (1, 'alice', '192.0.2.1', 'test', 1, ?)", at,
+	)
+	s.Require().NoError(err)
+}
+
+func (s *RetentionTestSuite) TestLoginHistoryAndInvites() {
+	s.recordLogin(s.now.AddDate(0, 0, -31))
+	s.recordLogin(s.now.AddDate(0, 0, -29))
+	_, err := s.db.CreateInvite("old", 1, s.now.AddDate(0, 0, -8))
+	s.Require().NoError(err)
+	_, err = s.db.CreateInvite("recent", 1, s.now.AddDate(0, 0, -6))
+	s.Require().NoError(err)
+
+	result, err := s.db.ApplyRetention(RetentionPolicy{LoginHistoryDays: 30, InviteDays: 7}, s.now, false)
+	s.Require().NoError(err)
+	s.Equal(RetentionResult{LoginEvents: 1, Invites: 1}, result)
+
+	events, err := s.db.ListLoginEvents(1, 10)
+	s.Require().NoError(err)
+	s.Len(events, 1)
+}
+
+func (s *RetentionTestSuite) TestAnonymizesExpenses() {
+	user, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	old := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.db.CreateExpense(10, "Dinner with Bob", "Eating Out", old, user.ID))
+	// Would clash with the first once both are described by their category
+	s.Require().NoError(s.db.CreateExpense(10, "Lunch with Carol", "Eating Out", old, user.ID))
+	s.Require().NoError(s.db.CreateExpense(20, "Doctor", "Health", s.now.AddDate(0, -6, 0), user.ID))
+
+	result, err := s.db.ApplyRetention(RetentionPolicy{ExpenseYears: 2}, s.now, false)
+	s.Require().NoError(err)
+	s.Equal(int64(2), result.Expenses)
+
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 3)
+	s.Equal("Doctor", expenses[0].Description)
+	s.NotNil(expenses[0].UserID)
+	descriptions := []string{expenses[1].Description, expenses[2].Description}
+	s.Contains(descriptions, "Eating Out")
+	s.Contains(descriptions, "Eating Out #2")
+	s.Nil(expenses[1].UserID)
+	s.Nil(expenses[2].UserID)
+
+	total, err := s.db.GetTotalForPeriod(0, 2023, 5)
+	s.Require().NoError(err)
+	s.Equal(20.0, total, "statistics are unchanged")
+
+	result, err = s.db.ApplyRetention(RetentionPolicy{ExpenseYears: 2}, s.now, false)
+	s.Require().NoError(err)
+	s.Zero(result.Expenses, "already anonymized")
+}
+
+func (s *RetentionTestSuite) TestDryRun() {
+	s.recordLogin(s.now.AddDate(-1, 0, 0))
+	s.Require().NoError(s.db.CreateExpense(10, "Dinner", "Eating Out", s.now.AddDate(-3, 0, 0), 1))
+
+	policy := RetentionPolicy{LoginHistoryDays: 30, ExpenseYears: 1}
+	result, err := s.db.ApplyRetention(policy, s.now, true)
+	s.Require().NoError(err)
+	s.Equal(RetentionResult{LoginEvents: 1, Expenses: 1}, result)
+
+	events, err := s.db.ListLoginEvents(1, 10)
+	s.Require().NoError(err)
+	s.Len(events, 1, "nothing is deleted")
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Equal("Dinner", expenses[0].Description)
+
+	result, err = s.db.ApplyRetention(policy, s.now, false)
+	s.Require().NoError(err)
+	s.Equal(RetentionResult{LoginEvents: 1, Expenses: 1}, result)
+}
+
+// TestRetentionSuite runs the retention policy test suite
+func TestRetentionSuite(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
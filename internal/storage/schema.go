@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+)
+
+// MissingSchema compares the database at path with the schema NewDB creates
+// and returns the tables, views, and columns it lacks, without changing it.
+// A database the server hasn't run on since an upgrade lacks the newest ones
+// until the server starts and migrates it.
+func MissingSchema(path string) ([]string, error) {
+	current, err := NewDB(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer current.Close()
+	want, err := schema(current.pool)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	have, err := schema(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name, columns := range want {
+		haveColumns, ok := have[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		found := make(map[string]bool, len(haveColumns))
+		for _, column := range haveColumns {
+			found[column] = true
+		}
+		for _, column := range columns {
+			if !found[column] {
+				missing = append(missing, name+"."+column)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// schema returns the columns of every table and view in a database.
+func schema(conn *sql.DB) (map[string][]string, error) {
+	rows, err := conn.Query("SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string][]string, len(names))
+	for _, name := range names {
+		columns, err := queryColumns(conn, name)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = columns
+	}
+	return tables, nil
+}
+
+// queryColumns returns the column names of a table or view.
+func queryColumns(conn *sql.DB, table string) ([]string, error) {
+	rows, err := conn.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SchemaTestSuite provides a test suite for schema checks
+type SchemaTestSuite struct {
+	suite.Suite
+	path string
+}
+
+// SetupTest runs before each test
+func (s *SchemaTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "expenses.db")
+}
+
+func (s *SchemaTestSuite) TestMigratedDatabase() {
+	db, err := NewDB(s.path)
+	s.Require().NoError(err)
+	s.Require().NoError(db.Close())
+
+	missing, err := MissingSchema(s.path)
+	s.Require().NoError(err)
+	s.Empty(missing)
+}
+
+func (s *SchemaTestSuite) TestOutdatedDatabase() {
+	conn, err := sql.Open("sqlite", s.path)
+	s.Require().NoError(err)
+	_, err = conn.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT, password_hash TEXT, created_at DATETIME)")
+	s.Require().NoError(err)
+	s.Require().NoError(conn.Close())
+
+	missing, err := MissingSchema(s.path)
+	s.Require().NoError(err)
+	s.Contains(missing, "expenses")
+	s.Contains(missing, "users.disabled_at")
+	s.NotContains(missing, "users.username")
+
+	// Checking doesn't migrate
+	missing, err = MissingSchema(s.path)
+	s.Require().NoError(err)
+	s.Contains(missing, "expenses")
+}
+
+func (s *SchemaTestSuite) TestMissingFile() {
+	_, err := MissingSchema(s.path)
+	s.Error(err)
+}
+
+// TestSchemaSuite runs the schema test suite
+func TestSchemaSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
@@ -0,0 +1,20 @@
+package storage
+
+import "crypto/rand"
+
+// secretLength is the size of generated secrets in bytes.
+const secretLength = 32
+
+// Secret returns the named server secret, generating and storing a random one
+// on first use so it survives restarts.
+func (db *DB) Secret(name string) ([]byte, error) {
+	value := make([]byte, secretLength)
+	if _, err := rand.Read(value); err != nil {
+		return nil, err
+	}
+	if _, err := db.conn.Exec("INSERT OR IGNORE INTO secrets (name, value) VALUES (?, ?)", name, value); err != nil {
+		return nil, err
+	}
+	err := db.conn.QueryRow("SELECT value FROM secrets WHERE name = ?", name).Scan(&value)
+	return value, err
+}
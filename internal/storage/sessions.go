@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"database/sql"
 	"time"
 
 	"expense-tracker/internal/models"
@@ -11,14 +12,18 @@ type SessionInfo struct {
 	User         *models.User
 	LastActivity time.Time
 	ExpiresAt    time.Time
+	Duration     time.Duration // Lifetime the session is renewed to while in use
+	Fingerprint  string        // Client the session is bound to, empty if unbound
 }
 
-// CreateSession creates a new session for a user.
-func (db *DB) CreateSession(token string, userID int64, expiresAt time.Time) error {
+// CreateSession creates a new session for a user that expires after
+// duration. A non-empty fingerprint binds the session to the client that
+// logged in.
+func (db *DB) CreateSession(token string, userID int64, duration time.Duration, fingerprint string) error {
 	now := time.Now()
 	_, err := db.conn.Exec(
-		"INSERT INTO sessions (token, user_id, expires_at, last_activity) VALUES (?, ?, ?, ?)",
-		token, userID, expiresAt, now,
+		"INSERT INTO sessions (token, user_id, expires_at, last_activity, duration, fingerprint) VALUES (?, ?, ?, ?, ?, ?)",
+		token, userID, now.Add(duration), now, int64(duration.Seconds()), sql.NullString{String: fingerprint, Valid: fingerprint != ""},
 	)
 	return err
 }
@@ -32,24 +37,32 @@ func (db *DB) ValidateSession(token string) (*models.User, error) {
 	return info.User, nil
 }
 
+// validateSessionQuery loads a live session and its user.
+const validateSessionQuery = `
+	SELECT ` + userColumns + `, sessions.last_activity, sessions.expires_at, sessions.duration, sessions.fingerprint
+	FROM sessions
+	JOIN users ON sessions.user_id = users.id
+	WHERE sessions.token = ? AND sessions.expires_at > CURRENT_TIMESTAMP
+`
+
 // ValidateSessionWithInfo checks if a session token is valid and returns session details.
 func (db *DB) ValidateSessionWithInfo(token string) (*SessionInfo, error) {
-	row := db.conn.QueryRow(`
-		SELECT u.id, u.username, u.password_hash, u.created_at, s.last_activity, s.expires_at
-		FROM sessions s
-		JOIN users u ON s.user_id = u.id
-		WHERE s.token = ? AND s.expires_at > CURRENT_TIMESTAMP
-	`, token)
-
-	var u models.User
+	// Runs on every authenticated request, so the statement is kept prepared
+	row := db.conn.QueryRowPrepared(validateSessionQuery, token)
+
 	var lastActivity, expiresAt time.Time
-	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt, &lastActivity, &expiresAt); err != nil {
+	var durationSeconds int64
+	var fingerprint sql.NullString
+	u, err := scanUser(row, &lastActivity, &expiresAt, &durationSeconds, &fingerprint)
+	if err != nil {
 		return nil, err
 	}
 	return &SessionInfo{
-		User:         &u,
+		User:         u,
 		LastActivity: lastActivity,
 		ExpiresAt:    expiresAt,
+		Duration:     time.Duration(durationSeconds) * time.Second,
+		Fingerprint:  fingerprint.String,
 	}, nil
 }
 
@@ -69,8 +82,76 @@ func (db *DB) DeleteSession(token string) error {
 	return err
 }
 
-// CleanExpiredSessions removes all expired sessions.
-func (db *DB) CleanExpiredSessions() error {
-	_, err := db.conn.Exec("DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP")
-	return err
+// DeleteUserSessions signs a user out everywhere and returns how many
+// sessions were deleted.
+func (db *DB) DeleteUserSessions(userID int64) (int64, error) {
+	result, err := db.conn.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanExpiredSessions removes all expired sessions and returns how many
+// were removed.
+func (db *DB) CleanExpiredSessions() (int64, error) {
+	result, err := db.conn.Exec("DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SessionTokenPrefix is how many characters of a token ListSessions shows,
+// enough to tell sessions apart without revealing the token.
+const SessionTokenPrefix = 8
+
+// SessionSummary describes an active session for listing.
+type SessionSummary struct {
+	TokenPrefix  string // First SessionTokenPrefix characters of the token
+	UserID       int64
+	Username     string
+	LastActivity time.Time
+	ExpiresAt    time.Time
+	Bound        bool // Bound to the client that logged in
+}
+
+// ListSessions returns the sessions that haven't expired, most recently used
+// first. A userID other than 0 only returns that user's sessions.
+func (db *DB) ListSessions(userID int64) ([]SessionSummary, error) {
+	rows, err := db.conn.Query(
+		`SELECT SUBSTR(sessions.token, 1, ?), users.id, users.username, sessions.last_activity, sessions.expires_at, sessions.fingerprint IS NOT NULL
+		FROM sessions
+		JOIN users ON sessions.user_id = users.id
+		WHERE sessions.expires_at > CURRENT_TIMESTAMP AND (? = 0 OR users.id = ?)
+		ORDER BY sessions.last_activity DESC`,
+		SessionTokenPrefix, userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionSummary
+	for rows.Next() {
+		var s SessionSummary
+		if err := rows.Scan(&s.TokenPrefix, &s.UserID, &s.Username, &s.LastActivity, &s.ExpiresAt, &s.Bound); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSessionsByPrefix removes the sessions whose token starts with prefix
+// and returns how many were removed.
+func (db *DB) DeleteSessionsByPrefix(prefix string) (int64, error) {
+	// Not LIKE, tokens can contain its wildcards
+	return rowsAffected(db.conn.Exec("DELETE FROM sessions WHERE SUBSTR(token, 1, ?) = ?", len(prefix), prefix))
+}
+
+// DeleteAllSessions signs everyone out and returns how many sessions were
+// removed.
+func (db *DB) DeleteAllSessions() (int64, error) {
+	return rowsAffected(db.conn.Exec("DELETE FROM sessions"))
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
@@ -43,8 +44,7 @@ func (s *SessionTestSuite) TestCreateAndValidateSession() {
 	token, err := auth.GenerateSessionToken()
 	s.Require().NoError(err)
 
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	err = s.db.CreateSession(token, s.user.ID, expiresAt)
+	err = s.db.CreateSession(token, s.user.ID, 30*24*time.Hour, "")
 	s.Require().NoError(err)
 
 	// Validate the session
@@ -57,8 +57,7 @@ func (s *SessionTestSuite) TestValidateSessionWithInfo() {
 	token, err := auth.GenerateSessionToken()
 	s.Require().NoError(err)
 
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	err = s.db.CreateSession(token, s.user.ID, expiresAt)
+	err = s.db.CreateSession(token, s.user.ID, 30*24*time.Hour, "")
 	s.Require().NoError(err)
 
 	// Get session info
@@ -69,14 +68,28 @@ func (s *SessionTestSuite) TestValidateSessionWithInfo() {
 	// Check that last_activity is recent
 	timeSinceActivity := time.Since(info.LastActivity)
 	s.Less(timeSinceActivity, 5*time.Second, "LastActivity should be recent")
+	s.Empty(info.Fingerprint, "sessions are unbound by default")
+	s.Equal(30*24*time.Hour, info.Duration)
+	s.WithinDuration(time.Now().Add(30*24*time.Hour), info.ExpiresAt, 5*time.Second)
+}
+
+func (s *SessionTestSuite) TestSessionFingerprint() {
+	token, err := auth.GenerateSessionToken()
+	s.Require().NoError(err)
+
+	err = s.db.CreateSession(token, s.user.ID, time.Hour, "abc123")
+	s.Require().NoError(err)
+
+	info, err := s.db.ValidateSessionWithInfo(token)
+	s.Require().NoError(err)
+	s.Equal("abc123", info.Fingerprint)
 }
 
 func (s *SessionTestSuite) TestRenewSession() {
 	token, err := auth.GenerateSessionToken()
 	s.Require().NoError(err)
 
-	originalExpiry := time.Now().Add(30 * 24 * time.Hour)
-	err = s.db.CreateSession(token, s.user.ID, originalExpiry)
+	err = s.db.CreateSession(token, s.user.ID, 30*24*time.Hour, "")
 	s.Require().NoError(err)
 
 	// Wait a moment to ensure timestamps differ
@@ -108,8 +121,7 @@ func (s *SessionTestSuite) TestDeleteSession() {
 	token, err := auth.GenerateSessionToken()
 	s.Require().NoError(err)
 
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	err = s.db.CreateSession(token, s.user.ID, expiresAt)
+	err = s.db.CreateSession(token, s.user.ID, 30*24*time.Hour, "")
 	s.Require().NoError(err)
 
 	// Verify session exists
@@ -125,7 +137,110 @@ func (s *SessionTestSuite) TestDeleteSession() {
 	s.Error(err, "expected error after deleting session")
 }
 
+func (s *SessionTestSuite) TestDeleteUserSessions() {
+	other, err := s.db.CreateUser("otheruser", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateSession("first", s.user.ID, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("second", s.user.ID, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("other", other.ID, time.Hour, ""))
+
+	deleted, err := s.db.DeleteUserSessions(s.user.ID)
+	s.Require().NoError(err)
+	s.Equal(int64(2), deleted)
+
+	_, err = s.db.ValidateSession("first")
+	s.Error(err)
+	_, err = s.db.ValidateSession("other")
+	s.NoError(err, "other users stay signed in")
+}
+
+func (s *SessionTestSuite) TestCleanExpiredSessions() {
+	for _, duration := range []time.Duration{-time.Hour, -time.Minute, time.Hour} {
+		token, err := auth.GenerateSessionToken()
+		s.Require().NoError(err)
+		s.Require().NoError(s.db.CreateSession(token, s.user.ID, duration, ""))
+	}
+
+	removed, err := s.db.CleanExpiredSessions()
+	s.Require().NoError(err)
+	s.Equal(int64(2), removed)
+
+	removed, err = s.db.CleanExpiredSessions()
+	s.Require().NoError(err)
+	s.Zero(removed, "the active session is kept")
+}
+
+func (s *SessionTestSuite) TestListSessions() {
+	other, err := s.db.CreateUser("otheruser", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateSession("aaaaaaaaaaaa", s.user.ID, time.Hour, "fingerprint"))
+	s.Require().NoError(s.db.CreateSession("bbbbbbbbbbbb", other.ID, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("cccccccccccc", s.user.ID, -time.Hour, ""))
+
+	sessions, err := s.db.ListSessions(0)
+	s.Require().NoError(err)
+	s.Len(sessions, 2, "expired sessions aren't listed")
+
+	sessions, err = s.db.ListSessions(s.user.ID)
+	s.Require().NoError(err)
+	s.Require().Len(sessions, 1)
+	s.Equal("aaaaaaaa", sessions[0].TokenPrefix)
+	s.Equal("testuser", sessions[0].Username)
+	s.True(sessions[0].Bound)
+}
+
+func (s *SessionTestSuite) TestDeleteSessionsByPrefix() {
+	s.Require().NoError(s.db.CreateSession("abc_1", s.user.ID, time.Hour, ""))
+	s.Require().NoError(s.db.CreateSession("abcx1", s.user.ID, time.Hour, ""))
+
+	deleted, err := s.db.DeleteSessionsByPrefix("abc_")
+	s.Require().NoError(err)
+	s.Equal(int64(1), deleted, "_ is not a wildcard")
+	_, err = s.db.ValidateSession("abcx1")
+	s.NoError(err)
+
+	deleted, err = s.db.DeleteAllSessions()
+	s.Require().NoError(err)
+	s.Equal(int64(1), deleted)
+}
+
 // Test suite runner
 func TestSessionSuite(t *testing.T) {
 	suite.Run(t, new(SessionTestSuite))
 }
+
+// BenchmarkValidateSessionWithInfo compares the prepared session lookup that
+// runs on every authenticated request with the same query prepared each time.
+func BenchmarkValidateSessionWithInfo(b *testing.B) {
+	db := newBenchDB(b)
+	user, err := db.CreateUser("bench", "hash")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.CreateSession("bench-token", user.ID, time.Hour, ""); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("prepared", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := db.ValidateSessionWithInfo("bench-token"); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+	b.Run("unprepared", func(b *testing.B) {
+		var lastActivity, expiresAt time.Time
+		var duration int64
+		var fingerprint sql.NullString
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				row := db.conn.QueryRow(validateSessionQuery, "bench-token")
+				if _, err := scanUser(row, &lastActivity, &expiresAt, &duration, &fingerprint); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	})
+}
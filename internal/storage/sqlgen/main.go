@@ -0,0 +1,404 @@
+// Command sqlgen generates the Go functions that run the SQL queries in
+// internal/storage/queries. It prepares every query against the migrated
+// schema, so a misspelled table or column, a wrong number of parameters, or
+// a result column without a matching field fails generation instead of a
+// request, and the results are scanned without hand-written code.
+//
+// Run it with go generate ./internal/storage. Each query in a .sql file is
+// preceded by comments naming it and giving its Go signature:
+//
+//	-- name: GetPasskey :one models.Passkey
+//	-- params: id string
+//	SELECT id, user_id, name FROM passkeys WHERE id = ?;
+//
+// :exec runs a statement, :execrows also returns how many rows it changed,
+// and :execlastid the ID of the row it inserted. :one returns the first row
+// of the result, :many all of them. A result type from package models is
+// filled by matching the column names to the json tags of its fields, or to
+// their names in snake case; any other type takes a single column.
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"expense-tracker/internal/storage"
+)
+
+// query is one annotated query of a .sql file.
+type query struct {
+	file   string // Base name of the .sql file
+	name   string // Exported name from the annotation, e.g. GetPasskey
+	kind   string // :exec, :execrows, :execlastid, :one, or :many
+	result string // Go type of a row, for :one and :many
+	params []param
+	sql    string
+}
+
+type param struct {
+	name, typ string
+}
+
+var kinds = []string{":exec", ":execrows", ":execlastid", ":one", ":many"}
+
+func main() {
+	dir := flag.String("queries", "queries", "directory of the .sql files")
+	models := flag.String("models", "../models", "directory of package models")
+	out := flag.String("out", "queries_gen.go", "file to write")
+	flag.Parse()
+
+	src, err := run(*dir, *models)
+	if err != nil {
+		log.Fatalf("sqlgen: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("sqlgen: %v", err)
+	}
+}
+
+// run reads and checks the queries in dir and returns the generated code.
+func run(dir, modelsDir string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var queries []query
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		qs, err := parseQueries(filepath.Base(path), string(data))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, qs...)
+	}
+
+	fields, err := loadModels(modelsDir)
+	if err != nil {
+		return nil, err
+	}
+	conn, cleanup, err := openSchema()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	columns := make([][]string, len(queries))
+	var errs []error
+	for i, q := range queries {
+		columns[i], err = checkQuery(conn, q, fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", q.file, q.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return generate(queries, columns, fields)
+}
+
+// parseQueries splits a .sql file into its annotated queries.
+func parseQueries(file, src string) ([]query, error) {
+	var queries []query
+	var cur *query
+	var body strings.Builder
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		cur.sql = strings.TrimSuffix(strings.TrimSpace(body.String()), ";")
+		if cur.sql == "" {
+			return fmt.Errorf("%s: %s has no SQL", file, cur.name)
+		}
+		queries = append(queries, *cur)
+		cur = nil
+		body.Reset()
+		return nil
+	}
+
+	for n, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- name:"):
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			fields := strings.Fields(strings.TrimPrefix(trimmed, "-- name:"))
+			if len(fields) < 2 || !slices.Contains(kinds, fields[1]) {
+				return nil, fmt.Errorf("%s:%d: want -- name: <Name> <%s> [result type]", file, n+1, strings.Join(kinds, "|"))
+			}
+			cur = &query{file: file, name: fields[0], kind: fields[1], result: strings.Join(fields[2:], " ")}
+			if (cur.kind == ":one" || cur.kind == ":many") != (cur.result != "") {
+				return nil, fmt.Errorf("%s:%d: only :one and :many queries have a result type, and they must", file, n+1)
+			}
+		case strings.HasPrefix(trimmed, "-- params:"):
+			if cur == nil || body.Len() > 0 {
+				return nil, fmt.Errorf("%s:%d: -- params must follow -- name", file, n+1)
+			}
+			for _, p := range strings.Split(strings.TrimPrefix(trimmed, "-- params:"), ",") {
+				name, typ, ok := strings.Cut(strings.TrimSpace(p), " ")
+				if !ok {
+					return nil, fmt.Errorf("%s:%d: want -- params: <name> <type>, ...", file, n+1)
+				}
+				cur.params = append(cur.params, param{name: name, typ: strings.TrimSpace(typ)})
+			}
+		case trimmed == "" && cur == nil, strings.HasPrefix(trimmed, "--") && body.Len() == 0:
+			// Blank lines and comments between queries
+		default:
+			if cur == nil {
+				return nil, fmt.Errorf("%s:%d: SQL without a -- name annotation", file, n+1)
+			}
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// loadModels returns the fields of the structs in package models by the
+// column names they match.
+func loadModels(dir string) (map[string]map[string]string, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	structs := map[string]map[string]string{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				spec, ok := n.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return false
+				}
+				fields := map[string]string{}
+				for _, field := range st.Fields.List {
+					for _, name := range field.Names {
+						fields[snakeCase(name.Name)] = name.Name
+						if field.Tag != nil {
+							tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("json")
+							if column, _, _ := strings.Cut(tag, ","); column != "" && column != "-" {
+								fields[column] = name.Name
+							}
+						}
+					}
+				}
+				structs["models."+spec.Name.Name] = fields
+				return false
+			})
+		}
+	}
+	return structs, nil
+}
+
+// snakeCase turns a Go field name into a column name, e.g. UserID into
+// user_id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// openSchema creates a database with the current schema to check queries
+// against.
+func openSchema() (*sql.DB, func(), error) {
+	dir, err := os.MkdirTemp("", "sqlgen")
+	if err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(dir, "schema.db")
+	db, err := storage.NewDB(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	db.Close()
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return conn, func() { conn.Close(); os.RemoveAll(dir) }, nil
+}
+
+// checkQuery prepares q against the schema and returns its result columns.
+func checkQuery(conn *sql.DB, q query, fields map[string]map[string]string) ([]string, error) {
+	if n := countPlaceholders(q.sql); n != len(q.params) {
+		return nil, fmt.Errorf("has %d placeholders but %d params", n, len(q.params))
+	}
+	stmt, err := conn.Prepare(q.sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	if q.result == "" {
+		return nil, nil
+	}
+
+	// Run the query on the empty database to learn its columns, undoing
+	// whatever it changes, e.g. with UPDATE ... RETURNING
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	rows, err := tx.Stmt(stmt).Query(make([]any, len(q.params))...)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(q.result, "models.") {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("returns %d columns into %s, want 1", len(columns), q.result)
+		}
+		return columns, nil
+	}
+	structFields, ok := fields[q.result]
+	if !ok {
+		return nil, fmt.Errorf("unknown result type %s", q.result)
+	}
+	for _, c := range columns {
+		if _, ok := structFields[c]; !ok {
+			return nil, fmt.Errorf("column %s has no field in %s, rename it with AS", c, q.result)
+		}
+	}
+	return columns, nil
+}
+
+// countPlaceholders counts the ? placeholders outside of string literals.
+func countPlaceholders(sql string) int {
+	n := 0
+	var quote rune
+	for _, r := range sql {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+		}
+	}
+	return n
+}
+
+// generate writes the Go code for the checked queries.
+func generate(queries []query, columns [][]string, fields map[string]map[string]string) ([]byte, error) {
+	var b bytes.Buffer
+	var usesModels, usesTime bool
+	for _, q := range queries {
+		usesModels = usesModels || strings.HasPrefix(q.result, "models.")
+		usesTime = usesTime || strings.Contains(q.result, "time.")
+		for _, p := range q.params {
+			usesTime = usesTime || strings.Contains(p.typ, "time.")
+			usesModels = usesModels || strings.Contains(p.typ, "models.")
+		}
+	}
+
+	b.WriteString("// Code generated by sqlgen from queries/*.sql. DO NOT EDIT.\n\npackage storage\n\n")
+	if usesModels || usesTime {
+		b.WriteString("import (\n")
+		if usesTime {
+			b.WriteString("\"time\"\n\n")
+		}
+		if usesModels {
+			b.WriteString("\"expense-tracker/internal/models\"\n")
+		}
+		b.WriteString(")\n\n")
+	}
+
+	for i, q := range queries {
+		fn := lowerFirst(q.name)
+		constName := fn + "Query"
+		fmt.Fprintf(&b, "const %s = `%s`\n\n", constName, q.sql)
+
+		var params, args []string
+		for _, p := range q.params {
+			params = append(params, p.name+" "+p.typ)
+			args = append(args, p.name)
+		}
+		call := constName
+		if len(args) > 0 {
+			call += ", " + strings.Join(args, ", ")
+		}
+		sig := strings.Join(append([]string{"q querier"}, params...), ", ")
+
+		var dest []string
+		for _, c := range columns[i] {
+			if f, ok := fields[q.result][c]; ok {
+				dest = append(dest, "&v."+f)
+			} else {
+				dest = append(dest, "&v")
+			}
+		}
+		scan := strings.Join(dest, ", ")
+
+		fmt.Fprintf(&b, "// %s runs %s from queries/%s.\n", fn, q.name, q.file)
+		switch q.kind {
+		case ":exec":
+			fmt.Fprintf(&b, "func %s(%s) error {\n_, err := q.Exec(%s)\nreturn err\n}\n\n", fn, sig, call)
+		case ":execrows", ":execlastid":
+			method := "RowsAffected"
+			if q.kind == ":execlastid" {
+				method = "LastInsertId"
+			}
+			fmt.Fprintf(&b, "func %s(%s) (int64, error) {\nresult, err := q.Exec(%s)\nif err != nil {\nreturn 0, err\n}\nreturn result.%s()\n}\n\n", fn, sig, call, method)
+		case ":one":
+			if strings.HasPrefix(q.result, "models.") {
+				fmt.Fprintf(&b, "func %s(%s) (*%s, error) {\nvar v %s\nif err := q.QueryRow(%s).Scan(%s); err != nil {\nreturn nil, err\n}\nreturn &v, nil\n}\n\n", fn, sig, q.result, q.result, call, scan)
+			} else {
+				fmt.Fprintf(&b, "func %s(%s) (%s, error) {\nvar v %s\nerr := q.QueryRow(%s).Scan(%s)\nreturn v, err\n}\n\n", fn, sig, q.result, q.result, call, scan)
+			}
+		case ":many":
+			fmt.Fprintf(&b, "func %s(%s) ([]%s, error) {\nrows, err := q.Query(%s)\nif err != nil {\nreturn nil, err\n}\ndefer rows.Close()\n\nvar items []%s\nfor rows.Next() {\nvar v %s\nif err := rows.Scan(%s); err != nil {\nreturn nil, err\n}\nitems = append(items, v)\n}\nreturn items, rows.Err()\n}\n\n", fn, sig, q.result, call, q.result, q.result, scan)
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedCodeIsUpToDate(t *testing.T) {
+	want, err := run("../queries", "../../models")
+	require.NoError(t, err)
+	have, err := os.ReadFile("../queries_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(have), "run go generate ./internal/storage")
+}
+
+func TestParseQueries(t *testing.T) {
+	queries, err := parseQueries("test.sql", `-- Passkeys
+
+-- name: GetPasskey :one models.Passkey
+-- params: id string, userID int64
+SELECT id, name
+FROM passkeys
+WHERE id = ? AND user_id = ?;
+
+-- name: DeletePasskeys :execrows
+DELETE FROM passkeys;
+`)
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, query{
+		file:   "test.sql",
+		name:   "GetPasskey",
+		kind:   ":one",
+		result: "models.Passkey",
+		params: []param{{"id", "string"}, {"userID", "int64"}},
+		sql:    "SELECT id, name\nFROM passkeys\nWHERE id = ? AND user_id = ?",
+	}, queries[0])
+	assert.Equal(t, ":execrows", queries[1].kind)
+	assert.Empty(t, queries[1].params)
+
+	for name, src := range map[string]string{
+		"unknown kind":          "-- name: Get :first int64\nSELECT 1;",
+		"missing result type":   "-- name: Get :one\nSELECT 1;",
+		"result type for exec":  "-- name: Delete :exec int64\nDELETE FROM passkeys;",
+		"params without a type": "-- name: Delete :exec\n-- params: id\nDELETE FROM passkeys WHERE id = ?;",
+		"no annotation":         "SELECT 1;",
+		"no SQL":                "-- name: Get :one int64\n",
+	} {
+		_, err := parseQueries("test.sql", src)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCheckQueries(t *testing.T) {
+	for name, src := range map[string]string{
+		"unknown column":       "-- name: Get :one int64\nSELECT nonsense FROM passkeys;",
+		"unknown table":        "-- name: Delete :exec\nDELETE FROM nowhere;",
+		"missing param":        "-- name: Delete :exec\nDELETE FROM passkeys WHERE id = ?;",
+		"extra param":          "-- name: Delete :exec\n-- params: id string\nDELETE FROM passkeys;",
+		"several columns":      "-- name: Get :one int64\nSELECT id, user_id FROM passkeys;",
+		"column without field": "-- name: Get :one models.Passkey\nSELECT id, public_key AS key FROM passkeys;",
+		"unknown model":        "-- name: Get :one models.Nonsense\nSELECT id FROM passkeys;",
+	} {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "test.sql"), []byte(src), 0o644))
+		_, err := run(dir, "../../models")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"ID":         "id",
+		"UserID":     "user_id",
+		"PublicKey":  "public_key",
+		"LastUsedAt": "last_used_at",
+		"HTTPServer": "http_server",
+	} {
+		assert.Equal(t, want, snakeCase(name), name)
+	}
+}
+
+func TestCountPlaceholders(t *testing.T) {
+	assert.Equal(t, 2, countPlaceholders("SELECT * FROM t WHERE a = ? AND b = '?' AND c = ?"))
+}
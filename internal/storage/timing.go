@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Queries are timed by wrapping the SQLite driver, so statements run in
+// transactions count as well. Each is named after the exported DB method
+// that ran it, e.g. FilterExpenses, and added to queryMetrics and to the
+// QueryStats of its context, if any.
+
+// queryMetrics holds how many queries each DB method ran and how long they
+// took, served by the metrics listener as db_queries.
+var queryMetrics = expvar.NewMap("db_queries")
+
+// queryMetricsMu keeps two queries from adding the same name at once.
+var queryMetricsMu sync.Mutex
+
+// queryMetric is a DB method's entry in queryMetrics.
+type queryMetric struct {
+	mu         sync.Mutex
+	count      int64
+	total, max time.Duration
+}
+
+func (m *queryMetric) add(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count++
+	m.total += d
+	m.max = max(m.max, d)
+}
+
+func (m *queryMetric) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, _ := json.Marshal(map[string]any{
+		"count":    m.count,
+		"total_ms": milliseconds(m.total),
+		"max_ms":   milliseconds(m.max),
+	})
+	return string(data)
+}
+
+// milliseconds returns d in milliseconds to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// recordQuery adds a query that took d to queryMetrics.
+func recordQuery(name string, d time.Duration) {
+	m, ok := queryMetrics.Get(name).(*queryMetric)
+	if !ok {
+		queryMetricsMu.Lock()
+		if m, ok = queryMetrics.Get(name).(*queryMetric); !ok {
+			m = &queryMetric{}
+			queryMetrics.Set(name, m)
+		}
+		queryMetricsMu.Unlock()
+	}
+	m.add(d)
+}
+
+// QueryTiming is how many queries a DB method ran and how long they took.
+type QueryTiming struct {
+	Name  string
+	Count int
+	Total time.Duration
+}
+
+// QueryStats adds up the queries run under a context, e.g. to see where a
+// slow request spent its time. It is safe for concurrent use.
+type QueryStats struct {
+	mu     sync.Mutex
+	byName map[string]*QueryTiming
+}
+
+type queryStatsKey struct{}
+
+// WithQueryStats returns a context whose queries, run with a DB from
+// WithContext, are added up in the returned QueryStats.
+func WithQueryStats(ctx context.Context) (context.Context, *QueryStats) {
+	stats := &QueryStats{byName: map[string]*QueryTiming{}}
+	return context.WithValue(ctx, queryStatsKey{}, stats), stats
+}
+
+func (s *QueryStats) add(name string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.byName[name]
+	if !ok {
+		t = &QueryTiming{Name: name}
+		s.byName[name] = t
+	}
+	t.Count++
+	t.Total += d
+}
+
+// Total returns how many queries ran and how long they took together.
+func (s *QueryStats) Total() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int
+	var total time.Duration
+	for _, t := range s.byName {
+		count += t.Count
+		total += t.Total
+	}
+	return count, total
+}
+
+// Timings returns the queries by DB method, longest total first.
+func (s *QueryStats) Timings() []QueryTiming {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timings := make([]QueryTiming, 0, len(s.byName))
+	for _, t := range s.byName {
+		timings = append(timings, *t)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].Total != timings[j].Total {
+			return timings[i].Total > timings[j].Total
+		}
+		return timings[i].Name < timings[j].Name
+	})
+	return timings
+}
+
+// dbMethodPrefix starts the names of DB's methods in stack traces.
+var dbMethodPrefix = reflect.TypeFor[DB]().PkgPath() + ".(*DB)."
+
+// queryName names the query being run after the exported DB method running
+// it, the outermost one when they call each other.
+func queryName() string {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	name := "other"
+	for {
+		frame, more := frames.Next()
+		if method, ok := strings.CutPrefix(frame.Function, dbMethodPrefix); ok {
+			method, _, _ = strings.Cut(method, ".") // Closures are named after their method
+			if method != "" && unicode.IsUpper(rune(method[0])) {
+				name = method
+			}
+		}
+		if !more {
+			return name
+		}
+	}
+}
+
+// timedConnector opens connections whose queries are timed.
+type timedConnector struct {
+	driver driver.Driver
+	name   string
+}
+
+func (c timedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.name)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn}, nil
+}
+
+func (c timedConnector) Driver() driver.Driver { return c.driver }
+
+// timedConn times the queries run on a connection of the SQLite driver,
+// which implements the context variants of the driver interfaces.
+type timedConn struct {
+	driver.Conn
+	// The context of the transaction in progress, whose statements run
+	// without one
+	txCtx context.Context
+}
+
+// done records a query started at start, under the QueryStats of ctx or
+// of the transaction in progress.
+func (c *timedConn) done(ctx context.Context, name string, start time.Time) {
+	d := time.Since(start)
+	recordQuery(name, d)
+	stats, ok := ctx.Value(queryStatsKey{}).(*QueryStats)
+	if !ok && c.txCtx != nil {
+		stats, ok = c.txCtx.Value(queryStatsKey{}).(*QueryStats)
+	}
+	if ok {
+		stats.add(name, d)
+	}
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{Stmt: stmt, conn: c}, nil
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.txCtx = ctx
+	return timedTx{Tx: tx, conn: c}, nil
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	name, start := queryName(), time.Now()
+	result, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.done(ctx, name, start)
+	}
+	return result, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	name, start := queryName(), time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		if err != driver.ErrSkip {
+			c.done(ctx, name, start)
+		}
+		return nil, err
+	}
+	return &timedRows{Rows: rows, done: func() { c.done(ctx, name, start) }}, nil
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *timedConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// timedTx ends the transaction of a timedConn.
+type timedTx struct {
+	driver.Tx
+	conn *timedConn
+}
+
+func (tx timedTx) Commit() error {
+	tx.conn.txCtx = nil
+	return tx.Tx.Commit()
+}
+
+func (tx timedTx) Rollback() error {
+	tx.conn.txCtx = nil
+	return tx.Tx.Rollback()
+}
+
+// timedStmt times the queries of a prepared statement.
+type timedStmt struct {
+	driver.Stmt
+	conn *timedConn
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	name, start := queryName(), time.Now()
+	defer s.conn.done(ctx, name, start)
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	name, start := queryName(), time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		s.conn.done(ctx, name, start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, done: func() { s.conn.done(ctx, name, start) }}, nil
+}
+
+// timedRows finishes timing a query once its rows are read, since SQLite
+// finds them as they're read.
+type timedRows struct {
+	driver.Rows
+	done func()
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	if r.done != nil {
+		r.done()
+		r.done = nil
+	}
+	return err
+}
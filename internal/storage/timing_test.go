@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// TimingTestSuite provides a test suite for timing queries
+type TimingTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *TimingTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *TimingTestSuite) TearDownTest() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *TimingTestSuite) TestQueryStats() {
+	ctx, stats := WithQueryStats(context.Background())
+	db := s.db.WithContext(ctx)
+	s.Require().NoError(db.CreateExpense(1, "Coffee", "Eating Out", time.Now(), 1))
+	_, err := db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	_, err = db.FilterExpenses(ExpenseFilter{Query: "coffee"})
+	s.Require().NoError(err)
+	_, err = s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+
+	byName := map[string]QueryTiming{}
+	for _, t := range stats.Timings() {
+		byName[t.Name] = t
+		s.Positive(t.Total, t.Name)
+	}
+	s.Equal(2, byName["FilterExpenses"].Count, "only those run under the context")
+	s.Greater(byName["CreateExpense"].Count, 1, "each statement of the transaction, named after the outermost method")
+	s.NotContains(byName, "InsertExpense")
+
+	count, total := stats.Total()
+	s.Equal(byName["FilterExpenses"].Count+byName["CreateExpense"].Count, count)
+	s.Positive(total)
+}
+
+func (s *TimingTestSuite) TestMetrics() {
+	_, err := s.db.ListCategories()
+	s.Require().NoError(err)
+
+	metric, ok := queryMetrics.Get("ListCategories").(*queryMetric)
+	s.Require().True(ok)
+	var served struct {
+		Count   int64   `json:"count"`
+		TotalMS float64 `json:"total_ms"`
+		MaxMS   float64 `json:"max_ms"`
+	}
+	s.Require().NoError(json.Unmarshal([]byte(metric.String()), &served))
+	s.Positive(served.Count)
+	s.GreaterOrEqual(served.TotalMS, served.MaxMS)
+}
+
+// TestTimingTestSuite runs the query timing test suite
+func TestTimingTestSuite(t *testing.T) {
+	suite.Run(t, new(TimingTestSuite))
+}
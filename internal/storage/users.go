@@ -1,51 +1,168 @@
 package storage
 
-import "expense-tracker/internal/models"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"expense-tracker/internal/models"
+)
 
 // CreateUser creates a new user with the given username and password hash.
+// The first user becomes an admin, everyone after a member.
 func (db *DB) CreateUser(username, passwordHash string) (*models.User, error) {
-	result, err := db.conn.Exec(
-		"INSERT INTO users (username, password_hash) VALUES (?, ?)",
-		username, passwordHash,
-	)
+	id, err := createUser(db.conn, username, passwordHash)
 	if err != nil {
 		return nil, err
 	}
+	return db.GetUserByID(id)
+}
+
+// NewUser is a user to create with CreateUsers.
+type NewUser struct {
+	Username      string
+	PasswordHash  string
+	Role          string // Empty for the default of CreateUser
+	Email         string // Optional
+	EmailVerified bool   // Whether Email counts as verified, e.g. set by an operator
+}
 
-	id, err := result.LastInsertId()
+// CreateUsers creates several users at once, in order, or none of them if
+// any fails. Without a role, the first user ever becomes an admin like with
+// CreateUser. A verified email address already in use fails with
+// ErrEmailTaken.
+func (db *DB) CreateUsers(users []NewUser) ([]*models.User, error) {
+	tx, err := db.conn.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	return db.GetUserByID(id)
+	ids := make([]int64, 0, len(users))
+	for _, u := range users {
+		id, err := createUser(tx, u.Username, u.PasswordHash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", u.Username, err)
+		}
+		if u.Role != "" {
+			if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", u.Role, id); err != nil {
+				return nil, fmt.Errorf("%s: %w", u.Username, err)
+			}
+		}
+		if u.Email != "" {
+			var verifiedAt sql.NullTime
+			if u.EmailVerified {
+				verifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+			if _, err := tx.Exec("UPDATE users SET email = ?, email_verified_at = ? WHERE id = ?", u.Email, verifiedAt, id); err != nil {
+				if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+					return nil, fmt.Errorf("%s: %w", u.Username, ErrEmailTaken)
+				}
+				return nil, fmt.Errorf("%s: %w", u.Username, err)
+			}
+		}
+		ids = append(ids, id)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	created := make([]*models.User, 0, len(ids))
+	for _, id := range ids {
+		user, err := db.GetUserByID(id)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, user)
+	}
+	return created, nil
+}
+
+// createUser inserts a user, on its own or within a transaction.
+func createUser(ex execer, username, passwordHash string) (int64, error) {
+	result, err := ex.Exec(
+		`INSERT INTO users (username, password_hash, role)
+		VALUES (?, ?, CASE WHEN EXISTS (SELECT 1 FROM users) THEN ? ELSE ? END)`,
+		username, passwordHash, models.RoleMember, models.RoleAdmin,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
 }
 
 // GetUserByID retrieves a user by ID.
 func (db *DB) GetUserByID(id int64) (*models.User, error) {
-	row := db.conn.QueryRow(
-		"SELECT id, username, password_hash, created_at FROM users WHERE id = ?",
-		id,
-	)
+	row := db.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id)
+	return scanUser(row)
+}
 
+// GetUserByUsername retrieves a user by username.
+func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	row := db.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username)
+	return scanUser(row)
+}
+
+// userColumns are the users columns read by scanUser.
+const userColumns = "users.id, users.username, users.password_hash, users.created_at, users.email, users.email_verified_at, users.role, users.delete_after, users.disabled_at"
+
+// scanUser reads a user from a row selecting userColumns, followed by any
+// extra destinations.
+func scanUser(row interface{ Scan(...any) error }, extra ...any) (*models.User, error) {
 	var u models.User
-	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt); err != nil {
+	var email sql.NullString
+	var verifiedAt, deleteAfter, disabledAt sql.NullTime
+	dest := append([]any{&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt, &email, &verifiedAt, &u.Role, &deleteAfter, &disabledAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
 		return nil, err
 	}
+	u.Email = email.String
+	u.EmailVerified = verifiedAt.Valid
+	u.Disabled = disabledAt.Valid
+	if deleteAfter.Valid {
+		u.DeleteAfter = &deleteAfter.Time
+	}
 	return &u, nil
 }
 
-// GetUserByUsername retrieves a user by username.
-func (db *DB) GetUserByUsername(username string) (*models.User, error) {
-	row := db.conn.QueryRow(
-		"SELECT id, username, password_hash, created_at FROM users WHERE username = ?",
-		username,
-	)
+// UserSummary is a user with figures about their activity, for listing
+// accounts.
+type UserSummary struct {
+	models.User
+	LastLogin *time.Time // Last successful login, nil if they never logged in
+	Expenses  int        // Current and archived expenses
+}
 
-	var u models.User
-	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt); err != nil {
+// ListUsers returns every user with their activity, oldest account first.
+func (db *DB) ListUsers() ([]UserSummary, error) {
+	rows, err := db.conn.Query(
+		`SELECT ` + userColumns + `,
+			(SELECT created_at FROM login_events WHERE user_id = users.id AND success ORDER BY created_at DESC LIMIT 1),
+			(SELECT COUNT(*) FROM all_expenses WHERE user_id = users.id)
+		FROM users ORDER BY id`,
+	)
+	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var lastLogin sql.NullTime
+		var expenses int
+		user, err := scanUser(rows, &lastLogin, &expenses)
+		if err != nil {
+			return nil, err
+		}
+		summary := UserSummary{User: *user, Expenses: expenses}
+		if lastLogin.Valid {
+			summary.LastLogin = &lastLogin.Time
+		}
+		users = append(users, summary)
+	}
+	return users, rows.Err()
 }
 
 // UserCount returns the number of users in the database.
@@ -54,3 +171,167 @@ func (db *DB) UserCount() (int, error) {
 	err := db.conn.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
 	return count, err
 }
+
+// SetRole changes a user's role to models.RoleAdmin or models.RoleMember.
+func (db *DB) SetRole(userID int64, role string) error {
+	_, err := db.conn.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID)
+	return err
+}
+
+// LoginLockout holds a user's failed login state.
+type LoginLockout struct {
+	FailedLogins int
+	LockedUntil  time.Time // Zero when the account has never been locked
+}
+
+// GetLoginLockout retrieves the failed login state of a user.
+func (db *DB) GetLoginLockout(userID int64) (*LoginLockout, error) {
+	var l LoginLockout
+	var lockedUntil sql.NullTime
+	err := db.conn.QueryRow(
+		"SELECT failed_logins, locked_until FROM users WHERE id = ?",
+		userID,
+	).Scan(&l.FailedLogins, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	l.LockedUntil = lockedUntil.Time
+	return &l, nil
+}
+
+// RecordFailedLogin increments a user's consecutive failed login count and
+// returns the new count.
+func (db *DB) RecordFailedLogin(userID int64) (int, error) {
+	var failures int
+	err := db.conn.QueryRow(
+		"UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ? RETURNING failed_logins",
+		userID,
+	).Scan(&failures)
+	return failures, err
+}
+
+// LockUser blocks logins for a user until the given time.
+func (db *DB) LockUser(userID int64, until time.Time) error {
+	_, err := db.conn.Exec("UPDATE users SET locked_until = ? WHERE id = ?", until, userID)
+	return err
+}
+
+// ResetFailedLogins clears a user's failed login count and any lock, e.g.
+// after a successful login.
+func (db *DB) ResetFailedLogins(userID int64) error {
+	_, err := db.conn.Exec("UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = ?", userID)
+	return err
+}
+
+// UnlockUser clears the failed login count and lock of a user by username.
+// It returns sql.ErrNoRows if no such user exists.
+func (db *DB) UnlockUser(username string) error {
+	result, err := db.conn.Exec(
+		"UPDATE users SET failed_logins = 0, locked_until = NULL WHERE username = ?",
+		username,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetPassword replaces a user's password hash and lifts any login lockout,
+// since the old failures were against the old password.
+func (db *DB) SetPassword(userID int64, passwordHash string) error {
+	_, err := db.conn.Exec(
+		"UPDATE users SET password_hash = ?, failed_logins = 0, locked_until = NULL WHERE id = ?",
+		passwordHash, userID,
+	)
+	return err
+}
+
+// ErrUsernameTaken is returned by RenameUser when another account has the
+// username.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// RenameUser changes a user's username. Usernames differing only in case
+// from another account's are taken too, so nobody can pass for someone
+// else. Sessions and passkeys belong to the account, not the name, so they
+// keep working. It returns sql.ErrNoRows if the user doesn't exist.
+func (db *DB) RenameUser(userID int64, username string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var taken bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM users WHERE username = ? COLLATE NOCASE AND id != ?)",
+		username, userID,
+	).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+	n, err := rowsAffected(tx.Exec("UPDATE users SET username = ? WHERE id = ?", username, userID))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// ErrEmailTaken is returned when an email address is already verified on
+// another account.
+var ErrEmailTaken = errors.New("email address is already in use")
+
+// SetEmail changes a user's email address. The new address is unverified
+// until VerifyEmail is called for it.
+func (db *DB) SetEmail(userID int64, email string) error {
+	var taken bool
+	if err := db.conn.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM users WHERE email = ? COLLATE NOCASE AND email_verified_at IS NOT NULL AND id != ?)",
+		email, userID,
+	).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+	_, err := db.conn.Exec("UPDATE users SET email = ?, email_verified_at = NULL WHERE id = ?", email, userID)
+	return err
+}
+
+// VerifyEmail marks a user's email address as verified. It returns
+// sql.ErrNoRows if the user's address is no longer email, and ErrEmailTaken
+// if another account verified the address first.
+func (db *DB) VerifyEmail(userID int64, email string) error {
+	result, err := db.conn.Exec(
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ? AND email = ?",
+		time.Now(), userID, email,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
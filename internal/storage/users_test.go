@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
 	"expense-tracker/internal/auth"
+	"expense-tracker/internal/models"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -54,6 +57,44 @@ func (s *UserTestSuite) TestCreateUserDuplicateUsername() {
 	s.Error(err, "expected error when creating user with duplicate username")
 }
 
+func (s *UserTestSuite) TestCreateUsers() {
+	users, err := s.db.CreateUsers([]NewUser{
+		{Username: "alice", PasswordHash: "hash1"},
+		{Username: "bob", PasswordHash: "hash2"},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(users, 2)
+	s.Equal("alice", users[0].Username)
+	s.True(users[0].IsAdmin(), "the first user is an admin")
+	s.Equal("bob", users[1].Username)
+	s.False(users[1].IsAdmin())
+
+	// A clash anywhere creates none of them
+	_, err = s.db.CreateUsers([]NewUser{
+		{Username: "carol", PasswordHash: "hash3"},
+		{Username: "alice", PasswordHash: "hash4"},
+	})
+	s.Error(err)
+	_, err = s.db.GetUserByUsername("carol")
+	s.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (s *UserTestSuite) TestCreateUsersWithRoleAndEmail() {
+	users, err := s.db.CreateUsers([]NewUser{
+		{Username: "alice", PasswordHash: "hash1", Role: models.RoleMember, Email: "alice@example.com", EmailVerified: true},
+		{Username: "bob", PasswordHash: "hash2", Role: models.RoleAdmin, Email: "bob@example.com"},
+	})
+	s.Require().NoError(err)
+	s.False(users[0].IsAdmin(), "an explicit role beats the first user rule")
+	s.Equal("alice@example.com", users[0].Email)
+	s.True(users[0].EmailVerified)
+	s.True(users[1].IsAdmin())
+	s.False(users[1].EmailVerified)
+
+	_, err = s.db.CreateUsers([]NewUser{{Username: "carol", PasswordHash: "hash3", Email: "ALICE@example.com", EmailVerified: true}})
+	s.ErrorIs(err, ErrEmailTaken)
+}
+
 func (s *UserTestSuite) TestGetUserByID() {
 	passwordHash, err := auth.HashPassword("testpassword")
 	s.Require().NoError(err)
@@ -132,6 +173,150 @@ func (s *UserTestSuite) TestUserCount() {
 	s.Equal(3, count)
 }
 
+func (s *UserTestSuite) TestListUsers() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", time.Now(), alice.ID))
+	s.Require().NoError(s.db.CreateExpense(20, "Bus", "Transport", time.Now(), alice.ID))
+	s.Require().NoError(s.db.RecordLogin(&alice.ID, "alice", "192.0.2.1", "", true))
+	s.Require().NoError(s.db.RecordLogin(&bob.ID, "bob", "192.0.2.1", "", false))
+
+	users, err := s.db.ListUsers()
+	s.Require().NoError(err)
+	s.Require().Len(users, 2)
+	s.Equal("alice", users[0].Username)
+	s.Equal(2, users[0].Expenses)
+	s.Require().NotNil(users[0].LastLogin)
+	s.WithinDuration(time.Now(), *users[0].LastLogin, time.Minute)
+	s.Equal("bob", users[1].Username)
+	s.Zero(users[1].Expenses)
+	s.Nil(users[1].LastLogin, "failed logins don't count")
+}
+
+func (s *UserTestSuite) TestLoginLockout() {
+	user, err := s.db.CreateUser("johndoe", "hash")
+	s.Require().NoError(err)
+
+	lockout, err := s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Zero(lockout.FailedLogins)
+	s.True(lockout.LockedUntil.IsZero())
+
+	for want := 1; want <= 3; want++ {
+		failures, err := s.db.RecordFailedLogin(user.ID)
+		s.Require().NoError(err)
+		s.Equal(want, failures)
+	}
+
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	s.Require().NoError(s.db.LockUser(user.ID, until))
+	lockout, err = s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Equal(3, lockout.FailedLogins)
+	s.True(lockout.LockedUntil.Equal(until))
+
+	s.Require().NoError(s.db.ResetFailedLogins(user.ID))
+	lockout, err = s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Zero(lockout.FailedLogins)
+	s.True(lockout.LockedUntil.IsZero())
+}
+
+func (s *UserTestSuite) TestUnlockUser() {
+	user, err := s.db.CreateUser("johndoe", "hash")
+	s.Require().NoError(err)
+	_, err = s.db.RecordFailedLogin(user.ID)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.LockUser(user.ID, time.Now().Add(time.Hour)))
+
+	s.Require().NoError(s.db.UnlockUser("johndoe"))
+	lockout, err := s.db.GetLoginLockout(user.ID)
+	s.Require().NoError(err)
+	s.Zero(lockout.FailedLogins)
+	s.True(lockout.LockedUntil.IsZero())
+
+	s.ErrorIs(s.db.UnlockUser("nobody"), sql.ErrNoRows)
+}
+
+func (s *UserTestSuite) TestSetAndVerifyEmail() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	s.Empty(alice.Email, "new users have no email")
+	s.False(alice.EmailVerified)
+
+	s.Require().NoError(s.db.SetEmail(alice.ID, "alice@example.com"))
+	user, err := s.db.GetUserByID(alice.ID)
+	s.Require().NoError(err)
+	s.Equal("alice@example.com", user.Email)
+	s.False(user.EmailVerified)
+
+	s.ErrorIs(s.db.VerifyEmail(alice.ID, "old@example.com"), sql.ErrNoRows, "only the current address can be verified")
+	s.Require().NoError(s.db.VerifyEmail(alice.ID, "alice@example.com"))
+	user, err = s.db.GetUserByUsername("alice")
+	s.Require().NoError(err)
+	s.True(user.EmailVerified)
+
+	// Changing the address requires verifying it again
+	s.Require().NoError(s.db.SetEmail(alice.ID, "alice@work.example.com"))
+	user, err = s.db.GetUserByID(alice.ID)
+	s.Require().NoError(err)
+	s.False(user.EmailVerified)
+}
+
+func (s *UserTestSuite) TestVerifiedEmailIsUnique() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	bob, err := s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+
+	// Both may claim the address until one of them verifies it
+	s.Require().NoError(s.db.SetEmail(alice.ID, "shared@example.com"))
+	s.Require().NoError(s.db.SetEmail(bob.ID, "Shared@example.com"))
+	s.Require().NoError(s.db.VerifyEmail(bob.ID, "Shared@example.com"))
+
+	s.ErrorIs(s.db.VerifyEmail(alice.ID, "shared@example.com"), ErrEmailTaken)
+	s.ErrorIs(s.db.SetEmail(alice.ID, "SHARED@example.com"), ErrEmailTaken)
+}
+
+func (s *UserTestSuite) TestRenameUser() {
+	alice, err := s.db.CreateUser("alice", "hash")
+	s.Require().NoError(err)
+	_, err = s.db.CreateUser("bob", "hash")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.CreateSession("token", alice.ID, time.Hour, ""))
+
+	s.Require().NoError(s.db.RenameUser(alice.ID, "alice.b"))
+	user, err := s.db.GetUserByUsername("alice.b")
+	s.Require().NoError(err)
+	s.Equal(alice.ID, user.ID)
+	_, err = s.db.GetUserByUsername("alice")
+	s.ErrorIs(err, sql.ErrNoRows, "the old name is free")
+	user, err = s.db.ValidateSession("token")
+	s.Require().NoError(err, "still signed in")
+	s.Equal("alice.b", user.Username)
+
+	s.ErrorIs(s.db.RenameUser(alice.ID, "bob"), ErrUsernameTaken)
+	s.ErrorIs(s.db.RenameUser(alice.ID, "Bob"), ErrUsernameTaken, "in any case")
+	s.NoError(s.db.RenameUser(alice.ID, "Alice.B"), "their own in another case")
+	s.ErrorIs(s.db.RenameUser(404, "carol"), sql.ErrNoRows)
+}
+
+func (s *UserTestSuite) TestSecret() {
+	first, err := s.db.Secret("signing_key")
+	s.Require().NoError(err)
+	s.Len(first, secretLength)
+
+	again, err := s.db.Secret("signing_key")
+	s.Require().NoError(err)
+	s.Equal(first, again, "a secret is generated once")
+
+	other, err := s.db.Secret("other")
+	s.Require().NoError(err)
+	s.NotEqual(first, other)
+}
+
 // Test suite runner
 func TestUserSuite(t *testing.T) {
 	suite.Run(t, new(UserTestSuite))
@@ -0,0 +1,19 @@
+package storage
+
+import "time"
+
+// DataVersion identifies the state of the expenses, archived expenses, and
+// categories. Every change to them increases Version, so pages built from
+// them can be cached for as long as it stays the same.
+type DataVersion struct {
+	Version   int64
+	ChangedAt time.Time // When Version last increased
+}
+
+// DataVersion returns the current data version. It is a single-row lookup,
+// cheap enough to run before every page.
+func (db *DB) DataVersion() (DataVersion, error) {
+	var v DataVersion
+	err := db.conn.QueryRowPrepared("SELECT version, changed_at FROM data_version WHERE id = 1").Scan(&v.Version, &v.ChangedAt)
+	return v, err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// VersionTestSuite provides a test suite for the data version
+type VersionTestSuite struct {
+	suite.Suite
+	db *DB
+}
+
+// SetupTest runs before each test
+func (s *VersionTestSuite) SetupTest() {
+	db, err := NewDB(":memory:")
+	s.Require().NoError(err, "failed to create test database")
+	s.db = db
+}
+
+// TearDownTest runs after each test
+func (s *VersionTestSuite) TearDownTest() {
+	s.db.Close()
+}
+
+// changes returns how much the data version moves while do runs.
+func (s *VersionTestSuite) changes(do func()) int64 {
+	before, err := s.db.DataVersion()
+	s.Require().NoError(err)
+	do()
+	after, err := s.db.DataVersion()
+	s.Require().NoError(err)
+	s.False(after.ChangedAt.Before(before.ChangedAt))
+	return after.Version - before.Version
+}
+
+func (s *VersionTestSuite) TestWritesMoveTheVersion() {
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Positive(s.changes(func() {
+		s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", day, 1))
+	}))
+	expenses, err := s.db.FilterExpenses(ExpenseFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(expenses, 1)
+
+	s.Positive(s.changes(func() {
+		expenses[0].Amount = 12
+		s.Require().NoError(s.db.UpdateExpense(&expenses[0]))
+	}))
+	s.Positive(s.changes(func() {
+		_, err := s.db.RenameCategory("Eating Out", "Restaurants")
+		s.Require().NoError(err)
+	}))
+	s.Positive(s.changes(func() {
+		_, err := s.db.ArchiveExpenses(day.AddDate(0, 1, 0))
+		s.Require().NoError(err)
+	}))
+	s.Positive(s.changes(func() {
+		s.Require().NoError(s.db.AddCategory("Pets"))
+	}))
+}
+
+func (s *VersionTestSuite) TestReadsKeepTheVersion() {
+	s.Require().NoError(s.db.CreateExpense(10, "Coffee", "Eating Out", time.Now(), 1))
+	s.Zero(s.changes(func() {
+		_, err := s.db.FilterExpenses(ExpenseFilter{})
+		s.Require().NoError(err)
+		_, err = s.db.GetMonthStats(0, 2025, 3)
+		s.Require().NoError(err)
+		_, err = s.db.CreateUser("someone", "hash")
+		s.Require().NoError(err)
+	}))
+}
+
+// TestVersionTestSuite runs the data version test suite
+func TestVersionTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionTestSuite))
+}
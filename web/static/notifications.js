@@ -0,0 +1,51 @@
+// Subscribing the browser to push notifications. The server sends its public
+// key base64url encoded and takes the subscription as the browser gives it.
+(function() {
+    if (!('serviceWorker' in navigator) || !window.PushManager) return;
+
+    function toBytes(s) {
+        const b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+        return Uint8Array.from(atob(b64), c => c.charCodeAt(0));
+    }
+
+    // The worker that shows the notifications, once it's running
+    async function activeWorker() {
+        const registration = await navigator.serviceWorker.register('/static/sw.js');
+        const worker = registration.installing || registration.waiting;
+        if (worker && !registration.active) {
+            await new Promise(resolve => worker.addEventListener('statechange', () => {
+                if (worker.state === 'activated') resolve();
+            }));
+        }
+        return registration;
+    }
+
+    async function subscribe(form) {
+        if (await Notification.requestPermission() !== 'granted') {
+            throw new Error('Allow notifications for this site in the browser settings');
+        }
+        const registration = await activeWorker();
+        const subscription = await registration.pushManager.subscribe({
+            userVisibleOnly: true,
+            applicationServerKey: toBytes(form.dataset.key)
+        });
+        const res = await fetch('/settings/notifications/push', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify(subscription)
+        });
+        if (!res.ok) throw new Error((await res.text()).trim() || 'Request failed');
+        htmx.ajax('GET', '/settings/notifications', {target: '#content'});
+    }
+
+    document.addEventListener('submit', function(e) {
+        const form = e.target.closest('[data-push-subscribe]');
+        if (!form) return;
+        e.preventDefault();
+        const error = form.parentElement.querySelector('[data-push-error]');
+        subscribe(form).catch(err => {
+            error.textContent = err.message;
+            error.hidden = false;
+        });
+    });
+})();
@@ -0,0 +1,96 @@
+// Passkey registration and login. The server sends WebAuthn options with
+// binary fields base64url encoded and expects responses encoded the same way.
+(function() {
+    if (!window.PublicKeyCredential) return;
+
+    function toBytes(s) {
+        const b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+        return Uint8Array.from(atob(b64), c => c.charCodeAt(0));
+    }
+
+    function toBase64url(buf) {
+        const bytes = new Uint8Array(buf);
+        let s = '';
+        for (let i = 0; i < bytes.length; i++) s += String.fromCharCode(bytes[i]);
+        return btoa(s).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    async function post(url, body) {
+        const res = await fetch(url, {
+            method: 'POST',
+            headers: body ? {'Content-Type': 'application/json'} : {},
+            body: body ? JSON.stringify(body) : undefined
+        });
+        if (!res.ok) throw new Error((await res.text()).trim() || 'Request failed');
+        return res.status === 204 ? null : res.json();
+    }
+
+    function showError(container, err) {
+        const el = container.querySelector('[data-passkey-error]');
+        if (!el) return;
+        // Cancelling the browser prompt is not worth an error message
+        if (err.name === 'NotAllowedError' || err.name === 'AbortError') {
+            el.hidden = true;
+            return;
+        }
+        el.textContent = err.message;
+        el.hidden = false;
+    }
+
+    async function register(form) {
+        const options = await post('/settings/passkeys/options');
+        options.challenge = toBytes(options.challenge);
+        options.user.id = toBytes(options.user.id);
+        options.excludeCredentials.forEach(c => c.id = toBytes(c.id));
+
+        const cred = await navigator.credentials.create({publicKey: options});
+        const publicKey = cred.response.getPublicKey();
+        if (!publicKey) throw new Error('This browser cannot register passkeys');
+
+        await post('/settings/passkeys', {
+            id: cred.id,
+            clientDataJSON: toBase64url(cred.response.clientDataJSON),
+            authenticatorData: toBase64url(cred.response.getAuthenticatorData()),
+            publicKey: toBase64url(publicKey),
+            name: form.elements.name.value
+        });
+        htmx.ajax('GET', '/settings/security', {target: '#content'});
+    }
+
+    async function login() {
+        const options = await post('/login/passkey/options');
+        options.challenge = toBytes(options.challenge);
+
+        const cred = await navigator.credentials.get({publicKey: options});
+        const result = await post('/login/passkey', {
+            id: cred.id,
+            clientDataJSON: toBase64url(cred.response.clientDataJSON),
+            authenticatorData: toBase64url(cred.response.authenticatorData),
+            signature: toBase64url(cred.response.signature),
+            userHandle: cred.response.userHandle ? toBase64url(cred.response.userHandle) : '',
+            remember: !!document.querySelector('.login-form [name=remember]:checked')
+        });
+        window.location = result.redirect;
+    }
+
+    document.addEventListener('submit', function(e) {
+        const form = e.target.closest('[data-passkey-register]');
+        if (!form) return;
+        e.preventDefault();
+        const section = form.parentElement;
+        register(form).catch(err => showError(section, err));
+    });
+
+    document.addEventListener('click', function(e) {
+        const container = e.target.closest('[data-passkey-login]');
+        if (!container || !e.target.closest('button')) return;
+        login().catch(err => showError(container, err));
+    });
+
+    // The passkey button is hidden unless the browser supports WebAuthn
+    function reveal() {
+        document.querySelectorAll('[data-passkey-login]').forEach(el => el.hidden = false);
+    }
+    document.addEventListener('DOMContentLoaded', reveal);
+    document.addEventListener('htmx:afterSwap', reveal);
+})();
@@ -1,14 +1,10 @@
-const CACHE_NAME = 'expense-tracker-v1';
+const CACHE_NAME = 'expense-tracker-v2';
 
-// Assets to cache for offline/instant startup
+// Assets to cache for offline/instant startup. Styles and scripts have
+// fingerprinted URLs that change on deploy, so they are cached as the
+// start page loads them.
 const STATIC_ASSETS = [
-    '/',
-    '/static/style.css',
-    '/static/datepicker.js',
-    '/static/pull-to-refresh.js',
-    '/static/manifest.json',
-    '/static/favicon.svg',
-    '/static/apple-touch-icon.png'
+    '/'
 ];
 
 // Install: pre-cache static assets
@@ -120,3 +116,27 @@ self.addEventListener('fetch', (event) => {
     // API requests (like POST/DELETE expenses): network only
     // These are handled by the method check above
 });
+
+// Push: show the notification the server sent
+self.addEventListener('push', (event) => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(
+        self.registration.showNotification(data.title || 'Expense Tracker', {
+            body: data.body,
+            icon: '/static/apple-touch-icon.png',
+            data: {url: data.url || '/'}
+        })
+    );
+});
+
+// Notification click: open the page it's about, in a tab already open if any
+self.addEventListener('notificationclick', (event) => {
+    event.notification.close();
+    const url = event.notification.data.url;
+    event.waitUntil(
+        self.clients.matchAll({type: 'window'}).then((windows) => {
+            const open = windows.find((w) => w.url === url);
+            return open ? open.focus() : self.clients.openWindow(url);
+        })
+    );
+});
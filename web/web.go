@@ -0,0 +1,29 @@
+// Package web holds the HTML templates, email templates, and static files,
+// embedded so the server binary runs from any directory.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates static
+var files embed.FS
+
+// Templates returns the page templates, with email templates under email/.
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+// Static returns the files served under /static/.
+func Static() fs.FS {
+	return sub("static")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err) // dir is a constant that is embedded above
+	}
+	return fsys
+}
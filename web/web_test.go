@@ -0,0 +1,19 @@
+package web
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedFiles(t *testing.T) {
+	for _, name := range []string{"base.html", "login.html", "email/verify-email.txt"} {
+		_, err := fs.Stat(Templates(), name)
+		assert.NoError(t, err, name)
+	}
+	for _, name := range []string{"style.css", "sw.js", "manifest.json"} {
+		_, err := fs.Stat(Static(), name)
+		assert.NoError(t, err, name)
+	}
+}